@@ -0,0 +1,113 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+type LogLevelResponse struct {
+	Level string `json:"level"`
+}
+
+type LogLevelRequest struct {
+	Level string `json:"level"`
+}
+
+func (h *Handlers) GetLogLevel(c echo.Context) error {
+	return c.JSON(http.StatusOK, LogLevelResponse{
+		Level: h.logger.GetLevel().String(),
+	})
+}
+
+func (h *Handlers) SetLogLevel(c echo.Context) error {
+	var req LogLevelRequest
+	if status, err := bindJSON(c, &req); err != nil {
+		return h.problemResponse(c, status, err.Error())
+	}
+
+	level, err := logrus.ParseLevel(req.Level)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid log level: " + req.Level})
+	}
+
+	oldLevel := h.logger.GetLevel()
+	h.logger.SetLevel(level)
+
+	h.logger.WithFields(logrus.Fields{
+		"old_level": oldLevel.String(),
+		"new_level": level.String(),
+	}).Info("Log level changed")
+
+	return c.JSON(http.StatusOK, LogLevelResponse{
+		Level: level.String(),
+	})
+}
+
+// DebugResponse is whether 500 responses carry error details.
+type DebugResponse struct {
+	Debug bool `json:"debug"`
+}
+
+type DebugRequest struct {
+	Debug *bool `json:"debug"`
+}
+
+func (h *Handlers) GetDebug(c echo.Context) error {
+	return c.JSON(http.StatusOK, DebugResponse{Debug: h.debug.Load()})
+}
+
+// SetDebug switches server.debug until the next restart.
+func (h *Handlers) SetDebug(c echo.Context) error {
+	var req DebugRequest
+	if status, err := bindJSON(c, &req); err != nil {
+		return h.problemResponse(c, status, err.Error())
+	}
+	if req.Debug == nil {
+		return h.problemResponse(c, http.StatusBadRequest, `Field "debug" is required`)
+	}
+
+	old := h.debug.Swap(*req.Debug)
+	entry := h.logger.WithFields(logrus.Fields{
+		"old_debug": old,
+		"new_debug": *req.Debug,
+	})
+	if *req.Debug {
+		entry.Warn("Debug mode changed; 500 responses include errors and stacks")
+	} else {
+		entry.Info("Debug mode changed")
+	}
+
+	return c.JSON(http.StatusOK, DebugResponse{Debug: *req.Debug})
+}
+
+// Backup streams the same archive as "greetd backup create". The log files
+// are added with ?include_logs=1.
+func (h *Handlers) Backup(c echo.Context) error {
+	includeLogs, err := boolParam(c, "include_logs")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	name := "greetd-backup-" + time.Now().UTC().Format("20060102T150405Z") + ".tar.gz"
+	c.Response().Header().Set(echo.HeaderContentType, "application/gzip")
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name))
+	c.Response().WriteHeader(http.StatusOK)
+
+	opts := storage.BackupOptions{
+		ConfigPath:    h.configPath,
+		AppLogPath:    h.appLogPath,
+		AccessLogPath: h.accessLogPath,
+		IncludeLogs:   includeLogs,
+	}
+	if err := storage.WriteBackup(c.Response(), h.store, h.stats, opts); err != nil {
+		// The status is already sent; the truncated archive fails to
+		// unpack.
+		h.logger.WithError(err).Error("Failed to write backup")
+	}
+	return nil
+}
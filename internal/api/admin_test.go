@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+func setupAdminServer(t *testing.T, keys []string, allowOpen bool) (*Server, *logrus.Logger) {
+	return setupServer(t, func(cfg *config.Config) {
+		cfg.Security.APIKeys = keys
+		cfg.Security.AllowOpenAdmin = allowOpen
+	})
+}
+
+func TestLogLevelEndpoints(t *testing.T) {
+	server, logger := setupAdminServer(t, []string{"secret"}, false)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/loglevel", nil)
+	req.Header.Set(APIKeyHeader, "secret")
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level":"info"}`, rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(`{"level":"debug"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level":"debug"}`, rec.Body.String())
+	assert.Equal(t, logrus.DebugLevel, logger.GetLevel())
+
+	req = httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(`{"level":"loud"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(APIKeyHeader, "secret")
+	rec = httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, logrus.DebugLevel, logger.GetLevel())
+}
+
+func TestDebugEndpoints(t *testing.T) {
+	server, logger := setupAdminServer(t, []string{"secret"}, false)
+	logger.SetOutput(&bytes.Buffer{})
+	server.echo.GET("/boom", func(c echo.Context) error {
+		return errors.New("database exploded")
+	})
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(APIKeyHeader, "secret")
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "/admin/debug", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"debug":false}`, rec.Body.String())
+	assert.NotContains(t, serve(http.MethodGet, "/boom", "").Body.String(), "database exploded")
+
+	rec = serve(http.MethodPut, "/admin/debug", `{"debug":true}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"debug":true}`, rec.Body.String())
+	assert.Contains(t, serve(http.MethodGet, "/boom", "").Body.String(), "database exploded")
+
+	rec = serve(http.MethodPut, "/admin/debug", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, mimeProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	assert.Contains(t, rec.Body.String(), `"detail":"Field \"debug\" is required"`)
+	rec = serve(http.MethodGet, "/admin/debug", "")
+	assert.JSONEq(t, `{"debug":true}`, rec.Body.String())
+
+	serve(http.MethodPut, "/admin/debug", `{"debug":false}`)
+	assert.NotContains(t, serve(http.MethodGet, "/boom", "").Body.String(), "database exploded")
+}
+
+func TestAdminAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       []string
+		allowOpen  bool
+		key        string
+		statusCode int
+	}{
+		{"no keys configured", nil, false, "", http.StatusForbidden},
+		{"no keys configured, open admin", nil, true, "", http.StatusOK},
+		{"missing key", []string{"secret"}, false, "", http.StatusUnauthorized},
+		{"wrong key", []string{"secret"}, false, "nope", http.StatusUnauthorized},
+		{"valid key", []string{"other", "secret"}, false, "secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := setupAdminServer(t, tt.keys, tt.allowOpen)
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/loglevel", nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			rec := httptest.NewRecorder()
+			server.echo.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.statusCode, rec.Code)
+		})
+	}
+}
+
+func TestBackupEndpoint(t *testing.T) {
+	server, _ := setupAdminServer(t, []string{"secret"}, false)
+	require.NoError(t, server.handlers.store.SetMessage(context.Background(), "Backed up", storage.UpdatedByAPI))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/backup", nil)
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/backup?include_logs=1", nil)
+	req.Header.Set(APIKeyHeader, "secret")
+	rec = httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/gzip", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), `attachment; filename="greetd-backup-`)
+
+	files, err := storage.ReadBackup(rec.Body, nil)
+	require.NoError(t, err)
+	var messages []byte
+	for _, file := range files {
+		if file.Name == storage.MessagesFileName {
+			messages = file.Data
+		}
+	}
+	assert.Contains(t, string(messages), "Backed up")
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/backup?include_logs=maybe", nil)
+	req.Header.Set(APIKeyHeader, "secret")
+	rec = httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	server, _ := setupAdminServer(t, []string{"secret"}, false)
+
+	serve := func(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+	admin := map[string]string{APIKeyHeader: "secret", "Content-Type": "application/json"}
+
+	rec := serve(http.MethodPost, "/admin/maintenance", `{"enabled":true,"message":"Back soon"}`, nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = serve(http.MethodPost, "/admin/maintenance", `{"message":"Back soon"}`, admin)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serve(http.MethodPost, "/admin/maintenance", `{"enabled":true,"message":"Back soon"}`, admin)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"enabled":true`)
+	assert.Contains(t, rec.Body.String(), `"updated_by":"api-key:`)
+
+	for _, path := range []string{"/api/v1/hello", "/api/v1/message", "/hello", "/api/v1/messages/motd"} {
+		rec = serve(http.MethodGet, path, "", nil)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code, path)
+		assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"), path)
+		assert.JSONEq(t, `{"type":"about:blank","title":"Service Unavailable","status":503,"detail":"Back soon"}`, rec.Body.String(), path)
+	}
+
+	rec = serve(http.MethodGet, "/ui", "", map[string]string{"Accept": "text/html"})
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), "Back soon")
+
+	rec = serve(http.MethodGet, "/api/v1/health", "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"checks":{"maintenance":"enabled","storage":"ok","templates":"ok"}`)
+
+	rec = serve(http.MethodGet, "/api/v1/readyz", "", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"status":"not ready"`)
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/metrics", "", nil).Code)
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/admin/loglevel", "", admin).Code)
+
+	rec = serve(http.MethodGet, "/admin/maintenance", "", admin)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"message":"Back soon"`)
+
+	// The mode is persisted in the data directory.
+	maintenance := storage.NewMaintenance(server.handlers.statePath)
+	require.NoError(t, maintenance.Load())
+	assert.True(t, maintenance.Get().Enabled)
+
+	rec = serve(http.MethodPost, "/admin/maintenance", `{"enabled":false}`, admin)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/v1/hello", "", nil).Code)
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/v1/readyz", "", nil).Code)
+}
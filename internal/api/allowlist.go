@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// IPAllowlist answers 403 to clients outside security.allow_cidrs. The
+// client address is the one the IP extractor derives, so forwarding headers
+// only count when they come from a trusted proxy. Without allowed ranges
+// every client passes.
+func (h *Handlers) IPAllowlist(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if len(h.allowNets) == 0 {
+			return next(c)
+		}
+
+		ip := c.RealIP()
+		if containsIP(h.allowNets, net.ParseIP(ip)) {
+			return next(c)
+		}
+		h.logger.WithFields(logrus.Fields{
+			"remote_ip": ip,
+			"method":    c.Request().Method,
+			"uri":       h.redact.URI(c.Request().RequestURI),
+		}).Warn("Request denied by security.allow_cidrs")
+		return h.problemResponse(c, http.StatusForbidden, "Requests from this address are not allowed")
+	}
+}
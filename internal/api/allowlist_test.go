@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+func TestIPAllowlist(t *testing.T) {
+	tests := []struct {
+		name       string
+		allow      []string
+		trusted    []string
+		remoteAddr string
+		forwarded  string
+		statusCode int
+	}{
+		{"no restriction", nil, nil, "203.0.113.7:4000", "", http.StatusOK},
+		{"IPv4 inside", []string{"10.1.0.0/16"}, nil, "10.1.2.3:4000", "", http.StatusOK},
+		{"IPv4 outside", []string{"10.1.0.0/16"}, nil, "10.2.0.1:4000", "", http.StatusForbidden},
+		{"single IPv4", []string{"192.0.2.10"}, nil, "192.0.2.10:4000", "", http.StatusOK},
+		{"IPv6 inside", []string{"2001:db8:1::/48"}, nil, "[2001:db8:1::42]:4000", "", http.StatusOK},
+		{"IPv6 outside", []string{"2001:db8:1::/48"}, nil, "[2001:db8:2::42]:4000", "", http.StatusForbidden},
+		{"IPv4 range does not admit IPv6", []string{"10.1.0.0/16"}, nil, "[2001:db8:1::42]:4000", "", http.StatusForbidden},
+		{"forwarded by trusted proxy", []string{"10.1.0.0/16"}, []string{"127.0.0.1"}, "127.0.0.1:4000", "10.1.2.3", http.StatusOK},
+		{"forwarded outside by trusted proxy", []string{"10.1.0.0/16"}, []string{"127.0.0.1"}, "127.0.0.1:4000", "203.0.113.7", http.StatusForbidden},
+		{"forwarded by untrusted client", []string{"10.1.0.0/16"}, nil, "203.0.113.7:4000", "10.1.2.3", http.StatusForbidden},
+		{"proxy itself is not the client", []string{"127.0.0.1"}, []string{"127.0.0.1"}, "127.0.0.1:4000", "203.0.113.7", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := setupServer(t, func(cfg *config.Config) {
+				cfg.Security.AllowCIDRs = tt.allow
+				cfg.Server.TrustedProxies = tt.trusted
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/message", strings.NewReader(`{"message":"Hi"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set(echo.HeaderXForwardedFor, tt.forwarded)
+			}
+			rec := httptest.NewRecorder()
+			server.echo.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.statusCode, rec.Code, rec.Body.String())
+			if tt.statusCode == http.StatusForbidden {
+				assert.Equal(t, mimeProblemJSON, rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestIPAllowlistRoutes(t *testing.T) {
+	server, logger := setupServer(t, func(cfg *config.Config) {
+		cfg.Security.AllowCIDRs = []string{"10.0.0.0/8", "fd00::/8"}
+		cfg.Security.AllowOpenAdmin = true
+	})
+	var logs bytes.Buffer
+	logger.SetOutput(&logs)
+
+	status := func(method, target string) int {
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = "203.0.113.7:4000"
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, route := range [][2]string{
+		{http.MethodPost, "/api/v1/message"},
+		{http.MethodPost, "/api/v1/message/undo"},
+		{http.MethodPost, "/api/v1/messages/news"},
+		{http.MethodDelete, "/api/v1/messages/news"},
+		{http.MethodGet, "/api/stats"},
+		{http.MethodDelete, "/api/stats"},
+		{http.MethodGet, "/admin/loglevel"},
+		{http.MethodGet, "/logs"},
+		{http.MethodGet, "/api/v1/logs"},
+	} {
+		assert.Equal(t, http.StatusForbidden, status(route[0], route[1]), "%s %s", route[0], route[1])
+	}
+	assert.Contains(t, logs.String(), "Request denied by security.allow_cidrs")
+	assert.Contains(t, logs.String(), "remote_ip=203.0.113.7")
+
+	// Reading stays open to everyone.
+	assert.Equal(t, http.StatusOK, status(http.MethodGet, "/api/v1/message"))
+	assert.Equal(t, http.StatusOK, status(http.MethodGet, "/api/v1/hello"))
+}
+
+func TestIPAllowlistInvalid(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DataPath = t.TempDir()
+	cfg.Security.AllowCIDRs = []string{"10.0.0.0/33"}
+	_, err := NewHandlers(cfg, storage.NewMessageStore(cfg.DataPath), logrus.New())
+	require.ErrorContains(t, err, "invalid security.allow_cidrs")
+}
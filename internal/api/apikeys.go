@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// apiKeyContextKey holds the name of the API key a request was
+// authenticated with, for updated_by and the request log.
+const apiKeyContextKey = "api_key_name"
+
+// APIKeyAuth protects routes with the API keys of security.api_keys and
+// those managed with greetd apikey. When there are neither, requests are
+// rejected with 403 unless security.allow_open_admin is set.
+func (h *Handlers) APIKeyAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		h.refreshAPIKeys()
+		if len(h.apiKeys) == 0 && h.managedKeys.Len() == 0 {
+			if h.allowOpenAdmin {
+				return next(c)
+			}
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "Admin endpoints are disabled: no API keys configured"})
+		}
+
+		if requestAPIKey(c.Request()) == "" {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "API key required"})
+		}
+		name := h.apiKeyName(c)
+		if name == "" {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid API key"})
+		}
+		return next(c)
+	}
+}
+
+// apiKeyName names the API key of a request and records it in the request
+// context: "api-key:" and the name of a managed key, or the start of the
+// SHA-256 of a configured one. It returns "" when the request has no valid
+// key.
+func (h *Handlers) apiKeyName(c echo.Context) string {
+	if name, ok := c.Get(apiKeyContextKey).(string); ok {
+		return name
+	}
+
+	name := apiKeyName(h.apiKeys, c.Request())
+	if name == "" {
+		h.refreshAPIKeys()
+		if managed, ok := h.managedKeys.Match(requestAPIKey(c.Request())); ok {
+			name = "api-key:" + managed
+		}
+	}
+	if name != "" {
+		c.Set(apiKeyContextKey, name)
+	}
+	return name
+}
+
+// refreshAPIKeys reads apikeys.json again if it changed, so keys created
+// or revoked with greetd apikey apply at once. A file that cannot be read
+// is logged once, and the keys already loaded stay in use.
+func (h *Handlers) refreshAPIKeys() {
+	changed, err := h.managedKeys.Refresh()
+	switch {
+	case err != nil:
+		if h.apiKeysFailed.CompareAndSwap(false, true) {
+			h.logger.WithError(err).Warn("Keeping the API keys already loaded")
+		}
+	case changed:
+		h.apiKeysFailed.Store(false)
+		h.logger.WithField("keys", h.managedKeys.Len()).Info("Reloaded API keys")
+	default:
+		h.apiKeysFailed.Store(false)
+	}
+}
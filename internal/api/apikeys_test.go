@@ -0,0 +1,66 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+func TestManagedAPIKeys(t *testing.T) {
+	server, logger := setupAdminServer(t, nil, false)
+	var logs bytes.Buffer
+	logger.SetOutput(&logs)
+
+	getLogLevel := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin/loglevel", nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusForbidden, getLogLevel(""))
+
+	// greetd apikey changes the file behind the server's back.
+	keys := storage.NewAPIKeys(server.handlers.statePath)
+	key, err := keys.Create("ci-bot")
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusUnauthorized, getLogLevel(""))
+	assert.Equal(t, http.StatusUnauthorized, getLogLevel("nope"))
+	assert.Equal(t, http.StatusOK, getLogLevel(key))
+	assert.Contains(t, logs.String(), "Reloaded API keys")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/message", strings.NewReader(`{"message":"Deployed"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"updated_by":"api-key:ci-bot"`)
+
+	// Revoking takes effect without a restart.
+	require.NoError(t, keys.Revoke("ci-bot"))
+	assert.Equal(t, http.StatusForbidden, getLogLevel(key))
+}
+
+func TestManagedAndConfiguredAPIKeys(t *testing.T) {
+	server, _ := setupAdminServer(t, []string{"secret"}, false)
+	key, err := storage.NewAPIKeys(server.handlers.statePath).Create("deploy")
+	require.NoError(t, err)
+
+	for _, k := range []string{"secret", key} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/loglevel", nil)
+		req.Header.Set(APIKeyHeader, k)
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// BindError is returned by Listen when an address cannot be bound.
+type BindError struct {
+	// Addr is the address that was asked for, such as "0.0.0.0:8080".
+	Addr string
+	// Retries is how many of the following ports were tried as well.
+	Retries int
+	// Owner names the process listening on the port, such as
+	// "greetd (pid 1234)", when it can be found.
+	Owner string
+	Err   error
+}
+
+func (e *BindError) Error() string {
+	msg := e.Err.Error()
+	if e.Retries > 0 {
+		msg += fmt.Sprintf(", and so are the next %d ports", e.Retries)
+	}
+	if e.Owner != "" {
+		msg += "; the port is held by " + e.Owner
+	}
+	return msg
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// InUse reports whether another socket holds the address, as opposed to
+// the address being invalid or not permitted.
+func (e *BindError) InUse() bool {
+	return addrInUse(e.Err)
+}
+
+// listen binds host and port, trying up to retries following ports while
+// the port is in use. Port 0 picks a free port and is not retried.
+func listen(host string, port, retries int) (net.Listener, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	l, err := net.Listen("tcp", addr)
+	if err == nil {
+		return l, nil
+	}
+	if port == 0 || !addrInUse(err) {
+		return nil, &BindError{Addr: addr, Err: err}
+	}
+
+	tried := 0
+	for next := port + 1; tried < retries && next <= 65535; next++ {
+		tried++
+		l, nextErr := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(next)))
+		if nextErr == nil {
+			return l, nil
+		}
+		if !addrInUse(nextErr) {
+			return nil, &BindError{Addr: net.JoinHostPort(host, strconv.Itoa(next)), Err: nextErr}
+		}
+	}
+	return nil, &BindError{Addr: addr, Retries: tried, Owner: portOwner(port), Err: err}
+}
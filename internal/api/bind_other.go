@@ -0,0 +1,13 @@
+//go:build !(unix || windows)
+
+package api
+
+// Other platforms neither retry the next port nor look for the owner.
+
+func addrInUse(err error) bool {
+	return false
+}
+
+func portOwner(port int) string {
+	return ""
+}
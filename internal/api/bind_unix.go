@@ -0,0 +1,14 @@
+//go:build unix
+
+package api
+
+import (
+	"errors"
+	"syscall"
+)
+
+// addrInUse reports whether err is a bind failure because another socket
+// holds the address.
+func addrInUse(err error) bool {
+	return errors.Is(err, syscall.EADDRINUSE)
+}
@@ -0,0 +1,8 @@
+//go:build unix && !linux
+
+package api
+
+// portOwner is not implemented without /proc; lsof -i shows the owner.
+func portOwner(port int) string {
+	return ""
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package api
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// addrInUse reports whether err is a bind failure because another socket
+// holds the address.
+func addrInUse(err error) bool {
+	return errors.Is(err, windows.WSAEADDRINUSE)
+}
+
+// portOwner is not implemented on Windows; netstat -ano shows the owner.
+func portOwner(port int) string {
+	return ""
+}
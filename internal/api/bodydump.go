@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// BodyDump logs the headers and bodies of each request and its response at
+// debug level, for debugging clients. Only textual bodies are logged, such
+// as JSON, YAML, plain text and forms, each cut off after limit bytes; the
+// rest of the body is never buffered. Credentials in headers are redacted,
+// and so are the query parameters of redact, along with the response to a
+// request that had one, as it may repeat the value.
+// Entries carry the request ID of the access log. Requests for which skip
+// returns true are not logged, and nothing is logged while the logger is
+// above debug level.
+func BodyDump(logger *logrus.Logger, limit int, redact *Redactor, skip func(echo.Context) bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !logger.IsLevelEnabled(logrus.DebugLevel) || (skip != nil && skip(c)) {
+				return next(c)
+			}
+
+			req := c.Request()
+			var reqBody []byte
+			reqTruncated := false
+			if req.Body != nil && dumpable(req.Header.Get(echo.HeaderContentType)) {
+				var err error
+				reqBody, err = io.ReadAll(io.LimitReader(req.Body, int64(limit)+1))
+				if err != nil {
+					return err
+				}
+				// The handler reads the whole body: what was read
+				// here, then the rest.
+				req.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(reqBody), req.Body), req.Body}
+				if len(reqBody) > limit {
+					reqBody, reqTruncated = reqBody[:limit], true
+				}
+			}
+
+			res := c.Response()
+			capture := &dumpWriter{ResponseWriter: res.Writer, limit: limit}
+			res.Writer = capture
+			err := next(c)
+			if err != nil {
+				// Answer the error here so the response is captured; the
+				// error handler leaves committed responses alone when the
+				// request logger passes the error on again.
+				c.Error(err)
+			}
+			res.Writer = capture.ResponseWriter
+
+			fields := logrus.Fields{
+				"request_id":       res.Header().Get(echo.HeaderXRequestID),
+				"method":           req.Method,
+				"uri":              redact.URI(req.RequestURI),
+				"status":           res.Status,
+				"request_headers":  redact.Headers(req.Header),
+				"response_headers": redact.Headers(res.Header()),
+			}
+			if reqBody != nil {
+				fields["request_body"] = dumpBody(reqBody, reqTruncated)
+			}
+			switch {
+			case redact.Hides(req.URL.RawQuery):
+				fields["response_body"] = redactedValue
+			case dumpable(res.Header().Get(echo.HeaderContentType)):
+				fields["response_body"] = dumpBody(capture.body.Bytes(), capture.truncated)
+			}
+			logger.WithFields(fields).Debug("HTTP bodies")
+			return err
+		}
+	}
+}
+
+// dumpable reports whether bodies of contentType are text worth logging.
+func dumpable(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case echo.MIMEApplicationJSON, echo.MIMEApplicationForm, echo.MIMETextPlain,
+		mimeYAML, "application/x-yaml", "text/yaml":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json")
+}
+
+func dumpBody(body []byte, truncated bool) string {
+	if truncated {
+		return string(body) + "…(truncated)"
+	}
+	return string(body)
+}
+
+// dumpWriter passes a response through, keeping its first limit bytes.
+type dumpWriter struct {
+	http.ResponseWriter
+	limit     int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *dumpWriter) Write(b []byte) (int, error) {
+	if room := w.limit - w.body.Len(); room > 0 {
+		w.body.Write(b[:min(room, len(b))])
+		w.truncated = w.truncated || len(b) > room
+	} else if len(b) > 0 {
+		w.truncated = true
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *dumpWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *dumpWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+func (w *dumpWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+// dumpEntries returns the "HTTP bodies" entries logged as JSON to buf.
+func dumpEntries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		if entry["msg"] == "HTTP bodies" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func TestBodyDump(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetLevel(logrus.DebugLevel)
+
+	e := echo.New()
+	e.Use(middleware.RequestID())
+	e.Use(BodyDump(logger, 16, nil, func(c echo.Context) bool { return c.Path() == "/skipped" }))
+	e.POST("/echo", func(c echo.Context) error {
+		var body map[string]string
+		if err := c.Bind(&body); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, body)
+	})
+	e.GET("/binary", func(c echo.Context) error {
+		return c.Blob(http.StatusOK, "application/gzip", []byte{0x1f, 0x8b})
+	})
+	e.GET("/skipped", func(c echo.Context) error { return c.String(http.StatusOK, "hidden") })
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		buf.Reset()
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{"message":"a long message body"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("X-API-Key", "secret")
+	req.Header.Set(echo.HeaderAuthorization, "Bearer secret")
+	rec := serve(req)
+	// The handler still reads the whole body.
+	assert.JSONEq(t, `{"message":"a long message body"}`, rec.Body.String())
+
+	entries := dumpEntries(t, &buf)
+	require.Len(t, entries, 1)
+	entry := entries[0]
+	assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), entry["request_id"])
+	assert.Equal(t, float64(http.StatusOK), entry["status"])
+	assert.Equal(t, `{"message":"a lo…(truncated)`, entry["request_body"])
+	assert.Equal(t, `{"message":"a lo…(truncated)`, entry["response_body"])
+	headers := entry["request_headers"].(map[string]interface{})
+	assert.Equal(t, redactedValue, headers["X-Api-Key"])
+	assert.Equal(t, redactedValue, headers["Authorization"])
+	assert.NotContains(t, buf.String(), "secret")
+
+	// Errors are dumped with the response the error handler wrote.
+	req = httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = serve(req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	entries = dumpEntries(t, &buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, float64(http.StatusBadRequest), entries[0]["status"])
+	assert.Contains(t, entries[0]["response_body"], "message")
+
+	// Binary bodies are left out.
+	serve(httptest.NewRequest(http.MethodGet, "/binary", nil))
+	entries = dumpEntries(t, &buf)
+	require.Len(t, entries, 1)
+	assert.NotContains(t, entries[0], "response_body")
+
+	serve(httptest.NewRequest(http.MethodGet, "/skipped", nil))
+	assert.Empty(t, dumpEntries(t, &buf))
+
+	// Nothing is logged above debug level.
+	logger.SetLevel(logrus.InfoLevel)
+	serve(httptest.NewRequest(http.MethodGet, "/binary", nil))
+	assert.Empty(t, dumpEntries(t, &buf))
+}
+
+func TestBodyDumpServer(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		server, logger := setupServer(t, func(cfg *config.Config) {
+			cfg.Logging.DumpBodies = enabled
+		})
+		var buf bytes.Buffer
+		logger.SetOutput(&buf)
+		logger.SetFormatter(&logrus.JSONFormatter{})
+		logger.SetLevel(logrus.DebugLevel)
+
+		serve := func(target string) {
+			buf.Reset()
+			rec := httptest.NewRecorder()
+			server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			require.Equal(t, http.StatusOK, rec.Code, target)
+		}
+
+		serve("/api/v1/message")
+		entries := dumpEntries(t, &buf)
+		if !enabled {
+			assert.Empty(t, entries, "dumping is off by default")
+			continue
+		}
+		require.Len(t, entries, 1)
+		assert.Contains(t, entries[0]["response_body"], "Hello, World!")
+
+		serve("/metrics")
+		assert.Empty(t, dumpEntries(t, &buf), "/metrics is never dumped")
+	}
+}
+
+func TestDumpable(t *testing.T) {
+	for contentType, want := range map[string]bool{
+		"application/json":                  true,
+		"application/json; charset=UTF-8":   true,
+		"application/problem+json":          true,
+		"application/yaml":                  true,
+		"text/plain; charset=utf-8":         true,
+		"application/x-www-form-urlencoded": true,
+		"text/html; charset=utf-8":          false,
+		"application/gzip":                  false,
+		"application/octet-stream":          false,
+		"text/event-stream":                 false,
+		"":                                  false,
+	} {
+		assert.Equal(t, want, dumpable(contentType), contentType)
+	}
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// contentETag returns a strong ETag for data.
+func contentETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:])[:12] + `"`
+}
+
+// notModified sets the ETag header and reports whether the request's
+// If-None-Match already names etag, in which case 304 has been written and
+// the handler must not send a body.
+func notModified(c echo.Context, etag string) bool {
+	c.Response().Header().Set("ETag", etag)
+
+	for _, candidate := range strings.Split(c.Request().Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			c.Response().WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
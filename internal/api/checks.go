@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/svanhalla/prompt-lab/greetd/internal/health"
+)
+
+// CheckRegistry holds the dependency checks backends register, and runs
+// them for /readyz and the checks of /health.
+type CheckRegistry struct {
+	// timeout bounds each check; zero means none.
+	timeout time.Duration
+	// cacheTTL is how long results are reused; zero runs the checks every
+	// time.
+	cacheTTL time.Duration
+
+	mu       sync.Mutex
+	checkers []health.Checker
+	results  map[string]error
+	ran      time.Time
+}
+
+// NewCheckRegistry returns an empty registry whose checks each get
+// timeout, and whose results are reused for cacheTTL.
+func NewCheckRegistry(timeout, cacheTTL time.Duration) *CheckRegistry {
+	return &CheckRegistry{timeout: timeout, cacheTTL: cacheTTL}
+}
+
+// Register adds a check. A check with the name of one registered before
+// replaces it.
+func (r *CheckRegistry) Register(checker health.Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, registered := range r.checkers {
+		if registered.Name() == checker.Name() {
+			r.checkers[i] = checker
+			r.results = nil
+			return
+		}
+	}
+	r.checkers = append(r.checkers, checker)
+	r.results = nil
+}
+
+// Run returns the result of every check by name: nil, a health.Warning, or
+// why the dependency is not usable. The checks run concurrently, and one
+// that exceeds the timeout fails without holding up the others. Requests
+// arriving while the checks run wait for their results rather than running
+// them again.
+func (r *CheckRegistry) Run(ctx context.Context) map[string]error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.results != nil && time.Since(r.ran) < r.cacheTTL {
+		return r.results
+	}
+
+	// The results are shared, so a client hanging up must not fail them.
+	ctx = context.WithoutCancel(ctx)
+	errs := make([]error, len(r.checkers))
+	var wg sync.WaitGroup
+	for i, checker := range r.checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = r.check(ctx, checker)
+		}()
+	}
+	wg.Wait()
+
+	results := make(map[string]error, len(r.checkers))
+	for i, checker := range r.checkers {
+		results[checker.Name()] = errs[i]
+	}
+	r.results, r.ran = results, time.Now()
+	return results
+}
+
+// check runs checker, giving up on it after the timeout even if it ignores
+// ctx.
+func (r *CheckRegistry) check(ctx context.Context, checker health.Checker) error {
+	if r.timeout <= 0 {
+		return checker.Check(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- checker.Check(ctx)
+	}()
+	select {
+	case err := <-done:
+		if err != nil && ctx.Err() != nil {
+			return fmt.Errorf("timed out after %s", r.timeout)
+		}
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s", r.timeout)
+	}
+}
+
+// checkStatus is the entry of a check in a checks map: "ok", or the
+// warning or error.
+func checkStatus(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	return err.Error()
+}
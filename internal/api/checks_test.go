@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/health"
+)
+
+// sleepCheck is a check that ignores its context and takes d.
+func sleepCheck(name string, d time.Duration, calls *atomic.Int32) health.Checker {
+	return health.CheckFunc(name, func(ctx context.Context) error {
+		calls.Add(1)
+		time.Sleep(d)
+		return nil
+	})
+}
+
+func TestCheckRegistrySlowCheck(t *testing.T) {
+	handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+		cfg.Health.CheckTimeout = "50ms"
+		cfg.Health.CheckCache = "0"
+	})
+	defer os.RemoveAll(tmpDir)
+
+	var calls atomic.Int32
+	handlers.checks.Register(sleepCheck("slow", 5*time.Second, &calls))
+	handlers.checks.Register(sleepCheck("quick", 0, &calls))
+
+	start := time.Now()
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, handlers.Ready(echo.New().NewContext(req, rec)))
+	assert.Less(t, time.Since(start), time.Second, "the slow check must not hold up /readyz")
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var ready ReadyResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ready))
+	assert.Equal(t, "not ready", ready.Status)
+	assert.Equal(t, "timed out after 50ms", ready.Checks["slow"])
+	assert.Equal(t, "ok", ready.Checks["quick"])
+	assert.Equal(t, "ok", ready.Checks["storage"])
+
+	res, _ := getHealth(t, handlers, "")
+	assert.Equal(t, "degraded", res.Status)
+	assert.Equal(t, "timed out after 50ms", res.Checks["slow"])
+}
+
+func TestCheckRegistryConcurrent(t *testing.T) {
+	registry := NewCheckRegistry(time.Second, 0)
+	var calls atomic.Int32
+	for _, name := range []string{"a", "b", "c", "d"} {
+		registry.Register(sleepCheck(name, 100*time.Millisecond, &calls))
+	}
+
+	start := time.Now()
+	results := registry.Run(context.Background())
+	assert.Less(t, time.Since(start), 300*time.Millisecond, "the checks run concurrently")
+	assert.Equal(t, map[string]error{"a": nil, "b": nil, "c": nil, "d": nil}, results)
+}
+
+func TestCheckRegistryCache(t *testing.T) {
+	registry := NewCheckRegistry(time.Second, time.Hour)
+	var calls atomic.Int32
+	registry.Register(sleepCheck("dep", 0, &calls))
+
+	for range 5 {
+		registry.Run(context.Background())
+	}
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Registering a check runs them all again.
+	registry.Register(health.CheckFunc("other", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}))
+	results := registry.Run(context.Background())
+	assert.Equal(t, int32(2), calls.Load())
+	assert.EqualError(t, results["other"], "connection refused")
+
+	// A canceled request does not fail the shared results.
+	registry = NewCheckRegistry(time.Second, time.Hour)
+	registry.Register(health.CheckFunc("dep", func(ctx context.Context) error {
+		return ctx.Err()
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, registry.Run(ctx)["dep"])
+}
+
+func TestCheckRegistryWarning(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+	handlers.checks.Register(health.CheckFunc("queue", func(ctx context.Context) error {
+		return health.Warning("backlog of 1200 deliveries")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, handlers.Ready(echo.New().NewContext(req, rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"queue":"backlog of 1200 deliveries"`)
+
+	res, _ := getHealth(t, handlers, "")
+	assert.Equal(t, "ok", res.Status)
+	assert.Equal(t, "backlog of 1200 deliveries", res.Checks["queue"])
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+// routeScopes records which routes form the JSON API, which the admin
+// group and which the internal listener serves, so middleware that sees
+// every request, such as CORS, can treat them differently. Route middleware
+// would not do: echo answers preflight OPTIONS requests without running it.
+type routeScopes struct {
+	api      map[string]bool
+	admin    map[string]bool
+	internal map[string]bool
+}
+
+// inAPI reports whether c matched a JSON API route.
+func (s *routeScopes) inAPI(c echo.Context) bool {
+	return s.api[c.Path()]
+}
+
+// inAdmin reports whether c matched an admin or profiling route.
+func (s *routeScopes) inAdmin(c echo.Context) bool {
+	return s.admin[c.Path()]
+}
+
+// inInternal reports whether c matched a route of the internal listener.
+func (s *routeScopes) inInternal(c echo.Context) bool {
+	return s.internal[c.Path()]
+}
+
+// recordPaths runs register and returns the paths of the routes it added.
+func recordPaths(e *echo.Echo, register func()) map[string]bool {
+	paths := map[string]bool{}
+	onAdd := e.OnAddRouteHandler
+	e.OnAddRouteHandler = func(host string, route echo.Route, h echo.HandlerFunc, m []echo.MiddlewareFunc) {
+		paths[route.Path] = true
+		if onAdd != nil {
+			onAdd(host, route, h, m)
+		}
+	}
+	defer func() { e.OnAddRouteHandler = onAdd }()
+
+	register()
+	return paths
+}
+
+// CORS answers cross-origin requests from origins to the routes applies
+// matches, with the methods, headers, credentials and max age of cfg.
+// Requests to other routes, and every request when origins is empty, get
+// no CORS headers, so browsers keep them same-origin.
+func CORS(origins []string, cfg config.CORSConfig, applies func(c echo.Context) bool) echo.MiddlewareFunc {
+	if len(origins) == 0 {
+		return func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	}
+	return middleware.CORSWithConfig(middleware.CORSConfig{
+		Skipper:          func(c echo.Context) bool { return !applies(c) },
+		AllowOrigins:     origins,
+		AllowMethods:     cfg.AllowedMethods,
+		AllowHeaders:     cfg.AllowedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge,
+	})
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+func TestCORS(t *testing.T) {
+	const (
+		app     = "https://app.example.com"
+		console = "https://console.example.com"
+		other   = "https://evil.example.org"
+	)
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Security.APIKeys = []string{"secret"}
+		cfg.Server.LegacyRoutes = true
+		cfg.CORS.AllowedOrigins = []string{app, console}
+		cfg.CORS.AllowCredentials = true
+		cfg.CORS.MaxAge = 600
+		cfg.CORS.AdminAllowedOrigins = []string{console}
+	})
+
+	preflight := func(path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		req.Header.Set(echo.HeaderOrigin, origin)
+		req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodPost)
+		req.Header.Set(echo.HeaderAccessControlRequestHeaders, "content-type")
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		origin  string
+		allowed bool
+	}{
+		{name: "API, allowed origin", path: "/api/v1/message", origin: app, allowed: true},
+		{name: "API route with a parameter", path: "/api/v1/messages/motd", origin: app, allowed: true},
+		{name: "legacy API route", path: "/message", origin: app, allowed: true},
+		{name: "API, other origin", path: "/api/v1/message", origin: other},
+		{name: "admin, API origin", path: "/admin/loglevel", origin: app},
+		{name: "admin, admin origin", path: "/admin/loglevel", origin: console, allowed: true},
+		{name: "HTML page", path: "/ui/message", origin: app},
+		{name: "static asset", path: "/static/app.css", origin: app},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := preflight(tt.path, tt.origin)
+
+			// Preflights never reach the auth middleware.
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+			if !tt.allowed {
+				assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+				assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowMethods))
+				return
+			}
+			assert.Equal(t, tt.origin, rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+			assert.Equal(t, "true", rec.Header().Get(echo.HeaderAccessControlAllowCredentials))
+			assert.Equal(t, "GET,HEAD,POST,PUT,DELETE", rec.Header().Get(echo.HeaderAccessControlAllowMethods))
+			assert.Contains(t, rec.Header().Get(echo.HeaderAccessControlAllowHeaders), "X-API-Key")
+			assert.Equal(t, "600", rec.Header().Get(echo.HeaderAccessControlMaxAge))
+		})
+	}
+
+	// Actual requests carry the header too, and only for allowed origins.
+	get := func(path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderOrigin, origin)
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, app, get("/api/v1/hello", app).Header().Get(echo.HeaderAccessControlAllowOrigin))
+	assert.Empty(t, get("/api/v1/hello", other).Header().Get(echo.HeaderAccessControlAllowOrigin))
+	assert.Empty(t, get("/ui", app).Header().Get(echo.HeaderAccessControlAllowOrigin))
+}
+
+func TestCORSWildcard(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.CORS.AllowedOrigins = []string{"*"}
+	})
+
+	for path, want := range map[string]string{
+		"/api/v1/message": "*",
+		"/admin/loglevel": "",
+		"/ui":             "",
+	} {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		req.Header.Set(echo.HeaderOrigin, "https://anywhere.example.com")
+		req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodGet)
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+
+		assert.Equal(t, want, rec.Header().Get(echo.HeaderAccessControlAllowOrigin), path)
+		assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowCredentials), path)
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/svanhalla/prompt-lab/greetd/internal/diff"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+// diffContext is the number of unchanged lines around each change of a
+// unified diff.
+const diffContext = 3
+
+// MessageDiffResponse is how a message changed between two revisions.
+type MessageDiffResponse struct {
+	Key  string `json:"key" yaml:"key"`
+	From int64  `json:"from" yaml:"from"`
+	To   int64  `json:"to" yaml:"to"`
+	// Unified is the change as a unified diff; "" when the text is the same.
+	Unified string   `json:"unified" yaml:"unified"`
+	Added   []string `json:"added" yaml:"added"`
+	Removed []string `json:"removed" yaml:"removed"`
+	// Lines are the lines of both revisions, in order, each marked as
+	// unchanged, deleted or inserted.
+	Lines []diff.Line `json:"lines" yaml:"lines"`
+}
+
+// MessageDiff compares two revisions of the default message.
+func (h *Handlers) MessageDiff(c echo.Context) error {
+	return h.messageDiff(c, storage.DefaultKey)
+}
+
+// KeyedMessageDiff compares two revisions of the message stored under :key.
+func (h *Handlers) KeyedMessageDiff(c echo.Context) error {
+	key := c.Param("key")
+	if !storage.ValidKey(key) {
+		return h.storeError(c, storage.ErrInvalidKey)
+	}
+	return h.messageDiff(c, key)
+}
+
+// messageDiff answers with the change between the revisions ?from and ?to
+// of the message under key. ?to defaults to the current revision and ?from
+// to the one before ?to, or to ?to itself when it is the oldest. Both must
+// still be in the history.
+func (h *Handlers) messageDiff(c echo.Context, key string) error {
+	from, hasFrom, err := revisionParam(c, "from")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	to, hasTo, err := revisionParam(c, "to")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	revisions, err := h.store.Revisions(c.Request().Context(), key)
+	if err != nil {
+		return h.storeError(c, err)
+	}
+
+	toIndex := len(revisions) - 1
+	if hasTo {
+		if toIndex = findRevision(revisions, to); toIndex < 0 {
+			return h.problemResponse(c, http.StatusNotFound, revisionNotFound(to, revisions))
+		}
+	}
+	// The oldest revision is compared with itself.
+	fromIndex := max(toIndex-1, 0)
+	if hasFrom {
+		if fromIndex = findRevision(revisions, from); fromIndex < 0 {
+			return h.problemResponse(c, http.StatusNotFound, revisionNotFound(from, revisions))
+		}
+	}
+
+	older, newer := revisions[fromIndex], revisions[toIndex]
+	lines := diff.Lines(older.Message, newer.Message)
+	res := MessageDiffResponse{
+		Key:     key,
+		From:    older.Revision,
+		To:      newer.Revision,
+		Unified: diff.Unified(revisionName(older.Revision), revisionName(newer.Revision), lines, diffContext),
+		Added:   []string{},
+		Removed: []string{},
+		Lines:   lines,
+	}
+	for _, line := range lines {
+		switch line.Op {
+		case diff.Insert:
+			res.Added = append(res.Added, line.Text)
+		case diff.Delete:
+			res.Removed = append(res.Removed, line.Text)
+		}
+	}
+	return negotiate(c, http.StatusOK, res, res.Unified)
+}
+
+// revisionParam parses the revision in query parameter name, and reports
+// whether there was one.
+func revisionParam(c echo.Context, name string) (int64, bool, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return 0, false, nil
+	}
+	revision, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || revision < 0 {
+		return 0, false, errors.New(name + " must be a revision number")
+	}
+	return revision, true, nil
+}
+
+// findRevision returns the index of revision in revisions, or -1.
+func findRevision(revisions []storage.MessageData, revision int64) int {
+	for i, data := range revisions {
+		if data.Revision == revision {
+			return i
+		}
+	}
+	return -1
+}
+
+func revisionNotFound(revision int64, revisions []storage.MessageData) string {
+	return fmt.Sprintf("Revision %d is not in the history, which holds revisions %s", revision, formatRevisions(revisions))
+}
+
+// formatRevisions lists the revision numbers of revisions, which are
+// sorted, with consecutive ones as ranges: "0, 2-5".
+func formatRevisions(revisions []storage.MessageData) string {
+	var parts []string
+	for i := 0; i < len(revisions); {
+		j := i
+		for j+1 < len(revisions) && revisions[j+1].Revision == revisions[j].Revision+1 {
+			j++
+		}
+		part := strconv.FormatInt(revisions[i].Revision, 10)
+		if j > i {
+			part += "-" + strconv.FormatInt(revisions[j].Revision, 10)
+		}
+		parts = append(parts, part)
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
+
+func revisionName(revision int64) string {
+	return "revision " + strconv.FormatInt(revision, 10)
+}
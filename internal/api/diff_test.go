@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/diff"
+)
+
+func TestMessageDiff(t *testing.T) {
+	server, _ := setupServer(t, nil)
+
+	do := func(method, target, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+	diffOf := func(target string) MessageDiffResponse {
+		t.Helper()
+		rec := do(http.MethodGet, target, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var res MessageDiffResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return res
+	}
+
+	// A fresh message has nothing to compare with.
+	res := diffOf("/api/v1/message/diff")
+	assert.Equal(t, int64(0), res.From)
+	assert.Equal(t, int64(0), res.To)
+	assert.Empty(t, res.Unified)
+	assert.Empty(t, res.Added)
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1/message", `{"message":"Hello\nthere"}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1/message", `{"message":"Hello\nyou"}`).Code)
+
+	res = diffOf("/api/v1/message/diff")
+	assert.Equal(t, "default", res.Key)
+	assert.Equal(t, int64(1), res.From)
+	assert.Equal(t, int64(2), res.To)
+	assert.Equal(t, []string{"you"}, res.Added)
+	assert.Equal(t, []string{"there"}, res.Removed)
+	assert.Equal(t, []diff.Line{{Op: diff.Equal, Text: "Hello"}, {Op: diff.Delete, Text: "there"}, {Op: diff.Insert, Text: "you"}}, res.Lines)
+	assert.Equal(t, "--- revision 1\n+++ revision 2\n@@ -1,2 +1,2 @@\n Hello\n-there\n+you\n", res.Unified)
+
+	res = diffOf("/api/v1/message/diff?from=0&to=1")
+	assert.Equal(t, []string{"Hello", "there"}, res.Added)
+	assert.Equal(t, []string{"Hello, World!"}, res.Removed)
+
+	rec := do(http.MethodGet, "/api/v1/message/diff?from=0", "", "Accept", "text/plain")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "--- revision 0\n+++ revision 2\n"), rec.Body.String())
+
+	rec = do(http.MethodGet, "/api/v1/message/diff?to=7", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, mimeProblemJSON, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "Revision 7 is not in the history, which holds revisions 0-2")
+
+	rec = do(http.MethodGet, "/api/v1/message/diff?from=latest", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"error":"from must be a revision number"}`, rec.Body.String())
+}
+
+func TestKeyedMessageDiff(t *testing.T) {
+	server, _ := setupServer(t, nil)
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/messages/motd/diff", "").Code)
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1/messages/motd", `{"message":"Open"}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1/messages/motd", `{"message":"Closed"}`).Code)
+
+	rec := do(http.MethodGet, "/api/v1/messages/motd/diff", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var res MessageDiffResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "motd", res.Key)
+	assert.Equal(t, []string{"Closed"}, res.Added)
+	assert.Equal(t, []string{"Open"}, res.Removed)
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+// DraftRequest is the body of PUT /message/draft.
+type DraftRequest struct {
+	Message string `json:"message"`
+}
+
+// GetDraft returns the draft of the message. Its revision counts the
+// writes to the draft, not to the message.
+func (h *Handlers) GetDraft(c echo.Context) error {
+	draft, err := h.store.GetDraft(c.Request().Context(), storage.DefaultKey)
+	if err != nil {
+		return h.storeError(c, err)
+	}
+	setLastModified(c, draft.UpdatedAt)
+	return negotiate(c, http.StatusOK, newMessageResponse(draft), draft.Message)
+}
+
+// SetDraft stores the draft of the message, validated like POST /message.
+// The message served by GET /message is unchanged until PublishDraft.
+func (h *Handlers) SetDraft(c echo.Context) error {
+	var req DraftRequest
+	if status, err := bindJSON(c, &req); err != nil {
+		return h.problemResponse(c, status, err.Error())
+	}
+	message := MessageRequest{Message: req.Message}
+	if status, err := h.prepareMessage(&message); err != nil {
+		return h.problemResponse(c, status, err.Error())
+	}
+
+	draft, err := h.store.SetDraft(c.Request().Context(), storage.DefaultKey, message.Message, h.updatedBy(c))
+	if err != nil {
+		return h.storeError(c, err)
+	}
+	return c.JSON(http.StatusOK, newMessageResponse(draft))
+}
+
+// DeleteDraft discards the draft of the message.
+func (h *Handlers) DeleteDraft(c echo.Context) error {
+	if err := h.store.DeleteDraft(c.Request().Context(), storage.DefaultKey); err != nil {
+		return h.storeError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// PublishDraft makes the draft the message, as a new revision written by
+// the publisher that can be undone, and removes the draft.
+func (h *Handlers) PublishDraft(c echo.Context) error {
+	data, err := h.store.PublishDraft(c.Request().Context(), storage.DefaultKey, h.updatedBy(c))
+	if errors.Is(err, storage.ErrNoDraft) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "No draft to publish"})
+	}
+	if err != nil {
+		return h.storeError(c, err)
+	}
+
+	c.Response().Header().Set("ETag", revisionETag(data.Revision))
+	return c.JSON(http.StatusOK, newMessageResponse(data))
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+func TestMessageDraft(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Message.MaxLength = 20
+	})
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+	message := func() MessageResponse {
+		t.Helper()
+		rec := do(http.MethodGet, "/api/v1/message", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var res MessageResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return res
+	}
+
+	rec := do(http.MethodGet, "/api/v1/message/draft", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.JSONEq(t, `{"error":"No draft"}`, rec.Body.String())
+	rec = do(http.MethodPost, "/api/v1/message/draft/publish", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.JSONEq(t, `{"error":"No draft to publish"}`, rec.Body.String())
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/api/v1/message/draft", "").Code)
+
+	// Drafts are validated like the message.
+	rec = do(http.MethodPut, "/api/v1/message/draft", `{"message":"  "}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = do(http.MethodPut, "/api/v1/message/draft", `{"message":"Far too long for the limit"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	rec = do(http.MethodPut, "/api/v1/message/draft", `{"message":"Hi","revision":1}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, mimeProblemJSON, rec.Header().Get("Content-Type"))
+
+	rec = do(http.MethodPut, "/api/v1/message/draft", `{"message":"Next week"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var draft MessageResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &draft))
+	assert.Equal(t, "Next week", draft.Message)
+	assert.Equal(t, storage.UpdatedByAPI, draft.UpdatedBy)
+
+	rec = do(http.MethodGet, "/api/v1/message/draft", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"message":"Next week"`)
+
+	// Only the published message is served.
+	assert.Equal(t, storage.DefaultMessage, message().Message)
+
+	rec = do(http.MethodPost, "/api/v1/message/draft/publish", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+	published := message()
+	assert.Equal(t, "Next week", published.Message)
+	assert.Equal(t, int64(1), published.Revision)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/message/draft", "").Code)
+
+	// Publishing is a change like any other.
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1/message/undo", "").Code)
+	assert.Equal(t, storage.DefaultMessage, message().Message)
+
+	require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/v1/message/draft", `{"message":"Scrapped"}`).Code)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/v1/message/draft", "").Code)
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/api/v1/message/draft/publish", "").Code)
+}
+
+func TestUIDraft(t *testing.T) {
+	handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+		cfg.Message.MaxLength = 10
+	})
+	defer os.RemoveAll(tmpDir)
+	ctx := context.Background()
+
+	e := echo.New()
+	e.GET("/ui", handlers.UI)
+	e.POST("/ui/draft", handlers.UIDraft)
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/ui/draft", strings.NewReader(form.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	ui := func() string {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui", nil))
+		return rec.Body.String()
+	}
+
+	assert.NotContains(t, ui(), `value="publish"`)
+
+	rec := post(url.Values{"message": {"far too long for it"}})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), `<p id="draft-message-error" class="field-error small" role="alert">Message is 19 characters long`)
+	assert.Contains(t, rec.Body.String(), ">far too long for it</textarea>")
+
+	rec = post(url.Values{"action": {"publish"}})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "There is no draft to publish or discard")
+
+	rec = post(url.Values{"action": {"save"}, "message": {"Staged"}})
+	require.Equal(t, http.StatusSeeOther, rec.Code, rec.Body.String())
+	draft, err := handlers.store.GetDraft(ctx, storage.DefaultKey)
+	require.NoError(t, err)
+	assert.Equal(t, "Staged", draft.Message)
+	assert.Equal(t, storage.UpdatedByUI, draft.UpdatedBy)
+
+	page := ui()
+	assert.Contains(t, page, ">Staged</textarea>")
+	assert.Contains(t, page, "Draft saved just now</time> by ui")
+	assert.Contains(t, page, `value="publish"`)
+	assert.Contains(t, page, "<p>Hello, World!</p>")
+
+	rec = post(url.Values{"action": {"publish"}})
+	require.Equal(t, http.StatusSeeOther, rec.Code, rec.Body.String())
+	assert.Equal(t, "Staged", handlers.store.GetMessage(ctx))
+	_, err = handlers.store.GetDraft(ctx, storage.DefaultKey)
+	assert.ErrorIs(t, err, storage.ErrNoDraft)
+
+	require.Equal(t, http.StatusSeeOther, post(url.Values{"message": {"Again"}}).Code)
+	require.Equal(t, http.StatusSeeOther, post(url.Values{"action": {"discard"}}).Code)
+	_, err = handlers.store.GetDraft(ctx, storage.DefaultKey)
+	assert.ErrorIs(t, err, storage.ErrNoDraft)
+	assert.Equal(t, "Staged", handlers.store.GetMessage(ctx))
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+)
+
+// flashCookie carries a one-time notice across the redirect after a form
+// submission.
+const flashCookie = "flash"
+
+// setFlash stores message for the next page view under path.
+func setFlash(c echo.Context, path, message string) {
+	c.SetCookie(&http.Cookie{
+		Name:     flashCookie,
+		Value:    url.QueryEscape(message),
+		Path:     path,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// popFlash returns the pending flash message, if any, and clears the cookie
+// set for path.
+func popFlash(c echo.Context, path string) string {
+	cookie, err := c.Cookie(flashCookie)
+	if err != nil {
+		return ""
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     flashCookie,
+		Path:     path,
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	message, err := url.QueryUnescape(cookie.Value)
+	if err != nil {
+		return ""
+	}
+	return message
+}
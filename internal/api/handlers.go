@@ -2,248 +2,1618 @@ package api
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	texttemplate "text/template"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/sirupsen/logrus"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/diff"
+	"github.com/svanhalla/prompt-lab/greetd/internal/greeting"
+	"github.com/svanhalla/prompt-lab/greetd/internal/health"
+	"github.com/svanhalla/prompt-lab/greetd/internal/i18n"
+	"github.com/svanhalla/prompt-lab/greetd/internal/logging"
 	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+	"github.com/svanhalla/prompt-lab/greetd/internal/update"
+	"github.com/svanhalla/prompt-lab/greetd/internal/validate"
 	"github.com/svanhalla/prompt-lab/greetd/internal/version"
 	"github.com/svanhalla/prompt-lab/greetd/internal/web"
-	"gopkg.in/yaml.v3"
 )
 
 type Handlers struct {
 	store     *storage.MessageStore
 	logger    *logrus.Logger
-	startTime time.Time
-	dataPath  string
+	useCDN    bool
 	templates *web.Templates
+	catalog   *i18n.Catalog
+	greeter   *greeting.Greeter
+	// messageTemplate is nil unless greetings.use_stored_template is on.
+	messageTemplate *greeting.MessageTemplate
+	helloCache      *helloCache // nil when greetings.cache_ttl is 0
+	// checks are the dependency checks of /readyz and /health.
+	checks *CheckRegistry
+	// locales holds the strings of the HTML pages.
+	locales *i18n.Catalog
+
+	messageRules   validate.Options
+	wordsFailed    atomic.Bool // the banned words file could not be read again
+	debug          atomic.Bool // server.debug, switched by PUT /admin/debug
+	renderMarkdown bool
+	basePath       string
+	requests       *StatusCounters
+	requestStats   *RequestStats
+	panics         atomic.Uint64
+	slowRequests   atomic.Uint64
+	crashDir       string // "" unless logging.crash_dumps is on
+	redact         *Redactor
+	appLogPath     string // "" when logging only to stdout
+	accessLogPath  string
+	configPath     string // "" when running on the defaults
+	logs           *logging.RingBuffer
+	stats          *storage.HelloStats
+	idempotency    *storage.IdempotencyStore // nil when disabled
+	maintenance    *storage.Maintenance
+	pprofEnabled   bool
+	statePath      string
+	minFreeSpace   uint64
+	freeSpace      func(dir string) (uint64, error)
+	trustedProxy   func(*http.Request) bool
+	apiKeys        []string
+	// managedKeys are the keys of greetd apikey, read again when
+	// apikeys.json changes.
+	managedKeys    *storage.APIKeys
+	apiKeysFailed  atomic.Bool // apikeys.json could not be read again
+	allowOpenAdmin bool
+	allowNets      []*net.IPNet // security.allow_cidrs; empty allows everyone
+	spec           specCache
+	routes         []RouteEntry // the route table of GET /admin/routes
+	// update is the latest result of the update check, nil unless
+	// update.check is on.
+	update atomic.Pointer[update.Result]
+
+	// done is closed on shutdown to end long-lived streams.
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
+const (
+	swaggerCDN = "https://unpkg.com/swagger-ui-dist@5.32.8/"
+	redocCDN   = "https://cdn.redoc.ly/redoc/v2.1.5/bundles/redoc.standalone.js"
+)
+
+// HealthResponse is returned by the health endpoint. Status is "ok", or
+// "degraded" when the state directory is running out of disk space or a
+// page template failed to load.
 type HealthResponse struct {
 	Status    string        `json:"status"`
 	Version   version.Info  `json:"version"`
 	Uptime    time.Duration `json:"uptime"`
+	StartedAt time.Time     `json:"started_at"`
 	Timestamp time.Time     `json:"timestamp"`
+	// Checks maps each condition to "ok" or what is wrong, such as
+	// "maintenance" being "enabled".
+	Checks map[string]string `json:"checks"`
+	// Details is only included with ?verbose=1.
+	Details *HealthDetails `json:"details,omitempty"`
 }
 
 type HelloResponse struct {
-	Message string `json:"message"`
+	Message string `json:"message" yaml:"message"`
+	Lang    string `json:"lang" yaml:"lang"`
 }
 
 type MessageResponse struct {
-	Message string `json:"message"`
+	Message   string     `json:"message" yaml:"message"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty" yaml:"updated_at,omitempty"`
+	UpdatedBy string     `json:"updated_by,omitempty" yaml:"updated_by,omitempty"`
+	Revision  int64      `json:"revision" yaml:"revision"`
+	// Previous is the message an update replaced. It is left out when
+	// the key had no message, and by reads.
+	Previous *string `json:"previous,omitempty" yaml:"previous,omitempty"`
+}
+
+// newMessageResponse returns data as a response, leaving out the metadata
+// of the default message, which was never set.
+func newMessageResponse(data storage.MessageData) MessageResponse {
+	res := MessageResponse{Message: data.Message, UpdatedBy: data.UpdatedBy, Revision: data.Revision}
+	if !data.UpdatedAt.IsZero() {
+		res.UpdatedAt = &data.UpdatedAt
+	}
+	return res
+}
+
+// newUpdateResponse is newMessageResponse for an update that replaced
+// previous, if anything.
+func newUpdateResponse(data storage.MessageData, previous *storage.MessageData) MessageResponse {
+	res := newMessageResponse(data)
+	if previous != nil {
+		res.Previous = &previous.Message
+	}
+	return res
 }
 
 type MessageRequest struct {
 	Message string `json:"message"`
+	// Revision, when set, must equal the stored revision for the update to
+	// be applied.
+	Revision *int64 `json:"revision,omitempty"`
 }
 
-func NewHandlers(store *storage.MessageStore, logger *logrus.Logger, dataPath string) (*Handlers, error) {
-	// Detect development mode by checking if template files exist
-	devMode := false
-	if _, err := os.Stat(filepath.Join("internal", "web", "templates", "ui.html")); err == nil {
-		devMode = true
-		logger.Info("Development mode: Using filesystem templates with hot reload")
-	} else {
-		logger.Info("Production mode: Using embedded templates")
+func NewHandlers(cfg *config.Config, store *storage.MessageStore, logger *logrus.Logger) (*Handlers, error) {
+	var templatesDir string
+	if cfg.UI.DevMode {
+		templatesDir = cfg.UI.TemplatesPath
+		if abs, err := filepath.Abs(templatesDir); err == nil {
+			templatesDir = abs
+		}
+		if _, err := os.Stat(templatesDir); err != nil {
+			logger.WithError(err).Warnf("Development mode: template directory %s not found; using embedded templates for missing files", templatesDir)
+		}
+	}
+
+	basePath := config.NormalizeBasePath(cfg.Server.BasePath)
+
+	locales, err := i18n.LoadLocales(cfg.UI.LocalesPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load UI locales: %w", err)
+	}
+
+	templates := web.LoadTemplates(templatesDir, basePath, locales)
+	if err := templates.Err(); err != nil {
+		if cfg.UI.StrictTemplates {
+			return nil, fmt.Errorf("failed to load templates: %w", err)
+		}
+		logger.WithError(err).Warn("Some page templates failed to load; their pages show a fallback")
+	}
+
+	catalog, err := i18n.Load(cfg.Greetings.CatalogPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load greetings catalog: %w", err)
 	}
 
-	templates, err := web.NewTemplates(devMode)
+	greeter, err := greeting.New(cfg.Greetings.Template, catalog)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load templates: %w", err)
+		return nil, err
+	}
+
+	trustedProxy, err := newProxyTrust(cfg.Server.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server.trusted_proxies: %w", err)
+	}
+	allowNets, err := parseCIDRs(cfg.Security.AllowCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid security.allow_cidrs: %w", err)
+	}
+
+	stats := storage.NewHelloStats(cfg.StateDir())
+	if err := stats.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load hello stats: %w", err)
+	}
+
+	var idempotency *storage.IdempotencyStore
+	if window := cfg.Message.IdempotencyTTL(); window > 0 {
+		idempotency = storage.NewIdempotencyStore(cfg.StateDir(), window)
+		idempotency.SetKeyring(store.Keyring())
+		if err := idempotency.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load idempotency keys: %w", err)
+		}
+	}
+
+	messageRules := cfg.Message.Rules()
+	messageRules.Words, err = validate.LoadWordFilter(cfg.Message.BannedWordsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load message.banned_words_file: %w", err)
+	}
+
+	maintenance := storage.NewMaintenance(cfg.StateDir())
+	if err := maintenance.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load maintenance mode: %w", err)
+	}
+
+	managedKeys := storage.NewAPIKeys(cfg.StateDir())
+	if err := managedKeys.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load API keys: %w", err)
+	}
+
+	logs := logging.NewRingBuffer(cfg.Logging.BufferSize)
+	logger.AddHook(logs)
+
+	crashDir := ""
+	if cfg.Logging.CrashDumps {
+		crashDir = cfg.StateDir()
+	}
+
+	var messageTemplate *greeting.MessageTemplate
+	if cfg.Greetings.UseStoredTemplate {
+		messageTemplate = &greeting.MessageTemplate{}
+	}
+
+	var helloCache *helloCache
+	if ttl := cfg.Greetings.CacheDuration(); ttl > 0 {
+		helloCache = newHelloCache(ttl, cfg.Greetings.CacheSize)
+		helloCache.revalidate = cfg.Greetings.UseStoredTemplate
 	}
 
-	return &Handlers{
+	checks := NewCheckRegistry(cfg.Health.CheckTimeoutDuration(), cfg.Health.CheckCacheDuration())
+	checks.Register(store.HealthCheck())
+
+	handlers := &Handlers{
 		store:     store,
 		logger:    logger,
-		startTime: time.Now(),
-		dataPath:  dataPath,
+		useCDN:    cfg.Docs.UseCDN,
 		templates: templates,
-	}, nil
+		catalog:   catalog,
+		greeter:   greeter,
+		locales:   locales,
+
+		messageTemplate: messageTemplate,
+		helloCache:      helloCache,
+		checks:          checks,
+
+		messageRules:   messageRules,
+		renderMarkdown: cfg.UI.RenderMarkdown,
+		basePath:       basePath,
+		requests:       &StatusCounters{},
+		requestStats:   NewRequestStats(),
+		crashDir:       crashDir,
+		redact:         NewRedactor(cfg.Logging.RedactParams),
+		appLogPath:     cfg.AppLogPath(),
+		accessLogPath:  cfg.AccessLogPath(),
+		configPath:     cfg.File,
+		logs:           logs,
+		stats:          stats,
+		idempotency:    idempotency,
+		maintenance:    maintenance,
+		pprofEnabled:   cfg.Server.EnablePprof,
+		statePath:      cfg.StateDir(),
+		minFreeSpace:   cfg.Health.MinFreeSpaceBytes(),
+		freeSpace:      storage.FreeSpace,
+		trustedProxy:   trustedProxy,
+		apiKeys:        cfg.Security.APIKeys,
+		managedKeys:    managedKeys,
+		allowOpenAdmin: cfg.Security.AllowOpenAdmin,
+		allowNets:      allowNets,
+		done:           make(chan struct{}),
+	}
+	handlers.debug.Store(cfg.Server.Debug)
+
+	go handlers.persistStats(statsSaveInterval)
+	if cfg.Storage.Watch {
+		handlers.watchStore()
+	}
+	return handlers, nil
+}
+
+// watchStore reloads messages.json when another program changes it, and
+// logs the reloads until Close.
+func (h *Handlers) watchStore() {
+	ctx, cancel := context.WithCancel(context.Background())
+	events, unsubscribe := h.store.Subscribe(16)
+	if err := h.store.Watch(ctx); err != nil {
+		h.logger.WithError(err).Warn("Cannot watch messages.json for changes; checking it every few seconds instead")
+	}
+
+	go func() {
+		defer cancel()
+		defer unsubscribe()
+		for {
+			select {
+			case event := <-events:
+				switch {
+				case event.Err != nil:
+					h.logger.WithError(event.Err).Warn("Ignoring changed messages.json; keeping the messages already loaded")
+				case event.Reloaded:
+					h.logger.Info("Reloaded messages.json after an external change")
+				}
+			case <-h.done:
+				return
+			}
+		}
+	}()
+}
+
+// statsSaveInterval is how often changed hello stats and idempotency keys
+// are written to disk.
+const statsSaveInterval = 30 * time.Second
+
+// persistStats saves the hello stats and idempotency keys every interval
+// until Close.
+func (h *Handlers) persistStats(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.saveStats()
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// saveStats writes changed hello stats and idempotency keys, unless the
+// storage is read-only.
+func (h *Handlers) saveStats() {
+	if err := h.stats.Save(); err != nil && !errors.Is(err, storage.ErrReadOnly) {
+		h.logger.WithError(err).Warn("Failed to save hello stats")
+	}
+	if h.idempotency == nil {
+		return
+	}
+	if err := h.idempotency.Save(); err != nil && !errors.Is(err, storage.ErrReadOnly) {
+		h.logger.WithError(err).Warn("Failed to save idempotency keys")
+	}
+}
+
+// Close ends open log streams so the server can shut down promptly, saves
+// the hello stats and idempotency keys, and stops buffering the entries of
+// the logger passed to NewHandlers.
+func (h *Handlers) Close() {
+	h.closeOnce.Do(func() {
+		close(h.done)
+		h.saveStats()
+		logging.RemoveHook(h.logger, h.logs)
+	})
 }
 
+// Health reports whether the server is up. Low disk space degrades the
+// status but still answers 200, since requests are still being served.
 func (h *Handlers) Health(c echo.Context) error {
-	return c.JSON(http.StatusOK, HealthResponse{
+	verbose, _ := strconv.ParseBool(c.QueryParam("verbose"))
+	return c.JSON(http.StatusOK, h.health(c.Request().Context(), verbose))
+}
+
+// health gathers the /health output, with details when verbose is set. A
+// failing dependency check degrades the status; a warning does not.
+func (h *Handlers) health(ctx context.Context, verbose bool) HealthResponse {
+	res := HealthResponse{
 		Status:    "ok",
 		Version:   version.Get(),
-		Uptime:    time.Since(h.startTime),
+		Uptime:    version.Uptime(),
+		StartedAt: version.StartTime(),
 		Timestamp: time.Now(),
-	})
+		Checks:    map[string]string{"maintenance": h.maintenanceCheck(), "templates": "ok"},
+	}
+	for name, err := range h.checks.Run(ctx) {
+		res.Checks[name] = checkStatus(err)
+		if err != nil && !health.IsWarning(err) {
+			res.Status = "degraded"
+		}
+	}
+	if h.templates.Err() != nil {
+		res.Status = "degraded"
+		res.Checks["templates"] = "degraded"
+	}
+	// An invalid message template is a warning: /hello falls back to the
+	// default greeting.
+	if h.messageTemplate != nil {
+		res.Checks["message_template"] = "ok"
+		if h.messageTemplateErr(ctx) != nil {
+			res.Checks["message_template"] = "invalid"
+		}
+	}
+
+	free, freeKnown := h.diskFree()
+	if freeKnown && free < h.minFreeSpace {
+		res.Status = "degraded"
+	}
+
+	if verbose {
+		res.Details = h.healthDetails(ctx, free, freeKnown)
+	}
+	return res
+}
+
+// ReadyResponse is returned by the readiness endpoint. Checks maps each
+// dependency to "ok" or a warning such as "read-only".
+type ReadyResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Ready reports whether the server can serve traffic, from the registered
+// dependency checks. A warning, such as a read-only store, leaves the server
+// ready: everything but changing the message still works. In maintenance
+// mode the server is not ready, so load balancers drain it.
+func (h *Handlers) Ready(c echo.Context) error {
+	res := h.readiness(c.Request().Context())
+	if res.Status != "ready" {
+		return c.JSON(http.StatusServiceUnavailable, res)
+	}
+	return c.JSON(http.StatusOK, res)
 }
 
+// readiness gathers the /readyz output.
+func (h *Handlers) readiness(ctx context.Context) ReadyResponse {
+	res := ReadyResponse{
+		Status: "ready",
+		Checks: map[string]string{"maintenance": h.maintenanceCheck()},
+	}
+	if h.maintenance.Get().Enabled {
+		res.Status = "not ready"
+	}
+	for name, err := range h.checks.Run(ctx) {
+		res.Checks[name] = checkStatus(err)
+		if err != nil && !health.IsWarning(err) {
+			res.Status = "not ready"
+		}
+	}
+	return res
+}
+
+// Version returns the build information.
+func (h *Handlers) Version(c echo.Context) error {
+	return c.JSON(http.StatusOK, version.Get())
+}
+
+// Hello greets ?name. Responses are cached for greetings.cache_ttl, which
+// is also their Cache-Control max-age, unless ?nocache=1 is given.
 func (h *Handlers) Hello(c echo.Context) error {
+	candidates := append([]string{c.QueryParam("lang")},
+		i18n.ParseAcceptLanguage(c.Request().Header.Get("Accept-Language"))...)
+	lang := h.catalog.Match(candidates...)
+
+	opts, err := helloOptions(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	nocache, err := boolParam(c, "nocache")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
 	name := c.QueryParam("name")
-	if name == "" {
-		name = "World"
+	tmpl, usesTime := h.storedTemplate(c.Request().Context())
+	key, cacheable := h.helloCacheKeyFor(c, name, lang, opts, usesTime)
+	cacheable = cacheable && !nocache
+	res := c.Response()
+	if cacheable {
+		if entry := h.helloCache.get(tmpl, key); entry != nil {
+			h.recordHello(c, name)
+			h.helloCache.setHeaders(res.Header())
+			res.Header().Add(echo.HeaderVary, echo.HeaderAccept)
+			return c.Blob(http.StatusOK, entry.contentType, entry.body)
+		}
 	}
 
-	return c.JSON(http.StatusOK, HelloResponse{
-		Message: fmt.Sprintf("Hello, %s!", name),
-	})
+	message, lang, err := h.greet(tmpl, name, lang, opts)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to render greeting")
+		return h.internalError(c, http.StatusInternalServerError, err, "")
+	}
+	h.recordHello(c, name)
+
+	if !cacheable {
+		if nocache {
+			res.Header().Set(echo.HeaderCacheControl, "no-store")
+		}
+		return negotiate(c, http.StatusOK, HelloResponse{Message: message, Lang: lang}, message)
+	}
+	h.helloCache.setHeaders(res.Header())
+	capture := &captureWriter{ResponseWriter: res.Writer}
+	res.Writer = capture
+	err = negotiate(c, http.StatusOK, HelloResponse{Message: message, Lang: lang}, message)
+	res.Writer = capture.ResponseWriter
+	if err == nil && res.Status == http.StatusOK {
+		h.helloCache.put(tmpl, key, res.Header().Get(echo.HeaderContentType), capture.body.Bytes())
+	}
+	return err
+}
+
+// storedTemplate returns the stored message as a message template and
+// whether it renders the current time, or nil unless
+// greetings.use_stored_template is on and the message is a valid template.
+func (h *Handlers) storedTemplate(ctx context.Context) (*texttemplate.Template, bool) {
+	if h.messageTemplate == nil {
+		return nil, false
+	}
+	tmpl, usesTime, err := h.messageTemplate.ParseTimed(h.store.Get(ctx).Message)
+	if err != nil {
+		return nil, false
+	}
+	return tmpl, usesTime
+}
+
+// recordHello counts a greeting of name in the hello stats. HEAD requests
+// get no greeting and are not counted.
+func (h *Handlers) recordHello(c echo.Context, name string) {
+	if c.Request().Method != http.MethodHead {
+		h.stats.Record(name)
+	}
+}
+
+// greet renders the greeting for /hello: the stored message template tmpl,
+// or the configured greeting when tmpl is nil or fails to render.
+func (h *Handlers) greet(tmpl *texttemplate.Template, name, lang string, opts greeting.Options) (string, string, error) {
+	if tmpl != nil {
+		message, matched, err := h.greeter.GreetMessage(tmpl, name, lang, opts)
+		if err == nil {
+			return message, matched, nil
+		}
+		h.logger.WithError(err).Warn("Failed to render the stored message; using the default greeting")
+	}
+	return h.greeter.Greet(name, lang, opts)
+}
+
+// messageTemplateErr reports why the stored message cannot be used as the
+// greeting, or nil when it can or greetings.use_stored_template is off.
+func (h *Handlers) messageTemplateErr(ctx context.Context) error {
+	if h.messageTemplate == nil {
+		return nil
+	}
+	_, err := h.messageTemplate.Parse(h.store.Get(ctx).Message)
+	return err
+}
+
+// HelloStatsResponse lists the most greeted names and the total number of
+// greetings.
+type HelloStatsResponse struct {
+	Total int64               `json:"total"`
+	Names []storage.NameCount `json:"names"`
+}
+
+// HelloStats returns the ?top= (default 10) most greeted names.
+func (h *Handlers) HelloStats(c echo.Context) error {
+	top := 10
+	if raw := c.QueryParam("top"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > storage.MaxStatsNames {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("top must be a number between 1 and %d", storage.MaxStatsNames),
+			})
+		}
+		top = parsed
+	}
+
+	names, total := h.stats.Top(top)
+	return c.JSON(http.StatusOK, HelloStatsResponse{Total: total, Names: names})
+}
+
+// ResetHelloStats clears the greeting counters.
+func (h *Handlers) ResetHelloStats(c echo.Context) error {
+	h.stats.Reset()
+	h.saveStats()
+	h.logger.Info("Hello stats reset")
+	return c.NoContent(http.StatusNoContent)
+}
+
+// MaxHelloRepeat caps ?repeat= on /hello.
+const MaxHelloRepeat = 10
+
+// helloOptions reads the repeat, shout, time_aware and at query parameters.
+func helloOptions(c echo.Context) (greeting.Options, error) {
+	var opts greeting.Options
+
+	if raw := c.QueryParam("repeat"); raw != "" {
+		repeat, err := strconv.Atoi(raw)
+		if err != nil || repeat < 1 || repeat > MaxHelloRepeat {
+			return opts, fmt.Errorf("repeat must be a number between 1 and %d", MaxHelloRepeat)
+		}
+		opts.Repeat = repeat
+	}
+
+	var err error
+	if opts.Shout, err = boolParam(c, "shout"); err != nil {
+		return opts, err
+	}
+	if opts.TimeAware, err = boolParam(c, "time_aware"); err != nil {
+		return opts, err
+	}
+
+	if raw := c.QueryParam("at"); raw != "" {
+		at, err := greeting.ParseClock(raw)
+		if err != nil {
+			return opts, fmt.Errorf("at must be a time of day in HH:MM format")
+		}
+		opts.At = at
+	}
+
+	return opts, nil
+}
+
+// boolParam parses an optional boolean query parameter.
+func boolParam(c echo.Context, name string) (bool, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", name)
+	}
+	return value, nil
 }
 
 func (h *Handlers) GetMessage(c echo.Context) error {
-	message := h.store.GetMessage()
+	data := h.store.Get(c.Request().Context())
 
-	return c.JSON(http.StatusOK, MessageResponse{
-		Message: message,
-	})
+	c.Response().Header().Set("ETag", representationETag(c, data.Revision))
+	setLastModified(c, data.UpdatedAt)
+	return negotiate(c, http.StatusOK, newMessageResponse(data), data.Message)
 }
 
 func (h *Handlers) SetMessage(c echo.Context) error {
-	var req MessageRequest
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+	return h.setMessage(c, storage.DefaultKey, func(data storage.MessageData, previous *storage.MessageData) interface{} {
+		return newUpdateResponse(data, previous)
+	})
+}
+
+// UndoMessage restores the message before the last change.
+func (h *Handlers) UndoMessage(c echo.Context) error {
+	return h.restoreMessage(c, h.store.UndoKey, "Message undone")
+}
+
+// RedoMessage restores the message undone last.
+func (h *Handlers) RedoMessage(c echo.Context) error {
+	return h.restoreMessage(c, h.store.RedoKey, "Message redone")
+}
+
+// restoreMessage restores the default message with restore and logs event,
+// so undo and redo can be told apart in the log.
+func (h *Handlers) restoreMessage(c echo.Context, restore func(ctx context.Context, key, updatedBy string) (storage.MessageData, error), event string) error {
+	data, err := restore(c.Request().Context(), storage.DefaultKey, h.updatedBy(c))
+	if err != nil {
+		return h.storeError(c, err)
+	}
+	h.logger.WithFields(logrus.Fields{
+		"key":        storage.DefaultKey,
+		"revision":   data.Revision,
+		"updated_by": data.UpdatedBy,
+	}).Info(event)
+
+	c.Response().Header().Set("ETag", revisionETag(data.Revision))
+	return c.JSON(http.StatusOK, newMessageResponse(data))
+}
+
+// prepareMessage validates and normalizes the message of a decoded
+// MessageRequest. It returns the status code to respond with alongside a
+// client-facing error.
+func (h *Handlers) prepareMessage(req *MessageRequest) (int, error) {
+	var err error
+	req.Message, err = validate.Prepare(req.Message, h.rules())
+	if err != nil {
+		var tooLong *validate.TooLongError
+		var forbidden *validate.ForbiddenError
+		switch {
+		case errors.As(err, &tooLong):
+			return http.StatusUnprocessableEntity, fmt.Errorf("Message exceeds the maximum length of %d characters", tooLong.Limit)
+		case errors.As(err, &forbidden):
+			return http.StatusUnprocessableEntity, errors.New("Message contains forbidden content")
+		case errors.Is(err, validate.ErrBannedWord):
+			return http.StatusUnprocessableEntity, errors.New("Message violates the banned words policy")
+		case errors.Is(err, validate.ErrBidiControl):
+			return http.StatusBadRequest, errors.New("Message cannot contain bidirectional control characters")
+		}
+		return http.StatusBadRequest, errors.New("Message cannot be empty")
 	}
+	return http.StatusOK, nil
+}
 
-	if strings.TrimSpace(req.Message) == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Message cannot be empty"})
+// rules returns the message validation rules, reading the banned words
+// again first if their file changed. A file that cannot be read is logged
+// once, and the words already loaded stay in use.
+func (h *Handlers) rules() validate.Options {
+	changed, err := h.messageRules.Words.Refresh()
+	switch {
+	case err != nil:
+		if h.wordsFailed.CompareAndSwap(false, true) {
+			h.logger.WithError(err).Warn("Keeping the banned words already loaded")
+		}
+	case changed:
+		h.wordsFailed.Store(false)
+		h.logger.WithField("words", h.messageRules.Words.Len()).Info("Reloaded banned words")
+	default:
+		h.wordsFailed.Store(false)
 	}
+	return h.messageRules
+}
 
-	if err := h.store.SetMessage(req.Message); err != nil {
-		h.logger.WithError(err).Error("Failed to save message")
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save message"})
+// updatedBy names the writer of an API request: the API key it used, or
+// "api" without one.
+func (h *Handlers) updatedBy(c echo.Context) string {
+	if name := h.apiKeyName(c); name != "" {
+		return name
 	}
+	return storage.UpdatedByAPI
+}
 
-	return c.JSON(http.StatusOK, MessageResponse(req))
+// storeError answers a failed store write.
+func (h *Handlers) storeError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrReadOnly):
+		return h.problemResponse(c, http.StatusServiceUnavailable, "Storage is read-only; the message cannot be changed")
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		// The client went away or the request timed out; nothing was saved.
+		return h.problemResponse(c, http.StatusServiceUnavailable, "The request ended before the message was saved")
+	case errors.Is(err, storage.ErrInvalidKey):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Key must be 1-64 characters of a-z, 0-9, - and _"})
+	case errors.Is(err, storage.ErrNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Message not found"})
+	case errors.Is(err, storage.ErrNothingToUndo):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Nothing to undo"})
+	case errors.Is(err, storage.ErrNoDraft):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "No draft"})
+	case errors.Is(err, storage.ErrNothingToRedo):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Nothing to redo"})
+	case errors.Is(err, storage.ErrTooManyKeys):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Too many messages; delete one before adding another"})
+	}
+	h.logger.WithError(err).Error("Failed to save message")
+	return h.internalError(c, http.StatusInternalServerError, err, "")
 }
 
-func (h *Handlers) UI(c echo.Context) error {
-	message := h.store.GetMessage()
+// bindJSON strictly decodes a JSON request body into v, a pointer to a
+// struct. Every JSON endpoint uses it instead of echo's Bind, so a body is
+// only accepted if it is a single JSON object whose fields all exist in v.
+// It returns the status code to respond with alongside a client-facing
+// error naming what is wrong.
+func bindJSON(c echo.Context, v interface{}) (int, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != echo.MIMEApplicationJSON {
+		return http.StatusUnsupportedMediaType, errors.New("Content-Type must be application/json")
+	}
 
-	data := struct {
-		Message string
-	}{
-		Message: message,
+	body := json.NewDecoder(c.Request().Body)
+	var raw json.RawMessage
+	if err := body.Decode(&raw); err != nil {
+		return bindError(err)
+	}
+	// Anything but the end of the body after the value, even whitespace
+	// followed by more JSON, is rejected.
+	if _, err := body.Token(); !errors.Is(err, io.EOF) {
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return bindError(err)
+		}
+		return http.StatusBadRequest, errors.New("Unexpected data after the JSON object")
+	}
+	if raw[0] != '{' {
+		return http.StatusBadRequest, errors.New("Request body must be a JSON object")
 	}
 
-	c.Response().Header().Set("Content-Type", "text/html; charset=utf-8")
-	return h.templates.GetUI().Execute(c.Response().Writer, data)
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return bindError(err)
+	}
+	return http.StatusOK, nil
 }
 
-func (h *Handlers) Logs(c echo.Context) error {
-	logFile := filepath.Join(h.dataPath, "app.log")
+// bindError maps a decoding error of bindJSON to a status code and a
+// client-facing error.
+func bindError(err error) (int, error) {
+	var httpErr *echo.HTTPError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &httpErr):
+		return httpErr.Code, errors.New("Request body too large")
+	case errors.Is(err, io.EOF):
+		return http.StatusBadRequest, errors.New("Request body is empty")
+	case errors.As(err, &typeErr):
+		return http.StatusBadRequest, fmt.Errorf("Field %q must be %s", typeErr.Field, jsonType(typeErr.Type))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return http.StatusBadRequest, fmt.Errorf("Unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		return http.StatusBadRequest, errors.New("Invalid JSON")
+	}
+}
 
-	var logs []string
-	file, err := os.Open(logFile)
+// jsonType names the JSON type a Go type is decoded from, with its article,
+// such as "an integer" for int64.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// uiPage is the data rendered by the ui.html template.
+type uiPage struct {
+	Message     string
+	MessageHTML template.HTML
+	UpdatedAt   time.Time
+	UpdatedBy   string
+	Revision    int64
+	CSRFToken   string
+	Error       string
+	// FieldError rejects the submitted text. It is shown next to the form
+	// for Key: renderUI moves it to that message or to NewError.
+	FieldError string
+	// Warning is shown instead of Error when the message changed while it
+	// was being edited.
+	Warning string
+	Flash   string
+	// MaxLength is the message limit in characters; 0 means no limit.
+	MaxLength int
+	// Key is the message the form was submitted for; it defaults to the
+	// default key.
+	Key string
+	// Draft is the text shown in the form for Key; it defaults to the stored
+	// message and preserves the submitted text when validation fails.
+	Draft string
+	// Pending is the stored draft of the default message, nil without one.
+	Pending *storage.MessageData
+	// PendingText is the text in the draft form; it defaults to the stored
+	// draft and preserves the submitted text when validation fails.
+	PendingText  string
+	PendingError string
+	// History are the latest changes to the default message, newest first.
+	History []uiRevision
+	// Messages are the named messages besides the default one.
+	Messages []uiKeyedMessage
+	// NewKey and NewDraft preserve a rejected submission for a new key.
+	NewKey   string
+	NewDraft string
+	NewError string
+}
+
+// uiRevision is a change to the default message on /ui: the revision it
+// made, and its lines compared with Base, the known revision numbered just
+// before it. After an undo that is not necessarily the message it replaced.
+type uiRevision struct {
+	Revision  int64
+	Base      int64
+	UpdatedAt time.Time
+	UpdatedBy string
+	Lines     []diff.Line
+}
+
+// uiHistoryLength is the number of changes /ui shows.
+const uiHistoryLength = 5
+
+// uiKeyedMessage is a named message with its inline edit form on /ui.
+type uiKeyedMessage struct {
+	Key       string
+	Message   string
+	UpdatedAt time.Time
+	UpdatedBy string
+	Revision  int64
+	Draft     string
+	Error     string
+}
+
+func (h *Handlers) UI(c echo.Context) error {
+	return h.renderUI(c, http.StatusOK, uiPage{Flash: popFlash(c, h.basePath+"/ui")})
+}
+
+// flashQuote is how much of a message the "changed from" flash quotes, so
+// the flash cookie stays small.
+const flashQuote = 60
+
+// UIMessage handles the message forms on /ui. Unlike the JSON API at
+// POST /message it answers with pages: a redirect back to /ui on success, or
+// the form with an inline error and the submitted text on failure. The form's
+// key field selects the message, defaulting to the default key, and its
+// revision field, when present, must still be current.
+func (h *Handlers) UIMessage(c echo.Context) error {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != echo.MIMEApplicationForm {
+		return h.renderUI(c, http.StatusUnsupportedMediaType, uiPage{Error: h.tr(c, "ui.error.form_encoding")})
+	}
+
+	key := strings.TrimSpace(c.FormValue("key"))
+	if key == "" {
+		key = storage.DefaultKey
+	}
+	message := c.FormValue("message")
+
+	if !storage.ValidKey(key) {
+		return h.renderUI(c, http.StatusBadRequest, uiPage{FieldError: h.tr(c, "ui.error.invalid_key"), Key: key, Draft: message})
+	}
+
+	message, err := validate.Prepare(message, h.rules())
 	if err != nil {
-		logs = []string{"No logs available"}
+		status, fieldError := h.uiValidationError(c, err)
+		return h.renderUI(c, status, uiPage{FieldError: fieldError, Key: key, Draft: message})
+	}
+
+	var previous *storage.MessageData
+	if revision := c.FormValue("revision"); revision != "" {
+		expected, parseErr := strconv.ParseInt(revision, 10, 64)
+		if parseErr != nil {
+			return h.renderUI(c, http.StatusBadRequest, uiPage{Error: h.tr(c, "ui.error.revision"), Key: key, Draft: message})
+		}
+		previous, _, err = h.store.CompareAndSwapKey(c.Request().Context(), key, message, storage.UpdatedByUI, expected)
 	} else {
-		defer file.Close()
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			logs = append(logs, scanner.Text())
+		previous, _, err = h.store.SwapKey(c.Request().Context(), key, message, storage.UpdatedByUI)
+	}
+	if err != nil {
+		page := uiPage{Key: key, Draft: message}
+		switch {
+		case errors.Is(err, storage.ErrConflict):
+			// The form is rendered with the new revision, so submitting
+			// the draft again replaces the other change.
+			page.Warning = h.tr(c, "ui.error.conflict")
+			return h.renderUI(c, http.StatusConflict, page)
+		case errors.Is(err, storage.ErrReadOnly):
+			page.Error = h.tr(c, "ui.error.read_only")
+			return h.renderUI(c, http.StatusServiceUnavailable, page)
+		case errors.Is(err, storage.ErrTooManyKeys):
+			page.Error = h.tr(c, "ui.error.too_many")
+			return h.renderUI(c, http.StatusConflict, page)
+		}
+		h.logger.WithError(err).Error("Failed to save message")
+		page.Error = h.tr(c, "ui.error.save")
+		return h.renderUI(c, http.StatusInternalServerError, page)
+	}
+
+	flash := h.tr(c, "ui.flash.updated")
+	if previous != nil && previous.Message != message {
+		flash = h.tr(c, "ui.flash.changed", web.Truncate(flashQuote, previous.Message), web.Truncate(flashQuote, message))
+	}
+	setFlash(c, h.basePath+"/ui", flash)
+	return c.Redirect(http.StatusSeeOther, h.basePath+"/ui")
+}
+
+// UIDraft handles the draft forms on /ui. The action field saves the
+// submitted text as the draft of the default message ("save", the default),
+// publishes the stored draft ("publish") or discards it ("discard"). Like
+// UIMessage it redirects back to /ui on success and re-renders the page with
+// an error otherwise.
+func (h *Handlers) UIDraft(c echo.Context) error {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != echo.MIMEApplicationForm {
+		return h.renderUI(c, http.StatusUnsupportedMediaType, uiPage{Error: h.tr(c, "ui.error.form_encoding")})
+	}
+
+	ctx := c.Request().Context()
+	text := c.FormValue("message")
+	var flash string
+	var err error
+	switch c.FormValue("action") {
+	case "publish":
+		_, err = h.store.PublishDraft(ctx, storage.DefaultKey, storage.UpdatedByUI)
+		flash = "ui.flash.draft_published"
+	case "discard":
+		err = h.store.DeleteDraft(ctx, storage.DefaultKey)
+		flash = "ui.flash.draft_discarded"
+	default:
+		message, prepareErr := validate.Prepare(text, h.rules())
+		if prepareErr != nil {
+			status, fieldError := h.uiValidationError(c, prepareErr)
+			return h.renderUI(c, status, uiPage{PendingError: fieldError, PendingText: text})
+		}
+		_, err = h.store.SetDraft(ctx, storage.DefaultKey, message, storage.UpdatedByUI)
+		flash = "ui.flash.draft_saved"
+	}
+	if err != nil {
+		page := uiPage{PendingText: text}
+		switch {
+		case errors.Is(err, storage.ErrNoDraft):
+			page.Error = h.tr(c, "ui.error.no_draft")
+			return h.renderUI(c, http.StatusConflict, page)
+		case errors.Is(err, storage.ErrReadOnly):
+			page.Error = h.tr(c, "ui.error.read_only")
+			return h.renderUI(c, http.StatusServiceUnavailable, page)
+		case errors.Is(err, storage.ErrTooManyKeys):
+			page.Error = h.tr(c, "ui.error.too_many")
+			return h.renderUI(c, http.StatusConflict, page)
+		}
+		h.logger.WithError(err).Error("Failed to save draft")
+		page.Error = h.tr(c, "ui.error.save")
+		return h.renderUI(c, http.StatusInternalServerError, page)
+	}
+
+	setFlash(c, h.basePath+"/ui", h.tr(c, flash))
+	return c.Redirect(http.StatusSeeOther, h.basePath+"/ui")
+}
+
+// uiValidationError returns the status code and the translated field error
+// for a message rejected by validate.Prepare.
+func (h *Handlers) uiValidationError(c echo.Context, err error) (int, string) {
+	var tooLong *validate.TooLongError
+	var forbidden *validate.ForbiddenError
+	switch {
+	case errors.As(err, &tooLong):
+		return http.StatusUnprocessableEntity, h.tr(c, "ui.error.too_long", tooLong.Length, tooLong.Limit)
+	case errors.As(err, &forbidden):
+		return http.StatusUnprocessableEntity, h.tr(c, "ui.error.forbidden")
+	case errors.Is(err, validate.ErrBannedWord):
+		return http.StatusUnprocessableEntity, h.tr(c, "ui.error.banned")
+	case errors.Is(err, validate.ErrBidiControl):
+		return http.StatusBadRequest, h.tr(c, "ui.error.bidi")
+	}
+	return http.StatusBadRequest, h.tr(c, "ui.error.empty")
+}
+
+// CSRFFailed re-renders the form with an inline error when the CSRF check
+// fails, reusing the visitor's token so the next submission can succeed.
+func (h *Handlers) CSRFFailed(err error, c echo.Context) error {
+	h.logger.WithError(err).Warn("Rejected form submission with invalid CSRF token")
+
+	page := uiPage{Error: h.tr(c, "ui.error.session")}
+	if cookie, cookieErr := c.Cookie(csrfField); cookieErr == nil {
+		page.CSRFToken = cookie.Value
+	}
+	return h.renderUI(c, http.StatusForbidden, page)
+}
+
+// renderUI fills in the stored messages, the draft and the CSRF token and
+// renders ui.html. A draft for page.Key replaces the stored text in that
+// message's form, and page.PendingText the stored draft in the draft form.
+func (h *Handlers) renderUI(c echo.Context, status int, page uiPage) error {
+	key, draft := page.Key, page.Draft
+	if key == "" {
+		key = storage.DefaultKey
+	}
+
+	ctx := c.Request().Context()
+	data := h.store.Get(ctx)
+	page.Message, page.UpdatedAt, page.UpdatedBy, page.Revision = data.Message, data.UpdatedAt, data.UpdatedBy, data.Revision
+	page.Draft = page.Message
+	if key == storage.DefaultKey && draft != "" {
+		page.Draft = draft
+	}
+
+	if pending, err := h.store.GetDraft(ctx, storage.DefaultKey); err == nil {
+		page.Pending = &pending
+		if page.PendingText == "" {
+			page.PendingText = pending.Message
+		}
+	}
+
+	if revisions, err := h.store.Revisions(ctx, storage.DefaultKey); err == nil {
+		for i := len(revisions) - 1; i > 0 && len(page.History) < uiHistoryLength; i-- {
+			data := revisions[i]
+			page.History = append(page.History, uiRevision{
+				Revision:  data.Revision,
+				Base:      revisions[i-1].Revision,
+				UpdatedAt: data.UpdatedAt,
+				UpdatedBy: data.UpdatedBy,
+				Lines:     diff.Lines(revisions[i-1].Message, data.Message),
+			})
+		}
+	}
+
+	found := key == storage.DefaultKey
+	for _, info := range h.store.Keys(ctx) {
+		if info.Key == storage.DefaultKey {
+			continue
+		}
+		data, err := h.store.GetKey(ctx, info.Key)
+		if err != nil {
+			continue // deleted in the meantime
+		}
+		item := uiKeyedMessage{Key: info.Key, Message: data.Message, UpdatedAt: data.UpdatedAt, UpdatedBy: data.UpdatedBy, Revision: data.Revision, Draft: data.Message}
+		if info.Key == key {
+			found = true
+			if draft != "" {
+				item.Draft = draft
+			}
+			item.Error = page.FieldError
 		}
+		page.Messages = append(page.Messages, item)
+	}
+	if !found {
+		page.NewKey, page.NewDraft, page.NewError = key, draft, page.FieldError
+	}
+	if key != storage.DefaultKey {
+		page.FieldError = ""
+	}
+	page.MaxLength = h.messageRules.MaxLength
+	if err := h.messageTemplateErr(ctx); err != nil && page.Warning == "" {
+		page.Warning = h.tr(c, "ui.warning.message_template", err)
+	}
+
+	if token, ok := c.Get(csrfContextKey).(string); ok {
+		page.CSRFToken = token
+	}
+
+	if h.renderMarkdown {
+		rendered, err := web.RenderMarkdown(page.Message)
+		if err != nil {
+			h.logger.WithError(err).Warn("Failed to render message as markdown")
+		} else {
+			page.MessageHTML = rendered
+		}
+	}
+
+	return h.render(c, status, h.templates.GetUI, page)
+}
+
+// Logs shows recent application log entries, or the tail of the access log
+// with ?source=access when one is configured. ?level= and ?q= filter the
+// entries, ?lines= sets the page size and ?page= counts back from the
+// newest entries.
+func (h *Handlers) Logs(c echo.Context) error {
+	filter, err := parseLogFilter(c)
+	if err != nil {
+		return h.errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+	size, err := positiveParam(c, "lines", logPageLines, maxLogPageLines)
+	if err != nil {
+		return h.errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+	page, err := positiveParam(c, "page", 1, 0)
+	if err != nil {
+		return h.errorResponse(c, http.StatusBadRequest, err.Error())
+	}
 
-		// Keep only last 50 lines
-		if len(logs) > 50 {
-			logs = logs[len(logs)-50:]
+	source := "app"
+	var entries []logging.Entry
+	if c.QueryParam("source") == "access" && h.accessLogPath != "" {
+		source = "access"
+		for _, line := range tailFile(h.accessLogPath, logScanLines) {
+			entries = append(entries, parseLogLine(line))
 		}
+	} else {
+		entries = h.appLogs()
+	}
+	filtered := filter.apply(entries)
+
+	empty := h.tr(c, "logs.empty")
+	switch {
+	case len(entries) > 0:
+		empty = h.tr(c, "logs.no_match")
+	case source == "app" && h.appLogPath == "":
+		empty = h.tr(c, "logs.stdout_only")
+	}
+
+	var level string
+	if filter.hasLevel {
+		level = filter.level.String()
+	}
+	query := url.Values{"source": {source}}
+	if level != "" {
+		query.Set("level", level)
+	}
+	if filter.query != "" {
+		query.Set("q", filter.query)
+	}
+	if size != logPageLines {
+		query.Set("lines", strconv.Itoa(size))
 	}
 
 	data := struct {
-		Logs []string
+		logPage
+		Empty        string
+		Source       string
+		HasAccessLog bool
+		Levels       []string
+		Level        string
+		Query        string
 	}{
-		Logs: logs,
+		logPage:      paginateLogs(filtered, page, size, query),
+		Empty:        empty,
+		Source:       source,
+		HasAccessLog: h.accessLogPath != "",
+		Levels:       []string{"error", "warning", "info", "debug", "trace"},
+		Level:        level,
+		Query:        filter.query,
 	}
 
-	c.Response().Header().Set("Content-Type", "text/html; charset=utf-8")
-	return h.templates.GetLogs().Execute(c.Response().Writer, data)
+	return h.render(c, http.StatusOK, h.templates.GetLogs, data)
 }
 
-func (h *Handlers) SwaggerUI(c echo.Context) error {
-	c.Response().Header().Set("Content-Type", "text/html; charset=utf-8")
-	return h.templates.GetSwagger().Execute(c.Response().Writer, nil)
+// LogsResponse is returned by the JSON logs endpoint.
+type LogsResponse struct {
+	Entries []logging.Entry `json:"entries"`
 }
 
-func (h *Handlers) SwaggerSpec(c echo.Context) error {
-	specPaths := []string{
-		"api/openapi.yaml",
-		filepath.Join(".", "api", "openapi.yaml"),
-		"../../../api/openapi.yaml", // For tests
+// LogEntries returns recent application log entries as JSON. ?limit= caps
+// the number of entries (default 100); ?level= and ?q= filter them as on
+// the /logs page.
+func (h *Handlers) LogEntries(c echo.Context) error {
+	limit := 100
+	if raw := c.QueryParam("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+		}
+		limit = parsed
+	}
+	filter, err := parseLogFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	var data []byte
-	var err error
+	entries := filter.apply(h.appLogs())
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return c.JSON(http.StatusOK, LogsResponse{Entries: entries})
+}
 
-	for _, specPath := range specPaths {
-		data, err = os.ReadFile(specPath)
-		if err == nil {
-			break
+// appLogs returns the application log entries, oldest first. Entries come
+// from the in-memory buffer; app.log is only read when the buffer is still
+// empty, e.g. right after a restart.
+func (h *Handlers) appLogs() []logging.Entry {
+	entries := h.logs.Entries()
+	if len(entries) == 0 && h.appLogPath != "" {
+		for _, line := range tailFile(h.appLogPath, logScanLines) {
+			entries = append(entries, parseLogLine(line))
 		}
 	}
+	return entries
+}
 
+// tailFile returns the last n lines of the file at path, or nil if it
+// cannot be read.
+func tailFile(path string, n int) []string {
+	file, err := os.Open(path)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "OpenAPI spec not found"})
+		return nil
 	}
+	defer file.Close()
 
-	return c.Blob(http.StatusOK, "application/yaml", data)
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return lines
 }
 
-func (h *Handlers) RedocDocs(c echo.Context) error {
-	specPaths := []string{
-		"api/openapi.yaml",
-		filepath.Join(".", "api", "openapi.yaml"),
-		"../../../api/openapi.yaml", // For tests
+// docsAsset returns the URL for a documentation asset, preferring the copy
+// embedded in the binary and falling back to the CDN when it is unavailable.
+func (h *Handlers) docsAsset(name, cdnURL string) string {
+	if !h.useCDN {
+		if url := web.AssetURL(name); url != "" {
+			return h.basePath + url
+		}
 	}
+	return cdnURL
+}
 
-	var data []byte
-	var err error
+// serverURL returns the URL the client reaches greetd under, including the
+// base path, such as "https://tools.example.com/greetd". Forwarded headers
+// are honored from trusted proxies.
+func (h *Handlers) serverURL(c echo.Context) string {
+	return externalURL(c.Request(), h.trustedProxy) + h.basePath
+}
 
-	for _, specPath := range specPaths {
-		data, err = os.ReadFile(specPath)
-		if err == nil {
-			break
-		}
+// SwaggerUI serves the Swagger UI page. It loads the spec from the server
+// URL the client used, so "Try it out" works behind proxies, and keeps the
+// API key entered with "Authorize" across reloads.
+func (h *Handlers) SwaggerUI(c echo.Context) error {
+	serverURL := h.serverURL(c)
+	data := struct {
+		CSS       string
+		BundleJS  string
+		PresetJS  string
+		SpecURL   string
+		ServerURL string
+	}{
+		CSS:       h.docsAsset("swagger-ui/swagger-ui.css", swaggerCDN+"swagger-ui.css"),
+		BundleJS:  h.docsAsset("swagger-ui/swagger-ui-bundle.js", swaggerCDN+"swagger-ui-bundle.js"),
+		PresetJS:  h.docsAsset("swagger-ui/swagger-ui-standalone-preset.js", swaggerCDN+"swagger-ui-standalone-preset.js"),
+		SpecURL:   serverURL + "/swagger/openapi.yaml",
+		ServerURL: serverURL,
+	}
+
+	// The page links content-hashed assets, so it must not be cached itself.
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	c.Response().Header().Add("Vary", "X-Forwarded-Proto, X-Forwarded-Host")
+	return h.render(c, http.StatusOK, h.templates.GetSwagger, data)
+}
+
+func (h *Handlers) SwaggerSpec(c echo.Context) error {
+	return h.serveSpec(c, "application/yaml", (*openAPISpec).YAML)
+}
+
+// SwaggerSpecJSON serves the OpenAPI spec converted to JSON.
+func (h *Handlers) SwaggerSpecJSON(c echo.Context) error {
+	return h.serveSpec(c, echo.MIMEApplicationJSONCharsetUTF8, (*openAPISpec).JSON)
+}
+
+// serveSpec renders the OpenAPI spec with the URL the client reached the
+// server on as its first server, so "Try it out" calls the right host.
+func (h *Handlers) serveSpec(c echo.Context, contentType string, render func(*openAPISpec, string) ([]byte, error)) error {
+	spec, err := h.spec.load()
+	if errors.Is(err, errSpecNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "OpenAPI spec not found"})
+	}
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to load OpenAPI spec")
+		return h.internalError(c, http.StatusInternalServerError, err, "")
 	}
 
+	data, err := render(spec, h.serverURL(c))
 	if err != nil {
+		h.logger.WithError(err).Warn("Failed to render OpenAPI spec")
+		return h.internalError(c, http.StatusInternalServerError, err, "")
+	}
+
+	// The spec is read from disk and may change, and its servers entry
+	// depends on the request, so clients revalidate every time and get a
+	// 304 while it is unchanged.
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	c.Response().Header().Add("Vary", "X-Forwarded-Proto, X-Forwarded-Host")
+	if notModified(c, contentETag(data)) {
+		return nil
+	}
+
+	return c.Blob(http.StatusOK, contentType, data)
+}
+
+func (h *Handlers) RedocDocs(c echo.Context) error {
+	spec, err := h.spec.load()
+	if errors.Is(err, errSpecNotFound) {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "OpenAPI spec not found"})
 	}
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to load OpenAPI spec")
+		return h.internalError(c, http.StatusInternalServerError, err, "")
+	}
 
-	var spec map[string]interface{}
-	if err := yaml.Unmarshal(data, &spec); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Invalid OpenAPI spec"})
+	data := struct {
+		Title   string
+		RedocJS string
+		SpecURL string
+	}{
+		Title:   spec.title,
+		RedocJS: h.docsAsset("redoc/redoc.standalone.js", redocCDN),
+		SpecURL: h.serverURL(c) + "/swagger/openapi.yaml",
 	}
 
-	info, ok := spec["info"].(map[string]interface{})
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	c.Response().Header().Add("Vary", "X-Forwarded-Proto, X-Forwarded-Host")
+	return h.render(c, http.StatusOK, h.templates.GetRedoc, data)
+}
+
+// Static serves assets embedded under internal/web/static. Content-hashed
+// paths are cached indefinitely; plain names get a short max-age. Both
+// answer If-None-Match with 304.
+func (h *Handlers) Static(c echo.Context) error {
+	return h.serveAsset(c, c.Param("*"))
+}
+
+// Favicon serves the embedded favicon.ico, which browsers request from the
+// root whether or not a page links it.
+func (h *Handlers) Favicon(c echo.Context) error {
+	return h.serveAsset(c, "favicon.ico")
+}
+
+func (h *Handlers) serveAsset(c echo.Context, name string) error {
+	asset, hashed, ok := web.LookupAsset(name)
 	if !ok {
-		info = map[string]interface{}{"title": "Greetd API", "version": "1.0.0"}
+		return echo.ErrNotFound
 	}
 
-	title, _ := info["title"].(string)
-	if title == "" {
-		title = "Greetd API"
+	if hashed {
+		c.Response().Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		c.Response().Header().Set("Cache-Control", "public, max-age=3600")
+	}
+	if notModified(c, `"`+asset.Hash+`"`) {
+		return nil
 	}
 
-	data_struct := struct {
-		Title string
-	}{
-		Title: title,
+	return c.Blob(http.StatusOK, asset.ContentType, asset.Data)
+}
+
+// MethodNotAllowed reports a known path requested with an unsupported method.
+// The router has already set the Allow header.
+func (h *Handlers) MethodNotAllowed(c echo.Context) error {
+	allow := c.Response().Header().Get(echo.HeaderAllow)
+	message := fmt.Sprintf("%s is not supported for %s; allowed methods: %s", c.Request().Method, c.Request().URL.Path, allow)
+	return h.errorResponse(c, http.StatusMethodNotAllowed, message)
+}
+
+// ServerError reports an unhandled error, with its details only in debug
+// mode. The status is taken from an *echo.HTTPError and defaults to 500.
+func (h *Handlers) ServerError(c echo.Context, err error) error {
+	status := http.StatusInternalServerError
+	var he *echo.HTTPError
+	if errors.As(err, &he) && he.Code >= http.StatusInternalServerError {
+		status = he.Code
+	}
+
+	h.logger.WithError(err).WithField("uri", h.redact.URI(c.Request().RequestURI)).Error("Unhandled error")
+	return h.internalError(c, status, err, "")
+}
+
+// maxStackLines bounds the stack in a debug 500 response.
+const maxStackLines = 20
+
+// internalError answers an unexpected error. The response names only the
+// request ID to find the logged details by, unless server.debug is on: then
+// it carries err and the top of stack, if there is one.
+func (h *Handlers) internalError(c echo.Context, status int, err error, stack string) error {
+	problem := ProblemDetails{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    "An unexpected error occurred",
+		RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+	}
+	if h.debug.Load() {
+		problem.Error = err.Error()
+		if lines := strings.Split(stack, "\n"); len(lines) > maxStackLines {
+			stack = strings.Join(lines[:maxStackLines], "\n") + "\n…"
+		}
+		problem.Stack = stack
 	}
+	return h.problem(c, problem)
+}
 
-	c.Response().Header().Set("Content-Type", "text/html; charset=utf-8")
-	return h.templates.GetRedoc().Execute(c.Response().Writer, data_struct)
+// clientError answers a 4xx error the way echo's default error handler
+// does, adding the error itself in debug mode. Unlike e.Debug, this follows
+// PUT /admin/debug.
+func (h *Handlers) clientError(c echo.Context, he *echo.HTTPError, err error) error {
+	var body interface{} = he.Message
+	switch m := he.Message.(type) {
+	case string:
+		fields := echo.Map{"message": m}
+		if h.debug.Load() {
+			fields["error"] = err.Error()
+		}
+		body = fields
+	case error:
+		body = echo.Map{"message": m.Error()}
+	}
+	if c.Request().Method == http.MethodHead {
+		return c.NoContent(he.Code)
+	}
+	return c.JSON(he.Code, body)
 }
 
-func (h *Handlers) NotFound(c echo.Context) error {
-	// For API requests (JSON), return JSON error
-	if c.Request().Header.Get("Accept") == "application/json" ||
-		c.Request().Header.Get("Content-Type") == "application/json" {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error":   "Not Found",
-			"message": "The requested endpoint does not exist",
+// errorResponse writes a JSON error body, or the HTML error page for
+// browsers.
+func (h *Handlers) errorResponse(c echo.Context, status int, message string) error {
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+
+	if !wantsHTML(c) {
+		return c.JSON(status, map[string]string{
+			"error":   http.StatusText(status),
+			"message": message,
 		})
 	}
+	return h.errorPage(c, ProblemDetails{Title: http.StatusText(status), Status: status, Detail: message})
+}
+
+// ProblemDetails is an RFC 9457 application/problem+json error body.
+type ProblemDetails struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Suggestions lists similar paths for a 404.
+	Suggestions []string `json:"suggestions,omitempty"`
+	// Problems lists the invalid entries of a rejected import.
+	Problems []ImportProblem `json:"problems,omitempty"`
+	// RequestID identifies a 5xx response in the logs.
+	RequestID string `json:"request_id,omitempty"`
+	// Error and Stack describe an unexpected error in debug mode.
+	Error string `json:"error,omitempty"`
+	Stack string `json:"stack,omitempty"`
+}
+
+// problemResponse writes an application/problem+json body, or the HTML
+// error page for browsers.
+func (h *Handlers) problemResponse(c echo.Context, status int, detail string) error {
+	return h.problem(c, ProblemDetails{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	})
+}
+
+// problem writes problem as application/problem+json, or as the HTML error
+// page for browsers.
+func (h *Handlers) problem(c echo.Context, problem ProblemDetails) error {
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	if !wantsHTML(c) {
+		return h.problemJSON(c, problem)
+	}
+	return h.errorPage(c, problem)
+}
+
+// problemJSON writes problem as application/problem+json.
+func (h *Handlers) problemJSON(c echo.Context, problem ProblemDetails) error {
+	data, err := json.Marshal(problem)
+	if err != nil {
+		return err
+	}
+	return c.Blob(problem.Status, mimeProblemJSON, data)
+}
+
+// errorPage renders problem as the HTML error page. If the error template
+// itself fails, the detail is sent as plain text.
+func (h *Handlers) errorPage(c echo.Context, problem ProblemDetails) error {
+	data := struct {
+		Status    int
+		Title     string
+		Message   string
+		RequestID string
+		Error     string
+		Stack     string
+	}{
+		Status:    problem.Status,
+		Title:     problem.Title,
+		Message:   problem.Detail,
+		RequestID: problem.RequestID,
+		Error:     problem.Error,
+		Stack:     problem.Stack,
+	}
+
+	c.Response().Header().Add(echo.HeaderVary, "Accept-Language, Cookie")
+	body, name, err := h.execute(h.templates.GetError, h.locale(c), data)
+	if err != nil {
+		h.logger.WithError(err).WithField("template", name).Error("Failed to render template")
+		return c.String(problem.Status, problem.Detail)
+	}
+	return c.Blob(problem.Status, mimeHTML, body)
+}
+
+// render executes a page template in the language of the request into a
+// buffer and sends it with status.
+// A template that fails to parse or execute answers 500 instead of a
+// half-written page; in dev and debug mode the response includes the error.
+func (h *Handlers) render(c echo.Context, status int, get func(lang string) (*template.Template, error), data interface{}) error {
+	c.Response().Header().Add(echo.HeaderVary, "Accept-Language, Cookie")
+	body, name, err := h.execute(get, h.locale(c), data)
+	if err != nil {
+		h.logger.WithError(err).WithField("template", name).Error("Failed to render template")
+
+		if errors.Is(err, web.ErrTemplateUnavailable) && wantsHTML(c) {
+			var detail string
+			if h.templates.DevMode() || h.debug.Load() {
+				detail = err.Error()
+			}
+			return c.Blob(http.StatusInternalServerError, mimeHTML, web.FallbackPage(name, detail))
+		}
+
+		detail := "The page could not be rendered"
+		if h.templates.DevMode() || h.debug.Load() {
+			detail += ": " + err.Error()
+		}
+		return h.problemResponse(c, http.StatusInternalServerError, detail)
+	}
+	return c.Blob(status, mimeHTML, body)
+}
+
+// execute runs the template returned by get for lang and returns its output
+// and name.
+func (h *Handlers) execute(get func(lang string) (*template.Template, error), lang string, data interface{}) ([]byte, string, error) {
+	tmpl, err := get(lang)
+	if err != nil {
+		var parseErr *web.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, parseErr.Name, err
+		}
+		return nil, "", err
+	}
 
-	// For browser requests, return helpful HTML page
-	c.Response().Header().Set("Content-Type", "text/html; charset=utf-8")
-	c.Response().WriteHeader(http.StatusNotFound)
-	return h.templates.GetNotFound().Execute(c.Response().Writer, nil)
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, tmpl.Name(), err
+	}
+	return buf.Bytes(), tmpl.Name(), nil
 }
@@ -2,31 +2,58 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/logging"
 	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+	"github.com/svanhalla/prompt-lab/greetd/internal/version"
+	"github.com/svanhalla/prompt-lab/greetd/internal/web"
 )
 
-func setupTestHandlers(t *testing.T) (*Handlers, string) {
+func setupTestHandlers(t testing.TB) (*Handlers, string) {
+	return setupTestHandlersWithConfig(t, nil)
+}
+
+// setupTestHandlersWithConfig lets a test adjust the default config before
+// the handlers are created.
+func setupTestHandlersWithConfig(t testing.TB, configure func(*config.Config)) (*Handlers, string) {
 	tmpDir, err := os.MkdirTemp("", "greetd-test")
 	require.NoError(t, err)
 
 	store := storage.NewMessageStore(tmpDir)
-	err = store.Load()
+	err = store.Load(context.Background())
 	require.NoError(t, err)
 
 	logger := logrus.New()
 	logger.SetOutput(os.Stderr)
 
-	handlers, err := NewHandlers(store, logger, tmpDir)
+	cfg := config.DefaultConfig()
+	cfg.DataPath = tmpDir
+	if configure != nil {
+		configure(cfg)
+	}
+
+	handlers, err := NewHandlers(cfg, store, logger)
 	if err != nil {
 		t.Fatalf("Failed to create handlers: %v", err)
 	}
@@ -56,6 +83,20 @@ func TestHealthHandler(t *testing.T) {
 	assert.NotEmpty(t, response.Version.Version)
 }
 
+func TestVersionHandler(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, handlers.Version(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/version", nil), rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response version.Info
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, version.Get(), response)
+	assert.Equal(t, runtime.GOOS, response.OS)
+}
+
 func TestHelloHandler(t *testing.T) {
 	handlers, tmpDir := setupTestHandlers(t)
 	defer os.RemoveAll(tmpDir)
@@ -75,6 +116,16 @@ func TestHelloHandler(t *testing.T) {
 			query:    "?name=Alice",
 			expected: "Hello, Alice!",
 		},
+		{
+			name:     "repeat and shout",
+			query:    "?name=Alice&repeat=2&shout=true",
+			expected: "HELLO, ALICE!\nHELLO, ALICE!",
+		},
+		{
+			name:     "time aware",
+			query:    "?name=Alice&time_aware=1&at=19:30",
+			expected: "Good evening, Alice!",
+		},
 	}
 
 	for _, tt := range tests {
@@ -139,6 +190,9 @@ func TestMessageHandlers(t *testing.T) {
 	require.NoError(t, err)
 
 	assert.Equal(t, newMessage, response.Message)
+	assert.Equal(t, int64(1), response.Revision)
+	require.NotNil(t, response.Previous)
+	assert.Equal(t, "Hello, World!", *response.Previous)
 
 	// Test GET message (updated)
 	req = httptest.NewRequest(http.MethodGet, "/message", nil)
@@ -148,21 +202,129 @@ func TestMessageHandlers(t *testing.T) {
 	err = handlers.GetMessage(c)
 	require.NoError(t, err)
 
+	response = MessageResponse{}
 	err = json.Unmarshal(rec.Body.Bytes(), &response)
 	require.NoError(t, err)
 
 	assert.Equal(t, newMessage, response.Message)
+	assert.Nil(t, response.Previous)
 }
 
-func TestSetMessageValidation(t *testing.T) {
+func TestSetMessagePrevious(t *testing.T) {
 	handlers, tmpDir := setupTestHandlers(t)
 	defer os.RemoveAll(tmpDir)
 
+	e := echo.New()
+	post := func(body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/message", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		require.NoError(t, handlers.SetMessage(e.NewContext(req, rec)))
+		return rec
+	}
+
+	rec := post(`{"message":"First"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"previous":"Hello, World!"`)
+
+	// A conditional update reports what it replaced too.
+	rec = post(`{"message":"Second","revision":1}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var res MessageResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "Second", res.Message)
+	assert.Equal(t, int64(2), res.Revision)
+	require.NotNil(t, res.Previous)
+	assert.Equal(t, "First", *res.Previous)
+
+	// So does one conditional on If-Match.
+	rec = post(`{"message":"Third"}`, "If-Match", `"2"`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"previous":"Second"`)
+
+	// A conflict has no previous message, only the current one.
+	rec = post(`{"message":"Stale","revision":1}`)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "previous")
+}
+
+func TestMessageMetadata(t *testing.T) {
+	handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+		cfg.Security.APIKeys = []string{"secret"}
+	})
+	defer os.RemoveAll(tmpDir)
+
+	e := echo.New()
+	post := func(key string) MessageResponse {
+		req := httptest.NewRequest(http.MethodPost, "/message", strings.NewReader(`{"message":"Fresh"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		require.NoError(t, handlers.SetMessage(e.NewContext(req, rec)))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res MessageResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return res
+	}
+
+	res := post("")
+	assert.Equal(t, storage.UpdatedByAPI, res.UpdatedBy)
+	require.NotNil(t, res.UpdatedAt)
+	assert.WithinDuration(t, time.Now(), *res.UpdatedAt, 2*time.Second)
+
+	// Keys are named by a fingerprint, never by the key itself.
+	res = post("secret")
+	assert.Regexp(t, `^api-key:[0-9a-f]{8}$`, res.UpdatedBy)
+	assert.Equal(t, storage.UpdatedByAPI, post("wrong").UpdatedBy)
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, handlers.GetMessage(e.NewContext(httptest.NewRequest(http.MethodGet, "/message", nil), rec)))
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &raw))
+	assert.Equal(t, "Fresh", raw["message"])
+	assert.Equal(t, storage.UpdatedByAPI, raw["updated_by"])
+	_, err := time.Parse(time.RFC3339, raw["updated_at"].(string))
+	assert.NoError(t, err)
+}
+
+func TestSetMessageValidation(t *testing.T) {
+	handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+		cfg.Message.MaxLength = 10
+		cfg.Message.ForbiddenPatterns = []string{`(?i)spam`}
+	})
+	defer os.RemoveAll(tmpDir)
+
 	tests := []struct {
-		name       string
-		body       string
-		statusCode int
+		name        string
+		contentType string
+		body        string
+		statusCode  int
+		errorText   string
 	}{
+		{
+			name:       "zero-width only message",
+			body:       `{"message": "\u200b\ufeff"}`,
+			statusCode: http.StatusBadRequest,
+			errorText:  "cannot be empty",
+		},
+		{
+			name:       "forbidden pattern",
+			body:       `{"message": "Buy SPAM"}`,
+			statusCode: http.StatusUnprocessableEntity,
+			errorText:  "forbidden content",
+		},
+		{
+			name:       "bidi override",
+			body:       `{"message": "abc\u202edcba"}`,
+			statusCode: http.StatusBadRequest,
+			errorText:  "bidirectional control characters",
+		},
 		{
 			name:       "empty message",
 			body:       `{"message": ""}`,
@@ -174,22 +336,45 @@ func TestSetMessageValidation(t *testing.T) {
 			statusCode: http.StatusBadRequest,
 		},
 		{
-			name:       "invalid JSON",
-			body:       `{"message": }`,
-			statusCode: http.StatusBadRequest,
+			name:       "too long",
+			body:       `{"message": "this is too long"}`,
+			statusCode: http.StatusUnprocessableEntity,
+			errorText:  "maximum length of 10",
+		},
+		{
+			name:       "limit counts characters not bytes",
+			body:       `{"message": "åäöåäöåäöå"}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:        "wrong content type",
+			contentType: "text/plain",
+			body:        `{"message": "hi"}`,
+			statusCode:  http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "content type with charset",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"message": "hi"}`,
+			statusCode:  http.StatusOK,
 		},
 		{
 			name:       "valid message",
-			body:       `{"message": "Valid message"}`,
+			body:       `{"message": "Valid"}`,
 			statusCode: http.StatusOK,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+
 			e := echo.New()
 			req := httptest.NewRequest(http.MethodPost, "/message", bytes.NewReader([]byte(tt.body)))
-			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Content-Type", contentType)
 			rec := httptest.NewRecorder()
 			c := e.NewContext(req, rec)
 
@@ -197,6 +382,1393 @@ func TestSetMessageValidation(t *testing.T) {
 			require.NoError(t, err)
 
 			assert.Equal(t, tt.statusCode, rec.Code)
+			if tt.errorText != "" {
+				assert.Equal(t, mimeProblemJSON, rec.Header().Get("Content-Type"))
+				var problem ProblemDetails
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+				assert.Equal(t, tt.statusCode, problem.Status)
+				assert.Contains(t, problem.Detail, tt.errorText)
+			}
+		})
+	}
+}
+
+func TestSetMessageMalformedBody(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	tests := []struct {
+		name   string
+		body   string
+		detail string
+	}{
+		{"invalid JSON", `{"message": }`, "Invalid JSON"},
+		{"truncated", `{"message": "hi"`, "Invalid JSON"},
+		{"empty body", ``, "Request body is empty"},
+		{"whitespace only", " \n", "Request body is empty"},
+		{"unknown field", `{"mesage": "typo"}`, `Unknown field "mesage"`},
+		{"wrong type", `{"message": 42}`, `Field "message" must be a string`},
+		{"wrong revision type", `{"message": "hi", "revision": "3"}`, `Field "revision" must be an integer`},
+		{"trailing garbage", `{"message": "x"} trailing junk`, "Unexpected data after the JSON object"},
+		{"second object", `{"message": "x"}{"message": "y"}`, "Unexpected data after the JSON object"},
+		{"array", `[{"message": "x"}]`, "Request body must be a JSON object"},
+		{"string", `"x"`, "Request body must be a JSON object"},
+		{"null", `null`, "Request body must be a JSON object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/message", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			require.NoError(t, handlers.SetMessage(e.NewContext(req, rec)))
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, mimeProblemJSON, rec.Header().Get("Content-Type"))
+			var problem ProblemDetails
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+			assert.Equal(t, http.StatusBadRequest, problem.Status)
+			assert.Equal(t, tt.detail, problem.Detail)
+		})
+	}
+
+	// Trailing whitespace is not trailing data.
+	req := httptest.NewRequest(http.MethodPost, "/message", strings.NewReader("{\"message\": \"x\"}\n\n"))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	require.NoError(t, handlers.SetMessage(echo.New().NewContext(req, rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestSetMessageNormalized(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	// "e" followed by a combining acute accent, and a zero-width space.
+	req := httptest.NewRequest(http.MethodPost, "/message", strings.NewReader(`{"message": "Cafe\u0301\u200b!"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	require.NoError(t, handlers.SetMessage(echo.New().NewContext(req, rec)))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Caf\u00e9!", handlers.store.GetMessage(context.Background()))
+}
+
+func TestSetMessageBannedWords(t *testing.T) {
+	wordsPath := filepath.Join(t.TempDir(), "banned.txt")
+	modTime := time.Now().Add(-time.Hour)
+	writeWords := func(content string) {
+		t.Helper()
+		modTime = modTime.Add(time.Second)
+		require.NoError(t, os.WriteFile(wordsPath, []byte(content), 0644))
+		require.NoError(t, os.Chtimes(wordsPath, modTime, modTime))
+	}
+	writeWords("darn\n")
+
+	post := func(handlers *Handlers, message string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(MessageRequest{Message: message})
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/message", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		require.NoError(t, handlers.SetMessage(echo.New().NewContext(req, rec)))
+		return rec
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+			cfg.Message.BannedWordsFile = wordsPath
+		})
+		defer os.RemoveAll(tmpDir)
+
+		rec := post(handlers, "Well, D-A-R-N!")
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), "banned words policy")
+		assert.NotContains(t, strings.ToLower(rec.Body.String()), "darn")
+
+		// The list is read again when the file changes.
+		writeWords("drat\n")
+		assert.Equal(t, http.StatusOK, post(handlers, "darn").Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, post(handlers, "drat").Code)
+
+		// The maintenance message follows the same policy.
+		req := httptest.NewRequest(http.MethodPost, "/admin/maintenance", strings.NewReader(`{"enabled":true,"message":"Drat, back soon"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec = httptest.NewRecorder()
+		require.NoError(t, handlers.SetMaintenance(echo.New().NewContext(req, rec)))
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), "banned words policy")
+	})
+
+	t.Run("mask", func(t *testing.T) {
+		writeWords("darn\n")
+		handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+			cfg.Message.BannedWordsFile = wordsPath
+			cfg.Message.FilterMode = config.FilterMask
+		})
+		defer os.RemoveAll(tmpDir)
+
+		rec := post(handlers, "Oh darn it")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Oh **** it", handlers.store.GetMessage(context.Background()))
+	})
+}
+
+func TestSetMessageBodyLimit(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	e := echo.New()
+	e.POST("/message", handlers.SetMessage, middleware.BodyLimit("16B"))
+
+	req := httptest.NewRequest(http.MethodPost, "/message", strings.NewReader(`{"message": "well over sixteen bytes"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+func TestSetMessageCancelled(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/message", strings.NewReader(`{"message": "Too late"}`)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	require.NoError(t, handlers.SetMessage(e.NewContext(req, rec)))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, mimeProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	assert.Contains(t, rec.Body.String(), `"detail":"The request ended before the message was saved"`)
+	assert.Equal(t, "Hello, World!", handlers.store.GetMessage(context.Background()))
+}
+
+func TestStaticHandler(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	asset, _, ok := web.LookupAsset("swagger-ui/swagger-ui.css")
+	require.True(t, ok)
+
+	tests := []struct {
+		name         string
+		path         string
+		statusCode   int
+		cacheControl string
+	}{
+		{"hashed name", asset.HashedName(), http.StatusOK, "public, max-age=31536000, immutable"},
+		{"plain name", "swagger-ui/swagger-ui.css", http.StatusOK, "public, max-age=3600"},
+		{"unknown asset", "swagger-ui/missing.js", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/static/"+tt.path, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("*")
+			c.SetParamValues(tt.path)
+
+			err := handlers.Static(c)
+			if tt.statusCode == http.StatusNotFound {
+				assert.Equal(t, echo.ErrNotFound, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.statusCode, rec.Code)
+			assert.Equal(t, tt.cacheControl, rec.Header().Get("Cache-Control"))
+			assert.Contains(t, rec.Header().Get("Content-Type"), "text/css")
+			assert.Equal(t, asset.Data, rec.Body.Bytes())
+		})
+	}
+}
+
+func TestSwaggerUIAssets(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	render := func() string {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/swagger/", nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handlers.SwaggerUI(e.NewContext(req, rec)))
+		return rec.Body.String()
+	}
+
+	body := render()
+	assert.Contains(t, body, web.AssetURL("swagger-ui/swagger-ui-bundle.js"))
+	assert.NotContains(t, body, "unpkg.com")
+
+	handlers.useCDN = true
+	body = render()
+	assert.Contains(t, body, "https://unpkg.com/swagger-ui-dist@")
+	assert.NotContains(t, body, "/static/swagger-ui")
+}
+
+func TestRedocAssets(t *testing.T) {
+	if web.AssetURL("redoc/redoc.standalone.js") == "" {
+		t.Fatal("internal/web/static/redoc/redoc.standalone.js is missing; run make assets and commit it")
+	}
+	// The docs page reads api/openapi.yaml relative to the repository root.
+	t.Chdir("../..")
+
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	render := func() string {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/docs", nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handlers.RedocDocs(e.NewContext(req, rec)))
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+
+	body := render()
+	assert.Contains(t, body, `src="/static/redoc/redoc.standalone.`)
+	assert.NotContains(t, body, "cdn.redoc.ly")
+
+	handlers.useCDN = true
+	assert.Contains(t, render(), redocCDN)
+}
+
+func TestDocsSpecURL(t *testing.T) {
+	// The docs pages read api/openapi.yaml relative to the repository root.
+	t.Chdir("../..")
+
+	handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+		cfg.Server.BasePath = "/greetd"
+		cfg.Server.TrustedProxies = []string{"192.0.2.1"}
+	})
+	defer os.RemoveAll(tmpDir)
+
+	serve := func(handler echo.HandlerFunc, path string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "192.0.2.1:4711"
+		req.Header.Set(echo.HeaderXForwardedProto, "https")
+		req.Header.Set("X-Forwarded-Host", "tools.example.com")
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler(e.NewContext(req, rec)))
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec
+	}
+
+	const specURL = "https://tools.example.com/greetd/swagger/openapi.yaml"
+
+	rec := serve(handlers.SwaggerUI, "/greetd/swagger/")
+	body := rec.Body.String()
+	assert.Contains(t, body, `data-spec-url="`+specURL+`"`)
+	assert.Contains(t, body, `data-server-url="https://tools.example.com/greetd"`)
+	assert.NotContains(t, body, `'/swagger/openapi.yaml'`)
+	assert.Contains(t, body, "persistAuthorization: true")
+	assert.Contains(t, body, "requestInterceptor")
+	assert.Contains(t, rec.Header().Values("Vary"), "X-Forwarded-Proto, X-Forwarded-Host")
+
+	rec = serve(handlers.RedocDocs, "/greetd/docs")
+	assert.Contains(t, rec.Body.String(), `<redoc spec-url="`+specURL+`">`)
+	assert.Contains(t, rec.Header().Values("Vary"), "X-Forwarded-Proto, X-Forwarded-Host")
+
+	// The served spec declares the API key so Swagger UI offers "Authorize".
+	rec = serve(handlers.SwaggerSpecJSON, "/greetd/swagger/openapi.json")
+	var spec struct {
+		Servers []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+		Components struct {
+			SecuritySchemes map[string]struct {
+				Type string `json:"type"`
+				In   string `json:"in"`
+				Name string `json:"name"`
+			} `json:"securitySchemes"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &spec))
+	require.NotEmpty(t, spec.Servers)
+	assert.Equal(t, "https://tools.example.com/greetd", spec.Servers[0].URL)
+	scheme := spec.Components.SecuritySchemes["ApiKeyAuth"]
+	assert.Equal(t, "apiKey", scheme.Type)
+	assert.Equal(t, "header", scheme.In)
+	assert.Equal(t, APIKeyHeader, scheme.Name)
+}
+
+func TestHTMLPagesUseEmbeddedStylesheet(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	pages := []struct {
+		name    string
+		handler echo.HandlerFunc
+	}{
+		{"ui", handlers.UI},
+		{"logs", handlers.Logs},
+		{"404", handlers.NotFound},
+		{"status", handlers.Status},
+	}
+
+	stylesheet := `<link rel="stylesheet" href="` + web.AssetURL("app.css") + `">`
+	themeScript := `<script src="` + web.AssetURL("theme.js") + `"></script>`
+
+	for _, page := range pages {
+		t.Run(page.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(echo.HeaderAccept, "text/html,application/xhtml+xml,*/*;q=0.8")
+			rec := httptest.NewRecorder()
+
+			require.NoError(t, page.handler(e.NewContext(req, rec)))
+
+			assert.Contains(t, rec.Body.String(), stylesheet)
+			assert.Contains(t, rec.Body.String(), themeScript, "the theme must apply before the page is painted")
+			assert.Contains(t, rec.Body.String(), `id="theme-toggle"`)
+			assert.NotContains(t, rec.Body.String(), "cdn.tailwindcss.com")
+		})
+	}
+}
+
+func TestTemplateRenderErrors(t *testing.T) {
+	dir := t.TempDir()
+	uiPath := filepath.Join(dir, "ui.html")
+	require.NoError(t, os.WriteFile(uiPath, []byte("<p>{{.Message}}</p>"), 0644))
+
+	handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+		cfg.UI.DevMode = true
+		cfg.UI.TemplatesPath = dir
+	})
+	defer os.RemoveAll(tmpDir)
+	require.True(t, handlers.templates.DevMode())
+
+	get := func(accept string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/ui", nil)
+		req.Header.Set(echo.HeaderAccept, accept)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handlers.UI(e.NewContext(req, rec)))
+		return rec
+	}
+
+	rec := get("text/html")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "<p>Hello, World!</p>", rec.Body.String())
+
+	tests := []struct {
+		name     string
+		template string
+		detail   string
+	}{
+		{"parse error", "<p>{{ if }}</p>", "ui.html:1: missing value for if"},
+		{"execute error", "<p>partial</p>{{.Missing}}", "evaluate field Missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(uiPath, []byte(tt.template), 0644))
+
+			rec := get("text/html")
+			assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			assert.Contains(t, rec.Header().Get(echo.HeaderContentType), "text/html")
+			assert.Contains(t, rec.Body.String(), tt.detail)
+			assert.NotContains(t, rec.Body.String(), "partial")
+
+			rec = get("application/json")
+			assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			assert.Equal(t, "application/problem+json", rec.Header().Get(echo.HeaderContentType))
+			var problem ProblemDetails
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+			assert.Equal(t, http.StatusInternalServerError, problem.Status)
+			assert.Contains(t, problem.Detail, tt.detail)
+
+			entries := handlers.logs.Entries()
+			require.NotEmpty(t, entries)
+			last := entries[len(entries)-1]
+			assert.Equal(t, "Failed to render template", last.Message)
+			assert.Equal(t, "ui.html", last.Fields["template"])
+		})
+	}
+}
+
+func TestTemplateRenderErrorsDebug(t *testing.T) {
+	get := func(string) (*template.Template, error) {
+		return template.New("ui.html").Parse("<p>partial</p>{{.Missing}}")
+	}
+
+	for _, debug := range []bool{false, true} {
+		t.Run(fmt.Sprintf("debug %t", debug), func(t *testing.T) {
+			handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+				cfg.Server.Debug = debug
+			})
+			defer os.RemoveAll(tmpDir)
+			require.False(t, handlers.templates.DevMode())
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/ui", nil)
+			req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			require.NoError(t, handlers.render(e.NewContext(req, rec), http.StatusOK, get, struct{}{}))
+
+			assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			var problem ProblemDetails
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+			if debug {
+				assert.Contains(t, problem.Detail, "can't evaluate field Missing")
+			} else {
+				assert.Equal(t, "The page could not be rendered", problem.Detail)
+			}
+		})
+	}
+}
+
+func TestUnavailableTemplate(t *testing.T) {
+	// A page that does not load at startup stands in for one missing from
+	// the binary: neither can be parsed again.
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ui.html"), []byte("<p>{{ if }}</p>"), 0644))
+	configure := func(cfg *config.Config) {
+		cfg.UI.DevMode = true
+		cfg.UI.TemplatesPath = dir
+	}
+
+	server, _ := setupServer(t, configure)
+	handlers := server.handlers
+	require.Error(t, handlers.templates.Err())
+
+	serve := func(method, target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set(echo.HeaderAccept, accept)
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "/ui", "text/html")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Header().Get(echo.HeaderContentType), "text/html")
+	assert.Contains(t, rec.Body.String(), "500 Internal Server Error")
+	assert.Contains(t, rec.Body.String(), "ui.html")
+
+	rec = serve(http.MethodGet, "/ui", "application/json")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get(echo.HeaderContentType))
+
+	// Other pages and the JSON API keep working.
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/logs", "text/html").Code)
+	rec = serve(http.MethodGet, "/api/v1/message", "application/json")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Hello, World!")
+
+	res, _ := getHealth(t, handlers, "")
+	assert.Equal(t, "degraded", res.Status)
+	assert.Equal(t, "degraded", res.Checks["templates"])
+
+	cfg := config.DefaultConfig()
+	cfg.DataPath = t.TempDir()
+	configure(cfg)
+	cfg.UI.StrictTemplates = true
+	_, err := NewHandlers(cfg, handlers.store, logrus.New())
+	assert.ErrorContains(t, err, "failed to load templates")
+}
+
+func TestContentNegotiation(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	tests := []struct {
+		name        string
+		handler     echo.HandlerFunc
+		target      string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"hello default", handlers.Hello, "/hello?name=Ann", "", "application/json", `{"message":"Hello, Ann!","lang":"en"}` + "\n"},
+		{"hello json", handlers.Hello, "/hello?name=Ann", "application/json", "application/json", `{"message":"Hello, Ann!","lang":"en"}` + "\n"},
+		{"hello text", handlers.Hello, "/hello?name=Ann", "text/plain", "text/plain", "Hello, Ann!"},
+		{"hello yaml", handlers.Hello, "/hello?name=Ann", "application/yaml", "application/yaml", "message: Hello, Ann!\nlang: en\n"},
+		{"hello unknown accept", handlers.Hello, "/hello?name=Ann", "application/xml", "application/json", `{"message":"Hello, Ann!","lang":"en"}` + "\n"},
+		{"hello browser accept", handlers.Hello, "/hello?name=Ann", "text/html,application/xhtml+xml,*/*;q=0.8", "application/json", `{"message":"Hello, Ann!","lang":"en"}` + "\n"},
+		{"hello q-values", handlers.Hello, "/hello?name=Ann", "application/json;q=0.5, text/plain", "text/plain", "Hello, Ann!"},
+		{"hello format text", handlers.Hello, "/hello?name=Ann&format=text", "application/json", "text/plain", "Hello, Ann!"},
+		{"hello format yaml", handlers.Hello, "/hello?name=Ann&format=yaml", "", "application/yaml", "message: Hello, Ann!\nlang: en\n"},
+		{"hello format json", handlers.Hello, "/hello?name=Ann&format=json", "text/plain", "application/json", `{"message":"Hello, Ann!","lang":"en"}` + "\n"},
+		{"message default", handlers.GetMessage, "/message", "", "application/json", `{"message":"Hello, World!","revision":0}` + "\n"},
+		{"message text", handlers.GetMessage, "/message", "text/plain", "text/plain", "Hello, World!"},
+		{"message yaml", handlers.GetMessage, "/message", "application/x-yaml", "application/yaml", "message: Hello, World!\nrevision: 0\n"},
+		{"message format override", handlers.GetMessage, "/message?format=text", "application/yaml", "text/plain", "Hello, World!"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			require.NoError(t, tt.handler(e.NewContext(req, rec)))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Header().Get("Content-Type"), tt.contentType)
+			assert.Equal(t, tt.body, rec.Body.String())
 		})
 	}
 }
+
+func TestHelloLanguages(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	tests := []struct {
+		name           string
+		query          string
+		acceptLanguage string
+		message        string
+		lang           string
+	}{
+		{"swedish", "?lang=sv&name=Anna", "", "Hej, Anna!", "sv"},
+		{"german default name", "?lang=de", "", "Hallo, Welt!", "de"},
+		{"japanese", "?lang=ja&name=Yuki", "", "こんにちは、Yuki！", "ja"},
+		{"region subtag", "?lang=fr-CA&name=Luc", "", "Bonjour, Luc !", "fr"},
+		{"unknown language", "?lang=xx&name=Ann", "", "Hello, Ann!", "en"},
+		{"accept-language header", "?name=Ana", "es-ES,es;q=0.9,en;q=0.8", "¡Hola, Ana!", "es"},
+		{"accept-language q-values", "?name=Ann", "de;q=0.5, sv", "Hej, Ann!", "sv"},
+		{"query wins over header", "?lang=de&name=Ann", "sv", "Hallo, Ann!", "de"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/hello"+tt.query, nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			rec := httptest.NewRecorder()
+
+			require.NoError(t, handlers.Hello(e.NewContext(req, rec)))
+
+			var response HelloResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.Equal(t, tt.message, response.Message)
+			assert.Equal(t, tt.lang, response.Lang)
+		})
+	}
+}
+
+func TestHelloTemplate(t *testing.T) {
+	handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+		cfg.Greetings.Template = "Welcome to {{.Service}}, {{.Name}}!"
+	})
+	defer os.RemoveAll(tmpDir)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/hello?name=Ann", nil)
+	rec := httptest.NewRecorder()
+
+	require.NoError(t, handlers.Hello(e.NewContext(req, rec)))
+
+	var response HelloResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "Welcome to greetd, Ann!", response.Message)
+}
+
+func TestHelloStoredTemplate(t *testing.T) {
+	handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+		cfg.Greetings.UseStoredTemplate = true
+	})
+	defer os.RemoveAll(tmpDir)
+	ctx := context.Background()
+
+	hello := func() string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/hello?name=Ann", nil)
+		require.NoError(t, handlers.Hello(echo.New().NewContext(req, rec)))
+		var response HelloResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response.Message
+	}
+	ui := func() string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		require.NoError(t, handlers.UI(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/ui", nil), rec)))
+		return rec.Body.String()
+	}
+
+	require.NoError(t, handlers.store.SetMessage(ctx, "Welcome back, {{.Name}} — lunch is at noon", storage.UpdatedByAPI))
+	assert.Equal(t, "Welcome back, Ann — lunch is at noon", hello())
+	res, _ := getHealth(t, handlers, "")
+	assert.Equal(t, "ok", res.Checks["message_template"])
+	assert.NotContains(t, ui(), "not a valid greeting template")
+
+	// A changed message is parsed again.
+	require.NoError(t, handlers.store.SetMessage(ctx, "See you, {{.Name}}", storage.UpdatedByAPI))
+	assert.Equal(t, "See you, Ann", hello())
+
+	// Invalid templates fall back to the default greeting and are reported.
+	for _, message := range []string{"Hi {{.Name", `{{index .Name 0}}`} {
+		require.NoError(t, handlers.store.SetMessage(ctx, message, storage.UpdatedByAPI))
+		assert.Equal(t, "Hello, Ann!", hello(), message)
+		res, _ := getHealth(t, handlers, "")
+		assert.Equal(t, "ok", res.Status)
+		assert.Equal(t, "invalid", res.Checks["message_template"])
+		assert.Contains(t, ui(), "not a valid greeting template")
+	}
+}
+
+func TestHelloStoredTemplateOff(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+	require.NoError(t, handlers.store.SetMessage(context.Background(), "Welcome back, {{.Name}}", storage.UpdatedByAPI))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/hello?name=Ann", nil)
+	require.NoError(t, handlers.Hello(echo.New().NewContext(req, rec)))
+	assert.Contains(t, rec.Body.String(), "Hello, Ann!")
+
+	res, _ := getHealth(t, handlers, "")
+	assert.NotContains(t, res.Checks, "message_template")
+}
+
+func TestUIMessageRendering(t *testing.T) {
+	const script = `<script>alert(1)</script>`
+
+	tests := []struct {
+		name        string
+		markdown    bool
+		message     string
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:        "plain text is escaped",
+			message:     script,
+			contains:    []string{"&lt;script&gt;alert(1)&lt;/script&gt;"},
+			notContains: []string{script},
+		},
+		{
+			name:        "markdown is rendered",
+			markdown:    true,
+			message:     "**bold** and _italic_",
+			contains:    []string{"<strong>bold</strong>", "<em>italic</em>"},
+			notContains: []string{"<p>**bold**"},
+		},
+		{
+			name:        "markdown strips script tags",
+			markdown:    true,
+			message:     "hi " + script,
+			notContains: []string{script},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+				cfg.UI.RenderMarkdown = tt.markdown
+			})
+			defer os.RemoveAll(tmpDir)
+			require.NoError(t, handlers.store.SetMessage(context.Background(), tt.message, storage.UpdatedByAPI))
+
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/ui", nil), rec)
+			require.NoError(t, handlers.UI(c))
+
+			for _, s := range tt.contains {
+				assert.Contains(t, rec.Body.String(), s)
+			}
+			for _, s := range tt.notContains {
+				assert.NotContains(t, rec.Body.String(), s)
+			}
+
+			// The API always returns the stored text untouched.
+			rec = httptest.NewRecorder()
+			c = e.NewContext(httptest.NewRequest(http.MethodGet, "/message", nil), rec)
+			require.NoError(t, handlers.GetMessage(c))
+
+			var response MessageResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.Equal(t, tt.message, response.Message)
+		})
+	}
+}
+
+func TestUIMessageCSRF(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	e := echo.New()
+	csrf := CSRF("/ui", handlers.CSRFFailed)
+	e.GET("/ui", handlers.UI, csrf)
+	e.POST("/ui/message", handlers.UIMessage, csrf)
+
+	// Load the form to obtain the token cookie and hidden field.
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var cookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == csrfField {
+			cookie = c
+		}
+	}
+	require.NotNil(t, cookie, "CSRF cookie should be issued")
+	assert.Contains(t, rec.Body.String(), `name="_csrf" value="`+cookie.Value+`"`)
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/ui/message", strings.NewReader(form.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("missing token", func(t *testing.T) {
+		rec := post(url.Values{"message": {"forged"}})
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "session has expired")
+		assert.Contains(t, rec.Body.String(), `<form id="messageForm"`)
+		assert.NotEqual(t, "forged", handlers.store.GetMessage(context.Background()))
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		rec := post(url.Values{"message": {"forged"}, csrfField: {"not-the-token"}})
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.NotEqual(t, "forged", handlers.store.GetMessage(context.Background()))
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		rec := post(url.Values{"message": {"From the form"}, csrfField: {cookie.Value}})
+
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+		assert.Equal(t, "/ui", rec.Header().Get(echo.HeaderLocation))
+		assert.Equal(t, "From the form", handlers.store.GetMessage(context.Background()))
+		assert.Equal(t, storage.UpdatedByUI, handlers.store.Get(context.Background()).UpdatedBy)
+
+		page := httptest.NewRecorder()
+		e.ServeHTTP(page, httptest.NewRequest(http.MethodGet, "/ui", nil))
+		assert.Contains(t, page.Body.String(), "Last updated just now</time> by ui")
+	})
+}
+
+func TestUIMessageForm(t *testing.T) {
+	wordsPath := filepath.Join(t.TempDir(), "banned.txt")
+	require.NoError(t, os.WriteFile(wordsPath, []byte("darn\n"), 0644))
+	handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+		cfg.Message.MaxLength = 10
+		cfg.Message.ForbiddenPatterns = []string{`spam`}
+		cfg.Message.BannedWordsFile = wordsPath
+	})
+	defer os.RemoveAll(tmpDir)
+	require.NoError(t, handlers.store.SetMessage(context.Background(), "Stored", storage.UpdatedByAPI))
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		statusCode  int
+		contains    []string
+	}{
+		{
+			name:       "empty message",
+			body:       url.Values{"message": {"   "}}.Encode(),
+			statusCode: http.StatusBadRequest,
+			contains:   []string{`<p id="message-error" class="field-error small" role="alert">Message cannot be empty</p>`, `<p>Stored</p>`},
+		},
+		{
+			name:       "too long keeps the entered text",
+			body:       url.Values{"message": {"far too long for it"}}.Encode(),
+			statusCode: http.StatusUnprocessableEntity,
+			contains:   []string{"19 characters long, exceeding the maximum length of 10", `aria-invalid="true" aria-describedby="message-error"`, ">far too long for it</textarea>"},
+		},
+		{
+			name:       "too long for a new key",
+			body:       url.Values{"key": {"motd"}, "message": {"far too long for it"}}.Encode(),
+			statusCode: http.StatusUnprocessableEntity,
+			contains:   []string{`<p id="new-message-error" class="field-error small" role="alert">Message is 19 characters long`, ">far too long for it</textarea>"},
+		},
+		{
+			name:       "forbidden pattern",
+			body:       url.Values{"message": {"more spam"}}.Encode(),
+			statusCode: http.StatusUnprocessableEntity,
+			contains:   []string{"Message contains forbidden content", ">more spam</textarea>"},
+		},
+		{
+			name:       "banned word",
+			body:       url.Values{"message": {"darn"}}.Encode(),
+			statusCode: http.StatusUnprocessableEntity,
+			contains:   []string{"Message violates the banned words policy"},
+		},
+		{
+			name:       "bidi override",
+			body:       url.Values{"message": {"a\u202eb"}}.Encode(),
+			statusCode: http.StatusBadRequest,
+			contains:   []string{"Message cannot contain bidirectional control characters"},
+		},
+		{
+			name:        "JSON is rejected",
+			contentType: echo.MIMEApplicationJSON,
+			body:        `{"message": "hi"}`,
+			statusCode:  http.StatusUnsupportedMediaType,
+			contains:    []string{"application/x-www-form-urlencoded"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = echo.MIMEApplicationForm
+			}
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/ui/message", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, contentType)
+			rec := httptest.NewRecorder()
+			require.NoError(t, handlers.UIMessage(e.NewContext(req, rec)))
+
+			assert.Equal(t, tt.statusCode, rec.Code)
+			for _, s := range tt.contains {
+				assert.Contains(t, rec.Body.String(), s)
+			}
+			assert.Equal(t, "Stored", handlers.store.GetMessage(context.Background()))
+		})
+	}
+
+	t.Run("limit is rendered for the counter", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		require.NoError(t, handlers.UI(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/ui", nil), rec)))
+
+		body := rec.Body.String()
+		assert.Contains(t, body, `<p class="counter small muted" data-max-length="10">Up to 10 characters</p>`)
+		assert.NotContains(t, body, "field-error")
+	})
+
+	t.Run("success redirects with a flash message", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/ui/message", strings.NewReader(url.Values{"message": {"Updated"}}.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handlers.UIMessage(e.NewContext(req, rec)))
+
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+		assert.Equal(t, "Updated", handlers.store.GetMessage(context.Background()))
+
+		cookies := rec.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, flashCookie, cookies[0].Name)
+
+		// The flash is shown once and then cleared.
+		req = httptest.NewRequest(http.MethodGet, "/ui", nil)
+		req.AddCookie(cookies[0])
+		rec = httptest.NewRecorder()
+		require.NoError(t, handlers.UI(e.NewContext(req, rec)))
+
+		assert.Contains(t, rec.Body.String(), "Message changed from “Stored” to “Updated”")
+		cleared := rec.Result().Cookies()
+		require.Len(t, cleared, 1)
+		assert.Equal(t, -1, cleared[0].MaxAge)
+	})
+}
+
+func TestUIKeyedMessages(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+	require.NoError(t, handlers.store.SetKey(context.Background(), "motd", "Welcome", storage.UpdatedByAPI))
+
+	post := func(values url.Values) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/ui/message", strings.NewReader(values.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handlers.UIMessage(e.NewContext(req, rec)))
+		return rec
+	}
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	require.NoError(t, handlers.UI(e.NewContext(httptest.NewRequest(http.MethodGet, "/ui", nil), rec)))
+	assert.Contains(t, rec.Body.String(), `<input type="hidden" name="key" value="motd">`)
+	assert.Contains(t, rec.Body.String(), `>Welcome</textarea>`)
+
+	rec = post(url.Values{"key": {"motd"}, "message": {"Updated"}})
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	data, err := handlers.store.GetKey(context.Background(), "motd")
+	require.NoError(t, err)
+	assert.Equal(t, "Updated", data.Message)
+	assert.Equal(t, storage.UpdatedByUI, data.UpdatedBy)
+	assert.Equal(t, "Hello, World!", handlers.store.GetMessage(context.Background()))
+
+	// A form rendered before the last change warns instead of clobbering it.
+	rec = post(url.Values{"key": {"motd"}, "message": {"Mine"}, "revision": {"1"}})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Someone else changed this message")
+	assert.Contains(t, rec.Body.String(), `>Mine</textarea>`)
+	assert.Contains(t, rec.Body.String(), `<input type="hidden" name="revision" value="2">`)
+	data, err = handlers.store.GetKey(context.Background(), "motd")
+	require.NoError(t, err)
+	assert.Equal(t, "Updated", data.Message)
+
+	rec = post(url.Values{"key": {"motd"}, "message": {"Mine"}, "revision": {"2"}})
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+
+	// A rejected new key keeps what was entered in the new message form.
+	rec = post(url.Values{"key": {"Bad Key"}, "message": {"Draft text"}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `value="Bad Key"`)
+	assert.Contains(t, rec.Body.String(), `>Draft text</textarea>`)
+}
+
+func TestLogsFromBuffer(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "app.log"), []byte("line from file\n"), 0644))
+
+	get := func(handler echo.HandlerFunc, target string) *httptest.ResponseRecorder {
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler(e.NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec)))
+		return rec
+	}
+
+	// An empty buffer falls back to app.log.
+	assert.Contains(t, get(handlers.Logs, "/logs").Body.String(), "line from file")
+
+	handlers.logger.WithField("user", "ada").Info("buffered entry")
+
+	body := get(handlers.Logs, "/logs").Body.String()
+	assert.Contains(t, body, "[INFO] buffered entry user=ada")
+	assert.NotContains(t, body, "line from file")
+
+	rec := get(handlers.LogEntries, "/api/v1/logs?limit=1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response LogsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Entries, 1)
+	assert.Equal(t, "buffered entry", response.Entries[0].Message)
+	assert.Equal(t, "info", response.Entries[0].Level)
+	assert.Equal(t, "ada", response.Entries[0].Fields["user"])
+
+	assert.Equal(t, http.StatusBadRequest, get(handlers.LogEntries, "/api/v1/logs?limit=zero").Code)
+}
+
+func TestCloseRemovesLogHook(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := storage.NewMessageStore(tmpDir)
+	require.NoError(t, store.Load(context.Background()))
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	other := logging.NewRingBuffer(1)
+	logger.AddHook(other)
+
+	cfg := config.DefaultConfig()
+	cfg.DataPath = tmpDir
+	// A server restarted in the same process must not leave its buffer
+	// behind on the shared logger.
+	for range 2 {
+		handlers, err := NewHandlers(cfg, store, logger)
+		require.NoError(t, err)
+		assert.Len(t, logger.Hooks[logrus.InfoLevel], 2)
+		handlers.Close()
+		assert.Equal(t, []logrus.Hook{other}, logger.Hooks[logrus.InfoLevel])
+	}
+}
+
+func TestLogsFiltering(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+	handlers.logger.SetLevel(logrus.DebugLevel)
+
+	get := func(handler echo.HandlerFunc, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler(echo.New().NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec)))
+		return rec
+	}
+
+	for i := 1; i <= 5; i++ {
+		handlers.logger.Debugf("debug %d", i)
+	}
+	handlers.logger.WithField("user", "ada").Warn("disk almost full")
+	handlers.logger.Error("Request failed")
+
+	body := get(handlers.Logs, "/logs?level=warn").Body.String()
+	assert.Contains(t, body, `<div class="log-line log-warning">`)
+	assert.Contains(t, body, `<div class="log-line log-error">`)
+	assert.NotContains(t, body, "debug 1")
+	assert.Contains(t, body, `<option value="warning" selected>`)
+
+	body = get(handlers.Logs, "/logs?q=ADA").Body.String()
+	assert.Contains(t, body, "disk almost full user=ada")
+	assert.NotContains(t, body, "Request failed")
+
+	body = get(handlers.Logs, "/logs?q=nothing+like+this").Body.String()
+	assert.Contains(t, body, "No matching log entries")
+
+	// Page 1 holds the newest entries; older ones are on the next pages.
+	body = get(handlers.Logs, "/logs?level=debug&q=debug&lines=2").Body.String()
+	assert.Contains(t, body, "debug 4")
+	assert.Contains(t, body, "debug 5")
+	assert.NotContains(t, body, "debug 3")
+	assert.Contains(t, body, `href="/logs?level=debug&amp;lines=2&amp;page=2&amp;q=debug&amp;source=app"`)
+	assert.NotContains(t, body, "Newer")
+
+	body = get(handlers.Logs, "/logs?level=debug&q=debug&lines=2&page=3").Body.String()
+	assert.Contains(t, body, "debug 1")
+	assert.NotContains(t, body, "debug 2")
+	assert.Contains(t, body, "Newer")
+	assert.NotContains(t, body, "Older")
+	assert.NotContains(t, body, `id="refresh"`, "auto-refresh is only offered on the newest page")
+
+	for _, query := range []string{"level=loud", "lines=0", "lines=501", "page=first"} {
+		assert.Equal(t, http.StatusBadRequest, get(handlers.Logs, "/logs?"+query).Code, query)
+	}
+
+	rec := get(handlers.LogEntries, "/api/v1/logs?level=error")
+	var response LogsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Entries, 1)
+	assert.Equal(t, "Request failed", response.Entries[0].Message)
+
+	rec = get(handlers.LogEntries, "/api/v1/logs?q=debug&limit=2")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Entries, 2)
+	assert.Equal(t, "debug 5", response.Entries[1].Message)
+
+	assert.Equal(t, http.StatusBadRequest, get(handlers.LogEntries, "/api/v1/logs?level=loud").Code)
+}
+
+func TestLogsFilteringFile(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	lines := strings.Join([]string{
+		`{"level":"info","msg":"json started","time":"2024-01-02T12:00:00Z","port":8080}`,
+		`{"level":"error","msg":"json failed","time":"2024-01-02T12:00:01Z"}`,
+		`plain text line`,
+	}, "\n")
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "app.log"), []byte(lines+"\n"), 0644))
+
+	get := func(target string) string {
+		rec := httptest.NewRecorder()
+		require.NoError(t, handlers.Logs(echo.New().NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec)))
+		return rec.Body.String()
+	}
+
+	// JSON lines are filtered by level; plain text lines only by query.
+	body := get("/logs?level=error")
+	assert.Contains(t, body, "[ERROR] json failed")
+	assert.NotContains(t, body, "json started")
+	assert.Contains(t, body, `<div class="log-line">plain text line</div>`)
+
+	body = get("/logs?q=PORT=8080")
+	assert.Contains(t, body, "[INFO] json started port=8080")
+	assert.NotContains(t, body, "plain text line")
+}
+
+func TestHelloInvalidOptions(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	for _, query := range []string{"repeat=0", "repeat=11", "repeat=many", "shout=loud", "time_aware=yes", "at=7pm"} {
+		t.Run(query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/hello?"+query, nil), rec)
+
+			require.NoError(t, handlers.Hello(c))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
+
+func TestLogsStdoutOutput(t *testing.T) {
+	handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+		cfg.Logging.Output = logging.OutputStdout
+	})
+	defer os.RemoveAll(tmpDir)
+
+	// A stale app.log from an earlier run is not read in stdout mode.
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "app.log"), []byte("line from file\n"), 0644))
+
+	get := func() string {
+		rec := httptest.NewRecorder()
+		require.NoError(t, handlers.Logs(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/logs", nil), rec)))
+		return rec.Body.String()
+	}
+
+	body := get()
+	assert.NotContains(t, body, "line from file")
+	assert.Contains(t, body, "written to stdout only")
+
+	handlers.logger.Info("buffered entry")
+	assert.Contains(t, get(), "[INFO] buffered entry")
+}
+
+func TestReadOnlyStorage(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+	require.NoError(t, os.Chmod(tmpDir, 0500))
+	defer os.Chmod(tmpDir, 0700)
+	require.NoError(t, handlers.store.Load(context.Background()))
+
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodPost, "/message", strings.NewReader(`{"message": "changed"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	require.NoError(t, handlers.SetMessage(e.NewContext(req, rec)))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, mimeProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	assert.Contains(t, rec.Body.String(), "Storage is read-only")
+	assert.Equal(t, "Hello, World!", handlers.store.GetMessage(context.Background()))
+
+	req = httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	rec = httptest.NewRecorder()
+	require.NoError(t, handlers.Ready(e.NewContext(req, rec)))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var ready ReadyResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ready))
+	assert.Equal(t, "ready", ready.Status)
+	assert.Equal(t, "read-only", ready.Checks["storage"])
+}
+
+func TestReadyHandler(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, handlers.Ready(e.NewContext(req, rec)))
+
+	var ready ReadyResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ready))
+	assert.Equal(t, "ready", ready.Status)
+	assert.Equal(t, "ok", ready.Checks["storage"])
+}
+
+func TestDocsCaching(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	specDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(specDir, "api"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(specDir, "api", "openapi.yaml"), []byte("openapi: 3.1.0\ninfo:\n  title: Greetd API\n"), 0644))
+	t.Chdir(specDir)
+
+	serve := func(handler echo.HandlerFunc, path, ifNoneMatch string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		if rest, ok := strings.CutPrefix(path, "/static/"); ok {
+			c.SetParamNames("*")
+			c.SetParamValues(rest)
+		}
+		require.NoError(t, handler(c))
+		return rec
+	}
+
+	t.Run("spec", func(t *testing.T) {
+		rec := serve(handlers.SwaggerSpec, "/swagger/openapi.yaml", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+		etag := rec.Header().Get("ETag")
+		assert.Regexp(t, `^"[0-9a-f]{12}"$`, etag)
+
+		rec = serve(handlers.SwaggerSpec, "/swagger/openapi.yaml", etag)
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Empty(t, rec.Body.String())
+		assert.Equal(t, etag, rec.Header().Get("ETag"))
+
+		rec = serve(handlers.SwaggerSpec, "/swagger/openapi.yaml", `"other", W/`+etag)
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+
+		rec = serve(handlers.SwaggerSpec, "/swagger/openapi.yaml", `"other"`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotEmpty(t, rec.Body.String())
+	})
+
+	t.Run("static asset", func(t *testing.T) {
+		asset, _, ok := web.LookupAsset("swagger-ui/swagger-ui.css")
+		require.True(t, ok)
+
+		rec := serve(handlers.Static, "/static/"+asset.HashedName(), `"`+asset.Hash+`"`)
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Equal(t, "public, max-age=31536000, immutable", rec.Header().Get("Cache-Control"))
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("html entry points", func(t *testing.T) {
+		assert.Equal(t, "no-cache", serve(handlers.SwaggerUI, "/swagger/", "").Header().Get("Cache-Control"))
+		assert.Equal(t, "no-cache", serve(handlers.RedocDocs, "/docs", "").Header().Get("Cache-Control"))
+	})
+}
+
+func TestHelloStatsEndpoints(t *testing.T) {
+	server, _ := setupAdminServer(t, []string{"secret"}, false)
+
+	serve := func(method, target, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, name := range []string{"Ann", "ann", "Bob", ""} {
+		require.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/v1/hello?name="+name, "").Code)
+	}
+	// HEAD is not a greeting, even when the cache answers it.
+	for range 2 {
+		require.Equal(t, http.StatusOK, serve(http.MethodHead, "/api/v1/hello?name=Ann", "").Code)
+	}
+
+	rec := serve(http.MethodGet, "/api/v1/hello/stats?top=1", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"total": 4, "names": [{"name": "ann", "count": 2}]}`, rec.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/api/v1/hello/stats?top=0", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodDelete, "/api/v1/hello/stats", "").Code)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/api/v1/hello/stats", "secret").Code)
+
+	rec = serve(http.MethodGet, "/api/v1/hello/stats", "")
+	assert.JSONEq(t, `{"total": 0, "names": []}`, rec.Body.String())
+}
+
+func TestHelloStatsPersistedOnClose(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/hello?name=Ann", nil)
+	require.NoError(t, handlers.Hello(e.NewContext(req, httptest.NewRecorder())))
+	assert.NoFileExists(t, filepath.Join(tmpDir, storage.StatsFileName))
+
+	handlers.Close()
+
+	stats := storage.NewHelloStats(tmpDir)
+	require.NoError(t, stats.Load())
+	names, total := stats.Top(10)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, []storage.NameCount{{Name: "ann", Count: 1}}, names)
+}
+
+// setupBenchHandlers returns handlers that log nothing, so the benchmarks
+// measure the handlers alone.
+func setupBenchHandlers(b *testing.B, configure func(*config.Config)) *Handlers {
+	handlers, tmpDir := setupTestHandlersWithConfig(b, configure)
+	b.Cleanup(func() { os.RemoveAll(tmpDir) })
+	handlers.logger.SetOutput(io.Discard)
+	return handlers
+}
+
+// BenchmarkHello compares rendering every greeting from a greeting
+// template with answering from the /hello cache.
+func BenchmarkHello(b *testing.B) {
+	for _, ttl := range []string{"0", "1m"} {
+		b.Run("cache_ttl="+ttl, func(b *testing.B) {
+			handlers := setupBenchHandlers(b, func(cfg *config.Config) {
+				cfg.Greetings.Template = "Welcome to {{.Service}} {{.Version}}, {{.Name}}!"
+				cfg.Greetings.CacheTTL = ttl
+			})
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/hello?name=Alice&repeat=3&shout=true", nil)
+
+			b.ReportAllocs()
+			for b.Loop() {
+				rec := httptest.NewRecorder()
+				if err := handlers.Hello(e.NewContext(req, rec)); err != nil || rec.Code != http.StatusOK {
+					b.Fatalf("Hello: %d %v", rec.Code, err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetMessage(b *testing.B) {
+	handlers := setupBenchHandlers(b, nil)
+	e := echo.New()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/message", nil)
+		rec := httptest.NewRecorder()
+		if err := handlers.GetMessage(e.NewContext(req, rec)); err != nil || rec.Code != http.StatusOK {
+			b.Fatalf("GetMessage: %d %v", rec.Code, err)
+		}
+	}
+}
+
+// BenchmarkSetMessage includes saving the message to disk.
+func BenchmarkSetMessage(b *testing.B) {
+	handlers := setupBenchHandlers(b, nil)
+	e := echo.New()
+
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		i++
+		body := `{"message": "Benchmark message ` + strconv.Itoa(i) + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/message", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		if err := handlers.SetMessage(e.NewContext(req, rec)); err != nil || rec.Code != http.StatusOK {
+			b.Fatalf("SetMessage: %d %v", rec.Code, err)
+		}
+	}
+}
+
+func TestUIHistory(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+	ctx := context.Background()
+
+	ui := func() string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		require.NoError(t, handlers.UI(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/ui", nil), rec)))
+		return rec.Body.String()
+	}
+
+	assert.NotContains(t, ui(), `class="history"`)
+
+	require.NoError(t, handlers.store.SetMessage(ctx, "Hi <there>", storage.UpdatedByAPI))
+	body := ui()
+	assert.Contains(t, body, `class="history"`)
+	assert.Contains(t, body, "Revision 1, compared with revision 0")
+	assert.Contains(t, body, `<span class="diff-line diff-delete">Hello, World!</span>`)
+	assert.Contains(t, body, `<span class="diff-line diff-insert">Hi &lt;there&gt;</span>`)
+
+	// After an undo, a revision is compared with the one numbered before
+	// it, which the label names.
+	require.NoError(t, handlers.store.SetMessage(ctx, "Bye", storage.UpdatedByAPI))
+	_, err := handlers.store.UndoKey(ctx, storage.DefaultKey, storage.UpdatedByAPI)
+	require.NoError(t, err)
+	body = ui()
+	assert.Contains(t, body, "Revision 3, compared with revision 2")
+	assert.Contains(t, body, "Revision 2, compared with revision 0")
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// get registers a GET route on g that answers HEAD requests too. HeadResponse
+// turns the GET response into the HEAD one.
+func get(g *echo.Group, path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) {
+	g.Match([]string{http.MethodGet, http.MethodHead}, path, h, m...)
+}
+
+// HeadResponse answers HEAD requests with the headers the handler sets for
+// GET, including the Content-Length of the body it writes, and no body.
+// Errors are rendered by the error handler before the middleware returns,
+// so their Content-Length is set as well. Nothing is sent for other methods
+// than HEAD.
+func HeadResponse(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if c.Request().Method != http.MethodHead {
+			return next(c)
+		}
+
+		res := c.Response()
+		w := &headWriter{ResponseWriter: res.Writer}
+		res.Writer = w
+		defer func() { res.Writer = w.ResponseWriter }()
+
+		if err := next(c); err != nil {
+			c.Error(err)
+		}
+		w.send()
+		// The body was counted, not sent.
+		res.Size = 0
+		return nil
+	}
+}
+
+// headWriter holds back the status of a HEAD response and counts the bytes
+// of the body instead of writing them, so the Content-Length can be set
+// once the handler is done.
+type headWriter struct {
+	http.ResponseWriter
+	status int
+	length int
+}
+
+func (w *headWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.length += len(p)
+	return len(p), nil
+}
+
+// send writes the held back status with the Content-Length of the body,
+// unless the handler set one or the status has no body.
+func (w *headWriter) send() {
+	if w.status == 0 {
+		return
+	}
+	header := w.ResponseWriter.Header()
+	if header.Get(echo.HeaderContentLength) == "" && w.status >= http.StatusOK && w.status != http.StatusNoContent && w.status != http.StatusNotModified {
+		header.Set(echo.HeaderContentLength, strconv.Itoa(w.length))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *headWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// setLastModified sets the Last-Modified header to t, unless t is zero.
+func setLastModified(c echo.Context, t time.Time) {
+	if !t.IsZero() {
+		c.Response().Header().Set(echo.HeaderLastModified, t.UTC().Format(http.TimeFormat))
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+func TestHead(t *testing.T) {
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		data, err := json.Marshal(storage.MessagesFile{Messages: map[string]storage.MessageData{
+			storage.DefaultKey: {Message: "Hello", UpdatedAt: updated, UpdatedBy: storage.UpdatedByCLI, Revision: 1},
+		}})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(cfg.DataPath, storage.MessagesFileName), data, 0o600))
+	})
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	get := serve(http.MethodGet, "/api/v1/message")
+	require.Equal(t, http.StatusOK, get.Code)
+	head := serve(http.MethodHead, "/api/v1/message")
+	require.Equal(t, http.StatusOK, head.Code)
+	assert.Empty(t, head.Body.String())
+	assert.Equal(t, strconv.Itoa(get.Body.Len()), head.Header().Get(echo.HeaderContentLength))
+	for _, name := range []string{echo.HeaderContentType, "ETag", echo.HeaderLastModified} {
+		assert.NotEmpty(t, head.Header().Get(name), name)
+		assert.Equal(t, get.Header().Get(name), head.Header().Get(name), name)
+	}
+	assert.Equal(t, updated.Format(http.TimeFormat), head.Header().Get(echo.HeaderLastModified))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/message", strings.NewReader(`{"message":"Hi"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	after := serve(http.MethodHead, "/api/v1/message")
+	require.Equal(t, http.StatusOK, after.Code)
+	modified, err := http.ParseTime(after.Header().Get(echo.HeaderLastModified))
+	require.NoError(t, err)
+	assert.True(t, modified.After(updated), "Last-Modified moves with the update")
+	assert.NotEqual(t, head.Header().Get("ETag"), after.Header().Get("ETag"))
+
+	// Other routes answer HEAD, errors included, without a body.
+	for target, status := range map[string]int{
+		"/health":                  http.StatusOK,
+		"/message":                 http.StatusOK,
+		"/ui":                      http.StatusOK,
+		"/api/v1/messages/missing": http.StatusNotFound,
+	} {
+		rec := serve(http.MethodHead, target)
+		assert.Equal(t, status, rec.Code, target)
+		assert.Empty(t, rec.Body.String(), target)
+		assert.NotEmpty(t, rec.Header().Get(echo.HeaderContentLength), target)
+	}
+
+	// The request stats keep HEAD apart from GET.
+	report := server.handlers.requestStats.Report()
+	methods := map[string]uint64{}
+	for _, route := range report.Routes {
+		if route.Route == "/api/v1/message" {
+			methods[route.Method] = route.Count
+		}
+	}
+	assert.Equal(t, map[string]uint64{http.MethodGet: 1, http.MethodHead: 2, http.MethodPost: 1}, methods)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/svanhalla/prompt-lab/greetd/internal/update"
+)
+
+// HealthDetails describes the server configuration and runtime. Fields that
+// could not be determined on this platform are omitted.
+type HealthDetails struct {
+	PprofEnabled bool `json:"pprof_enabled"`
+	Goroutines   int  `json:"goroutines"`
+	// HeapInUse is in bytes.
+	HeapInUse uint64 `json:"heap_inuse"`
+	// GCPauseTotal is the total stop-the-world pause time in nanoseconds.
+	GCPauseTotal time.Duration `json:"gc_pause_total"`
+	GCCount      uint32        `json:"gc_count"`
+	OpenFiles    *int          `json:"open_files,omitempty"`
+	// DiskFree is the free space, in bytes, on the state directory's
+	// filesystem.
+	DiskFree       *uint64    `json:"disk_free,omitempty"`
+	Message        string     `json:"message"`
+	MessageUpdated *time.Time `json:"message_updated,omitempty"`
+	// Requests counts responses by status class, e.g. "2xx".
+	Requests map[string]uint64 `json:"requests"`
+	// ErrorRate is the fraction of responses that were 5xx, from 0 to 1.
+	ErrorRate float64       `json:"error_rate"`
+	LogLevel  string        `json:"log_level"`
+	Ready     ReadyResponse `json:"ready"`
+	// Update is the result of the latest update check, when update.check
+	// is on.
+	Update *update.Result `json:"update,omitempty"`
+}
+
+// diskFree returns the free space on the state directory's filesystem, or
+// false when it cannot be determined.
+func (h *Handlers) diskFree() (uint64, bool) {
+	free, err := h.freeSpace(h.statePath)
+	if err != nil {
+		h.logger.WithError(err).Debug("Failed to determine free disk space")
+		return 0, false
+	}
+	return free, true
+}
+
+// healthDetails gathers the verbose /health output. free is the result of
+// diskFree, which Health has already called.
+func (h *Handlers) healthDetails(ctx context.Context, free uint64, freeKnown bool) *HealthDetails {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	details := &HealthDetails{
+		PprofEnabled: h.pprofEnabled,
+		Goroutines:   runtime.NumGoroutine(),
+		HeapInUse:    mem.HeapInuse,
+		GCPauseTotal: time.Duration(mem.PauseTotalNs),
+		GCCount:      mem.NumGC,
+	}
+	if n, ok := openFiles(); ok {
+		details.OpenFiles = &n
+	}
+	if freeKnown {
+		details.DiskFree = &free
+	}
+	message := h.store.Get(ctx)
+	details.Message = message.Message
+	if !message.UpdatedAt.IsZero() {
+		details.MessageUpdated = &message.UpdatedAt
+	}
+
+	details.Requests = make(map[string]uint64)
+	var total uint64
+	for class := 1; class <= 5; class++ {
+		n := h.requests.Count(class)
+		details.Requests[fmt.Sprintf("%dxx", class)] = n
+		total += n
+	}
+	if total > 0 {
+		details.ErrorRate = float64(h.requests.Count(5)) / float64(total)
+	}
+
+	details.LogLevel = h.logger.GetLevel().String()
+	details.Ready = h.readiness(ctx)
+	details.Update = h.update.Load()
+	return details
+}
+
+// Status renders the status page, a human-readable view of the verbose
+// /health output that refreshes itself.
+func (h *Handlers) Status(c echo.Context) error {
+	return h.render(c, http.StatusOK, h.templates.GetStatus, h.health(c.Request().Context(), true))
+}
+
+// openFiles counts the process's open file descriptors where the platform
+// lists them under /proc/self/fd or /dev/fd.
+func openFiles() (int, bool) {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		// The directory being read holds a descriptor of its own.
+		if entries, err := os.ReadDir(dir); err == nil {
+			return len(entries) - 1, true
+		}
+	}
+	return 0, false
+}
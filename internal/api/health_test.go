@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+	"github.com/svanhalla/prompt-lab/greetd/internal/update"
+	"github.com/svanhalla/prompt-lab/greetd/internal/version"
+)
+
+// getHealth calls the Health handler with the given query string.
+func getHealth(t *testing.T, handlers *Handlers, query string) (HealthResponse, map[string]interface{}) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/health"+query, nil), rec)
+	require.NoError(t, handlers.Health(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var res HealthResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &raw))
+	return res, raw
+}
+
+func TestHealthVerbose(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+	handlers.freeSpace = func(string) (uint64, error) { return 10 << 30, nil }
+
+	res, raw := getHealth(t, handlers, "")
+	assert.Equal(t, "ok", res.Status)
+	assert.NotContains(t, raw, "details")
+
+	require.NoError(t, handlers.store.SetMessage(context.Background(), "Fresh", storage.UpdatedByAPI))
+
+	res, _ = getHealth(t, handlers, "?verbose=1")
+	assert.Equal(t, "ok", res.Status)
+	require.NotNil(t, res.Details)
+	assert.Positive(t, res.Details.Goroutines)
+	assert.Positive(t, res.Details.HeapInUse)
+	require.NotNil(t, res.Details.DiskFree)
+	assert.Equal(t, uint64(10<<30), *res.Details.DiskFree)
+	require.NotNil(t, res.Details.MessageUpdated)
+	assert.False(t, res.Details.MessageUpdated.IsZero())
+	assert.Equal(t, "Fresh", res.Details.Message)
+	assert.Equal(t, handlers.logger.GetLevel().String(), res.Details.LogLevel)
+	assert.Equal(t, "ready", res.Details.Ready.Status)
+	assert.Equal(t, "ok", res.Details.Ready.Checks["storage"])
+}
+
+func TestHealthStartedAt(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	first, raw := getHealth(t, handlers, "")
+	require.Contains(t, raw, "started_at")
+	startedAt, err := time.Parse(time.RFC3339, raw["started_at"].(string))
+	require.NoError(t, err)
+	assert.True(t, startedAt.Equal(version.StartTime()))
+
+	time.Sleep(10 * time.Millisecond)
+	second, _ := getHealth(t, handlers, "")
+	assert.True(t, second.StartedAt.Equal(first.StartedAt))
+	assert.Greater(t, second.Uptime, first.Uptime)
+
+	// Handlers created later report the same start.
+	other, otherDir := setupTestHandlers(t)
+	defer os.RemoveAll(otherDir)
+	third, _ := getHealth(t, other, "")
+	assert.True(t, third.StartedAt.Equal(first.StartedAt))
+	assert.Greater(t, third.Uptime, second.Uptime)
+
+	assert.Contains(t, handlers.metricsText(), fmt.Sprintf("greetd_start_time_seconds %d\n", version.StartTime().Unix()))
+	assert.Contains(t, handlers.metricsText(), "greetd_uptime_seconds ")
+}
+
+func TestHealthRequests(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	res, _ := getHealth(t, handlers, "?verbose=1")
+	assert.Equal(t, uint64(0), res.Details.Requests["2xx"])
+	assert.Zero(t, res.Details.ErrorRate)
+
+	for _, status := range []int{200, 200, 204, 404, 500} {
+		handlers.requests.Observe(status)
+	}
+
+	res, _ = getHealth(t, handlers, "?verbose=1")
+	assert.Equal(t, map[string]uint64{"1xx": 0, "2xx": 3, "3xx": 0, "4xx": 1, "5xx": 1}, res.Details.Requests)
+	assert.InDelta(t, 0.2, res.Details.ErrorRate, 1e-9)
+}
+
+func TestStatusPage(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	require.NoError(t, handlers.store.SetMessage(context.Background(), "Status check", storage.UpdatedByAPI))
+	handlers.requests.Observe(http.StatusOK)
+	_, err := handlers.maintenance.Set(true, "", "test")
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/status", nil), rec)
+	require.NoError(t, handlers.Status(c))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get(echo.HeaderContentType), "text/html")
+	body := rec.Body.String()
+	assert.Contains(t, body, "Status check")
+	assert.Contains(t, body, `id="requests-2xx">1<`)
+	assert.Contains(t, body, `id="error-rate">0.0%<`)
+	assert.Contains(t, body, `<span class="indicator indicator-ok">ok</span> storage`)
+	assert.Contains(t, body, `<span class="indicator indicator-fail">enabled</span> maintenance`)
+	assert.Contains(t, body, `\/api\/v1\/health?verbose=1`)
+	assert.NotContains(t, body, "unpkg.com")
+	assert.NotContains(t, body, `id="update"`)
+}
+
+func TestStatusPageUpdate(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	status := func() string {
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/status", nil), rec)
+		require.NoError(t, handlers.Status(c))
+		return rec.Body.String()
+	}
+
+	url := "https://github.com/svanhalla/prompt-lab/releases/tag/v1.3.0"
+	handlers.update.Store(&update.Result{Status: update.StatusUpToDate, Current: "v1.3.0", Latest: "v1.3.0", URL: url})
+	assert.NotContains(t, status(), `id="update"`)
+
+	handlers.update.Store(&update.Result{Status: update.StatusAvailable, Current: "v1.2.0", Latest: "v1.3.0", URL: url})
+	assert.Contains(t, status(), `<a id="update" class="indicator indicator-update" href="`+url+`">Update available: v1.3.0</a>`)
+
+	res, _ := getHealth(t, handlers, "?verbose=1")
+	require.NotNil(t, res.Details.Update)
+	assert.Equal(t, "v1.3.0", res.Details.Update.Latest)
+}
+
+func TestHealthDegraded(t *testing.T) {
+	handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+		cfg.Health.MinFreeSpace = "100MB"
+	})
+	defer os.RemoveAll(tmpDir)
+
+	var checked string
+	handlers.freeSpace = func(dir string) (uint64, error) {
+		checked = dir
+		return 50 << 20, nil
+	}
+
+	res, raw := getHealth(t, handlers, "")
+	assert.Equal(t, "degraded", res.Status)
+	assert.NotContains(t, raw, "details")
+	assert.Equal(t, tmpDir, checked)
+
+	res, _ = getHealth(t, handlers, "?verbose=true")
+	assert.Equal(t, "degraded", res.Status)
+	require.NotNil(t, res.Details.DiskFree)
+	assert.Equal(t, uint64(50<<20), *res.Details.DiskFree)
+
+	// Free space that cannot be determined is not treated as low.
+	handlers.freeSpace = func(string) (uint64, error) { return 0, errors.ErrUnsupported }
+	res, _ = getHealth(t, handlers, "?verbose=1")
+	assert.Equal(t, "ok", res.Status)
+	assert.Nil(t, res.Details.DiskFree)
+
+	// A threshold of 0 disables the check.
+	handlers.freeSpace = func(string) (uint64, error) { return 0, nil }
+	handlers.minFreeSpace = 0
+	res, _ = getHealth(t, handlers, "")
+	assert.Equal(t, "ok", res.Status)
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/svanhalla/prompt-lab/greetd/internal/greeting"
+)
+
+// helloCacheKey identifies a /hello response: everything the greeting and
+// its encoding depend on besides the greeting template.
+type helloCacheKey struct {
+	name   string
+	lang   string
+	format string
+	opts   greeting.Options
+	// period is the time of day of a time-aware greeting for now.
+	period string
+}
+
+type helloCacheEntry struct {
+	key         helloCacheKey
+	contentType string
+	body        []byte
+	expires     time.Time
+}
+
+// helloCache keeps encoded /hello responses for greetings.cache_ttl, at most
+// greetings.cache_size of them. The entries belong to the stored message
+// template they were rendered with, or nil for the configured greeting, and
+// are dropped when it changes. The configured greeting itself only changes
+// with a restart.
+type helloCache struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	size int
+	now  func() time.Time
+	// revalidate makes clients check back on every request, as the stored
+	// message can change the greeting at any time.
+	revalidate bool
+	// source is the message template the entries were rendered with.
+	source *template.Template
+	// order holds the *helloCacheEntry values, most recently used first;
+	// entries indexes it by key.
+	order   *list.List
+	entries map[helloCacheKey]*list.Element
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func newHelloCache(ttl time.Duration, size int) *helloCache {
+	return &helloCache{
+		ttl:     ttl,
+		size:    size,
+		now:     time.Now,
+		order:   list.New(),
+		entries: map[helloCacheKey]*list.Element{},
+	}
+}
+
+// get returns the response cached for key and source, or nil.
+func (c *helloCache) get(source *template.Template, key helloCacheKey) *helloCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setSource(source)
+
+	elem, ok := c.entries[key]
+	if ok && c.now().Before(elem.Value.(*helloCacheEntry).expires) {
+		c.order.MoveToFront(elem)
+		c.hits.Add(1)
+		return elem.Value.(*helloCacheEntry)
+	}
+	if ok {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+	c.misses.Add(1)
+	return nil
+}
+
+// put caches a response rendered for key with source.
+func (c *helloCache) put(source *template.Template, key helloCacheKey, contentType string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setSource(source)
+
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+	}
+	c.entries[key] = c.order.PushFront(&helloCacheEntry{
+		key:         key,
+		contentType: contentType,
+		body:        body,
+		expires:     c.now().Add(c.ttl),
+	})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*helloCacheEntry).key)
+	}
+}
+
+// setSource drops the entries when source is not the template they were
+// rendered with. c.mu must be held.
+func (c *helloCache) setSource(source *template.Template) {
+	if source == c.source {
+		return
+	}
+	c.source = source
+	c.order.Init()
+	clear(c.entries)
+}
+
+// len returns the number of cached responses, including expired ones that
+// have not been looked up since.
+func (c *helloCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// setHeaders lets clients and shared caches keep a response for the TTL,
+// or only with revalidation when the greeting follows the stored message.
+func (c *helloCache) setHeaders(header http.Header) {
+	if c.revalidate {
+		header.Set(echo.HeaderCacheControl, "no-cache")
+	} else {
+		header.Set(echo.HeaderCacheControl, "public, max-age="+strconv.Itoa(int(c.ttl/time.Second)))
+	}
+	header.Add(echo.HeaderVary, "Accept-Language")
+}
+
+// helloCacheKeyFor returns the cache key of a /hello request, and false when
+// its response must not be cached: when the cache is off, with ?pretty, or
+// when the stored message template renders the current time.
+func (h *Handlers) helloCacheKeyFor(c echo.Context, name, lang string, opts greeting.Options, usesTime bool) (helloCacheKey, bool) {
+	if h.helloCache == nil || (usesTime && opts.At.IsZero()) {
+		return helloCacheKey{}, false
+	}
+	if _, pretty := c.QueryParams()["pretty"]; pretty {
+		return helloCacheKey{}, false
+	}
+
+	key := helloCacheKey{name: name, lang: lang, format: responseFormat(c), opts: opts}
+	if opts.TimeAware && opts.At.IsZero() {
+		key.period = greeting.Period(time.Now())
+	}
+	return key, true
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+// getHello requests target from server with the given Accept header.
+func getHello(t *testing.T, server *Server, target, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	return rec
+}
+
+func TestHelloCache(t *testing.T) {
+	server, _ := setupServer(t, nil)
+	cache := server.handlers.helloCache
+	require.NotNil(t, cache)
+
+	first := getHello(t, server, "/api/v1/hello?name=Ann", "")
+	assert.Equal(t, "public, max-age=60", first.Header().Get("Cache-Control"))
+	assert.Subset(t, first.Header().Values("Vary"), []string{"Accept-Language", "Accept"})
+	assert.Equal(t, uint64(0), cache.hits.Load())
+	assert.Equal(t, uint64(1), cache.misses.Load())
+
+	second := getHello(t, server, "/api/v1/hello?name=Ann", "")
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, first.Header().Get("Content-Type"), second.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=60", second.Header().Get("Cache-Control"))
+	assert.Equal(t, first.Header().Values("Vary"), second.Header().Values("Vary"))
+	assert.Equal(t, uint64(1), cache.hits.Load())
+
+	// Other representations and languages are cached separately.
+	text := getHello(t, server, "/api/v1/hello?name=Ann", "text/plain")
+	assert.Equal(t, "Hello, Ann!", text.Body.String())
+	assert.Contains(t, getHello(t, server, "/api/v1/hello?name=Ann&lang=sv", "").Body.String(), "Hej")
+	assert.Equal(t, uint64(1), cache.hits.Load())
+	assert.Equal(t, 3, cache.len())
+
+	// nocache renders the greeting without touching the cache.
+	bypass := getHello(t, server, "/api/v1/hello?name=Ann&nocache=1", "")
+	assert.Equal(t, first.Body.String(), bypass.Body.String())
+	assert.Equal(t, "no-store", bypass.Header().Get("Cache-Control"))
+	assert.Equal(t, uint64(1), cache.hits.Load())
+	assert.Equal(t, uint64(3), cache.misses.Load())
+
+	// Cached greetings are still counted.
+	names, total := server.handlers.stats.Top(1)
+	assert.Equal(t, int64(5), total)
+	assert.Equal(t, int64(5), names[0].Count)
+
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "greetd_hello_cache_entries 3\n")
+	assert.Contains(t, rec.Body.String(), "greetd_hello_cache_hits_total 1\n")
+	assert.Contains(t, rec.Body.String(), "greetd_hello_cache_misses_total 3\n")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/hello?nocache=maybe", nil)
+	rec = httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHelloCacheDisabled(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Greetings.CacheTTL = "0"
+	})
+	require.Nil(t, server.handlers.helloCache)
+
+	rec := getHello(t, server, "/api/v1/hello?name=Ann", "")
+	assert.Empty(t, rec.Header().Get("Cache-Control"))
+	assert.Contains(t, rec.Body.String(), "Hello, Ann!")
+
+	rec = httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "greetd_hello_cache_hits_total 0\n")
+}
+
+func TestHelloCacheTemplateChange(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Greetings.UseStoredTemplate = true
+	})
+	store := server.handlers.store
+	ctx := context.Background()
+
+	require.NoError(t, store.SetMessage(ctx, "Welcome back, {{.Name}}", storage.UpdatedByCLI))
+	assert.Contains(t, getHello(t, server, "/api/v1/hello?name=Ann", "").Body.String(), "Welcome back, Ann")
+	rec := getHello(t, server, "/api/v1/hello?name=Ann", "")
+	assert.Contains(t, rec.Body.String(), "Welcome back, Ann")
+	assert.Equal(t, uint64(1), server.handlers.helloCache.hits.Load())
+	// Clients must not keep a greeting the next stored message changes.
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+
+	require.NoError(t, store.SetMessage(ctx, "Good to see you, {{.Name}}", storage.UpdatedByCLI))
+	assert.Contains(t, getHello(t, server, "/api/v1/hello?name=Ann", "").Body.String(), "Good to see you, Ann")
+
+	// An invalid template falls back to the usual greeting, which is not
+	// served from the entries of the template before.
+	require.NoError(t, store.SetMessage(ctx, "Hi {{.Nope}}", storage.UpdatedByCLI))
+	assert.Contains(t, getHello(t, server, "/api/v1/hello?name=Ann", "").Body.String(), "Hello, Ann!")
+	assert.Equal(t, uint64(1), server.handlers.helloCache.hits.Load())
+}
+
+func TestHelloCacheTimedTemplate(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Greetings.UseStoredTemplate = true
+	})
+	require.NoError(t, server.handlers.store.SetMessage(context.Background(), `It is {{.Time.Format "15:04"}}, {{.Name}}`, storage.UpdatedByCLI))
+
+	// Rendered for now, the message is not cached.
+	for range 2 {
+		rec := getHello(t, server, "/api/v1/hello?name=Ann", "")
+		assert.Empty(t, rec.Header().Get("Cache-Control"))
+	}
+	assert.Equal(t, 0, server.handlers.helloCache.len())
+
+	// Rendered for a given time, it is.
+	rec := getHello(t, server, "/api/v1/hello?name=Ann&at=08:30", "")
+	assert.Contains(t, rec.Body.String(), "It is 08:30, Ann")
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, 1, server.handlers.helloCache.len())
+}
+
+func TestHelloCacheExpiryAndEviction(t *testing.T) {
+	cache := newHelloCache(time.Minute, 2)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	keys := []helloCacheKey{{name: "a"}, {name: "b"}, {name: "c"}}
+	cache.put(nil, keys[0], "text/plain", []byte("a"))
+	cache.put(nil, keys[1], "text/plain", []byte("b"))
+	require.NotNil(t, cache.get(nil, keys[0]))
+
+	// b is the least recently used and makes room for c.
+	cache.put(nil, keys[2], "text/plain", []byte("c"))
+	assert.Equal(t, 2, cache.len())
+	assert.Nil(t, cache.get(nil, keys[1]))
+	assert.Equal(t, "a", string(cache.get(nil, keys[0]).body))
+
+	now = now.Add(time.Minute)
+	assert.Nil(t, cache.get(nil, keys[0]))
+	assert.Equal(t, 1, cache.len())
+	assert.Equal(t, uint64(2), cache.hits.Load())
+	assert.Equal(t, uint64(2), cache.misses.Load())
+}
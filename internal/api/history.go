@@ -0,0 +1,63 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+// MessageHistoryResponse lists the revisions of a message that are still
+// known, newest, and so current, first.
+type MessageHistoryResponse struct {
+	Key       string            `json:"key" yaml:"key"`
+	Revisions []MessageResponse `json:"revisions" yaml:"revisions"`
+}
+
+// MessageHistory lists the revisions of the default message.
+func (h *Handlers) MessageHistory(c echo.Context) error {
+	return h.messageHistory(c, storage.DefaultKey)
+}
+
+// KeyedMessageHistory lists the revisions of the message stored under :key.
+func (h *Handlers) KeyedMessageHistory(c echo.Context) error {
+	key := c.Param("key")
+	if !storage.ValidKey(key) {
+		return h.storeError(c, storage.ErrInvalidKey)
+	}
+	return h.messageHistory(c, key)
+}
+
+// messageHistory answers with the current revision of the message under
+// key and those the store keeps for undo and redo. As text, each revision
+// is a line of its number, time, writer and first message line.
+func (h *Handlers) messageHistory(c echo.Context, key string) error {
+	revisions, err := h.store.Revisions(c.Request().Context(), key)
+	if err != nil {
+		return h.storeError(c, err)
+	}
+
+	res := MessageHistoryResponse{
+		Key:       key,
+		Revisions: make([]MessageResponse, 0, len(revisions)),
+	}
+	var text strings.Builder
+	for _, data := range slices.Backward(revisions) {
+		res.Revisions = append(res.Revisions, newMessageResponse(data))
+
+		updatedAt, updatedBy := "-", "-"
+		if !data.UpdatedAt.IsZero() {
+			updatedAt = data.UpdatedAt.UTC().Format(time.RFC3339)
+		}
+		if data.UpdatedBy != "" {
+			updatedBy = data.UpdatedBy
+		}
+		firstLine, _, _ := strings.Cut(data.Message, "\n")
+		fmt.Fprintf(&text, "%d\t%s\t%s\t%s\n", data.Revision, updatedAt, updatedBy, firstLine)
+	}
+	return negotiate(c, http.StatusOK, res, text.String())
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageHistory(t *testing.T) {
+	server, _ := setupServer(t, nil)
+
+	do := func(method, target, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+	historyOf := func(target string) MessageHistoryResponse {
+		t.Helper()
+		rec := do(http.MethodGet, target, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var res MessageHistoryResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return res
+	}
+
+	res := historyOf("/api/v1/message/history")
+	assert.Equal(t, "default", res.Key)
+	require.Len(t, res.Revisions, 1)
+	assert.Nil(t, res.Revisions[0].UpdatedAt)
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1/message", `{"message":"First"}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1/message", `{"message":"Second\nline"}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1/message/undo", "").Code)
+
+	// Undoing writes revision 3 with the text of revision 1; revision 2
+	// stays in the history to be redone.
+	res = historyOf("/api/v1/message/history")
+	var revisions []int64
+	for _, revision := range res.Revisions {
+		revisions = append(revisions, revision.Revision)
+	}
+	assert.Equal(t, []int64{3, 2, 0}, revisions)
+	assert.Equal(t, "First", res.Revisions[0].Message)
+	assert.Equal(t, "api", res.Revisions[0].UpdatedBy)
+	assert.NotNil(t, res.Revisions[0].UpdatedAt)
+
+	rec := do(http.MethodGet, "/api/v1/message/history", "", "Accept", "text/plain")
+	require.Equal(t, http.StatusOK, rec.Code)
+	lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "3\t"), lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], "\tapi\tSecond"), lines[1])
+	assert.Equal(t, "0\t-\t-\tHello, World!", lines[2])
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1/messages/motd", `{"message":"Welcome"}`).Code)
+	res = historyOf("/api/v1/messages/motd/history")
+	assert.Equal(t, "motd", res.Key)
+	require.Len(t, res.Revisions, 1)
+	assert.Equal(t, "Welcome", res.Revisions[0].Message)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/messages/unknown/history", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/v1/messages/Bad%20Key/history", "").Code)
+}
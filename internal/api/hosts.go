@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AllowedHosts answers requests addressed to a host that is not in hosts
+// with 421 Misdirected Request, so greetd does not serve requests meant for
+// another site, such as in a DNS rebinding attack. Entries are host names
+// without a port; "*.example.com" allows the subdomains of example.com.
+// The host is X-Forwarded-Host when trusted reports a trusted proxy, and
+// the Host header otherwise. Requests for which skip returns true are let
+// through. Without hosts every request is allowed.
+func AllowedHosts(hosts []string, trusted func(*http.Request) bool, skip func(echo.Context) bool) echo.MiddlewareFunc {
+	allowed := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		allowed = append(allowed, normalizeHost(host))
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if len(allowed) == 0 {
+			return next
+		}
+		return func(c echo.Context) error {
+			if skip != nil && skip(c) {
+				return next(c)
+			}
+			_, host := clientOrigin(c.Request(), trusted)
+			if hostAllowed(allowed, hostName(host)) {
+				return next(c)
+			}
+			return c.JSON(http.StatusMisdirectedRequest, map[string]string{"error": "Host not allowed"})
+		}
+	}
+}
+
+// RedirectHTTPS redirects requests that did not arrive over HTTPS to the
+// same URL with https://, on port, or the default port when it is 0. A
+// request forwarded by a trusted proxy counts as HTTPS when its
+// X-Forwarded-Proto says so. GET and HEAD are redirected with 301; other
+// methods with 308, which keeps the method and body. Requests for which
+// skip returns true are let through.
+func RedirectHTTPS(port int, trusted func(*http.Request) bool, skip func(echo.Context) bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			scheme, host := clientOrigin(r, trusted)
+			if scheme == "https" || (skip != nil && skip(c)) {
+				return next(c)
+			}
+
+			host = hostName(host)
+			if port != 0 && port != 443 {
+				host = net.JoinHostPort(host, strconv.Itoa(port))
+			} else if strings.Contains(host, ":") {
+				host = "[" + host + "]"
+			}
+
+			status := http.StatusMovedPermanently
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				status = http.StatusPermanentRedirect
+			}
+			return c.Redirect(status, "https://"+host+r.URL.RequestURI())
+		}
+	}
+}
+
+// hostAllowed reports whether host matches one of allowed.
+func hostAllowed(allowed []string, host string) bool {
+	for _, pattern := range allowed {
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// hostName returns host without its port, normalized.
+func hostName(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	return normalizeHost(host)
+}
+
+// normalizeHost lowercases host and removes IPv6 brackets and a trailing
+// dot, which name the same host.
+func normalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	return strings.ToLower(host)
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+func TestAllowedHosts(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedHosts = []string{"greetd.example.com", "*.greetd.test"}
+		cfg.Server.TrustedProxies = []string{"10.0.0.0/8"}
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		host       string
+		remoteAddr string
+		forwarded  string
+		want       int
+	}{
+		{name: "allowed host", path: "/api/v1/hello", host: "greetd.example.com", want: http.StatusOK},
+		{name: "allowed host with port", path: "/api/v1/hello", host: "GREETD.example.com:8080", want: http.StatusOK},
+		{name: "allowed subdomain", path: "/api/v1/hello", host: "eu.greetd.test", want: http.StatusOK},
+		{name: "wildcard does not match the domain", path: "/api/v1/hello", host: "greetd.test", want: http.StatusMisdirectedRequest},
+		{name: "other host", path: "/api/v1/hello", host: "evil.example.com", want: http.StatusMisdirectedRequest},
+		{name: "IP address", path: "/api/v1/hello", host: "192.0.2.10:8080", want: http.StatusMisdirectedRequest},
+		{name: "unknown path", path: "/missing", host: "evil.example.com", want: http.StatusMisdirectedRequest},
+		{name: "health probe by IP", path: "/api/v1/health", host: "192.0.2.10:8080", want: http.StatusOK},
+		{name: "forwarded host from trusted proxy", path: "/api/v1/hello", host: "10.0.0.2", remoteAddr: "10.0.0.1:1234", forwarded: "greetd.example.com", want: http.StatusOK},
+		{name: "forwarded host from untrusted peer", path: "/api/v1/hello", host: "evil.example.com", remoteAddr: "203.0.113.9:1234", forwarded: "greetd.example.com", want: http.StatusMisdirectedRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Host = tt.host
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-Host", tt.forwarded)
+			}
+			rec := httptest.NewRecorder()
+			server.echo.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code, rec.Body.String())
+			if tt.want == http.StatusMisdirectedRequest {
+				assert.Contains(t, rec.Body.String(), "Host not allowed")
+			}
+		})
+	}
+}
+
+func TestAllowedHostsDisabledByDefault(t *testing.T) {
+	server, _ := setupServer(t, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/hello", nil)
+	req.Host = "anything.example.com"
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRedirectHTTPS(t *testing.T) {
+	tests := []struct {
+		name       string
+		port       int
+		method     string
+		target     string
+		host       string
+		remoteAddr string
+		proto      string
+		tls        bool
+		want       int
+		location   string
+	}{
+		{name: "plain HTTP", target: "/ui?lang=sv", host: "greetd.example.com", want: http.StatusMovedPermanently, location: "https://greetd.example.com/ui?lang=sv"},
+		{name: "port replaced", port: 8443, target: "/ui", host: "greetd.example.com:8080", want: http.StatusMovedPermanently, location: "https://greetd.example.com:8443/ui"},
+		{name: "default port left out", port: 443, target: "/ui", host: "greetd.example.com:8080", want: http.StatusMovedPermanently, location: "https://greetd.example.com/ui"},
+		{name: "IPv6 host", target: "/ui", host: "[2001:db8::1]:8080", want: http.StatusMovedPermanently, location: "https://[2001:db8::1]/ui"},
+		{name: "POST keeps its method", method: http.MethodPost, target: "/api/v1/message", host: "greetd.example.com", want: http.StatusPermanentRedirect, location: "https://greetd.example.com/api/v1/message"},
+		{name: "TLS", target: "/api/v1/hello", host: "greetd.example.com", tls: true, want: http.StatusOK},
+		{name: "forwarded HTTPS from trusted proxy", target: "/api/v1/hello", host: "greetd.example.com", remoteAddr: "10.0.0.1:1234", proto: "https", want: http.StatusOK},
+		{name: "forwarded HTTP from trusted proxy", target: "/api/v1/hello", host: "greetd.example.com", remoteAddr: "10.0.0.1:1234", proto: "http", want: http.StatusMovedPermanently, location: "https://greetd.example.com/api/v1/hello"},
+		{name: "forwarded HTTPS from untrusted peer", target: "/api/v1/hello", host: "greetd.example.com", remoteAddr: "203.0.113.9:1234", proto: "https", want: http.StatusMovedPermanently, location: "https://greetd.example.com/api/v1/hello"},
+		{name: "health probe", target: "/health", host: "192.0.2.10:8080", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := setupServer(t, func(cfg *config.Config) {
+				cfg.Server.RedirectHTTPS = true
+				cfg.Server.HTTPSPort = tt.port
+				cfg.Server.TrustedProxies = []string{"10.0.0.0/8"}
+			})
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, tt.target, strings.NewReader(`{"message":"Hi"}`))
+			req.Host = tt.host
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			if tt.tls {
+				req = httptest.NewRequest(method, "https://"+tt.host+tt.target, nil)
+			}
+			rec := httptest.NewRecorder()
+			server.echo.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.location, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestRedirectHTTPSDisabledByDefault(t *testing.T) {
+	server, _ := setupServer(t, nil)
+
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/hello", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
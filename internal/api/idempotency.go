@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client's key for a write that may be
+	// retried.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader marks a response replayed for a repeated key.
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLen bounds the length of an Idempotency-Key.
+	maxIdempotencyKeyLen = 255
+)
+
+// Idempotent makes a write safe to retry: a request carrying an
+// Idempotency-Key is answered once, and a repeat of it with the same key
+// within message.idempotency_window gets the stored response instead of
+// being applied again. Reusing a key for a different request answers 422,
+// and repeating it while the first is still running answers 409. Keys are
+// scoped to the writer, so clients with different API keys cannot see each
+// other's responses. Server errors are not remembered, so the request can
+// be retried. Requests without the header are passed through.
+func (h *Handlers) Idempotent(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.Request().Header.Get(IdempotencyKeyHeader)
+		if h.idempotency == nil || key == "" {
+			return next(c)
+		}
+		if !validIdempotencyKey(key) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Idempotency-Key must be 1-255 printable ASCII characters"})
+		}
+
+		fingerprint, err := h.requestFingerprint(c)
+		if err != nil {
+			var httpErr *echo.HTTPError
+			if errors.As(err, &httpErr) {
+				return c.JSON(httpErr.Code, map[string]string{"error": "Request body too large"})
+			}
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to read request body"})
+		}
+
+		scoped := h.updatedBy(c) + " " + key
+		stored, err := h.idempotency.Begin(scoped, fingerprint)
+		switch {
+		case errors.Is(err, storage.ErrIdempotencyMismatch):
+			return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": "Idempotency-Key was already used for a different request"})
+		case errors.Is(err, storage.ErrIdempotencyInProgress):
+			return c.JSON(http.StatusConflict, map[string]string{"error": "A request with this Idempotency-Key is still being processed"})
+		case stored != nil:
+			header := c.Response().Header()
+			if stored.ETag != "" {
+				header.Set("ETag", stored.ETag)
+			}
+			header.Set(idempotentReplayedHeader, "true")
+			return c.Blob(stored.Status, stored.ContentType, stored.Body)
+		}
+
+		res := c.Response()
+		capture := &captureWriter{ResponseWriter: res.Writer}
+		res.Writer = capture
+		err = next(c)
+		res.Writer = capture.ResponseWriter
+
+		if err != nil || !res.Committed || res.Status >= http.StatusInternalServerError {
+			h.idempotency.Abort(scoped)
+			return err
+		}
+		h.idempotency.Finish(scoped, storage.StoredResponse{
+			Fingerprint: fingerprint,
+			Status:      res.Status,
+			ContentType: res.Header().Get(echo.HeaderContentType),
+			ETag:        res.Header().Get("ETag"),
+			Body:        capture.body.Bytes(),
+		})
+		return nil
+	}
+}
+
+// validIdempotencyKey reports whether key is 1-255 printable ASCII
+// characters.
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return key != ""
+}
+
+// requestFingerprint identifies a request by its method, route, If-Match
+// header and body, so a retry matches and a different request under the
+// same key does not. The route is taken relative to the API prefix, so the
+// deprecated aliases match their /api/v1 routes. The body is read and put
+// back for the handler.
+func (h *Handlers) requestFingerprint(c echo.Context) (string, error) {
+	req := c.Request()
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	route := strings.TrimPrefix(strings.TrimPrefix(c.Path(), h.basePath), APIPrefix)
+	sum := sha256.New()
+	io.WriteString(sum, req.Method+" "+route+"\n"+req.Header.Get("If-Match")+"\n")
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// captureWriter keeps a copy of the response body written through it.
+type captureWriter struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
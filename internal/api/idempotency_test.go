@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+func TestIdempotentMessage(t *testing.T) {
+	server, _ := setupServer(t, nil)
+
+	post := func(target, body, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := post("/api/v1/message", `{"message":"Hi"}`, "abc-123")
+	require.Equal(t, http.StatusOK, first.Code)
+	assert.Empty(t, first.Header().Get(idempotentReplayedHeader))
+
+	// The retry is answered with the stored response instead of writing
+	// another revision, also through the deprecated alias.
+	for _, target := range []string{"/api/v1/message", "/message"} {
+		replay := post(target, `{"message":"Hi"}`, "abc-123")
+		require.Equal(t, http.StatusOK, replay.Code, target)
+		assert.Equal(t, "true", replay.Header().Get(idempotentReplayedHeader))
+		assert.Equal(t, first.Header().Get("ETag"), replay.Header().Get("ETag"))
+		assert.Equal(t, first.Header().Get("Content-Type"), replay.Header().Get("Content-Type"))
+		assert.Equal(t, first.Body.String(), replay.Body.String())
+	}
+	assert.Equal(t, int64(1), server.handlers.store.Get(context.Background()).Revision)
+
+	rec := post("/api/v1/message", `{"message":"Hello"}`, "abc-123")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "different request")
+
+	// Rejected requests are remembered too.
+	rec = post("/api/v1/message", `{"message":" "}`, "empty")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = post("/api/v1/message", `{"message":" "}`, "empty")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get(idempotentReplayedHeader))
+
+	// Without a key every request is applied.
+	require.Equal(t, http.StatusOK, post("/api/v1/message", `{"message":"Hi"}`, "").Code)
+	assert.Equal(t, int64(2), server.handlers.store.Get(context.Background()).Revision)
+
+	rec = post("/api/v1/message", `{"message":"Hi"}`, strings.Repeat("k", 256))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "greetd_idempotency_keys 2\n")
+	assert.Contains(t, rec.Body.String(), "greetd_idempotency_hits_total 3\n")
+}
+
+func TestIdempotentMessageScopedToAPIKey(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Security.APIKeys = []string{"alpha", "beta"}
+	})
+
+	post := func(apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/message", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(IdempotencyKeyHeader, "shared")
+		req.Header.Set(APIKeyHeader, apiKey)
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, post("alpha", `{"message":"From alpha"}`).Code)
+	rec := post("beta", `{"message":"From beta"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get(idempotentReplayedHeader))
+	assert.Contains(t, rec.Body.String(), "From beta")
+}
+
+func TestIdempotencyDisabled(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Message.IdempotencyWindow = "0"
+	})
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/message", strings.NewReader(`{"message":"Hi"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(IdempotencyKeyHeader, "abc")
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get(idempotentReplayedHeader))
+	}
+
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "greetd_idempotency_keys 0\n")
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+// importField is the multipart field POST /admin/import reads the file from.
+const importField = "file"
+
+// ImportChange is a difference between the stored messages and an upload.
+type ImportChange struct {
+	Key string `json:"key"`
+	// Change is "added", "changed" or "removed".
+	Change string `json:"change"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// ImportResponse summarizes what POST /admin/import changed, or would
+// change with ?dry_run=1.
+type ImportResponse struct {
+	DryRun  bool           `json:"dry_run"`
+	Added   int            `json:"added"`
+	Changed int            `json:"changed"`
+	Removed int            `json:"removed"`
+	Changes []ImportChange `json:"changes"`
+}
+
+// ImportProblem is why an entry of an upload was rejected. It is listed
+// under problems in the 422 problem details.
+type ImportProblem struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// ImportMessages replaces the stored messages with the uploaded file, like
+// "greetd import". The file may be an export file or a messages.json, which
+// replace every message in a single write, or a message.json, which only
+// replaces the default message. Every entry is validated like POST /message
+// before anything is written; ?dry_run=1 only reports the changes.
+func (h *Handlers) ImportMessages(c echo.Context) error {
+	dryRun, err := boolParam(c, "dry_run")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	upload, err := c.FormFile(importField)
+	if err != nil {
+		var httpErr *echo.HTTPError
+		switch {
+		case errors.As(err, &httpErr) && httpErr.Code == http.StatusRequestEntityTooLarge:
+			return h.problemResponse(c, http.StatusRequestEntityTooLarge, "Request body too large")
+		case errors.Is(err, http.ErrNotMultipart):
+			return h.problemResponse(c, http.StatusUnsupportedMediaType, "Content-Type must be multipart/form-data")
+		}
+		return h.problemResponse(c, http.StatusBadRequest, fmt.Sprintf("The upload must have a %q file field", importField))
+	}
+	file, err := upload.Open()
+	if err != nil {
+		return h.problemResponse(c, http.StatusBadRequest, "The uploaded file cannot be read")
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return h.problemResponse(c, http.StatusBadRequest, "The uploaded file cannot be read")
+	}
+
+	messages, complete, err := storage.ParseImport(data)
+	if err != nil {
+		return h.problemResponse(c, http.StatusUnprocessableEntity, err.Error())
+	}
+	if problems := h.prepareImport(messages); len(problems) > 0 {
+		return h.problem(c, ProblemDetails{
+			Type:     "about:blank",
+			Title:    http.StatusText(http.StatusUnprocessableEntity),
+			Status:   http.StatusUnprocessableEntity,
+			Detail:   "The file has invalid entries",
+			Problems: problems,
+		})
+	}
+
+	ctx := c.Request().Context()
+	var changes []storage.MessageChange
+	switch {
+	case dryRun && complete:
+		changes = h.store.Diff(ctx, messages)
+	case dryRun:
+		// Only the default message is replaced; the other keys stay.
+		next := h.store.Export(ctx).Messages
+		next[storage.DefaultKey] = messages[storage.DefaultKey]
+		changes = h.store.Diff(ctx, next)
+	case complete:
+		changes, err = h.store.SwapMessages(ctx, messages, h.updatedBy(c))
+	default:
+		changes, err = h.importDefault(ctx, messages[storage.DefaultKey], h.updatedBy(c))
+	}
+	if err != nil {
+		return h.storeError(c, err)
+	}
+
+	res := newImportResponse(changes, dryRun)
+	h.logger.WithFields(logrus.Fields{
+		"uploaded_by": h.updatedBy(c),
+		"file":        upload.Filename,
+		"dry_run":     dryRun,
+		"added":       res.Added,
+		"changed":     res.Changed,
+		"removed":     res.Removed,
+	}).Info("Messages imported")
+	return c.JSON(http.StatusOK, res)
+}
+
+// importDefault replaces the default message with message, reporting the
+// change against the message it replaced. An unchanged message is not
+// written again.
+func (h *Handlers) importDefault(ctx context.Context, message, updatedBy string) ([]storage.MessageChange, error) {
+	if h.store.GetMessage(ctx) == message {
+		return nil, nil
+	}
+	previous, _, err := h.store.SwapKey(ctx, storage.DefaultKey, message, updatedBy)
+	if err != nil || previous.Message == message {
+		return nil, err
+	}
+	return []storage.MessageChange{{Key: storage.DefaultKey, Old: previous.Message, New: message}}, nil
+}
+
+// prepareImport validates every key and message of an upload with the
+// rules of POST /message, replacing the messages by their prepared form. It
+// returns every problem found, sorted by key. message.max_keys is checked
+// by the store when the messages are written.
+func (h *Handlers) prepareImport(messages map[string]string) []ImportProblem {
+	keys := make([]string, 0, len(messages))
+	for key := range messages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var problems []ImportProblem
+	for _, key := range keys {
+		if !storage.ValidKey(key) {
+			problems = append(problems, ImportProblem{Key: key, Error: "Key must be 1-64 characters of a-z, 0-9, - and _"})
+			continue
+		}
+		req := MessageRequest{Message: messages[key]}
+		if _, err := h.prepareMessage(&req); err != nil {
+			problems = append(problems, ImportProblem{Key: key, Error: err.Error()})
+			continue
+		}
+		messages[key] = req.Message
+	}
+	return problems
+}
+
+func newImportResponse(changes []storage.MessageChange, dryRun bool) ImportResponse {
+	res := ImportResponse{DryRun: dryRun, Changes: make([]ImportChange, 0, len(changes))}
+	for _, change := range changes {
+		item := ImportChange{Key: change.Key, Old: change.Old, New: change.New}
+		switch {
+		case change.Added:
+			item.Change = "added"
+			res.Added++
+		case change.Removed:
+			item.Change = "removed"
+			res.Removed++
+		default:
+			item.Change = "changed"
+			res.Changed++
+		}
+		res.Changes = append(res.Changes, item)
+	}
+	return res
+}
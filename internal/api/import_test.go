@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+// uploadImport posts content as the file field of a multipart form to
+// target with the API key "secret".
+func uploadImport(t *testing.T, server *Server, target, content string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile(importField, "messages.yaml")
+	require.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest(http.MethodPost, target, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set(APIKeyHeader, "secret")
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestImportMessages(t *testing.T) {
+	server, logger := setupAdminServer(t, []string{"secret"}, false)
+	var logs bytes.Buffer
+	logger.SetOutput(&logs)
+	store := server.handlers.store
+	ctx := context.Background()
+	require.NoError(t, store.SetKey(ctx, "footer", "Bye", storage.UpdatedByCLI))
+
+	const file = "apiVersion: greetd/v1\nmessages:\n  default: Hi\n  motd: Open\n"
+
+	rec := uploadImport(t, server, "/admin/import?dry_run=1", file)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var res ImportResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.True(t, res.DryRun)
+	assert.Equal(t, 1, res.Added)
+	assert.Equal(t, 1, res.Changed)
+	assert.Equal(t, 1, res.Removed)
+	assert.Equal(t, []ImportChange{
+		{Key: "default", Change: "changed", Old: storage.DefaultMessage, New: "Hi"},
+		{Key: "footer", Change: "removed", Old: "Bye"},
+		{Key: "motd", Change: "added", New: "Open"},
+	}, res.Changes)
+	assert.Equal(t, storage.DefaultMessage, store.GetMessage(ctx))
+
+	rec = uploadImport(t, server, "/admin/import", file)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.False(t, res.DryRun)
+	assert.Len(t, res.Changes, 3)
+	assert.Equal(t, "Hi", store.GetMessage(ctx))
+	assert.Equal(t, "api-key:2bb80d53", store.Get(ctx).UpdatedBy)
+	_, err := store.GetKey(ctx, "footer")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.Contains(t, logs.String(), "Messages imported")
+	assert.Contains(t, logs.String(), "uploaded_by=\"api-key:2bb80d53\"")
+
+	// A message.json only replaces the default message.
+	rec = uploadImport(t, server, "/admin/import", `{"message": "Hello again"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, []ImportChange{{Key: "default", Change: "changed", Old: "Hi", New: "Hello again"}}, res.Changes)
+	motd, err := store.GetKey(ctx, "motd")
+	require.NoError(t, err)
+	assert.Equal(t, "Open", motd.Message)
+
+	// So does a messages.json, the store's own format.
+	rec = uploadImport(t, server, "/admin/import", `{"messages": {"default": {"message": "Hello again"}, "motd": {"message": "Open"}}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Empty(t, res.Changes)
+}
+
+func TestImportMessagesInvalid(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Security.APIKeys = []string{"secret"}
+		cfg.Message.MaxLength = 10
+		cfg.Message.ImportLimit = "1KB"
+	})
+
+	rec := uploadImport(t, server, "/admin/import", "apiVersion: greetd/v1\nmessages:\n  default: Fine\n  motd: \"  \"\n  Bad Key: x\n  footer: far too long for it\n")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, mimeProblemJSON, rec.Header().Get("Content-Type"))
+	var res ProblemDetails
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "The file has invalid entries", res.Detail)
+	assert.Equal(t, []ImportProblem{
+		{Key: "Bad Key", Error: "Key must be 1-64 characters of a-z, 0-9, - and _"},
+		{Key: "footer", Error: "Message exceeds the maximum length of 10 characters"},
+		{Key: "motd", Error: "Message cannot be empty"},
+	}, res.Problems)
+	assert.Equal(t, storage.DefaultMessage, server.handlers.store.GetMessage(context.Background()))
+
+	rec = uploadImport(t, server, "/admin/import", `{"greeting": "Hi"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, mimeProblemJSON, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), `"detail":"unrecognized file`)
+
+	rec = uploadImport(t, server, "/admin/import", "apiVersion: greetd/v1\nmessages:\n  default: "+strings.Repeat("x", 2000)+"\n")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/import", strings.NewReader(`{"message":"Hi"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(APIKeyHeader, "secret")
+	rec = httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/import", nil)
+	rec = httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// internalListenerKey marks the context of requests that arrived on the
+// internal listener.
+type internalListenerKey struct{}
+
+// internalContext is the base context of the internal listener's server.
+func internalContext(net.Listener) context.Context {
+	return context.WithValue(context.Background(), internalListenerKey{}, true)
+}
+
+// onInternalListener reports whether r arrived on the internal listener.
+func onInternalListener(r *http.Request) bool {
+	internal, _ := r.Context().Value(internalListenerKey{}).(bool)
+	return internal
+}
+
+// InternalOnly answers requests on the internal listener with 404 unless
+// they matched a route allowed reports as internal. Requests on the public
+// listener pass through.
+func InternalOnly(allowed func(c echo.Context) bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if onInternalListener(c.Request()) && !allowed(c) {
+				return echo.ErrNotFound
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/svanhalla/prompt-lab/greetd/internal/i18n"
+)
+
+const (
+	// langCookie remembers the language picked with ?lang= on the HTML
+	// pages.
+	langCookie = "lang"
+	// langCookieMaxAge keeps the choice for a year.
+	langCookieMaxAge = 365 * 24 * 60 * 60
+)
+
+// locale returns the language of the HTML pages for this request: ?lang=,
+// then the language cookie, then Accept-Language, then i18n.DefaultLang. A
+// supported ?lang= different from the cookie is stored in it, so the choice
+// made with the language picker sticks.
+func (h *Handlers) locale(c echo.Context) string {
+	cookie, _ := c.Cookie(langCookie)
+
+	if lang, ok := h.locales.MatchTag(c.QueryParam("lang")); ok {
+		if cookie == nil || cookie.Value != lang {
+			path := h.basePath
+			if path == "" {
+				path = "/"
+			}
+			c.SetCookie(&http.Cookie{
+				Name:     langCookie,
+				Value:    lang,
+				Path:     path,
+				MaxAge:   langCookieMaxAge,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		return lang
+	}
+
+	candidates := i18n.ParseAcceptLanguage(c.Request().Header.Get("Accept-Language"))
+	if cookie != nil {
+		candidates = append([]string{cookie.Value}, candidates...)
+	}
+	return h.locales.Match(candidates...)
+}
+
+// tr returns the UI string key in the language of the request, formatted
+// with args.
+func (h *Handlers) tr(c echo.Context, key string, args ...interface{}) string {
+	return h.locales.Tr(h.locale(c), key, args...)
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+func TestUILanguage(t *testing.T) {
+	handlers, tmpDir := setupTestHandlers(t)
+	defer os.RemoveAll(tmpDir)
+
+	tests := []struct {
+		name           string
+		target         string
+		cookie         string
+		acceptLanguage string
+		lang           string
+		setsCookie     bool
+	}{
+		{name: "default", target: "/ui", lang: "en"},
+		{name: "query parameter", target: "/ui?lang=sv", lang: "sv", setsCookie: true},
+		{name: "query parameter with region", target: "/ui?lang=sv-SE", lang: "sv", setsCookie: true},
+		{name: "cookie", target: "/ui", cookie: "sv", lang: "sv"},
+		{name: "query parameter matching the cookie", target: "/ui?lang=sv", cookie: "sv", lang: "sv"},
+		{name: "query parameter overrides cookie", target: "/ui?lang=en", cookie: "sv", lang: "en", setsCookie: true},
+		{name: "accept-language", target: "/ui", acceptLanguage: "sv-SE,sv;q=0.9,en;q=0.8", lang: "sv"},
+		{name: "cookie overrides accept-language", target: "/ui", cookie: "en", acceptLanguage: "sv", lang: "en"},
+		{name: "unsupported query parameter", target: "/ui?lang=xx", acceptLanguage: "sv", lang: "sv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: langCookie, Value: tt.cookie})
+			}
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			rec := httptest.NewRecorder()
+			require.NoError(t, handlers.UI(e.NewContext(req, rec)))
+
+			body := rec.Body.String()
+			assert.Contains(t, body, `<html lang="`+tt.lang+`">`)
+			if tt.lang == "sv" {
+				assert.Contains(t, body, "Uppdatera meddelandet")
+			} else {
+				assert.Contains(t, body, "Update Message")
+			}
+			assert.Contains(t, rec.Header().Values(echo.HeaderVary), "Accept-Language, Cookie")
+
+			setCookie := rec.Header().Get(echo.HeaderSetCookie)
+			if tt.setsCookie {
+				assert.Contains(t, setCookie, langCookie+"="+tt.lang)
+				assert.Contains(t, setCookie, "Path=/;")
+			} else {
+				assert.NotContains(t, setCookie, langCookie+"=")
+			}
+		})
+	}
+}
+
+func TestUILanguageFormErrors(t *testing.T) {
+	handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+		cfg.Message.MaxLength = 10
+	})
+	defer os.RemoveAll(tmpDir)
+
+	post := func(body string) string {
+		t.Helper()
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/ui/message", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		req.AddCookie(&http.Cookie{Name: langCookie, Value: "sv"})
+		rec := httptest.NewRecorder()
+		require.NoError(t, handlers.UIMessage(e.NewContext(req, rec)))
+		return rec.Body.String()
+	}
+
+	assert.Contains(t, post(url.Values{"message": {" "}}.Encode()), "Meddelandet får inte vara tomt")
+	assert.Contains(t, post(url.Values{"message": {"far too long for it"}}.Encode()), "Meddelandet är 19 tecken långt, vilket överskrider maxlängden på 10 tecken")
+}
+
+func TestUILocalesPath(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sv.yaml"), []byte(`ui.submit: "Spara"`), 0644))
+
+	handlers, tmpDir := setupTestHandlersWithConfig(t, func(cfg *config.Config) {
+		cfg.UI.LocalesPath = dir
+	})
+	defer os.RemoveAll(tmpDir)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/ui?lang=sv", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, handlers.UI(e.NewContext(req, rec)))
+
+	assert.Contains(t, rec.Body.String(), "Spara")
+	assert.Contains(t, rec.Body.String(), "Namngivna meddelanden:", "keys missing from the override keep the built-in text")
+
+	cfg := config.DefaultConfig()
+	cfg.DataPath = tmpDir
+	cfg.UI.LocalesPath = filepath.Join(dir, "missing")
+	_, err := NewHandlers(cfg, handlers.store, handlers.logger)
+	assert.ErrorContains(t, err, "failed to load UI locales")
+}
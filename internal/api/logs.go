@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/svanhalla/prompt-lab/greetd/internal/logging"
+)
+
+const (
+	// logPageLines is the number of entries shown per /logs page by default.
+	logPageLines = 50
+	// maxLogPageLines caps ?lines= on the /logs page.
+	maxLogPageLines = 500
+	// logScanLines is how many lines of a log file are searched by /logs.
+	logScanLines = 5000
+)
+
+// logFilter selects log entries by ?level= and ?q=.
+type logFilter struct {
+	// level is the least severe level kept; entries without a level, such
+	// as plain-text lines, are only matched by query.
+	level    logrus.Level
+	hasLevel bool
+	// query is matched case-insensitively against the formatted entry.
+	query string
+}
+
+func parseLogFilter(c echo.Context) (logFilter, error) {
+	f := logFilter{query: strings.TrimSpace(c.QueryParam("q"))}
+	if raw := c.QueryParam("level"); raw != "" {
+		level, err := logrus.ParseLevel(raw)
+		if err != nil {
+			return f, fmt.Errorf("invalid level %q", raw)
+		}
+		f.level, f.hasLevel = level, true
+	}
+	return f, nil
+}
+
+func (f logFilter) match(entry logging.Entry) bool {
+	if f.hasLevel {
+		if level, err := logrus.ParseLevel(entry.Level); err == nil && level > f.level {
+			return false
+		}
+	}
+	if f.query != "" && !strings.Contains(strings.ToLower(formatEntry(entry)), strings.ToLower(f.query)) {
+		return false
+	}
+	return true
+}
+
+func (f logFilter) apply(entries []logging.Entry) []logging.Entry {
+	if !f.hasLevel && f.query == "" {
+		return entries
+	}
+	var matched []logging.Entry
+	for _, entry := range entries {
+		if f.match(entry) {
+			matched = append(matched, entry)
+		}
+	}
+	return matched
+}
+
+// parseLogLine turns a line of a log file into an entry. JSON lines written
+// by logrus keep their time, level and fields; anything else, including
+// JSON access log lines, becomes an entry with only the line as message.
+func parseLogLine(line string) logging.Entry {
+	var data map[string]interface{}
+	if json.Unmarshal([]byte(line), &data) != nil {
+		return logging.Entry{Message: line}
+	}
+	level, ok := data["level"].(string)
+	if !ok {
+		return logging.Entry{Message: line}
+	}
+
+	entry := logging.Entry{Level: level}
+	if msg, ok := data["msg"].(string); ok {
+		entry.Message = msg
+	}
+	if raw, ok := data["time"].(string); ok {
+		entry.Time, _ = time.Parse(time.RFC3339, raw)
+	}
+	for key, value := range data {
+		if key == "level" || key == "msg" || key == "time" {
+			continue
+		}
+		if entry.Fields == nil {
+			entry.Fields = make(map[string]interface{})
+		}
+		entry.Fields[key] = value
+	}
+	return entry
+}
+
+// formatEntry formats an entry for display. Plain-text lines from a log
+// file are shown as they are.
+func formatEntry(entry logging.Entry) string {
+	if entry.Level == "" && entry.Time.IsZero() {
+		return entry.Message
+	}
+	return entry.String()
+}
+
+// logLine is a log entry as shown on the /logs page.
+type logLine struct {
+	Text string
+	// Level selects the color; empty for plain-text lines.
+	Level string
+}
+
+// logPage is a page of log entries. Page 1 holds the newest entries.
+type logPage struct {
+	Lines []logLine
+	Total int
+	Page  int
+	Size  int
+	// OlderURL and NewerURL link the neighbouring pages; empty on the last
+	// and first page.
+	OlderURL string
+	NewerURL string
+}
+
+// paginateLogs returns page (1-based, newest first) of size entries, each
+// page oldest first. query is the /logs query the page links keep.
+func paginateLogs(entries []logging.Entry, page, size int, query url.Values) logPage {
+	res := logPage{Total: len(entries), Page: page, Size: size}
+
+	end := len(entries) - (page-1)*size
+	start := max(end-size, 0)
+	if end > 0 {
+		for _, entry := range entries[start:end] {
+			res.Lines = append(res.Lines, logLine{Text: formatEntry(entry), Level: entry.Level})
+		}
+	}
+
+	link := func(page int) string {
+		q := url.Values{}
+		for key, values := range query {
+			q[key] = values
+		}
+		q.Set("page", strconv.Itoa(page))
+		return "/logs?" + q.Encode()
+	}
+	if start > 0 {
+		res.OlderURL = link(page + 1)
+	}
+	if page > 1 {
+		res.NewerURL = link(page - 1)
+	}
+	return res
+}
+
+// positiveParam parses the query parameter name as a positive integer, at
+// most limit unless limit is 0. def is returned when it is absent.
+func positiveParam(c echo.Context, name string, def, limit int) (int, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	switch {
+	case err != nil || n < 1:
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	case limit > 0 && n > limit:
+		return 0, fmt.Errorf("%s must be at most %d", name, limit)
+	}
+	return n, nil
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+// MaintenanceRequest enables or disables maintenance mode. Message is shown
+// to users while it is enabled.
+type MaintenanceRequest struct {
+	Enabled *bool  `json:"enabled"`
+	Message string `json:"message,omitempty"`
+}
+
+type MaintenanceResponse struct {
+	Enabled   bool       `json:"enabled"`
+	Message   string     `json:"message,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
+}
+
+func newMaintenanceResponse(state storage.MaintenanceState) MaintenanceResponse {
+	res := MaintenanceResponse{Enabled: state.Enabled, Message: state.Message, UpdatedBy: state.UpdatedBy}
+	if !state.UpdatedAt.IsZero() {
+		res.UpdatedAt = &state.UpdatedAt
+	}
+	return res
+}
+
+func (h *Handlers) GetMaintenance(c echo.Context) error {
+	return c.JSON(http.StatusOK, newMaintenanceResponse(h.maintenance.Get()))
+}
+
+func (h *Handlers) SetMaintenance(c echo.Context) error {
+	var req MaintenanceRequest
+	if status, err := bindJSON(c, &req); err != nil {
+		return h.problemResponse(c, status, err.Error())
+	}
+	if req.Enabled == nil {
+		return h.problemResponse(c, http.StatusBadRequest, `Field "enabled" is required`)
+	}
+	if req.Message != "" {
+		// Validated like a stored message, so a banned word is 422 here too.
+		message := MessageRequest{Message: req.Message}
+		if status, err := h.prepareMessage(&message); err != nil {
+			return h.problemResponse(c, status, err.Error())
+		}
+		req.Message = message.Message
+	}
+
+	state, err := h.maintenance.Set(*req.Enabled, req.Message, h.updatedBy(c))
+	if errors.Is(err, storage.ErrReadOnly) {
+		return h.problemResponse(c, http.StatusServiceUnavailable, "Storage is read-only; maintenance mode cannot be changed")
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to save maintenance mode")
+		return h.internalError(c, http.StatusInternalServerError, err, "")
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"enabled":    state.Enabled,
+		"updated_by": state.UpdatedBy,
+	}).Warn("Maintenance mode changed")
+
+	return c.JSON(http.StatusOK, newMaintenanceResponse(state))
+}
+
+// MaintenanceGate answers 503 with the maintenance message while
+// maintenance mode is enabled. It guards the user-facing routes; health,
+// metrics and admin routes keep working.
+func (h *Handlers) MaintenanceGate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		state := h.maintenance.Get()
+		if !state.Enabled {
+			return next(c)
+		}
+		return h.problemResponse(c, http.StatusServiceUnavailable, state.Message)
+	}
+}
+
+// maintenanceCheck is the "maintenance" entry of the health checks.
+func (h *Handlers) maintenanceCheck() string {
+	if h.maintenance.Get().Enabled {
+		return "enabled"
+	}
+	return "ok"
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+// MessageKey describes one named message in the GET /messages listing.
+type MessageKey struct {
+	Key       string     `json:"key" yaml:"key"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty" yaml:"updated_at,omitempty"`
+	UpdatedBy string     `json:"updated_by,omitempty" yaml:"updated_by,omitempty"`
+}
+
+type MessageListResponse struct {
+	Keys []MessageKey `json:"keys" yaml:"keys"`
+}
+
+// KeyedMessageResponse is a named message.
+type KeyedMessageResponse struct {
+	Key             string `json:"key" yaml:"key"`
+	MessageResponse `yaml:",inline"`
+}
+
+// ListMessages lists the keys with a message. The default key is always
+// included.
+func (h *Handlers) ListMessages(c echo.Context) error {
+	infos := h.store.Keys(c.Request().Context())
+
+	res := MessageListResponse{Keys: make([]MessageKey, 0, len(infos))}
+	for _, info := range infos {
+		key := MessageKey{Key: info.Key, UpdatedBy: info.UpdatedBy}
+		if !info.UpdatedAt.IsZero() {
+			updatedAt := info.UpdatedAt
+			key.UpdatedAt = &updatedAt
+		}
+		res.Keys = append(res.Keys, key)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *Handlers) GetKeyedMessage(c echo.Context) error {
+	key := c.Param("key")
+	if !storage.ValidKey(key) {
+		return h.storeError(c, storage.ErrInvalidKey)
+	}
+
+	data, err := h.store.GetKey(c.Request().Context(), key)
+	if err != nil {
+		return h.storeError(c, err)
+	}
+
+	c.Response().Header().Set("ETag", representationETag(c, data.Revision))
+	setLastModified(c, data.UpdatedAt)
+	return negotiate(c, http.StatusOK, KeyedMessageResponse{Key: key, MessageResponse: newMessageResponse(data)}, data.Message)
+}
+
+// SetKeyedMessage creates or replaces the message stored under :key.
+func (h *Handlers) SetKeyedMessage(c echo.Context) error {
+	key := c.Param("key")
+	if !storage.ValidKey(key) {
+		return h.storeError(c, storage.ErrInvalidKey)
+	}
+
+	return h.setMessage(c, key, func(data storage.MessageData, previous *storage.MessageData) interface{} {
+		return KeyedMessageResponse{Key: key, MessageResponse: newUpdateResponse(data, previous)}
+	})
+}
+
+// ConflictResponse is returned with 409 when an update names a revision
+// that is no longer current.
+type ConflictResponse struct {
+	Error   string      `json:"error"`
+	Current interface{} `json:"current"`
+}
+
+// setMessage stores the message in the request body under key and answers
+// with render's view of the stored message and the one it replaced. When
+// the request names a revision, in the body or with If-Match, the update
+// only applies if it is still current; otherwise the current message is
+// returned with 409.
+func (h *Handlers) setMessage(c echo.Context, key string, render func(data storage.MessageData, previous *storage.MessageData) interface{}) error {
+	var req MessageRequest
+	if status, err := bindJSON(c, &req); err != nil {
+		return h.problemResponse(c, status, err.Error())
+	}
+	if status, err := h.prepareMessage(&req); err != nil {
+		return h.problemResponse(c, status, err.Error())
+	}
+
+	revision, conditional, err := expectedRevision(c, req)
+	if err != nil {
+		return h.problemResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	ctx := c.Request().Context()
+	var previous *storage.MessageData
+	var data storage.MessageData
+	if conditional {
+		previous, data, err = h.store.CompareAndSwapKey(ctx, key, req.Message, h.updatedBy(c), revision)
+	} else {
+		previous, data, err = h.store.SwapKey(ctx, key, req.Message, h.updatedBy(c))
+	}
+	if errors.Is(err, storage.ErrConflict) {
+		c.Response().Header().Set("ETag", revisionETag(data.Revision))
+		return c.JSON(http.StatusConflict, ConflictResponse{
+			Error:   "The message was changed by someone else; review the current revision and try again",
+			Current: render(data, nil),
+		})
+	}
+	if err != nil {
+		return h.storeError(c, err)
+	}
+
+	c.Response().Header().Set("ETag", revisionETag(data.Revision))
+	return c.JSON(http.StatusOK, render(data, previous))
+}
+
+// revisionETag is the ETag of a message revision.
+func revisionETag(revision int64) string {
+	return `"` + strconv.FormatInt(revision, 10) + `"`
+}
+
+// representationETag is the ETag of a message revision in the format the
+// request negotiated: the revision ETag for JSON, suffixed with the format
+// otherwise, such as "3-text", so caches keep the forms apart.
+func representationETag(c echo.Context, revision int64) string {
+	format := responseFormat(c)
+	if format == formatJSON {
+		return revisionETag(revision)
+	}
+	return `"` + strconv.FormatInt(revision, 10) + "-" + format + `"`
+}
+
+// expectedRevision returns the revision an update is conditional on, from
+// the revision field or the If-Match header. If-Match: * and a request
+// without either are unconditional. The ETag of any representation of a
+// revision names it; an If-Match that names no revision can never match.
+func expectedRevision(c echo.Context, req MessageRequest) (int64, bool, error) {
+	ifMatch := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		if req.Revision == nil {
+			return 0, false, nil
+		}
+		return *req.Revision, true, nil
+	}
+
+	revision := int64(-1)
+	if unquoted, err := strconv.Unquote(ifMatch); err == nil && strings.HasPrefix(ifMatch, `"`) {
+		number, _, _ := strings.Cut(unquoted, "-")
+		if n, err := strconv.ParseInt(number, 10, 64); err == nil && n >= 0 {
+			revision = n
+		}
+	}
+	if req.Revision != nil && *req.Revision != revision {
+		return 0, false, errors.New("The revision field and If-Match header disagree")
+	}
+	return revision, true, nil
+}
+
+// DeleteKeyedMessage removes the message stored under :key. Deleting the
+// default key restores the default message.
+func (h *Handlers) DeleteKeyedMessage(c echo.Context) error {
+	key := c.Param("key")
+	if !storage.ValidKey(key) {
+		return h.storeError(c, storage.ErrInvalidKey)
+	}
+
+	if err := h.store.DeleteKey(c.Request().Context(), key); err != nil {
+		return h.storeError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
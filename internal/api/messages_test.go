@@ -0,0 +1,234 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+func TestKeyedMessages(t *testing.T) {
+	server, _ := setupAdminServer(t, []string{"secret"}, false)
+
+	do := func(method, target, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/api/v1/messages", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"keys":[{"key":"default"}]}`, rec.Body.String())
+
+	rec = do(http.MethodGet, "/api/v1/messages/motd", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = do(http.MethodPost, "/api/v1/messages/motd", `{"message":"Welcome"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var res KeyedMessageResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "motd", res.Key)
+	assert.Equal(t, "Welcome", res.Message)
+	assert.Equal(t, "api", res.UpdatedBy)
+	assert.NotNil(t, res.UpdatedAt)
+	// A new key replaced nothing.
+	assert.NotContains(t, rec.Body.String(), "previous")
+
+	rec = do(http.MethodPost, "/api/v1/messages/motd", `{"message":"Welcome back"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	res = KeyedMessageResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	require.NotNil(t, res.Previous)
+	assert.Equal(t, "Welcome", *res.Previous)
+	assert.Equal(t, int64(2), res.Revision)
+
+	rec = do(http.MethodGet, "/api/v1/messages/motd", "", "Accept", "text/plain")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Welcome back", rec.Body.String())
+
+	// /message is the default key.
+	rec = do(http.MethodPost, "/api/v1/message", `{"message":"Hi"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = do(http.MethodGet, "/api/v1/messages/default", "")
+	assert.Contains(t, rec.Body.String(), `"message":"Hi"`)
+
+	var list MessageListResponse
+	rec = do(http.MethodGet, "/api/v1/messages", "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Keys, 2)
+	assert.Equal(t, "default", list.Keys[0].Key)
+	assert.Equal(t, "motd", list.Keys[1].Key)
+	assert.NotNil(t, list.Keys[1].UpdatedAt)
+
+	// Deleting needs an API key.
+	rec = do(http.MethodDelete, "/api/v1/messages/motd", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = do(http.MethodDelete, "/api/v1/messages/motd", "", APIKeyHeader, "secret")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = do(http.MethodDelete, "/api/v1/messages/motd", "", APIKeyHeader, "secret")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestKeyedMessageErrors(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Message.MaxKeys = 1
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		statusCode int
+	}{
+		{"invalid key", http.MethodGet, "/api/v1/messages/Not-Valid", "", http.StatusBadRequest},
+		{"invalid key on POST", http.MethodPost, "/api/v1/messages/a.b", `{"message":"x"}`, http.StatusBadRequest},
+		{"empty message", http.MethodPost, "/api/v1/messages/motd", `{"message":"  "}`, http.StatusBadRequest},
+		{"first key", http.MethodPost, "/api/v1/messages/motd", `{"message":"x"}`, http.StatusOK},
+		{"too many keys", http.MethodPost, "/api/v1/messages/footer", `{"message":"x"}`, http.StatusConflict},
+		{"replacing is allowed", http.MethodPost, "/api/v1/messages/motd", `{"message":"y"}`, http.StatusOK},
+		{"no legacy alias", http.MethodGet, "/messages", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			server.echo.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.statusCode, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestMessageRevisions(t *testing.T) {
+	server, _ := setupServer(t, nil)
+
+	post := func(target, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/message", nil))
+	assert.Equal(t, `"0"`, rec.Header().Get("ETag"))
+	assert.Contains(t, rec.Body.String(), `"revision":0`)
+
+	rec = post("/api/v1/message", `{"message":"One","revision":0}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+	assert.Contains(t, rec.Body.String(), `"revision":1`)
+
+	// Each format has its own ETag, and any of them names the revision.
+	rec = httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/message?format=text", nil))
+	assert.Equal(t, `"1-text"`, rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/message?format=yaml", nil))
+	assert.Equal(t, `"1-yaml"`, rec.Header().Get("ETag"))
+
+	rec = post("/api/v1/message", `{"message":"Two"}`, "If-Match", `"1-text"`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"revision":2`)
+
+	// A second dashboard still editing revision 1 gets the current message.
+	for _, tt := range []struct {
+		name   string
+		body   string
+		header []string
+	}{
+		{"stale body revision", `{"message":"Stale","revision":1}`, nil},
+		{"stale If-Match", `{"message":"Stale"}`, []string{"If-Match", `"1"`}},
+		{"foreign If-Match", `{"message":"Stale"}`, []string{"If-Match", `"abc"`}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := post("/api/v1/message", tt.body, tt.header...)
+			require.Equal(t, http.StatusConflict, rec.Code)
+			assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+			var res struct {
+				Error   string          `json:"error"`
+				Current MessageResponse `json:"current"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.NotEmpty(t, res.Error)
+			assert.Equal(t, "Two", res.Current.Message)
+			assert.Equal(t, int64(2), res.Current.Revision)
+		})
+	}
+
+	rec = post("/api/v1/message", `{"message":"Both","revision":2}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = post("/api/v1/message", `{"message":"Anyway"}`, "If-Match", "*")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"revision":3`)
+
+	// Named messages carry their own revision.
+	rec = post("/api/v1/messages/motd", `{"message":"Welcome","revision":0}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = post("/api/v1/messages/motd", `{"message":"Again","revision":0}`)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"key":"motd"`)
+}
+
+func TestMessageUndoRedo(t *testing.T) {
+	server, logger := setupServer(t, nil)
+	var logs bytes.Buffer
+	logger.SetOutput(&logs)
+
+	do := func(target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("/api/v1/message/undo", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.JSONEq(t, `{"error":"Nothing to undo"}`, rec.Body.String())
+	rec = do("/api/v1/message/redo", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	require.Equal(t, http.StatusOK, do("/api/v1/message", `{"message":"Oops"}`).Code)
+
+	rec = do("/api/v1/message/undo", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var res MessageResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "Hello, World!", res.Message)
+	assert.Equal(t, int64(2), res.Revision)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	rec = do("/api/v1/message/redo", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "Oops", res.Message)
+	assert.Equal(t, int64(3), res.Revision)
+
+	assert.Contains(t, logs.String(), `msg="Message undone" key=default revision=2`)
+	assert.Contains(t, logs.String(), `msg="Message redone" key=default revision=3`)
+}
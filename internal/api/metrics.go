@@ -0,0 +1,88 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v4"
+	"github.com/svanhalla/prompt-lab/greetd/internal/version"
+)
+
+// StatusCounters counts responses by status class (1xx through 5xx).
+type StatusCounters struct {
+	classes [5]atomic.Uint64
+}
+
+// Observe records a response with the given status code.
+func (s *StatusCounters) Observe(status int) {
+	if class := status / 100; class >= 1 && class <= 5 {
+		s.classes[class-1].Add(1)
+	}
+}
+
+// Count returns the number of responses in a status class, e.g. 2 for 2xx.
+func (s *StatusCounters) Count(class int) uint64 {
+	if class < 1 || class > 5 {
+		return 0
+	}
+	return s.classes[class-1].Load()
+}
+
+// Metrics exposes request counters, the idempotency key store and the
+// /hello cache in the Prometheus text format. The request counters include
+// requests excluded from the request log.
+func (h *Handlers) Metrics(c echo.Context) error {
+	return c.Blob(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(h.metricsText()))
+}
+
+// metricsText renders the metrics in the Prometheus text format.
+func (h *Handlers) metricsText() string {
+	var b strings.Builder
+	b.WriteString("# HELP greetd_start_time_seconds When the process started, in seconds since the Unix epoch.\n")
+	b.WriteString("# TYPE greetd_start_time_seconds gauge\n")
+	fmt.Fprintf(&b, "greetd_start_time_seconds %d\n", version.StartTime().Unix())
+	b.WriteString("# HELP greetd_uptime_seconds How long the process has been running.\n")
+	b.WriteString("# TYPE greetd_uptime_seconds gauge\n")
+	fmt.Fprintf(&b, "greetd_uptime_seconds %.3f\n", version.Uptime().Seconds())
+	b.WriteString("# HELP greetd_http_requests_total HTTP requests by response status class.\n")
+	b.WriteString("# TYPE greetd_http_requests_total counter\n")
+	for class := 1; class <= 5; class++ {
+		fmt.Fprintf(&b, "greetd_http_requests_total{class=\"%dxx\"} %d\n", class, h.requests.Count(class))
+	}
+
+	var keys int
+	var hits uint64
+	if h.idempotency != nil {
+		keys, hits = h.idempotency.Len(), h.idempotency.Hits()
+	}
+	b.WriteString("# HELP greetd_idempotency_keys Idempotency keys currently remembered.\n")
+	b.WriteString("# TYPE greetd_idempotency_keys gauge\n")
+	fmt.Fprintf(&b, "greetd_idempotency_keys %d\n", keys)
+	b.WriteString("# HELP greetd_idempotency_hits_total Responses replayed for a repeated idempotency key.\n")
+	b.WriteString("# TYPE greetd_idempotency_hits_total counter\n")
+	fmt.Fprintf(&b, "greetd_idempotency_hits_total %d\n", hits)
+	var cacheEntries int
+	var cacheHits, cacheMisses uint64
+	if h.helloCache != nil {
+		cacheEntries, cacheHits, cacheMisses = h.helloCache.len(), h.helloCache.hits.Load(), h.helloCache.misses.Load()
+	}
+	b.WriteString("# HELP greetd_hello_cache_entries Responses currently in the /hello cache.\n")
+	b.WriteString("# TYPE greetd_hello_cache_entries gauge\n")
+	fmt.Fprintf(&b, "greetd_hello_cache_entries %d\n", cacheEntries)
+	b.WriteString("# HELP greetd_hello_cache_hits_total /hello requests answered from the cache.\n")
+	b.WriteString("# TYPE greetd_hello_cache_hits_total counter\n")
+	fmt.Fprintf(&b, "greetd_hello_cache_hits_total %d\n", cacheHits)
+	b.WriteString("# HELP greetd_hello_cache_misses_total Cacheable /hello requests that had to render the greeting.\n")
+	b.WriteString("# TYPE greetd_hello_cache_misses_total counter\n")
+	fmt.Fprintf(&b, "greetd_hello_cache_misses_total %d\n", cacheMisses)
+	b.WriteString("# HELP greetd_panics_total Requests whose handler panicked.\n")
+	b.WriteString("# TYPE greetd_panics_total counter\n")
+	fmt.Fprintf(&b, "greetd_panics_total %d\n", h.panics.Load())
+	b.WriteString("# HELP greetd_http_slow_requests_total Requests that took longer than logging.slow_request_threshold.\n")
+	b.WriteString("# TYPE greetd_http_slow_requests_total counter\n")
+	fmt.Fprintf(&b, "greetd_http_slow_requests_total %d\n", h.slowRequests.Load())
+
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+// APIKeyHeader is the request header carrying an API key.
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyName identifies the configured key a request carries without
+// revealing it, as "api-key:" and the start of the key's SHA-256, or
+// returns "" when the request has no valid key.
+func apiKeyName(keys []string, r *http.Request) string {
+	provided := requestAPIKey(r)
+	if provided == "" {
+		return ""
+	}
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(key)) == 1 {
+			return config.KeyFingerprint(key)
+		}
+	}
+	return ""
+}
+
+// requestAPIKey extracts the key from X-API-Key or an Authorization bearer token.
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+
+	auth := r.Header.Get(echo.HeaderAuthorization)
+	if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+
+	return ""
+}
+
+const (
+	// csrfField is the form field and cookie carrying the CSRF token.
+	csrfField = "_csrf"
+	// csrfContextKey is where the middleware stores the token for templates.
+	csrfContextKey = "csrf"
+)
+
+// CSRF protects the HTML form flow; cookiePath scopes the token cookie to
+// the UI. The JSON API relies on API-key auth instead and must not be routed
+// through it. onError renders the response for a request whose token is
+// missing or invalid.
+func CSRF(cookiePath string, onError middleware.CSRFErrorHandler) echo.MiddlewareFunc {
+	return middleware.CSRFWithConfig(middleware.CSRFConfig{
+		TokenLookup:    "form:" + csrfField,
+		ContextKey:     csrfContextKey,
+		CookieName:     csrfField,
+		CookiePath:     cookiePath,
+		CookieHTTPOnly: true,
+		CookieSameSite: http.SameSiteStrictMode,
+		ErrorHandler:   onError,
+	})
+}
+
+// Deprecated marks responses from legacy unversioned routes and points
+// clients at the equivalent route under prefix. basePath is the prefix the
+// whole server is mounted under, if any.
+func Deprecated(basePath, prefix string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			successor := basePath + prefix + strings.TrimPrefix(c.Request().URL.Path, basePath)
+
+			header := c.Response().Header()
+			header.Set("Deprecation", "true")
+			header.Set("Link", "<"+successor+`>; rel="successor-version"`)
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	formatJSON = "json"
+	formatText = "text"
+	formatYAML = "yaml"
+
+	mimeYAML        = "application/yaml"
+	mimeProblemJSON = "application/problem+json"
+	mimeHTML        = "text/html; charset=utf-8"
+)
+
+// mediaRange is a single entry of an Accept header.
+type mediaRange struct {
+	mimeType string
+	q        float64
+}
+
+// parseAccept splits an Accept header into media ranges ordered by
+// preference. Entries with equal q-values keep their original order.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mimeType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mimeType == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(key) != "q" {
+				continue
+			}
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		ranges = append(ranges, mediaRange{mimeType: mimeType, q: q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	return ranges
+}
+
+// responseFormat picks the representation for a content endpoint. The
+// ?format= query parameter wins over the Accept header; anything unknown
+// falls back to JSON.
+func responseFormat(c echo.Context) string {
+	switch strings.ToLower(c.QueryParam("format")) {
+	case formatJSON:
+		return formatJSON
+	case formatText, "txt", "plain":
+		return formatText
+	case formatYAML, "yml":
+		return formatYAML
+	}
+
+	for _, r := range parseAccept(c.Request().Header.Get(echo.HeaderAccept)) {
+		switch r.mimeType {
+		case echo.MIMEApplicationJSON, "*/*", "application/*":
+			return formatJSON
+		case echo.MIMETextPlain, "text/*":
+			return formatText
+		case mimeYAML, "application/x-yaml", "text/yaml", "text/x-yaml":
+			return formatYAML
+		}
+	}
+
+	return formatJSON
+}
+
+// isJSONType reports whether mimeType is JSON or a JSON-based type such as
+// application/vnd.api+json or application/problem+json.
+func isJSONType(mimeType string) bool {
+	return mimeType == "application/*" ||
+		(strings.HasPrefix(mimeType, "application/") && strings.Contains(mimeType, "json"))
+}
+
+// wantsHTML reports whether the client explicitly prefers an HTML page to a
+// JSON body. Browsers rank text/html above everything else; curl and API
+// clients send JSON types or bare wildcards and get JSON.
+func wantsHTML(c echo.Context) bool {
+	contentType := strings.ToLower(c.Request().Header.Get(echo.HeaderContentType))
+	if isJSONType(strings.TrimSpace(strings.Split(contentType, ";")[0])) {
+		return false
+	}
+
+	var htmlQ, jsonQ float64
+	for _, r := range parseAccept(c.Request().Header.Get(echo.HeaderAccept)) {
+		switch {
+		case r.mimeType == echo.MIMETextHTML || r.mimeType == "application/xhtml+xml":
+			htmlQ = max(htmlQ, r.q)
+		case isJSONType(r.mimeType):
+			jsonQ = max(jsonQ, r.q)
+		}
+	}
+
+	return htmlQ > jsonQ
+}
+
+// negotiate writes v in the representation the client asked for. text is the
+// bare string used for text/plain responses.
+func negotiate(c echo.Context, status int, v interface{}, text string) error {
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+
+	switch responseFormat(c) {
+	case formatText:
+		return c.String(status, text)
+	case formatYAML:
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to encode YAML"})
+		}
+		return c.Blob(status, mimeYAML, data)
+	default:
+		return c.JSON(status, v)
+	}
+}
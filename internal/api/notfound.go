@@ -0,0 +1,249 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// maxSuggestions is the number of "did you mean" routes offered.
+	maxSuggestions = 3
+	// maxSuggestPathLen bounds the edit distance work per request; longer
+	// paths get no suggestions.
+	maxSuggestPathLen = 128
+)
+
+// routeCategories are the groups of the 404 route listing, in order.
+var routeCategories = []string{"API", "UI", "Docs", "Admin"}
+
+// uiRoutes are the routes listed under "UI"; routes not under /admin,
+// /debug, /swagger or /docs are otherwise listed under "API".
+var uiRoutes = map[string]bool{
+	"/":            true,
+	"/ui":          true,
+	"/ui/message":  true,
+	"/ui/draft":    true,
+	"/logs":        true,
+	"/logs/stream": true,
+	"/status":      true,
+	"/favicon.ico": true,
+}
+
+// unlinkedRoutes are GET routes the 404 page does not link: the log stream
+// never finishes loading, and the draft is usually not there.
+var unlinkedRoutes = map[string]bool{
+	"/logs/stream":               true,
+	"/message/draft":             true,
+	APIPrefix + "/message/draft": true,
+}
+
+// routeInfo is a registered path and the methods it answers.
+type routeInfo struct {
+	Path    string
+	Methods []string
+}
+
+// Linkable reports whether the route can be opened in a browser.
+func (r routeInfo) Linkable() bool {
+	return r.Methods[0] == http.MethodGet && !strings.Contains(r.Path, ":") && !unlinkedRoutes[r.Path]
+}
+
+// MethodList returns the methods separated by commas, e.g. "GET, POST".
+func (r routeInfo) MethodList() string {
+	return strings.Join(r.Methods, ", ")
+}
+
+// adminRoute reports whether path is an admin or profiling route, which are
+// never suggested for a mistyped path.
+func adminRoute(path string) bool {
+	return strings.HasPrefix(path, "/admin/") || strings.HasPrefix(path, "/debug/")
+}
+
+// routeGroup is a category of the 404 route listing.
+type routeGroup struct {
+	Name   string
+	Routes []routeInfo
+}
+
+// knownRoutes lists the routes registered on e below basePath, relative to
+// it and sorted by path. Wildcard routes are left out since they cannot be
+// suggested as they are.
+func knownRoutes(e *echo.Echo, basePath string) []routeInfo {
+	methods := make(map[string][]string)
+	for _, r := range e.Routes() {
+		if strings.Contains(r.Path, "*") || !strings.HasPrefix(r.Path, basePath) {
+			continue
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			continue
+		}
+		path := strings.TrimPrefix(r.Path, basePath)
+		if path == "" {
+			path = "/"
+		}
+		methods[path] = append(methods[path], r.Method)
+	}
+
+	routes := make([]routeInfo, 0, len(methods))
+	for path, m := range methods {
+		sort.Slice(m, func(i, j int) bool { return methodOrder(m[i]) < methodOrder(m[j]) })
+		routes = append(routes, routeInfo{Path: path, Methods: compactStrings(m)})
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Path < routes[j].Path })
+	return routes
+}
+
+func methodOrder(method string) int {
+	for i, m := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		if m == method {
+			return i
+		}
+	}
+	return len(method)
+}
+
+// compactStrings removes adjacent duplicates from a sorted slice.
+func compactStrings(s []string) []string {
+	out := s[:0]
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// groupRoutes sorts routes into routeCategories, leaving out empty ones.
+func groupRoutes(routes []routeInfo) []routeGroup {
+	byCategory := make(map[string][]routeInfo)
+	for _, r := range routes {
+		category := "API"
+		switch {
+		case uiRoutes[r.Path]:
+			category = "UI"
+		case strings.HasPrefix(r.Path, "/swagger/") || r.Path == "/docs":
+			category = "Docs"
+		case adminRoute(r.Path):
+			category = "Admin"
+		}
+		byCategory[category] = append(byCategory[category], r)
+	}
+
+	var groups []routeGroup
+	for _, name := range routeCategories {
+		if len(byCategory[name]) > 0 {
+			groups = append(groups, routeGroup{Name: name, Routes: byCategory[name]})
+		}
+	}
+	return groups
+}
+
+// suggestRoutes returns up to maxSuggestions routes close to the requested
+// path: the routes it is a suffix of, such as /api/v1/message for /message,
+// or else the routes at the smallest edit distance within a limit. Admin
+// and profiling routes are left out.
+func suggestRoutes(path string, routes []routeInfo) []string {
+	path = strings.ToLower(strings.TrimSuffix(path, "/"))
+	if path == "" || len(path) > maxSuggestPathLen {
+		return nil
+	}
+
+	type candidate struct {
+		path     string
+		distance int
+	}
+	limit := max(2, len(path)/3)
+
+	var candidates []candidate
+	for _, r := range routes {
+		route := strings.ToLower(r.Path)
+		if route == "/" || adminRoute(route) {
+			continue
+		}
+		if strings.HasSuffix(route, path) {
+			candidates = append(candidates, candidate{r.Path, 0})
+			continue
+		}
+		if d := editDistance(path, route, limit); d <= limit {
+			candidates = append(candidates, candidate{r.Path, d})
+		}
+	}
+
+	// Only the closest matches are suggested.
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+	var suggestions []string
+	for _, c := range candidates {
+		if len(suggestions) == maxSuggestions || c.distance > candidates[0].distance {
+			break
+		}
+		suggestions = append(suggestions, c.path)
+	}
+	return suggestions
+}
+
+// editDistance returns the Levenshtein distance between a and b, or a value
+// above limit as soon as the distance is known to exceed it.
+func editDistance(a, b string, limit int) int {
+	if diff := len(a) - len(b); diff > limit || -diff > limit {
+		return limit + 1
+	}
+
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// NotFound answers requests for unknown paths with suggestions of similar
+// routes: application/problem+json with a "suggestions" member for API
+// clients, or the 404 page listing every route for browsers.
+func (h *Handlers) NotFound(c echo.Context) error {
+	routes := knownRoutes(c.Echo(), h.basePath)
+	suggestions := suggestRoutes(strings.TrimPrefix(c.Request().URL.Path, h.basePath), routes)
+
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	if !wantsHTML(c) {
+		problem := ProblemDetails{
+			Type:   "about:blank",
+			Title:  http.StatusText(http.StatusNotFound),
+			Status: http.StatusNotFound,
+			Detail: "The requested endpoint does not exist",
+		}
+		for _, s := range suggestions {
+			problem.Suggestions = append(problem.Suggestions, h.basePath+s)
+		}
+		return h.problemJSON(c, problem)
+	}
+
+	data := struct {
+		Suggestions []string
+		Groups      []routeGroup
+	}{
+		Suggestions: suggestions,
+		Groups:      groupRoutes(routes),
+	}
+	return h.render(c, http.StatusNotFound, h.templates.GetNotFound, data)
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http/pprof"
+
+	"github.com/labstack/echo/v4"
+)
+
+// PprofPrefix is where the profiling endpoints are mounted when
+// server.enable_pprof is set.
+const PprofPrefix = "/debug/pprof"
+
+// Pprof serves the net/http/pprof handlers: the index for the bare prefix,
+// and a named profile such as "heap" or "goroutine" otherwise.
+func (h *Handlers) Pprof(c echo.Context) error {
+	res, req := c.Response(), c.Request()
+
+	switch name := c.Param("*"); name {
+	case "":
+		pprof.Index(res, req)
+	case "cmdline":
+		pprof.Cmdline(res, req)
+	case "profile":
+		pprof.Profile(res, req)
+	case "symbol":
+		pprof.Symbol(res, req)
+	case "trace":
+		pprof.Trace(res, req)
+	default:
+		pprof.Handler(name).ServeHTTP(res, req)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+func TestPprofDisabledByDefault(t *testing.T) {
+	server, _ := setupAdminServer(t, []string{"secret"}, false)
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/pprof/heap", nil)
+	req.Header.Set(APIKeyHeader, "secret")
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/health?verbose=1", nil))
+	var health HealthResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &health))
+	require.NotNil(t, health.Details)
+	assert.False(t, health.Details.PprofEnabled)
+}
+
+func TestPprofEnabled(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Server.EnablePprof = true
+		cfg.Server.BasePath = "/greetd"
+		cfg.Security.APIKeys = []string{"secret"}
+	})
+
+	get := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get("/greetd/debug/pprof/heap", "").Code)
+
+	rec := get("/greetd/debug/pprof/heap", "secret")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEmpty(t, rec.Body.Bytes())
+
+	rec = get("/greetd/debug/pprof/goroutine?debug=1", "secret")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "goroutine profile")
+
+	rec = get("/greetd/debug/pprof/", "secret")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "heap")
+
+	assert.Equal(t, http.StatusNotFound, get("/greetd/debug/pprof/nonsense", "secret").Code)
+
+	// Profiling requests are not counted in the metrics, even failed ones.
+	assert.Zero(t, server.handlers.requests.Count(2))
+	assert.Zero(t, server.handlers.requests.Count(4))
+
+	var health HealthResponse
+	require.NoError(t, json.Unmarshal(get("/greetd/api/v1/health?verbose=true", "").Body.Bytes(), &health))
+	require.NotNil(t, health.Details)
+	assert.True(t, health.Details.PprofEnabled)
+
+	var brief HealthResponse
+	require.NoError(t, json.Unmarshal(get("/greetd/api/v1/health", "").Body.Bytes(), &brief))
+	assert.Nil(t, brief.Details)
+}
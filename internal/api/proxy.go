@@ -0,0 +1,144 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// NewIPExtractor returns an IPExtractor that only honors X-Forwarded-For and
+// X-Real-IP when the immediate peer is within one of the trusted proxy ranges.
+// With no trusted proxies the forwarding headers are ignored entirely.
+func NewIPExtractor(trustedProxies []string) (echo.IPExtractor, error) {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+
+	// Echo trusts loopback and private ranges by default; only trust what is configured.
+	opts := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+
+	for _, proxy := range trustedProxies {
+		ipNet, err := parseCIDR(proxy)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, echo.TrustIPRange(ipNet))
+	}
+
+	fromXFF := echo.ExtractIPFromXFFHeader(opts...)
+	fromRealIP := echo.ExtractIPFromRealIPHeader(opts...)
+
+	return func(r *http.Request) string {
+		if r.Header.Get(echo.HeaderXForwardedFor) == "" && r.Header.Get(echo.HeaderXRealIP) != "" {
+			return fromRealIP(r)
+		}
+		return fromXFF(r)
+	}, nil
+}
+
+// newProxyTrust returns a function reporting whether a request arrived
+// directly from one of trustedProxies, so its X-Forwarded-* headers can be
+// believed. Without trusted proxies no request is trusted.
+func newProxyTrust(trustedProxies []string) (func(*http.Request) bool, error) {
+	nets, err := parseCIDRs(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(r *http.Request) bool {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return containsIP(nets, net.ParseIP(host))
+	}, nil
+}
+
+// externalURL returns the scheme and host a client used to reach the
+// server, such as "https://greetd.example.com". X-Forwarded-Proto and
+// X-Forwarded-Host are only honored when trusted reports the request came
+// from a trusted proxy.
+func externalURL(r *http.Request, trusted func(*http.Request) bool) string {
+	scheme, host := clientOrigin(r, trusted)
+	return scheme + "://" + host
+}
+
+// clientOrigin is externalURL as its scheme and host.
+func clientOrigin(r *http.Request, trusted func(*http.Request) bool) (scheme, host string) {
+	scheme, host = "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if trusted != nil && trusted(r) {
+		// Each proxy may append to the headers; the first value is the
+		// one the client sent to the outermost proxy.
+		first := func(header string) string {
+			value, _, _ := strings.Cut(r.Header.Get(header), ",")
+			return strings.TrimSpace(value)
+		}
+		if proto := strings.ToLower(first(echo.HeaderXForwardedProto)); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwarded := first("X-Forwarded-Host"); forwarded != "" {
+			host = forwarded
+		}
+	}
+	return scheme, host
+}
+
+// parseCIDRs parses each of values with parseCIDR.
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		ipNet, err := parseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// containsIP reports whether ip is within one of nets. A nil ip is in none.
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCIDR accepts either CIDR notation or a bare IP address.
+func parseCIDR(value string) (*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid address %q", value)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %w", value, err)
+	}
+	return ipNet, nil
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPExtractor(t *testing.T) {
+	tests := []struct {
+		name       string
+		trusted    []string
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{
+			name:       "no trusted proxies ignores XFF",
+			remoteAddr: "10.0.0.5:1234",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4"},
+			expected:   "10.0.0.5",
+		},
+		{
+			name:       "spoofed XFF from untrusted peer",
+			trusted:    []string{"10.0.0.0/8"},
+			remoteAddr: "203.0.113.9:1234",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4"},
+			expected:   "203.0.113.9",
+		},
+		{
+			name:       "spoofed X-Real-IP from untrusted peer",
+			trusted:    []string{"10.0.0.0/8"},
+			remoteAddr: "203.0.113.9:1234",
+			headers:    map[string]string{"X-Real-IP": "1.2.3.4"},
+			expected:   "203.0.113.9",
+		},
+		{
+			name:       "chain through trusted proxies",
+			trusted:    []string{"10.0.0.0/8", "192.168.1.1"},
+			remoteAddr: "10.0.0.5:1234",
+			headers:    map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.7, 192.168.1.1"},
+			expected:   "198.51.100.7",
+		},
+		{
+			name:       "X-Real-IP from trusted peer",
+			trusted:    []string{"10.0.0.0/8"},
+			remoteAddr: "10.0.0.5:1234",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.7"},
+			expected:   "198.51.100.7",
+		},
+		{
+			name:       "IPv6 trusted proxy",
+			trusted:    []string{"fd00::/8"},
+			remoteAddr: "[fd00::1]:1234",
+			headers:    map[string]string{"X-Forwarded-For": "2001:db8::7"},
+			expected:   "2001:db8::7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extractor, err := NewIPExtractor(tt.trusted)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			assert.Equal(t, tt.expected, extractor(req))
+		})
+	}
+}
+
+func TestIPExtractorInvalidCIDR(t *testing.T) {
+	_, err := NewIPExtractor([]string{"not-a-cidr"})
+	assert.Error(t, err)
+
+	_, err = NewIPExtractor([]string{"10.0.0.0/99"})
+	assert.Error(t, err)
+}
+
+func TestRequestLoggerFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+
+	extractor, err := NewIPExtractor([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.IPExtractor = extractor
+	e.Use(RequestLogger(logger, RequestLogOptions{SampleRate: 1}))
+	e.GET("/hello", func(c echo.Context) error {
+		return c.String(http.StatusOK, "hi")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.RemoteAddr = "10.1.2.3:5555"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	req.Header.Set("User-Agent", "test-agent/1.0")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+
+	assert.Equal(t, "198.51.100.7", entry["remote_ip"])
+	assert.Equal(t, "test-agent/1.0", entry["user_agent"])
+	assert.Equal(t, float64(2), entry["bytes_out"])
+}
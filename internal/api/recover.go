@@ -0,0 +1,141 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/svanhalla/prompt-lab/greetd/internal/version"
+)
+
+// maxStackFrames bounds the stack trace logged for a panic.
+const maxStackFrames = 64
+
+// Recover answers a request whose handler panics with the standard 500
+// problem response instead of dropping the connection. The panic is logged
+// at error level with a stack trace of the handler, the request and its
+// request ID, counted in greetd_panics_total, and written to a crash report
+// in the state directory when logging.crash_dumps is on. A panic with
+// http.ErrAbortHandler is passed on, since it aborts the response on
+// purpose.
+func (h *Handlers) Recover(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			p := recoveredPanic(value)
+			if p.value == http.ErrAbortHandler {
+				panic(p.value)
+			}
+
+			h.panics.Add(1)
+			req := c.Request()
+			entry := h.logger.WithFields(logrus.Fields{
+				"panic":      fmt.Sprint(p.value),
+				"stack":      p.stack,
+				"method":     req.Method,
+				"path":       req.URL.Path,
+				"request_id": c.Response().Header().Get(echo.HeaderXRequestID),
+			})
+			if h.crashDir != "" {
+				path, dumpErr := writeCrashReport(h.crashDir, c, p, h.redact)
+				if dumpErr != nil {
+					h.logger.WithError(dumpErr).Warn("Failed to write crash report")
+				} else {
+					entry = entry.WithField("crash_report", path)
+				}
+			}
+			entry.Error("Handler panicked")
+
+			// The error is passed on for the request log; the error
+			// handler leaves the committed response alone.
+			err = fmt.Errorf("panic: %v", p.value)
+			if !c.Response().Committed {
+				if respErr := h.internalError(c, http.StatusInternalServerError, err, p.stack); respErr != nil {
+					err = respErr
+				}
+			}
+		}()
+		return next(c)
+	}
+}
+
+// handlerPanic is a recovered panic value with the stack of the handler
+// that raised it.
+type handlerPanic struct {
+	value interface{}
+	stack string
+}
+
+// recoveredPanic wraps a value just recovered from a panic with the current
+// stack, unless it already carries the stack of a panic raised again from
+// another goroutine.
+func recoveredPanic(value interface{}) *handlerPanic {
+	if p, ok := value.(*handlerPanic); ok {
+		return p
+	}
+	return &handlerPanic{value: value, stack: panicStack()}
+}
+
+// panicStack returns the stack of the goroutine that panicked, one
+// "function file:line" per line, without the frames of the runtime's panic
+// handling and this middleware, and ending before net/http, which only
+// shows how the request got to the handler.
+func panicStack() string {
+	pcs := make([]uintptr, maxStackFrames)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(1, pcs)])
+
+	var lines []string
+	panicking := false
+	for {
+		frame, more := frames.Next()
+		switch {
+		case frame.Function == "runtime.gopanic":
+			// Everything up to here was recovering.
+			lines, panicking = nil, true
+		case strings.HasPrefix(frame.Function, "net/http."):
+			more = false
+		case !panicking || strings.HasPrefix(frame.Function, "runtime."):
+		default:
+			lines = append(lines, fmt.Sprintf("%s %s:%d", frame.Function, frame.File, frame.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// writeCrashReport writes the panic, the request and the stacks of the
+// handler and of all goroutines to crash-<timestamp>.txt in dir, and
+// returns its path. The request URI is redacted by redact.
+func writeCrashReport(dir string, c echo.Context, p *handlerPanic, redact *Redactor) (string, error) {
+	now := time.Now().UTC()
+	req := c.Request()
+
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "time: %s\n", now.Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "version: %s\n", version.Get().Version)
+	fmt.Fprintf(&b, "request_id: %s\n", c.Response().Header().Get(echo.HeaderXRequestID))
+	fmt.Fprintf(&b, "request: %s %s\n", req.Method, redact.URI(req.RequestURI))
+	fmt.Fprintf(&b, "panic: %v\n\n", p.value)
+	fmt.Fprintf(&b, "%s\n\n", p.stack)
+	b.Write(buf)
+
+	path := filepath.Join(dir, "crash-"+now.Format("20060102T150405.000000000Z")+".txt")
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
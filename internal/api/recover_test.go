@@ -0,0 +1,91 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+func TestRecover(t *testing.T) {
+	server, logger := setupServer(t, func(cfg *config.Config) { cfg.Logging.CrashDumps = true })
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	server.echo.GET("/explode", func(c echo.Context) error {
+		panic("kaboom")
+	})
+
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/explode", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, mimeProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	var problem ProblemDetails
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, http.StatusInternalServerError, problem.Status)
+	assert.Equal(t, "An unexpected error occurred", problem.Detail)
+	assert.NotContains(t, rec.Body.String(), "kaboom")
+
+	var entry map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var e map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &e))
+		if e["msg"] == "Handler panicked" {
+			entry = e
+		}
+	}
+	require.NotNil(t, entry, "panic not logged: %s", buf.String())
+	assert.Equal(t, "error", entry["level"])
+	assert.Equal(t, "kaboom", entry["panic"])
+	assert.Equal(t, http.MethodGet, entry["method"])
+	assert.Equal(t, "/explode", entry["path"])
+	assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), entry["request_id"])
+	assert.NotEmpty(t, entry["request_id"])
+	stack := entry["stack"].(string)
+	// The trace starts at the handler.
+	assert.Contains(t, strings.SplitN(stack, "\n", 2)[0], "recover_test.go", stack)
+	assert.NotContains(t, stack, "runtime.gopanic")
+	assert.NotContains(t, stack, "panicStack")
+
+	// The crash report holds the request and the stacks of all goroutines.
+	reports, err := filepath.Glob(filepath.Join(server.config.StateDir(), "crash-*.txt"))
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, reports[0], entry["crash_report"])
+	report, err := os.ReadFile(reports[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(report), "request: GET /explode")
+	assert.Contains(t, string(report), "panic: kaboom")
+	assert.Contains(t, string(report), "goroutine ")
+
+	rec = httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "greetd_panics_total 1\n")
+	assert.Contains(t, rec.Body.String(), `greetd_http_requests_total{class="5xx"} 1`)
+}
+
+func TestRecoverWithoutCrashDumps(t *testing.T) {
+	server, _ := setupServer(t, nil)
+	server.echo.GET("/explode", func(c echo.Context) error {
+		panic("kaboom")
+	})
+
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/explode", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	reports, err := filepath.Glob(filepath.Join(server.config.StateDir(), "crash-*.txt"))
+	require.NoError(t, err)
+	assert.Empty(t, reports)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// redactedValue is logged in place of credentials and redacted query
+// parameter values.
+const redactedValue = "[redacted]"
+
+// redactedHeaders are never logged with their values.
+var redactedHeaders = []string{echo.HeaderAuthorization, "X-API-Key", echo.HeaderCookie, echo.HeaderSetCookie}
+
+// Redactor hides personal data in the URIs of logged requests: the values
+// of the query parameters it was made with. The request itself is left
+// alone. A nil Redactor hides nothing.
+type Redactor struct {
+	params map[string]bool
+}
+
+// NewRedactor returns a Redactor for the query parameters named params,
+// matched regardless of case.
+func NewRedactor(params []string) *Redactor {
+	r := &Redactor{params: make(map[string]bool, len(params))}
+	for _, param := range params {
+		r.params[strings.ToLower(param)] = true
+	}
+	return r
+}
+
+// URI returns uri, a request URI or a URL such as a Referer, with the
+// values of the redacted query parameters replaced.
+func (r *Redactor) URI(uri string) string {
+	path, query, ok := strings.Cut(uri, "?")
+	if !ok {
+		return uri
+	}
+	return path + "?" + r.Query(query)
+}
+
+// Query returns the raw query string with the values of the redacted
+// parameters replaced, keeping the rest as sent.
+func (r *Redactor) Query(rawQuery string) string {
+	if !r.Hides(rawQuery) {
+		return rawQuery
+	}
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		if key, ok := r.hidden(pair); ok {
+			pairs[i] = key + "=" + redactedValue
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// Hides reports whether the raw query string has a value to redact, which
+// responses may repeat, such as the greeting of /hello?name=.
+func (r *Redactor) Hides(rawQuery string) bool {
+	if r == nil || len(r.params) == 0 || rawQuery == "" {
+		return false
+	}
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if _, ok := r.hidden(pair); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hidden returns the key of a key=value pair whose value is redacted.
+func (r *Redactor) hidden(pair string) (string, bool) {
+	key, _, hasValue := strings.Cut(pair, "=")
+	name, err := url.QueryUnescape(key)
+	if err != nil {
+		name = key
+	}
+	return key, hasValue && r.params[strings.ToLower(name)]
+}
+
+// Headers returns header as one value per name, with credentials replaced
+// and the query of the Referer redacted.
+func (r *Redactor) Headers(header http.Header) map[string]string {
+	res := make(map[string]string, len(header))
+	for name, values := range header {
+		res[name] = strings.Join(values, ", ")
+	}
+	for _, name := range redactedHeaders {
+		if _, ok := res[http.CanonicalHeaderKey(name)]; ok {
+			res[http.CanonicalHeaderKey(name)] = redactedValue
+		}
+	}
+	if referer, ok := res["Referer"]; ok {
+		res["Referer"] = r.URI(referer)
+	}
+	return res
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+func TestRedactor(t *testing.T) {
+	redact := NewRedactor([]string{"name", "Token"})
+
+	tests := []struct {
+		uri, want string
+	}{
+		{"/hello", "/hello"},
+		{"/hello?name=Alice", "/hello?name=[redacted]"},
+		{"/hello?NAME=Alice&lang=sv", "/hello?NAME=[redacted]&lang=sv"},
+		{"/hello?lang=sv&name=Alice%20Smith&name=Bob", "/hello?lang=sv&name=[redacted]&name=[redacted]"},
+		{"/hello?n%61me=Alice&token=abc", "/hello?n%61me=[redacted]&token=[redacted]"},
+		{"/hello?name&names=Alice", "/hello?name&names=Alice"},
+		{"https://greetd.example.com/ui?name=Alice", "https://greetd.example.com/ui?name=[redacted]"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, redact.URI(tt.uri), tt.uri)
+	}
+
+	assert.True(t, redact.Hides("lang=sv&name=Alice"))
+	assert.False(t, redact.Hides("lang=sv&name"))
+
+	headers := redact.Headers(http.Header{
+		"Authorization": {"Bearer s3cr3t-key"},
+		"Referer":       {"https://greetd.example.com/ui?name=Alice"},
+		"Accept":        {"application/json"},
+	})
+	assert.Equal(t, map[string]string{
+		"Authorization": redactedValue,
+		"Referer":       "https://greetd.example.com/ui?name=[redacted]",
+		"Accept":        "application/json",
+	}, headers)
+
+	var none *Redactor
+	assert.Equal(t, "/hello?name=Alice", none.URI("/hello?name=Alice"))
+	assert.Equal(t, "/hello?name=Alice", NewRedactor(nil).URI("/hello?name=Alice"))
+}
+
+func TestRedaction(t *testing.T) {
+	const secretName = "Alice Secret"
+	secrets := []string{"Alice", "s3cr3t-key", "session=abc123"}
+
+	request := func(server *Server, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set(APIKeyHeader, "s3cr3t-key")
+		req.Header.Set(echo.HeaderCookie, "session=abc123")
+		req.Header.Set("Referer", "https://greetd.example.com/ui?name=Alice+Secret")
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, format := range []string{"combined", "json"} {
+		t.Run("access log "+format, func(t *testing.T) {
+			server, logger := setupServer(t, func(cfg *config.Config) {
+				cfg.Logging.AccessLog.Format = format
+			})
+			logger.SetOutput(&bytes.Buffer{})
+			defer server.accessLog.Close()
+
+			// The handler still sees the name.
+			rec := request(server, "/api/v1/hello?name=Alice%20Secret")
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Body.String(), secretName)
+
+			data, err := os.ReadFile(server.config.AccessLogPath())
+			require.NoError(t, err)
+			assert.Contains(t, string(data), "/api/v1/hello?name=[redacted]")
+			for _, secret := range secrets {
+				assert.NotContains(t, string(data), secret)
+			}
+
+			rec = request(server, "/logs?source=access")
+			assert.Contains(t, rec.Body.String(), "name=[redacted]")
+			assert.NotContains(t, rec.Body.String(), "Alice")
+		})
+	}
+
+	t.Run("application log", func(t *testing.T) {
+		var appLog bytes.Buffer
+		server, logger := setupServer(t, func(cfg *config.Config) {
+			cfg.Logging.AccessLog.File = ""
+			cfg.Logging.DumpBodies = true
+			cfg.Logging.CrashDumps = true
+			cfg.Security.AllowCIDRs = []string{"10.0.0.0/8"}
+			cfg.Security.APIKeys = []string{"s3cr3t-key"}
+		})
+		logger.SetOutput(&appLog)
+		logger.SetFormatter(&logrus.JSONFormatter{})
+		// The query and the body dump are only logged at debug level.
+		logger.SetLevel(logrus.DebugLevel)
+		server.echo.GET("/explode", func(c echo.Context) error {
+			panic("kaboom")
+		})
+
+		rec := request(server, "/api/v1/hello?name=Alice%20Secret")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), secretName)
+		request(server, "/explode?name=Alice")
+		request(server, "/admin/loglevel?name=Alice")
+
+		assert.Contains(t, appLog.String(), `"query":"name=[redacted]"`)
+		assert.Contains(t, appLog.String(), `"uri":"/api/v1/hello?name=[redacted]"`)
+		assert.Contains(t, appLog.String(), `"X-Api-Key":"[redacted]"`)
+		assert.Contains(t, appLog.String(), "Request denied by security.allow_cidrs")
+		for _, secret := range secrets {
+			assert.NotContains(t, appLog.String(), secret)
+		}
+
+		reports, err := filepath.Glob(filepath.Join(server.config.StateDir(), "crash-*.txt"))
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		report, err := os.ReadFile(reports[0])
+		require.NoError(t, err)
+		assert.Contains(t, string(report), "request: GET /explode?name=[redacted]")
+		assert.NotContains(t, string(report), "Alice")
+
+		// The recent errors keep the URI of the panic.
+		req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+		req.RemoteAddr = "10.1.2.3:1234"
+		req.Header.Set(APIKeyHeader, "s3cr3t-key")
+		rec = httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"uri":"/explode?name=[redacted]"`)
+		assert.NotContains(t, rec.Body.String(), "Alice")
+
+		// The recent logs come from the same entries.
+		for _, target := range []string{"/api/v1/logs?lines=500", "/logs?lines=500"} {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.RemoteAddr = "10.1.2.3:1234"
+			rec := httptest.NewRecorder()
+			server.echo.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code, target)
+			assert.Contains(t, rec.Body.String(), "name=[redacted]", target)
+			assert.NotContains(t, rec.Body.String(), "Alice", target)
+		}
+	})
+}
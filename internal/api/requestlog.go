@@ -0,0 +1,287 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestLogOptions controls which requests RequestLogger writes to the log.
+// Requests answered with 4xx or 5xx, and slow ones, are always logged.
+type RequestLogOptions struct {
+	// SkipPaths are glob patterns (path.Match syntax, e.g. "/static/*") for
+	// requests that are not logged. They are matched relative to BasePath,
+	// both with and without the /api/v1 prefix.
+	SkipPaths []string
+	// ExcludePrefixes are path prefixes, relative to BasePath, of requests
+	// that are neither logged nor counted, such as the profiling endpoints.
+	ExcludePrefixes []string
+	// SampleRate is the fraction of remaining successful requests to log,
+	// between 0 and 1.
+	SampleRate float64
+	// BasePath is the prefix the server is mounted under, if any.
+	BasePath string
+	// Counters, when set, counts every request including unlogged ones.
+	Counters *StatusCounters
+	// Stats, when set, collects per-route stats of every request including
+	// unlogged ones.
+	Stats *RequestStats
+	// Access, when set, receives request entries in AccessFormat instead of
+	// the application log.
+	Access io.Writer
+	// AccessFormat is "combined", "common" or "json".
+	AccessFormat string
+	// Format is how entries in the application log are written: "json",
+	// "text", or "common" for one Apache common log format line per
+	// request. Empty uses the logger's formatter.
+	Format string
+	// SlowThreshold, when positive, is how long a request may take before
+	// it is logged at warn level with slow=true, even with an access log.
+	SlowThreshold time.Duration
+	// SlowRequests, when set, counts the slow requests.
+	SlowRequests *atomic.Uint64
+	// LongRunning reports requests that are expected to take long, such as
+	// the log stream, and are never slow.
+	LongRunning func(c echo.Context) bool
+	// Redact hides query parameter values in logged URIs, queries and
+	// referers.
+	Redact *Redactor
+}
+
+func RequestLogger(logger *logrus.Logger, opts RequestLogOptions) echo.MiddlewareFunc {
+	requests := requestLogger(logger, opts.Format)
+	return middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
+		Skipper: func(c echo.Context) bool {
+			return opts.excluded(c.Request().URL.Path)
+		},
+		LogURI:          true,
+		LogStatus:       true,
+		LogMethod:       true,
+		LogLatency:      true,
+		LogRemoteIP:     true,
+		LogUserAgent:    true,
+		LogResponseSize: true,
+		LogReferer:      true,
+		LogProtocol:     true,
+		LogRequestID:    true,
+		LogError:        true,
+		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
+			// Redacted first, as the stats keep the URI of recent errors.
+			v.URI = opts.Redact.URI(v.URI)
+			v.Referer = opts.Redact.URI(v.Referer)
+
+			if opts.Counters != nil {
+				opts.Counters.Observe(v.Status)
+			}
+			if opts.Stats != nil {
+				opts.Stats.Observe(c.Path(), v)
+			}
+
+			slow := opts.slow(c, v.Latency)
+			if slow && opts.SlowRequests != nil {
+				opts.SlowRequests.Add(1)
+			}
+
+			if v.Status < http.StatusBadRequest && !slow {
+				if opts.skip(c.Request().URL.Path) || !opts.sampled() {
+					return nil
+				}
+			}
+
+			if opts.Access != nil {
+				if _, err := opts.Access.Write(formatAccessEntry(opts.AccessFormat, v)); err != nil {
+					logger.WithError(err).Warn("Failed to write access log")
+				}
+				if !slow {
+					return nil
+				}
+			}
+
+			level := logrus.InfoLevel
+			if slow {
+				level = logrus.WarnLevel
+			}
+			// The query string can hold personal data, so it is only
+			// logged at debug level.
+			var query string
+			if logger.IsLevelEnabled(logrus.DebugLevel) {
+				query = opts.Redact.Query(c.Request().URL.RawQuery)
+			}
+			if requests != logger {
+				// Follows changes made with PUT /admin/loglevel.
+				requests.SetLevel(logger.GetLevel())
+			}
+
+			if opts.Format == "common" {
+				v.URI = c.Request().URL.EscapedPath()
+				if query != "" {
+					v.URI += "?" + query
+				}
+				requests.Log(level, strings.TrimSuffix(string(formatAccessEntry("common", v)), "\n"))
+				return nil
+			}
+
+			route := c.Path()
+			if route == "" {
+				route = unmatchedRoute
+			}
+			fields := logrus.Fields{
+				"method":     v.Method,
+				"route":      route,
+				"status":     v.Status,
+				"latency_ms": milliseconds(v.Latency),
+				"remote_ip":  v.RemoteIP,
+				"user_agent": v.UserAgent,
+				"bytes_out":  v.ResponseSize,
+				"request_id": v.RequestID,
+			}
+			if query != "" {
+				fields["query"] = query
+			}
+			// Who acted, when the request was made with an API key.
+			if name, ok := c.Get(apiKeyContextKey).(string); ok {
+				fields["api_key"] = name
+			}
+			if slow {
+				fields["slow"] = true
+			}
+			requests.WithFields(fields).Log(level, "HTTP request")
+			return nil
+		},
+	})
+}
+
+// formatAccessEntry renders one access log line, including the trailing newline.
+func formatAccessEntry(format string, v middleware.RequestLoggerValues) []byte {
+	if format == "json" {
+		data, _ := json.Marshal(struct {
+			Time      time.Time `json:"time"`
+			RemoteIP  string    `json:"remote_ip"`
+			Method    string    `json:"method"`
+			URI       string    `json:"uri"`
+			Protocol  string    `json:"protocol"`
+			Status    int       `json:"status"`
+			BytesOut  int64     `json:"bytes_out"`
+			LatencyMS float64   `json:"latency_ms"`
+			Referer   string    `json:"referer,omitempty"`
+			UserAgent string    `json:"user_agent,omitempty"`
+		}{
+			Time:      v.StartTime,
+			RemoteIP:  v.RemoteIP,
+			Method:    v.Method,
+			URI:       v.URI,
+			Protocol:  v.Protocol,
+			Status:    v.Status,
+			BytesOut:  v.ResponseSize,
+			LatencyMS: milliseconds(v.Latency),
+			Referer:   v.Referer,
+			UserAgent: v.UserAgent,
+		})
+		return append(data, '\n')
+	}
+
+	size := "-"
+	if v.ResponseSize > 0 {
+		size = strconv.FormatInt(v.ResponseSize, 10)
+	}
+
+	line := fmt.Sprintf(`%s - - [%s] "%s %s %s" %d %s`,
+		v.RemoteIP, v.StartTime.Format("02/Jan/2006:15:04:05 -0700"), v.Method, v.URI, v.Protocol, v.Status, size)
+	if format == "combined" {
+		line += fmt.Sprintf(` %q %q`, dashIfEmpty(v.Referer), dashIfEmpty(v.UserAgent))
+	}
+	return []byte(line + "\n")
+}
+
+// requestLogger returns the logger request entries are written with: logger
+// itself, or one sharing its output, hooks and level that formats entries as
+// format.
+func requestLogger(logger *logrus.Logger, format string) *logrus.Logger {
+	var formatter logrus.Formatter
+	switch format {
+	case "json":
+		formatter = &logrus.JSONFormatter{}
+	case "text":
+		formatter = &logrus.TextFormatter{FullTimestamp: true}
+	case "common":
+		formatter = messageFormatter{}
+	default:
+		return logger
+	}
+	return &logrus.Logger{
+		Out:          logger.Out,
+		Hooks:        logger.Hooks,
+		Formatter:    formatter,
+		ReportCaller: logger.ReportCaller,
+		Level:        logger.GetLevel(),
+		ExitFunc:     logger.ExitFunc,
+	}
+}
+
+// messageFormatter writes only the message of an entry, such as a common log
+// format line.
+type messageFormatter struct{}
+
+func (messageFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	return []byte(e.Message + "\n"), nil
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// skip reports whether requestPath matches one of the skip patterns.
+func (o RequestLogOptions) skip(requestPath string) bool {
+	rel := strings.TrimPrefix(requestPath, o.BasePath)
+	candidates := []string{rel, strings.TrimPrefix(rel, APIPrefix)}
+
+	for _, pattern := range o.SkipPaths {
+		for _, candidate := range candidates {
+			if ok, _ := path.Match(pattern, candidate); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// excluded reports whether requestPath is under one of the excluded prefixes.
+func (o RequestLogOptions) excluded(requestPath string) bool {
+	rel := strings.TrimPrefix(requestPath, o.BasePath)
+	for _, prefix := range o.ExcludePrefixes {
+		if rel == prefix || strings.HasPrefix(rel, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// slow reports whether a request that took latency exceeded SlowThreshold.
+func (o RequestLogOptions) slow(c echo.Context, latency time.Duration) bool {
+	if o.SlowThreshold <= 0 || latency <= o.SlowThreshold {
+		return false
+	}
+	return o.LongRunning == nil || !o.LongRunning(c)
+}
+
+// sampled reports whether a successful request should be logged.
+func (o RequestLogOptions) sampled() bool {
+	if o.SampleRate >= 1 {
+		return true
+	}
+	return rand.Float64() < o.SampleRate
+}
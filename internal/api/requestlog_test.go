@@ -0,0 +1,326 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+func TestRequestLoggerSkipAndSample(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   RequestLogOptions
+		path   string
+		status int
+		logged bool
+	}{
+		{name: "logged by default", opts: RequestLogOptions{SampleRate: 1}, path: "/hello", status: http.StatusOK, logged: true},
+		{name: "skipped path", opts: RequestLogOptions{SkipPaths: []string{"/health"}, SampleRate: 1}, path: "/health", status: http.StatusOK},
+		{name: "skipped versioned path", opts: RequestLogOptions{SkipPaths: []string{"/health"}, SampleRate: 1}, path: "/api/v1/health", status: http.StatusOK},
+		{name: "skipped under base path", opts: RequestLogOptions{SkipPaths: []string{"/health"}, SampleRate: 1, BasePath: "/greetd"}, path: "/greetd/health", status: http.StatusOK},
+		{name: "glob pattern", opts: RequestLogOptions{SkipPaths: []string{"/static/*"}, SampleRate: 1}, path: "/static/app.css", status: http.StatusOK},
+		{name: "glob does not cross segments", opts: RequestLogOptions{SkipPaths: []string{"/static/*"}, SampleRate: 1}, path: "/static/swagger-ui/x.js", status: http.StatusOK, logged: true},
+		{name: "skipped path still logs errors", opts: RequestLogOptions{SkipPaths: []string{"/health"}, SampleRate: 1}, path: "/health", status: http.StatusServiceUnavailable, logged: true},
+		{name: "sampled out", opts: RequestLogOptions{SampleRate: 0}, path: "/hello", status: http.StatusOK},
+		{name: "sampling never drops client errors", opts: RequestLogOptions{SampleRate: 0}, path: "/hello", status: http.StatusBadRequest, logged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := logrus.New()
+			logger.SetOutput(&buf)
+
+			counters := &StatusCounters{}
+			tt.opts.Counters = counters
+
+			e := echo.New()
+			e.Use(RequestLogger(logger, tt.opts))
+			e.GET("/*", func(c echo.Context) error {
+				return c.NoContent(tt.status)
+			})
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.logged, strings.Contains(buf.String(), "HTTP request"))
+			assert.Equal(t, uint64(1), counters.Count(tt.status/100), "every request is counted")
+		})
+	}
+}
+
+func TestRequestLoggerAPIKey(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+
+	e := echo.New()
+	e.Use(RequestLogger(logger, RequestLogOptions{SampleRate: 1}))
+	e.GET("/admin", func(c echo.Context) error {
+		c.Set(apiKeyContextKey, "api-key:ci-bot")
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/hello", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/admin", nil))
+	assert.Contains(t, buf.String(), `api_key="api-key:ci-bot"`)
+
+	buf.Reset()
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hello", nil))
+	assert.NotContains(t, buf.String(), "api_key")
+}
+
+func TestRequestLoggerFormat(t *testing.T) {
+	serve := func(format string, level logrus.Level, target string) string {
+		var buf bytes.Buffer
+		logger := logrus.New()
+		logger.SetOutput(&buf)
+		logger.SetLevel(level)
+
+		e := echo.New()
+		e.Use(RequestLogger(logger, RequestLogOptions{SampleRate: 1, Format: format}))
+		e.GET("/greetings/:name", func(c echo.Context) error {
+			return c.String(http.StatusOK, "hello")
+		})
+
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.RemoteAddr = "192.0.2.10:51234"
+		e.ServeHTTP(httptest.NewRecorder(), req)
+		return buf.String()
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(serve("json", logrus.InfoLevel, "/greetings/ada?email=ada@example.com")), &entry))
+		assert.Equal(t, "HTTP request", entry["msg"])
+		assert.Equal(t, "/greetings/:name", entry["route"])
+		assert.Equal(t, float64(http.StatusOK), entry["status"])
+		assert.IsType(t, float64(0), entry["latency_ms"])
+		assert.NotContains(t, entry, "uri")
+		assert.NotContains(t, entry, "latency")
+		assert.NotContains(t, entry, "query")
+	})
+
+	t.Run("json at debug level", func(t *testing.T) {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(serve("json", logrus.DebugLevel, "/greetings/ada?email=ada@example.com")), &entry))
+		assert.Equal(t, "email=ada@example.com", entry["query"])
+	})
+
+	t.Run("json unmatched", func(t *testing.T) {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(serve("json", logrus.InfoLevel, "/nowhere")), &entry))
+		assert.Equal(t, unmatchedRoute, entry["route"])
+		assert.Equal(t, float64(http.StatusNotFound), entry["status"])
+	})
+
+	t.Run("text", func(t *testing.T) {
+		line := serve("text", logrus.InfoLevel, "/greetings/ada?email=ada@example.com")
+		fields := map[string]string{}
+		for _, m := range regexp.MustCompile(`(\w+)=("[^"]*"|\S+)`).FindAllStringSubmatch(line, -1) {
+			fields[m[1]] = strings.Trim(m[2], `"`)
+		}
+		assert.Equal(t, "/greetings/:name", fields["route"])
+		assert.Equal(t, "200", fields["status"])
+		_, err := strconv.ParseFloat(fields["latency_ms"], 64)
+		assert.NoError(t, err)
+		assert.NotContains(t, line, "ada@example.com")
+	})
+
+	t.Run("common", func(t *testing.T) {
+		line := serve("common", logrus.InfoLevel, "/greetings/ada?email=ada@example.com")
+		assert.Regexp(t, `^192\.0\.2\.10 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /greetings/ada HTTP/1\.1" 200 5\n$`, line)
+
+		line = serve("common", logrus.DebugLevel, "/greetings/ada?email=ada@example.com")
+		assert.Contains(t, line, `"GET /greetings/ada?email=ada@example.com HTTP/1.1"`)
+	})
+
+	t.Run("logger format", func(t *testing.T) {
+		line := serve("", logrus.InfoLevel, "/greetings/ada")
+		assert.Contains(t, line, "level=info")
+		assert.Contains(t, line, "route=\"/greetings/:name\"")
+	})
+}
+
+func TestRequestLoggerFollowsLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+
+	e := echo.New()
+	e.Use(RequestLogger(logger, RequestLogOptions{SampleRate: 1, Format: "json"}))
+	e.GET("/hello", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	logger.SetLevel(logrus.WarnLevel)
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hello", nil))
+	assert.Empty(t, buf.String())
+
+	logger.SetLevel(logrus.InfoLevel)
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hello", nil))
+	assert.Contains(t, buf.String(), `"msg":"HTTP request"`)
+}
+
+func TestRequestLoggerSlow(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+
+	var slow atomic.Uint64
+	e := echo.New()
+	e.Use(RequestLogger(logger, RequestLogOptions{
+		SkipPaths:     []string{"/health"},
+		SampleRate:    1,
+		SlowThreshold: 20 * time.Millisecond,
+		SlowRequests:  &slow,
+		LongRunning:   func(c echo.Context) bool { return c.Path() == "/stream" },
+	}))
+	delayed := func(c echo.Context) error {
+		time.Sleep(50 * time.Millisecond)
+		return c.NoContent(http.StatusOK)
+	}
+	e.GET("/hello", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	e.GET("/health", delayed)
+	e.GET("/stream", delayed)
+
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hello", nil))
+	assert.Contains(t, buf.String(), "level=info")
+	assert.NotContains(t, buf.String(), "slow")
+
+	// A slow request is logged even on a skipped path.
+	buf.Reset()
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Contains(t, buf.String(), "level=warning")
+	assert.Contains(t, buf.String(), "slow=true")
+	assert.EqualValues(t, 1, slow.Load())
+
+	buf.Reset()
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stream", nil))
+	assert.NotContains(t, buf.String(), "slow")
+	assert.EqualValues(t, 1, slow.Load())
+}
+
+func TestMetricsCountsSlowRequests(t *testing.T) {
+	server, logger := setupServer(t, func(cfg *config.Config) {
+		cfg.Logging.SlowRequestThreshold = "1ns"
+	})
+	var logs bytes.Buffer
+	logger.SetOutput(&logs)
+
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/message", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, logs.String(), "slow=true")
+
+	rec = httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "greetd_http_slow_requests_total 1\n")
+}
+
+func TestMetricsCountsSkippedRequests(t *testing.T) {
+	server, _ := setupServer(t, nil)
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `greetd_http_requests_total{class="2xx"} 3`)
+	assert.Contains(t, rec.Body.String(), `greetd_http_requests_total{class="4xx"} 1`)
+}
+
+func TestFormatAccessEntry(t *testing.T) {
+	v := middleware.RequestLoggerValues{
+		StartTime:    time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+		RemoteIP:     "198.51.100.7",
+		Method:       http.MethodGet,
+		URI:          "/hello?name=Ada",
+		Protocol:     "HTTP/1.1",
+		Status:       http.StatusOK,
+		ResponseSize: 42,
+		Latency:      1500 * time.Microsecond,
+		UserAgent:    "curl/8.0",
+	}
+
+	assert.Equal(t,
+		`198.51.100.7 - - [01/Mar/2024:12:30:00 +0000] "GET /hello?name=Ada HTTP/1.1" 200 42`+"\n",
+		string(formatAccessEntry("common", v)))
+	assert.Equal(t,
+		`198.51.100.7 - - [01/Mar/2024:12:30:00 +0000] "GET /hello?name=Ada HTTP/1.1" 200 42 "-" "curl/8.0"`+"\n",
+		string(formatAccessEntry("combined", v)))
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(formatAccessEntry("json", v), &entry))
+	assert.Equal(t, "/hello?name=Ada", entry["uri"])
+	assert.Equal(t, float64(200), entry["status"])
+	assert.Equal(t, 1.5, entry["latency_ms"])
+	assert.NotContains(t, entry, "referer")
+}
+
+func TestAccessLogFile(t *testing.T) {
+	t.Run("separate file", func(t *testing.T) {
+		var appLog bytes.Buffer
+		server, logger := setupServer(t, func(cfg *config.Config) {
+			cfg.Logging.AccessLog.Format = "json"
+		})
+		logger.SetOutput(&appLog)
+		defer server.accessLog.Close()
+
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/hello", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		data, err := os.ReadFile(server.config.AccessLogPath())
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"uri":"/hello"`)
+		assert.NotContains(t, appLog.String(), "HTTP request")
+
+		rec = httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/logs?source=access", nil))
+		assert.Contains(t, rec.Body.String(), "&#34;uri&#34;:&#34;/hello&#34;")
+		assert.Contains(t, rec.Body.String(), `class="tab tab-active">Access`)
+	})
+
+	t.Run("empty file keeps request entries in the app log", func(t *testing.T) {
+		var appLog bytes.Buffer
+		server, logger := setupServer(t, func(cfg *config.Config) {
+			cfg.Logging.AccessLog.File = ""
+		})
+		logger.SetOutput(&appLog)
+
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/hello", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		assert.Nil(t, server.accessLog)
+		assert.Contains(t, appLog.String(), "HTTP request")
+
+		rec = httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/logs", nil))
+		assert.NotContains(t, rec.Body.String(), `class="tabs"`)
+	})
+}
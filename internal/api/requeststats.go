@@ -0,0 +1,242 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// latencyBuckets are the upper bounds of the per-route latency histogram.
+var latencyBuckets = [...]time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second,
+}
+
+const (
+	// recentErrors is the number of 5xx responses RequestStats keeps.
+	recentErrors = 5
+	// unmatchedRoute names requests that matched no route, so unknown
+	// paths cannot grow the route table.
+	unmatchedRoute = "unmatched"
+)
+
+// RequestStats collects request counts, latencies and recent errors per
+// route since the process started or the stats were last reset. Counters
+// are updated atomically, so observing a request takes no lock, except to
+// record a server error.
+type RequestStats struct {
+	since    atomic.Int64 // Unix nanoseconds
+	requests atomic.Uint64
+	bytesOut atomic.Uint64
+	classes  StatusCounters
+	routes   sync.Map // method + " " + route -> *routeStats
+
+	mu     sync.Mutex
+	errors []RequestError // oldest first
+}
+
+type routeStats struct {
+	method, route string
+	count         atomic.Uint64
+	totalLatency  atomic.Int64
+	maxLatency    atomic.Int64
+	// buckets counts requests per latency bucket; the last one counts
+	// those slower than every bound.
+	buckets [len(latencyBuckets) + 1]atomic.Uint64
+}
+
+// RequestError is a request that was answered with a 5xx status.
+type RequestError struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	Method    string    `json:"method"`
+	URI       string    `json:"uri"`
+	Status    int       `json:"status"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// RouteStats summarizes the requests of one route.
+type RouteStats struct {
+	Method string `json:"method"`
+	Route  string `json:"route"`
+	Count  uint64 `json:"count"`
+	// MeanMS and P95MS are latencies in milliseconds. P95MS is estimated
+	// from the histogram buckets, and never exceeds the slowest request.
+	MeanMS float64 `json:"mean_ms"`
+	P95MS  float64 `json:"p95_ms"`
+}
+
+// RequestStatsResponse is the body of GET /api/stats.
+type RequestStatsResponse struct {
+	Since    time.Time `json:"since"`
+	Requests uint64    `json:"requests"`
+	BytesOut uint64    `json:"bytes_out"`
+	// Status counts responses by class, "1xx" through "5xx".
+	Status map[string]uint64 `json:"status"`
+	// Routes are sorted by count, busiest first.
+	Routes []RouteStats `json:"routes"`
+	// RecentErrors are the latest 5xx responses, most recent first.
+	RecentErrors []RequestError `json:"recent_errors"`
+}
+
+// NewRequestStats returns empty stats starting now.
+func NewRequestStats() *RequestStats {
+	s := &RequestStats{}
+	s.since.Store(time.Now().UnixNano())
+	return s
+}
+
+// Observe records a request answered by route, the path pattern it
+// matched, or "" when it matched none.
+func (s *RequestStats) Observe(route string, v middleware.RequestLoggerValues) {
+	s.requests.Add(1)
+	if v.ResponseSize > 0 {
+		s.bytesOut.Add(uint64(v.ResponseSize))
+	}
+	s.classes.Observe(v.Status)
+
+	if route == "" {
+		route = unmatchedRoute
+	}
+	entry, ok := s.routes.Load(v.Method + " " + route)
+	if !ok {
+		entry, _ = s.routes.LoadOrStore(v.Method+" "+route, &routeStats{method: v.Method, route: route})
+	}
+	entry.(*routeStats).observe(v.Latency)
+
+	if v.Status >= http.StatusInternalServerError {
+		requestError := RequestError{
+			Time:      v.StartTime,
+			RequestID: v.RequestID,
+			Method:    v.Method,
+			URI:       v.URI,
+			Status:    v.Status,
+		}
+		if v.Error != nil {
+			requestError.Error = v.Error.Error()
+		}
+
+		s.mu.Lock()
+		s.errors = append(s.errors, requestError)
+		if len(s.errors) > recentErrors {
+			s.errors = s.errors[len(s.errors)-recentErrors:]
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (r *routeStats) observe(latency time.Duration) {
+	r.count.Add(1)
+	r.totalLatency.Add(int64(latency))
+	for {
+		slowest := r.maxLatency.Load()
+		if int64(latency) <= slowest || r.maxLatency.CompareAndSwap(slowest, int64(latency)) {
+			break
+		}
+	}
+
+	bucket := sort.Search(len(latencyBuckets), func(i int) bool { return latency <= latencyBuckets[i] })
+	r.buckets[bucket].Add(1)
+}
+
+// summary returns the count, mean and estimated 95th percentile latency.
+func (r *routeStats) summary() RouteStats {
+	stats := RouteStats{Method: r.method, Route: r.route, Count: r.count.Load()}
+	if stats.Count == 0 {
+		return stats
+	}
+	stats.MeanMS = milliseconds(time.Duration(r.totalLatency.Load() / int64(stats.Count)))
+
+	slowest := time.Duration(r.maxLatency.Load())
+	target := uint64(math.Ceil(0.95 * float64(stats.Count)))
+	var seen uint64
+	p95 := slowest
+	for i, bound := range latencyBuckets {
+		if seen += r.buckets[i].Load(); seen >= target {
+			p95 = min(bound, slowest)
+			break
+		}
+	}
+	stats.P95MS = milliseconds(p95)
+	return stats
+}
+
+// Report returns the stats collected so far.
+func (s *RequestStats) Report() RequestStatsResponse {
+	report := RequestStatsResponse{
+		Since:        time.Unix(0, s.since.Load()).UTC(),
+		Requests:     s.requests.Load(),
+		BytesOut:     s.bytesOut.Load(),
+		Status:       make(map[string]uint64, 5),
+		Routes:       []RouteStats{},
+		RecentErrors: []RequestError{},
+	}
+	for class := 1; class <= 5; class++ {
+		report.Status[fmt.Sprintf("%dxx", class)] = s.classes.Count(class)
+	}
+
+	s.routes.Range(func(_, value any) bool {
+		if stats := value.(*routeStats).summary(); stats.Count > 0 {
+			report.Routes = append(report.Routes, stats)
+		}
+		return true
+	})
+	sort.Slice(report.Routes, func(i, j int) bool {
+		a, b := report.Routes[i], report.Routes[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		return a.Method < b.Method
+	})
+
+	s.mu.Lock()
+	for i := len(s.errors) - 1; i >= 0; i-- {
+		report.RecentErrors = append(report.RecentErrors, s.errors[i])
+	}
+	s.mu.Unlock()
+	return report
+}
+
+// Reset clears the stats and starts counting again from now.
+func (s *RequestStats) Reset() {
+	s.routes.Clear()
+	s.requests.Store(0)
+	s.bytesOut.Store(0)
+	for i := range s.classes.classes {
+		s.classes.classes[i].Store(0)
+	}
+	s.since.Store(time.Now().UnixNano())
+
+	s.mu.Lock()
+	s.errors = nil
+	s.mu.Unlock()
+}
+
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
+
+// GetRequestStats reports the request counts, latencies and recent errors
+// collected since the server started or the stats were last reset.
+func (h *Handlers) GetRequestStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.requestStats.Report())
+}
+
+// ResetRequestStats clears the request stats.
+func (h *Handlers) ResetRequestStats(c echo.Context) error {
+	h.requestStats.Reset()
+	h.logger.Info("Request stats reset")
+	return c.NoContent(http.StatusNoContent)
+}
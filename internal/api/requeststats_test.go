@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestStats(t *testing.T) {
+	stats := NewRequestStats()
+	observe := func(route string, status int, latency time.Duration) {
+		stats.Observe(route, middleware.RequestLoggerValues{
+			StartTime:    time.Now(),
+			Method:       http.MethodGet,
+			URI:          route,
+			Status:       status,
+			Latency:      latency,
+			ResponseSize: 10,
+		})
+	}
+
+	// 19 fast requests and one slow one: the slow one is above the 95th
+	// percentile and only moves the mean.
+	for i := 0; i < 19; i++ {
+		observe("/api/v1/hello", http.StatusOK, 3*time.Millisecond)
+	}
+	observe("/api/v1/hello", http.StatusOK, 1900*time.Millisecond)
+	observe("/api/v1/message", http.StatusOK, 300*time.Microsecond)
+	observe("", http.StatusNotFound, time.Millisecond)
+	for i := 1; i <= 6; i++ {
+		stats.Observe("/api/v1/message", middleware.RequestLoggerValues{
+			Method:    http.MethodPost,
+			URI:       "/api/v1/message",
+			Status:    http.StatusInternalServerError,
+			RequestID: fmt.Sprintf("req-%d", i),
+			Error:     errors.New("disk full"),
+		})
+	}
+
+	report := stats.Report()
+	assert.EqualValues(t, 28, report.Requests)
+	assert.EqualValues(t, 220, report.BytesOut)
+	assert.Equal(t, map[string]uint64{"1xx": 0, "2xx": 21, "3xx": 0, "4xx": 1, "5xx": 6}, report.Status)
+
+	require.Len(t, report.Routes, 4)
+	hello := report.Routes[0]
+	assert.Equal(t, RouteStats{Method: http.MethodGet, Route: "/api/v1/hello", Count: 20, MeanMS: 97.85, P95MS: 5}, hello)
+	assert.Equal(t, "POST", report.Routes[1].Method)
+	assert.Equal(t, 0.3, report.Routes[2].P95MS, "p95 never exceeds the slowest request")
+	assert.Equal(t, unmatchedRoute, report.Routes[3].Route)
+
+	require.Len(t, report.RecentErrors, recentErrors)
+	assert.Equal(t, "req-6", report.RecentErrors[0].RequestID)
+	assert.Equal(t, "req-2", report.RecentErrors[4].RequestID)
+	assert.Equal(t, "disk full", report.RecentErrors[0].Error)
+
+	since := report.Since
+	stats.Reset()
+	report = stats.Report()
+	assert.Zero(t, report.Requests)
+	assert.Empty(t, report.Routes)
+	assert.Empty(t, report.RecentErrors)
+	assert.False(t, report.Since.Before(since))
+}
+
+func TestRequestStatsEndpoints(t *testing.T) {
+	server, _ := setupAdminServer(t, []string{"secret"}, false)
+	serve := func(method, target, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	serve(http.MethodGet, "/api/v1/hello", "")
+	serve(http.MethodGet, "/api/v1/hello", "")
+	rec := serve(http.MethodGet, "/api/v1/messages/missing", "")
+	assert.NotEmpty(t, rec.Header().Get(echo.HeaderXRequestID))
+
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/api/stats", "").Code)
+	rec = serve(http.MethodGet, "/api/stats", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var report RequestStatsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.EqualValues(t, 4, report.Requests)
+	assert.EqualValues(t, 2, report.Status["4xx"])
+	require.NotEmpty(t, report.Routes)
+	assert.Equal(t, "/api/v1/hello", report.Routes[0].Route)
+	assert.EqualValues(t, 2, report.Routes[0].Count)
+	assert.Positive(t, report.BytesOut)
+
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodDelete, "/api/stats", "").Code)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/api/stats", "secret").Code)
+
+	rec = serve(http.MethodGet, "/api/stats", "secret")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	// The unauthorized DELETE was counted before the reset and is gone;
+	// the successful one finished after it.
+	assert.EqualValues(t, 1, report.Requests)
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"maps"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+// APIPrefix is where the versioned JSON API is mounted.
+const APIPrefix = "/api/v1"
+
+// registerRoutes mounts every route served by greetd under server.base_path
+// and returns which of them are API, admin and internal routes. GET routes
+// answer HEAD as well, except for the log stream, which never ends, and the
+// admin and profiling routes.
+func registerRoutes(e *echo.Echo, cfg *config.Config, handlers *Handlers) routeScopes {
+	var scopes routeScopes
+	basePath := config.NormalizeBasePath(cfg.Server.BasePath)
+	root := e.Group(basePath)
+
+	home := func(c echo.Context) error {
+		return c.Redirect(http.StatusFound, basePath+"/ui")
+	}
+	get(root, "/", home)
+	if basePath != "" {
+		get(root, "", home)
+	}
+
+	// JSON API, plus the deprecated unversioned aliases
+	scopes.api = recordPaths(e, func() {
+		v1 := root.Group(APIPrefix)
+		registerAPIRoutes(v1, cfg, handlers)
+		// No unversioned alias: /logs is the HTML page.
+		get(v1, "/logs", handlers.LogEntries, handlers.IPAllowlist)
+		// Named messages are new in v1 and have no unversioned aliases.
+		get(v1, "/messages", handlers.ListMessages, handlers.MaintenanceGate)
+		get(v1, "/messages/:key", handlers.GetKeyedMessage, handlers.MaintenanceGate)
+		get(v1, "/messages/:key/diff", handlers.KeyedMessageDiff, handlers.MaintenanceGate)
+		get(v1, "/messages/:key/history", handlers.KeyedMessageHistory, handlers.MaintenanceGate)
+		v1.POST("/messages/:key", handlers.SetKeyedMessage, handlers.IPAllowlist, handlers.MaintenanceGate, middleware.BodyLimit(cfg.Message.BodyLimit))
+		v1.DELETE("/messages/:key", handlers.DeleteKeyedMessage, handlers.IPAllowlist, handlers.MaintenanceGate, handlers.APIKeyAuth)
+		// Request stats name the URIs and errors of failed requests, so
+		// reading them needs an API key as well.
+		get(root, "/api/stats", handlers.GetRequestStats, handlers.IPAllowlist, handlers.APIKeyAuth)
+		root.DELETE("/api/stats", handlers.ResetRequestStats, handlers.IPAllowlist, handlers.APIKeyAuth)
+		if cfg.Server.LegacyRoutes {
+			registerAPIRoutes(root, cfg, handlers, Deprecated(basePath, APIPrefix))
+		}
+	})
+
+	// Web UI
+	csrf := CSRF(basePath+"/ui", handlers.CSRFFailed)
+	get(root, "/ui", handlers.UI, handlers.MaintenanceGate, csrf)
+	root.POST("/ui/message", handlers.UIMessage, handlers.IPAllowlist, handlers.MaintenanceGate, csrf)
+	root.POST("/ui/draft", handlers.UIDraft, handlers.IPAllowlist, handlers.MaintenanceGate, csrf)
+	get(root, "/logs", handlers.Logs, handlers.IPAllowlist)
+	root.GET("/logs/stream", handlers.LogStream, handlers.IPAllowlist)
+	get(root, "/status", handlers.Status)
+
+	// Metrics
+	get(root, "/metrics", handlers.Metrics)
+
+	// Admin
+	// Auth is attached per route rather than to the group: group middleware
+	// makes echo answer unmatched methods with 404 instead of 405.
+	adminAuth := []echo.MiddlewareFunc{handlers.IPAllowlist, handlers.APIKeyAuth}
+	scopes.admin = recordPaths(e, func() {
+		admin := root.Group("/admin")
+		admin.GET("/loglevel", handlers.GetLogLevel, adminAuth...)
+		admin.PUT("/loglevel", handlers.SetLogLevel, adminAuth...)
+		admin.GET("/debug", handlers.GetDebug, adminAuth...)
+		admin.PUT("/debug", handlers.SetDebug, adminAuth...)
+		admin.GET("/backup", handlers.Backup, adminAuth...)
+		admin.GET("/maintenance", handlers.GetMaintenance, adminAuth...)
+		admin.POST("/maintenance", handlers.SetMaintenance, adminAuth...)
+		admin.POST("/import", handlers.ImportMessages, append(adminAuth, middleware.BodyLimit(cfg.Message.ImportLimit))...)
+		admin.GET("/routes", handlers.Routes, adminAuth...)
+
+		// Profiling, only when enabled
+		if cfg.Server.EnablePprof {
+			root.GET(PprofPrefix, func(c echo.Context) error {
+				return c.Redirect(http.StatusMovedPermanently, basePath+PprofPrefix+"/")
+			})
+			root.GET(PprofPrefix+"/*", handlers.Pprof, adminAuth...)
+			root.POST(PprofPrefix+"/symbol", handlers.Pprof, adminAuth...)
+		}
+	})
+
+	// Embedded static assets
+	get(root, "/static/*", handlers.Static)
+	get(root, "/favicon.ico", handlers.Favicon)
+
+	// API Documentation
+	get(root, "/swagger/openapi.yaml", handlers.SwaggerSpec)
+	get(root, "/swagger/openapi.json", handlers.SwaggerSpecJSON)
+	get(root, "/swagger/*", handlers.SwaggerUI)
+	get(root, "/docs", handlers.RedocDocs)
+
+	// The internal listener serves health, metrics, profiling and admin.
+	scopes.internal = map[string]bool{
+		basePath + "/health":             true,
+		basePath + APIPrefix + "/health": true,
+		basePath + "/metrics":            true,
+	}
+	maps.Copy(scopes.internal, scopes.admin)
+
+	return scopes
+}
+
+// registerAPIRoutes mounts the JSON endpoints on g. mw is applied to each
+// route individually for the same reason as the admin routes.
+func registerAPIRoutes(g *echo.Group, cfg *config.Config, handlers *Handlers, mw ...echo.MiddlewareFunc) {
+	with := func(extra ...echo.MiddlewareFunc) []echo.MiddlewareFunc {
+		return append(append([]echo.MiddlewareFunc{}, mw...), extra...)
+	}
+
+	get(g, "/health", handlers.Health, with()...)
+	get(g, "/readyz", handlers.Ready, with()...)
+	get(g, "/version", handlers.Version, with()...)
+	// The user-facing routes answer 503 in maintenance mode.
+	get(g, "/hello", handlers.Hello, with(handlers.MaintenanceGate)...)
+	get(g, "/hello/stats", handlers.HelloStats, with()...)
+	g.DELETE("/hello/stats", handlers.ResetHelloStats, with(handlers.IPAllowlist, handlers.APIKeyAuth)...)
+	get(g, "/message", handlers.GetMessage, with(handlers.MaintenanceGate)...)
+	get(g, "/message/diff", handlers.MessageDiff, with(handlers.MaintenanceGate)...)
+	get(g, "/message/history", handlers.MessageHistory, with(handlers.MaintenanceGate)...)
+	// Writes are limited to security.allow_cidrs.
+	g.POST("/message", handlers.SetMessage, with(handlers.IPAllowlist, handlers.MaintenanceGate, middleware.BodyLimit(cfg.Message.BodyLimit), handlers.Idempotent)...)
+	g.POST("/message/undo", handlers.UndoMessage, with(handlers.IPAllowlist, handlers.MaintenanceGate)...)
+	g.POST("/message/redo", handlers.RedoMessage, with(handlers.IPAllowlist, handlers.MaintenanceGate)...)
+	get(g, "/message/draft", handlers.GetDraft, with(handlers.MaintenanceGate)...)
+	g.PUT("/message/draft", handlers.SetDraft, with(handlers.IPAllowlist, handlers.MaintenanceGate, middleware.BodyLimit(cfg.Message.BodyLimit))...)
+	g.DELETE("/message/draft", handlers.DeleteDraft, with(handlers.IPAllowlist, handlers.MaintenanceGate)...)
+	g.POST("/message/draft/publish", handlers.PublishDraft, with(handlers.IPAllowlist, handlers.MaintenanceGate)...)
+}
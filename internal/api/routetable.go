@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RouteEntry is one registration in the route table of GET /admin/routes.
+type RouteEntry struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+}
+
+// RoutesResponse is the body of GET /admin/routes.
+type RoutesResponse struct {
+	Routes []RouteEntry `json:"routes"`
+}
+
+// RouteConflict is a route registered after another one that overlaps it.
+type RouteConflict struct {
+	First  RouteEntry
+	Second RouteEntry
+}
+
+func (c RouteConflict) String() string {
+	first := c.First.Method + " " + c.First.Path
+	second := c.Second.Method + " " + c.Second.Path
+	if strings.HasSuffix(c.First.Path, "*") && c.First.Path != c.Second.Path {
+		return second + " is registered after the wildcard " + first
+	}
+	return second + " is registered twice, by " + c.First.Handler + " and " + c.Second.Handler
+}
+
+// routeTable records the routes added to an echo instance in the order
+// they are registered, which echo's own Routes forgets.
+type routeTable struct {
+	routes []RouteEntry
+}
+
+// add is an echo OnAddRouteHandler.
+func (t *routeTable) add(_ string, route echo.Route, _ echo.HandlerFunc, _ []echo.MiddlewareFunc) {
+	t.routes = append(t.routes, RouteEntry{
+		Method:  route.Method,
+		Path:    route.Path,
+		Handler: handlerName(route.Name),
+	})
+}
+
+// sorted returns the routes sorted by path and then method.
+func (t *routeTable) sorted() []RouteEntry {
+	routes := append([]RouteEntry{}, t.routes...)
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// handlerName shortens the function name echo records for a handler, such
+// as "github.com/.../internal/api.(*Handlers).Health-fm", to
+// "api.(*Handlers).Health".
+func handlerName(name string) string {
+	name = name[strings.LastIndex(name, "/")+1:]
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// routeParam matches a path parameter such as ":key".
+var routeParam = regexp.MustCompile(`:[^/]+`)
+
+// findRouteConflicts reports routes registered for a method and path that
+// an earlier route already covers: the same path, whatever its parameters
+// are called, or a path under an earlier wildcard. Echo matches static
+// segments before wildcards, so such a route is still reached, but the
+// table then reads as if the wildcard took its requests; register the
+// specific route first, as /swagger/openapi.yaml is before /swagger/*.
+func findRouteConflicts(routes []RouteEntry) []RouteConflict {
+	var conflicts []RouteConflict
+	for i, second := range routes {
+		pattern := routeParam.ReplaceAllString(second.Path, ":")
+		for _, first := range routes[:i] {
+			if first.Method != second.Method {
+				continue
+			}
+			earlier := routeParam.ReplaceAllString(first.Path, ":")
+			wildcard, ok := strings.CutSuffix(earlier, "*")
+			if earlier == pattern || ok && strings.HasPrefix(pattern, wildcard) {
+				conflicts = append(conflicts, RouteConflict{First: first, Second: second})
+				break
+			}
+		}
+	}
+	return conflicts
+}
+
+// Routes lists every registered route with the handler serving it, sorted
+// by path, for debugging routing.
+func (h *Handlers) Routes(c echo.Context) error {
+	return c.JSON(http.StatusOK, RoutesResponse{Routes: h.routes})
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindRouteConflicts(t *testing.T) {
+	handler := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e := echo.New()
+	routes := &routeTable{}
+	e.OnAddRouteHandler = routes.add
+
+	// Not conflicting: specific routes before the wildcard, other methods.
+	e.GET("/swagger/openapi.yaml", handler)
+	e.GET("/swagger/*", handler)
+	e.POST("/swagger/upload", handler)
+	e.GET("/messages/:key", handler)
+	e.DELETE("/messages/:key", handler)
+	assert.Empty(t, findRouteConflicts(routes.routes))
+
+	// Deliberately conflicting: a route under an earlier wildcard and the
+	// same route under another parameter name.
+	e.GET("/swagger/openapi.json", handler)
+	e.GET("/messages/:name", handler)
+	conflicts := findRouteConflicts(routes.routes)
+	require.Len(t, conflicts, 2)
+	assert.Equal(t, "GET /swagger/openapi.json is registered after the wildcard GET /swagger/*", conflicts[0].String())
+	assert.Equal(t, "GET /messages/:name is registered twice, by api.TestFindRouteConflicts.func1 and api.TestFindRouteConflicts.func1", conflicts[1].String())
+	assert.Equal(t, "/messages/:key", conflicts[1].First.Path)
+}
+
+func TestRoutesEndpoint(t *testing.T) {
+	server, _ := setupAdminServer(t, []string{"secret"}, false)
+
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/routes", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/routes", nil)
+	req.Header.Set(APIKeyHeader, "secret")
+	rec = httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp RoutesResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Contains(t, resp.Routes, RouteEntry{Method: http.MethodGet, Path: "/api/v1/health", Handler: "api.(*Handlers).Health"})
+	assert.Contains(t, resp.Routes, RouteEntry{Method: http.MethodHead, Path: "/api/v1/health", Handler: "api.(*Handlers).Health"})
+	assert.Contains(t, resp.Routes, RouteEntry{Method: http.MethodGet, Path: "/admin/routes", Handler: "api.(*Handlers).Routes"})
+	assert.True(t, sort.SliceIsSorted(resp.Routes, func(i, j int) bool { return resp.Routes[i].Path < resp.Routes[j].Path }))
+	assert.Len(t, resp.Routes, len(server.echo.Routes()))
+}
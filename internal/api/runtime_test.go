@@ -2,7 +2,9 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -55,7 +57,7 @@ paths:
 
 	// Setup storage
 	store := storage.NewMessageStore(tmpDir)
-	err = store.Load()
+	err = store.Load(context.Background())
 	require.NoError(t, err)
 
 	// Create server
@@ -164,8 +166,9 @@ paths:
 		n, _ := resp.Body.Read(body)
 		content := string(body[:n])
 
-		// Check for Tailwind CSS
-		assert.Contains(t, content, "tailwindcss.com")
+		// Check for the embedded stylesheet
+		assert.Contains(t, content, `<link rel="stylesheet" href="/static/app.`)
+		assert.NotContains(t, content, "tailwindcss.com")
 		// Check for message display
 		assert.Contains(t, content, "Current Message")
 		// Check for update form
@@ -185,8 +188,9 @@ paths:
 		n, _ := resp.Body.Read(body)
 		content := string(body[:n])
 
-		// Check for Tailwind CSS
-		assert.Contains(t, content, "tailwindcss.com")
+		// Check for the embedded stylesheet
+		assert.Contains(t, content, `<link rel="stylesheet" href="/static/app.`)
+		assert.NotContains(t, content, "tailwindcss.com")
 		// Check for logs display
 		assert.Contains(t, content, "Application Logs")
 	})
@@ -199,13 +203,15 @@ paths:
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 		assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
 
-		body := make([]byte, 2048)
-		n, _ := resp.Body.Read(body)
-		content := string(body[:n])
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		content := string(body)
 
-		// Check for Swagger UI elements
+		// Check for Swagger UI elements served from the binary
 		assert.Contains(t, content, "swagger-ui")
 		assert.Contains(t, content, "SwaggerUIBundle")
+		assert.Contains(t, content, "/static/swagger-ui/swagger-ui-bundle.")
+		assert.NotContains(t, content, "unpkg.com")
 		assert.Contains(t, content, "Greetd API")
 	})
 
@@ -223,7 +229,7 @@ paths:
 
 		// Check for Redoc elements
 		assert.Contains(t, content, "redoc")
-		assert.Contains(t, content, "redoc.standalone.js")
+		assert.Contains(t, content, "redoc.standalone")
 		assert.Contains(t, content, "Greetd API")
 	})
 
@@ -258,7 +264,7 @@ func TestServerStartupValidation(t *testing.T) {
 	logger.SetOutput(os.Stderr)
 
 	store := storage.NewMessageStore(tmpDir)
-	err = store.Load()
+	err = store.Load(context.Background())
 	require.NoError(t, err)
 
 	// Server creation should not panic or error
@@ -313,7 +319,7 @@ paths:
 	logger.SetOutput(os.Stderr)
 
 	store := storage.NewMessageStore(tmpDir)
-	err = store.Load()
+	err = store.Load(context.Background())
 	require.NoError(t, err)
 
 	server, err := NewServer(cfg, store, logger)
@@ -2,94 +2,403 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/labstack/gommon/bytes"
 	"github.com/sirupsen/logrus"
 	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/logging"
 	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+	"github.com/svanhalla/prompt-lab/greetd/internal/update"
 )
 
 type Server struct {
-	echo   *echo.Echo
-	config *config.Config
-	logger *logrus.Logger
+	echo      *echo.Echo
+	config    *config.Config
+	logger    *logrus.Logger
+	accessLog logging.RotatingFile
+	handlers  *Handlers
+
+	// internal serves the internal listener, when server.internal is
+	// enabled.
+	internal *http.Server
+
+	mu               sync.Mutex
+	listener         net.Listener
+	internalListener net.Listener
 }
 
 func NewServer(cfg *config.Config, store *storage.MessageStore, logger *logrus.Logger) (*Server, error) {
 	e := echo.New()
 	e.HideBanner = true
+	// echo's debug mode indents JSON responses and keeps this value; the
+	// error details follow PUT /admin/debug.
+	e.Debug = cfg.Server.Debug
 
-	// Middleware
-	e.Use(middleware.Recover())
-	e.Use(middleware.CORS())
-	e.Use(RequestLogger(logger))
+	ipExtractor, err := NewIPExtractor(cfg.Server.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server.trusted_proxies: %w", err)
+	}
+	e.IPExtractor = ipExtractor
 
 	// Handlers
-	handlers, err := NewHandlers(store, logger, cfg.DataPath)
+	handlers, err := NewHandlers(cfg, store, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create handlers: %w", err)
 	}
 
-	// Custom 404 handler
+	requestLog := RequestLogOptions{
+		SkipPaths:       cfg.Logging.SkipPaths,
+		ExcludePrefixes: []string{PprofPrefix},
+		SampleRate:      cfg.Logging.SampleRate,
+		BasePath:        config.NormalizeBasePath(cfg.Server.BasePath),
+		Counters:        handlers.requests,
+		Stats:           handlers.requestStats,
+		AccessFormat:    cfg.Logging.AccessLog.Format,
+		Format:          cfg.Logging.RequestFormat,
+		SlowThreshold:   cfg.Logging.SlowRequestDuration(),
+		SlowRequests:    &handlers.slowRequests,
+		Redact:          handlers.redact,
+	}
+	// Log streams, backups and CPU profiles or traces run longer than a
+	// request may.
+	streamPath := requestLog.BasePath + "/logs/stream"
+	backupPath := requestLog.BasePath + "/admin/backup"
+	longRunning := func(c echo.Context) bool {
+		return c.Path() == streamPath || c.Path() == backupPath || requestLog.excluded(c.Request().URL.Path)
+	}
+	requestLog.LongRunning = longRunning
+
+	var accessLog logging.RotatingFile
+	path := cfg.AccessLogPath()
+	if path != "" {
+		if err := storage.CheckWritable(filepath.Dir(path)); err != nil {
+			logger.WithError(err).Warn("Access log directory is not writable; logging requests to the application log")
+			path = ""
+		}
+	}
+	if path != "" {
+		accessLog = logging.NewRotatingFile(path, logging.Rotation{
+			MaxSize:    cfg.Logging.AccessLog.MaxSize,
+			MaxBackups: cfg.Logging.AccessLog.MaxBackups,
+			MaxAge:     cfg.Logging.AccessLog.MaxAge,
+			Compress:   cfg.Logging.AccessLog.Compress,
+		})
+		requestLog.Access = accessLog
+	}
+
+	timeouts := cfg.Server.Timeouts()
+	e.Server.ReadTimeout = timeouts.Read
+	e.Server.WriteTimeout = timeouts.Write
+	e.Server.IdleTimeout = timeouts.Idle
+
+	// Middleware
+	e.Use(ServerHeaders(cfg.Server.ServerHeader, cfg.Server.ExposeVersion))
+	e.Use(middleware.RequestID())
+	// The API, admin and internal routes are known once registerRoutes has
+	// run.
+	scopes := &routeScopes{}
+	e.Use(InternalOnly(scopes.inInternal))
+	e.Use(CORS(cfg.CORS.AllowedOrigins, cfg.CORS, scopes.inAPI))
+	e.Use(CORS(cfg.CORS.AdminAllowedOrigins, cfg.CORS, scopes.inAdmin))
+	e.Use(RequestLogger(logger, requestLog))
+	// Inside the request logger, so a panic is logged and counted as a 500
+	// with its request ID.
+	e.Use(handlers.Recover)
+	if cfg.Logging.DumpBodies {
+		if !logger.IsLevelEnabled(logrus.DebugLevel) {
+			logger.Warn("logging.dump_bodies is on, but bodies are only logged at debug level")
+		}
+		// Metrics and streams are never dumped: one is noise, the other
+		// never ends.
+		metricsPath := requestLog.BasePath + "/metrics"
+		limit, _ := bytes.Parse(cfg.Logging.DumpBodyLimit)
+		e.Use(BodyDump(logger, int(limit), handlers.redact, func(c echo.Context) bool {
+			switch c.Path() {
+			case metricsPath, streamPath, backupPath:
+				return true
+			}
+			return requestLog.excluded(c.Request().URL.Path)
+		}))
+	}
+	// Load balancers and orchestrators probe health over plain HTTP and by
+	// IP address, and so do sidecars on the internal listener.
+	probes := map[string]bool{}
+	for _, prefix := range []string{requestLog.BasePath, requestLog.BasePath + APIPrefix} {
+		probes[prefix+"/health"] = true
+		probes[prefix+"/readyz"] = true
+	}
+	isProbe := func(c echo.Context) bool {
+		return probes[c.Path()] || onInternalListener(c.Request())
+	}
+	e.Use(AllowedHosts(cfg.Server.AllowedHosts, handlers.trustedProxy, isProbe))
+	if cfg.Server.RedirectHTTPS {
+		e.Use(RedirectHTTPS(cfg.Server.HTTPSPort, handlers.trustedProxy, isProbe))
+	}
+	e.Use(RequestTimeout(logger, timeouts.Request, handlers.redact, longRunning))
+	// Routes with a limit of their own, such as uploads, are not capped
+	// here, so theirs may be larger.
+	importPath := requestLog.BasePath + "/admin/import"
+	e.Use(middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{
+		Limit: cfg.Server.BodyLimit,
+		Skipper: func(c echo.Context) bool {
+			return c.Path() == importPath
+		},
+	}))
+	e.Use(HeadResponse)
+
+	// Errors are answered as JSON unless the client prefers HTML
 	e.HTTPErrorHandler = func(err error, c echo.Context) {
-		if he, ok := err.(*echo.HTTPError); ok && he.Code == http.StatusNotFound {
-			handlers.NotFound(c)
+		if c.Response().Committed {
 			return
 		}
-		e.DefaultHTTPErrorHandler(err, c)
-	}
-
-	// Routes
-	e.GET("/", func(c echo.Context) error {
-		return c.Redirect(http.StatusFound, "/ui")
-	})
-	e.GET("/health", handlers.Health)
-	e.GET("/hello", handlers.Hello)
-	e.GET("/message", handlers.GetMessage)
-	e.POST("/message", handlers.SetMessage)
-	e.GET("/ui", handlers.UI)
-	e.GET("/logs", handlers.Logs)
-
-	// API Documentation
-	e.GET("/swagger/openapi.yaml", handlers.SwaggerSpec)
-	e.GET("/swagger/*", handlers.SwaggerUI)
-	e.GET("/docs", handlers.RedocDocs)
-
-	return &Server{
-		echo:   e,
-		config: cfg,
-		logger: logger,
-	}, nil
+
+		var he *echo.HTTPError
+		if !errors.As(err, &he) {
+			handlers.ServerError(c, err)
+			return
+		}
+
+		switch {
+		case he.Code == http.StatusNotFound:
+			handlers.NotFound(c)
+		case he.Code == http.StatusMethodNotAllowed:
+			handlers.MethodNotAllowed(c)
+		case he.Code == http.StatusRequestEntityTooLarge:
+			handlers.problemResponse(c, he.Code, "Request body too large")
+		case he.Code >= http.StatusInternalServerError:
+			handlers.ServerError(c, err)
+		default:
+			handlers.clientError(c, he, err)
+		}
+	}
+
+	routes := &routeTable{}
+	e.OnAddRouteHandler = routes.add
+	*scopes = registerRoutes(e, cfg, handlers)
+	e.OnAddRouteHandler = nil
+	handlers.routes = routes.sorted()
+	for _, route := range handlers.routes {
+		logger.WithFields(logrus.Fields{
+			"method":  route.Method,
+			"path":    route.Path,
+			"handler": route.Handler,
+		}).Debug("Route")
+	}
+	if conflicts := findRouteConflicts(routes.routes); len(conflicts) > 0 {
+		descriptions := make([]string, len(conflicts))
+		for i, conflict := range conflicts {
+			descriptions[i] = conflict.String()
+		}
+		if cfg.Server.RouteConflicts != config.RouteConflictsWarn {
+			handlers.Close()
+			return nil, fmt.Errorf("conflicting routes: %s", strings.Join(descriptions, "; "))
+		}
+		for _, description := range descriptions {
+			logger.Warn("Conflicting routes: " + description)
+		}
+	}
+
+	server := &Server{
+		echo:      e,
+		config:    cfg,
+		logger:    logger,
+		accessLog: accessLog,
+		handlers:  handlers,
+	}
+	if cfg.Server.Internal.Enabled {
+		server.internal = &http.Server{
+			Handler:      e,
+			ReadTimeout:  timeouts.Read,
+			WriteTimeout: timeouts.Write,
+			IdleTimeout:  timeouts.Idle,
+			BaseContext:  internalContext,
+		}
+	}
+	return server, nil
 }
 
+// Listen binds the configured host and port, and the internal listener's
+// when it is enabled, without serving yet, so the addresses are known
+// before Start. Port 0 picks a free port, and with server.port_retry a
+// port in use is retried with the next ones; Addr and InternalAddr report
+// the port taken. A failure is a *BindError.
+func (s *Server) Listen() error {
+	l, err := listen(s.config.Server.Host, s.config.Server.Port, s.config.Server.PortRetry)
+	if err != nil {
+		return err
+	}
+	if port := l.Addr().(*net.TCPAddr).Port; s.config.Server.Port != 0 && port != s.config.Server.Port {
+		s.logger.Warnf("Port %d is in use; listening on port %d instead", s.config.Server.Port, port)
+	}
+
+	var internal net.Listener
+	if s.internal != nil {
+		internal, err = listen(s.config.Server.Internal.Host, s.config.Server.Internal.Port, 0)
+		if err != nil {
+			l.Close()
+			return fmt.Errorf("internal listener: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.listener = l
+	s.internalListener = internal
+	s.mu.Unlock()
+	return nil
+}
+
+// Addr returns the address the server is listening on, such as
+// "127.0.0.1:54321", or "" before Listen or Serve.
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// InternalAddr returns the address of the internal listener, or "" before
+// Listen or when it is disabled.
+func (s *Server) InternalAddr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.internalListener == nil {
+		return ""
+	}
+	return s.internalListener.Addr().String()
+}
+
+// ManagedKeys returns how many API keys are managed with greetd apikey.
+func (s *Server) ManagedKeys() int {
+	return s.handlers.managedKeys.Len()
+}
+
+// URL returns the base URL of the Web UI on the listening address, using
+// localhost when listening on all interfaces.
+func (s *Server) URL() string {
+	host, port, err := net.SplitHostPort(s.Addr())
+	if err != nil {
+		return ""
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port) + config.NormalizeBasePath(s.config.Server.BasePath) + "/ui"
+}
+
+// Checks returns the registry of dependency checks behind /readyz and the
+// checks of /health, for backends created outside the server to register
+// theirs. The message store's "storage" check is registered already.
+func (s *Server) Checks() *CheckRegistry {
+	return s.handlers.checks
+}
+
+// Start serves on the listener from Listen, binding the configured host and
+// port first if Listen was not called.
 func (s *Server) Start() error {
-	addr := fmt.Sprintf("%s:%d", s.config.Server.Host, s.config.Server.Port)
-	s.logger.Infof("Starting server on %s", addr)
-	return s.echo.Start(addr)
+	if s.Addr() == "" {
+		if err := s.Listen(); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	l := s.listener
+	s.mu.Unlock()
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l instead of listening on the configured
+// host and port, and on the internal listener if Listen bound it. When the
+// internal listener fails, l is closed too and its error returned, and
+// the other way around.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	internal := s.internalListener
+	s.mu.Unlock()
+
+	internalErr := make(chan error, 1)
+	if internal != nil {
+		go func() {
+			if err := s.internal.Serve(internal); !errors.Is(err, http.ErrServerClosed) {
+				internalErr <- err
+				s.echo.Close()
+			}
+		}()
+	}
+
+	s.echo.Listener = l
+	s.echo.HidePort = true
+	err := s.echo.Start("")
+	if internal != nil && !errors.Is(err, http.ErrServerClosed) {
+		s.internal.Close()
+	}
+	select {
+	case err := <-internalErr:
+		return fmt.Errorf("internal listener: %w", err)
+	default:
+		return err
+	}
+}
+
+// SetUpdate records the result of an update check for /status and the
+// verbose /health output.
+func (s *Server) SetUpdate(result update.Result) {
+	s.handlers.update.Store(&result)
+}
+
+// RotateLogs starts new app.log and access log files, keeping the current
+// ones as backups.
+func (s *Server) RotateLogs() error {
+	err := logging.Rotate(s.logger)
+	if s.accessLog != nil {
+		err = errors.Join(err, s.accessLog.Rotate())
+	}
+	return err
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.logger.Info("Shutting down server...")
-	return s.echo.Shutdown(ctx)
-}
+	s.handlers.Close()
 
-func RequestLogger(logger *logrus.Logger) echo.MiddlewareFunc {
-	return middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
-		LogURI:     true,
-		LogStatus:  true,
-		LogMethod:  true,
-		LogLatency: true,
-		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
-			logger.WithFields(logrus.Fields{
-				"method":  v.Method,
-				"uri":     v.URI,
-				"status":  v.Status,
-				"latency": v.Latency,
-			}).Info("HTTP request")
-			return nil
-		},
-	})
+	// Both listeners drain at once, within the same deadline.
+	internalErr := make(chan error, 1)
+	go func() {
+		if s.internal == nil {
+			internalErr <- nil
+			return
+		}
+		s.mu.Lock()
+		internal := s.internalListener
+		s.mu.Unlock()
+		err := s.internal.Shutdown(ctx)
+		if internal != nil {
+			// Bound by Listen but never served, it is not closed by
+			// Shutdown.
+			internal.Close()
+		}
+		internalErr <- err
+	}()
+	err := errors.Join(s.echo.Shutdown(ctx), <-internalErr)
+
+	if s.accessLog != nil {
+		if closeErr := s.accessLog.Close(); closeErr != nil {
+			s.logger.WithError(closeErr).Warn("Failed to close access log")
+		}
+	}
+
+	return err
 }
@@ -2,21 +2,54 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/labstack/echo/v4"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/svanhalla/prompt-lab/greetd/internal/config"
 	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+	"github.com/svanhalla/prompt-lab/greetd/internal/web"
 )
 
+// setupServer creates a server backed by a temporary data directory. configure
+// may adjust the default config first.
+func setupServer(t *testing.T, configure func(*config.Config)) (*Server, *logrus.Logger) {
+	tmpDir := t.TempDir()
+
+	cfg := config.DefaultConfig()
+	cfg.DataPath = tmpDir
+	if configure != nil {
+		configure(cfg)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+
+	store := storage.NewMessageStore(tmpDir)
+	store.SetMaxKeys(cfg.Message.MaxKeys)
+	require.NoError(t, store.Load(context.Background()))
+
+	server, err := NewServer(cfg, store, logger)
+	require.NoError(t, err)
+
+	return server, logger
+}
+
 func TestServerE2E(t *testing.T) {
 	// Create temporary directory
 	tmpDir, err := os.MkdirTemp("", "greetd-e2e-test")
@@ -57,7 +90,7 @@ paths:
 
 	// Setup storage
 	store := storage.NewMessageStore(tmpDir)
-	err = store.Load()
+	err = store.Load(context.Background())
 	require.NoError(t, err)
 
 	// Create server
@@ -178,7 +211,7 @@ func TestServerGracefulShutdown(t *testing.T) {
 
 	// Setup storage
 	store := storage.NewMessageStore(tmpDir)
-	err = store.Load()
+	err = store.Load(context.Background())
 	require.NoError(t, err)
 
 	// Create server
@@ -206,3 +239,655 @@ func TestServerGracefulShutdown(t *testing.T) {
 		t.Fatal("Server did not shutdown within timeout")
 	}
 }
+
+func TestMethodNotAllowed(t *testing.T) {
+	server, _ := setupAdminServer(t, []string{"secret"}, false)
+
+	tests := []struct {
+		method string
+		path   string
+		allow  []string
+	}{
+		{http.MethodPost, "/", []string{http.MethodGet}},
+		{http.MethodPost, "/health", []string{http.MethodGet}},
+		{http.MethodDelete, "/hello", []string{http.MethodGet}},
+		{http.MethodPut, "/message", []string{http.MethodGet, http.MethodPost}},
+		{http.MethodDelete, "/message", []string{http.MethodGet, http.MethodPost}},
+		{http.MethodPost, "/ui", []string{http.MethodGet}},
+		{http.MethodGet, "/ui/message", []string{http.MethodPost}},
+		{http.MethodPost, "/logs", []string{http.MethodGet}},
+		{http.MethodPost, "/admin/loglevel", []string{http.MethodGet, http.MethodPut}},
+		{http.MethodPost, "/static/app.css", []string{http.MethodGet}},
+		{http.MethodPut, "/swagger/openapi.yaml", []string{http.MethodGet}},
+		{http.MethodPost, "/swagger/index.html", []string{http.MethodGet}},
+		{http.MethodPatch, "/docs", []string{http.MethodGet}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(APIKeyHeader, "secret")
+			rec := httptest.NewRecorder()
+			server.echo.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+			assert.Contains(t, rec.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+			for _, method := range tt.allow {
+				assert.Contains(t, rec.Header().Get(echo.HeaderAllow), method)
+			}
+
+			var body map[string]string
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, "Method Not Allowed", body["error"])
+		})
+	}
+}
+
+func TestOptions(t *testing.T) {
+	server, _ := setupAdminServer(t, []string{"secret"}, false)
+
+	tests := []struct {
+		name   string
+		path   string
+		origin string
+		allow  []string
+	}{
+		{name: "plain", path: "/message", allow: []string{http.MethodGet, http.MethodPost}},
+		{name: "CORS preflight", path: "/message", origin: "https://example.com", allow: []string{http.MethodGet, http.MethodPost}},
+		{name: "admin", path: "/admin/loglevel", allow: []string{http.MethodGet, http.MethodPut}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, tt.path, nil)
+			if tt.origin != "" {
+				req.Header.Set(echo.HeaderOrigin, tt.origin)
+				req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodPost)
+			}
+			rec := httptest.NewRecorder()
+			server.echo.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+			for _, method := range tt.allow {
+				assert.Contains(t, rec.Header().Get(echo.HeaderAllow), method)
+			}
+			// No origins are allowed by default, so a preflight is
+			// answered without CORS headers.
+			assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+		})
+	}
+}
+
+func TestErrorNegotiation(t *testing.T) {
+	server, _ := setupAdminServer(t, []string{"secret"}, false)
+	server.echo.GET("/boom", func(c echo.Context) error {
+		return errors.New("database exploded")
+	})
+
+	const browser = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		accept string
+		status int
+		html   bool
+	}{
+		{name: "404 curl", path: "/missing", accept: "*/*", status: http.StatusNotFound},
+		{name: "404 no accept", path: "/missing", status: http.StatusNotFound},
+		{name: "404 json with wildcard", path: "/missing", accept: "application/json, */*", status: http.StatusNotFound},
+		{name: "404 vendor json", path: "/missing", accept: "application/vnd.api+json", status: http.StatusNotFound},
+		{name: "404 json preferred over html", path: "/missing", accept: "text/html;q=0.5, application/json", status: http.StatusNotFound},
+		{name: "404 browser", path: "/missing", accept: browser, status: http.StatusNotFound, html: true},
+		{name: "404 html preferred over json", path: "/missing", accept: "text/html, application/json;q=0.9", status: http.StatusNotFound, html: true},
+		{name: "405 curl", method: http.MethodDelete, path: "/hello", accept: "*/*", status: http.StatusMethodNotAllowed},
+		{name: "405 browser", method: http.MethodDelete, path: "/hello", accept: browser, status: http.StatusMethodNotAllowed, html: true},
+		{name: "500 curl", path: "/boom", accept: "*/*", status: http.StatusInternalServerError},
+		{name: "500 browser", path: "/boom", accept: browser, status: http.StatusInternalServerError, html: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			req := httptest.NewRequest(method, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set(echo.HeaderAccept, tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			server.echo.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.NotContains(t, rec.Body.String(), "database exploded")
+			if tt.html {
+				assert.Contains(t, rec.Header().Get(echo.HeaderContentType), echo.MIMETextHTML)
+				assert.Contains(t, rec.Body.String(), "<!DOCTYPE html>")
+				return
+			}
+
+			if tt.status != http.StatusMethodNotAllowed {
+				assert.Equal(t, mimeProblemJSON, rec.Header().Get(echo.HeaderContentType))
+				var problem ProblemDetails
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+				assert.Equal(t, http.StatusText(tt.status), problem.Title)
+				assert.Equal(t, tt.status, problem.Status)
+				if tt.status == http.StatusInternalServerError {
+					assert.Equal(t, "An unexpected error occurred", problem.Detail)
+					assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), problem.RequestID)
+					assert.NotEmpty(t, problem.RequestID)
+				}
+				return
+			}
+
+			assert.Contains(t, rec.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+			var body map[string]string
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, http.StatusText(tt.status), body["error"])
+		})
+	}
+}
+
+func TestDebugErrors(t *testing.T) {
+	serve := func(server *Server, target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set(echo.HeaderAccept, accept)
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+	problemOf := func(t *testing.T, rec *httptest.ResponseRecorder) ProblemDetails {
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+		require.Equal(t, mimeProblemJSON, rec.Header().Get(echo.HeaderContentType))
+		var problem ProblemDetails
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+		assert.Equal(t, "An unexpected error occurred", problem.Detail)
+		assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), problem.RequestID)
+		return problem
+	}
+
+	for _, debug := range []bool{false, true} {
+		t.Run(fmt.Sprintf("debug %t", debug), func(t *testing.T) {
+			server, logger := setupServer(t, func(cfg *config.Config) {
+				cfg.Server.Debug = debug
+			})
+			logger.SetOutput(&bytes.Buffer{})
+			server.echo.GET("/boom", func(c echo.Context) error {
+				return errors.New("database exploded")
+			})
+			server.echo.GET("/explode", func(c echo.Context) error {
+				panic("kaboom")
+			})
+			server.echo.GET("/invalid", func(c echo.Context) error {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid input").SetInternal(errors.New("strconv: parsing"))
+			})
+
+			problem := problemOf(t, serve(server, "/boom", "*/*"))
+			panicked := problemOf(t, serve(server, "/explode", "*/*"))
+			page := serve(server, "/boom", "text/html")
+			require.Equal(t, http.StatusInternalServerError, page.Code)
+			assert.Contains(t, page.Body.String(), page.Header().Get(echo.HeaderXRequestID))
+
+			var invalid map[string]string
+			rec := serve(server, "/invalid", "*/*")
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &invalid))
+			assert.Equal(t, "Invalid input", invalid["message"])
+
+			if !debug {
+				assert.Empty(t, problem.Error)
+				assert.Empty(t, panicked.Error)
+				assert.Empty(t, panicked.Stack)
+				assert.NotContains(t, page.Body.String(), "database exploded")
+				assert.NotContains(t, invalid, "error")
+				return
+			}
+
+			assert.Equal(t, "database exploded", problem.Error)
+			assert.Empty(t, problem.Stack, "a returned error has no stack")
+			assert.Equal(t, "panic: kaboom", panicked.Error)
+			assert.Contains(t, panicked.Stack, "TestDebugErrors")
+			assert.LessOrEqual(t, strings.Count(panicked.Stack, "\n"), maxStackLines)
+			assert.Contains(t, page.Body.String(), "database exploded")
+			assert.Contains(t, invalid["error"], "strconv: parsing")
+		})
+	}
+}
+
+func TestDebugHandlerErrors(t *testing.T) {
+	for _, debug := range []bool{false, true} {
+		t.Run(fmt.Sprintf("debug %t", debug), func(t *testing.T) {
+			var dataPath string
+			server, logger := setupServer(t, func(cfg *config.Config) {
+				dataPath = cfg.DataPath
+				cfg.Server.Debug = debug
+				// Valid for the sample name, but fails for names under
+				// three characters.
+				cfg.Greetings.Template = "Hello, {{slice .Name 3}}!"
+			})
+			logger.SetOutput(&bytes.Buffer{})
+
+			// A directory in place of messages.json fails every write,
+			// even for root.
+			require.NoError(t, os.MkdirAll(filepath.Join(dataPath, storage.MessagesFileName, "blocked"), 0755))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/message", strings.NewReader(`{"message":"changed"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			write := httptest.NewRecorder()
+			server.echo.ServeHTTP(write, req)
+
+			greet := httptest.NewRecorder()
+			server.echo.ServeHTTP(greet, httptest.NewRequest(http.MethodGet, "/api/v1/hello?name=Al", nil))
+
+			for _, rec := range []*httptest.ResponseRecorder{write, greet} {
+				require.Equal(t, http.StatusInternalServerError, rec.Code, rec.Body.String())
+				require.Equal(t, mimeProblemJSON, rec.Header().Get(echo.HeaderContentType))
+				var problem ProblemDetails
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+				assert.Equal(t, "An unexpected error occurred", problem.Detail)
+				assert.NotEmpty(t, problem.RequestID)
+				assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), problem.RequestID)
+				if debug {
+					assert.NotEmpty(t, problem.Error)
+				} else {
+					assert.Empty(t, problem.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Server.BodyLimit = "1KB"
+		cfg.Security.APIKeys = []string{"secret"}
+	})
+	large := strings.Repeat("Hello ", 170)
+
+	// Within message.body_limit, but over server.body_limit.
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/messages/news", strings.NewReader(`{"message":"`+large+`"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Equal(t, mimeProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	var problem ProblemDetails
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, problem.Status)
+	assert.Equal(t, "Request body too large", problem.Detail)
+
+	// Imports are bound by message.import_limit instead.
+	rec = uploadImport(t, server, "/admin/import", `{"messages":{"news":{"message":"`+large+`"}}}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/health", ""},
+		{http.MethodGet, "/version", ""},
+		{http.MethodGet, "/hello", ""},
+		{http.MethodGet, "/message", ""},
+		{http.MethodPost, "/message", `{"message": "versioned"}`},
+	}
+
+	send := func(server *Server, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		}
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("legacy routes enabled", func(t *testing.T) {
+		server, _ := setupServer(t, nil)
+
+		for _, tt := range tests {
+			rec := send(server, tt.method, APIPrefix+tt.path, tt.body)
+			assert.Equal(t, http.StatusOK, rec.Code, "%s %s%s", tt.method, APIPrefix, tt.path)
+			assert.Empty(t, rec.Header().Get("Deprecation"))
+
+			rec = send(server, tt.method, tt.path, tt.body)
+			assert.Equal(t, http.StatusOK, rec.Code, "%s %s", tt.method, tt.path)
+			assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+			assert.Equal(t, `<`+APIPrefix+tt.path+`>; rel="successor-version"`, rec.Header().Get("Link"))
+		}
+	})
+
+	t.Run("legacy routes disabled", func(t *testing.T) {
+		server, _ := setupServer(t, func(cfg *config.Config) {
+			cfg.Server.LegacyRoutes = false
+		})
+
+		for _, tt := range tests {
+			rec := send(server, tt.method, APIPrefix+tt.path, tt.body)
+			assert.Equal(t, http.StatusOK, rec.Code, "%s %s%s", tt.method, APIPrefix, tt.path)
+
+			rec = send(server, tt.method, tt.path, tt.body)
+			assert.Equal(t, http.StatusNotFound, rec.Code, "%s %s", tt.method, tt.path)
+		}
+	})
+}
+
+func TestBasePath(t *testing.T) {
+	// The docs pages read api/openapi.yaml relative to the repository root.
+	t.Chdir("../..")
+
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Server.BasePath = "/greetd/"
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderAccept, "text/html")
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, path := range []string{"/greetd", "/greetd/"} {
+		rec := get(path)
+		assert.Equal(t, http.StatusFound, rec.Code, path)
+		assert.Equal(t, "/greetd/ui", rec.Header().Get(echo.HeaderLocation), path)
+	}
+
+	assert.Equal(t, http.StatusNotFound, get("/ui").Code, "routes must not be served from the root")
+
+	linkPattern := regexp.MustCompile(`(?:href|src|action|spec-url)=["']([^"']+)["']|url: '([^']+)'`)
+
+	for _, page := range []string{"/greetd/ui", "/greetd/logs", "/greetd/swagger/", "/greetd/docs", "/greetd/status", "/greetd/missing"} {
+		t.Run(page, func(t *testing.T) {
+			rec := get(page)
+			body := rec.Body.String()
+
+			matches := linkPattern.FindAllStringSubmatch(body, -1)
+			require.NotEmpty(t, matches)
+
+			for _, m := range matches {
+				link := strings.ReplaceAll(m[1]+m[2], `\/`, "/")
+				if !strings.HasPrefix(link, "/") {
+					continue // external (CDN) or relative link
+				}
+
+				assert.True(t, strings.HasPrefix(link, "/greetd/"), "link %q on %s is not under the base path", link, page)
+				assert.NotEqual(t, http.StatusNotFound, get(link).Code, "link %q on %s does not resolve", link, page)
+			}
+		})
+	}
+}
+
+func TestPageNavigation(t *testing.T) {
+	// The docs pages read api/openapi.yaml relative to the repository root.
+	t.Chdir("../..")
+
+	server, _ := setupServer(t, nil)
+
+	pages := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/ui", http.StatusOK},
+		{http.MethodGet, "/logs", http.StatusOK},
+		{http.MethodGet, "/swagger/", http.StatusOK},
+		{http.MethodGet, "/docs", http.StatusOK},
+		{http.MethodGet, "/status", http.StatusOK},
+		{http.MethodGet, "/missing", http.StatusNotFound},
+		{http.MethodDelete, "/hello", http.StatusMethodNotAllowed},
+	}
+
+	for _, page := range pages {
+		t.Run(page.method+" "+page.path, func(t *testing.T) {
+			req := httptest.NewRequest(page.method, page.path, nil)
+			req.Header.Set(echo.HeaderAccept, "text/html")
+			rec := httptest.NewRecorder()
+			server.echo.ServeHTTP(rec, req)
+
+			assert.Equal(t, page.status, rec.Code)
+			body := rec.Body.String()
+			assert.Contains(t, body, `<header class="nav">`)
+			for _, link := range []string{`href="/ui">UI<`, `href="/logs">Logs<`, `href="/status">Status<`, `href="/swagger/">Docs<`, `href="/api/v1/health">Health<`} {
+				assert.Contains(t, body, link)
+			}
+			assert.Equal(t, 1, strings.Count(body, "<!DOCTYPE html>"))
+		})
+	}
+}
+
+func TestFavicon(t *testing.T) {
+	server, _ := setupServer(t, nil)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderAccept, "image/avif,image/webp,*/*")
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/favicon.ico")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/x-icon", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "public, max-age=3600", rec.Header().Get("Cache-Control"))
+	assert.True(t, bytes.HasPrefix(rec.Body.Bytes(), []byte{0, 0, 1, 0}), "not an ICO file")
+
+	rec = get(web.AssetURL("favicon.svg"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/svg+xml", rec.Header().Get(echo.HeaderContentType))
+
+	// Browsers asking for the icon no longer end up in the 404 handler.
+	assert.Equal(t, uint64(2), server.handlers.requests.Count(2))
+	assert.Zero(t, server.handlers.requests.Count(4))
+}
+
+func TestNotFoundSuggestions(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Server.BasePath = "/greetd"
+		cfg.Server.LegacyRoutes = false
+		cfg.Server.EnablePprof = true
+	})
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderAccept, accept)
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/greetd/api/v1/mesage", []string{"/greetd/api/v1/message"}},
+		{"/greetd/message", []string{"/greetd/api/v1/message", "/greetd/ui/message"}},
+		{"/greetd/stauts", []string{"/greetd/status"}},
+		{"/greetd/completely/unrelated/path", nil},
+		{"/greetd/" + strings.Repeat("a", 1000), nil},
+		// Admin and profiling routes are never suggested.
+		{"/greetd/maintenance", nil},
+		{"/greetd/admin/maintenanse", nil},
+		{"/greetd/debug/pprf", nil},
+	}
+
+	for _, tt := range tests {
+		rec := get(tt.path, "application/json")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		var problem ProblemDetails
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+		assert.Equal(t, tt.want, problem.Suggestions, tt.path)
+	}
+
+	rec := get("/greetd/api/v1/helo", "text/html")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, `Did you mean <a href="/greetd/api/v1/hello">/api/v1/hello</a>?`)
+	for _, group := range []string{">API</h2>", ">UI</h2>", ">Docs</h2>", ">Admin</h2>"} {
+		assert.Contains(t, body, group)
+	}
+	assert.Contains(t, body, `<a href="/greetd/api/v1/health" class="route"><strong>/api/v1/health</strong> - GET</a>`)
+	assert.Contains(t, body, `<span class="route"><strong>/api/v1/messages/:key</strong> - GET, POST, DELETE</span>`)
+	assert.NotContains(t, body, "/static/*")
+}
+
+func TestListenEphemeralPort(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Server.Host = "127.0.0.1"
+		cfg.Server.Port = 0
+		cfg.Server.BasePath = "/greetd"
+	})
+
+	assert.Empty(t, server.Addr())
+	require.NoError(t, server.Listen())
+	addr := server.Addr()
+	assert.Regexp(t, `^127\.0\.0\.1:\d+$`, addr)
+	assert.NotEqual(t, "127.0.0.1:0", addr)
+	assert.Equal(t, "http://"+addr+"/greetd/ui", server.URL())
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Start() }()
+
+	// The listener is bound before Start, so requests succeed right away.
+	resp, err := http.Get("http://" + addr + "/greetd/health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, server.Shutdown(ctx))
+	assert.ErrorIs(t, <-serveErr, http.ErrServerClosed)
+}
+
+func TestListenPortInUse(t *testing.T) {
+	held, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer held.Close()
+	port := held.Addr().(*net.TCPAddr).Port
+
+	listen := func(retries int) (*Server, *bytes.Buffer, error) {
+		server, logger := setupServer(t, func(cfg *config.Config) {
+			cfg.Server.Host = "127.0.0.1"
+			cfg.Server.Port = port
+			cfg.Server.PortRetry = retries
+		})
+		var logs bytes.Buffer
+		logger.SetOutput(&logs)
+		return server, &logs, server.Listen()
+	}
+
+	_, _, err = listen(0)
+	var bindErr *BindError
+	require.ErrorAs(t, err, &bindErr)
+	assert.True(t, bindErr.InUse())
+	assert.Equal(t, held.Addr().String(), bindErr.Addr)
+	if runtime.GOOS == "linux" {
+		// The test holds the port itself.
+		assert.Contains(t, bindErr.Owner, fmt.Sprintf("(pid %d)", os.Getpid()))
+		assert.Contains(t, err.Error(), "the port is held by")
+	}
+
+	server, logs, err := listen(5)
+	require.NoError(t, err)
+	defer server.listener.Close()
+	taken := server.listener.Addr().(*net.TCPAddr).Port
+	assert.Greater(t, taken, port)
+	assert.LessOrEqual(t, taken, port+5)
+	assert.Contains(t, logs.String(), fmt.Sprintf("Port %d is in use; listening on port %d instead", port, taken))
+
+	err = &BindError{Addr: "127.0.0.1:8080", Retries: 3, Owner: "greetd (pid 42)", Err: errors.New("bind: address already in use")}
+	assert.EqualError(t, err, "bind: address already in use, and so are the next 3 ports; the port is held by greetd (pid 42)")
+}
+
+func TestInternalListener(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Server.Host = "127.0.0.1"
+		cfg.Server.Port = 0
+		cfg.Server.EnablePprof = true
+		cfg.Server.AllowedHosts = []string{"greetd.example.com"}
+		cfg.Server.Internal = config.InternalListenerConfig{Enabled: true, Host: "127.0.0.1", Port: 0}
+		cfg.Security.APIKeys = []string{"secret"}
+	})
+
+	assert.Empty(t, server.InternalAddr())
+	require.NoError(t, server.Listen())
+	public, internal := server.Addr(), server.InternalAddr()
+	assert.Regexp(t, `^127\.0\.0\.1:\d+$`, internal)
+	assert.NotEqual(t, public, internal)
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Start() }()
+
+	// Without keep-alives no connection is left open that would delay
+	// Shutdown.
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	get := func(addr, path string) int {
+		req, err := http.NewRequest(http.MethodGet, "http://"+addr+path, nil)
+		require.NoError(t, err)
+		req.Host = "greetd.example.com"
+		req.Header.Set(APIKeyHeader, "secret")
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		path     string
+		internal int
+	}{
+		{"/health", http.StatusOK},
+		{"/api/v1/health", http.StatusOK},
+		{"/metrics", http.StatusOK},
+		{"/admin/loglevel", http.StatusOK},
+		{"/debug/pprof/cmdline", http.StatusOK},
+		{"/api/v1/hello", http.StatusNotFound},
+		{"/api/v1/readyz", http.StatusNotFound},
+		{"/ui", http.StatusNotFound},
+		{"/status", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, http.StatusOK, get(public, tt.path), "public %s", tt.path)
+		assert.Equal(t, tt.internal, get(internal, tt.path), "internal %s", tt.path)
+	}
+
+	// The internal listener is not held to server.allowed_hosts.
+	resp, err := client.Get("http://" + internal + "/metrics")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, server.Shutdown(ctx))
+	assert.ErrorIs(t, <-serveErr, http.ErrServerClosed)
+	_, err = net.Dial("tcp", internal)
+	assert.Error(t, err)
+}
+
+func TestURLUnspecifiedHost(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Server.Host = "0.0.0.0"
+		cfg.Server.Port = 0
+	})
+
+	serveErr := make(chan error, 1)
+	require.NoError(t, server.Listen())
+	go func() { serveErr <- server.Start() }()
+
+	_, port, err := net.SplitHostPort(server.Addr())
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:"+port+"/ui", server.URL())
+
+	require.NoError(t, server.Shutdown(context.Background()))
+	<-serveErr
+}
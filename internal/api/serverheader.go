@@ -0,0 +1,38 @@
+package api
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/version"
+)
+
+// VersionHeader is the response header carrying the running version when
+// server.expose_version is set.
+const VersionHeader = "X-Greetd-Version"
+
+// ServerHeaders sets the Server response header to server, "greetd/" and the
+// version when it is empty, or leaves it out when it is "off". With
+// exposeVersion, VersionHeader is set as well. The headers are set before
+// the handler runs, so errors and unmatched routes carry them too.
+func ServerHeaders(server string, exposeVersion bool) echo.MiddlewareFunc {
+	current := version.Get().Version
+	switch server {
+	case "":
+		server = "greetd/" + current
+	case config.ServerHeaderOff:
+		server = ""
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Response().Header()
+			if server != "" {
+				header.Set(echo.HeaderServer, server)
+			}
+			if exposeVersion {
+				header.Set(VersionHeader, current)
+			}
+			return next(c)
+		}
+	}
+}
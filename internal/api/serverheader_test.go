@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/version"
+)
+
+func TestServerHeaders(t *testing.T) {
+	tests := []struct {
+		name          string
+		serverHeader  string
+		exposeVersion bool
+		wantServer    string
+		wantVersion   string
+	}{
+		{name: "default", wantServer: "greetd/" + version.Get().Version},
+		{name: "custom", serverHeader: "web", wantServer: "web"},
+		{name: "off", serverHeader: config.ServerHeaderOff},
+		{name: "version exposed", serverHeader: config.ServerHeaderOff, exposeVersion: true, wantVersion: version.Get().Version},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := setupServer(t, func(cfg *config.Config) {
+				cfg.Server.ServerHeader = tt.serverHeader
+				cfg.Server.ExposeVersion = tt.exposeVersion
+			})
+
+			// Successes, errors, unknown routes and other methods all
+			// carry the same headers.
+			for _, req := range []*http.Request{
+				httptest.NewRequest(http.MethodGet, "/api/v1/health", nil),
+				httptest.NewRequest(http.MethodGet, "/nowhere", nil),
+				httptest.NewRequest(http.MethodDelete, "/api/v1/hello", nil),
+				jsonRequest(http.MethodPost, "/api/v1/message", `{"message":""}`),
+			} {
+				rec := httptest.NewRecorder()
+				server.echo.ServeHTTP(rec, req)
+
+				target := req.Method + " " + req.URL.Path
+				assert.Equal(t, tt.wantServer, rec.Header().Get(echo.HeaderServer), target)
+				_, sent := rec.Header()[echo.HeaderServer]
+				assert.Equal(t, tt.wantServer != "", sent, target)
+				assert.Equal(t, tt.wantVersion, rec.Header().Get(VersionHeader), target)
+			}
+		})
+	}
+}
+
+func jsonRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	return req
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// specPaths are where the OpenAPI spec is looked for, relative to the
+// working directory.
+var specPaths = []string{
+	"api/openapi.yaml",
+	"../../../api/openapi.yaml", // For tests
+}
+
+var errSpecNotFound = errors.New("OpenAPI spec not found")
+
+// specCache holds the parsed OpenAPI spec until the file on disk changes.
+type specCache struct {
+	mu   sync.Mutex
+	spec *openAPISpec
+}
+
+// openAPISpec is a parsed spec. The JSON form is converted on first use.
+type openAPISpec struct {
+	path    string
+	modTime time.Time
+	size    int64
+
+	root  *yaml.Node // the top-level mapping
+	title string
+
+	jsonOnce sync.Once
+	jsonDoc  map[string]interface{}
+	jsonErr  error
+}
+
+// load returns the spec, parsing the file again only when its modification
+// time or size changed since the last call.
+func (s *specCache) load() (*openAPISpec, error) {
+	for _, path := range specPaths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if cached := s.spec; cached != nil && cached.path == path &&
+			cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+			return cached, nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		spec, err := parseSpec(data)
+		if err != nil {
+			return nil, err
+		}
+		spec.path, spec.modTime, spec.size = path, info.ModTime(), info.Size()
+		s.spec = spec
+		return spec, nil
+	}
+	return nil, errSpecNotFound
+}
+
+func parseSpec(data []byte) (*openAPISpec, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI spec: %w", err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("invalid OpenAPI spec: not a mapping")
+	}
+
+	spec := &openAPISpec{root: doc.Content[0], title: "Greetd API"}
+	if info := mappingValue(spec.root, "info"); info != nil {
+		if title := mappingValue(info, "title"); title != nil && title.Value != "" {
+			spec.title = title.Value
+		}
+	}
+	return spec, nil
+}
+
+// YAML renders the spec with serverURL as its first server.
+func (s *openAPISpec) YAML(serverURL string) ([]byte, error) {
+	root := *s.root
+	root.Content = append([]*yaml.Node{}, s.root.Content...)
+	if i := mappingIndex(&root, "servers"); i >= 0 {
+		root.Content[i] = s.servers(serverURL)
+	} else {
+		root.Content = append(root.Content, scalarNode("servers"), s.servers(serverURL))
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// JSON renders the spec as JSON with serverURL as its first server.
+func (s *openAPISpec) JSON(serverURL string) ([]byte, error) {
+	s.jsonOnce.Do(func() {
+		var doc interface{}
+		if s.jsonErr = s.root.Decode(&doc); s.jsonErr != nil {
+			return
+		}
+		s.jsonDoc, _ = jsonCompatible(doc).(map[string]interface{})
+	})
+	if s.jsonErr != nil {
+		return nil, s.jsonErr
+	}
+
+	var servers interface{}
+	if err := s.servers(serverURL).Decode(&servers); err != nil {
+		return nil, err
+	}
+
+	doc := make(map[string]interface{}, len(s.jsonDoc)+1)
+	for key, value := range s.jsonDoc {
+		doc[key] = value
+	}
+	doc["servers"] = servers
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// servers returns the spec's servers list with serverURL first. An entry
+// for the same URL elsewhere in the list is dropped.
+func (s *openAPISpec) servers(serverURL string) *yaml.Node {
+	list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	list.Content = append(list.Content, &yaml.Node{
+		Kind: yaml.MappingNode,
+		Tag:  "!!map",
+		Content: []*yaml.Node{
+			scalarNode("url"), scalarNode(serverURL),
+			scalarNode("description"), scalarNode("This server"),
+		},
+	})
+
+	if existing := mappingValue(s.root, "servers"); existing != nil && existing.Kind == yaml.SequenceNode {
+		for _, server := range existing.Content {
+			if url := mappingValue(server, "url"); url != nil && url.Value == serverURL {
+				continue
+			}
+			list.Content = append(list.Content, server)
+		}
+	}
+	return list
+}
+
+// mappingValue returns the value for key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if i := mappingIndex(node, key); i >= 0 {
+		return node.Content[i]
+	}
+	return nil
+}
+
+// mappingIndex returns the index in node.Content of the value for key in a
+// mapping node, or -1.
+func mappingIndex(node *yaml.Node, key string) int {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return -1
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// jsonCompatible converts the maps with non-string keys that YAML allows,
+// such as unquoted status codes, into maps encoding/json can marshal.
+func jsonCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = jsonCompatible(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = jsonCompatible(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonCompatible(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+const testSpec = `openapi: 3.1.0
+info:
+  title: Greetd API
+  version: 1.0.0
+servers:
+  - url: http://localhost:8080
+    description: Development server
+paths:
+  /api/v1/health:
+    get:
+      responses:
+        200:
+          description: Health information
+`
+
+type specServers struct {
+	Servers []struct {
+		URL         string `json:"url" yaml:"url"`
+		Description string `json:"description" yaml:"description"`
+	} `json:"servers" yaml:"servers"`
+}
+
+func TestSwaggerSpecServers(t *testing.T) {
+	specDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(specDir, "api"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(specDir, "api", "openapi.yaml"), []byte(testSpec), 0644))
+	t.Chdir(specDir)
+
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Server.BasePath = "/greetd"
+		cfg.Server.TrustedProxies = []string{"10.0.0.0/8"}
+	})
+
+	get := func(path, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = "greetd.internal:8080"
+		req.RemoteAddr = remoteAddr
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, req)
+		return rec
+	}
+	forwarded := map[string]string{
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "greetd.example.com, proxy.internal",
+	}
+
+	t.Run("yaml", func(t *testing.T) {
+		rec := get("/greetd/swagger/openapi.yaml", "203.0.113.9:1234", forwarded)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var spec specServers
+		require.NoError(t, yaml.Unmarshal(rec.Body.Bytes(), &spec))
+		require.Len(t, spec.Servers, 2)
+		// Forwarding headers from an untrusted peer are ignored.
+		assert.Equal(t, "http://greetd.internal:8080/greetd", spec.Servers[0].URL)
+		assert.Equal(t, "http://localhost:8080", spec.Servers[1].URL)
+	})
+
+	t.Run("json from a trusted proxy", func(t *testing.T) {
+		rec := get("/greetd/swagger/openapi.json", "10.0.0.5:1234", forwarded)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json; charset=UTF-8", rec.Header().Get("Content-Type"))
+
+		var doc map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+		assert.Equal(t, "3.1.0", doc["openapi"])
+		// Unquoted status codes become string keys.
+		assert.Contains(t, rec.Body.String(), `"200": {`)
+
+		var spec specServers
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &spec))
+		require.Len(t, spec.Servers, 2)
+		assert.Equal(t, "https://greetd.example.com/greetd", spec.Servers[0].URL)
+		assert.Equal(t, "This server", spec.Servers[0].Description)
+
+		etag := rec.Header().Get("ETag")
+		assert.NotEmpty(t, etag)
+		assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+
+		rec = get("/greetd/swagger/openapi.json", "10.0.0.5:1234", map[string]string{
+			"X-Forwarded-Proto": "https",
+			"X-Forwarded-Host":  "greetd.example.com",
+			"If-None-Match":     etag,
+		})
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+
+		// A different host gets a different spec, so the ETag no longer matches.
+		rec = get("/greetd/swagger/openapi.json", "10.0.0.5:1234", map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("changed file is reloaded", func(t *testing.T) {
+		updated := testSpec + "x-updated: true\n"
+		require.NoError(t, os.WriteFile(filepath.Join(specDir, "api", "openapi.yaml"), []byte(updated), 0644))
+
+		rec := get("/greetd/swagger/openapi.json", "203.0.113.9:1234", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"x-updated": true`)
+	})
+}
+
+func TestSwaggerSpecNotFound(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server, _ := setupServer(t, nil)
+
+	for _, path := range []string{"/swagger/openapi.yaml", "/swagger/openapi.json"} {
+		rec := httptest.NewRecorder()
+		server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code, path)
+	}
+}
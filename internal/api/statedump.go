@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/svanhalla/prompt-lab/greetd/internal/version"
+)
+
+// WriteStateDump writes the configuration summary, the state of the message
+// store, the metrics and the stacks of all goroutines to
+// state-<timestamp>.txt in the state directory, for looking into a server
+// that is stuck but still running, and returns its path.
+func (s *Server) WriteStateDump() (string, error) {
+	now := time.Now().UTC()
+	store := s.handlers.store
+	ctx := context.Background()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "time: %s\n", now.Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "uptime: %s\n", version.Uptime().Round(time.Second))
+	fmt.Fprintf(&b, "goroutines: %d\n", runtime.NumGoroutine())
+
+	b.WriteString("\n== configuration ==\n")
+	for _, setting := range s.config.Summary(s.ManagedKeys()) {
+		if setting.Key == "address" && s.Addr() != "" {
+			setting.Value = s.Addr()
+		}
+		if setting.Key == "internal" && s.InternalAddr() != "" {
+			setting.Value = s.InternalAddr()
+		}
+		fmt.Fprintf(&b, "%s: %s\n", setting.Key, setting.Value)
+	}
+
+	b.WriteString("\n== storage ==\n")
+	fmt.Fprintf(&b, "keys: %d\n", store.Len())
+	fmt.Fprintf(&b, "read_only: %t\n", store.ReadOnly())
+	for _, key := range store.Keys(ctx) {
+		data, err := store.GetKey(ctx, key.Key)
+		if err != nil {
+			fmt.Fprintf(&b, "%s: %v\n", key.Key, err)
+			continue
+		}
+		fmt.Fprintf(&b, "%s: revision %d, %d bytes, updated %s by %q\n",
+			key.Key, data.Revision, len(data.Message), data.UpdatedAt.Format(time.RFC3339), data.UpdatedBy)
+	}
+
+	b.WriteString("\n== metrics ==\n")
+	b.WriteString(s.handlers.metricsText())
+
+	b.WriteString("\n== goroutines ==\n")
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	b.Write(buf)
+
+	path := filepath.Join(s.config.StateDir(), "state-"+now.Format("20060102T150405.000000000Z")+".txt")
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
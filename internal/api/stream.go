@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// streamQueueSize bounds the entries held for a slow stream client.
+	streamQueueSize = 100
+	// streamHeartbeat keeps idle streams open through proxies.
+	streamHeartbeat = 15 * time.Second
+)
+
+// LogStream streams new application log entries as Server-Sent Events, one
+// JSON entry per event. ?level= only sends entries at that severity or above.
+func (h *Handlers) LogStream(c echo.Context) error {
+	minLevel := logrus.TraceLevel
+	if raw := c.QueryParam("level"); raw != "" {
+		level, err := logrus.ParseLevel(raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid level %q", raw)})
+		}
+		minLevel = level
+	}
+
+	entries, unsubscribe := h.logs.Subscribe(streamQueueSize)
+	defer unsubscribe()
+
+	res := c.Response()
+	// Streams outlive server.write_timeout by design.
+	if err := http.NewResponseController(res.Writer).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.logger.WithError(err).Warn("Failed to clear the write deadline for the log stream")
+	}
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no") // disable nginx buffering
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-h.done:
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case entry, ok := <-entries:
+			if !ok {
+				return nil
+			}
+			if level, err := logrus.ParseLevel(entry.Level); err == nil && level > minLevel {
+				continue
+			}
+
+			data, err := json.Marshal(entry)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(res, "data: %s\n\n", data); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
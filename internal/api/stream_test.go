@@ -0,0 +1,87 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/logging"
+)
+
+// readEvent returns the data of the next SSE event, skipping comments.
+func readEvent(t *testing.T, reader *bufio.Reader) logging.Entry {
+	t.Helper()
+
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+
+		if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+			var entry logging.Entry
+			require.NoError(t, json.Unmarshal([]byte(data), &entry))
+			return entry
+		}
+	}
+}
+
+func TestLogStream(t *testing.T) {
+	server, logger := setupServer(t, nil)
+	ts := httptest.NewServer(server.echo)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/logs/stream?level=warn")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// The handler subscribes before sending headers, so nothing logged from
+	// here on can be missed.
+	logger.Info("filtered out")
+	logger.WithField("code", 42).Warn("streamed warning")
+
+	entry := readEvent(t, bufio.NewReader(resp.Body))
+	assert.Equal(t, "streamed warning", entry.Message)
+	assert.Equal(t, "warning", entry.Level)
+	assert.Equal(t, float64(42), entry.Fields["code"])
+}
+
+func TestLogStreamEndsOnShutdown(t *testing.T) {
+	server, _ := setupServer(t, nil)
+	ts := httptest.NewServer(server.echo)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/logs/stream")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = bufio.NewReader(resp.Body).ReadString('x') // blocks until the stream ends
+		close(done)
+	}()
+
+	server.handlers.Close()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not end after Close")
+	}
+}
+
+func TestLogStreamInvalidLevel(t *testing.T) {
+	server, _ := setupServer(t, nil)
+
+	rec := httptest.NewRecorder()
+	server.echo.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/logs/stream?level=loud", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestTimeout answers requests whose handler runs longer than timeout
+// with 503 as soon as the deadline passes. The handler writes into a buffer
+// that is only sent when it returns in time, so late output is discarded.
+// The request context is cancelled at the deadline, and the middleware
+// returns once the handler does. Skipped requests, such as long-lived
+// streams, run without a timeout. Timeouts are logged with their URI
+// redacted by redact.
+func RequestTimeout(logger *logrus.Logger, timeout time.Duration, redact *Redactor, skipper middleware.Skipper) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if timeout <= 0 || (skipper != nil && skipper(c)) {
+				return next(c)
+			}
+
+			req := c.Request()
+			ctx, cancel := context.WithTimeout(req.Context(), timeout)
+			defer cancel()
+			c.SetRequest(req.WithContext(ctx))
+
+			res := c.Response()
+			buffer := &timeoutWriter{header: res.Header().Clone()}
+			c.SetResponse(echo.NewResponse(buffer, c.Echo()))
+
+			done := make(chan error, 1)
+			panicked := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						// The stack of this goroutine is lost once the
+						// panic is raised again below.
+						panicked <- recoveredPanic(p)
+					}
+				}()
+				done <- next(c)
+			}()
+
+			select {
+			case err := <-done:
+				c.SetResponse(res)
+				buffer.flushTo(res)
+				return err
+			case p := <-panicked:
+				c.SetResponse(res)
+				panic(p)
+			case <-ctx.Done():
+				buffer.expire()
+				logger.WithFields(logrus.Fields{
+					"method":  req.Method,
+					"uri":     redact.URI(req.RequestURI),
+					"timeout": true,
+				}).Warnf("Request exceeded the %s request timeout", timeout)
+
+				// res is no longer reachable from the handler, which keeps
+				// the buffered response, so it is safe to answer here.
+				body, _ := json.Marshal(ProblemDetails{
+					Type:   "about:blank",
+					Title:  http.StatusText(http.StatusServiceUnavailable),
+					Status: http.StatusServiceUnavailable,
+					Detail: "The request timed out",
+				})
+				res.Header().Set(echo.HeaderContentType, mimeProblemJSON)
+				res.Header().Set(echo.HeaderContentLength, strconv.Itoa(len(body)))
+				res.WriteHeader(http.StatusServiceUnavailable)
+				res.Write(body)
+				res.Flush()
+
+				// The client has its answer, but echo reuses the context
+				// once this returns, so wait for the handler to let go.
+				select {
+				case <-done:
+				case <-panicked:
+				}
+				c.SetResponse(res)
+				return nil
+			}
+		}
+	}
+}
+
+// timeoutWriter buffers a response until the handler returns. Writes after
+// the deadline fail with http.ErrHandlerTimeout.
+type timeoutWriter struct {
+	mu      sync.Mutex
+	header  http.Header
+	body    bytes.Buffer
+	status  int
+	expired bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired || w.status != 0 {
+		return
+	}
+	w.status = status
+}
+
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(p)
+}
+
+// expire discards the buffered response and rejects further writes.
+func (w *timeoutWriter) expire() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.expired = true
+	w.body.Reset()
+}
+
+// flushTo sends the buffered headers, status and body to res. Nothing is
+// sent when the handler wrote nothing, leaving the response to the error
+// handler.
+func (w *timeoutWriter) flushTo(res *echo.Response) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	header := res.Header()
+	for key := range header {
+		delete(header, key)
+	}
+	for key, values := range w.header {
+		header[key] = values
+	}
+
+	if w.status == 0 {
+		return
+	}
+	res.WriteHeader(w.status)
+	res.Write(w.body.Bytes())
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+
+	e := echo.New()
+	e.Use(RequestTimeout(logger, 50*time.Millisecond, nil, func(c echo.Context) bool {
+		return c.Path() == "/stream"
+	}))
+
+	slow := func(c echo.Context) error {
+		select {
+		case <-c.Request().Context().Done():
+		case <-time.After(time.Second):
+		}
+		return c.String(http.StatusOK, "late")
+	}
+	e.GET("/slow", slow)
+	e.GET("/stream", func(c echo.Context) error {
+		time.Sleep(100 * time.Millisecond)
+		return c.String(http.StatusOK, "streamed")
+	})
+	e.GET("/fast", func(c echo.Context) error {
+		c.Response().Header().Set("X-Test", "yes")
+		return c.String(http.StatusCreated, "fast")
+	})
+	e.GET("/fails", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusTeapot, "no")
+	})
+
+	t.Run("slow handler times out", func(t *testing.T) {
+		buf.Reset()
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, mimeProblemJSON, rec.Header().Get(echo.HeaderContentType))
+		assert.JSONEq(t, `{"type":"about:blank","title":"Service Unavailable","status":503,"detail":"The request timed out"}`, rec.Body.String())
+
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.Equal(t, true, entry["timeout"])
+		assert.Equal(t, "/slow", entry["uri"])
+		assert.Equal(t, "warning", entry["level"])
+	})
+
+	t.Run("skipped route runs to completion", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "streamed", rec.Body.String())
+	})
+
+	t.Run("fast handler response is passed through", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast", nil))
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "yes", rec.Header().Get("X-Test"))
+		assert.Equal(t, "fast", rec.Body.String())
+	})
+
+	t.Run("handler errors reach the error handler", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fails", nil))
+
+		assert.Equal(t, http.StatusTeapot, rec.Code)
+	})
+}
+
+func TestRequestTimeoutLoggedStatus(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+
+	e := echo.New()
+	e.Use(RequestLogger(logger, RequestLogOptions{SampleRate: 1}))
+	e.Use(RequestTimeout(logger, 20*time.Millisecond, nil, nil))
+	e.GET("/slow", func(c echo.Context) error {
+		<-c.Request().Context().Done()
+		return c.Request().Context().Err()
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	decoder := json.NewDecoder(&buf)
+	var timeoutEntry, requestEntry map[string]interface{}
+	require.NoError(t, decoder.Decode(&timeoutEntry))
+	require.NoError(t, decoder.Decode(&requestEntry))
+	assert.Equal(t, true, timeoutEntry["timeout"])
+	assert.Equal(t, float64(http.StatusServiceUnavailable), requestEntry["status"])
+}
+
+func TestServerTimeouts(t *testing.T) {
+	server, _ := setupServer(t, func(cfg *config.Config) {
+		cfg.Server.ReadTimeout = "5s"
+		cfg.Server.WriteTimeout = "0"
+		cfg.Server.IdleTimeout = "1m"
+	})
+
+	assert.Equal(t, 5*time.Second, server.echo.Server.ReadTimeout)
+	assert.Zero(t, server.echo.Server.WriteTimeout)
+	assert.Equal(t, time.Minute, server.echo.Server.IdleTimeout)
+}
@@ -0,0 +1,457 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultServer is the server URL used when none is given.
+const DefaultServer = "http://localhost:8080"
+
+// apiPrefix is where the server mounts the versioned JSON API.
+const apiPrefix = "/api/v1"
+
+// statsPath is where the request stats are served, outside the versioned
+// API.
+const statsPath = "/api/stats"
+
+// defaultKey is the key of the message served by /message.
+const defaultKey = "default"
+
+// Client talks to a running greetd API server.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+// Health is the part of the health response the client reads.
+type Health struct {
+	Status string `json:"status"`
+}
+
+// Probe is the answer of the health or readiness endpoint.
+type Probe struct {
+	StatusCode int
+	// Status is "ok" or "degraded" for /health, and "ready" or "not
+	// ready" for /readyz.
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+	// Body is the response as sent.
+	Body json.RawMessage `json:"-"`
+}
+
+// maxProbeBody bounds the health response Probe reads.
+const maxProbeBody = 1 << 20
+
+// Greeting is a greeting returned by the server.
+type Greeting struct {
+	Message string `json:"message"`
+	Lang    string `json:"lang"`
+}
+
+// Message is the stored message.
+type Message struct {
+	Message string `json:"message"`
+}
+
+// Revision is a stored revision of a message.
+type Revision struct {
+	Message string `json:"message"`
+	// UpdatedAt is nil for the default message, which was never set.
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	Revision  int64      `json:"revision"`
+}
+
+// History lists the revisions of a message the server still knows, newest
+// first.
+type History struct {
+	Key       string     `json:"key"`
+	Revisions []Revision `json:"revisions"`
+}
+
+// Maintenance is the maintenance mode of the server.
+type Maintenance struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+}
+
+// Stats are the request stats of the server since it started or the stats
+// were last reset.
+type Stats struct {
+	Since        time.Time         `json:"since"`
+	Requests     uint64            `json:"requests"`
+	BytesOut     uint64            `json:"bytes_out"`
+	Status       map[string]uint64 `json:"status"`
+	Routes       []RouteStats      `json:"routes"`
+	RecentErrors []RequestError    `json:"recent_errors"`
+}
+
+// RouteStats are the requests of one route, with latencies in
+// milliseconds.
+type RouteStats struct {
+	Method string  `json:"method"`
+	Route  string  `json:"route"`
+	Count  uint64  `json:"count"`
+	MeanMS float64 `json:"mean_ms"`
+	P95MS  float64 `json:"p95_ms"`
+}
+
+// RequestError is a request the server answered with a 5xx status.
+type RequestError struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	Method    string    `json:"method"`
+	URI       string    `json:"uri"`
+	Status    int       `json:"status"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Error is returned when the server responds with a non-2xx status.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("server returned %d", e.StatusCode)
+	}
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Message)
+}
+
+func New(baseURL, apiKey string) *Client {
+	return NewWithHTTPClient(baseURL, apiKey, &http.Client{
+		Timeout: 10 * time.Second,
+	})
+}
+
+// NewWithHTTPClient returns a client that makes its requests with
+// httpClient, such as one from httpclient.New that goes through a proxy.
+func NewWithHTTPClient(baseURL, apiKey string, httpClient *http.Client) *Client {
+	if baseURL == "" {
+		baseURL = DefaultServer
+	}
+
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: httpClient,
+	}
+}
+
+func (c *Client) GetLogLevel(ctx context.Context) (string, error) {
+	var out LogLevel
+	if err := c.do(ctx, http.MethodGet, "/admin/loglevel", nil, &out); err != nil {
+		return "", err
+	}
+	return out.Level, nil
+}
+
+func (c *Client) SetLogLevel(ctx context.Context, level string) (string, error) {
+	var out LogLevel
+	if err := c.do(ctx, http.MethodPut, "/admin/loglevel", LogLevel{Level: level}, &out); err != nil {
+		return "", err
+	}
+	return out.Level, nil
+}
+
+func (c *Client) GetMaintenance(ctx context.Context) (*Maintenance, error) {
+	var out Maintenance
+	if err := c.do(ctx, http.MethodGet, "/admin/maintenance", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetMaintenance enables or disables maintenance mode. message is shown to
+// users while it is enabled; "" uses the server's default text.
+func (c *Client) SetMaintenance(ctx context.Context, enabled bool, message string) (*Maintenance, error) {
+	var out Maintenance
+	if err := c.do(ctx, http.MethodPost, "/admin/maintenance", Maintenance{Enabled: enabled, Message: message}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	var out Health
+	if err := c.do(ctx, http.MethodGet, apiPrefix+"/health", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Probe fetches /health, or /readyz when ready is set. Unlike the other
+// methods it returns the answer whatever its status code, since /readyz
+// answers 503 with its checks while the server is not ready; an error means
+// the server could not be reached or did not answer with a health response.
+func (c *Client) Probe(ctx context.Context, ready bool) (*Probe, error) {
+	path := apiPrefix + "/health"
+	if ready {
+		path = apiPrefix + "/readyz"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	probe := Probe{StatusCode: resp.StatusCode, Body: body}
+	if err := json.Unmarshal(body, &probe); err != nil || probe.Status == "" {
+		return nil, &Error{StatusCode: resp.StatusCode, Message: "not a health response"}
+	}
+	return &probe, nil
+}
+
+func (c *Client) Hello(ctx context.Context, name string) (*Greeting, error) {
+	var out Greeting
+	if err := c.do(ctx, http.MethodGet, apiPrefix+"/hello?name="+url.QueryEscape(name), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetMessage(ctx context.Context) (string, error) {
+	var out Message
+	if err := c.do(ctx, http.MethodGet, apiPrefix+"/message", nil, &out); err != nil {
+		return "", err
+	}
+	return out.Message, nil
+}
+
+func (c *Client) SetMessage(ctx context.Context, message string) (string, error) {
+	var out Message
+	if err := c.do(ctx, http.MethodPost, apiPrefix+"/message", Message{Message: message}, &out); err != nil {
+		return "", err
+	}
+	return out.Message, nil
+}
+
+// Stats returns the request stats of the server; it needs the API key.
+func (c *Client) Stats(ctx context.Context) (*Stats, error) {
+	var out Stats
+	if err := c.do(ctx, http.MethodGet, statsPath, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ResetStats clears the request stats; it needs the API key.
+func (c *Client) ResetStats(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, statsPath, nil, nil)
+}
+
+// Get requests path, such as /api/v1/hello?name=Ann, and discards the
+// response. It returns an *Error unless the server answers with 2xx.
+func (c *Client) Get(ctx context.Context, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Reading the body to the end lets the connection be reused.
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &Error{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// SetKeyedMessage stores message under the named message key.
+func (c *Client) SetKeyedMessage(ctx context.Context, key, message string) (string, error) {
+	var out Message
+	if err := c.do(ctx, http.MethodPost, apiPrefix+"/messages/"+url.PathEscape(key), Message{Message: message}, &out); err != nil {
+		return "", err
+	}
+	return out.Message, nil
+}
+
+// History returns the revisions of the message stored under key, or of the
+// default message for storage's default key.
+func (c *Client) History(ctx context.Context, key string) (*History, error) {
+	path := apiPrefix + "/messages/" + url.PathEscape(key) + "/history"
+	if key == defaultKey {
+		path = apiPrefix + "/message/history"
+	}
+	var out History
+	if err := c.do(ctx, http.MethodGet, path, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteKeyedMessage deletes the named message key; it needs the API key.
+func (c *Client) DeleteKeyedMessage(ctx context.Context, key string) error {
+	return c.do(ctx, http.MethodDelete, apiPrefix+"/messages/"+url.PathEscape(key), nil, nil)
+}
+
+// SetMaxIdleConns keeps up to n idle connections to the server open, so n
+// concurrent callers reuse connections instead of opening new ones. The
+// proxy and TLS settings of the transport are kept.
+func (c *Client) SetMaxIdleConns(n int) {
+	transport, ok := c.httpClient.Transport.(*http.Transport)
+	if !ok {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+	transport = transport.Clone()
+	transport.MaxIdleConnsPerHost = n
+	c.httpClient.Transport = transport
+}
+
+// Page fetches an HTML page such as /ui and reports an error unless the
+// server answers with a 2xx HTML response.
+func (c *Client) Page(ctx context.Context, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &Error{StatusCode: resp.StatusCode}
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+		return fmt.Errorf("unexpected content type %q", contentType)
+	}
+	return nil
+}
+
+// Profile downloads a pprof profile such as "heap", "goroutine" or
+// "profile" (CPU) to w. seconds, when positive, sets how long CPU profiles
+// and traces sample; the request is not bound by the client timeout then.
+func (c *Client) Profile(ctx context.Context, profileType string, seconds int, w io.Writer) error {
+	path := "/debug/pprof/" + url.PathEscape(profileType)
+	if seconds > 0 {
+		path += "?seconds=" + strconv.Itoa(seconds)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	httpClient := *c.httpClient
+	if seconds > 0 {
+		httpClient.Timeout += time.Duration(seconds) * time.Second
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// pprof answers errors in plain text, the API key check in JSON.
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		var errBody struct {
+			Error string `json:"error"`
+		}
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &errBody) == nil {
+			message = errBody.Error
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: message}
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to download profile: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// Problem details, such as the maintenance response, carry the
+		// message in detail.
+		var errBody struct {
+			Error  string `json:"error"`
+			Detail string `json:"detail"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&errBody)
+		message := errBody.Error
+		if message == "" {
+			message = errBody.Detail
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: message}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
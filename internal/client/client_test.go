@@ -0,0 +1,339 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogLevel(t *testing.T) {
+	level := "info"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/admin/loglevel", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("X-API-Key"))
+
+		if r.Method == http.MethodPut {
+			var req LogLevel
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			level = req.Level
+		}
+		json.NewEncoder(w).Encode(LogLevel{Level: level})
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL+"/", "secret")
+
+	got, err := c.GetLogLevel(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "info", got)
+
+	got, err = c.SetLogLevel(context.Background(), "debug")
+	require.NoError(t, err)
+	assert.Equal(t, "debug", got)
+	assert.Equal(t, "debug", level)
+}
+
+func TestErrorResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"API key required"}`))
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL, "").GetLogLevel(context.Background())
+	require.Error(t, err)
+
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Equal(t, "API key required", apiErr.Message)
+}
+
+func TestMaintenance(t *testing.T) {
+	state := Maintenance{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/admin/maintenance", r.URL.Path)
+		if r.Method == http.MethodPost {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&state))
+		}
+		json.NewEncoder(w).Encode(state)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "secret")
+	got, err := c.SetMaintenance(context.Background(), true, "Back soon")
+	require.NoError(t, err)
+	assert.Equal(t, &Maintenance{Enabled: true, Message: "Back soon"}, got)
+
+	got, err = c.GetMaintenance(context.Background())
+	require.NoError(t, err)
+	assert.True(t, got.Enabled)
+}
+
+func TestStats(t *testing.T) {
+	reset := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/stats", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("X-API-Key"))
+		if r.Method == http.MethodDelete {
+			reset = true
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{"requests": 3, "routes": [{"method": "GET", "route": "/api/v1/hello", "count": 3, "mean_ms": 1.5, "p95_ms": 2}]}`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "secret")
+	stats, err := c.Stats(context.Background())
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, stats.Requests)
+	assert.Equal(t, []RouteStats{{Method: "GET", Route: "/api/v1/hello", Count: 3, MeanMS: 1.5, P95MS: 2}}, stats.Routes)
+
+	require.NoError(t, c.ResetStats(context.Background()))
+	assert.True(t, reset)
+}
+
+func TestProblemResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"type":"about:blank","title":"Service Unavailable","status":503,"detail":"Back soon"}`))
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL, "").GetMessage(context.Background())
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "Back soon", apiErr.Message)
+}
+
+func TestMessageAndGreeting(t *testing.T) {
+	stored := "Hello, World!"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v1/health":
+			json.NewEncoder(w).Encode(Health{Status: "ok"})
+		case "GET /api/v1/hello":
+			json.NewEncoder(w).Encode(Greeting{Message: "Hello, " + r.URL.Query().Get("name") + "!", Lang: "en"})
+		case "POST /api/v1/message":
+			var req Message
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			stored = req.Message
+			fallthrough
+		case "GET /api/v1/message":
+			json.NewEncoder(w).Encode(Message{Message: stored})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "")
+	ctx := context.Background()
+
+	health, err := c.Health(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", health.Status)
+
+	greeting, err := c.Hello(ctx, "Ann & Bo")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Ann & Bo!", greeting.Message)
+
+	got, err := c.SetMessage(ctx, "updated")
+	require.NoError(t, err)
+	assert.Equal(t, "updated", got)
+
+	got, err = c.GetMessage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "updated", got)
+}
+
+func TestKeyedMessageAndGet(t *testing.T) {
+	stored := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/api/v1/messages/")
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Path == "/api/v1/hello" {
+				w.Write([]byte(`{"message":"Hello, World!"}`))
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"not found"}`))
+		case http.MethodPost:
+			var req Message
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			stored[key] = req.Message
+			json.NewEncoder(w).Encode(req)
+		case http.MethodDelete:
+			if r.Header.Get("X-API-Key") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			delete(stored, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	c := New(srv.URL, "secret")
+	require.NoError(t, c.Get(ctx, "/api/v1/hello"))
+	var apiErr *Error
+	require.ErrorAs(t, c.Get(ctx, "/missing"), &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+
+	got, err := c.SetKeyedMessage(ctx, "bench", "hi")
+	require.NoError(t, err)
+	assert.Equal(t, "hi", got)
+	assert.Equal(t, map[string]string{"bench": "hi"}, stored)
+
+	require.Error(t, New(srv.URL, "").DeleteKeyedMessage(ctx, "bench"))
+	require.NoError(t, c.DeleteKeyedMessage(ctx, "bench"))
+	assert.Empty(t, stored)
+}
+
+func TestHistory(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/message/history":
+			w.Write([]byte(`{"key":"default","revisions":[{"message":"Hi","updated_at":"2026-10-15T08:00:00Z","updated_by":"cli","revision":1},{"message":"Hello, World!","revision":0}]}`))
+		case "/api/v1/messages/motd/history":
+			w.Write([]byte(`{"key":"motd","revisions":[{"message":"Welcome","updated_at":"2026-10-15T09:00:00Z","revision":1}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"Message not found"}`))
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	c := New(srv.URL, "")
+
+	history, err := c.History(ctx, "default")
+	require.NoError(t, err)
+	require.Len(t, history.Revisions, 2)
+	assert.Equal(t, int64(1), history.Revisions[0].Revision)
+	assert.Equal(t, "cli", history.Revisions[0].UpdatedBy)
+	require.NotNil(t, history.Revisions[0].UpdatedAt)
+	assert.Nil(t, history.Revisions[1].UpdatedAt)
+
+	history, err = c.History(ctx, "motd")
+	require.NoError(t, err)
+	assert.Equal(t, "motd", history.Key)
+
+	_, err = c.History(ctx, "missing")
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
+
+func TestPage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ui":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html></html>"))
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("{}"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "")
+	ctx := context.Background()
+
+	assert.NoError(t, c.Page(ctx, "/ui"))
+	assert.ErrorContains(t, c.Page(ctx, "/json"), "content type")
+
+	var apiErr *Error
+	require.ErrorAs(t, c.Page(ctx, "/missing"), &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
+
+func TestProfile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"API key required"}`))
+			return
+		}
+		if r.URL.Path != "/debug/pprof/heap" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("Unknown profile\n"))
+			return
+		}
+		assert.Equal(t, "5", r.URL.Query().Get("seconds"))
+		w.Write([]byte("profile-data"))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	require.NoError(t, New(srv.URL, "secret").Profile(context.Background(), "heap", 5, &buf))
+	assert.Equal(t, "profile-data", buf.String())
+
+	var apiErr *Error
+	err := New(srv.URL, "secret").Profile(context.Background(), "nonsense", 0, io.Discard)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "Unknown profile", apiErr.Message)
+
+	err = New(srv.URL, "").Profile(context.Background(), "heap", 0, io.Discard)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "API key required", apiErr.Message)
+}
+
+func TestProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/readyz":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"not ready","checks":{"maintenance":"enabled"}}`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`<html></html>`))
+		}
+	}))
+	defer srv.Close()
+	c := New(srv.URL, "")
+
+	probe, err := c.Probe(context.Background(), true)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, probe.StatusCode)
+	assert.Equal(t, "not ready", probe.Status)
+	assert.Equal(t, map[string]string{"maintenance": "enabled"}, probe.Checks)
+	assert.JSONEq(t, `{"status":"not ready","checks":{"maintenance":"enabled"}}`, string(probe.Body))
+
+	_, err = c.Probe(context.Background(), false)
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+}
+
+func TestSetMaxIdleConnsKeepsTransport(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(LogLevel{Level: "info"})
+	}))
+	defer srv.Close()
+
+	// srv.Client trusts the server's certificate; the default transport
+	// does not.
+	c := NewWithHTTPClient(srv.URL, "", srv.Client())
+	c.SetMaxIdleConns(4)
+
+	got, err := c.GetLogLevel(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "info", got)
+}
@@ -2,49 +2,81 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 	"github.com/svanhalla/prompt-lab/greetd/internal/api"
-	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/httpclient"
+	"github.com/svanhalla/prompt-lab/greetd/internal/systemd"
+	"github.com/svanhalla/prompt-lab/greetd/internal/update"
+	"github.com/svanhalla/prompt-lab/greetd/internal/version"
+)
+
+// Exit codes of greetd api besides 1 for other failures.
+const (
+	apiExitConfig = 2
+	apiExitBind   = 3
 )
 
 var (
-	host string
-	port int
+	host      string
+	port      int
+	portRetry int
+	portFile  string
+	pidFile   string
+
+	enablePprof     bool
+	devMode         bool
+	strictTemplates bool
+	dumpBodies      bool
+	debugErrors     bool
 )
 
 var apiCmd = &cobra.Command{
 	Use:   "api",
 	Short: "Start the HTTP API and Web server",
+	Long: `Starts the HTTP API and Web server. With --port 0 a free port is chosen;
+the address is logged and, with --port-file, written to a file once the
+server accepts connections. When the port is in use, --port-retry tries
+that many following ports in turn and logs the one taken.
+
+The exit code is 2 when the configuration is invalid and 3 when the address
+cannot be bound, such as when another instance holds the port.
+
+With update.check on, the server looks for a newer release at start and
+then once a day, logs it and shows it on /status; nothing is installed.
+
+SIGUSR1 rotates app.log and the access log, and SIGUSR2 writes a state
+dump with the effective configuration, the stored messages, the metrics and
+all goroutine stacks to state-<timestamp>.txt in the state directory.
+
+When NOTIFY_SOCKET is set, as under a systemd Type=notify service, READY=1
+is sent once the server accepts connections, STOPPING=1 when it shuts down,
+and watchdog heartbeats when WatchdogSec is configured.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		cfg, err := loadConfigAndLogger()
+		cfg, logger, err := loadConfigAndLogger(cmd)
 		if err != nil {
 			fmt.Printf("Error loading config: %v\n", err)
-			os.Exit(1)
-		}
-
-		logger := globalLogger.(*logrus.Logger)
-
-		// Override with flags if provided
-		if host != "" {
-			cfg.Server.Host = host
-		}
-		if port != 0 {
-			cfg.Server.Port = port
+			os.Exit(apiExitConfig)
 		}
 
 		// Initialize message store
-		store := storage.NewMessageStore(cfg.DataPath)
-		if err := store.Load(); err != nil {
+		store, err := loadMessageStore(cmd.Context(), cfg)
+		if err != nil {
 			logger.WithError(err).Fatal("Failed to load message store")
 		}
+		if store.ReadOnly() {
+			logger.Warnf("%s is not writable; serving the stored message read-only", cfg.StateDir())
+		}
 
 		// Create and start server
 		server, err := api.NewServer(cfg, store, logger)
@@ -52,17 +84,47 @@ var apiCmd = &cobra.Command{
 			logger.WithError(err).Fatal("Failed to create server")
 		}
 
+		// Bind before anything reports the server as started.
+		if err := server.Listen(); err != nil {
+			logger.WithError(err).Error("Server failed to start")
+			fmt.Fprintln(cmd.ErrOrStderr(), bindErrorMessage(err))
+			os.Exit(apiExitBind)
+		}
+		logSummary(logger, cfg, server)
+		if portFile != "" {
+			if err := os.WriteFile(portFile, []byte(server.Addr()+"\n"), 0644); err != nil {
+				logger.WithError(err).Fatal("Failed to write port file")
+			}
+			defer os.Remove(portFile)
+		}
+		if pidFile != "" {
+			if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+				logger.WithError(err).Fatal("Failed to write PID file")
+			}
+			defer os.Remove(pidFile)
+		}
+
 		// Graceful shutdown
 		go func() {
-			if err := server.Start(); err != nil {
+			if err := server.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 				logger.WithError(err).Fatal("Server failed to start")
 			}
 		}()
 
+		// The listener is bound, so connections are accepted from here on.
+		notifySystemd(logger, systemd.Ready)
+		stopWatchdog := startWatchdog(logger, systemd.WatchdogInterval())
+		defer stopWatchdog()
+		stopSignals := handleSignals(logger, server)
+		defer stopSignals()
+		stopUpdateCheck := startUpdateCheck(logger, cfg, server)
+		defer stopUpdateCheck()
+
 		// Wait for interrupt signal
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 		<-quit
+		notifySystemd(logger, systemd.Stopping)
 
 		// Shutdown with timeout
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -74,12 +136,144 @@ var apiCmd = &cobra.Command{
 	},
 }
 
+// bindErrorMessage explains a failure to listen, with what to do when the
+// port is in use.
+func bindErrorMessage(err error) string {
+	msg := "Error: cannot start the server: " + err.Error()
+	var bindErr *api.BindError
+	if errors.As(err, &bindErr) && bindErr.InUse() {
+		msg += "\nStop the process holding the port, choose another one with --port or server.port, or start with --port-retry to try the next ports."
+	}
+	return msg
+}
+
+// logSummary logs the effective configuration as one entry with a field
+// per setting, with the addresses the server listens on, which port 0 only
+// picks at Listen.
+func logSummary(logger *logrus.Logger, cfg *config.Config, server *api.Server) {
+	summary := cfg.Summary(server.ManagedKeys())
+	for i, setting := range summary {
+		switch {
+		case setting.Key == "address":
+			summary[i].Value = server.Addr()
+		case setting.Key == "internal" && server.InternalAddr() != "":
+			summary[i].Value = server.InternalAddr()
+		}
+	}
+	summary = append(summary, config.Setting{Key: "web_ui", Value: server.URL()})
+
+	fields := make(logrus.Fields, len(summary))
+	for _, setting := range summary {
+		fields[setting.Key] = setting.Value
+	}
+	logger.WithFields(fields).Info("Starting greetd")
+}
+
+// notifySystemd sends state to the service manager, if there is one.
+func notifySystemd(logger *logrus.Logger, state string) {
+	sent, err := systemd.Notify(state)
+	if err != nil {
+		logger.WithError(err).Warnf("Failed to send %s to the service manager", state)
+		return
+	}
+	if sent {
+		logger.Debugf("Sent %s to the service manager", state)
+	}
+}
+
+// startWatchdog sends watchdog heartbeats at half of interval until the
+// returned function is called. A zero interval disables it.
+func startWatchdog(logger *logrus.Logger, interval time.Duration) func() {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				notifySystemd(logger, systemd.Watchdog)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// startUpdateCheck looks for a newer release when update.check is on, at
+// once and then daily until the returned function is called, and logs the
+// result and shows it on /status. Failures are logged and leave the
+// status unknown.
+func startUpdateCheck(logger *logrus.Logger, cfg *config.Config, server *api.Server) func() {
+	if !cfg.Update.Check {
+		return func() {}
+	}
+
+	httpClient, err := httpclient.New(cfg.Outbound.Options())
+	if err != nil {
+		logger.WithError(err).Warn("Update check disabled: invalid outbound settings")
+		return func() {}
+	}
+	checker := &update.Checker{
+		Client:   httpClient,
+		URL:      cfg.Update.ReleasesURL,
+		Current:  version.Get().Version,
+		CacheDir: cfg.StateDir(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(update.CacheTTL)
+		defer ticker.Stop()
+		for {
+			result, err := checker.Check(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			server.SetUpdate(result)
+			switch {
+			case err != nil:
+				logger.WithError(err).Warn("Update check failed")
+			case result.Available():
+				logger.WithFields(logrus.Fields{"latest": result.Latest, "url": result.URL}).Info("Update available")
+			default:
+				logger.WithField("latest", result.Latest).Debug("greetd is up to date")
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
 func init() {
 	apiCmd.Flags().StringVar(&host, "host", "", "server host")
-	apiCmd.Flags().IntVar(&port, "port", 0, "server port")
-
-	viper.BindPFlag("server.host", apiCmd.Flags().Lookup("host"))
-	viper.BindPFlag("server.port", apiCmd.Flags().Lookup("port"))
+	apiCmd.Flags().IntVar(&port, "port", 0, "server port (0 picks a free port)")
+	apiCmd.Flags().IntVar(&portRetry, "port-retry", 0, "when the port is in use, try up to this many following ports")
+	apiCmd.Flags().StringVar(&portFile, "port-file", "", "write the listening address to this file")
+	apiCmd.Flags().StringVar(&pidFile, "pid-file", "", "write the process ID to this file while running")
+	apiCmd.Flags().BoolVar(&enablePprof, "enable-pprof", false, "serve pprof profiles under /debug/pprof/ (requires an API key)")
+	apiCmd.Flags().BoolVar(&devMode, "dev", false, "serve the page templates from ui.templates_path with hot reload")
+	apiCmd.Flags().BoolVar(&dumpBodies, "dump-bodies", false, "log request and response bodies of the JSON API at debug level")
+	apiCmd.Flags().BoolVar(&debugErrors, "debug", false, "include errors and stacks in 500 responses (not for production)")
+	apiCmd.Flags().BoolVar(&strictTemplates, "strict-templates", false, "refuse to start when a page template fails to load")
 
 	rootCmd.AddCommand(apiCmd)
 }
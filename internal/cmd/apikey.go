@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+var (
+	apikeyName   string
+	apikeyOutput string
+)
+
+var apikeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "Manage the API keys of the admin endpoints",
+	Long: `Creates, lists and revokes named API keys, kept as salted SHA-256 hashes in
+apikeys.json in the state directory. They are accepted besides the keys of
+security.api_keys, and the name of the key a change was made with is
+recorded as its updated_by. A running server picks up changes to the keys
+without a restart.`,
+}
+
+var apikeyCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an API key",
+	Long: `Creates an API key named --name and prints it. The key is only printed
+this once; store it right away.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		keys := loadAPIKeys(cmd)
+		key, err := keys.Create(apikeyName)
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), key)
+		fmt.Fprintf(cmd.ErrOrStderr(), "Created API key %q. It is not shown again.\n", apikeyName)
+	},
+}
+
+var apikeyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the API keys",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if apikeyOutput != "text" && apikeyOutput != "json" {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Error: unknown output format %q (use text or json)\n", apikeyOutput)
+			os.Exit(1)
+		}
+		if err := printAPIKeys(cmd.OutOrStdout(), loadAPIKeys(cmd).List(), apikeyOutput == "json"); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var apikeyRevokeCmd = &cobra.Command{
+	Use:   "revoke <name>",
+	Short: "Revoke an API key",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := loadAPIKeys(cmd).Revoke(args[0]); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Revoked API key %q\n", args[0])
+	},
+}
+
+// loadAPIKeys loads the managed keys of the configured state directory, or
+// exits.
+func loadAPIKeys(cmd *cobra.Command) *storage.APIKeys {
+	cfg, err := config.Load(cfgFile, dataPathFlag, configFlags(cmd))
+	if err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	keys := storage.NewAPIKeys(cfg.StateDir())
+	if err := keys.Load(); err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return keys
+}
+
+// apiKeyInfo is a key in the output of greetd apikey list, without its hash.
+type apiKeyInfo struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// printAPIKeys writes keys as a table, or as JSON with asJSON.
+func printAPIKeys(w io.Writer, keys []storage.APIKey, asJSON bool) error {
+	infos := make([]apiKeyInfo, len(keys))
+	for i, key := range keys {
+		infos[i] = apiKeyInfo{Name: key.Name, CreatedAt: key.CreatedAt}
+	}
+
+	if asJSON {
+		output, err := json.MarshalIndent(infos, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal API keys: %w", err)
+		}
+		fmt.Fprintln(w, string(output))
+		return nil
+	}
+
+	if len(infos) == 0 {
+		fmt.Fprintln(w, "No API keys created yet")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tCREATED")
+	for _, info := range infos {
+		fmt.Fprintf(tw, "%s\t%s\n", info.Name, info.CreatedAt.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+func init() {
+	apikeyCreateCmd.Flags().StringVar(&apikeyName, "name", "", "name of the key, 1-64 characters of a-z, 0-9, - and _")
+	apikeyCreateCmd.MarkFlagRequired("name")
+	apikeyListCmd.Flags().StringVarP(&apikeyOutput, "output", "o", "text", "output format (text, json)")
+	apikeyListCmd.RegisterFlagCompletionFunc("output", completeValues("text", "json"))
+	apikeyCmd.AddCommand(apikeyCreateCmd)
+	apikeyCmd.AddCommand(apikeyListCmd)
+	apikeyCmd.AddCommand(apikeyRevokeCmd)
+	rootCmd.AddCommand(apikeyCmd)
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+// runAPIKeyCommand runs "greetd apikey" with args and returns its standard
+// output.
+func runAPIKeyCommand(t *testing.T, configPath string, args ...string) string {
+	t.Helper()
+
+	var out, errOut bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&errOut)
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		apikeyName = ""
+		apikeyOutput = "text"
+	})
+
+	rootCmd.SetArgs(append([]string{"apikey", "--config", configPath, "--log-output", "stdout"}, args...))
+	require.NoError(t, Execute(), errOut.String())
+	return out.String()
+}
+
+func TestAPIKeyCommands(t *testing.T) {
+	configPath, dataPath := writeTestConfig(t)
+
+	assert.Contains(t, runAPIKeyCommand(t, configPath, "list"), "No API keys created yet")
+
+	key := strings.TrimSpace(runAPIKeyCommand(t, configPath, "create", "--name", "ci-bot"))
+	require.NotEmpty(t, key)
+
+	keys := storage.NewAPIKeys(dataPath)
+	require.NoError(t, keys.Load())
+	name, ok := keys.Match(key)
+	assert.True(t, ok)
+	assert.Equal(t, "ci-bot", name)
+	data, err := os.ReadFile(filepath.Join(dataPath, storage.APIKeysFileName))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), key)
+
+	list := runAPIKeyCommand(t, configPath, "list")
+	assert.Contains(t, list, "NAME")
+	assert.Contains(t, list, "ci-bot")
+	assert.NotContains(t, list, key)
+
+	var infos []apiKeyInfo
+	require.NoError(t, json.Unmarshal([]byte(runAPIKeyCommand(t, configPath, "list", "-o", "json")), &infos))
+	require.Len(t, infos, 1)
+	assert.Equal(t, "ci-bot", infos[0].Name)
+	assert.False(t, infos[0].CreatedAt.IsZero())
+
+	assert.Contains(t, runAPIKeyCommand(t, configPath, "revoke", "ci-bot"), `Revoked API key "ci-bot"`)
+	require.NoError(t, keys.Load())
+	assert.Equal(t, 0, keys.Len())
+}
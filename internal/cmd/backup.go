@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+var (
+	backupIncludeLogs bool
+	backupForce       bool
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up and restore the data directory",
+}
+
+var backupCreateCmd = &cobra.Command{
+	Use:   "create <file.tar.gz>",
+	Short: "Write the messages, greeting statistics and config to an archive",
+	Long: `Writes messages.json, hello_stats.json and the config file to a gzipped
+tarball. The log files are added with --include-logs.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		out := cmd.OutOrStdout()
+
+		cfg, err := config.Load(cfgFile, dataPathFlag, configFlags(cmd))
+		if err != nil {
+			fmt.Fprintf(out, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		if err := createBackup(cmd.Context(), out, cfg, args[0], backupIncludeLogs); err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore <file.tar.gz>",
+	Short: "Restore the data directory from an archive",
+	Long: `Validates every file in an archive written by "greetd backup create" and
+then writes them to the state directory and config file location. Nothing is
+overwritten without --force. Stop the server before restoring, and start it
+again afterwards to pick up the restored data.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		out := cmd.OutOrStdout()
+
+		cfg, err := config.Load(cfgFile, dataPathFlag, configFlags(cmd))
+		if err != nil {
+			fmt.Fprintf(out, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		if err := restoreBackup(out, cfg, args[0], backupForce); err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// backupOptions returns where the config and log files of cfg live. The
+// config file is the one that was loaded, or the default location.
+func backupOptions(cfg *config.Config, includeLogs bool) storage.BackupOptions {
+	configPath := cfg.File
+	if configPath == "" {
+		configPath = config.DefaultConfigPath(cfg.DataPath)
+	}
+	return storage.BackupOptions{
+		ConfigPath:    configPath,
+		AppLogPath:    cfg.AppLogPath(),
+		AccessLogPath: cfg.AccessLogPath(),
+		IncludeLogs:   includeLogs,
+	}
+}
+
+// createBackup writes the archive to path, removing it again on failure.
+func createBackup(ctx context.Context, out io.Writer, cfg *config.Config, path string, includeLogs bool) error {
+	store, err := loadMessageStore(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load message store: %w", err)
+	}
+	stats := storage.NewHelloStats(cfg.StateDir())
+	if err := stats.Load(); err != nil {
+		return fmt.Errorf("failed to load greeting statistics: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := storage.WriteBackup(file, store, stats, backupOptions(cfg, includeLogs)); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	fmt.Fprintf(out, "Backup written to %s\n", path)
+	return nil
+}
+
+// restoreBackup validates the archive at path before writing any of it.
+func restoreBackup(out io.Writer, cfg *config.Config, path string, force bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	files, err := storage.ReadBackup(file, checkBackupConfig)
+	if err != nil {
+		return err
+	}
+
+	written, err := storage.RestoreBackup(files, cfg.StateDir(), backupOptions(cfg, true), force)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("refusing to overwrite existing files (use --force): %w", err)
+	}
+	for _, path := range written {
+		fmt.Fprintf(out, "Restored %s\n", path)
+	}
+	return err
+}
+
+// checkBackupConfig rejects a config.json that greetd would refuse to load.
+func checkBackupConfig(data []byte) error {
+	cfg := config.DefaultConfig()
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return err
+	}
+	return cfg.Validate()
+}
+
+func init() {
+	backupCreateCmd.Flags().BoolVar(&backupIncludeLogs, "include-logs", false, "add the log files to the archive")
+	backupRestoreCmd.Flags().BoolVar(&backupForce, "force", false, "overwrite existing files")
+	backupCmd.AddCommand(backupCreateCmd)
+	backupCmd.AddCommand(backupRestoreCmd)
+	rootCmd.AddCommand(backupCmd)
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	configPath, dataPath := writeTestConfig(t)
+	cfg, err := config.Load(configPath, "", nil)
+	require.NoError(t, err)
+
+	store := storage.NewMessageStore(dataPath)
+	require.NoError(t, store.Load(context.Background()))
+	require.NoError(t, store.SetMessage(context.Background(), "Moving hosts", storage.UpdatedByCLI))
+
+	archive := filepath.Join(t.TempDir(), "greetd.tar.gz")
+	var out bytes.Buffer
+	require.NoError(t, createBackup(context.Background(), &out, cfg, archive, false))
+	assert.Contains(t, out.String(), "Backup written to "+archive)
+
+	// Restore onto a fresh host whose config file does not exist yet.
+	target := t.TempDir()
+	restoreCfg := config.DefaultConfig()
+	restoreCfg.DataPath = target
+
+	out.Reset()
+	require.NoError(t, restoreBackup(&out, restoreCfg, archive, false))
+	assert.Contains(t, out.String(), "Restored "+filepath.Join(target, storage.MessagesFileName))
+	assert.FileExists(t, filepath.Join(target, "config.json"))
+
+	restored := storage.NewMessageStore(target)
+	require.NoError(t, restored.Load(context.Background()))
+	assert.Equal(t, "Moving hosts", restored.GetMessage(context.Background()))
+
+	err = restoreBackup(&out, restoreCfg, archive, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--force")
+	require.NoError(t, restoreBackup(&out, restoreCfg, archive, true))
+}
+
+func TestBackupRestoreRejectsInvalidConfig(t *testing.T) {
+	configPath, _ := writeTestConfig(t)
+	cfg, err := config.Load(configPath, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"logging":{"output":"syslog"}}`), 0644))
+
+	archive := filepath.Join(t.TempDir(), "greetd.tar.gz")
+	require.NoError(t, createBackup(context.Background(), &bytes.Buffer{}, cfg, archive, false))
+
+	target := config.DefaultConfig()
+	target.DataPath = t.TempDir()
+	err = restoreBackup(&bytes.Buffer{}, target, archive, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "logging.output")
+	assert.NoFileExists(t, filepath.Join(target.DataPath, storage.MessagesFileName))
+}
@@ -0,0 +1,285 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/svanhalla/prompt-lab/greetd/internal/client"
+)
+
+var (
+	benchEndpoint    string
+	benchConcurrency int
+	benchDuration    time.Duration
+	benchPostMessage bool
+	benchOutput      string
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure the throughput and latency of a running server",
+	Long: `Sends requests to a running server from --concurrency workers for
+--duration, and prints the throughput, the latency percentiles and the
+errors by status code.
+
+By default every worker requests --endpoint with GET. --post-message
+exercises the write path instead: every request stores a message under a
+temporary named message key, which is deleted afterwards, so the real
+messages are left alone. Deleting needs an API key, so --post-message
+requires --api-key and stops before the run if the server rejects it.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if benchConcurrency < 1 || benchDuration <= 0 {
+			fmt.Fprintln(cmd.ErrOrStderr(), "Error: --concurrency and --duration must be positive")
+			os.Exit(1)
+		}
+		if benchOutput != "text" && benchOutput != "json" {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Error: unknown output format %q (use text or json)\n", benchOutput)
+			os.Exit(1)
+		}
+		if benchPostMessage && clientAPIKey() == "" {
+			fmt.Fprintln(cmd.ErrOrStderr(), "Error: --post-message needs --api-key (or GREETD_API_KEY) to delete its message afterwards")
+			os.Exit(1)
+		}
+
+		c, err := newClient(cmd)
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+			os.Exit(1)
+		}
+		c.SetMaxIdleConns(benchConcurrency)
+		request, describe := benchGet(c, benchEndpoint)
+		var key string
+		if benchPostMessage {
+			key = benchKey()
+			if err := checkBenchKey(cmd.Context(), c, key); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+				os.Exit(1)
+			}
+			request, describe = benchPost(c, key)
+		}
+
+		result := runBench(cmd.Context(), benchConcurrency, benchDuration, request)
+		result.Endpoint = describe
+
+		if key != "" {
+			if err := c.DeleteKeyedMessage(context.Background(), key); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed to delete the benchmark message %q: %v\n", key, err)
+			}
+		}
+
+		if benchOutput == "json" {
+			output, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Error marshaling results: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(output))
+		} else {
+			printBench(cmd.OutOrStdout(), result)
+		}
+		if result.Requests == 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// BenchResult is what greetd bench measured.
+type BenchResult struct {
+	Endpoint    string  `json:"endpoint"`
+	Concurrency int     `json:"concurrency"`
+	Seconds     float64 `json:"seconds"`
+	// Requests counts the requests that completed, failed or not.
+	Requests   int     `json:"requests"`
+	Throughput float64 `json:"requests_per_second"`
+	Errors     int     `json:"errors"`
+	// ErrorsByStatus counts failed requests by status code, or "network"
+	// when there was no response.
+	ErrorsByStatus map[string]int `json:"errors_by_status"`
+	Latency        BenchLatency   `json:"latency_ms"`
+}
+
+// BenchLatency are request latencies in milliseconds.
+type BenchLatency struct {
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// benchGet returns a request for GET endpoint.
+func benchGet(c *client.Client, endpoint string) (func(ctx context.Context, n int) error, string) {
+	if !strings.HasPrefix(endpoint, "/") {
+		endpoint = "/" + endpoint
+	}
+	return func(ctx context.Context, n int) error {
+		return c.Get(ctx, endpoint)
+	}, "GET " + endpoint
+}
+
+// benchPost returns a request that stores a new message under key.
+func benchPost(c *client.Client, key string) (func(ctx context.Context, n int) error, string) {
+	return func(ctx context.Context, n int) error {
+		_, err := c.SetKeyedMessage(ctx, key, "greetd bench message "+strconv.Itoa(n))
+		return err
+	}, "POST /api/v1/messages/" + key
+}
+
+// checkBenchKey deletes the unused key before the run to check that the
+// server accepts the API key, which the final delete needs. The server
+// answers 404 for a key it accepts.
+func checkBenchKey(ctx context.Context, c *client.Client, key string) error {
+	err := c.DeleteKeyedMessage(ctx, key)
+	var apiErr *client.Error
+	if err == nil || errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return fmt.Errorf("cannot delete the benchmark message afterwards: %w", err)
+}
+
+// benchKey returns a named message key that no one else uses.
+func benchKey() string {
+	var b [6]byte
+	rand.Read(b[:])
+	return "bench-" + hex.EncodeToString(b[:])
+}
+
+// runBench calls request from concurrency goroutines until duration has
+// passed or ctx is done, and summarizes the outcome. Each call gets its own
+// number. Requests cut off by the end of the run are not counted.
+func runBench(ctx context.Context, concurrency int, duration time.Duration, request func(ctx context.Context, n int) error) BenchResult {
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	type worker struct {
+		latencies []time.Duration
+		errors    map[string]int
+	}
+	workers := make([]worker, concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range workers {
+		w := &workers[i]
+		w.errors = map[string]int{}
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for n := id; ctx.Err() == nil; n += concurrency {
+				began := time.Now()
+				err := request(ctx, n)
+				if ctx.Err() != nil {
+					return
+				}
+				w.latencies = append(w.latencies, time.Since(began))
+				if err != nil {
+					var apiErr *client.Error
+					if errors.As(err, &apiErr) {
+						w.errors[strconv.Itoa(apiErr.StatusCode)]++
+					} else {
+						w.errors["network"]++
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	result := BenchResult{
+		Concurrency:    concurrency,
+		Seconds:        math.Round(elapsed.Seconds()*1000) / 1000,
+		ErrorsByStatus: map[string]int{},
+	}
+	var latencies []time.Duration
+	for _, w := range workers {
+		latencies = append(latencies, w.latencies...)
+		for status, count := range w.errors {
+			result.ErrorsByStatus[status] += count
+			result.Errors += count
+		}
+	}
+	result.Requests = len(latencies)
+	if result.Requests == 0 {
+		return result
+	}
+	result.Throughput = math.Round(float64(result.Requests)/elapsed.Seconds()*10) / 10
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	percentile := func(p float64) float64 {
+		return milliseconds(latencies[int(math.Ceil(p*float64(len(latencies))))-1])
+	}
+	result.Latency = BenchLatency{
+		Mean: milliseconds(total / time.Duration(len(latencies))),
+		P50:  percentile(0.50),
+		P90:  percentile(0.90),
+		P95:  percentile(0.95),
+		P99:  percentile(0.99),
+		Max:  milliseconds(latencies[len(latencies)-1]),
+	}
+	return result
+}
+
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
+
+// printBench writes result as a summary and tables.
+func printBench(w io.Writer, result BenchResult) {
+	fmt.Fprintf(w, "%s with %d workers for %.1fs\n", result.Endpoint, result.Concurrency, result.Seconds)
+	fmt.Fprintf(w, "Requests: %d (%.1f/s), errors: %d\n\n", result.Requests, result.Throughput, result.Errors)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MEAN (ms)\tP50 (ms)\tP90 (ms)\tP95 (ms)\tP99 (ms)\tMAX (ms)")
+	l := result.Latency
+	fmt.Fprintf(tw, "%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\n", l.Mean, l.P50, l.P90, l.P95, l.P99, l.Max)
+	tw.Flush()
+
+	if result.Errors == 0 {
+		return
+	}
+	statuses := make([]string, 0, len(result.ErrorsByStatus))
+	for status := range result.ErrorsByStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	fmt.Fprintln(w, "\nErrors:")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tCOUNT")
+	for _, status := range statuses {
+		fmt.Fprintf(tw, "%s\t%d\n", status, result.ErrorsByStatus[status])
+	}
+	tw.Flush()
+}
+
+func init() {
+	benchCmd.Flags().StringVar(&serverURL, "server", client.DefaultServer, "greetd server URL")
+	benchCmd.Flags().StringVar(&apiKey, "api-key", "", "API key, required with --post-message to delete its key afterwards (or GREETD_API_KEY)")
+	benchCmd.Flags().StringVar(&benchEndpoint, "endpoint", "/api/v1/hello", "path to request with GET, including any query")
+	benchCmd.Flags().IntVar(&benchConcurrency, "concurrency", 20, "number of concurrent workers")
+	benchCmd.Flags().DurationVar(&benchDuration, "duration", 30*time.Second, "how long to send requests")
+	benchCmd.Flags().BoolVar(&benchPostMessage, "post-message", false, "store messages under a temporary key instead of requesting --endpoint")
+	benchCmd.Flags().StringVarP(&benchOutput, "output", "o", "text", "output format (text, json)")
+	benchCmd.RegisterFlagCompletionFunc("output", completeValues("text", "json"))
+	rootCmd.AddCommand(benchCmd)
+}
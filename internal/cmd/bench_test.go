@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/client"
+)
+
+func TestRunBench(t *testing.T) {
+	var calls atomic.Int64
+	result := runBench(context.Background(), 4, 100*time.Millisecond, func(ctx context.Context, n int) error {
+		time.Sleep(time.Millisecond)
+		switch calls.Add(1) % 4 {
+		case 0:
+			return &client.Error{StatusCode: http.StatusServiceUnavailable}
+		case 1:
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	assert.Equal(t, 4, result.Concurrency)
+	assert.Greater(t, result.Requests, 10)
+	assert.LessOrEqual(t, int64(result.Requests), calls.Load())
+	assert.Greater(t, result.Throughput, 0.0)
+	assert.InDelta(t, result.Requests/2, result.Errors, 4)
+	assert.Equal(t, result.Errors, result.ErrorsByStatus["503"]+result.ErrorsByStatus["network"])
+	assert.Positive(t, result.ErrorsByStatus["503"])
+	assert.Positive(t, result.ErrorsByStatus["network"])
+
+	l := result.Latency
+	assert.GreaterOrEqual(t, l.P50, 1.0)
+	assert.True(t, l.P50 <= l.P90 && l.P90 <= l.P95 && l.P95 <= l.P99 && l.P99 <= l.Max, "%+v", l)
+}
+
+// runBenchCommand runs greetd bench against server with args and returns
+// its output.
+func runBenchCommand(t *testing.T, server string, args ...string) string {
+	t.Helper()
+	writeTestConfig(t)
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		serverURL, apiKey = client.DefaultServer, ""
+		benchEndpoint, benchConcurrency, benchDuration = "/api/v1/hello", 20, 30*time.Second
+		benchPostMessage, benchOutput = false, "text"
+	})
+	rootCmd.SetArgs(append([]string{"bench", "--server", server, "--duration", "100ms", "--concurrency", "2"}, args...))
+	require.NoError(t, rootCmd.Execute())
+	return out.String()
+}
+
+func TestBenchCommand(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/api/v1/hello?name=Ann", r.URL.RequestURI())
+		w.Write([]byte(`{"message":"Hello, Ann!"}`))
+	}))
+	defer srv.Close()
+
+	out := runBenchCommand(t, srv.URL, "--endpoint", "/api/v1/hello?name=Ann", "--output", "json")
+	var result BenchResult
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Equal(t, "GET /api/v1/hello?name=Ann", result.Endpoint)
+	assert.Equal(t, 2, result.Concurrency)
+	assert.Positive(t, result.Requests)
+	assert.Zero(t, result.Errors)
+	assert.Empty(t, result.ErrorsByStatus)
+
+	out = runBenchCommand(t, srv.URL, "--endpoint", "api/v1/hello?name=Ann", "--output", "text")
+	assert.Contains(t, out, "GET /api/v1/hello?name=Ann with 2 workers")
+	assert.Contains(t, out, "P95 (ms)")
+	assert.NotContains(t, out, "Errors:")
+}
+
+func TestBenchPostMessage(t *testing.T) {
+	var mu sync.Mutex
+	keys := map[string]int{}
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := strings.CutPrefix(r.URL.Path, "/api/v1/messages/")
+		require.True(t, ok, r.URL.Path)
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPost:
+			var body client.Message
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.True(t, strings.HasPrefix(body.Message, "greetd bench message "))
+			keys[key]++
+			json.NewEncoder(w).Encode(body)
+		case http.MethodDelete:
+			assert.Equal(t, "secret", r.Header.Get("X-API-Key"))
+			deleted = append(deleted, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	out := runBenchCommand(t, srv.URL, "--post-message", "--api-key", "secret")
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, keys, 1, "every request uses the same temporary key")
+	for key := range keys {
+		assert.True(t, strings.HasPrefix(key, "bench-"), key)
+		// Once to check the API key before the run, and once after it.
+		assert.Equal(t, []string{key, key}, deleted)
+		assert.Contains(t, out, "POST /api/v1/messages/"+key)
+	}
+}
+
+func TestCheckBenchKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		if r.Header.Get("X-API-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	assert.NoError(t, checkBenchKey(context.Background(), client.New(srv.URL, "secret"), "bench-1"))
+	err := checkBenchKey(context.Background(), client.New(srv.URL, "wrong"), "bench-1")
+	assert.ErrorContains(t, err, "server returned 401")
+}
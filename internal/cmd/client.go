@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/svanhalla/prompt-lab/greetd/internal/client"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/httpclient"
+)
+
+var (
+	serverURL string
+	apiKey    string
+
+	profileType    string
+	profileOutput  string
+	profileSeconds int
+
+	statsReset bool
+)
+
+var clientCmd = &cobra.Command{
+	Use:   "client",
+	Short: "Interact with a running greetd server",
+}
+
+var clientLogLevelCmd = &cobra.Command{
+	Use:   "loglevel [level]",
+	Short: "Show or change the log level of a running server",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newClient(cmd)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		ctx := context.Background()
+
+		if len(args) == 0 {
+			level, err := c.GetLogLevel(ctx)
+			if err != nil {
+				fmt.Printf("Error getting log level: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(level)
+			return
+		}
+
+		level, err := c.SetLogLevel(ctx, args[0])
+		if err != nil {
+			fmt.Printf("Error setting log level: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Log level set to: %s\n", level)
+	},
+}
+
+var clientProfileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Download a pprof profile from a running server",
+	Long: `Downloads a pprof profile from a server started with --enable-pprof or
+server.enable_pprof, using the API key. Types include heap, goroutine,
+allocs, block, mutex, threadcreate, profile (CPU) and trace. Inspect the
+result with "go tool pprof".`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		output := profileOutput
+		if output == "" {
+			output = profileType + ".pprof"
+		}
+
+		c, err := newClient(cmd)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Printf("Error creating %s: %v\n", output, err)
+			os.Exit(1)
+		}
+
+		err = c.Profile(context.Background(), profileType, profileSeconds, f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(output)
+			fmt.Printf("Error downloading %s profile: %v\n", profileType, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %s profile to %s\n", profileType, output)
+	},
+}
+
+var clientStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the request stats of a running server",
+	Long: `Prints the requests a running server has answered since it started or its
+stats were last reset: totals, counts and latencies per route, responses by
+status class, and the latest server errors with their request IDs. Reading
+the stats needs the API key; --reset clears them afterwards.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newClient(cmd)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		ctx := context.Background()
+
+		stats, err := c.Stats(ctx)
+		if err != nil {
+			fmt.Printf("Error getting stats: %v\n", err)
+			os.Exit(1)
+		}
+		printStats(cmd.OutOrStdout(), stats)
+
+		if statsReset {
+			if err := c.ResetStats(ctx); err != nil {
+				fmt.Printf("Error resetting stats: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "\nStats reset")
+		}
+	},
+}
+
+// printStats writes stats as tables.
+func printStats(w io.Writer, stats *client.Stats) {
+	fmt.Fprintf(w, "Since %s: %d requests, %d bytes served\n", stats.Since.Local().Format(time.DateTime), stats.Requests, stats.BytesOut)
+	fmt.Fprintf(w, "Status: 1xx %d, 2xx %d, 3xx %d, 4xx %d, 5xx %d\n\n",
+		stats.Status["1xx"], stats.Status["2xx"], stats.Status["3xx"], stats.Status["4xx"], stats.Status["5xx"])
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tROUTE\tCOUNT\tMEAN (ms)\tP95 (ms)")
+	for _, route := range stats.Routes {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.1f\t%.1f\n", route.Method, route.Route, route.Count, route.MeanMS, route.P95MS)
+	}
+	tw.Flush()
+
+	if len(stats.RecentErrors) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nRecent errors:")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tREQUEST ID\tSTATUS\tREQUEST\tERROR")
+	for _, e := range stats.RecentErrors {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s %s\t%s\n", e.Time.Local().Format(time.DateTime), e.RequestID, e.Status, e.Method, e.URI, e.Error)
+	}
+	tw.Flush()
+}
+
+// newClient returns a client for --server with the API key of --api-key or
+// GREETD_API_KEY.
+func newClient(cmd *cobra.Command) (*client.Client, error) {
+	return outboundClient(cmd, serverURL, clientAPIKey())
+}
+
+// clientAPIKey returns the API key of --api-key or GREETD_API_KEY.
+func clientAPIKey() string {
+	if apiKey != "" {
+		return apiKey
+	}
+	return os.Getenv("GREETD_API_KEY")
+}
+
+// outboundClient returns a client for server that makes its requests as
+// configured under outbound: through the proxy, with the timeout and
+// trusting the CA file.
+func outboundClient(cmd *cobra.Command, server, key string) (*client.Client, error) {
+	cfg, err := config.Load(cfgFile, dataPathFlag, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	httpClient, err := outboundHTTPClient(cmd, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return client.NewWithHTTPClient(server, key, httpClient), nil
+}
+
+// outboundHTTPClient returns the HTTP client configured under outbound,
+// warning on standard error when it does not verify certificates.
+func outboundHTTPClient(cmd *cobra.Command, cfg *config.Config) (*http.Client, error) {
+	httpClient, err := httpclient.New(cfg.Outbound.Options())
+	if err != nil {
+		return nil, fmt.Errorf("outbound: %w", err)
+	}
+	if cfg.Outbound.TLSSkipVerify {
+		fmt.Fprintln(cmd.ErrOrStderr(), "WARNING: outbound.tls_skip_verify is set; server certificates are NOT verified and the connection, API key included, can be intercepted")
+	}
+	return httpClient, nil
+}
+
+func init() {
+	clientCmd.PersistentFlags().StringVar(&serverURL, "server", client.DefaultServer, "greetd server URL")
+	clientCmd.PersistentFlags().StringVar(&apiKey, "api-key", "", "API key for admin endpoints (or GREETD_API_KEY)")
+
+	clientProfileCmd.Flags().StringVar(&profileType, "type", "heap", "profile type, e.g. heap, goroutine or profile (CPU)")
+	clientProfileCmd.Flags().StringVarP(&profileOutput, "output", "o", "", "file to write the profile to (default <type>.pprof)")
+	clientProfileCmd.Flags().IntVar(&profileSeconds, "seconds", 0, "sampling duration for CPU profiles and traces")
+
+	clientCmd.AddCommand(clientLogLevelCmd)
+	clientStatsCmd.Flags().BoolVar(&statsReset, "reset", false, "clear the stats after printing them")
+
+	clientCmd.AddCommand(clientProfileCmd)
+	clientCmd.AddCommand(clientStatsCmd)
+	rootCmd.AddCommand(clientCmd)
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/svanhalla/prompt-lab/greetd/internal/client"
+)
+
+func TestPrintStats(t *testing.T) {
+	since := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var out bytes.Buffer
+	printStats(&out, &client.Stats{
+		Since:    since,
+		Requests: 21,
+		BytesOut: 2048,
+		Status:   map[string]uint64{"2xx": 20, "5xx": 1},
+		Routes: []client.RouteStats{
+			{Method: "GET", Route: "/api/v1/hello", Count: 20, MeanMS: 1.25, P95MS: 2},
+			{Method: "POST", Route: "/api/v1/message", Count: 1, MeanMS: 12, P95MS: 12},
+		},
+		RecentErrors: []client.RequestError{
+			{Time: since, RequestID: "abc123", Method: "POST", URI: "/api/v1/message", Status: 500, Error: "disk full"},
+		},
+	})
+
+	text := out.String()
+	assert.Contains(t, text, "21 requests, 2048 bytes served")
+	assert.Contains(t, text, "2xx 20, 3xx 0, 4xx 0, 5xx 1")
+	assert.Contains(t, text, "GET     /api/v1/hello    20     1.2        2.0")
+	assert.Contains(t, text, "abc123      500     POST /api/v1/message  disk full")
+}
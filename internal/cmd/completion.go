@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/i18n"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish|powershell",
+	Short: "Generate a shell completion script",
+	Long: `Prints a completion script for the given shell to standard output. Besides
+commands and flags it completes the languages of --lang and the formats of
+--output.
+
+Bash (needs the bash-completion package):
+  Current shell:  source <(greetd completion bash)
+  Linux:          greetd completion bash > /etc/bash_completion.d/greetd
+  macOS:          greetd completion bash > $(brew --prefix)/etc/bash_completion.d/greetd
+
+Zsh (completion must be enabled, e.g. with "autoload -U compinit; compinit"
+in ~/.zshrc):
+  greetd completion zsh > "${fpath[1]}/_greetd"
+
+Fish:
+  Current shell:  greetd completion fish | source
+  Permanently:    greetd completion fish > ~/.config/fish/completions/greetd.fish
+
+PowerShell:
+  Current shell:  greetd completion powershell | Out-String | Invoke-Expression
+  Permanently:    add the line above to your PowerShell profile
+
+Start a new shell after installing a script for it to take effect.`,
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		out := cmd.OutOrStdout()
+
+		var err error
+		switch args[0] {
+		case "bash":
+			err = rootCmd.GenBashCompletionV2(out, true)
+		case "zsh":
+			err = rootCmd.GenZshCompletion(out)
+		case "fish":
+			err = rootCmd.GenFishCompletion(out, true)
+		case "powershell":
+			err = rootCmd.GenPowerShellCompletionWithDesc(out)
+		}
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// completeLang completes --lang with the languages of the greeting
+// catalog, including those added by greetings.catalog_path.
+func completeLang(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	catalog := i18n.Default()
+	if cfg, err := config.Load(cfgFile, dataPathFlag, nil); err == nil {
+		if loaded, err := i18n.Load(cfg.Greetings.CatalogPath); err == nil {
+			catalog = loaded
+		}
+	}
+	return catalog.Languages(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeValues completes a flag with a fixed set of values.
+func completeValues(values ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runCompletion runs greetd with args and returns its output.
+func runCompletion(t *testing.T, args ...string) string {
+	t.Helper()
+	configPath, _ := writeTestConfig(t)
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	t.Cleanup(func() { rootCmd.SetOut(nil) })
+	// The word being completed has to come last.
+	rootCmd.SetArgs(append([]string{"--config", configPath}, args...))
+	require.NoError(t, rootCmd.Execute())
+	return out.String()
+}
+
+func TestCompletionScripts(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		t.Run(shell, func(t *testing.T) {
+			script := runCompletion(t, "completion", shell)
+			assert.Contains(t, script, "greetd")
+		})
+	}
+}
+
+func TestFlagCompletion(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		want  []string
+		avoid []string
+	}{
+		{"lang", []string{"hello", "--lang", ""}, []string{"en", "sv", "ja"}, nil},
+		{"version output", []string{"version", "--output", ""}, []string{"text", "json"}, nil},
+		{"gen-docs format", []string{"gen-docs", "--format", ""}, []string{"markdown", "man"}, nil},
+		{"completion shells", []string{"completion", ""}, []string{"bash", "zsh", "fish", "powershell"}, []string{"--config"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := runCompletion(t, append([]string{"__complete"}, tt.args...)...)
+			values := strings.Split(strings.TrimSpace(out), "\n")
+			for _, want := range tt.want {
+				assert.Contains(t, values, want)
+			}
+			for _, avoid := range tt.avoid {
+				assert.NotContains(t, values, avoid)
+			}
+		})
+	}
+}
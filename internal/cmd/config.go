@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+var configEffective bool
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the configuration file",
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create the data directory and a default config file",
+	Run: func(cmd *cobra.Command, args []string) {
+		out := cmd.OutOrStdout()
+
+		path, err := config.Init(cfgFile, dataPathFlag)
+		if errors.Is(err, os.ErrExist) {
+			fmt.Fprintf(out, "Config file already exists: %s\n", path)
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Fprintf(out, "Created config file: %s\n", path)
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show [--effective]",
+	Short: "Print the loaded configuration",
+	Long: `Prints the configuration as loaded from the config file, environment and
+flags, as JSON. With --effective it prints the summary the server logs when
+it starts instead: the version, address, paths, storage, logging and which
+features are enabled. API keys are shown by their fingerprint only.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.Load(cfgFile, dataPathFlag, configFlags(cmd))
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		out := cmd.OutOrStdout()
+
+		if configEffective {
+			keys := storage.NewAPIKeys(cfg.StateDir())
+			if err := keys.Load(); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+				os.Exit(1)
+			}
+			for _, line := range formatSummary(cfg.Summary(keys.Len())) {
+				fmt.Fprintln(out, line)
+			}
+			return
+		}
+
+		masked := *cfg
+		masked.Security.APIKeys = make([]string, len(cfg.Security.APIKeys))
+		for i, key := range cfg.Security.APIKeys {
+			masked.Security.APIKeys[i] = config.KeyFingerprint(key)
+		}
+		data, err := json.MarshalIndent(masked, "", "  ")
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(out, string(data))
+	},
+}
+
+// formatSummary renders the settings of a configuration summary as
+// "key: value" lines with the values aligned.
+func formatSummary(summary []config.Setting) []string {
+	width := 0
+	for _, setting := range summary {
+		width = max(width, len(setting.Key)+1)
+	}
+	lines := make([]string, len(summary))
+	for i, setting := range summary {
+		lines[i] = fmt.Sprintf("%-*s %s", width, setting.Key+":", setting.Value)
+	}
+	return lines
+}
+
+func init() {
+	configShowCmd.Flags().BoolVar(&configEffective, "effective", false, "print the summary of the effective configuration")
+
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+	"github.com/svanhalla/prompt-lab/greetd/internal/web"
+)
+
+var doctorFix bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose common setup problems",
+	Long: `Checks the config file, state directory, message file, server port, log
+files and embedded templates, printing OK, WARN or FAIL with a hint for each.
+With --fix, a missing config file and state directory are created and a
+corrupt message file is moved aside. The exit code is 0 when everything is OK, 1 for
+warnings and 2 for failures.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		findings := runDoctor(cfgFile, dataPathFlag, configFlags(cmd), doctorFix)
+		os.Exit(int(printDoctor(cmd.OutOrStdout(), findings)))
+	},
+}
+
+// doctorStatus is the severity of a finding; higher is worse.
+type doctorStatus int
+
+const (
+	doctorOK doctorStatus = iota
+	doctorWarn
+	doctorFail
+)
+
+func (s doctorStatus) String() string {
+	switch s {
+	case doctorOK:
+		return "OK"
+	case doctorWarn:
+		return "WARN"
+	default:
+		return "FAIL"
+	}
+}
+
+// doctorFinding is the outcome of one doctor check.
+type doctorFinding struct {
+	Check  string
+	Status doctorStatus
+	Detail string
+	Hint   string
+}
+
+// runDoctor runs every check against the config at configPath (or the
+// default location) and, when fix is set, repairs what it safely can.
+// dataPath and flags override settings as they do for the other commands.
+func runDoctor(configPath, dataPath string, flags config.Flags, fix bool) []doctorFinding {
+	var findings []doctorFinding
+	add := func(check string, status doctorStatus, detail, hint string) {
+		findings = append(findings, doctorFinding{Check: check, Status: status, Detail: detail, Hint: hint})
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.DataPath = config.ResolveDataPath(dataPath)
+	if configPath == "" {
+		configPath = config.DefaultConfigPath(cfg.DataPath)
+	}
+
+	// Config file.
+	switch _, err := os.Stat(configPath); {
+	case errors.Is(err, os.ErrNotExist) && fix:
+		if _, err := config.Init(configPath, dataPath); err != nil {
+			add("config", doctorFail, err.Error(), "run greetd config init")
+			break
+		}
+		add("config", doctorOK, "created "+configPath, "")
+	case errors.Is(err, os.ErrNotExist):
+		add("config", doctorWarn, configPath+" does not exist; defaults are used",
+			"run greetd config init or greetd doctor --fix to write a default config file")
+	default:
+		loaded, err := config.Load(configPath, dataPath, flags)
+		if err != nil {
+			add("config", doctorFail, err.Error(), "correct the reported setting in "+configPath)
+			break
+		}
+		cfg = loaded
+		add("config", doctorOK, configPath, "")
+	}
+
+	// State directory.
+	stateDir := cfg.StateDir()
+	stateOK := false
+	info, err := os.Stat(stateDir)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && fix:
+		if err := os.MkdirAll(stateDir, 0755); err != nil {
+			add("state path", doctorFail, err.Error(), "create "+stateDir+" or set state_path to a writable directory")
+			break
+		}
+		add("state path", doctorOK, "created "+stateDir, "")
+		stateOK = true
+	case errors.Is(err, os.ErrNotExist):
+		add("state path", doctorWarn, stateDir+" does not exist yet; it is created when greetd first writes to it",
+			"run greetd doctor --fix to create it now")
+	case err != nil:
+		add("state path", doctorFail, err.Error(), "check the permissions of "+filepath.Dir(stateDir))
+	case !info.IsDir():
+		add("state path", doctorFail, stateDir+" is not a directory", "set state_path to a directory")
+	default:
+		if err := storage.CheckWritable(stateDir); err != nil {
+			add("state path", doctorFail, err.Error(), "make "+stateDir+" writable by this user")
+			break
+		}
+		add("state path", doctorOK, stateDir, "")
+		stateOK = true
+	}
+
+	// Message files: messages.json, and a message.json from before keyed
+	// messages until it is carried over.
+	found := false
+	for _, file := range []struct {
+		name  string
+		parse func([]byte) error
+	}{
+		{storage.MessagesFileName, func(data []byte) error { return json.Unmarshal(data, &storage.MessagesFile{}) }},
+		{storage.LegacyMessageFileName, func(data []byte) error { return json.Unmarshal(data, &storage.MessageData{}) }},
+	} {
+		messagePath := filepath.Join(stateDir, file.name)
+		data, err := os.ReadFile(messagePath)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		found = true
+
+		switch {
+		case err != nil:
+			add("message file", doctorFail, err.Error(), "check the permissions of "+messagePath)
+		case storage.IsEncrypted(data):
+			// Never reset a file only because its key is missing.
+			keys, err := loadKeyring(cfg)
+			if err == nil {
+				_, err = keys.Decrypt(data)
+			}
+			if err != nil {
+				add("message file", doctorFail, err.Error(),
+					"set storage.encryption.key_file or "+config.StorageKeyEnv+" to the key it was encrypted with")
+				break
+			}
+			add("message file", doctorOK, messagePath+" (encrypted)", "")
+		case file.parse(data) != nil:
+			if !fix || !stateOK {
+				add("message file", doctorFail, messagePath+" is not valid JSON",
+					"run greetd doctor --fix to back it up and reset the messages")
+				break
+			}
+			backup := messagePath + ".bak"
+			if err := os.Rename(messagePath, backup); err != nil {
+				add("message file", doctorFail, err.Error(), "move "+messagePath+" aside manually")
+				break
+			}
+			add("message file", doctorOK, "moved to "+backup+"; the default message is used until a new one is set", "")
+		default:
+			add("message file", doctorOK, messagePath, "")
+		}
+	}
+	if !found {
+		add("message file", doctorOK, filepath.Join(stateDir, storage.MessagesFileName)+" not created yet; the default message is used", "")
+	}
+
+	// Server port.
+	addr := net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.Port))
+	if listener, err := net.Listen("tcp", addr); err != nil {
+		add("port", doctorFail, err.Error(), "stop the process using it, or change server.port or pass --port")
+	} else {
+		listener.Close()
+		add("port", doctorOK, addr+" is available", "")
+	}
+
+	// Log files.
+	for _, logFile := range []struct{ check, path string }{
+		{"app log", cfg.AppLogPath()},
+		{"access log", cfg.AccessLogPath()},
+	} {
+		if logFile.path == "" {
+			add(logFile.check, doctorOK, "disabled", "")
+			continue
+		}
+		if !stateOK && filepath.Dir(logFile.path) == stateDir {
+			add(logFile.check, doctorWarn, "not checked: the state path is unavailable", "")
+			continue
+		}
+		if err := checkLogFile(logFile.path); err != nil {
+			add(logFile.check, doctorFail, err.Error(), "make "+logFile.path+" and its directory writable")
+			continue
+		}
+		add(logFile.check, doctorOK, logFile.path, "")
+	}
+
+	// Templates.
+	if _, err := web.NewTemplates("", cfg.Server.BasePath, nil); err != nil {
+		add("templates", doctorFail, err.Error(), "the binary is broken; rebuild or reinstall greetd")
+	} else {
+		add("templates", doctorOK, "embedded templates parse", "")
+	}
+
+	return findings
+}
+
+// checkLogFile verifies that path can be appended to, or created when it
+// does not exist yet, without changing an existing file.
+func checkLogFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(filepath.Dir(path)); err != nil {
+			return err
+		}
+		return storage.CheckWritable(filepath.Dir(path))
+	}
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// printDoctor writes the findings and returns the worst status.
+func printDoctor(w io.Writer, findings []doctorFinding) doctorStatus {
+	worst := doctorOK
+	for _, finding := range findings {
+		fmt.Fprintf(w, "%-6s %-13s %s\n", "["+finding.Status.String()+"]", finding.Check, finding.Detail)
+		if finding.Hint != "" {
+			fmt.Fprintf(w, "%-6s %-13s hint: %s\n", "", "", finding.Hint)
+		}
+		worst = max(worst, finding.Status)
+	}
+	return worst
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "create a missing config file and state directory, and move a corrupt message file aside")
+	rootCmd.AddCommand(doctorCmd)
+}
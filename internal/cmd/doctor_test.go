@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+// writeDoctorConfig writes a config whose data path is dataPath and whose
+// port is port, keeping everything inside a temp dir.
+func writeDoctorConfig(t *testing.T, dataPath string, port int) string {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	cfg := config.DefaultConfig()
+	cfg.DataPath = dataPath
+	cfg.Server.Host = "127.0.0.1"
+	cfg.Server.Port = port
+
+	configPath := filepath.Join(tmpDir, "config.json")
+	require.NoError(t, cfg.Save(configPath))
+	return configPath
+}
+
+// statuses maps each check to its status.
+func statuses(findings []doctorFinding) map[string]doctorStatus {
+	result := make(map[string]doctorStatus, len(findings))
+	for _, finding := range findings {
+		result[finding.Check] = finding.Status
+	}
+	return result
+}
+
+func TestDoctorHealthy(t *testing.T) {
+	configPath := writeDoctorConfig(t, t.TempDir(), 0)
+
+	findings := runDoctor(configPath, "", nil, false)
+	for _, finding := range findings {
+		assert.Equal(t, doctorOK, finding.Status, "%s: %s", finding.Check, finding.Detail)
+	}
+	assert.Equal(t, doctorOK, printDoctor(&bytes.Buffer{}, findings))
+}
+
+func TestDoctorMissingDataPath(t *testing.T) {
+	dataPath := filepath.Join(t.TempDir(), "missing")
+	configPath := writeDoctorConfig(t, dataPath, 0)
+
+	assert.Equal(t, doctorWarn, statuses(runDoctor(configPath, "", nil, false))["state path"])
+	assert.NoDirExists(t, dataPath)
+
+	assert.Equal(t, doctorOK, statuses(runDoctor(configPath, "", nil, true))["state path"])
+	assert.DirExists(t, dataPath)
+}
+
+func TestDoctorCorruptMessage(t *testing.T) {
+	for _, name := range []string{storage.LegacyMessageFileName, storage.MessagesFileName} {
+		t.Run(name, func(t *testing.T) {
+			dataPath := t.TempDir()
+			configPath := writeDoctorConfig(t, dataPath, 0)
+			messagePath := filepath.Join(dataPath, name)
+			require.NoError(t, os.WriteFile(messagePath, []byte(`{"message": `), 0644))
+
+			findings := runDoctor(configPath, "", nil, false)
+			assert.Equal(t, doctorFail, statuses(findings)["message file"])
+
+			var out bytes.Buffer
+			assert.Equal(t, doctorFail, printDoctor(&out, findings))
+			assert.Contains(t, out.String(), "hint: run greetd doctor --fix")
+
+			assert.Equal(t, doctorOK, statuses(runDoctor(configPath, "", nil, true))["message file"])
+
+			backup, err := os.ReadFile(messagePath + ".bak")
+			require.NoError(t, err)
+			assert.Equal(t, `{"message": `, string(backup))
+
+			store := storage.NewMessageStore(dataPath)
+			require.NoError(t, store.Load(context.Background()))
+			assert.Equal(t, "Hello, World!", store.GetMessage(context.Background()))
+		})
+	}
+}
+
+func TestDoctorEncryptedMessage(t *testing.T) {
+	dataPath := t.TempDir()
+	configPath := writeDoctorConfig(t, dataPath, 0)
+
+	keys, err := storage.NewKeyring(bytes.Repeat([]byte{1}, storage.KeySize))
+	require.NoError(t, err)
+	store := storage.NewMessageStore(dataPath)
+	store.SetKeyring(keys)
+	require.NoError(t, store.Load(context.Background()))
+	require.NoError(t, store.SetMessage(context.Background(), "Secret", storage.UpdatedByCLI))
+
+	// A file without its key is reported but never reset.
+	assert.Equal(t, doctorFail, statuses(runDoctor(configPath, "", nil, true))["message file"])
+	assert.NoFileExists(t, filepath.Join(dataPath, storage.MessagesFileName+".bak"))
+
+	t.Setenv(config.StorageKeyEnv, "wrong passphrase")
+	assert.Equal(t, doctorFail, statuses(runDoctor(configPath, "", nil, false))["message file"])
+}
+
+func TestDoctorPortInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	configPath := writeDoctorConfig(t, t.TempDir(), listener.Addr().(*net.TCPAddr).Port)
+
+	assert.Equal(t, doctorFail, statuses(runDoctor(configPath, "", nil, false))["port"])
+}
+
+func TestDoctorMissingConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	configPath := filepath.Join(t.TempDir(), "config.json")
+
+	assert.Equal(t, doctorWarn, statuses(runDoctor(configPath, "", nil, false))["config"])
+	assert.NoFileExists(t, configPath)
+
+	assert.Equal(t, doctorOK, statuses(runDoctor(configPath, "", nil, true))["config"])
+	assert.FileExists(t, configPath)
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+	"github.com/svanhalla/prompt-lab/greetd/internal/validate"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	exportOutput string
+	importDryRun bool
+	importForce  bool
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write the stored messages to a reviewable file",
+	Long: `Writes every stored message, including the default one, to a versioned
+file (apiVersion: greetd/v1) that can be kept in version control and applied
+with "greetd import". The file is YAML unless --output ends in .json; without
+--output it is printed to standard output.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		out := cmd.OutOrStdout()
+
+		cfg, err := config.Load(cfgFile, dataPathFlag, configFlags(cmd))
+		if err != nil {
+			fmt.Fprintf(out, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		if err := exportMessages(cmd.Context(), out, cfg, exportOutput); err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Replace the stored messages with the contents of an export file",
+	Long: `Validates every message in a file written by "greetd export", prints what
+would change and applies it in a single write. Keys missing from the file are
+deleted, and a missing default key restores the default message.
+
+Replacing existing messages requires --force unless the file matches them
+already. --dry-run only prints the changes. Use "-" to read from standard
+input.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		out := cmd.OutOrStdout()
+
+		cfg, err := config.Load(cfgFile, dataPathFlag, configFlags(cmd))
+		if err != nil {
+			fmt.Fprintf(out, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		if err := importMessages(cmd.Context(), out, cmd.InOrStdin(), cfg, args[0], importDryRun, importForce); err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// exportMessages writes the stored messages to path, or to out when path
+// is empty.
+func exportMessages(ctx context.Context, out io.Writer, cfg *config.Config, path string) error {
+	store, err := loadMessageStore(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load message store: %w", err)
+	}
+
+	var data []byte
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if data, err = json.MarshalIndent(store.Export(ctx), "", "  "); err == nil {
+			data = append(data, '\n')
+		}
+	} else {
+		data, err = yaml.Marshal(store.Export(ctx))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal messages: %w", err)
+	}
+
+	if path == "" {
+		_, err := out.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Messages exported to %s\n", path)
+	return nil
+}
+
+// importMessages validates the export file at path, prints the changes it
+// makes and, unless dryRun is set, applies them.
+func importMessages(ctx context.Context, out io.Writer, stdin io.Reader, cfg *config.Config, path string, dryRun, force bool) error {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	file, err := storage.ParseExport(data)
+	if err != nil {
+		return err
+	}
+	if err := validateExport(file, cfg); err != nil {
+		return err
+	}
+
+	store, err := loadMessageStore(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load message store: %w", err)
+	}
+
+	changes := store.Diff(ctx, file.Messages)
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "Nothing to import: the messages are up to date")
+		return nil
+	}
+	printChanges(out, changes)
+
+	if dryRun {
+		fmt.Fprintln(out, "Dry run: nothing was changed")
+		return nil
+	}
+	if store.Len() > 0 && !force {
+		return fmt.Errorf("refusing to replace existing messages (use --force)")
+	}
+	changes, err = store.SwapMessages(ctx, file.Messages, storage.UpdatedByCLI)
+	if err != nil {
+		return fmt.Errorf("failed to import messages: %w", err)
+	}
+	fmt.Fprintf(out, "Imported %d change(s)\n", len(changes))
+	return nil
+}
+
+// validateExport checks every key and message in file with the rules the
+// API applies, reporting all invalid entries at once. The messages in file
+// are replaced by their prepared form, normalized and possibly masked.
+func validateExport(file storage.ExportFile, cfg *config.Config) error {
+	keys := make([]string, 0, len(file.Messages))
+	for key := range file.Messages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var problems []string
+	if len(keys) > cfg.Message.MaxKeys {
+		problems = append(problems, fmt.Sprintf("%d messages exceed message.max_keys (%d)", len(keys), cfg.Message.MaxKeys))
+	}
+	rules, err := messageRules(cfg)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if !storage.ValidKey(key) {
+			problems = append(problems, fmt.Sprintf("%q: %v", key, storage.ErrInvalidKey))
+			continue
+		}
+		message, err := validate.Prepare(file.Messages[key], rules)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		file.Messages[key] = message
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid export file:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// printChanges lists changes as "+" added, "-" removed and "~" changed keys.
+func printChanges(out io.Writer, changes []storage.MessageChange) {
+	for _, change := range changes {
+		switch {
+		case change.Added:
+			fmt.Fprintf(out, "+ %s: %q\n", change.Key, change.New)
+		case change.Removed:
+			fmt.Fprintf(out, "- %s: %q\n", change.Key, change.Old)
+		default:
+			fmt.Fprintf(out, "~ %s: %q -> %q\n", change.Key, change.Old, change.New)
+		}
+	}
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "file to write (default: standard output)")
+	exportCmd.MarkFlagFilename("output", "yaml", "yml", "json")
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "print the changes without applying them")
+	importCmd.Flags().BoolVar(&importForce, "force", false, "replace existing messages")
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+}
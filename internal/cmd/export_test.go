@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+func TestExportImport(t *testing.T) {
+	configPath, dataPath := writeTestConfig(t)
+	cfg, err := config.Load(configPath, "", nil)
+	require.NoError(t, err)
+
+	store := storage.NewMessageStore(dataPath)
+	require.NoError(t, store.Load(context.Background()))
+	require.NoError(t, store.SetKey(context.Background(), "motd", "Welcome", storage.UpdatedByCLI))
+
+	file := filepath.Join(t.TempDir(), "messages.yaml")
+	var out bytes.Buffer
+	require.NoError(t, exportMessages(context.Background(), &out, cfg, file))
+	assert.Contains(t, out.String(), "Messages exported to "+file)
+
+	// Importing the unchanged file needs no --force.
+	out.Reset()
+	require.NoError(t, importMessages(context.Background(), &out, nil, cfg, file, false, false))
+	assert.Contains(t, out.String(), "Nothing to import")
+
+	edited := "apiVersion: greetd/v1\nmessages:\n  default: Hi\n  banner: New\n"
+	require.NoError(t, os.WriteFile(file, []byte(edited), 0644))
+
+	out.Reset()
+	require.NoError(t, importMessages(context.Background(), &out, nil, cfg, file, true, false))
+	assert.Equal(t, strings.Join([]string{
+		`+ banner: "New"`,
+		`~ default: "Hello, World!" -> "Hi"`,
+		`- motd: "Welcome"`,
+		"Dry run: nothing was changed",
+	}, "\n")+"\n", out.String())
+
+	err = importMessages(context.Background(), &out, nil, cfg, file, false, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--force")
+
+	out.Reset()
+	require.NoError(t, importMessages(context.Background(), &out, nil, cfg, file, false, true))
+	assert.Contains(t, out.String(), "Imported 3 change(s)")
+
+	require.NoError(t, store.Load(context.Background()))
+	assert.Equal(t, "Hi", store.GetMessage(context.Background()))
+	_, err = store.GetKey(context.Background(), "motd")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestImportValidatesEveryEntry(t *testing.T) {
+	configPath, dataPath := writeTestConfig(t)
+	cfg, err := config.Load(configPath, "", nil)
+	require.NoError(t, err)
+
+	invalid := "apiVersion: greetd/v1\nmessages:\n  default: \"\"\n  \"bad key\": x\n  motd: ok\n"
+	err = importMessages(context.Background(), &bytes.Buffer{}, strings.NewReader(invalid), cfg, "-", false, true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"bad key"`)
+	assert.Contains(t, err.Error(), "default:")
+	assert.NoFileExists(t, filepath.Join(dataPath, storage.MessagesFileName))
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+	"github.com/svanhalla/prompt-lab/greetd/internal/version"
+)
+
+var (
+	genDocsDir    string
+	genDocsFormat string
+)
+
+var genDocsCmd = &cobra.Command{
+	Use:   "gen-docs",
+	Short: "Generate reference documentation for the commands",
+	Long: `Writes one page per command, with its usage, flags and subcommands, to
+--dir: greetd_hello.md and so on with --format markdown, or greetd-hello.1
+man pages with --format man. Existing pages are overwritten.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := genDocs(genDocsDir, genDocsFormat); err != nil {
+			fmt.Fprintf(cmd.OutOrStdout(), "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s docs to %s\n", genDocsFormat, genDocsDir)
+	},
+}
+
+// genDocs writes the reference pages of every command to dir in format,
+// "markdown" or "man".
+func genDocs(dir, format string) error {
+	if format != "markdown" && format != "man" {
+		return fmt.Errorf("unknown format %q (use markdown or man)", format)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	// The generation date would make every page change on each run.
+	disableAutoGenTag(rootCmd)
+	if format == "man" {
+		header := &doc.GenManHeader{
+			Title:   "GREETD",
+			Section: "1",
+			Source:  "greetd " + version.Get().Version,
+		}
+		return doc.GenManTree(rootCmd, header, dir)
+	}
+	return doc.GenMarkdownTree(rootCmd, dir)
+}
+
+func disableAutoGenTag(cmd *cobra.Command) {
+	cmd.DisableAutoGenTag = true
+	for _, sub := range cmd.Commands() {
+		disableAutoGenTag(sub)
+	}
+}
+
+func init() {
+	genDocsCmd.Flags().StringVar(&genDocsDir, "dir", "./docs", "directory to write the pages to")
+	genDocsCmd.Flags().StringVar(&genDocsFormat, "format", "markdown", "page format (markdown, man)")
+	genDocsCmd.RegisterFlagCompletionFunc("format", completeValues("markdown", "man"))
+	genDocsCmd.MarkFlagDirname("dir")
+	rootCmd.AddCommand(genDocsCmd)
+}
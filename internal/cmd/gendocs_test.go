@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// documentedCommands returns cmd and its subcommands that get a page.
+func documentedCommands(cmd *cobra.Command) []*cobra.Command {
+	if !cmd.IsAvailableCommand() && cmd != rootCmd {
+		return nil
+	}
+	commands := []*cobra.Command{cmd}
+	for _, sub := range cmd.Commands() {
+		commands = append(commands, documentedCommands(sub)...)
+	}
+	return commands
+}
+
+func TestGenDocs(t *testing.T) {
+	tests := []struct {
+		format string
+		page   func(*cobra.Command) string
+	}{
+		{"markdown", func(cmd *cobra.Command) string {
+			return strings.ReplaceAll(cmd.CommandPath(), " ", "_") + ".md"
+		}},
+		{"man", func(cmd *cobra.Command) string {
+			return strings.ReplaceAll(cmd.CommandPath(), " ", "-") + ".1"
+		}},
+	}
+
+	commands := documentedCommands(rootCmd)
+	require.Greater(t, len(commands), 20)
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			writeTestConfig(t)
+			dir := filepath.Join(t.TempDir(), "docs")
+
+			var out bytes.Buffer
+			rootCmd.SetOut(&out)
+			t.Cleanup(func() {
+				rootCmd.SetOut(nil)
+				genDocsDir = "./docs"
+				genDocsFormat = "markdown"
+			})
+			rootCmd.SetArgs([]string{"gen-docs", "--dir", dir, "--format", tt.format})
+			require.NoError(t, rootCmd.Execute())
+			assert.Contains(t, out.String(), "Wrote "+tt.format+" docs to "+dir)
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			assert.Len(t, entries, len(commands))
+			for _, cmd := range commands {
+				data, err := os.ReadFile(filepath.Join(dir, tt.page(cmd)))
+				if assert.NoError(t, err, cmd.CommandPath()) {
+					assert.NotContains(t, string(data), "Auto generated")
+				}
+			}
+
+			hello, err := os.ReadFile(filepath.Join(dir, tt.page(helloCmd)))
+			require.NoError(t, err)
+			assert.Contains(t, string(hello), "time-aware")
+		})
+	}
+}
+
+func TestGenDocsUnknownFormat(t *testing.T) {
+	err := genDocs(t.TempDir(), "html")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown format "html"`)
+}
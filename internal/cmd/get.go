@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+	"github.com/svanhalla/prompt-lab/greetd/internal/web"
+)
+
+var getCmd = &cobra.Command{
+	Use:   "get",
+	Short: "Show application data",
+}
+
+var getMessageKey string
+
+var getMessageCmd = &cobra.Command{
+	Use:   "message",
+	Short: "Print a stored message",
+	Long: `Print a stored message from the data directory.
+
+Without --key the default message served by /message is printed.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		out := cmd.OutOrStdout()
+
+		if !storage.ValidKey(getMessageKey) {
+			fmt.Fprintf(out, "Error: %v\n", storage.ErrInvalidKey)
+			return
+		}
+
+		cfg, _, err := loadConfigAndLogger(cmd)
+		if err != nil {
+			fmt.Fprintf(out, "Error loading config: %v\n", err)
+			return
+		}
+
+		store, err := loadMessageStore(cmd.Context(), cfg)
+		if err != nil {
+			fmt.Fprintf(out, "Error loading message store: %v\n", err)
+			return
+		}
+
+		data, err := store.GetKey(cmd.Context(), getMessageKey)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %s: %v\n", getMessageKey, err)
+			return
+		}
+		fmt.Fprintln(out, data.Message)
+	},
+}
+
+var (
+	historyKey    string
+	historyLimit  int
+	historySince  string
+	historyOutput string
+	historyFull   bool
+	historyServer string
+)
+
+// historyMessageWidth is the number of characters of a message the history
+// table shows without --full.
+const historyMessageWidth = 60
+
+var getHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Print the revisions of a stored message",
+	Long: `Print the revisions of a stored message that are kept for undo and redo,
+newest first, as a table of revision, time, writer and message. Messages are
+cut to their first line and 60 characters unless --full is given, and
+--output json prints the revisions as stored.
+
+--since keeps the revisions made within a duration before now, such as 2h or
+30m, or after a date (2006-01-02) or RFC 3339 time. With --server the history
+is read from a running server instead of the data directory.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		out := cmd.OutOrStdout()
+
+		if historyOutput != "text" && historyOutput != "json" {
+			fmt.Fprintf(out, "Error: unknown output format %q (use text or json)\n", historyOutput)
+			return
+		}
+		if historyLimit < 0 {
+			fmt.Fprintf(out, "Error: --limit must not be negative, got %d\n", historyLimit)
+			return
+		}
+		if !storage.ValidKey(historyKey) {
+			fmt.Fprintf(out, "Error: %v\n", storage.ErrInvalidKey)
+			return
+		}
+		since, err := parseSince(historySince, time.Now())
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return
+		}
+
+		var revisions []storage.MessageData
+		if historyServer == "" {
+			revisions, err = localHistory(cmd, historyKey)
+		} else {
+			revisions, err = serverHistory(cmd, historyServer, historyKey)
+		}
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return
+		}
+
+		revisions = filterHistory(revisions, historyLimit, since)
+		if err := printHistory(out, revisions, historyOutput == "json", historyFull); err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+		}
+	},
+}
+
+// localHistory returns the revisions of the message under key in the data
+// directory, newest first.
+func localHistory(cmd *cobra.Command, key string) ([]storage.MessageData, error) {
+	cfg, _, err := loadConfigAndLogger(cmd)
+	if err != nil {
+		return nil, err
+	}
+	store, err := loadMessageStore(cmd.Context(), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load message store: %w", err)
+	}
+
+	revisions, err := store.Revisions(cmd.Context(), key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	slices.Reverse(revisions)
+	return revisions, nil
+}
+
+// serverHistory returns the revisions of the message under key from the
+// server at url, newest first.
+func serverHistory(cmd *cobra.Command, url, key string) ([]storage.MessageData, error) {
+	c, err := outboundClient(cmd, url, "")
+	if err != nil {
+		return nil, err
+	}
+	history, err := c.History(cmd.Context(), key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+
+	revisions := make([]storage.MessageData, len(history.Revisions))
+	for i, revision := range history.Revisions {
+		revisions[i] = storage.MessageData{Message: revision.Message, UpdatedBy: revision.UpdatedBy, Revision: revision.Revision}
+		if revision.UpdatedAt != nil {
+			revisions[i].UpdatedAt = *revision.UpdatedAt
+		}
+	}
+	return revisions, nil
+}
+
+// parseSince returns the time --since stands for: value before now for a
+// duration such as 2h, or the given date or RFC 3339 time. An empty value
+// returns the zero time.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("--since must not be negative, got %s", value)
+		}
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("--since: invalid duration or time %q (use e.g. 2h, 2006-01-02 or 2006-01-02T15:04:05Z)", value)
+}
+
+// filterHistory keeps the revisions, newest first, made at or after since
+// unless it is zero, and at most limit of them unless it is 0. The default
+// message, which was never set, has no time and is left out by since.
+func filterHistory(revisions []storage.MessageData, limit int, since time.Time) []storage.MessageData {
+	if !since.IsZero() {
+		revisions = slices.DeleteFunc(revisions, func(data storage.MessageData) bool {
+			return data.UpdatedAt.Before(since)
+		})
+	}
+	if limit > 0 && len(revisions) > limit {
+		revisions = revisions[:limit]
+	}
+	return revisions
+}
+
+// printHistory writes revisions as a table, or as JSON with asJSON. The
+// table shows the first line of each message, cut to historyMessageWidth
+// characters, or with full the whole message with its line breaks as \n.
+func printHistory(w io.Writer, revisions []storage.MessageData, asJSON, full bool) error {
+	if asJSON {
+		output, err := json.MarshalIndent(revisions, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal history: %w", err)
+		}
+		fmt.Fprintln(w, string(output))
+		return nil
+	}
+
+	if len(revisions) == 0 {
+		fmt.Fprintln(w, "No revisions found")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REVISION\tUPDATED\tBY\tMESSAGE")
+	for _, data := range revisions {
+		updatedAt, updatedBy := "-", "-"
+		if !data.UpdatedAt.IsZero() {
+			updatedAt = data.UpdatedAt.Format(time.RFC3339)
+		}
+		if data.UpdatedBy != "" {
+			updatedBy = data.UpdatedBy
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", data.Revision, updatedAt, updatedBy, historyMessage(data.Message, full))
+	}
+	return tw.Flush()
+}
+
+// historyMessage returns message as shown in the history table.
+func historyMessage(message string, full bool) string {
+	if full {
+		return strings.ReplaceAll(message, "\n", `\n`)
+	}
+	firstLine, rest, _ := strings.Cut(message, "\n")
+	if rest != "" {
+		firstLine += "…"
+	}
+	return web.Truncate(historyMessageWidth, firstLine)
+}
+
+func init() {
+	getMessageCmd.Flags().StringVar(&getMessageKey, "key", storage.DefaultKey, "name of the message to print")
+	getCmd.AddCommand(getMessageCmd)
+
+	getHistoryCmd.Flags().StringVar(&historyKey, "key", storage.DefaultKey, "name of the message whose history to print")
+	getHistoryCmd.Flags().IntVar(&historyLimit, "limit", 20, "print at most this many revisions (0 for all)")
+	getHistoryCmd.Flags().StringVar(&historySince, "since", "", "only revisions made within a duration (e.g. 2h) or after a date or time")
+	getHistoryCmd.Flags().StringVarP(&historyOutput, "output", "o", "text", "output format (text, json)")
+	getHistoryCmd.RegisterFlagCompletionFunc("output", completeValues("text", "json"))
+	getHistoryCmd.Flags().BoolVar(&historyFull, "full", false, "show whole messages instead of their first line cut to 60 characters")
+	getHistoryCmd.Flags().StringVar(&historyServer, "server", "", "read the history from the greetd server at this URL instead of the data directory")
+	getCmd.AddCommand(getHistoryCmd)
+	rootCmd.AddCommand(getCmd)
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+// runGetHistory runs "greetd get history" with args, and the defaults of
+// the other flags, and returns its output.
+func runGetHistory(t *testing.T, configPath string, args ...string) string {
+	t.Helper()
+
+	// The flags keep their values between Execute calls.
+	reset := func() {
+		historyKey = storage.DefaultKey
+		historyLimit = 20
+		historySince = ""
+		historyOutput = "text"
+		historyFull = false
+		historyServer = ""
+	}
+	reset()
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		reset()
+	})
+
+	rootCmd.SetArgs(append([]string{"get", "history", "--config", configPath, "--log-output", "stdout"}, args...))
+	require.NoError(t, Execute())
+	return out.String()
+}
+
+func TestGetHistory(t *testing.T) {
+	configPath, _ := writeTestConfig(t)
+	runSetMessage := func(args ...string) {
+		rootCmd.SetIn(strings.NewReader(""))
+		rootCmd.SetOut(&bytes.Buffer{})
+		rootCmd.SetArgs(append([]string{"set", "message", "--config", configPath, "--log-output", "stdout"}, args...))
+		require.NoError(t, Execute())
+	}
+	t.Cleanup(func() {
+		rootCmd.SetIn(nil)
+		setMessageKey = storage.DefaultKey
+	})
+
+	long := strings.Repeat("x", 70)
+	runSetMessage("First")
+	runSetMessage("Second\nline")
+	runSetMessage(long)
+
+	out := runGetHistory(t, configPath)
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	require.Len(t, lines, 5, out)
+	assert.Regexp(t, `^REVISION\s+UPDATED\s+BY\s+MESSAGE$`, lines[0])
+	assert.Regexp(t, `^3\s+\S+\s+cli\s+x{59}…$`, lines[1])
+	assert.Regexp(t, `^2\s+\S+\s+cli\s+Second…$`, lines[2])
+	assert.Regexp(t, `^0\s+-\s+-\s+Hello, World!$`, lines[4])
+
+	out = runGetHistory(t, configPath, "--limit", "1", "--full")
+	assert.Contains(t, out, long)
+	assert.NotContains(t, out, "Second")
+
+	out = runGetHistory(t, configPath, "--since", "1h", "--output", "json")
+	var revisions []storage.MessageData
+	require.NoError(t, json.Unmarshal([]byte(out), &revisions))
+	require.Len(t, revisions, 3, "the default message has no time")
+	assert.Equal(t, "Second\nline", revisions[1].Message)
+
+	runSetMessage("--key", "motd", "Welcome")
+	assert.Regexp(t, `1\s+\S+\s+cli\s+Welcome\n$`, runGetHistory(t, configPath, "--key", "motd"))
+	assert.Contains(t, runGetHistory(t, configPath, "--key", "footer"), "Error: footer: message not found")
+	assert.Contains(t, runGetHistory(t, configPath, "--since", "yesterday"), "Error: --since: invalid duration or time")
+}
+
+func TestGetHistoryFromServer(t *testing.T) {
+	configPath, _ := writeTestConfig(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/messages/motd/history", r.URL.Path)
+		w.Write([]byte(`{"key":"motd","revisions":[{"message":"Welcome back","updated_at":"2026-10-15T09:00:00Z","updated_by":"ops","revision":2},{"message":"Welcome","updated_at":"2026-10-14T09:00:00Z","updated_by":"cli","revision":1}]}`))
+	}))
+	defer srv.Close()
+
+	out := runGetHistory(t, configPath, "--server", srv.URL, "--key", "motd", "--since", "2026-10-15")
+	assert.Regexp(t, `2\s+2026-10-15T09:00:00Z\s+ops\s+Welcome back\n$`, out)
+	assert.NotContains(t, out, "cli")
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	since, err := parseSince("", now)
+	require.NoError(t, err)
+	assert.True(t, since.IsZero())
+
+	since, err = parseSince("2h30m", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC), since)
+
+	since, err = parseSince("2026-10-01T08:00:00+02:00", now)
+	require.NoError(t, err)
+	assert.True(t, since.Equal(time.Date(2026, 10, 1, 6, 0, 0, 0, time.UTC)))
+
+	since, err = parseSince("2026-10-01", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local), since)
+
+	for _, value := range []string{"-2h", "2 days", "10/01/2026"} {
+		_, err := parseSince(value, now)
+		assert.Error(t, err, value)
+	}
+}
@@ -1,40 +1,188 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/svanhalla/prompt-lab/greetd/internal/client"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
 	"github.com/svanhalla/prompt-lab/greetd/internal/version"
 )
 
+// Exit codes of greetd health.
+const (
+	healthExitOK        = 0
+	healthExitDegraded  = 1
+	healthExitUnhealthy = 2
+)
+
+// healthSummaries start the summary line, by exit code.
+var healthSummaries = [...]string{"healthy", "degraded", "unhealthy"}
+
+var (
+	healthServer  string
+	healthReady   bool
+	healthTimeout time.Duration
+	healthOutput  string
+)
+
 type HealthInfo struct {
 	Status    string       `json:"status"`
 	Version   version.Info `json:"version"`
 	Timestamp time.Time    `json:"timestamp"`
+	// Checks maps each local check to "ok" or what is wrong.
+	Checks map[string]string `json:"checks"`
 }
 
 var healthCmd = &cobra.Command{
 	Use:   "health",
 	Short: "Print application health information",
+	Long: `Checks that the config loads and the state directory is writable, and
+prints the result as JSON. With --server it probes a running server's
+/health endpoint instead, or /readyz with --ready, and prints the JSON
+response only with --output json.
+
+A one-line summary goes to standard error. The exit code is 0 when healthy
+(or ready), 1 when degraded, and 2 when unhealthy, not ready or unreachable,
+so the command can serve as a Docker HEALTHCHECK:
+
+  HEALTHCHECK CMD ./greetd health --server http://localhost:8080 || exit 1`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		health := HealthInfo{
-			Status:    "ok",
-			Version:   version.Get(),
-			Timestamp: time.Now(),
+		var code int
+		if healthServer == "" {
+			code = localHealth(cmd, cmd.OutOrStdout(), cmd.ErrOrStderr())
+		} else {
+			if healthOutput != "text" && healthOutput != "json" {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Error: unknown output format %q (use text or json)\n", healthOutput)
+				os.Exit(healthExitUnhealthy)
+			}
+			c, err := outboundClient(cmd, healthServer, "")
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+				os.Exit(healthExitUnhealthy)
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), healthTimeout)
+			defer cancel()
+			code = probeHealth(ctx, c, healthReady, healthOutput == "json", cmd.OutOrStdout(), cmd.ErrOrStderr())
+		}
+		if code != healthExitOK {
+			os.Exit(code)
+		}
+	},
+}
+
+// localHealth prints the health of the local installation as JSON and
+// returns the exit code.
+func localHealth(cmd *cobra.Command, out, errOut io.Writer) int {
+	health := HealthInfo{
+		Status:    "ok",
+		Version:   version.Get(),
+		Timestamp: time.Now(),
+		Checks:    map[string]string{"config": "ok", "state_dir": "ok"},
+	}
+
+	code := healthExitOK
+	cfg, err := config.Load(cfgFile, dataPathFlag, configFlags(cmd))
+	if err != nil {
+		health.Status = "unhealthy"
+		health.Checks["config"] = err.Error()
+		delete(health.Checks, "state_dir")
+		code = healthExitUnhealthy
+	} else if err := storage.CheckWritable(cfg.StateDir()); err != nil {
+		// The server still serves everything but message changes.
+		health.Status = "degraded"
+		health.Checks["state_dir"] = err.Error()
+		code = healthExitDegraded
+	}
+
+	output, err := json.MarshalIndent(health, "", "  ")
+	if err != nil {
+		fmt.Fprintf(errOut, "Error marshaling health info: %v\n", err)
+		return healthExitUnhealthy
+	}
+	fmt.Fprintln(out, string(output))
+
+	fmt.Fprintf(errOut, "%s: status %q%s\n", healthSummaries[code], health.Status, formatChecks(health.Checks))
+	return code
+}
+
+// probeHealth probes the server of c, prints a summary to errOut and, with
+// printJSON, the response to out, and returns the exit code.
+func probeHealth(ctx context.Context, c *client.Client, ready, printJSON bool, out, errOut io.Writer) int {
+	endpoint := "/health"
+	if ready {
+		endpoint = "/readyz"
+	}
+
+	probe, err := c.Probe(ctx, ready)
+	if err != nil {
+		var apiErr *client.Error
+		if errors.As(err, &apiErr) {
+			fmt.Fprintf(errOut, "unhealthy: %s %v\n", endpoint, err)
+		} else {
+			fmt.Fprintf(errOut, "unreachable: %v\n", err)
 		}
+		return healthExitUnhealthy
+	}
+	if printJSON {
+		fmt.Fprintln(out, string(probe.Body))
+	}
 
-		output, err := json.MarshalIndent(health, "", "  ")
-		if err != nil {
-			fmt.Printf("Error marshaling health info: %v\n", err)
-			return
+	var code int
+	switch {
+	case probe.StatusCode != http.StatusOK:
+		code = healthExitUnhealthy
+	case probe.Status == "ok" || probe.Status == "ready":
+		code = healthExitOK
+	case probe.Status == "degraded":
+		code = healthExitDegraded
+	default:
+		code = healthExitUnhealthy
+	}
+	// /readyz reports warnings, such as read-only storage, in its checks.
+	if code == healthExitOK && ready {
+		for _, check := range probe.Checks {
+			if check != "ok" {
+				code = healthExitDegraded
+			}
 		}
+	}
 
-		fmt.Println(string(output))
-	},
+	fmt.Fprintf(errOut, "%s: %s returned %d, status %q%s\n", healthSummaries[code], endpoint, probe.StatusCode, probe.Status, formatChecks(probe.Checks))
+	return code
+}
+
+// formatChecks lists the checks that are not "ok", e.g. " (storage: read-only)".
+func formatChecks(checks map[string]string) string {
+	var failing []string
+	for name, check := range checks {
+		if check != "ok" {
+			failing = append(failing, name+": "+check)
+		}
+	}
+	if len(failing) == 0 {
+		return ""
+	}
+	sort.Strings(failing)
+	return " (" + strings.Join(failing, ", ") + ")"
 }
 
 func init() {
+	healthCmd.Flags().StringVar(&healthServer, "server", "", "probe the greetd server at this URL instead of the local installation")
+	healthCmd.Flags().BoolVar(&healthReady, "ready", false, "probe /readyz instead of /health (with --server)")
+	healthCmd.Flags().DurationVar(&healthTimeout, "timeout", 2*time.Second, "how long to wait for the server (with --server)")
+	healthCmd.Flags().StringVarP(&healthOutput, "output", "o", "text", "output format with --server (text, json)")
+	healthCmd.RegisterFlagCompletionFunc("output", completeValues("text", "json"))
 	rootCmd.AddCommand(healthCmd)
 }
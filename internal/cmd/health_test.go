@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/client"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+func TestProbeHealth(t *testing.T) {
+	tests := []struct {
+		name    string
+		ready   bool
+		status  int
+		body    string
+		delay   time.Duration
+		want    int
+		summary string
+	}{
+		{"healthy", false, http.StatusOK, `{"status":"ok","checks":{"maintenance":"ok"}}`, 0, healthExitOK, "healthy: /health returned 200, status \"ok\"\n"},
+		{"degraded", false, http.StatusOK, `{"status":"degraded","checks":{"templates":"degraded"}}`, 0, healthExitDegraded, "degraded: /health returned 200, status \"degraded\" (templates: degraded)\n"},
+		{"server error", false, http.StatusInternalServerError, `{"status":"ok"}`, 0, healthExitUnhealthy, "unhealthy: /health returned 500, status \"ok\"\n"},
+		{"not a health response", false, http.StatusBadGateway, `<html>Bad Gateway</html>`, 0, healthExitUnhealthy, "unhealthy: /health server returned 502: not a health response\n"},
+		{"ready", true, http.StatusOK, `{"status":"ready","checks":{"storage":"ok","maintenance":"ok"}}`, 0, healthExitOK, "healthy: /readyz returned 200, status \"ready\"\n"},
+		{"ready with warning", true, http.StatusOK, `{"status":"ready","checks":{"storage":"read-only","maintenance":"ok"}}`, 0, healthExitDegraded, "degraded: /readyz returned 200, status \"ready\" (storage: read-only)\n"},
+		{"not ready", true, http.StatusServiceUnavailable, `{"status":"not ready","checks":{"storage":"ok","maintenance":"enabled"}}`, 0, healthExitUnhealthy, "unhealthy: /readyz returned 503, status \"not ready\" (maintenance: enabled)\n"},
+		{"timeout", false, http.StatusOK, `{"status":"ok"}`, time.Second, healthExitUnhealthy, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.ready {
+					assert.Equal(t, "/api/v1/readyz", r.URL.Path)
+				} else {
+					assert.Equal(t, "/api/v1/health", r.URL.Path)
+				}
+				select {
+				case <-time.After(tt.delay):
+				case <-r.Context().Done():
+					return
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			var out, errOut bytes.Buffer
+			code := probeHealth(ctx, client.New(srv.URL, ""), tt.ready, true, &out, &errOut)
+
+			assert.Equal(t, tt.want, code)
+			if tt.summary != "" {
+				assert.Equal(t, tt.summary, errOut.String())
+			} else {
+				assert.Contains(t, errOut.String(), "unreachable: ")
+			}
+			if tt.delay == 0 && json.Valid([]byte(tt.body)) {
+				assert.JSONEq(t, tt.body, out.String())
+			} else {
+				assert.Empty(t, out.String())
+			}
+		})
+	}
+}
+
+func TestProbeHealthOutput(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer srv.Close()
+
+	var out, errOut bytes.Buffer
+	assert.Equal(t, healthExitOK, probeHealth(context.Background(), client.New(srv.URL, ""), false, false, &out, &errOut))
+	assert.Empty(t, out.String())
+	assert.Equal(t, "healthy: /health returned 200, status \"ok\"\n", errOut.String())
+}
+
+func TestProbeHealthUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	var out, errOut bytes.Buffer
+	assert.Equal(t, healthExitUnhealthy, probeHealth(context.Background(), client.New(url, ""), false, true, &out, &errOut))
+	assert.Empty(t, out.String())
+	assert.Contains(t, errOut.String(), "unreachable: request failed")
+}
+
+func TestLocalHealth(t *testing.T) {
+	configPath, _ := writeTestConfig(t)
+	t.Cleanup(func() { cfgFile = "" })
+
+	run := func() (HealthInfo, string, int) {
+		t.Helper()
+		cfgFile = configPath
+		var out, errOut bytes.Buffer
+		code := localHealth(healthCmd, &out, &errOut)
+		var health HealthInfo
+		require.NoError(t, json.Unmarshal(out.Bytes(), &health))
+		return health, errOut.String(), code
+	}
+
+	health, summary, code := run()
+	assert.Equal(t, healthExitOK, code)
+	assert.Equal(t, "ok", health.Status)
+	assert.Equal(t, map[string]string{"config": "ok", "state_dir": "ok"}, health.Checks)
+	assert.Equal(t, "healthy: status \"ok\"\n", summary)
+
+	// A state directory that cannot be written to is degraded.
+	cfg, err := config.Load(configPath, "", nil)
+	require.NoError(t, err)
+	blocker := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(blocker, nil, 0644))
+	cfg.StatePath = blocker
+	require.NoError(t, cfg.Save(configPath))
+
+	health, summary, code = run()
+	assert.Equal(t, healthExitDegraded, code)
+	assert.Equal(t, "degraded", health.Status)
+	assert.NotEqual(t, "ok", health.Checks["state_dir"])
+	assert.Contains(t, summary, "degraded: status \"degraded\" (state_dir: ")
+
+	// A config that does not load is unhealthy.
+	require.NoError(t, os.WriteFile(configPath, []byte("{"), 0644))
+	health, summary, code = run()
+	assert.Equal(t, healthExitUnhealthy, code)
+	assert.Equal(t, "unhealthy", health.Status)
+	assert.Contains(t, summary, "unhealthy: status \"unhealthy\" (config: ")
+}
@@ -1,27 +1,96 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/greeting"
+	"github.com/svanhalla/prompt-lab/greetd/internal/i18n"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
 )
 
 var (
-	name string
+	name      string
+	lang      string
+	repeat    int
+	shout     bool
+	timeAware bool
+	at        string
 )
 
 var helloCmd = &cobra.Command{
 	Use:   "hello",
 	Short: "Print a friendly greeting",
 	Run: func(cmd *cobra.Command, args []string) {
-		if name == "" {
-			name = "World"
+		if repeat < 1 {
+			fmt.Println("Error: --repeat must be at least 1")
+			os.Exit(1)
 		}
-		fmt.Printf("Hello, %s!\n", name)
+
+		opts := greeting.Options{Repeat: repeat, Shout: shout, TimeAware: timeAware}
+		if at != "" {
+			clock, err := greeting.ParseClock(at)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			opts.At = clock
+		}
+
+		cfg, err := config.Load(cfgFile, dataPathFlag, configFlags(cmd))
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+
+		catalog, err := i18n.Load(cfg.Greetings.CatalogPath)
+		if err != nil {
+			fmt.Printf("Error loading greetings catalog: %v\n", err)
+			os.Exit(1)
+		}
+
+		greeter, err := greeting.New(cfg.Greetings.Template, catalog)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		message, _, err := greeter.Greet(name, lang, opts)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(message)
+
+		recordHello(cfg.StateDir(), name)
 	},
 }
 
+// recordHello counts the greeting in the local hello stats. Stats are best
+// effort: a read-only data directory is skipped silently.
+func recordHello(dataPath, name string) {
+	stats := storage.NewHelloStats(dataPath)
+	if err := stats.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load hello stats: %v\n", err)
+		return
+	}
+
+	stats.Record(name)
+	if err := stats.Save(); err != nil && !errors.Is(err, storage.ErrReadOnly) {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save hello stats: %v\n", err)
+	}
+}
+
 func init() {
 	helloCmd.Flags().StringVar(&name, "name", "", "name to greet")
+	helloCmd.Flags().StringVar(&lang, "lang", i18n.DefaultLang, "greeting language (en, sv, de, fr, es, ja, ...)")
+	helloCmd.Flags().IntVar(&repeat, "repeat", 1, "print the greeting N times")
+	helloCmd.Flags().BoolVar(&shout, "shout", false, "uppercase the greeting")
+	helloCmd.Flags().BoolVar(&timeAware, "time-aware", false, "greet with good morning, afternoon or evening")
+	helloCmd.Flags().StringVar(&at, "at", "", "time of day (HH:MM) for --time-aware instead of the current time")
+	helloCmd.RegisterFlagCompletionFunc("lang", completeLang)
 	rootCmd.AddCommand(helloCmd)
 }
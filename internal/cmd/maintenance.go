@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/svanhalla/prompt-lab/greetd/internal/client"
+)
+
+var maintenanceMessage string
+
+var maintenanceCmd = &cobra.Command{
+	Use:   "maintenance [on|off]",
+	Short: "Show or change the maintenance mode of a running server",
+	Long: `Shows or changes the maintenance mode of a running server through its
+admin API, using the API key. While it is on, /hello, /message and /ui answer
+503 with the --message text; /health, /readyz (not ready), /metrics and the
+admin endpoints keep working. The mode is kept in the data directory and
+survives restarts.`,
+	Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: []string{"on", "off"},
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newClient(cmd)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		ctx := context.Background()
+
+		var state *client.Maintenance
+		if len(args) == 0 {
+			state, err = c.GetMaintenance(ctx)
+		} else {
+			state, err = c.SetMaintenance(ctx, args[0] == "on", maintenanceMessage)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if !state.Enabled {
+			fmt.Println("Maintenance mode is off")
+			return
+		}
+		fmt.Printf("Maintenance mode is on: %s\n", state.Message)
+	},
+}
+
+func init() {
+	maintenanceCmd.Flags().StringVar(&maintenanceMessage, "message", "", "text shown to users while maintenance mode is on")
+	maintenanceCmd.Flags().StringVar(&serverURL, "server", client.DefaultServer, "greetd server URL")
+	maintenanceCmd.Flags().StringVar(&apiKey, "api-key", "", "API key for admin endpoints (or GREETD_API_KEY)")
+	rootCmd.AddCommand(maintenanceCmd)
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+var migrateDryRun bool
+
+var migrateDataCmd = &cobra.Command{
+	Use:   "migrate-data",
+	Short: "Move ~/.greetd to the XDG config and state directories",
+	Long: `Moves config.json from ~/.greetd to $XDG_CONFIG_HOME/greetd and everything
+else (messages.json, logs) to $XDG_STATE_HOME/greetd, then removes the empty
+legacy directory. Nothing is overwritten; use --dry-run to see the plan.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := migrateData(cmd.OutOrStdout(), config.LegacyDataPath(), config.XDGConfigPath(), config.XDGStatePath(), migrateDryRun)
+		if err != nil {
+			fmt.Fprintf(cmd.OutOrStdout(), "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// migrateData moves config.json from legacy to configDir and every other
+// entry to stateDir. With dryRun it only prints what it would do.
+func migrateData(out io.Writer, legacy, configDir, stateDir string, dryRun bool) error {
+	entries, err := os.ReadDir(legacy)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(out, "Nothing to migrate: %s does not exist\n", legacy)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	type move struct{ from, to string }
+	var moves []move
+	for _, entry := range entries {
+		dir := stateDir
+		if entry.Name() == "config.json" {
+			dir = configDir
+		}
+		to := filepath.Join(dir, entry.Name())
+		if _, err := os.Lstat(to); err == nil {
+			return fmt.Errorf("%s already exists; move or remove it first", to)
+		}
+		moves = append(moves, move{from: filepath.Join(legacy, entry.Name()), to: to})
+	}
+
+	for _, m := range moves {
+		if dryRun {
+			fmt.Fprintf(out, "Would move %s -> %s\n", m.from, m.to)
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(m.to), 0755); err != nil {
+			return err
+		}
+		if filepath.Base(m.from) == "config.json" {
+			err = moveConfigFile(m.from, m.to, legacy, configDir)
+		} else {
+			err = moveFile(m.from, m.to)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to move %s: %w", m.from, err)
+		}
+		fmt.Fprintf(out, "Moved %s -> %s\n", m.from, m.to)
+	}
+
+	if dryRun {
+		fmt.Fprintf(out, "Would remove %s\n", legacy)
+		return nil
+	}
+	if err := os.Remove(legacy); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", legacy, err)
+	}
+	fmt.Fprintf(out, "Removed %s\n", legacy)
+	return nil
+}
+
+// moveConfigFile moves the config file and points a data_path that named
+// the legacy directory at configDir instead. Other settings are kept as-is.
+func moveConfigFile(from, to, legacy, configDir string) error {
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+
+	var settings map[string]interface{}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("invalid config file: %w", err)
+	}
+	if dataPath, ok := settings["data_path"].(string); ok && config.ExpandPath(dataPath) == legacy {
+		settings["data_path"] = configDir
+	}
+
+	data, err = json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(to, data, 0644); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
+
+// moveFile renames from to to, copying when they are on different file
+// systems.
+func moveFile(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("cannot copy directory %s across file systems", from)
+	}
+
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
+
+func init() {
+	migrateDataCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "print what would be moved without changing anything")
+	rootCmd.AddCommand(migrateDataCmd)
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeLegacyDir creates a legacy data directory with a config file that
+// points data_path at it, a message file and a log file.
+func writeLegacyDir(t *testing.T) string {
+	t.Helper()
+
+	legacy := filepath.Join(t.TempDir(), ".greetd")
+	require.NoError(t, os.Mkdir(legacy, 0755))
+	config, err := json.Marshal(map[string]interface{}{"data_path": legacy, "server": map[string]interface{}{"port": 9090}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(legacy, "config.json"), config, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(legacy, "message.json"), []byte(`{"message":"hi"}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(legacy, "app.log"), []byte("log\n"), 0644))
+	return legacy
+}
+
+func TestMigrateDataDryRun(t *testing.T) {
+	legacy := writeLegacyDir(t)
+	configDir := filepath.Join(t.TempDir(), "config")
+	stateDir := filepath.Join(t.TempDir(), "state")
+
+	var out bytes.Buffer
+	require.NoError(t, migrateData(&out, legacy, configDir, stateDir, true))
+
+	assert.Contains(t, out.String(), "Would move "+filepath.Join(legacy, "config.json")+" -> "+filepath.Join(configDir, "config.json"))
+	assert.Contains(t, out.String(), "Would move "+filepath.Join(legacy, "message.json")+" -> "+filepath.Join(stateDir, "message.json"))
+	assert.FileExists(t, filepath.Join(legacy, "message.json"))
+	assert.NoDirExists(t, configDir)
+	assert.NoDirExists(t, stateDir)
+}
+
+func TestMigrateData(t *testing.T) {
+	legacy := writeLegacyDir(t)
+	configDir := filepath.Join(t.TempDir(), "config")
+	stateDir := filepath.Join(t.TempDir(), "state")
+
+	require.NoError(t, migrateData(&bytes.Buffer{}, legacy, configDir, stateDir, false))
+
+	assert.NoDirExists(t, legacy)
+	assert.FileExists(t, filepath.Join(stateDir, "message.json"))
+	assert.FileExists(t, filepath.Join(stateDir, "app.log"))
+
+	data, err := os.ReadFile(filepath.Join(configDir, "config.json"))
+	require.NoError(t, err)
+	var settings map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &settings))
+	assert.Equal(t, configDir, settings["data_path"])
+	assert.Equal(t, float64(9090), settings["server"].(map[string]interface{})["port"])
+}
+
+func TestMigrateDataRefusesToOverwrite(t *testing.T) {
+	legacy := writeLegacyDir(t)
+	stateDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(stateDir, "message.json"), []byte("{}"), 0644))
+
+	err := migrateData(&bytes.Buffer{}, legacy, t.TempDir(), stateDir, false)
+	assert.ErrorContains(t, err, "already exists")
+	assert.FileExists(t, filepath.Join(legacy, "message.json"))
+}
+
+func TestMigrateDataNothingToDo(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, migrateData(&out, filepath.Join(t.TempDir(), ".greetd"), t.TempDir(), t.TempDir(), false))
+	assert.Contains(t, out.String(), "Nothing to migrate")
+}
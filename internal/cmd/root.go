@@ -1,18 +1,24 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"os"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 	"github.com/svanhalla/prompt-lab/greetd/internal/config"
 	"github.com/svanhalla/prompt-lab/greetd/internal/logging"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+	"github.com/svanhalla/prompt-lab/greetd/internal/validate"
 )
 
 var (
-	cfgFile   string
-	logLevel  string
-	logFormat string
+	cfgFile      string
+	dataPathFlag string
+	logLevel     string
+	logFormat    string
+	logOutput    string
 )
 
 var rootCmd = &cobra.Command{
@@ -30,45 +36,91 @@ func Execute() error {
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
-
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file path")
+	rootCmd.PersistentFlags().StringVar(&dataPathFlag, "data-path", "", "config directory (default $XDG_CONFIG_HOME/greetd or an existing ~/.greetd, or $GREETD_DATA_PATH)")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "log level (debug, info, warn, error)")
 	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log format (text, json)")
-
-	viper.BindPFlag("logging.level", rootCmd.PersistentFlags().Lookup("log-level"))
-	viper.BindPFlag("logging.format", rootCmd.PersistentFlags().Lookup("log-format"))
+	rootCmd.PersistentFlags().StringVar(&logOutput, "log-output", "", "log output (stdout, file, both)")
 }
 
-func initConfig() {
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
+// configFlags returns the flags of cmd that override config settings. Flags
+// the command does not have are nil and ignored by config.Load.
+func configFlags(cmd *cobra.Command) config.Flags {
+	flags := cmd.Flags()
+	return config.Flags{
+		"logging.level":       flags.Lookup("log-level"),
+		"logging.format":      flags.Lookup("log-format"),
+		"logging.output":      flags.Lookup("log-output"),
+		"server.host":         flags.Lookup("host"),
+		"server.port":         flags.Lookup("port"),
+		"server.port_retry":   flags.Lookup("port-retry"),
+		"server.enable_pprof": flags.Lookup("enable-pprof"),
+		"server.debug":        flags.Lookup("debug"),
+		"logging.dump_bodies": flags.Lookup("dump-bodies"),
+		"ui.dev_mode":         flags.Lookup("dev"),
+		"ui.strict_templates": flags.Lookup("strict-templates"),
 	}
 }
 
-func loadConfigAndLogger() (*config.Config, error) {
-	cfg, err := config.Load(cfgFile)
+// loadConfigAndLogger loads the configuration with the flags set on cmd
+// applied and sets up the application logger.
+func loadConfigAndLogger(cmd *cobra.Command) (*config.Config, *logrus.Logger, error) {
+	cfg, err := config.Load(cfgFile, dataPathFlag, configFlags(cmd))
 	if err != nil {
-		return nil, fmt.Errorf("failed to load config: %w", err)
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
-	// Override with flags if provided
-	if logLevel != "" {
-		cfg.Logging.Level = logLevel
-	}
-	if logFormat != "" {
-		cfg.Logging.Format = logFormat
+	// On a read-only filesystem, log to stdout rather than failing every
+	// write to app.log.
+	var unwritable error
+	if cfg.Logging.Output != logging.OutputStdout {
+		if unwritable = storage.CheckWritable(cfg.StateDir()); unwritable != nil {
+			cfg.Logging.Output = logging.OutputStdout
+		}
 	}
 
-	logger, err := logging.Setup(cfg.Logging.Level, cfg.Logging.Format, cfg.DataPath)
+	logger, err := logging.Setup(cfg.Logging.Level, cfg.Logging.Format, cfg.Logging.Output, cfg.StateDir())
 	if err != nil {
-		return nil, fmt.Errorf("failed to setup logging: %w", err)
+		return nil, nil, fmt.Errorf("failed to setup logging: %w", err)
+	}
+	if unwritable != nil {
+		logger.WithError(unwritable).Warnf("%s is not writable; logging to stdout only", cfg.StateDir())
 	}
 
-	// Store logger globally for commands to use
-	globalLogger = logger
+	return cfg, logger, nil
+}
+
+// loadKeyring returns the keys messages.json is encrypted with, or nil
+// when none are configured.
+func loadKeyring(cfg *config.Config) (*storage.Keyring, error) {
+	return storage.LoadKeyring(cfg.Storage.Encryption.KeyFile, os.Getenv(config.StorageKeyEnv))
+}
 
-	return cfg, nil
+// messageRules returns the rules messages are validated with, including
+// the banned words of message.banned_words_file.
+func messageRules(cfg *config.Config) (validate.Options, error) {
+	rules := cfg.Message.Rules()
+	words, err := validate.LoadWordFilter(cfg.Message.BannedWordsFile)
+	if err != nil {
+		return rules, fmt.Errorf("failed to load message.banned_words_file: %w", err)
+	}
+	rules.Words = words
+	return rules, nil
 }
 
-var globalLogger interface{}
+// loadMessageStore loads the messages in the state directory of cfg,
+// reading and writing messages.json with the configured keys.
+func loadMessageStore(ctx context.Context, cfg *config.Config) (*storage.MessageStore, error) {
+	keys, err := loadKeyring(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	store := storage.NewMessageStore(cfg.StateDir())
+	store.SetKeyring(keys)
+	store.SetMaxKeys(cfg.Message.MaxKeys)
+	if err := store.Load(ctx); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/api"
+	"github.com/svanhalla/prompt-lab/greetd/internal/client"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+// writeTestConfig points the commands at a config file and data directory
+// under a temp dir, and keeps config.Load away from the real home directory.
+func writeTestConfig(t *testing.T) (configPath, dataPath string) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	resetFlags(t)
+
+	cfg := config.DefaultConfig()
+	cfg.DataPath = filepath.Join(tmpDir, "data")
+	require.NoError(t, os.MkdirAll(cfg.DataPath, 0755))
+
+	configPath = filepath.Join(tmpDir, "config.json")
+	require.NoError(t, cfg.Save(configPath))
+	return configPath, cfg.DataPath
+}
+
+// resetFlags restores the persistent flags after the test, since the
+// commands keep their values in package variables between Execute calls and
+// viper reads flags that were once set.
+func resetFlags(t *testing.T) {
+	t.Cleanup(func() {
+		rootCmd.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+			f.Value.Set(f.DefValue)
+			f.Changed = false
+		})
+	})
+}
+
+func TestCommandsDoNotPanic(t *testing.T) {
+	configPath, dataPath := writeTestConfig(t)
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"api help", []string{"api", "--help"}},
+		{"set message", []string{"set", "message", "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rootCmd.SetArgs(append(tt.args, "--config", configPath, "--log-output", "stdout"))
+			assert.NotPanics(t, func() {
+				assert.NoError(t, Execute())
+			})
+		})
+	}
+
+	store := storage.NewMessageStore(dataPath)
+	require.NoError(t, store.Load(context.Background()))
+	assert.Equal(t, "x", store.GetMessage(context.Background()))
+}
+
+func TestDataPathInstancesDoNotInterfere(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	resetFlags(t)
+
+	ctx := context.Background()
+	clients := map[string]*client.Client{}
+
+	for _, instance := range []string{"a", "b"} {
+		dataPath := filepath.Join(t.TempDir(), instance)
+
+		rootCmd.SetArgs([]string{"set", "message", "from " + instance, "--data-path", dataPath, "--log-output", "stdout"})
+		require.NoError(t, Execute())
+
+		cfg, logger, err := loadConfigAndLogger(setMessageCmd)
+		require.NoError(t, err)
+		require.Equal(t, dataPath, cfg.DataPath)
+
+		store := storage.NewMessageStore(cfg.DataPath)
+		require.NoError(t, store.Load(context.Background()))
+
+		server, err := api.NewServer(cfg, store, logger)
+		require.NoError(t, err)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		go server.Serve(listener)
+		t.Cleanup(func() { server.Shutdown(context.Background()) })
+
+		clients[instance] = client.New("http://"+listener.Addr().String(), "")
+	}
+
+	_, err := clients["a"].SetMessage(ctx, "updated a")
+	require.NoError(t, err)
+
+	for instance, want := range map[string]string{"a": "updated a", "b": "from b"} {
+		got, err := clients[instance].GetMessage(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "instance %s", instance)
+	}
+}
+
+func TestConfigInit(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	resetFlags(t)
+	dataPath := filepath.Join(t.TempDir(), "data")
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	t.Cleanup(func() { rootCmd.SetOut(nil) })
+
+	rootCmd.SetArgs([]string{"config", "init", "--data-path", dataPath})
+	require.NoError(t, Execute())
+	assert.Contains(t, out.String(), "Created config file")
+	assert.FileExists(t, filepath.Join(dataPath, "config.json"))
+
+	out.Reset()
+	require.NoError(t, Execute())
+	assert.Contains(t, out.String(), "already exists")
+}
+
+func TestConfigShow(t *testing.T) {
+	configPath, dataPath := writeTestConfig(t)
+	cfg, err := config.Load(configPath, "", nil)
+	require.NoError(t, err)
+	cfg.Security.APIKeys = []string{"top-secret"}
+	require.NoError(t, cfg.Save(configPath))
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		configEffective = false
+	})
+
+	rootCmd.SetArgs([]string{"config", "show", "--config", configPath})
+	require.NoError(t, Execute())
+	var shown config.Config
+	require.NoError(t, json.Unmarshal(out.Bytes(), &shown))
+	assert.Equal(t, dataPath, shown.DataPath)
+	assert.Equal(t, []string{config.KeyFingerprint("top-secret")}, shown.Security.APIKeys)
+
+	out.Reset()
+	rootCmd.SetArgs([]string{"config", "show", "--effective", "--config", configPath})
+	require.NoError(t, Execute())
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.NotEmpty(t, lines)
+	assert.True(t, strings.HasPrefix(lines[0], "version:"), lines[0])
+	assert.Contains(t, out.String(), "config_file: "+configPath)
+	assert.Contains(t, out.String(), config.KeyFingerprint("top-secret"))
+	assert.NotContains(t, out.String(), "top-secret")
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/svanhalla/prompt-lab/greetd/internal/api"
+	"github.com/svanhalla/prompt-lab/greetd/internal/client"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+var selftestTimeout time.Duration
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Start a throwaway server and verify its endpoints",
+	Long: `Starts the API on an ephemeral port with a temporary data directory,
+exercises the main endpoints, prints a PASS/FAIL table and exits non-zero if
+any check failed. The temporary directory is removed afterwards.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), selftestTimeout)
+		defer cancel()
+
+		results, err := runSelftest(ctx)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if !printSelftest(cmd.OutOrStdout(), results) {
+			os.Exit(1)
+		}
+	},
+}
+
+// selftestResult is the outcome of one selftest check.
+type selftestResult struct {
+	Name string
+	Err  error
+}
+
+type selftestCheck struct {
+	name string
+	run  func(ctx context.Context, c *client.Client) error
+}
+
+var selftestChecks = []selftestCheck{
+	{"GET /health", func(ctx context.Context, c *client.Client) error {
+		health, err := c.Health(ctx)
+		if err != nil {
+			return err
+		}
+		if health.Status != "ok" {
+			return fmt.Errorf("status %q", health.Status)
+		}
+		return nil
+	}},
+	{"GET /hello", func(ctx context.Context, c *client.Client) error {
+		greeting, err := c.Hello(ctx, "Selftest")
+		if err != nil {
+			return err
+		}
+		if greeting.Message != "Hello, Selftest!" {
+			return fmt.Errorf("unexpected greeting %q", greeting.Message)
+		}
+		return nil
+	}},
+	{"GET /message", func(ctx context.Context, c *client.Client) error {
+		_, err := c.GetMessage(ctx)
+		return err
+	}},
+	{"POST /message", func(ctx context.Context, c *client.Client) error {
+		const want = "selftest message"
+		if _, err := c.SetMessage(ctx, want); err != nil {
+			return err
+		}
+		got, err := c.GetMessage(ctx)
+		if err != nil {
+			return err
+		}
+		if got != want {
+			return fmt.Errorf("stored %q, read back %q", want, got)
+		}
+		return nil
+	}},
+	{"GET /ui", func(ctx context.Context, c *client.Client) error {
+		return c.Page(ctx, "/ui")
+	}},
+	{"GET /swagger/", func(ctx context.Context, c *client.Client) error {
+		return c.Page(ctx, "/swagger/")
+	}},
+	{"GET /docs", func(ctx context.Context, c *client.Client) error {
+		return c.Page(ctx, "/docs")
+	}},
+}
+
+// runSelftest starts a server with default settings and a temporary data
+// directory, runs every check against it and removes the directory again.
+func runSelftest(ctx context.Context) ([]selftestResult, error) {
+	dataPath, err := os.MkdirTemp("", "greetd-selftest")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	defer os.RemoveAll(dataPath)
+
+	cfg := config.DefaultConfig()
+	cfg.DataPath = dataPath
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	store := storage.NewMessageStore(dataPath)
+	if err := store.Load(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load message store: %w", err)
+	}
+
+	server, err := api.NewServer(cfg, store, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Serve(listener) }()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+		<-serveErr
+	}()
+
+	c := client.New("http://"+listener.Addr().String(), "")
+
+	results := make([]selftestResult, 0, len(selftestChecks))
+	for _, check := range selftestChecks {
+		results = append(results, selftestResult{Name: check.name, Err: check.run(ctx, c)})
+	}
+
+	return results, nil
+}
+
+// printSelftest writes the results as a table and reports whether all
+// checks passed.
+func printSelftest(w io.Writer, results []selftestResult) bool {
+	passed := true
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tRESULT\tDETAIL")
+	for _, result := range results {
+		status, detail := "PASS", ""
+		if result.Err != nil {
+			status, detail = "FAIL", result.Err.Error()
+			passed = false
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", result.Name, status, detail)
+	}
+	tw.Flush()
+
+	return passed
+}
+
+func init() {
+	selftestCmd.Flags().DurationVar(&selftestTimeout, "timeout", 30*time.Second, "time allowed for all checks")
+	rootCmd.AddCommand(selftestCmd)
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSelftest(t *testing.T) {
+	// /docs reads the spec from api/openapi.yaml in the working directory.
+	t.Chdir("../..")
+
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	results, err := runSelftest(ctx)
+	require.NoError(t, err)
+	require.Len(t, results, len(selftestChecks))
+	for _, result := range results {
+		assert.NoError(t, result.Err, result.Name)
+	}
+
+	leftovers, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	assert.Empty(t, leftovers, "selftest left files in %s", tmpDir)
+}
+
+func TestPrintSelftest(t *testing.T) {
+	var buf bytes.Buffer
+	ok := printSelftest(&buf, []selftestResult{
+		{Name: "GET /health"},
+		{Name: "GET /docs", Err: errors.New("server returned 404")},
+	})
+
+	assert.False(t, ok)
+	assert.Regexp(t, `GET /health\s+PASS`, buf.String())
+	assert.Regexp(t, `GET /docs\s+FAIL\s+server returned 404`, buf.String())
+
+	assert.True(t, printSelftest(&bytes.Buffer{}, []selftestResult{{Name: "GET /health"}}))
+}
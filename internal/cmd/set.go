@@ -2,10 +2,15 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+	"github.com/svanhalla/prompt-lab/greetd/internal/validate"
 )
 
 var setCmd = &cobra.Command{
@@ -13,39 +18,180 @@ var setCmd = &cobra.Command{
 	Short: "Set application data",
 }
 
+var (
+	setMessageFile  string
+	setMessageKey   string
+	setMessageUndo  bool
+	setMessageRedo  bool
+	setMessageForce bool
+)
+
 var setMessageCmd = &cobra.Command{
-	Use:   "message <text>",
+	Use:   "message <text> | --file <path> | - | --undo | --redo",
 	Short: "Set the message that the API and Web UI will serve",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Set the message that the API and Web UI will serve.
+
+The message is taken from the arguments, from a file with --file, or from
+standard input with "-" or when input is piped and no arguments are given.
+A single trailing newline is removed from file and stdin input.
+
+With --key the message is stored under that name instead of the default
+one served by /message.
+
+--undo restores the message before the last change, and --redo restores
+the message undone last.
+
+--force stores the message even if it contains words from
+message.banned_words_file; the other rules still apply.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		cfg, err := loadConfigAndLogger()
+		out := cmd.OutOrStdout()
+
+		if !storage.ValidKey(setMessageKey) {
+			fmt.Fprintf(out, "Error: %v\n", storage.ErrInvalidKey)
+			return
+		}
+
+		if setMessageUndo || setMessageRedo {
+			restoreMessage(cmd, args)
+			return
+		}
+
+		message, err := readMessageInput(args, setMessageFile, cmd.InOrStdin())
 		if err != nil {
-			fmt.Printf("Error loading config: %v\n", err)
+			fmt.Fprintf(out, "Error: %v\n", err)
 			return
 		}
 
-		message := strings.Join(args, " ")
-		if strings.TrimSpace(message) == "" {
-			fmt.Println("Error: message cannot be empty")
+		cfg, logger, err := loadConfigAndLogger(cmd)
+		if err != nil {
+			fmt.Fprintf(out, "Error loading config: %v\n", err)
 			return
 		}
 
-		store := storage.NewMessageStore(cfg.DataPath)
-		if err := store.Load(); err != nil {
-			fmt.Printf("Error loading message store: %v\n", err)
+		rules, err := messageRules(cfg)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return
+		}
+		if setMessageForce {
+			rules.Words = nil
+		}
+		if message, err = validate.Prepare(message, rules); err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
 			return
 		}
 
-		if err := store.SetMessage(message); err != nil {
-			fmt.Printf("Error setting message: %v\n", err)
+		store, err := loadMessageStore(cmd.Context(), cfg)
+		if err != nil {
+			fmt.Fprintf(out, "Error loading message store: %v\n", err)
 			return
 		}
 
-		fmt.Printf("Message set to: %s\n", message)
+		if err := store.SetKey(cmd.Context(), setMessageKey, message, storage.UpdatedByCLI); err != nil {
+			fmt.Fprintf(out, "Error setting message: %v\n", err)
+			return
+		}
+		logger.WithFields(logrus.Fields{
+			"key":    setMessageKey,
+			"length": len(message),
+		}).Debug("Message set from the CLI")
+
+		fmt.Fprintf(out, "Message set (%d bytes, %d characters)\n", len(message), utf8.RuneCountInString(message))
 	},
 }
 
+// restoreMessage handles set message --undo and --redo.
+func restoreMessage(cmd *cobra.Command, args []string) {
+	out := cmd.OutOrStdout()
+
+	if setMessageUndo && setMessageRedo {
+		fmt.Fprintln(out, "Error: --undo cannot be combined with --redo")
+		return
+	}
+	if len(args) > 0 || setMessageFile != "" {
+		fmt.Fprintln(out, "Error: --undo and --redo cannot be combined with a message")
+		return
+	}
+
+	cfg, logger, err := loadConfigAndLogger(cmd)
+	if err != nil {
+		fmt.Fprintf(out, "Error loading config: %v\n", err)
+		return
+	}
+
+	store, err := loadMessageStore(cmd.Context(), cfg)
+	if err != nil {
+		fmt.Fprintf(out, "Error loading message store: %v\n", err)
+		return
+	}
+
+	restore, event := store.UndoKey, "Message undone"
+	if setMessageRedo {
+		restore, event = store.RedoKey, "Message redone"
+	}
+	data, err := restore(cmd.Context(), setMessageKey, storage.UpdatedByCLI)
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return
+	}
+	logger.WithFields(logrus.Fields{
+		"key":        setMessageKey,
+		"revision":   data.Revision,
+		"updated_by": data.UpdatedBy,
+	}).Debug(event + " from the CLI")
+
+	fmt.Fprintf(out, "Message restored (revision %d): %s\n", data.Revision, data.Message)
+}
+
+// readMessageInput returns the message from --file, from stdin ("-" or
+// piped input without arguments), or from the joined arguments.
+func readMessageInput(args []string, file string, stdin io.Reader) (string, error) {
+	var data []byte
+	var err error
+
+	switch {
+	case file != "":
+		if len(args) > 0 {
+			return "", fmt.Errorf("--file cannot be combined with message arguments")
+		}
+		data, err = os.ReadFile(file)
+	case len(args) == 1 && args[0] == "-":
+		data, err = io.ReadAll(stdin)
+	case len(args) == 0:
+		if !isPiped(stdin) {
+			return "", fmt.Errorf("no message given: pass it as arguments, with --file, or on stdin")
+		}
+		data, err = io.ReadAll(stdin)
+	default:
+		return strings.Join(args, " "), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read message: %w", err)
+	}
+
+	message := string(data)
+	if trimmed, ok := strings.CutSuffix(message, "\n"); ok {
+		message = strings.TrimSuffix(trimmed, "\r")
+	}
+	return message, nil
+}
+
+// isPiped reports whether r is something other than an interactive terminal.
+func isPiped(r io.Reader) bool {
+	file, ok := r.(*os.File)
+	if !ok {
+		return true
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
+}
+
 func init() {
+	setMessageCmd.Flags().StringVar(&setMessageFile, "file", "", "read the message from a file")
+	setMessageCmd.Flags().StringVar(&setMessageKey, "key", storage.DefaultKey, "name of the message to set")
+	setMessageCmd.Flags().BoolVar(&setMessageUndo, "undo", false, "restore the message before the last change")
+	setMessageCmd.Flags().BoolVar(&setMessageRedo, "redo", false, "restore the message undone last")
+	setMessageCmd.Flags().BoolVar(&setMessageForce, "force", false, "store the message even if it contains banned words")
 	setCmd.AddCommand(setMessageCmd)
 	rootCmd.AddCommand(setCmd)
 }
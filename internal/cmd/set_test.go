@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+// runSetMessage runs "greetd set message" with args and stdin and returns
+// its output and the stored message.
+func runSetMessage(t *testing.T, stdin io.Reader, args ...string) (string, string) {
+	t.Helper()
+
+	configPath, dataPath := writeTestConfig(t)
+
+	var out bytes.Buffer
+	rootCmd.SetIn(stdin)
+	rootCmd.SetOut(&out)
+	t.Cleanup(func() {
+		rootCmd.SetIn(nil)
+		rootCmd.SetOut(nil)
+		setMessageFile = ""
+		setMessageKey = storage.DefaultKey
+		setMessageForce = false
+	})
+
+	rootCmd.SetArgs(append([]string{"set", "message", "--config", configPath, "--log-output", "stdout"}, args...))
+	require.NoError(t, Execute())
+
+	store := storage.NewMessageStore(dataPath)
+	require.NoError(t, store.Load(context.Background()))
+	return out.String(), store.GetMessage(context.Background())
+}
+
+func TestSetMessageFromStdin(t *testing.T) {
+	input := "line one\n  line two\n\n"
+
+	for _, args := range [][]string{{"-"}, nil} {
+		out, stored := runSetMessage(t, strings.NewReader(input), args...)
+		assert.Equal(t, "line one\n  line two\n", stored, "args %v", args)
+		assert.Contains(t, out, "Message set (20 bytes, 20 characters)")
+	}
+}
+
+func TestSetMessageFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "message.txt")
+	require.NoError(t, os.WriteFile(path, []byte("Grüße 👋\r\n"), 0644))
+
+	out, stored := runSetMessage(t, strings.NewReader(""), "--file", path)
+	assert.Equal(t, "Grüße 👋", stored)
+	assert.Contains(t, out, "Message set (12 bytes, 7 characters)")
+}
+
+func TestSetMessageFileTooLong(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "message.txt")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("a", 2000)), 0644))
+
+	out, stored := runSetMessage(t, strings.NewReader(""), "--file", path)
+	assert.Contains(t, out, "Error:")
+	assert.Contains(t, out, "1024")
+	assert.Equal(t, "Hello, World!", stored)
+}
+
+func TestSetMessageNormalized(t *testing.T) {
+	out, stored := runSetMessage(t, strings.NewReader(""), "\ufeffCafe\u0301")
+	assert.Equal(t, "Caf\u00e9", stored)
+	assert.Contains(t, out, "Message set (5 bytes, 4 characters)")
+
+	out, stored = runSetMessage(t, strings.NewReader(""), "abc\u202edcba")
+	assert.Contains(t, out, "Error: message contains bidirectional control characters")
+	assert.Equal(t, "Hello, World!", stored)
+}
+
+func TestSetMessageBannedWords(t *testing.T) {
+	configPath, dataPath := writeTestConfig(t)
+	cfg, err := config.Load(configPath, "", nil)
+	require.NoError(t, err)
+	cfg.Message.BannedWordsFile = filepath.Join(t.TempDir(), "banned.txt")
+	require.NoError(t, os.WriteFile(cfg.Message.BannedWordsFile, []byte("darn\n"), 0644))
+	require.NoError(t, cfg.Save(configPath))
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		setMessageForce = false
+	})
+	set := func(args ...string) string {
+		out.Reset()
+		rootCmd.SetArgs(append([]string{"set", "message", "--config", configPath, "--log-output", "stdout"}, args...))
+		require.NoError(t, Execute())
+		store := storage.NewMessageStore(dataPath)
+		require.NoError(t, store.Load(context.Background()))
+		return store.GetMessage(context.Background())
+	}
+
+	assert.Equal(t, "Hello, World!", set("Oh darn"))
+	assert.Contains(t, out.String(), "Error: message contains a word that is not allowed")
+
+	assert.Equal(t, "Oh darn", set("Oh darn", "--force"))
+	assert.Contains(t, out.String(), "Message set")
+}
+
+func TestSetAndGetMessageKey(t *testing.T) {
+	configPath, dataPath := writeTestConfig(t)
+
+	run := func(args ...string) string {
+		var out bytes.Buffer
+		rootCmd.SetIn(strings.NewReader(""))
+		rootCmd.SetOut(&out)
+		rootCmd.SetArgs(append(args, "--config", configPath, "--log-output", "stdout"))
+		require.NoError(t, Execute())
+		return out.String()
+	}
+	t.Cleanup(func() {
+		rootCmd.SetIn(nil)
+		rootCmd.SetOut(nil)
+		setMessageKey = storage.DefaultKey
+		getMessageKey = storage.DefaultKey
+		setMessageUndo, setMessageRedo = false, false
+	})
+
+	assert.Contains(t, run("set", "message", "--key", "motd", "Welcome back"), "Message set")
+	assert.Equal(t, "Welcome back\n", run("get", "message", "--key", "motd"))
+	assert.Equal(t, "Hello, World!\n", run("get", "message", "--key", storage.DefaultKey))
+
+	assert.Contains(t, run("get", "message", "--key", "footer"), "Error: footer: message not found")
+	assert.Contains(t, run("set", "message", "--key", "Bad Key", "hi"), "Error: key must be")
+
+	store := storage.NewMessageStore(dataPath)
+	require.NoError(t, store.Load(context.Background()))
+	data, err := store.GetKey(context.Background(), "motd")
+	require.NoError(t, err)
+	assert.Equal(t, storage.UpdatedByCLI, data.UpdatedBy)
+}
+
+func TestSetMessageUndo(t *testing.T) {
+	configPath, _ := writeTestConfig(t)
+
+	run := func(args ...string) string {
+		var out bytes.Buffer
+		rootCmd.SetIn(strings.NewReader(""))
+		rootCmd.SetOut(&out)
+		rootCmd.SetArgs(append(args, "--config", configPath, "--log-output", "stdout"))
+		require.NoError(t, Execute())
+		setMessageUndo, setMessageRedo = false, false
+		return out.String()
+	}
+	t.Cleanup(func() {
+		rootCmd.SetIn(nil)
+		rootCmd.SetOut(nil)
+	})
+
+	assert.Contains(t, run("set", "message", "--undo"), "Error: nothing to undo")
+
+	run("set", "message", "First")
+	run("set", "message", "Second")
+	assert.Contains(t, run("set", "message", "--undo"), "Message restored (revision 3): First")
+	assert.Contains(t, run("set", "message", "--redo"), "Message restored (revision 4): Second")
+	assert.Contains(t, run("set", "message", "--redo"), "Error: nothing to redo")
+	assert.Contains(t, run("set", "message", "--undo", "text"), "cannot be combined with a message")
+	assert.Equal(t, "Second\n", run("get", "message"))
+}
+
+func TestReadMessageInput(t *testing.T) {
+	message, err := readMessageInput([]string{"hello", "there"}, "", strings.NewReader("ignored"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello there", message)
+
+	_, err = readMessageInput([]string{"hello"}, "message.txt", nil)
+	assert.Error(t, err)
+
+	_, err = readMessageInput(nil, filepath.Join(t.TempDir(), "missing.txt"), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package cmd
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/svanhalla/prompt-lab/greetd/internal/api"
+)
+
+// handleSignals does nothing where there is no SIGUSR1 or SIGUSR2, such as
+// on Windows.
+func handleSignals(logger *logrus.Logger, server *api.Server) func() {
+	return func() {}
+}
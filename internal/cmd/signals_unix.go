@@ -0,0 +1,54 @@
+//go:build unix
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+	"github.com/svanhalla/prompt-lab/greetd/internal/api"
+)
+
+// handleSignals rotates the log files on SIGUSR1 and writes a state dump
+// on SIGUSR2 until the returned function is called.
+func handleSignals(logger *logrus.Logger, server *api.Server) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				handleSignal(logger, server, sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+func handleSignal(logger *logrus.Logger, server *api.Server, sig os.Signal) {
+	switch sig {
+	case syscall.SIGUSR1:
+		if err := server.RotateLogs(); err != nil {
+			logger.WithError(err).Warn("Failed to rotate the log files on SIGUSR1")
+			return
+		}
+		logger.Info("Rotated the log files on SIGUSR1")
+	case syscall.SIGUSR2:
+		path, err := server.WriteStateDump()
+		if err != nil {
+			logger.WithError(err).Warn("Failed to write a state dump on SIGUSR2")
+			return
+		}
+		logger.WithField("file", path).Info("Wrote a state dump on SIGUSR2")
+	}
+}
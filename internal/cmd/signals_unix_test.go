@@ -0,0 +1,65 @@
+//go:build unix
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/api"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/logging"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+func TestHandleSignals(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DataPath = t.TempDir()
+	cfg.Logging.AccessLog.Compress = false
+	logger, err := logging.Setup("info", "text", logging.OutputFile, cfg.StateDir())
+	require.NoError(t, err)
+
+	store := storage.NewMessageStore(cfg.StateDir())
+	require.NoError(t, store.Load(context.Background()))
+	require.NoError(t, store.SetMessage(context.Background(), "Hello", storage.UpdatedByCLI))
+	server, err := api.NewServer(cfg, store, logger)
+	require.NoError(t, err)
+	t.Cleanup(func() { server.Shutdown(context.Background()) })
+
+	stop := handleSignals(logger, server)
+	defer stop()
+	logger.Info("Before rotation")
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	assert.Eventually(t, func() bool {
+		// The old app.log is compressed in the background.
+		backups, _ := filepath.Glob(filepath.Join(cfg.StateDir(), "app-*.log.gz"))
+		data, _ := os.ReadFile(filepath.Join(cfg.StateDir(), logging.FileName))
+		return len(backups) == 1 && strings.Contains(string(data), "Rotated the log files on SIGUSR1") && !strings.Contains(string(data), "Before rotation")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR2))
+	var dumps []string
+	assert.Eventually(t, func() bool {
+		dumps, _ = filepath.Glob(filepath.Join(cfg.StateDir(), "state-*.txt"))
+		return len(dumps) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, dumps, 1)
+
+	data, err := os.ReadFile(dumps[0])
+	require.NoError(t, err)
+	for _, want := range []string{"== configuration ==", "state_path: " + cfg.StateDir(), "default: revision 1", "greetd_http_requests_total", "goroutine "} {
+		assert.Contains(t, string(data), want)
+	}
+	assert.Eventually(t, func() bool {
+		data, _ := os.ReadFile(filepath.Join(cfg.StateDir(), logging.FileName))
+		return strings.Contains(string(data), "Wrote a state dump on SIGUSR2")
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+)
+
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Manage how messages.json is stored",
+}
+
+var storageEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt messages.json with the configured key",
+	Long: `Rewrites messages.json encrypted with the first key of
+storage.encryption.key_file, or with the GREETD_STORAGE_KEY passphrase. A
+file encrypted with another configured key is re-encrypted, which completes a
+key rotation.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runStorageCommand(cmd, true)
+	},
+}
+
+var storageDecryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Store messages.json in plaintext again",
+	Long: `Rewrites messages.json in plaintext, reading it with the configured keys.
+Remove storage.encryption.key_file and GREETD_STORAGE_KEY from the server's
+configuration first, or it encrypts the file again on the next write.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runStorageCommand(cmd, false)
+	},
+}
+
+func runStorageCommand(cmd *cobra.Command, encrypt bool) {
+	out := cmd.OutOrStdout()
+
+	cfg, err := config.Load(cfgFile, dataPathFlag, configFlags(cmd))
+	if err != nil {
+		fmt.Fprintf(out, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := rewriteMessages(cmd.Context(), out, cfg, encrypt); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// rewriteMessages rewrites messages.json in place, encrypted with the
+// configured keys or in plaintext.
+func rewriteMessages(ctx context.Context, out io.Writer, cfg *config.Config, encrypt bool) error {
+	keys, err := loadKeyring(cfg)
+	if err != nil {
+		return err
+	}
+	if keys == nil {
+		return errors.New("no encryption key configured; set storage.encryption.key_file or " + config.StorageKeyEnv)
+	}
+
+	store, err := loadMessageStore(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load message store: %w", err)
+	}
+	if !encrypt {
+		keys = nil
+	}
+	if err := store.Rewrite(ctx, keys); err != nil {
+		return err
+	}
+
+	if store.Len() == 0 {
+		fmt.Fprintln(out, "No messages stored yet; nothing to rewrite")
+		return nil
+	}
+	state := "encrypted"
+	if !encrypt {
+		state = "decrypted"
+	}
+	fmt.Fprintf(out, "Messages %s in %s\n", state, cfg.StateDir())
+	return nil
+}
+
+func init() {
+	storageCmd.AddCommand(storageEncryptCmd)
+	storageCmd.AddCommand(storageDecryptCmd)
+	rootCmd.AddCommand(storageCmd)
+}
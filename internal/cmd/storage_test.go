@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+func TestStorageEncryptDecrypt(t *testing.T) {
+	configPath, dataPath := writeTestConfig(t)
+	messagesPath := filepath.Join(dataPath, storage.MessagesFileName)
+
+	cfg, err := config.Load(configPath, "", nil)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	assert.ErrorContains(t, rewriteMessages(context.Background(), &out, cfg, true), "no encryption key configured")
+
+	store := storage.NewMessageStore(dataPath)
+	require.NoError(t, store.Load(context.Background()))
+	require.NoError(t, store.SetMessage(context.Background(), "Door code 1234", storage.UpdatedByCLI))
+
+	keyFile := filepath.Join(t.TempDir(), "greetd.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(hex.EncodeToString(bytes.Repeat([]byte{1}, storage.KeySize))+"\n"), 0600))
+	cfg.Storage.Encryption.KeyFile = keyFile
+
+	require.NoError(t, rewriteMessages(context.Background(), &out, cfg, true))
+	assert.Contains(t, out.String(), "Messages encrypted")
+	data, err := os.ReadFile(messagesPath)
+	require.NoError(t, err)
+	assert.True(t, storage.IsEncrypted(data))
+
+	loaded, err := loadMessageStore(context.Background(), cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "Door code 1234", loaded.GetMessage(context.Background()))
+
+	// Backups keep the file encrypted, and restore it as is.
+	archive := filepath.Join(t.TempDir(), "greetd.tar.gz")
+	require.NoError(t, createBackup(context.Background(), &out, cfg, archive, false))
+	restoreCfg := config.DefaultConfig()
+	restoreCfg.DataPath = t.TempDir()
+	require.NoError(t, restoreBackup(&out, restoreCfg, archive, false))
+	data, err = os.ReadFile(filepath.Join(restoreCfg.DataPath, storage.MessagesFileName))
+	require.NoError(t, err)
+	assert.True(t, storage.IsEncrypted(data))
+	assert.NotContains(t, string(data), "Door code")
+
+	out.Reset()
+	require.NoError(t, rewriteMessages(context.Background(), &out, cfg, false))
+	assert.Contains(t, out.String(), "Messages decrypted")
+	data, err = os.ReadFile(messagesPath)
+	require.NoError(t, err)
+	assert.False(t, storage.IsEncrypted(data))
+	assert.Contains(t, string(data), "Door code 1234")
+}
@@ -1,21 +1,113 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/update"
 	"github.com/svanhalla/prompt-lab/greetd/internal/version"
 )
 
+var (
+	versionOutput      string
+	versionCheck       bool
+	versionCheckStrict bool
+)
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print version information",
+	Long: `Prints version information. --check also asks the GitHub releases API
+whether a newer release exists and prints it with its URL; nothing is
+downloaded. The answer is cached in the state directory for a day, and the
+request goes through the proxy configured under outbound. When the check
+fails the update status is "unknown" and the exit code is still 0, unless
+--check-strict is given.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if versionOutput != "text" && versionOutput != "json" {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Error: unknown output format %q (use text or json)\n", versionOutput)
+			os.Exit(1)
+		}
+
 		info := version.Get()
-		fmt.Println(info.String())
+		var result *update.Result
+		if versionCheck || versionCheckStrict {
+			checked := checkForUpdate(cmd, info.Version)
+			result = &checked
+		}
+
+		if err := printVersion(cmd.OutOrStdout(), info, result, versionOutput); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if versionCheckStrict && result.Status == update.StatusUnknown {
+			os.Exit(1)
+		}
 	},
 }
 
+// checkForUpdate compares current with the latest release. Any failure
+// leaves the status unknown, with the reason in the result.
+func checkForUpdate(cmd *cobra.Command, current string) update.Result {
+	unknown := func(err error) update.Result {
+		return update.Result{Status: update.StatusUnknown, Current: current, Error: err.Error()}
+	}
+
+	cfg, err := config.Load(cfgFile, dataPathFlag, nil)
+	if err != nil {
+		return unknown(fmt.Errorf("failed to load config: %w", err))
+	}
+	httpClient, err := outboundHTTPClient(cmd, cfg)
+	if err != nil {
+		return unknown(err)
+	}
+
+	checker := &update.Checker{
+		Client:   httpClient,
+		URL:      cfg.Update.ReleasesURL,
+		Current:  current,
+		CacheDir: cfg.StateDir(),
+	}
+	result, _ := checker.Check(cmd.Context())
+	return result
+}
+
+// printVersion writes info, and the update status when there is one, as
+// text or JSON.
+func printVersion(w io.Writer, info version.Info, result *update.Result, output string) error {
+	if output == "json" {
+		data, err := json.MarshalIndent(struct {
+			version.Info
+			Update *update.Result `json:"update,omitempty"`
+		}{info, result}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal version info: %w", err)
+		}
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+
+	fmt.Fprintln(w, info.String())
+	switch {
+	case result == nil:
+	case result.Status == update.StatusAvailable:
+		fmt.Fprintf(w, "Update available: %s (%s)\n", result.Latest, result.URL)
+	case result.Status == update.StatusUpToDate:
+		fmt.Fprintf(w, "Up to date: %s is the latest release\n", result.Latest)
+	default:
+		fmt.Fprintf(w, "Update status unknown: %s\n", result.Error)
+	}
+	return nil
+}
+
 func init() {
+	versionCmd.Flags().StringVarP(&versionOutput, "output", "o", "text", "output format (text, json)")
+	versionCmd.Flags().BoolVar(&versionCheck, "check", false, "check for a newer release")
+	versionCmd.Flags().BoolVar(&versionCheckStrict, "check-strict", false, "check for a newer release and exit with 1 when the check fails")
+	versionCmd.RegisterFlagCompletionFunc("output", completeValues("text", "json"))
 	rootCmd.AddCommand(versionCmd)
 }
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/config"
+	"github.com/svanhalla/prompt-lab/greetd/internal/update"
+	"github.com/svanhalla/prompt-lab/greetd/internal/version"
+)
+
+func TestVersionCheck(t *testing.T) {
+	releases := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"tag_name": "v1.3.0",
+			"html_url": "https://github.com/svanhalla/prompt-lab/releases/tag/v1.3.0",
+		})
+	}))
+	defer releases.Close()
+
+	configPath, dataPath := writeTestConfig(t)
+	cfg, err := config.Load(configPath, "", nil)
+	require.NoError(t, err)
+	cfg.Update.ReleasesURL = releases.URL
+	require.NoError(t, cfg.Save(configPath))
+
+	current := version.Version
+	version.Version = "v1.2.0"
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	t.Cleanup(func() {
+		version.Version = current
+		rootCmd.SetOut(nil)
+		versionOutput = "text"
+		versionCheck = false
+	})
+
+	rootCmd.SetArgs([]string{"version", "--check", "--config", configPath})
+	require.NoError(t, Execute())
+	assert.Contains(t, out.String(), "greetd v1.2.0")
+	assert.Contains(t, out.String(), "Update available: v1.3.0 (https://github.com/svanhalla/prompt-lab/releases/tag/v1.3.0)")
+	assert.FileExists(t, filepath.Join(dataPath, update.CacheFileName))
+
+	// The release is cached, so the check works without the API.
+	releases.Close()
+	out.Reset()
+	rootCmd.SetArgs([]string{"version", "--check", "--output", "json", "--config", configPath})
+	require.NoError(t, Execute())
+	var shown struct {
+		Version string        `json:"version"`
+		Update  update.Result `json:"update"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &shown))
+	assert.Equal(t, "v1.2.0", shown.Version)
+	assert.Equal(t, update.StatusAvailable, shown.Update.Status)
+	assert.Equal(t, "v1.3.0", shown.Update.Latest)
+
+	// Without it, and without a cache, the status is unknown and the
+	// command still succeeds.
+	require.NoError(t, os.Remove(filepath.Join(dataPath, update.CacheFileName)))
+	out.Reset()
+	rootCmd.SetArgs([]string{"version", "--check", "--output", "text", "--config", configPath})
+	require.NoError(t, Execute())
+	assert.Contains(t, out.String(), "Update status unknown: request failed")
+}
@@ -3,86 +3,995 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/labstack/gommon/bytes"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"github.com/svanhalla/prompt-lab/greetd/internal/greeting"
+	"github.com/svanhalla/prompt-lab/greetd/internal/httpclient"
+	"github.com/svanhalla/prompt-lab/greetd/internal/logging"
+	"github.com/svanhalla/prompt-lab/greetd/internal/update"
+	"github.com/svanhalla/prompt-lab/greetd/internal/validate"
+	"golang.org/x/net/http/httpguts"
 )
 
 type Config struct {
-	Server   ServerConfig `json:"server" mapstructure:"server"`
-	Logging  LogConfig    `json:"logging" mapstructure:"logging"`
-	DataPath string       `json:"data_path" mapstructure:"data_path"`
+	Server    ServerConfig    `json:"server" mapstructure:"server"`
+	Logging   LogConfig       `json:"logging" mapstructure:"logging"`
+	Security  SecurityConfig  `json:"security" mapstructure:"security"`
+	Docs      DocsConfig      `json:"docs" mapstructure:"docs"`
+	Greetings GreetingsConfig `json:"greetings" mapstructure:"greetings"`
+	Message   MessageConfig   `json:"message" mapstructure:"message"`
+	UI        UIConfig        `json:"ui" mapstructure:"ui"`
+	Health    HealthConfig    `json:"health" mapstructure:"health"`
+	Storage   StorageConfig   `json:"storage" mapstructure:"storage"`
+	Outbound  OutboundConfig  `json:"outbound" mapstructure:"outbound"`
+	Update    UpdateConfig    `json:"update" mapstructure:"update"`
+	CORS      CORSConfig      `json:"cors" mapstructure:"cors"`
+	// DataPath holds config.json, and the state as well unless StatePath
+	// is set.
+	DataPath string `json:"data_path" mapstructure:"data_path"`
+	// StatePath holds messages.json and the log files. Empty uses DataPath,
+	// or the XDG state directory when DataPath is the default.
+	StatePath string `json:"state_path" mapstructure:"state_path"`
+
+	// File is the config file Load read, or "" when the defaults applied.
+	File string `json:"-" mapstructure:"-"`
 }
 
 type ServerConfig struct {
 	Host string `json:"host" mapstructure:"host"`
 	Port int    `json:"port" mapstructure:"port"`
+	// PortRetry is how many of the following ports are tried in turn when
+	// Port is in use, for running several instances in development.
+	PortRetry int `json:"port_retry" mapstructure:"port_retry"`
+	// TrustedProxies lists CIDRs (or single IPs) whose X-Forwarded-For and
+	// X-Real-IP headers are honored when determining the client IP.
+	TrustedProxies []string `json:"trusted_proxies" mapstructure:"trusted_proxies"`
+	// LegacyRoutes keeps the unversioned JSON endpoints (/health, /hello,
+	// /message) as deprecated aliases of their /api/v1 equivalents.
+	LegacyRoutes bool `json:"legacy_routes" mapstructure:"legacy_routes"`
+	// BasePath mounts every route under a prefix such as "/greetd" when
+	// greetd is served from a sub-path behind a reverse proxy.
+	BasePath string `json:"base_path" mapstructure:"base_path"`
+	// ReadTimeout, WriteTimeout and IdleTimeout bound connections, e.g.
+	// "15s"; "0" disables one. RequestTimeout bounds each handler, except
+	// for streaming routes.
+	ReadTimeout    string `json:"read_timeout" mapstructure:"read_timeout"`
+	WriteTimeout   string `json:"write_timeout" mapstructure:"write_timeout"`
+	IdleTimeout    string `json:"idle_timeout" mapstructure:"idle_timeout"`
+	RequestTimeout string `json:"request_timeout" mapstructure:"request_timeout"`
+	// EnablePprof mounts the net/http/pprof handlers under /debug/pprof/,
+	// behind the admin API keys.
+	EnablePprof bool `json:"enable_pprof" mapstructure:"enable_pprof"`
+	// AllowedHosts lists the host names requests may be addressed to, such
+	// as "greetd.example.com" or "*.example.com" for its subdomains; other
+	// hosts are answered with 421. Empty allows every host.
+	AllowedHosts []string `json:"allowed_hosts" mapstructure:"allowed_hosts"`
+	// RedirectHTTPS redirects requests that did not arrive over HTTPS,
+	// directly or as reported by a trusted proxy, to their https:// URL.
+	RedirectHTTPS bool `json:"redirect_https" mapstructure:"redirect_https"`
+	// HTTPSPort is the port of the URLs RedirectHTTPS redirects to; 0
+	// leaves it out, for the default port 443.
+	HTTPSPort int `json:"https_port" mapstructure:"https_port"`
+	// BodyLimit caps every request body, e.g. "1MB", except on routes
+	// with a limit of their own such as message.import_limit.
+	BodyLimit string `json:"body_limit" mapstructure:"body_limit"`
+	// RouteConflicts is what happens at startup when two routes overlap:
+	// "fail" refuses to start, "warn" logs them and starts anyway.
+	RouteConflicts string `json:"route_conflicts" mapstructure:"route_conflicts"`
+	// ServerHeader is the Server response header. Empty sends
+	// "greetd/<version>"; ServerHeaderOff sends none.
+	ServerHeader string `json:"server_header" mapstructure:"server_header"`
+	// ExposeVersion adds an X-Greetd-Version response header with the
+	// running version.
+	ExposeVersion bool `json:"expose_version" mapstructure:"expose_version"`
+	// Debug puts the error and the top of its stack into 500 responses,
+	// and the parse error into pages that fail to render. Off, they only
+	// carry the request ID and the details are logged.
+	Debug bool `json:"debug" mapstructure:"debug"`
+	// Internal is a second listener, such as one on localhost for a
+	// sidecar, that serves only the health, metrics, profiling and admin
+	// routes.
+	Internal InternalListenerConfig `json:"internal" mapstructure:"internal"`
+}
+
+// InternalListenerConfig is the address of the internal listener.
+type InternalListenerConfig struct {
+	Enabled bool   `json:"enabled" mapstructure:"enabled"`
+	Host    string `json:"host" mapstructure:"host"`
+	// Port 0 picks a free port.
+	Port int `json:"port" mapstructure:"port"`
+}
+
+// ServerHeaderOff as server.server_header leaves the Server header out.
+const ServerHeaderOff = "off"
+
+// What to do about overlapping routes.
+const (
+	RouteConflictsFail = "fail"
+	RouteConflictsWarn = "warn"
+)
+
+// Timeouts holds the parsed server timeouts; zero means no timeout.
+type Timeouts struct {
+	Read    time.Duration
+	Write   time.Duration
+	Idle    time.Duration
+	Request time.Duration
+}
+
+// Timeouts returns the parsed timeouts. Values are checked by Validate, so
+// malformed ones are treated as zero here.
+func (s ServerConfig) Timeouts() Timeouts {
+	parse := func(value string) time.Duration {
+		d, _ := parseTimeout(value)
+		return d
+	}
+	return Timeouts{
+		Read:    parse(s.ReadTimeout),
+		Write:   parse(s.WriteTimeout),
+		Idle:    parse(s.IdleTimeout),
+		Request: parse(s.RequestTimeout),
+	}
+}
+
+// parseTimeout parses a duration such as "30s"; "" and "0" mean none.
+func parseTimeout(value string) (time.Duration, error) {
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err == nil && d < 0 {
+		err = fmt.Errorf("negative duration")
+	}
+	return d, err
 }
 
 type LogConfig struct {
 	Level  string `json:"level" mapstructure:"level"`
 	Format string `json:"format" mapstructure:"format"`
+	// Output is "stdout", "file" (app.log in the state directory) or "both".
+	Output string `json:"output" mapstructure:"output"`
+	// SkipPaths are glob patterns (e.g. "/static/*") for requests that are
+	// left out of the request log unless they fail.
+	SkipPaths []string `json:"skip_paths" mapstructure:"skip_paths"`
+	// SampleRate is the fraction (0-1) of successful requests that are logged.
+	SampleRate float64 `json:"sample_rate" mapstructure:"sample_rate"`
+	// BufferSize is the number of recent entries kept in memory for /logs.
+	BufferSize int `json:"buffer_size" mapstructure:"buffer_size"`
+	// DumpBodies logs the request and response bodies of the JSON API at
+	// debug level, with credentials redacted.
+	DumpBodies bool `json:"dump_bodies" mapstructure:"dump_bodies"`
+	// DumpBodyLimit is how much of each body DumpBodies logs, e.g. "4KB".
+	DumpBodyLimit string `json:"dump_body_limit" mapstructure:"dump_body_limit"`
+	// CrashDumps writes a crash-<timestamp>.txt report with the stacks of
+	// all goroutines to the state directory when a handler panics.
+	CrashDumps bool `json:"crash_dumps" mapstructure:"crash_dumps"`
+	// SlowRequestThreshold is how long a request may take, e.g. "1s",
+	// before its entry is logged at warn level; "0" turns it off.
+	SlowRequestThreshold string `json:"slow_request_threshold" mapstructure:"slow_request_threshold"`
+	// RequestFormat is how request entries in the application log are
+	// written: "json", "text" or "common" (Apache common log format).
+	// Empty follows Format.
+	RequestFormat string `json:"request_format" mapstructure:"request_format"`
+	// RedactParams names the query parameters, such as "name", whose
+	// values are logged as "[redacted]", matched regardless of case.
+	RedactParams []string `json:"redact_params" mapstructure:"redact_params"`
+	// AccessLog sends HTTP request entries to a separate file.
+	AccessLog AccessLogConfig `json:"access_log" mapstructure:"access_log"`
 }
 
-func DefaultConfig() *Config {
-	homeDir, _ := os.UserHomeDir()
-	dataPath := filepath.Join(homeDir, ".greetd")
+// SlowRequestDuration returns the parsed SlowRequestThreshold; zero turns
+// it off. The value is checked by Validate, so a malformed one is treated
+// as zero here.
+func (l LogConfig) SlowRequestDuration() time.Duration {
+	d, _ := parseTimeout(l.SlowRequestThreshold)
+	return d
+}
+
+// AccessLogConfig controls the HTTP access log.
+type AccessLogConfig struct {
+	// File is the access log path, relative to the state directory unless
+	// absolute.
+	// Empty writes request entries to the application log instead.
+	File string `json:"file" mapstructure:"file"`
+	// Format is "combined", "common" or "json".
+	Format     string `json:"format" mapstructure:"format"`
+	MaxSize    int    `json:"max_size" mapstructure:"max_size"` // MB
+	MaxBackups int    `json:"max_backups" mapstructure:"max_backups"`
+	MaxAge     int    `json:"max_age" mapstructure:"max_age"` // days
+	Compress   bool   `json:"compress" mapstructure:"compress"`
+}
+
+// SecurityConfig controls access to the administrative endpoints.
+type SecurityConfig struct {
+	// APIKeys are accepted via the X-API-Key header or as a bearer token.
+	APIKeys []string `json:"api_keys" mapstructure:"api_keys"`
+	// AllowOpenAdmin permits admin routes without a key when no keys are configured.
+	AllowOpenAdmin bool `json:"allow_open_admin" mapstructure:"allow_open_admin"`
+	// AllowCIDRs lists CIDRs (or single IPs) of the clients allowed to use
+	// the admin routes, change messages and read the logs. Empty allows
+	// everyone.
+	AllowCIDRs []string `json:"allow_cidrs" mapstructure:"allow_cidrs"`
+}
+
+// DocsConfig controls how the Swagger UI and Redoc pages load their assets.
+type DocsConfig struct {
+	// UseCDN loads the documentation bundles from public CDNs instead of the binary.
+	UseCDN bool `json:"use_cdn" mapstructure:"use_cdn"`
+}
+
+// GreetingsConfig controls how greetings are produced.
+type GreetingsConfig struct {
+	// CatalogPath points at a YAML file that overrides or adds languages to
+	// the built-in greeting catalog.
+	CatalogPath string `json:"catalog_path" mapstructure:"catalog_path"`
+	// Template is a text/template for the greeting, e.g.
+	// "Welcome to {{.Service}}, {{.Name}}!". Empty uses the catalog.
+	Template string `json:"template" mapstructure:"template"`
+	// UseStoredTemplate renders the stored message as the /hello greeting,
+	// e.g. "Welcome back, {{.Name}}", falling back to the greeting above
+	// while it is not a valid message template.
+	UseStoredTemplate bool `json:"use_stored_template" mapstructure:"use_stored_template"`
+	// CacheTTL is how long a rendered /hello response is reused, and the
+	// max-age of its Cache-Control header, e.g. "1m"; "0" turns the cache
+	// off.
+	CacheTTL string `json:"cache_ttl" mapstructure:"cache_ttl"`
+	// CacheSize caps the number of cached /hello responses; the least
+	// recently used makes room for a new one.
+	CacheSize int `json:"cache_size" mapstructure:"cache_size"`
+}
+
+// CacheDuration returns the parsed CacheTTL; zero turns the /hello cache
+// off. The value is checked by Validate, so a malformed one is treated as
+// zero here.
+func (g GreetingsConfig) CacheDuration() time.Duration {
+	d, _ := parseTimeout(g.CacheTTL)
+	return d
+}
+
+// MessageConfig holds the limits applied to stored messages.
+type MessageConfig struct {
+	// MaxLength is the maximum message length in runes.
+	MaxLength int `json:"max_length" mapstructure:"max_length"`
+	// BodyLimit caps the POST /message request body, e.g. "64KB".
+	BodyLimit string `json:"body_limit" mapstructure:"body_limit"`
+	// ImportLimit caps the POST /admin/import request body, e.g. "1MB".
+	ImportLimit string `json:"import_limit" mapstructure:"import_limit"`
+	// MaxKeys caps the number of named messages.
+	MaxKeys int `json:"max_keys" mapstructure:"max_keys"`
+	// IdempotencyWindow is how long the response to a POST /message with an
+	// Idempotency-Key header is replayed for the same key, e.g. "24h"; "0"
+	// ignores the header.
+	IdempotencyWindow string `json:"idempotency_window" mapstructure:"idempotency_window"`
+	// ForbiddenPatterns are regular expressions messages must not match.
+	ForbiddenPatterns []string `json:"forbidden_patterns" mapstructure:"forbidden_patterns"`
+	// BannedWordsFile lists words messages must not contain, one per line.
+	// The server reads it again when it changes. Empty disables the filter.
+	BannedWordsFile string `json:"banned_words_file" mapstructure:"banned_words_file"`
+	// FilterMode is what happens to a message containing a banned word:
+	// "reject" refuses it, "mask" replaces the word with asterisks.
+	FilterMode string `json:"filter_mode" mapstructure:"filter_mode"`
+}
+
+// Filter modes for banned words.
+const (
+	FilterReject = "reject"
+	FilterMask   = "mask"
+)
+
+// IdempotencyTTL returns the parsed IdempotencyWindow; zero turns
+// idempotency keys off. The value is checked by Validate, so a malformed
+// one is treated as zero here.
+func (m MessageConfig) IdempotencyTTL() time.Duration {
+	d, _ := parseTimeout(m.IdempotencyWindow)
+	return d
+}
+
+// Rules returns the validation rules for messages, without the banned
+// words, which validate.LoadWordFilter reads from BannedWordsFile. The
+// patterns are checked by Validate, so malformed ones are left out here.
+func (m MessageConfig) Rules() validate.Options {
+	rules := validate.Options{MaxLength: m.MaxLength, MaskWords: m.FilterMode == FilterMask}
+	for _, pattern := range m.ForbiddenPatterns {
+		if re, err := regexp.Compile(pattern); err == nil {
+			rules.Forbidden = append(rules.Forbidden, re)
+		}
+	}
+	return rules
+}
+
+// UIConfig controls the HTML message manager.
+type UIConfig struct {
+	// RenderMarkdown renders the message on /ui as sanitized markdown instead
+	// of escaped plain text. The JSON API always returns the raw message.
+	RenderMarkdown bool `json:"render_markdown" mapstructure:"render_markdown"`
+	// DevMode serves the page templates from TemplatesPath, re-read when
+	// they change, instead of the ones embedded in the binary.
+	DevMode bool `json:"dev_mode" mapstructure:"dev_mode"`
+	// TemplatesPath is the template directory used in dev mode, relative
+	// to the working directory unless absolute.
+	TemplatesPath string `json:"templates_path" mapstructure:"templates_path"`
+	// StrictTemplates refuses to start when a page template fails to load.
+	// Otherwise those pages show a fallback and /health reports them.
+	StrictTemplates bool `json:"strict_templates" mapstructure:"strict_templates"`
+	// LocalesPath is a directory of <lang>.yaml files that override or add
+	// to the built-in text of the HTML pages. Empty uses the built-in text.
+	LocalesPath string `json:"locales_path" mapstructure:"locales_path"`
+}
+
+// StorageConfig controls how the server keeps messages.json.
+type StorageConfig struct {
+	// Watch reloads messages.json as soon as another program changes it,
+	// such as an editor or configuration management.
+	Watch bool `json:"watch" mapstructure:"watch"`
+	// Encryption encrypts messages.json at rest.
+	Encryption EncryptionConfig `json:"encryption" mapstructure:"encryption"`
+}
+
+// EncryptionConfig names the keys messages.json is encrypted with. Without
+// a key file or StorageKeyEnv it is stored in plaintext.
+type EncryptionConfig struct {
+	// KeyFile holds 32-byte keys, one per line in hex or base64, or a
+	// single raw key. The first encrypts; all of them decrypt.
+	KeyFile string `json:"key_file" mapstructure:"key_file"`
+}
+
+// OutboundConfig controls the HTTP calls greetd makes, such as the client
+// commands talking to a running server.
+type OutboundConfig struct {
+	// ProxyURL is the proxy to go through, e.g.
+	// "http://proxy.example.com:3128", except for hosts in NO_PROXY and
+	// loopback addresses. Empty uses HTTP_PROXY and HTTPS_PROXY.
+	ProxyURL string `json:"proxy_url" mapstructure:"proxy_url"`
+	// Timeout bounds each request, e.g. "10s"; "0" disables it.
+	Timeout string `json:"timeout" mapstructure:"timeout"`
+	// TLSSkipVerify accepts any server certificate. Only for testing.
+	TLSSkipVerify bool `json:"tls_skip_verify" mapstructure:"tls_skip_verify"`
+	// CAFile is a PEM bundle of certificates to trust in addition to the
+	// system ones, such as a corporate root CA.
+	CAFile string `json:"ca_file" mapstructure:"ca_file"`
+}
+
+// Options returns the options of the outbound HTTP clients. The timeout
+// is checked by Validate, so a malformed one is treated as zero here.
+func (o OutboundConfig) Options() httpclient.Options {
+	timeout, _ := parseTimeout(o.Timeout)
+	return httpclient.Options{
+		ProxyURL:      o.ProxyURL,
+		Timeout:       timeout,
+		TLSSkipVerify: o.TLSSkipVerify,
+		CAFile:        o.CAFile,
+	}
+}
+
+// UpdateConfig controls the check for new releases.
+type UpdateConfig struct {
+	// Check makes greetd api look for a newer release once a day and
+	// show it on /status. greetd version --check works regardless.
+	Check bool `json:"check" mapstructure:"check"`
+	// ReleasesURL is the API endpoint of the latest release, such as a
+	// mirror of the GitHub releases API.
+	ReleasesURL string `json:"releases_url" mapstructure:"releases_url"`
+}
 
+// CORSConfig is the cross-origin policy of the JSON API. The admin and
+// profiling routes only allow AdminAllowedOrigins, and the HTML pages no
+// other origin at all.
+type CORSConfig struct {
+	// AllowedOrigins are the origins, such as "https://app.example.com" or
+	// "https://*.example.com", whose pages may call the API; "*" allows
+	// any. Empty allows none: same-origin only.
+	AllowedOrigins []string `json:"allowed_origins" mapstructure:"allowed_origins"`
+	// AllowedMethods and AllowedHeaders are what cross-origin requests
+	// may use.
+	AllowedMethods []string `json:"allowed_methods" mapstructure:"allowed_methods"`
+	AllowedHeaders []string `json:"allowed_headers" mapstructure:"allowed_headers"`
+	// AllowCredentials lets cross-origin requests carry cookies and
+	// credentials. It cannot be combined with "*".
+	AllowCredentials bool `json:"allow_credentials" mapstructure:"allow_credentials"`
+	// MaxAge is how many seconds browsers may cache a preflight answer;
+	// 0 leaves it to the browser.
+	MaxAge int `json:"max_age" mapstructure:"max_age"`
+	// AdminAllowedOrigins are the origins allowed on the admin routes.
+	// Empty, the default, allows none.
+	AdminAllowedOrigins []string `json:"admin_allowed_origins" mapstructure:"admin_allowed_origins"`
+}
+
+// corsMethods are the methods cors.allowed_methods may list.
+var corsMethods = map[string]bool{"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true}
+
+func (c CORSConfig) validate() error {
+	for _, origins := range []struct {
+		key    string
+		values []string
+	}{
+		{"cors.allowed_origins", c.AllowedOrigins},
+		{"cors.admin_allowed_origins", c.AdminAllowedOrigins},
+	} {
+		for _, origin := range origins.values {
+			if origin == "*" {
+				if c.AllowCredentials {
+					// Any site could then act with the user's credentials.
+					return fmt.Errorf("%s: \"*\" cannot be combined with cors.allow_credentials", origins.key)
+				}
+				continue
+			}
+			u, err := url.Parse(origin)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+				return fmt.Errorf("%s: invalid origin %q", origins.key, origin)
+			}
+		}
+	}
+
+	for _, method := range c.AllowedMethods {
+		if !corsMethods[method] {
+			return fmt.Errorf("cors.allowed_methods: unknown method %q", method)
+		}
+	}
+
+	if c.MaxAge < 0 {
+		return fmt.Errorf("cors.max_age must not be negative, got %d", c.MaxAge)
+	}
+	return nil
+}
+
+// StorageKeyEnv names the environment variable holding a passphrase that
+// messages.json is encrypted with. It takes precedence over the keys in
+// storage.encryption.key_file, which still decrypt.
+const StorageKeyEnv = "GREETD_STORAGE_KEY"
+
+// DefaultTemplatesPath is the template directory in a source checkout.
+const DefaultTemplatesPath = "internal/web/templates"
+
+// HealthConfig controls the health check.
+type HealthConfig struct {
+	// MinFreeSpace is the free space, e.g. "100MB", below which /health
+	// reports "degraded" for the state directory's filesystem. "0"
+	// disables the check.
+	MinFreeSpace string `json:"min_free_space" mapstructure:"min_free_space"`
+	// CheckTimeout bounds each dependency check of /readyz and /health,
+	// e.g. "2s"; a check that takes longer fails.
+	CheckTimeout string `json:"check_timeout" mapstructure:"check_timeout"`
+	// CheckCache is how long the results of the dependency checks are
+	// reused, e.g. "1s", so frequent probes do not hammer the
+	// dependencies; "0" runs them for every request.
+	CheckCache string `json:"check_cache" mapstructure:"check_cache"`
+}
+
+// CheckTimeoutDuration returns the parsed CheckTimeout; zero means no
+// timeout. The value is checked by Validate, so a malformed one is treated
+// as zero here.
+func (h HealthConfig) CheckTimeoutDuration() time.Duration {
+	d, _ := parseTimeout(h.CheckTimeout)
+	return d
+}
+
+// CheckCacheDuration returns the parsed CheckCache; zero turns caching
+// off. The value is checked by Validate, so a malformed one is treated as
+// zero here.
+func (h HealthConfig) CheckCacheDuration() time.Duration {
+	d, _ := parseTimeout(h.CheckCache)
+	return d
+}
+
+// MinFreeSpaceBytes returns MinFreeSpace in bytes. The value is checked by
+// Validate, so a malformed one is treated as zero here.
+func (h HealthConfig) MinFreeSpaceBytes() uint64 {
+	n, _ := bytes.Parse(h.MinFreeSpace)
+	if n < 0 {
+		return 0
+	}
+	return uint64(n)
+}
+
+func DefaultConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Host: "0.0.0.0",
-			Port: 8080,
+			Host:           "0.0.0.0",
+			Port:           8080,
+			TrustedProxies: []string{},
+			AllowedHosts:   []string{},
+			LegacyRoutes:   true,
+			ReadTimeout:    "15s",
+			WriteTimeout:   "60s",
+			IdleTimeout:    "120s",
+			RequestTimeout: "30s",
+			BodyLimit:      "1MB",
+			RouteConflicts: RouteConflictsFail,
+			Internal: InternalListenerConfig{
+				Host: "127.0.0.1",
+				Port: 9091,
+			},
 		},
 		Logging: LogConfig{
-			Level:  "info",
-			Format: "text",
+			Level:                "info",
+			Format:               "text",
+			Output:               logging.OutputBoth,
+			SkipPaths:            []string{"/health", "/livez", "/readyz", "/metrics"},
+			RedactParams:         []string{"name"},
+			SampleRate:           1,
+			BufferSize:           logging.DefaultBufferSize,
+			DumpBodyLimit:        "4KB",
+			SlowRequestThreshold: "1s",
+			AccessLog: AccessLogConfig{
+				File:       "access.log",
+				Format:     "combined",
+				MaxSize:    10,
+				MaxBackups: 3,
+				MaxAge:     28,
+				Compress:   true,
+			},
 		},
-		DataPath: dataPath,
+		Greetings: GreetingsConfig{
+			CacheTTL:  "1m",
+			CacheSize: 1000,
+		},
+		Message: MessageConfig{
+			MaxLength:         validate.DefaultMaxLength,
+			BodyLimit:         "64KB",
+			ImportLimit:       "1MB",
+			MaxKeys:           100,
+			ForbiddenPatterns: []string{},
+			FilterMode:        FilterReject,
+			IdempotencyWindow: "24h",
+		},
+		Security: SecurityConfig{
+			APIKeys:    []string{},
+			AllowCIDRs: []string{},
+		},
+		UI: UIConfig{
+			TemplatesPath: DefaultTemplatesPath,
+		},
+		Health: HealthConfig{
+			MinFreeSpace: "100MB",
+			CheckTimeout: "2s",
+			CheckCache:   "1s",
+		},
+		Outbound: OutboundConfig{
+			Timeout: "10s",
+		},
+		Update: UpdateConfig{
+			ReleasesURL: update.DefaultReleasesURL,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:      []string{},
+			AllowedMethods:      []string{"GET", "HEAD", "POST", "PUT", "DELETE"},
+			AllowedHeaders:      []string{"Content-Type", "Authorization", "X-API-Key", "If-Match", "Idempotency-Key"},
+			AdminAllowedOrigins: []string{},
+		},
+		DataPath: DefaultDataPath(),
 	}
 }
 
-func Load(configPath string) (*Config, error) {
-	cfg := DefaultConfig()
+// DataPathEnv names the environment variable that overrides the data
+// directory when no data path is passed to Load.
+const DataPathEnv = "GREETD_DATA_PATH"
+
+// ResolveDataPath returns dataPath, or the GREETD_DATA_PATH environment
+// variable, or the default data directory, with "~" and environment
+// variables expanded.
+func ResolveDataPath(dataPath string) string {
+	if dataPath == "" {
+		dataPath = os.Getenv(DataPathEnv)
+	}
+	if dataPath == "" {
+		return DefaultConfig().DataPath
+	}
+	return ExpandPath(dataPath)
+}
 
+// ExpandPath expands environment variables and a leading "~" in p.
+func ExpandPath(p string) string {
+	p = os.ExpandEnv(p)
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
+
+// DefaultConfigPath returns the config file location inside dataPath.
+func DefaultConfigPath(dataPath string) string {
+	return filepath.Join(dataPath, "config.json")
+}
+
+// Init creates the data directory and writes a config file with the default
+// settings to configPath (default: config.json in the data directory). It
+// refuses to overwrite an existing file and returns the path it wrote.
+func Init(configPath, dataPath string) (string, error) {
+	cfg := DefaultConfig()
+	cfg.DataPath = ResolveDataPath(dataPath)
 	if configPath == "" {
-		configPath = filepath.Join(cfg.DataPath, "config.json")
+		configPath = DefaultConfigPath(cfg.DataPath)
+	}
+
+	if _, err := os.Stat(configPath); err == nil {
+		return configPath, fmt.Errorf("config file %s: %w", configPath, os.ErrExist)
 	}
 
-	// Create data directory if it doesn't exist
 	if err := os.MkdirAll(cfg.DataPath, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create data directory: %w", err)
+		return configPath, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return configPath, fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	// Create config file with defaults if it doesn't exist
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		if err := cfg.Save(configPath); err != nil {
-			return nil, fmt.Errorf("failed to create default config: %w", err)
-		}
+	if err := cfg.Save(configPath); err != nil {
+		return configPath, err
+	}
+	return configPath, nil
+}
+
+// Flags maps config keys such as "server.port" to command-line flags. A flag
+// overrides its key when it was set on the command line; nil flags are
+// ignored.
+type Flags map[string]*pflag.Flag
+
+// Load reads the config file at configPath, defaulting to config.json in the
+// data directory. It has no side effects: when the default file does not
+// exist the defaults are used, and nothing is created. A non-empty dataPath
+// takes precedence over GREETD_DATA_PATH and the data_path setting. Every
+// call uses its own viper instance, so loads do not affect each other.
+func Load(configPath, dataPath string, flags Flags) (*Config, error) {
+	cfg := DefaultConfig()
+	cfg.DataPath = ResolveDataPath(dataPath)
+
+	explicit := configPath != ""
+	if !explicit {
+		configPath = DefaultConfigPath(cfg.DataPath)
+	}
+
+	// An explicitly requested file must exist; without one, a missing
+	// default config file just means the defaults apply.
+	_, statErr := os.Stat(configPath)
+	if statErr != nil && (explicit || !os.IsNotExist(statErr)) {
+		return nil, fmt.Errorf("failed to read config: %w", statErr)
 	}
 
-	viper.SetConfigFile(configPath)
-	viper.SetEnvPrefix("GREETD")
-	viper.AutomaticEnv()
+	v := viper.New()
+	v.SetConfigFile(configPath)
+	v.SetEnvPrefix("GREETD")
+	v.AutomaticEnv()
+
+	for key, flag := range flags {
+		if flag == nil {
+			continue
+		}
+		if err := v.BindPFlag(key, flag); err != nil {
+			return nil, fmt.Errorf("failed to bind flag --%s: %w", flag.Name, err)
+		}
+	}
 
 	// Set defaults
-	viper.SetDefault("server.host", cfg.Server.Host)
-	viper.SetDefault("server.port", cfg.Server.Port)
-	viper.SetDefault("logging.level", cfg.Logging.Level)
-	viper.SetDefault("logging.format", cfg.Logging.Format)
-	viper.SetDefault("data_path", cfg.DataPath)
+	v.SetDefault("server.host", cfg.Server.Host)
+	v.SetDefault("server.port", cfg.Server.Port)
+	v.SetDefault("server.port_retry", cfg.Server.PortRetry)
+	v.SetDefault("server.trusted_proxies", cfg.Server.TrustedProxies)
+	v.SetDefault("server.legacy_routes", cfg.Server.LegacyRoutes)
+	v.SetDefault("server.base_path", cfg.Server.BasePath)
+	v.SetDefault("server.read_timeout", cfg.Server.ReadTimeout)
+	v.SetDefault("server.write_timeout", cfg.Server.WriteTimeout)
+	v.SetDefault("server.idle_timeout", cfg.Server.IdleTimeout)
+	v.SetDefault("server.request_timeout", cfg.Server.RequestTimeout)
+	v.SetDefault("server.enable_pprof", cfg.Server.EnablePprof)
+	v.SetDefault("server.allowed_hosts", cfg.Server.AllowedHosts)
+	v.SetDefault("server.redirect_https", cfg.Server.RedirectHTTPS)
+	v.SetDefault("server.https_port", cfg.Server.HTTPSPort)
+	v.SetDefault("server.body_limit", cfg.Server.BodyLimit)
+	v.SetDefault("server.route_conflicts", cfg.Server.RouteConflicts)
+	v.SetDefault("server.server_header", cfg.Server.ServerHeader)
+	v.SetDefault("server.expose_version", cfg.Server.ExposeVersion)
+	v.SetDefault("server.debug", cfg.Server.Debug)
+	v.SetDefault("server.internal.enabled", cfg.Server.Internal.Enabled)
+	v.SetDefault("server.internal.host", cfg.Server.Internal.Host)
+	v.SetDefault("server.internal.port", cfg.Server.Internal.Port)
+	v.SetDefault("logging.level", cfg.Logging.Level)
+	v.SetDefault("logging.format", cfg.Logging.Format)
+	v.SetDefault("logging.output", cfg.Logging.Output)
+	v.SetDefault("logging.skip_paths", cfg.Logging.SkipPaths)
+	v.SetDefault("logging.redact_params", cfg.Logging.RedactParams)
+	v.SetDefault("logging.sample_rate", cfg.Logging.SampleRate)
+	v.SetDefault("logging.buffer_size", cfg.Logging.BufferSize)
+	v.SetDefault("logging.dump_bodies", cfg.Logging.DumpBodies)
+	v.SetDefault("logging.dump_body_limit", cfg.Logging.DumpBodyLimit)
+	v.SetDefault("logging.crash_dumps", cfg.Logging.CrashDumps)
+	v.SetDefault("logging.slow_request_threshold", cfg.Logging.SlowRequestThreshold)
+	v.SetDefault("logging.request_format", cfg.Logging.RequestFormat)
+	v.SetDefault("logging.access_log.file", cfg.Logging.AccessLog.File)
+	v.SetDefault("logging.access_log.format", cfg.Logging.AccessLog.Format)
+	v.SetDefault("logging.access_log.max_size", cfg.Logging.AccessLog.MaxSize)
+	v.SetDefault("logging.access_log.max_backups", cfg.Logging.AccessLog.MaxBackups)
+	v.SetDefault("logging.access_log.max_age", cfg.Logging.AccessLog.MaxAge)
+	v.SetDefault("logging.access_log.compress", cfg.Logging.AccessLog.Compress)
+	v.SetDefault("security.api_keys", cfg.Security.APIKeys)
+	v.SetDefault("security.allow_open_admin", cfg.Security.AllowOpenAdmin)
+	v.SetDefault("security.allow_cidrs", cfg.Security.AllowCIDRs)
+	v.SetDefault("docs.use_cdn", cfg.Docs.UseCDN)
+	v.SetDefault("greetings.catalog_path", cfg.Greetings.CatalogPath)
+	v.SetDefault("greetings.template", cfg.Greetings.Template)
+	v.SetDefault("greetings.use_stored_template", cfg.Greetings.UseStoredTemplate)
+	v.SetDefault("greetings.cache_ttl", cfg.Greetings.CacheTTL)
+	v.SetDefault("greetings.cache_size", cfg.Greetings.CacheSize)
+	v.SetDefault("message.max_length", cfg.Message.MaxLength)
+	v.SetDefault("message.body_limit", cfg.Message.BodyLimit)
+	v.SetDefault("message.import_limit", cfg.Message.ImportLimit)
+	v.SetDefault("message.max_keys", cfg.Message.MaxKeys)
+	v.SetDefault("message.idempotency_window", cfg.Message.IdempotencyWindow)
+	v.SetDefault("message.forbidden_patterns", cfg.Message.ForbiddenPatterns)
+	v.SetDefault("message.banned_words_file", cfg.Message.BannedWordsFile)
+	v.SetDefault("message.filter_mode", cfg.Message.FilterMode)
+	v.SetDefault("ui.render_markdown", cfg.UI.RenderMarkdown)
+	v.SetDefault("ui.dev_mode", cfg.UI.DevMode)
+	v.SetDefault("ui.templates_path", cfg.UI.TemplatesPath)
+	v.SetDefault("ui.strict_templates", cfg.UI.StrictTemplates)
+	v.SetDefault("ui.locales_path", cfg.UI.LocalesPath)
+	v.SetDefault("health.min_free_space", cfg.Health.MinFreeSpace)
+	v.SetDefault("health.check_timeout", cfg.Health.CheckTimeout)
+	v.SetDefault("health.check_cache", cfg.Health.CheckCache)
+	v.SetDefault("storage.watch", cfg.Storage.Watch)
+	v.SetDefault("storage.encryption.key_file", cfg.Storage.Encryption.KeyFile)
+	v.SetDefault("outbound.proxy_url", cfg.Outbound.ProxyURL)
+	v.SetDefault("outbound.timeout", cfg.Outbound.Timeout)
+	v.SetDefault("outbound.tls_skip_verify", cfg.Outbound.TLSSkipVerify)
+	v.SetDefault("outbound.ca_file", cfg.Outbound.CAFile)
+	v.SetDefault("update.check", cfg.Update.Check)
+	v.SetDefault("update.releases_url", cfg.Update.ReleasesURL)
+	v.SetDefault("cors.allowed_origins", cfg.CORS.AllowedOrigins)
+	v.SetDefault("cors.allowed_methods", cfg.CORS.AllowedMethods)
+	v.SetDefault("cors.allowed_headers", cfg.CORS.AllowedHeaders)
+	v.SetDefault("cors.allow_credentials", cfg.CORS.AllowCredentials)
+	v.SetDefault("cors.max_age", cfg.CORS.MaxAge)
+	v.SetDefault("cors.admin_allowed_origins", cfg.CORS.AdminAllowedOrigins)
+	v.SetDefault("data_path", cfg.DataPath)
+	v.SetDefault("state_path", cfg.StatePath)
 
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("failed to read config: %w", err)
+	if statErr == nil {
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
 	}
 
-	if err := viper.Unmarshal(cfg); err != nil {
+	if err := v.Unmarshal(cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	// greeting.template is accepted as an alias of greetings.template.
+	if cfg.Greetings.Template == "" {
+		cfg.Greetings.Template = v.GetString("greeting.template")
+	}
+
+	cfg.Server.BasePath = NormalizeBasePath(cfg.Server.BasePath)
+	if dataPath != "" {
+		cfg.DataPath = dataPath
+	}
+	cfg.DataPath = ExpandPath(cfg.DataPath)
+	cfg.StatePath = ExpandPath(cfg.StatePath)
+	cfg.Storage.Encryption.KeyFile = ExpandPath(cfg.Storage.Encryption.KeyFile)
+	cfg.Message.BannedWordsFile = ExpandPath(cfg.Message.BannedWordsFile)
+	cfg.Outbound.CAFile = ExpandPath(cfg.Outbound.CAFile)
+	if statErr == nil {
+		cfg.File = configPath
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 
 	return cfg, nil
 }
 
+// NormalizeBasePath returns p with a leading slash and without a trailing
+// one, so "greetd/" becomes "/greetd". The root ("" or "/") becomes "".
+func NormalizeBasePath(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// Validate reports configuration values that would fail at runtime.
+func (c *Config) Validate() error {
+	if strings.ContainsAny(c.Server.BasePath, "*:?# ") {
+		return fmt.Errorf("server.base_path: invalid path %q", c.Server.BasePath)
+	}
+
+	for _, host := range c.Server.AllowedHosts {
+		if host == "" || strings.ContainsAny(host, "/@ ") || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+			return fmt.Errorf("server.allowed_hosts: invalid host %q", host)
+		}
+	}
+
+	if c.Server.PortRetry < 0 || c.Server.PortRetry > 100 {
+		return fmt.Errorf("server.port_retry must be between 0 and 100, got %d", c.Server.PortRetry)
+	}
+
+	if c.Server.HTTPSPort < 0 || c.Server.HTTPSPort > 65535 {
+		return fmt.Errorf("server.https_port must be between 0 and 65535, got %d", c.Server.HTTPSPort)
+	}
+
+	if internal := c.Server.Internal; internal.Enabled {
+		if internal.Port < 0 || internal.Port > 65535 {
+			return fmt.Errorf("server.internal.port must be between 0 and 65535, got %d", internal.Port)
+		}
+		if internal.Port != 0 && internal.Port == c.Server.Port {
+			return fmt.Errorf("server.internal.port must differ from server.port, got %d", internal.Port)
+		}
+	}
+
+	for _, timeout := range []struct{ key, value string }{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.request_timeout", c.Server.RequestTimeout},
+		{"logging.slow_request_threshold", c.Logging.SlowRequestThreshold},
+		{"message.idempotency_window", c.Message.IdempotencyWindow},
+		{"greetings.cache_ttl", c.Greetings.CacheTTL},
+		{"health.check_timeout", c.Health.CheckTimeout},
+		{"health.check_cache", c.Health.CheckCache},
+		{"outbound.timeout", c.Outbound.Timeout},
+	} {
+		if _, err := parseTimeout(timeout.value); err != nil {
+			return fmt.Errorf("%s: invalid duration %q", timeout.key, timeout.value)
+		}
+	}
+
+	if n, err := bytes.Parse(c.Server.BodyLimit); err != nil || n <= 0 {
+		return fmt.Errorf("server.body_limit: invalid size %q", c.Server.BodyLimit)
+	}
+
+	switch c.Server.RouteConflicts {
+	case RouteConflictsFail, RouteConflictsWarn:
+	default:
+		return fmt.Errorf("server.route_conflicts must be %s or %s, got %q", RouteConflictsFail, RouteConflictsWarn, c.Server.RouteConflicts)
+	}
+
+	if !httpguts.ValidHeaderFieldValue(c.Server.ServerHeader) {
+		return fmt.Errorf("server.server_header: invalid header value %q", c.Server.ServerHeader)
+	}
+
+	switch c.Logging.Output {
+	case logging.OutputStdout, logging.OutputFile, logging.OutputBoth:
+	default:
+		return fmt.Errorf("logging.output must be stdout, file or both, got %q", c.Logging.Output)
+	}
+
+	for _, pattern := range c.Logging.SkipPaths {
+		if _, err := path.Match(pattern, "/"); err != nil {
+			return fmt.Errorf("logging.skip_paths: invalid pattern %q", pattern)
+		}
+	}
+
+	for _, param := range c.Logging.RedactParams {
+		if strings.TrimSpace(param) == "" {
+			return fmt.Errorf("logging.redact_params: empty parameter name")
+		}
+	}
+
+	if c.Logging.SampleRate < 0 || c.Logging.SampleRate > 1 {
+		return fmt.Errorf("logging.sample_rate must be between 0 and 1, got %v", c.Logging.SampleRate)
+	}
+
+	if c.Logging.BufferSize <= 0 {
+		return fmt.Errorf("logging.buffer_size must be positive, got %d", c.Logging.BufferSize)
+	}
+
+	if n, err := bytes.Parse(c.Logging.DumpBodyLimit); err != nil || n <= 0 {
+		return fmt.Errorf("logging.dump_body_limit: invalid size %q", c.Logging.DumpBodyLimit)
+	}
+
+	switch c.Logging.RequestFormat {
+	case "", "json", "text", "common":
+	default:
+		return fmt.Errorf("logging.request_format must be json, text or common, got %q", c.Logging.RequestFormat)
+	}
+
+	switch c.Logging.AccessLog.Format {
+	case "combined", "common", "json":
+	default:
+		return fmt.Errorf("logging.access_log.format must be combined, common or json, got %q", c.Logging.AccessLog.Format)
+	}
+
+	if c.Message.MaxLength <= 0 {
+		return fmt.Errorf("message.max_length must be positive, got %d", c.Message.MaxLength)
+	}
+
+	if c.Greetings.CacheSize <= 0 {
+		return fmt.Errorf("greetings.cache_size must be positive, got %d", c.Greetings.CacheSize)
+	}
+
+	if c.Message.MaxKeys <= 0 {
+		return fmt.Errorf("message.max_keys must be positive, got %d", c.Message.MaxKeys)
+	}
+
+	if _, err := bytes.Parse(c.Message.BodyLimit); err != nil {
+		return fmt.Errorf("message.body_limit: invalid size %q", c.Message.BodyLimit)
+	}
+
+	if _, err := bytes.Parse(c.Message.ImportLimit); err != nil {
+		return fmt.Errorf("message.import_limit: invalid size %q", c.Message.ImportLimit)
+	}
+
+	if _, err := validate.CompilePatterns(c.Message.ForbiddenPatterns); err != nil {
+		return fmt.Errorf("message.forbidden_patterns: %w", err)
+	}
+
+	switch c.Message.FilterMode {
+	case FilterReject, FilterMask:
+	default:
+		return fmt.Errorf("message.filter_mode must be %s or %s, got %q", FilterReject, FilterMask, c.Message.FilterMode)
+	}
+
+	if n, err := bytes.Parse(c.Health.MinFreeSpace); err != nil || n < 0 {
+		return fmt.Errorf("health.min_free_space: invalid size %q", c.Health.MinFreeSpace)
+	}
+
+	if _, err := httpclient.Proxy(c.Outbound.ProxyURL); err != nil {
+		return fmt.Errorf("outbound.proxy_url: %w", err)
+	}
+
+	if u, err := url.Parse(c.Update.ReleasesURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("update.releases_url: invalid URL %q", c.Update.ReleasesURL)
+	}
+
+	if err := c.CORS.validate(); err != nil {
+		return err
+	}
+
+	if c.UI.DevMode && c.UI.TemplatesPath == "" {
+		return fmt.Errorf("ui.templates_path must be set when ui.dev_mode is enabled")
+	}
+
+	if c.Greetings.Template != "" {
+		if _, err := greeting.Parse(c.Greetings.Template); err != nil {
+			return fmt.Errorf("greetings.template: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// AppLogPath returns the application log file path, or "" when logging
+// only to stdout.
+func (c *Config) AppLogPath() string {
+	if c.Logging.Output == logging.OutputStdout {
+		return ""
+	}
+	return filepath.Join(c.StateDir(), logging.FileName)
+}
+
+// StateDir returns the directory for messages.json and the log files:
+// state_path when set, the default state directory when data_path is the
+// default, and data_path otherwise.
+func (c *Config) StateDir() string {
+	if c.StatePath != "" {
+		return c.StatePath
+	}
+	if c.DataPath == DefaultDataPath() {
+		return DefaultStatePath()
+	}
+	return c.DataPath
+}
+
+// AccessLogPath returns the resolved access log path, or "" when request
+// entries go to the application log.
+func (c *Config) AccessLogPath() string {
+	file := c.Logging.AccessLog.File
+	if file == "" || filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(c.StateDir(), file)
+}
+
 func (c *Config) Save(path string) error {
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
@@ -4,7 +4,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -16,6 +18,13 @@ func TestDefaultConfig(t *testing.T) {
 	assert.Equal(t, 8080, cfg.Server.Port)
 	assert.Equal(t, "info", cfg.Logging.Level)
 	assert.Equal(t, "text", cfg.Logging.Format)
+	assert.False(t, cfg.Logging.DumpBodies)
+	assert.False(t, cfg.Logging.CrashDumps)
+	assert.False(t, cfg.Greetings.UseStoredTemplate)
+	assert.Equal(t, time.Minute, cfg.Greetings.CacheDuration())
+	assert.Equal(t, 1000, cfg.Greetings.CacheSize)
+	assert.Equal(t, 2*time.Second, cfg.Health.CheckTimeoutDuration())
+	assert.Equal(t, time.Second, cfg.Health.CheckCacheDuration())
 	assert.NotEmpty(t, cfg.DataPath)
 }
 
@@ -36,7 +45,7 @@ func TestConfigSaveAndLoad(t *testing.T) {
 	require.NoError(t, err)
 
 	// Load config
-	loadedCfg, err := Load(configPath)
+	loadedCfg, err := Load(configPath, "", nil)
 	require.NoError(t, err)
 
 	assert.Equal(t, cfg.Server.Host, loadedCfg.Server.Host)
@@ -53,15 +62,337 @@ func TestLoadNonExistentConfig(t *testing.T) {
 
 	configPath := filepath.Join(tmpDir, "config.json")
 
-	// Load non-existent config (should create default)
-	cfg, err := Load(configPath)
+	// An explicitly requested config file must exist
+	_, err = Load(configPath, "", nil)
+	assert.Error(t, err)
+
+	// Without one, a missing default config file means the defaults apply
+	t.Setenv("HOME", tmpDir)
+	cfg, err := Load("", filepath.Join(tmpDir, "data"), nil)
 	require.NoError(t, err)
 
 	// Verify default values
 	assert.Equal(t, "0.0.0.0", cfg.Server.Host)
 	assert.Equal(t, 8080, cfg.Server.Port)
 
-	// Verify config file was created
-	_, err = os.Stat(configPath)
-	assert.NoError(t, err)
+	// Verify nothing was created
+	assert.NoFileExists(t, configPath)
+	assert.NoDirExists(t, filepath.Join(tmpDir, "data"))
+	assert.NoDirExists(t, filepath.Join(tmpDir, ".greetd"))
+}
+
+func TestInit(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dataPath := filepath.Join(t.TempDir(), "data")
+
+	path, err := Init("", dataPath)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dataPath, "config.json"), path)
+	assert.FileExists(t, path)
+
+	cfg, err := Load("", dataPath, nil)
+	require.NoError(t, err)
+	assert.Equal(t, dataPath, cfg.DataPath)
+
+	_, err = Init("", dataPath)
+	assert.ErrorIs(t, err, os.ErrExist)
+}
+
+func TestLoadRejectsInvalidGreetingTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	cfg := DefaultConfig()
+	cfg.DataPath = tmpDir
+	cfg.Greetings.Template = "Hello, {{.Nmae}}!"
+	require.NoError(t, cfg.Save(configPath))
+
+	_, err := Load(configPath, "", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "greetings.template")
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*Config)
+		wantErr   string
+	}{
+		{name: "defaults", configure: func(*Config) {}},
+		{name: "sample rate above one", configure: func(c *Config) { c.Logging.SampleRate = 1.5 }, wantErr: "logging.sample_rate"},
+		{name: "negative sample rate", configure: func(c *Config) { c.Logging.SampleRate = -0.1 }, wantErr: "logging.sample_rate"},
+		{name: "malformed skip pattern", configure: func(c *Config) { c.Logging.SkipPaths = []string{"/static/["} }, wantErr: "logging.skip_paths"},
+		{name: "empty redacted parameter", configure: func(c *Config) { c.Logging.RedactParams = []string{"name", " "} }, wantErr: "logging.redact_params"},
+		{name: "malformed dump body limit", configure: func(c *Config) { c.Logging.DumpBodyLimit = "lots" }, wantErr: "logging.dump_body_limit"},
+		{name: "malformed import limit", configure: func(c *Config) { c.Message.ImportLimit = "lots" }, wantErr: "message.import_limit"},
+		{name: "malformed body limit", configure: func(c *Config) { c.Server.BodyLimit = "lots" }, wantErr: "server.body_limit"},
+		{name: "malformed outbound timeout", configure: func(c *Config) { c.Outbound.Timeout = "soon" }, wantErr: "outbound.timeout"},
+		{name: "malformed proxy URL", configure: func(c *Config) { c.Outbound.ProxyURL = "proxy.example.com:3128" }, wantErr: "outbound.proxy_url"},
+		{name: "proxy URL", configure: func(c *Config) { c.Outbound.ProxyURL = "http://proxy.example.com:3128" }},
+		{name: "malformed releases URL", configure: func(c *Config) { c.Update.ReleasesURL = "api.github.com/releases" }, wantErr: "update.releases_url"},
+		{name: "update check", configure: func(c *Config) { c.Update.Check = true }},
+		{name: "unknown route conflicts", configure: func(c *Config) { c.Server.RouteConflicts = "ignore" }, wantErr: "server.route_conflicts"},
+		{name: "CORS origins", configure: func(c *Config) {
+			c.CORS.AllowedOrigins = []string{"https://app.example.com", "https://*.example.com", "http://localhost:3000"}
+			c.CORS.AllowCredentials = true
+		}},
+		{name: "CORS wildcard", configure: func(c *Config) { c.CORS.AllowedOrigins = []string{"*"} }},
+		{name: "CORS wildcard with credentials", configure: func(c *Config) {
+			c.CORS.AllowedOrigins = []string{"*"}
+			c.CORS.AllowCredentials = true
+		}, wantErr: "cannot be combined with cors.allow_credentials"},
+		{name: "admin CORS wildcard with credentials", configure: func(c *Config) {
+			c.CORS.AdminAllowedOrigins = []string{"*"}
+			c.CORS.AllowCredentials = true
+		}, wantErr: "cors.admin_allowed_origins"},
+		{name: "CORS origin with a path", configure: func(c *Config) { c.CORS.AllowedOrigins = []string{"https://app.example.com/ui"} }, wantErr: "cors.allowed_origins"},
+		{name: "CORS origin without a scheme", configure: func(c *Config) { c.CORS.AllowedOrigins = []string{"app.example.com"} }, wantErr: "cors.allowed_origins"},
+		{name: "unknown CORS method", configure: func(c *Config) { c.CORS.AllowedMethods = []string{"get"} }, wantErr: "cors.allowed_methods"},
+		{name: "negative CORS max age", configure: func(c *Config) { c.CORS.MaxAge = -1 }, wantErr: "cors.max_age"},
+		{name: "server header", configure: func(c *Config) { c.Server.ServerHeader = "web" }},
+		{name: "server header off", configure: func(c *Config) { c.Server.ServerHeader = ServerHeaderOff }},
+		{name: "server header with a newline", configure: func(c *Config) { c.Server.ServerHeader = "web\r\nX-Evil: 1" }, wantErr: "server.server_header"},
+		{name: "warn about route conflicts", configure: func(c *Config) { c.Server.RouteConflicts = RouteConflictsWarn }},
+		{name: "unknown request format", configure: func(c *Config) { c.Logging.RequestFormat = "apache" }, wantErr: "logging.request_format"},
+		{name: "common request format", configure: func(c *Config) { c.Logging.RequestFormat = "common" }},
+		{name: "malformed slow request threshold", configure: func(c *Config) { c.Logging.SlowRequestThreshold = "soon" }, wantErr: "logging.slow_request_threshold"},
+		{name: "unknown log output", configure: func(c *Config) { c.Logging.Output = "syslog" }, wantErr: "logging.output"},
+		{name: "base path with wildcard", configure: func(c *Config) { c.Server.BasePath = "/greetd/*" }, wantErr: "server.base_path"},
+		{name: "allowed hosts", configure: func(c *Config) { c.Server.AllowedHosts = []string{"greetd.example.com", "*.example.com"} }},
+		{name: "allowed host with path", configure: func(c *Config) { c.Server.AllowedHosts = []string{"example.com/greetd"} }, wantErr: "server.allowed_hosts"},
+		{name: "allowed host with inner wildcard", configure: func(c *Config) { c.Server.AllowedHosts = []string{"greetd.*.com"} }, wantErr: "server.allowed_hosts"},
+		{name: "negative port retry", configure: func(c *Config) { c.Server.PortRetry = -1 }, wantErr: "server.port_retry"},
+		{name: "https port out of range", configure: func(c *Config) { c.Server.HTTPSPort = 70000 }, wantErr: "server.https_port"},
+		{name: "internal listener on the server port", configure: func(c *Config) { c.Server.Internal.Enabled = true; c.Server.Internal.Port = c.Server.Port }, wantErr: "server.internal.port"},
+		{name: "internal listener on a free port", configure: func(c *Config) { c.Server.Internal.Enabled = true; c.Server.Internal.Port = 0 }},
+		{name: "no message keys", configure: func(c *Config) { c.Message.MaxKeys = 0 }, wantErr: "message.max_keys"},
+		{name: "malformed cache ttl", configure: func(c *Config) { c.Greetings.CacheTTL = "soon" }, wantErr: "greetings.cache_ttl"},
+		{name: "malformed check timeout", configure: func(c *Config) { c.Health.CheckTimeout = "2" }, wantErr: "health.check_timeout"},
+		{name: "no cache size", configure: func(c *Config) { c.Greetings.CacheSize = 0 }, wantErr: "greetings.cache_size"},
+		{name: "malformed idempotency window", configure: func(c *Config) { c.Message.IdempotencyWindow = "a day" }, wantErr: "message.idempotency_window"},
+		{name: "forbidden patterns", configure: func(c *Config) { c.Message.ForbiddenPatterns = []string{`(?i)casino`, `https?://`} }},
+		{name: "malformed forbidden pattern", configure: func(c *Config) { c.Message.ForbiddenPatterns = []string{"(unclosed"} }, wantErr: "message.forbidden_patterns"},
+		{name: "mask banned words", configure: func(c *Config) { c.Message.FilterMode = FilterMask }},
+		{name: "unknown filter mode", configure: func(c *Config) { c.Message.FilterMode = "censor" }, wantErr: "message.filter_mode"},
+		{name: "idempotency keys off", configure: func(c *Config) { c.Message.IdempotencyWindow = "0" }},
+		{name: "dev mode without templates path", configure: func(c *Config) { c.UI.DevMode, c.UI.TemplatesPath = true, "" }, wantErr: "ui.templates_path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.configure(cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestNormalizeBasePath(t *testing.T) {
+	for input, want := range map[string]string{
+		"":         "",
+		"/":        "",
+		"greetd":   "/greetd",
+		"/greetd/": "/greetd",
+		" /a/b/ ":  "/a/b",
+	} {
+		assert.Equal(t, want, NormalizeBasePath(input), input)
+	}
+}
+
+func TestLoadDataPathOverride(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(DataPathEnv, "")
+
+	dataPath := filepath.Join(t.TempDir(), "instance-a")
+	t.Setenv("GREETD_TEST_DIR", dataPath)
+
+	cfg, err := Load("", "$GREETD_TEST_DIR", nil)
+	require.NoError(t, err)
+	assert.Equal(t, dataPath, cfg.DataPath)
+	assert.NoDirExists(t, dataPath)
+	assert.NoDirExists(t, filepath.Join(home, ".greetd"))
+
+	// The override wins over data_path in the config file.
+	other := t.TempDir()
+	fileCfg := DefaultConfig()
+	fileCfg.DataPath = other
+	configPath := filepath.Join(other, "config.json")
+	require.NoError(t, fileCfg.Save(configPath))
+
+	cfg, err = Load(configPath, dataPath, nil)
+	require.NoError(t, err)
+	assert.Equal(t, dataPath, cfg.DataPath)
+
+	cfg, err = Load(configPath, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, other, cfg.DataPath)
+}
+
+func TestLoadDataPathEnv(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(DataPathEnv, "~/instance-b")
+
+	cfg, err := Load("", "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "instance-b"), cfg.DataPath)
+	assert.NoDirExists(t, filepath.Join(home, ".greetd"))
+}
+
+func TestExpandPath(t *testing.T) {
+	t.Setenv("HOME", "/home/ada")
+	t.Setenv("GREETD_TEST_DIR", "/srv/greetd")
+
+	for input, want := range map[string]string{
+		"~":                      "/home/ada",
+		"~/data":                 "/home/ada/data",
+		"$GREETD_TEST_DIR/a":     "/srv/greetd/a",
+		"${GREETD_TEST_DIR}/b":   "/srv/greetd/b",
+		"/var/lib/greetd":        "/var/lib/greetd",
+		"relative/~not-expanded": "relative/~not-expanded",
+	} {
+		assert.Equal(t, want, ExpandPath(input), input)
+	}
+}
+
+func TestLoadIsolated(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	first := DefaultConfig()
+	first.DataPath = t.TempDir()
+	first.Server.Port = 9001
+	first.Greetings.Template = "Hi {{.Name}}"
+	firstPath := filepath.Join(first.DataPath, "config.json")
+	require.NoError(t, first.Save(firstPath))
+
+	// The second file only sets the port, so everything else must come
+	// from the defaults rather than the first file.
+	secondDir := t.TempDir()
+	secondPath := filepath.Join(secondDir, "config.json")
+	require.NoError(t, os.WriteFile(secondPath, []byte(`{"server": {"port": 9002}}`), 0644))
+
+	cfg, err := Load(firstPath, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 9001, cfg.Server.Port)
+	assert.Equal(t, "Hi {{.Name}}", cfg.Greetings.Template)
+
+	cfg, err = Load(secondPath, secondDir, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 9002, cfg.Server.Port)
+	assert.Empty(t, cfg.Greetings.Template)
+	assert.Equal(t, secondDir, cfg.DataPath)
+}
+
+func TestLoadGreetingTemplateAlias(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"greeting": {"template": "Hi {{.Name}}"}}`), 0644))
+	cfg, err := Load(path, dir, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Hi {{.Name}}", cfg.Greetings.Template)
+
+	// greetings.template wins when both are set.
+	require.NoError(t, os.WriteFile(path, []byte(`{"greeting": {"template": "Hi {{.Name}}"}, "greetings": {"template": "Hey {{.Name}}"}}`), 0644))
+	cfg, err = Load(path, dir, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Hey {{.Name}}", cfg.Greetings.Template)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"greeting": {"template": "Hi {{.Nmae}}"}}`), 0644))
+	_, err = Load(path, dir, nil)
+	assert.ErrorContains(t, err, "greetings.template")
+}
+
+func TestLoadStorageWatch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	cfg, err := Load("", t.TempDir(), nil)
+	require.NoError(t, err)
+	assert.False(t, cfg.Storage.Watch)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"storage": {"watch": true}}`), 0644))
+	cfg, err = Load(path, dir, nil)
+	require.NoError(t, err)
+	assert.True(t, cfg.Storage.Watch)
+}
+
+func TestLoadFlags(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	dataPath := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.DataPath = dataPath
+	cfg.Server.Port = 9001
+	cfg.Logging.Level = "warn"
+	configPath := filepath.Join(dataPath, "config.json")
+	require.NoError(t, cfg.Save(configPath))
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Int("port", 0, "")
+	flags.String("log-level", "info", "")
+	require.NoError(t, flags.Parse([]string{"--port", "9100"}))
+
+	loaded, err := Load(configPath, "", Flags{
+		"server.port":   flags.Lookup("port"),
+		"logging.level": flags.Lookup("log-level"),
+		"server.host":   nil,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 9100, loaded.Server.Port, "a flag that was set overrides the file")
+	assert.Equal(t, "warn", loaded.Logging.Level, "an unset flag's default does not")
+}
+
+func TestLoadUIDevMode(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	load := func(t *testing.T, fileDevMode bool, args ...string) *Config {
+		dataPath := t.TempDir()
+		cfg := DefaultConfig()
+		cfg.DataPath = dataPath
+		cfg.UI.DevMode = fileDevMode
+		configPath := filepath.Join(dataPath, "config.json")
+		require.NoError(t, cfg.Save(configPath))
+
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.Bool("dev", false, "")
+		require.NoError(t, flags.Parse(args))
+
+		loaded, err := Load(configPath, "", Flags{"ui.dev_mode": flags.Lookup("dev")})
+		require.NoError(t, err)
+		return loaded
+	}
+
+	assert.False(t, load(t, false).UI.DevMode, "off by default")
+	assert.True(t, load(t, true).UI.DevMode, "the config file turns it on")
+	assert.True(t, load(t, false, "--dev").UI.DevMode, "the flag overrides the file")
+	assert.False(t, load(t, true, "--dev=false").UI.DevMode, "an explicit --dev=false overrides the file")
+	assert.Equal(t, DefaultTemplatesPath, load(t, true).UI.TemplatesPath)
+}
+
+func TestServerTimeouts(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.Equal(t, Timeouts{Read: 15 * time.Second, Write: time.Minute, Idle: 2 * time.Minute, Request: 30 * time.Second}, cfg.Server.Timeouts())
+
+	cfg.Server.WriteTimeout = "0"
+	cfg.Server.RequestTimeout = ""
+	require.NoError(t, cfg.Validate())
+	assert.Zero(t, cfg.Server.Timeouts().Write)
+	assert.Zero(t, cfg.Server.Timeouts().Request)
+
+	cfg.Server.ReadTimeout = "soon"
+	assert.ErrorContains(t, cfg.Validate(), "server.read_timeout")
+
+	cfg.Server.ReadTimeout = "-1s"
+	assert.ErrorContains(t, cfg.Validate(), "server.read_timeout")
 }
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// appName is the directory name used below the XDG base directories.
+const appName = "greetd"
+
+// LegacyDataPath returns ~/.greetd, where earlier versions kept the config
+// file, message and logs together.
+func LegacyDataPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".greetd")
+}
+
+// XDGConfigPath returns $XDG_CONFIG_HOME/greetd (default ~/.config/greetd).
+func XDGConfigPath() string {
+	return xdgPath("XDG_CONFIG_HOME", ".config")
+}
+
+// XDGStatePath returns $XDG_STATE_HOME/greetd, falling back to
+// $XDG_DATA_HOME/greetd and then ~/.local/state/greetd.
+func XDGStatePath() string {
+	if os.Getenv("XDG_STATE_HOME") == "" && os.Getenv("XDG_DATA_HOME") != "" {
+		return xdgPath("XDG_DATA_HOME", filepath.Join(".local", "share"))
+	}
+	return xdgPath("XDG_STATE_HOME", filepath.Join(".local", "state"))
+}
+
+// xdgPath returns the greetd directory below the base directory named by
+// env, or below fallback in the home directory when env is unset. Relative
+// values are ignored, as the XDG spec requires.
+func xdgPath(env, fallback string) string {
+	if base := os.Getenv(env); filepath.IsAbs(base) {
+		return filepath.Join(base, appName)
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, fallback, appName)
+}
+
+// usesLegacyDataPath reports whether ~/.greetd exists and should keep being
+// used for everything.
+func usesLegacyDataPath() bool {
+	info, err := os.Stat(LegacyDataPath())
+	return err == nil && info.IsDir()
+}
+
+// DefaultDataPath returns the directory holding config.json: ~/.greetd for
+// existing installs, otherwise the XDG config directory.
+func DefaultDataPath() string {
+	if usesLegacyDataPath() {
+		return LegacyDataPath()
+	}
+	return XDGConfigPath()
+}
+
+// DefaultStatePath returns the directory holding message.json and the log
+// files: ~/.greetd for existing installs, otherwise the XDG state directory.
+func DefaultStatePath() string {
+	if usesLegacyDataPath() {
+		return LegacyDataPath()
+	}
+	return XDGStatePath()
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultPathsXDG(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+	t.Setenv("XDG_STATE_HOME", "/xdg/state")
+	t.Setenv("XDG_DATA_HOME", "/xdg/data")
+
+	assert.Equal(t, "/xdg/config/greetd", DefaultDataPath())
+	assert.Equal(t, "/xdg/state/greetd", DefaultStatePath())
+
+	t.Setenv("XDG_STATE_HOME", "")
+	assert.Equal(t, "/xdg/data/greetd", DefaultStatePath())
+
+	t.Setenv("XDG_CONFIG_HOME", "relative")
+	t.Setenv("XDG_DATA_HOME", "")
+	assert.Equal(t, filepath.Join(home, ".config", "greetd"), DefaultDataPath())
+	assert.Equal(t, filepath.Join(home, ".local", "state", "greetd"), DefaultStatePath())
+}
+
+func TestDefaultPathsLegacy(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+	t.Setenv("XDG_STATE_HOME", "/xdg/state")
+	require.NoError(t, os.Mkdir(filepath.Join(home, ".greetd"), 0755))
+
+	assert.Equal(t, filepath.Join(home, ".greetd"), DefaultDataPath())
+	assert.Equal(t, filepath.Join(home, ".greetd"), DefaultStatePath())
+}
+
+func TestStateDir(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+	t.Setenv("XDG_STATE_HOME", "/xdg/state")
+
+	cfg := DefaultConfig()
+	assert.Equal(t, "/xdg/state/greetd", cfg.StateDir())
+	assert.Equal(t, "/xdg/state/greetd/app.log", cfg.AppLogPath())
+
+	cfg.DataPath = "/srv/greetd"
+	assert.Equal(t, "/srv/greetd", cfg.StateDir())
+
+	cfg.StatePath = "/var/lib/greetd"
+	assert.Equal(t, "/var/lib/greetd", cfg.StateDir())
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/svanhalla/prompt-lab/greetd/internal/version"
+)
+
+// Setting is one entry of the configuration summary.
+type Setting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Summary lists what a server runs with, in a fixed order, for the
+// startup log and greetd config show --effective. API keys are listed by
+// their fingerprint, never their value, followed by the number of keys
+// managed with greetd apikey, managedKeys.
+func (c *Config) Summary(managedKeys int) []Setting {
+	info := version.Get()
+	basePath := NormalizeBasePath(c.Server.BasePath)
+
+	configFile := c.File
+	if configFile == "" {
+		configFile = "none, using defaults"
+	}
+
+	storage := []string{"file"}
+	if c.Storage.Encryption.KeyFile != "" || os.Getenv(StorageKeyEnv) != "" {
+		storage = append(storage, "encrypted")
+	}
+	if c.Storage.Watch {
+		storage = append(storage, "watched")
+	}
+
+	accessLog := c.AccessLogPath()
+	if accessLog == "" {
+		accessLog = "application log"
+	}
+
+	apiKeys := "none"
+	if len(c.Security.APIKeys) > 0 || managedKeys > 0 {
+		keys := make([]string, 0, len(c.Security.APIKeys)+1)
+		for _, key := range c.Security.APIKeys {
+			keys = append(keys, KeyFingerprint(key))
+		}
+		if managedKeys > 0 {
+			keys = append(keys, strconv.Itoa(managedKeys)+" managed")
+		}
+		apiKeys = strings.Join(keys, ", ")
+	}
+
+	allowCIDRs := "everyone"
+	if len(c.Security.AllowCIDRs) > 0 {
+		allowCIDRs = strings.Join(c.Security.AllowCIDRs, ", ")
+	}
+
+	tls := "disabled"
+	if c.Server.RedirectHTTPS {
+		tls = "disabled, redirecting to HTTPS"
+	}
+
+	pprof := "disabled"
+	if c.Server.EnablePprof {
+		pprof = "enabled at " + basePath + "/debug/pprof/"
+	}
+
+	debug := "off"
+	if c.Server.Debug {
+		debug = "ON, errors and stacks in 500 responses"
+	}
+
+	outbound := "proxy from environment"
+	if u, err := url.Parse(c.Outbound.ProxyURL); err == nil && c.Outbound.ProxyURL != "" {
+		outbound = "proxy " + u.Redacted()
+	}
+	outbound += ", timeout " + c.Outbound.Timeout
+	if c.Outbound.CAFile != "" {
+		outbound += ", CA file " + c.Outbound.CAFile
+	}
+	if c.Outbound.TLSSkipVerify {
+		outbound += ", TLS verification DISABLED"
+	}
+
+	internal := "disabled"
+	if c.Server.Internal.Enabled {
+		internal = net.JoinHostPort(c.Server.Internal.Host, strconv.Itoa(c.Server.Internal.Port))
+	}
+
+	updates := "not checked"
+	if c.Update.Check {
+		updates = "checked daily"
+	}
+
+	templates := "production, embedded"
+	if c.UI.DevMode {
+		dir := c.UI.TemplatesPath
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		templates = "development, hot reload from " + dir
+	}
+
+	return []Setting{
+		{"version", info.Version + " (commit " + info.Commit + ")"},
+		{"address", net.JoinHostPort(c.Server.Host, strconv.Itoa(c.Server.Port))},
+		{"internal", internal},
+		{"base_path", basePath + "/"},
+		{"config_file", configFile},
+		{"data_path", c.DataPath},
+		{"state_path", c.StateDir()},
+		{"storage", strings.Join(storage, ", ")},
+		{"log_level", c.Logging.Level},
+		{"log_format", c.Logging.Format},
+		{"log_output", c.Logging.Output},
+		{"access_log", accessLog},
+		{"api_keys", apiKeys},
+		{"open_admin", strconv.FormatBool(c.Security.AllowOpenAdmin && len(c.Security.APIKeys) == 0 && managedKeys == 0)},
+		{"allow_cidrs", allowCIDRs},
+		{"tls", tls},
+		{"metrics", "enabled at " + basePath + "/metrics"},
+		{"pprof", pprof},
+		{"debug", debug},
+		{"outbound", outbound},
+		{"updates", updates},
+		{"templates", templates},
+	}
+}
+
+// KeyFingerprint names an API key without revealing it: "api-key:" and the
+// start of the key's SHA-256.
+func KeyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "api-key:" + hex.EncodeToString(sum[:])[:8]
+}
@@ -0,0 +1,228 @@
+// Package diff compares texts line by line, for showing how a message
+// changed between revisions.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op is what happened to a line.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+func (op Op) String() string {
+	switch op {
+	case Delete:
+		return "delete"
+	case Insert:
+		return "insert"
+	default:
+		return "equal"
+	}
+}
+
+// MarshalText encodes op by its name, e.g. "insert".
+func (op Op) MarshalText() ([]byte, error) {
+	return []byte(op.String()), nil
+}
+
+// UnmarshalText decodes an op from its name.
+func (op *Op) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "equal":
+		*op = Equal
+	case "delete":
+		*op = Delete
+	case "insert":
+		*op = Insert
+	default:
+		return fmt.Errorf("unknown op %q", text)
+	}
+	return nil
+}
+
+// Line is a line of the old text, the new text, or both.
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxCells bounds the table of the longest common subsequence. Changes to
+// texts too long for it are reported as replacing every line in between
+// the common start and end.
+const maxCells = 4 << 20
+
+// Lines returns the edit script from a to b: the lines of a, with those
+// removed marked Delete, interleaved with the lines added from b marked
+// Insert. Removals come before the insertions that replace them.
+func Lines(a, b string) []Line {
+	before, after := splitLines(a), splitLines(b)
+
+	// Only the part between a common start and end needs comparing.
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix && before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(before)+len(after)-prefix-suffix)
+	for _, text := range before[:prefix] {
+		lines = append(lines, Line{Equal, text})
+	}
+	lines = append(lines, middle(before[prefix:len(before)-suffix], after[prefix:len(after)-suffix])...)
+	for _, text := range before[len(before)-suffix:] {
+		lines = append(lines, Line{Equal, text})
+	}
+	return lines
+}
+
+// middle diffs a and b through their longest common subsequence.
+func middle(a, b []string) []Line {
+	var lines []Line
+	if len(a)*len(b) > maxCells || len(a) == 0 || len(b) == 0 {
+		for _, text := range a {
+			lines = append(lines, Line{Delete, text})
+		}
+		for _, text := range b {
+			lines = append(lines, Line{Insert, text})
+		}
+		return lines
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, Line{Equal, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, Line{Delete, a[i]})
+			i++
+		default:
+			lines = append(lines, Line{Insert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, Line{Delete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, Line{Insert, b[j]})
+	}
+	return lines
+}
+
+// splitLines splits text into lines. A final newline does not start
+// another line.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Changed reports whether lines contain an insertion or deletion.
+func Changed(lines []Line) bool {
+	for _, line := range lines {
+		if line.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Unified formats lines as a unified diff with context unchanged lines
+// around each change, headed by the names of the old and new text. It
+// returns "" when nothing changed.
+func Unified(fromName, toName string, lines []Line, context int) string {
+	if !Changed(lines) {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+
+	// oldLine and newLine are the line numbers, from 0, before lines[i].
+	oldLine, newLine := make([]int, len(lines)+1), make([]int, len(lines)+1)
+	for i, line := range lines {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if line.Op != Insert {
+			oldLine[i+1]++
+		}
+		if line.Op != Delete {
+			newLine[i+1]++
+		}
+	}
+
+	for start := 0; start < len(lines); {
+		// A hunk runs from context lines before a change to context lines
+		// after the last change that is at most 2*context lines from the
+		// one before it.
+		first := start
+		for first < len(lines) && lines[first].Op == Equal {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		last := first
+		for i := first; i < len(lines) && i <= last+2*context; i++ {
+			if lines[i].Op != Equal {
+				last = i
+			}
+		}
+		from, to := max(first-context, start), min(last+context+1, len(lines))
+
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n",
+			hunkRange(oldLine[from], oldLine[to]-oldLine[from]),
+			hunkRange(newLine[from], newLine[to]-newLine[from]))
+		for _, line := range lines[from:to] {
+			prefix := " "
+			switch line.Op {
+			case Delete:
+				prefix = "-"
+			case Insert:
+				prefix = "+"
+			}
+			b.WriteString(prefix + line.Text + "\n")
+		}
+		start = to
+	}
+	return b.String()
+}
+
+// hunkRange formats the lines of a hunk in one text: its first line,
+// counted from 1, and how many there are. An empty range is numbered by
+// the line before it.
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
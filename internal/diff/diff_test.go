@@ -0,0 +1,92 @@
+package diff
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Line
+	}{
+		{"equal", "a\nb", "a\nb", []Line{{Equal, "a"}, {Equal, "b"}}},
+		{"both empty", "", "", []Line{}},
+		{"from empty", "", "a", []Line{{Insert, "a"}}},
+		{"to empty", "a", "", []Line{{Delete, "a"}}},
+		{"replaced line", "a\nb\nc", "a\nx\nc", []Line{{Equal, "a"}, {Delete, "b"}, {Insert, "x"}, {Equal, "c"}}},
+		{"added line", "a\nc", "a\nb\nc", []Line{{Equal, "a"}, {Insert, "b"}, {Equal, "c"}}},
+		{"removed lines", "a\nb\nc\nd", "a\nd", []Line{{Equal, "a"}, {Delete, "b"}, {Delete, "c"}, {Equal, "d"}}},
+		{"moved line", "a\nb\nc", "b\nc\na", []Line{{Delete, "a"}, {Equal, "b"}, {Equal, "c"}, {Insert, "a"}}},
+		{"final newline", "a\n", "a", []Line{{Equal, "a"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Lines(tt.a, tt.b))
+		})
+	}
+}
+
+func TestLinesTooLong(t *testing.T) {
+	var a, b []string
+	for i := range 3000 {
+		a = append(a, "a"+strings.Repeat("x", i%7))
+		b = append(b, "b"+strings.Repeat("x", i%7))
+	}
+	lines := Lines("same\n"+strings.Join(a, "\n"), "same\n"+strings.Join(b, "\n"))
+	require.Len(t, lines, 6001)
+	assert.Equal(t, Line{Equal, "same"}, lines[0])
+	assert.Equal(t, Delete, lines[1].Op)
+	assert.Equal(t, Insert, lines[6000].Op)
+}
+
+func TestUnified(t *testing.T) {
+	assert.Empty(t, Unified("a", "b", Lines("same", "same"), 3))
+
+	before := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12"
+	after := "1\nTWO\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13"
+	assert.Equal(t, `--- revision 1
++++ revision 2
+@@ -1,5 +1,5 @@
+ 1
+-2
++TWO
+ 3
+ 4
+ 5
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`, Unified("revision 1", "revision 2", Lines(before, after), 3))
+
+	// Changes close together share a hunk.
+	assert.Equal(t, `--- a
++++ b
+@@ -1,3 +1,3 @@
+-1
++one
+ 2
+-3
++three
+`, Unified("a", "b", Lines("1\n2\n3", "one\n2\nthree"), 1))
+
+	assert.Equal(t, "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+new\n", Unified("a", "b", Lines("", "new"), 3))
+}
+
+func TestLineJSON(t *testing.T) {
+	data, err := json.Marshal([]Line{{Insert, "x"}, {Delete, "y"}, {Equal, "z"}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"op":"insert","text":"x"},{"op":"delete","text":"y"},{"op":"equal","text":"z"}]`, string(data))
+
+	var lines []Line
+	require.NoError(t, json.Unmarshal(data, &lines))
+	assert.Equal(t, []Line{{Insert, "x"}, {Delete, "y"}, {Equal, "z"}}, lines)
+	assert.Error(t, json.Unmarshal([]byte(`[{"op":"move"}]`), &lines))
+}
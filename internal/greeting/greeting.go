@@ -0,0 +1,337 @@
+package greeting
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/svanhalla/prompt-lab/greetd/internal/i18n"
+	"github.com/svanhalla/prompt-lab/greetd/internal/version"
+)
+
+// ServiceName is exposed to greeting templates as {{.Service}}.
+const ServiceName = "greetd"
+
+// Data is the value greeting templates are executed against.
+type Data struct {
+	Name    string
+	Service string
+	Version string
+	Lang    string
+}
+
+// Greeter renders greetings for both the API and the CLI so the two cannot drift.
+type Greeter struct {
+	catalog  *i18n.Catalog
+	template *template.Template
+}
+
+// Parse compiles a greeting template and verifies it only references known
+// fields, so mistakes are reported up front instead of rendering "<no value>".
+func Parse(text string) (*template.Template, error) {
+	tmpl, err := template.New("greeting").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid greeting template: %w", err)
+	}
+
+	sample := Data{Name: "World", Service: ServiceName, Version: "dev", Lang: i18n.DefaultLang}
+	if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil {
+		return nil, fmt.Errorf("invalid greeting template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// New returns a Greeter. An empty templateText uses the localized greetings
+// from the catalog; otherwise the template is used for every language.
+func New(templateText string, catalog *i18n.Catalog) (*Greeter, error) {
+	g := &Greeter{catalog: catalog}
+
+	if templateText != "" {
+		tmpl, err := Parse(templateText)
+		if err != nil {
+			return nil, err
+		}
+		g.template = tmpl
+	}
+
+	return g, nil
+}
+
+// Options adjust how a greeting is presented.
+type Options struct {
+	// Repeat prints the greeting this many times, one per line. Values
+	// below 1 print it once.
+	Repeat int
+	// Shout uppercases the greeting.
+	Shout bool
+	// TimeAware picks the morning, afternoon or evening greeting from the
+	// catalog. Greeting templates are used as-is.
+	TimeAware bool
+	// At is the time TimeAware greetings are based on; zero means now.
+	At time.Time
+}
+
+// ParseClock parses an "HH:MM" time of day for Options.At.
+func ParseClock(value string) (time.Time, error) {
+	at, err := time.Parse("15:04", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: expected HH:MM", value)
+	}
+	return at, nil
+}
+
+// Period returns "morning" (05-12), "afternoon" (12-18) or "evening" for t.
+func Period(t time.Time) string {
+	switch hour := t.Hour(); {
+	case hour >= 5 && hour < 12:
+		return "morning"
+	case hour >= 12 && hour < 18:
+		return "afternoon"
+	default:
+		return "evening"
+	}
+}
+
+// Greet renders the greeting for name in the best matching language,
+// formatted according to opts, and reports which language was used.
+func (g *Greeter) Greet(name, lang string, opts Options) (string, string, error) {
+	message, lang, err := g.render(name, lang, opts)
+	if err != nil {
+		return "", lang, err
+	}
+	return opts.format(message), lang, nil
+}
+
+// GreetMessage is Greet with a message template returned by ParseMessage
+// in place of the configured greeting. .Time is opts.At, or now.
+func (g *Greeter) GreetMessage(tmpl *template.Template, name, lang string, opts Options) (string, string, error) {
+	lang = g.catalog.Match(lang)
+	if name == "" {
+		name = g.catalog.T(lang, "greeting.default_name")
+	}
+	at := opts.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	message, err := executeMessage(tmpl, MessageData{Name: name, Time: at, Version: version.Get().Version})
+	if err != nil {
+		return "", lang, fmt.Errorf("failed to render message template: %w", err)
+	}
+	return opts.format(message), lang, nil
+}
+
+// format applies Shout and Repeat to message.
+func (opts Options) format(message string) string {
+	if opts.Shout {
+		message = strings.ToUpper(message)
+	}
+	if opts.Repeat > 1 {
+		message = strings.TrimSuffix(strings.Repeat(message+"\n", opts.Repeat), "\n")
+	}
+	return message
+}
+
+func (g *Greeter) render(name, lang string, opts Options) (string, string, error) {
+	lang = g.catalog.Match(lang)
+	if name == "" {
+		name = g.catalog.T(lang, "greeting.default_name")
+	}
+
+	if g.template == nil {
+		format := g.catalog.T(lang, "greeting")
+		if opts.TimeAware {
+			at := opts.At
+			if at.IsZero() {
+				at = time.Now()
+			}
+			if timed, ok := g.catalog.Lookup(lang, "greeting."+Period(at)); ok {
+				format = timed
+			}
+		}
+		return fmt.Sprintf(format, name), lang, nil
+	}
+
+	var buf bytes.Buffer
+	err := g.template.Execute(&buf, Data{
+		Name:    name,
+		Service: ServiceName,
+		Version: version.Get().Version,
+		Lang:    lang,
+	})
+	if err != nil {
+		return "", lang, fmt.Errorf("failed to render greeting: %w", err)
+	}
+
+	return buf.String(), lang, nil
+}
+
+// MessageData is the value a stored message is executed against when it is
+// used as the greeting template.
+type MessageData struct {
+	Name    string
+	Time    time.Time
+	Version string
+}
+
+// maxMessageOutput caps what a message template may render, since printf
+// can pad its output to a megabyte per call.
+const maxMessageOutput = 64 << 10
+
+// messageFuncs are the only functions a message template may call.
+var messageFuncs = map[string]bool{"printf": true, "print": true, "println": true}
+
+var errMessageOutput = errors.New("output is too long")
+
+// ParseMessage compiles a stored message as a greeting template. Messages
+// are set through the API rather than by the operator, so a message may
+// only use the fields of MessageData, if and with, and the printf, print
+// and println functions; other functions, loops and nested templates are
+// rejected.
+func ParseMessage(text string) (*template.Template, error) {
+	tmpl, err := template.New("message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message template: %w", err)
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, errors.New("invalid message template: templates cannot be defined")
+	}
+	if tmpl.Tree != nil {
+		if err := checkMessageNode(tmpl.Root); err != nil {
+			return nil, fmt.Errorf("invalid message template: %w", err)
+		}
+	}
+
+	sample := MessageData{Name: "World", Time: time.Now(), Version: "dev"}
+	if _, err := executeMessage(tmpl, sample); err != nil {
+		return nil, fmt.Errorf("invalid message template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// checkMessageNode rejects what ParseMessage does not allow in node and
+// the nodes below it.
+func checkMessageNode(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkMessageNode(child); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return checkMessageNode(n.Pipe)
+	case *parse.IfNode:
+		return checkMessageBranch(&n.BranchNode)
+	case *parse.WithNode:
+		return checkMessageBranch(&n.BranchNode)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := checkMessageNode(cmd); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if err := checkMessageNode(arg); err != nil {
+				return err
+			}
+		}
+	case *parse.ChainNode:
+		return checkMessageNode(n.Node)
+	case *parse.IdentifierNode:
+		if !messageFuncs[n.Ident] {
+			return fmt.Errorf("function %q is not allowed", n.Ident)
+		}
+	case *parse.RangeNode:
+		return errors.New("range is not allowed")
+	case *parse.TemplateNode:
+		return errors.New("templates cannot be included")
+	}
+	return nil
+}
+
+func checkMessageBranch(n *parse.BranchNode) error {
+	for _, child := range []parse.Node{n.Pipe, n.List, n.ElseList} {
+		if err := checkMessageNode(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executeMessage runs tmpl against data, failing once the output exceeds
+// maxMessageOutput.
+func executeMessage(tmpl *template.Template, data MessageData) (string, error) {
+	out := &limitedBuffer{limit: maxMessageOutput}
+	if err := tmpl.Execute(out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, errMessageOutput
+	}
+	return b.Buffer.Write(p)
+}
+
+// UsesTime reports whether tmpl, returned by ParseMessage, renders
+// differently at different times, so that what it renders for now cannot be
+// reused later.
+func UsesTime(tmpl *template.Template) bool {
+	data := MessageData{Name: "World", Version: "dev"}
+	data.Time = time.Date(2001, time.February, 3, 4, 5, 6, 7, time.UTC)
+	first, firstErr := executeMessage(tmpl, data)
+	data.Time = time.Date(2012, time.November, 24, 17, 38, 49, 500000000, time.FixedZone("X", 3600))
+	second, secondErr := executeMessage(tmpl, data)
+	return firstErr != nil || secondErr != nil || first != second
+}
+
+// MessageTemplate caches the template ParseMessage returns for the stored
+// message, and parses the message again only when it changes.
+type MessageTemplate struct {
+	mu       sync.Mutex
+	text     string
+	parsed   bool
+	tmpl     *template.Template
+	usesTime bool
+	err      error
+}
+
+// Parse returns the template for text, or why it is not a valid message
+// template.
+func (m *MessageTemplate) Parse(text string) (*template.Template, error) {
+	tmpl, _, err := m.ParseTimed(text)
+	return tmpl, err
+}
+
+// ParseTimed is Parse that also reports UsesTime of the template.
+func (m *MessageTemplate) ParseTimed(text string) (*template.Template, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.parsed || text != m.text {
+		m.tmpl, m.err = ParseMessage(text)
+		m.usesTime = m.err == nil && UsesTime(m.tmpl)
+		m.text, m.parsed = text, true
+	}
+	return m.tmpl, m.usesTime, m.err
+}
@@ -0,0 +1,250 @@
+package greeting
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/i18n"
+	"github.com/svanhalla/prompt-lab/greetd/internal/version"
+)
+
+func TestGreetCatalog(t *testing.T) {
+	g, err := New("", i18n.Default())
+	require.NoError(t, err)
+
+	message, lang, err := g.Greet("", "", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, World!", message)
+	assert.Equal(t, "en", lang)
+
+	message, lang, err = g.Greet("Anna", "sv", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "Hej, Anna!", message)
+	assert.Equal(t, "sv", lang)
+}
+
+func TestGreetTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		person   string
+		lang     string
+		expected string
+	}{
+		{"name and service", "Welcome to {{.Service}}, {{.Name}}!", "Ann", "", "Welcome to greetd, Ann!"},
+		{"version", "{{.Service}} {{.Version}} says hi to {{.Name}}", "Bo", "", "greetd " + version.Get().Version + " says hi to Bo"},
+		{"localized default name", "Hi {{.Name}} ({{.Lang}})", "", "de", "Hi Welt (de)"},
+		{"static text", "Howdy!", "Ann", "", "Howdy!"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := New(tt.template, i18n.Default())
+			require.NoError(t, err)
+
+			message, _, err := g.Greet(tt.person, tt.lang, Options{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, message)
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		contains string
+	}{
+		{"syntax error", "Hello, {{.Name", "invalid greeting template"},
+		{"unknown field", "Hello, {{.Nmae}}!", "Nmae"},
+		{"unknown function", "Hello, {{shout .Name}}!", "shout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.template)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.contains)
+
+			_, err = New(tt.template, i18n.Default())
+			assert.Error(t, err)
+		})
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestGreetOptionsGolden(t *testing.T) {
+	g, err := New("", i18n.Default())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		lang string
+		opts Options
+	}{
+		{"plain", "en", Options{}},
+		{"repeat", "en", Options{Repeat: 3}},
+		{"shout", "en", Options{Shout: true}},
+		{"repeat_shout", "en", Options{Repeat: 2, Shout: true}},
+		{"morning", "en", Options{TimeAware: true, At: clock(t, "08:30")}},
+		{"afternoon", "en", Options{TimeAware: true, At: clock(t, "12:00")}},
+		{"evening", "en", Options{TimeAware: true, At: clock(t, "23:15")}},
+		{"night", "en", Options{TimeAware: true, At: clock(t, "04:59")}},
+		{"morning_shout", "en", Options{TimeAware: true, Shout: true, At: clock(t, "06:00")}},
+		{"morning_repeat", "en", Options{TimeAware: true, Repeat: 2, At: clock(t, "06:00")}},
+		{"evening_repeat_shout", "en", Options{TimeAware: true, Repeat: 3, Shout: true, At: clock(t, "19:00")}},
+		{"evening_sv", "sv", Options{TimeAware: true, At: clock(t, "19:00")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, _, err := g.Greet("Ada", tt.lang, tt.opts)
+			require.NoError(t, err)
+
+			golden := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, []byte(message+"\n"), 0644))
+			}
+
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), message+"\n")
+		})
+	}
+}
+
+func TestGreetTimeAwareWithTemplate(t *testing.T) {
+	g, err := New("Welcome, {{.Name}}!", i18n.Default())
+	require.NoError(t, err)
+
+	message, _, err := g.Greet("Ada", "", Options{TimeAware: true, Shout: true, At: clock(t, "08:00")})
+	require.NoError(t, err)
+	assert.Equal(t, "WELCOME, ADA!", message)
+}
+
+func TestParseClock(t *testing.T) {
+	at, err := ParseClock("17:45")
+	require.NoError(t, err)
+	assert.Equal(t, "afternoon", Period(at))
+
+	for _, invalid := range []string{"", "25:00", "5pm", "12"} {
+		_, err := ParseClock(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func clock(t *testing.T, value string) time.Time {
+	t.Helper()
+	at, err := ParseClock(value)
+	require.NoError(t, err)
+	return at
+}
+
+func TestGreetMessage(t *testing.T) {
+	g, err := New("", i18n.Default())
+	require.NoError(t, err)
+	at := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		message  string
+		person   string
+		lang     string
+		opts     Options
+		expected string
+	}{
+		{"name", "Welcome back, {{.Name}} — lunch is at noon", "Ann", "", Options{At: at}, "Welcome back, Ann — lunch is at noon"},
+		{"default name", "Hi {{.Name}}", "", "sv", Options{At: at}, "Hi världen"},
+		{"plain text", "Lunch is at noon", "Ann", "", Options{At: at}, "Lunch is at noon"},
+		{"time and printf", `{{printf "%s at %s" .Name (.Time.Format "15:04")}}`, "Bo", "", Options{At: at}, "Bo at 12:00"},
+		{"version", "v{{.Version}}", "Bo", "", Options{At: at}, "v" + version.Get().Version},
+		{"condition", "{{if .Name}}Hi {{.Name}}{{else}}Hi{{end}}", "Cy", "", Options{At: at}, "Hi Cy"},
+		{"options", "Hi {{.Name}}", "Di", "", Options{At: at, Shout: true, Repeat: 2}, "HI DI\nHI DI"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseMessage(tt.message)
+			require.NoError(t, err)
+			message, _, err := g.GreetMessage(tmpl, tt.person, tt.lang, tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, message)
+		})
+	}
+}
+
+func TestParseMessageInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		contains string
+	}{
+		{"syntax error", "Hello, {{.Name", "invalid message template"},
+		{"unknown field", "Hello, {{.Service}}!", "Service"},
+		{"builtin function", `{{index .Name 0}}`, `function "index" is not allowed`},
+		{"call", `{{call .Name}}`, `function "call" is not allowed`},
+		{"function in condition", `{{if eq .Name "Ann"}}Hi{{end}}`, `function "eq" is not allowed`},
+		{"range", "{{range 3}}spam{{end}}", "range is not allowed"},
+		{"define", `{{define "x"}}hi{{end}}{{.Name}}`, "templates cannot be defined"},
+		{"include", `{{template "message" .}}`, "templates cannot be included"},
+		{"output limit", `{{printf "%100000s" .Name}}`, "output is too long"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMessage(tt.message)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.contains)
+		})
+	}
+}
+
+func TestMessageTemplateCache(t *testing.T) {
+	var cache MessageTemplate
+
+	first, err := cache.Parse("Hi {{.Name}}")
+	require.NoError(t, err)
+	again, err := cache.Parse("Hi {{.Name}}")
+	require.NoError(t, err)
+	assert.Same(t, first, again)
+
+	_, err = cache.Parse("Hi {{.Name")
+	assert.Error(t, err)
+
+	changed, err := cache.Parse("Bye {{.Name}}")
+	require.NoError(t, err)
+	assert.NotSame(t, first, changed)
+}
+
+func TestUsesTime(t *testing.T) {
+	tests := []struct {
+		message string
+		want    bool
+	}{
+		{"Hi {{.Name}}", false},
+		{"Version {{.Version}}", false},
+		{`It is {{.Time.Format "15:04"}}`, true},
+		{"{{with .Time}}{{.Year}}{{end}}", true},
+		{"{{.}}", true},
+		{"{{if .Time.IsZero}}never{{end}}", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			tmpl, err := ParseMessage(tt.message)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, UsesTime(tmpl))
+
+			var cache MessageTemplate
+			_, usesTime, err := cache.ParseTimed(tt.message)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, usesTime)
+		})
+	}
+}
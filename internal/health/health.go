@@ -0,0 +1,49 @@
+// Package health defines the checks backends register for the readiness
+// endpoint and the checks of /health.
+package health
+
+import (
+	"context"
+	"errors"
+)
+
+// Checker checks a dependency of the server.
+type Checker interface {
+	// Name is the key of the check in the checks of /readyz and /health.
+	Name() string
+	// Check returns nil when the dependency is usable, a Warning when it
+	// is usable with limitations, and any other error when it is not.
+	Check(ctx context.Context) error
+}
+
+// Warning is a Check error that leaves the server ready, such as storage
+// that is read-only while everything else still works.
+type Warning string
+
+func (w Warning) Error() string {
+	return string(w)
+}
+
+// IsWarning reports whether err is a Warning.
+func IsWarning(err error) bool {
+	var w Warning
+	return errors.As(err, &w)
+}
+
+// CheckFunc returns a Checker named name that calls check.
+func CheckFunc(name string, check func(ctx context.Context) error) Checker {
+	return checkFunc{name: name, check: check}
+}
+
+type checkFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (c checkFunc) Name() string {
+	return c.name
+}
+
+func (c checkFunc) Check(ctx context.Context) error {
+	return c.check(ctx)
+}
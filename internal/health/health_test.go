@@ -0,0 +1,24 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckFunc(t *testing.T) {
+	check := CheckFunc("queue", func(ctx context.Context) error {
+		return Warning("backlog of 1200 deliveries")
+	})
+	assert.Equal(t, "queue", check.Name())
+
+	err := check.Check(context.Background())
+	assert.EqualError(t, err, "backlog of 1200 deliveries")
+	assert.True(t, IsWarning(err))
+	assert.True(t, IsWarning(fmt.Errorf("queue: %w", err)))
+	assert.False(t, IsWarning(errors.New("connection refused")))
+	assert.False(t, IsWarning(nil))
+}
@@ -0,0 +1,107 @@
+// Package httpclient builds the HTTP clients greetd makes outbound calls
+// with, so every caller goes through the same proxy and trusts the same
+// certificates.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Options configure an outbound client.
+type Options struct {
+	// ProxyURL is the proxy every request goes through, such as
+	// "http://proxy.example.com:3128", except for hosts listed in NO_PROXY
+	// and loopback addresses. Empty uses HTTP_PROXY, HTTPS_PROXY and
+	// NO_PROXY from the environment.
+	ProxyURL string
+	// Timeout bounds each request, including reading the response body;
+	// zero means no timeout.
+	Timeout time.Duration
+	// TLSSkipVerify accepts any server certificate. Only for testing.
+	TLSSkipVerify bool
+	// CAFile is a PEM bundle of certificates trusted in addition to the
+	// system ones.
+	CAFile string
+}
+
+// New returns a client for opts. It fails when the proxy URL is malformed
+// or the CA bundle cannot be read or holds no certificates.
+func New(opts Options) (*http.Client, error) {
+	proxy, err := Proxy(opts.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	if opts.TLSSkipVerify || opts.CAFile != "" {
+		transport.TLSClientConfig = &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: opts.TLSSkipVerify,
+		}
+	}
+	if opts.CAFile != "" {
+		pool, err := LoadCAFile(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	return &http.Client{Transport: transport, Timeout: opts.Timeout}, nil
+}
+
+// Proxy returns the proxy selection for proxyURL: the environment's when
+// it is empty, and otherwise proxyURL for every request except to the hosts
+// in NO_PROXY and to loopback addresses.
+func Proxy(proxyURL string) (func(*http.Request) (*url.URL, error), error) {
+	if proxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", proxyURL)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http, https or socks5", proxyURL)
+	}
+
+	noProxy := os.Getenv("NO_PROXY")
+	if noProxy == "" {
+		noProxy = os.Getenv("no_proxy")
+	}
+	proxy := (&httpproxy.Config{HTTPProxy: proxyURL, HTTPSProxy: proxyURL, NoProxy: noProxy}).ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}, nil
+}
+
+// LoadCAFile returns the system certificate pool with the PEM certificates
+// of file added.
+func LoadCAFile(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("failed to read CA file: " + file + " holds no PEM certificates")
+	}
+	return pool, nil
+}
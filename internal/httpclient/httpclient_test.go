@@ -0,0 +1,114 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http://env-proxy.example.com:8080")
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("NO_PROXY", "internal.example.com")
+
+	tests := []struct {
+		name     string
+		proxyURL string
+		target   string
+		want     string
+	}{
+		{"configured", "http://proxy.example.com:3128", "http://greetd.example.com/hello", "http://proxy.example.com:3128"},
+		{"configured for HTTPS", "http://proxy.example.com:3128", "https://greetd.example.com/hello", "http://proxy.example.com:3128"},
+		{"configured, NO_PROXY host", "http://proxy.example.com:3128", "http://internal.example.com/hello", ""},
+		{"configured, loopback", "http://proxy.example.com:3128", "http://127.0.0.1:8080/hello", ""},
+		{"configured, localhost", "http://proxy.example.com:3128", "http://localhost:8080/hello", ""},
+		{"environment", "", "http://greetd.example.com/hello", "http://env-proxy.example.com:8080"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy, err := Proxy(tt.proxyURL)
+			require.NoError(t, err)
+
+			got, err := proxy(httptest.NewRequest(http.MethodGet, tt.target, nil))
+			require.NoError(t, err)
+			if tt.want == "" {
+				assert.Nil(t, got)
+				return
+			}
+			require.NotNil(t, got)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+
+	for _, invalid := range []string{"proxy.example.com:3128", "ftp://proxy.example.com", "http://", "://"} {
+		_, err := Proxy(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestNewProxy(t *testing.T) {
+	var requested string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+		io.WriteString(w, "via proxy")
+	}))
+	defer proxy.Close()
+
+	client, err := New(Options{ProxyURL: proxy.URL, Timeout: 5 * time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, client.Timeout)
+
+	resp, err := client.Get("http://greetd.example.com/api/v1/health")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "via proxy", string(body))
+	assert.Equal(t, "http://greetd.example.com/api/v1/health", requested)
+}
+
+func TestNewCAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	get := func(opts Options) error {
+		client, err := New(opts)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	// The test server's certificate is not trusted by default.
+	assert.Error(t, get(Options{}))
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, cert, 0o600))
+	assert.NoError(t, get(Options{CAFile: caFile}))
+
+	assert.NoError(t, get(Options{TLSSkipVerify: true}))
+
+	_, err := New(Options{CAFile: filepath.Join(dir, "missing.pem")})
+	assert.ErrorContains(t, err, "failed to read CA file")
+
+	empty := filepath.Join(dir, "empty.pem")
+	require.NoError(t, os.WriteFile(empty, []byte("not a certificate\n"), 0o600))
+	_, err = LoadCAFile(empty)
+	assert.ErrorContains(t, err, "holds no PEM certificates")
+}
@@ -0,0 +1,206 @@
+package i18n
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLang is used whenever a requested language is not in the catalog.
+const DefaultLang = "en"
+
+//go:embed catalog.yaml
+var builtinCatalog []byte
+
+// Catalog maps language codes to translated strings.
+type Catalog struct {
+	langs map[string]map[string]string
+}
+
+// Default returns the catalog embedded in the binary.
+func Default() *Catalog {
+	c, err := parse(builtinCatalog)
+	if err != nil {
+		panic(fmt.Sprintf("i18n: invalid built-in catalog: %v", err))
+	}
+	return c
+}
+
+// Load returns the built-in catalog merged with the user-supplied file at
+// path. Entries in the file override or add languages and keys. An empty
+// path returns the built-in catalog unchanged.
+func Load(path string) (*Catalog, error) {
+	c := Default()
+	if path == "" {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+
+	override, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid catalog %s: %w", path, err)
+	}
+
+	c.merge(override.langs)
+	return c, nil
+}
+
+// merge adds langs to the catalog, replacing existing keys.
+func (c *Catalog) merge(langs map[string]map[string]string) {
+	for lang, entries := range langs {
+		if c.langs[lang] == nil {
+			c.langs[lang] = make(map[string]string)
+		}
+		for key, value := range entries {
+			c.langs[lang][key] = value
+		}
+	}
+}
+
+// greetingKeys are the catalog entries that take the name as their only
+// placeholder.
+var greetingKeys = []string{"greeting", "greeting.morning", "greeting.afternoon", "greeting.evening"}
+
+func parse(data []byte) (*Catalog, error) {
+	var langs map[string]map[string]string
+	if err := yaml.Unmarshal(data, &langs); err != nil {
+		return nil, err
+	}
+
+	normalized := make(map[string]map[string]string, len(langs))
+	for lang, entries := range langs {
+		for _, key := range greetingKeys {
+			if greeting, ok := entries[key]; ok && strings.Count(greeting, "%s") != 1 {
+				return nil, fmt.Errorf("%s for %q must contain exactly one %%s", key, lang)
+			}
+		}
+		normalized[strings.ToLower(lang)] = entries
+	}
+
+	return &Catalog{langs: normalized}, nil
+}
+
+// Languages returns the sorted list of language codes in the catalog.
+func (c *Catalog) Languages() []string {
+	langs := make([]string, 0, len(c.langs))
+	for lang := range c.langs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Match returns the first candidate available in the catalog. Region
+// subtags are tried with and without the region ("sv-SE" matches "sv").
+// DefaultLang is returned when nothing matches.
+func (c *Catalog) Match(candidates ...string) string {
+	for _, candidate := range candidates {
+		if lang, ok := c.MatchTag(candidate); ok {
+			return lang
+		}
+	}
+	return DefaultLang
+}
+
+// MatchTag returns the catalog language for a single language tag, with or
+// without its region, and whether the catalog has one.
+func (c *Catalog) MatchTag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if tag == "" {
+		return "", false
+	}
+	if _, ok := c.langs[tag]; ok {
+		return tag, true
+	}
+	if base, _, ok := strings.Cut(tag, "-"); ok {
+		if _, ok := c.langs[base]; ok {
+			return base, true
+		}
+	}
+	return "", false
+}
+
+// T looks up key for lang, falling back to DefaultLang and finally to the key itself.
+func (c *Catalog) T(lang, key string) string {
+	if value, ok := c.langs[lang][key]; ok {
+		return value
+	}
+	if value, ok := c.langs[DefaultLang][key]; ok {
+		return value
+	}
+	return key
+}
+
+// Tr is T with the entry used as a format for args, as with fmt.Sprintf.
+func (c *Catalog) Tr(lang, key string, args ...interface{}) string {
+	if len(args) == 0 {
+		return c.T(lang, key)
+	}
+	return fmt.Sprintf(c.T(lang, key), args...)
+}
+
+// Lookup returns the entry for key in lang without falling back to another
+// language.
+func (c *Catalog) Lookup(lang, key string) (string, bool) {
+	value, ok := c.langs[lang][key]
+	return value, ok
+}
+
+// Greeting renders the greeting for name in the best matching language and
+// reports which language was used. An empty name uses the localized default.
+func (c *Catalog) Greeting(lang, name string) (string, string) {
+	lang = c.Match(lang)
+	if name == "" {
+		name = c.T(lang, "greeting.default_name")
+	}
+	return fmt.Sprintf(c.T(lang, "greeting"), name), lang
+}
+
+// ParseAcceptLanguage returns the language tags of an Accept-Language header
+// ordered by preference.
+func ParseAcceptLanguage(header string) []string {
+	type tagQ struct {
+		tag string
+		q   float64
+	}
+
+	var tags []tagQ
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(key) == "q" {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		tags = append(tags, tagQ{tag: tag, q: q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
@@ -0,0 +1,152 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultCatalog(t *testing.T) {
+	c := Default()
+
+	assert.Subset(t, c.Languages(), []string{"en", "sv", "de", "fr", "es", "ja"})
+
+	greeting, lang := c.Greeting("en", "")
+	assert.Equal(t, "Hello, World!", greeting)
+	assert.Equal(t, "en", lang)
+
+	greeting, lang = c.Greeting("sv", "Anna")
+	assert.Equal(t, "Hej, Anna!", greeting)
+	assert.Equal(t, "sv", lang)
+}
+
+func TestMatch(t *testing.T) {
+	c := Default()
+
+	tests := []struct {
+		name       string
+		candidates []string
+		expected   string
+	}{
+		{"exact", []string{"de"}, "de"},
+		{"case insensitive", []string{"SV"}, "sv"},
+		{"region subtag", []string{"sv-SE"}, "sv"},
+		{"underscore region", []string{"fr_CA"}, "fr"},
+		{"first available wins", []string{"xx", "", "ja", "de"}, "ja"},
+		{"nothing matches", []string{"xx", "yy"}, DefaultLang},
+		{"no candidates", nil, DefaultLang},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, c.Match(tt.candidates...))
+		})
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.yaml")
+	content := `sv:
+  greeting: "Tjena, %s!"
+fi:
+  greeting: "Hei, %s!"
+  greeting.default_name: "maailma"
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	c, err := Load(path)
+	require.NoError(t, err)
+
+	greeting, _ := c.Greeting("sv", "Anna")
+	assert.Equal(t, "Tjena, Anna!", greeting)
+
+	greeting, _ = c.Greeting("sv", "")
+	assert.Equal(t, "Tjena, världen!", greeting, "keys missing from the override keep the built-in value")
+
+	greeting, lang := c.Greeting("fi", "")
+	assert.Equal(t, "Hei, maailma!", greeting)
+	assert.Equal(t, "fi", lang)
+
+	greeting, _ = c.Greeting("en", "Ann")
+	assert.Equal(t, "Hello, Ann!", greeting)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := Load(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+
+	path := filepath.Join(dir, "bad.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`en:
+  greeting: "Hello!"
+`), 0644))
+	_, err = Load(path)
+	assert.Error(t, err)
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	assert.Equal(t, []string{"sv-SE", "sv", "en"}, ParseAcceptLanguage("sv-SE,sv;q=0.9,en;q=0.8"))
+	assert.Equal(t, []string{"sv", "de"}, ParseAcceptLanguage("de;q=0.5, sv, *;q=0.1"))
+	assert.Equal(t, []string{"en"}, ParseAcceptLanguage("fr;q=0, en"))
+	assert.Empty(t, ParseAcceptLanguage(""))
+}
+
+func TestMatchTag(t *testing.T) {
+	c := DefaultLocales()
+
+	lang, ok := c.MatchTag("sv-SE")
+	assert.True(t, ok)
+	assert.Equal(t, "sv", lang)
+
+	_, ok = c.MatchTag("xx")
+	assert.False(t, ok)
+
+	_, ok = c.MatchTag("")
+	assert.False(t, ok)
+}
+
+func TestDefaultLocales(t *testing.T) {
+	c := DefaultLocales()
+
+	assert.Equal(t, []string{"en", "sv"}, c.Languages())
+	assert.Equal(t, "Loggar", c.T("sv", "nav.logs"))
+	assert.Equal(t, "Högst 10 tecken", c.Tr("sv", "ui.max_length", 10))
+
+	// Every key has an English entry to fall back on.
+	for _, lang := range c.Languages() {
+		for key := range c.langs[lang] {
+			_, ok := c.Lookup(DefaultLang, key)
+			assert.True(t, ok, "%s has %s, which is missing from %s", lang, key, DefaultLang)
+		}
+	}
+}
+
+func TestLoadLocales(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sv.yaml"), []byte(`nav.logs: "Loggbok"`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fi.yaml"), []byte(`language.name: "Suomi"
+nav.logs: "Lokit"
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a locale"), 0644))
+
+	c, err := LoadLocales(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"en", "fi", "sv"}, c.Languages())
+	assert.Equal(t, "Loggbok", c.T("sv", "nav.logs"))
+	assert.Equal(t, "Hälsa", c.T("sv", "nav.health"), "keys missing from the override keep the built-in value")
+	assert.Equal(t, "Lokit", c.T("fi", "nav.logs"))
+	assert.Equal(t, "Health", c.T("fi", "nav.health"), "missing keys fall back to English")
+	assert.Equal(t, "no.such.key", c.T("fi", "no.such.key"))
+
+	_, err = LoadLocales(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "de.yaml"), []byte("nav: [not, a, string]"), 0644))
+	_, err = LoadLocales(dir)
+	assert.Error(t, err)
+}
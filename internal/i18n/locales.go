@@ -0,0 +1,80 @@
+package i18n
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed locales/*.yaml
+var builtinLocales embed.FS
+
+// localeExt is the extension of the per-language files of the UI strings.
+const localeExt = ".yaml"
+
+// DefaultLocales returns the strings of the HTML pages embedded in the
+// binary. They form a catalog of their own, separate from the greetings,
+// with one file per language in locales/.
+func DefaultLocales() *Catalog {
+	entries, err := builtinLocales.ReadDir("locales")
+	if err != nil {
+		panic(fmt.Sprintf("i18n: missing built-in locales: %v", err))
+	}
+
+	c := &Catalog{langs: make(map[string]map[string]string)}
+	for _, entry := range entries {
+		data, err := builtinLocales.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("i18n: missing built-in locale %s: %v", entry.Name(), err))
+		}
+		if err := c.addLocale(entry.Name(), data); err != nil {
+			panic(fmt.Sprintf("i18n: invalid built-in locale: %v", err))
+		}
+	}
+	return c
+}
+
+// LoadLocales returns the built-in UI strings merged with the files in dir,
+// each named after its language, such as sv.yaml. Entries in the files
+// override or add languages and keys. An empty dir returns the built-in
+// strings unchanged.
+func LoadLocales(dir string) (*Catalog, error) {
+	c := DefaultLocales()
+	if dir == "" {
+		return c, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read locales: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != localeExt {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read locale: %w", err)
+		}
+		if err := c.addLocale(entry.Name(), data); err != nil {
+			return nil, fmt.Errorf("invalid locale %s: %w", filepath.Join(dir, entry.Name()), err)
+		}
+	}
+	return c, nil
+}
+
+// addLocale merges the keys of the locale file name into the catalog.
+func (c *Catalog) addLocale(name string, data []byte) error {
+	var entries map[string]string
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	lang := strings.ToLower(strings.TrimSuffix(name, localeExt))
+	c.merge(map[string]map[string]string{lang: entries})
+	return nil
+}
@@ -0,0 +1,63 @@
+// Package lockfile provides advisory file locks, so greetd processes sharing
+// a data directory, such as the API server and "greetd set message", take
+// turns changing it. The locks only exclude other processes that take them
+// too; they do not stop other programs from writing the files.
+package lockfile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pollInterval is how often Acquire retries a lock held by someone else.
+const pollInterval = 10 * time.Millisecond
+
+// Lock is an exclusive lock on a file, held until Release.
+type Lock struct {
+	file *os.File
+}
+
+// Acquire waits until it holds an exclusive lock on path, creating the file
+// and its directory if needed. It gives up with the context's error once
+// ctx is done. The lock is also released when the process exits.
+func Acquire(ctx context.Context, path string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		locked, err := tryLock(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if locked {
+			return &Lock{file: file}, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			file.Close()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Release gives up the lock. The lock file is left in place, since removing
+// it could let two processes lock different files under the same name.
+func (l *Lock) Release() error {
+	if err := unlock(l.file); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,54 @@
+package lockfile
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquire(t *testing.T) {
+	switch runtime.GOOS {
+	case "linux", "darwin", "freebsd", "windows":
+	default:
+		t.Skip("file locks are not supported on " + runtime.GOOS)
+	}
+
+	path := filepath.Join(t.TempDir(), "data", "messages.json.lock")
+
+	lock, err := Acquire(context.Background(), path)
+	require.NoError(t, err)
+	assert.FileExists(t, path)
+
+	// A second holder waits until the context gives up.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = Acquire(ctx, path)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	acquired := make(chan *Lock)
+	go func() {
+		second, err := Acquire(context.Background(), path)
+		assert.NoError(t, err)
+		acquired <- second
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("the lock was acquired while held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, lock.Release())
+	select {
+	case second := <-acquired:
+		require.NotNil(t, second)
+		assert.NoError(t, second.Release())
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lock was not acquired after its release")
+	}
+}
@@ -0,0 +1,147 @@
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultBufferSize is the number of entries kept by a RingBuffer by default.
+const DefaultBufferSize = 500
+
+// Entry is a log entry retained in memory, including its structured fields.
+type Entry struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// String formats the entry as a single line for display.
+func (e Entry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s [%s] %s", e.Time.Format(time.RFC3339), strings.ToUpper(e.Level), e.Message)
+
+	keys := make([]string, 0, len(e.Fields))
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%v", key, e.Fields[key])
+	}
+
+	return b.String()
+}
+
+// RingBuffer is a logrus hook that keeps the most recent entries in memory.
+// It is safe for concurrent use.
+type RingBuffer struct {
+	mu          sync.RWMutex
+	entries     []Entry
+	next        int
+	full        bool
+	subscribers map[chan Entry]struct{}
+}
+
+// NewRingBuffer returns a buffer holding up to size entries. A size of zero
+// or less uses DefaultBufferSize.
+func NewRingBuffer(size int) *RingBuffer {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &RingBuffer{
+		entries:     make([]Entry, size),
+		subscribers: make(map[chan Entry]struct{}),
+	}
+}
+
+// Levels implements logrus.Hook.
+func (b *RingBuffer) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook.
+func (b *RingBuffer) Fire(e *logrus.Entry) error {
+	entry := Entry{
+		Time:    e.Time,
+		Level:   e.Level.String(),
+		Message: e.Message,
+	}
+	if len(e.Data) > 0 {
+		entry.Fields = make(map[string]interface{}, len(e.Data))
+		for key, value := range e.Data {
+			if err, ok := value.(error); ok {
+				value = err.Error()
+			}
+			entry.Fields[key] = value
+		}
+	}
+
+	b.mu.Lock()
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	if b.next == 0 {
+		b.full = true
+	}
+	for ch := range b.subscribers {
+		select {
+		case ch <- entry:
+		default:
+			// The subscriber is not keeping up; drop rather than block logging.
+		}
+	}
+	b.mu.Unlock()
+
+	return nil
+}
+
+// Subscribe returns a channel receiving entries logged from now on. At most
+// queue entries are held for a slow subscriber; further entries are dropped
+// until it catches up. Call cancel to unsubscribe and close the channel.
+func (b *RingBuffer) Subscribe(queue int) (entries <-chan Entry, cancel func()) {
+	ch := make(chan Entry, queue)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Entries returns the buffered entries, oldest first.
+func (b *RingBuffer) Entries() []Entry {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if !b.full {
+		return append([]Entry(nil), b.entries[:b.next]...)
+	}
+
+	result := make([]Entry, 0, len(b.entries))
+	result = append(result, b.entries[b.next:]...)
+	return append(result, b.entries[:b.next]...)
+}
+
+// Len returns the number of buffered entries.
+func (b *RingBuffer) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.full {
+		return len(b.entries)
+	}
+	return b.next
+}
@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newBufferedLogger(size int) (*logrus.Logger, *RingBuffer) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	buffer := NewRingBuffer(size)
+	logger.AddHook(buffer)
+	return logger, buffer
+}
+
+func TestRingBufferKeepsMostRecent(t *testing.T) {
+	logger, buffer := newBufferedLogger(3)
+
+	for i := 1; i <= 5; i++ {
+		logger.Infof("entry %d", i)
+	}
+
+	entries := buffer.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("len(Entries()) = %d, want 3", len(entries))
+	}
+	for i, want := range []string{"entry 3", "entry 4", "entry 5"} {
+		if entries[i].Message != want {
+			t.Errorf("Entries()[%d].Message = %q, want %q", i, entries[i].Message, want)
+		}
+	}
+}
+
+func TestRingBufferRetainsFields(t *testing.T) {
+	logger, buffer := newBufferedLogger(10)
+
+	logger.WithError(errors.New("boom")).WithField("status", 500).Warn("request failed")
+
+	entries := buffer.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("len(Entries()) = %d, want 1", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.Level != "warning" {
+		t.Errorf("Level = %q, want warning", entry.Level)
+	}
+	if entry.Fields["status"] != 500 {
+		t.Errorf("Fields[status] = %v, want 500", entry.Fields["status"])
+	}
+	if entry.Fields["error"] != "boom" {
+		t.Errorf("Fields[error] = %v, want boom", entry.Fields["error"])
+	}
+	if got := entry.String(); got != entry.Time.Format("2006-01-02T15:04:05Z07:00")+" [WARNING] request failed error=boom status=500" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestRingBufferConcurrentUse(t *testing.T) {
+	logger, buffer := newBufferedLogger(50)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Info(fmt.Sprintf("worker %d entry %d", i, j))
+				buffer.Entries()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if buffer.Len() != 50 {
+		t.Errorf("Len() = %d, want 50", buffer.Len())
+	}
+}
+
+func TestRingBufferSubscribe(t *testing.T) {
+	logger, buffer := newBufferedLogger(10)
+
+	logger.Info("before subscribing")
+	entries, cancel := buffer.Subscribe(2)
+
+	logger.Info("first")
+	logger.Info("second")
+	logger.Info("dropped while the queue is full")
+
+	for _, want := range []string{"first", "second"} {
+		if got := (<-entries).Message; got != want {
+			t.Errorf("received %q, want %q", got, want)
+		}
+	}
+
+	cancel()
+	cancel() // safe to call twice
+	if _, ok := <-entries; ok {
+		t.Error("channel should be closed after cancel")
+	}
+
+	// Logging after unsubscribing must not block or panic.
+	logger.Info("after cancel")
+	if buffer.Len() != 5 {
+		t.Errorf("Len() = %d, want 5", buffer.Len())
+	}
+}
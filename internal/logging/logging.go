@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -9,7 +10,19 @@ import (
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-func Setup(level, format, dataPath string) (*logrus.Logger, error) {
+// Log outputs accepted by Setup.
+const (
+	OutputStdout = "stdout"
+	OutputFile   = "file"
+	OutputBoth   = "both"
+)
+
+// FileName is the application log file created under the data path.
+const FileName = "app.log"
+
+// Setup creates the application logger. output selects stdout, the rotating
+// app.log under dataPath, or both; with OutputStdout no file is created.
+func Setup(level, format, output, dataPath string) (*logrus.Logger, error) {
 	logger := logrus.New()
 
 	// Set log level
@@ -28,18 +41,84 @@ func Setup(level, format, dataPath string) (*logrus.Logger, error) {
 		})
 	}
 
+	if output == OutputStdout {
+		logger.SetOutput(os.Stdout)
+		return logger, nil
+	}
+
 	// Setup log file with rotation
-	logFile := &lumberjack.Logger{
-		Filename:   filepath.Join(dataPath, "app.log"),
-		MaxSize:    10, // MB
+	logFile := NewRotatingFile(filepath.Join(dataPath, FileName), Rotation{
+		MaxSize:    10,
 		MaxBackups: 3,
-		MaxAge:     28, // days
+		MaxAge:     28,
 		Compress:   true,
-	}
+	})
 
-	// Write to both stdout and file
-	multiWriter := io.MultiWriter(os.Stdout, logFile)
-	logger.SetOutput(multiWriter)
+	switch output {
+	case OutputFile:
+		logger.SetOutput(&appLog{Writer: logFile, file: logFile})
+	case OutputBoth, "":
+		logger.SetOutput(&appLog{Writer: io.MultiWriter(os.Stdout, logFile), file: logFile})
+	default:
+		return nil, fmt.Errorf("invalid log output %q: must be stdout, file or both", output)
+	}
 
 	return logger, nil
 }
+
+// appLog is the output of a logger that Setup pointed at app.log, so
+// Rotate can find the file.
+type appLog struct {
+	io.Writer
+	file RotatingFile
+}
+
+// Rotate starts a new app.log for a logger from Setup, keeping the current
+// one as a backup as rotating by size does. Loggers that do not write to
+// app.log are left alone.
+func Rotate(logger *logrus.Logger) error {
+	if out, ok := logger.Out.(*appLog); ok {
+		return out.file.Rotate()
+	}
+	return nil
+}
+
+// RemoveHook removes hook from logger, which logrus has no method for. The
+// other hooks are kept in order.
+func RemoveHook(logger *logrus.Logger, hook logrus.Hook) {
+	hooks := make(logrus.LevelHooks)
+	for level, fired := range logger.Hooks {
+		for _, h := range fired {
+			if h != hook {
+				hooks[level] = append(hooks[level], h)
+			}
+		}
+	}
+	logger.ReplaceHooks(hooks)
+}
+
+// Rotation configures size- and age-based log file rotation.
+type Rotation struct {
+	MaxSize    int // MB
+	MaxBackups int
+	MaxAge     int // days
+	Compress   bool
+}
+
+// RotatingFile is a log file that rotates by size and age, and on demand
+// with Rotate.
+type RotatingFile interface {
+	io.WriteCloser
+	Rotate() error
+}
+
+// NewRotatingFile returns a writer for path that rotates according to r.
+func NewRotatingFile(path string, r Rotation) RotatingFile {
+	return &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    r.MaxSize,
+		MaxBackups: r.MaxBackups,
+		MaxAge:     r.MaxAge,
+		Compress:   r.Compress,
+	}
+}
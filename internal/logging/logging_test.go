@@ -1,7 +1,11 @@
 package logging
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSetup(t *testing.T) {
@@ -20,7 +24,7 @@ func TestSetup(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			logger, err := Setup(tt.level, tt.format, tt.dataPath)
+			logger, err := Setup(tt.level, tt.format, OutputBoth, tt.dataPath)
 			if err != nil {
 				t.Fatalf("Setup failed: %v", err)
 			}
@@ -37,7 +41,7 @@ func TestSetup(t *testing.T) {
 func TestSetupInvalidLevel(t *testing.T) {
 	tmpDir := t.TempDir()
 
-	logger, err := Setup("invalid", "text", tmpDir)
+	logger, err := Setup("invalid", "text", OutputBoth, tmpDir)
 	if err == nil {
 		t.Error("Setup should fail with invalid level")
 	}
@@ -45,3 +49,78 @@ func TestSetupInvalidLevel(t *testing.T) {
 		t.Error("Setup should return nil logger on error")
 	}
 }
+
+func TestSetupOutput(t *testing.T) {
+	tests := []struct {
+		output   string
+		wantFile bool
+	}{
+		{OutputStdout, false},
+		{OutputFile, true},
+		{OutputBoth, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			tmpDir := t.TempDir()
+
+			logger, err := Setup("info", "text", tt.output, tmpDir)
+			if err != nil {
+				t.Fatalf("Setup failed: %v", err)
+			}
+			logger.Info("test message")
+
+			_, err = os.Stat(filepath.Join(tmpDir, FileName))
+			if exists := err == nil; exists != tt.wantFile {
+				t.Errorf("app.log exists = %v, want %v", exists, tt.wantFile)
+			}
+		})
+	}
+}
+
+func TestSetupInvalidOutput(t *testing.T) {
+	if _, err := Setup("info", "text", "syslog", t.TempDir()); err == nil {
+		t.Error("Setup should fail with invalid output")
+	}
+}
+
+func TestRotate(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	logger, err := Setup("info", "text", OutputFile, tmpDir)
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	logger.Info("before rotation")
+	if err := Rotate(logger); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	logger.Info("after rotation")
+
+	// The backup is compressed in the background.
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		compressed, _ := filepath.Glob(filepath.Join(tmpDir, "app-*.log.gz"))
+		plain, _ := filepath.Glob(filepath.Join(tmpDir, "app-*.log"))
+		if len(compressed) == 1 && len(plain) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("backups = %v %v, want one compressed", compressed, plain)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, FileName))
+	if err != nil {
+		t.Fatalf("reading app.log: %v", err)
+	}
+	if strings.Contains(string(data), "before rotation") || !strings.Contains(string(data), "after rotation") {
+		t.Errorf("app.log = %q, want only the entry after rotation", data)
+	}
+
+	stdout, err := Setup("info", "text", OutputStdout, tmpDir)
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if err := Rotate(stdout); err != nil {
+		t.Errorf("Rotate of a stdout logger failed: %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// APIKeysFileName is the file the keys managed with greetd apikey are
+// persisted to.
+const APIKeysFileName = "apikeys.json"
+
+// apiKeyPrefix starts every generated key, so leaked keys are easy to spot.
+const apiKeyPrefix = "greetd_"
+
+var (
+	// ErrAPIKeyExists is returned when creating a key under a name in use.
+	ErrAPIKeyExists = errors.New("an API key with this name already exists")
+	// ErrAPIKeyNotFound is returned when revoking a name without a key.
+	ErrAPIKeyNotFound = errors.New("no API key with this name")
+)
+
+// APIKey is a managed key as persisted: its name and a salted SHA-256 hash,
+// never the key itself.
+type APIKey struct {
+	Name      string    `json:"name"`
+	Salt      string    `json:"salt"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// matches reports whether key hashes to k.
+func (k APIKey) matches(key string) bool {
+	salt, err := hex.DecodeString(k.Salt)
+	if err != nil {
+		return false
+	}
+	want, err := hex.DecodeString(k.Hash)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(hashAPIKey(salt, key), want) == 1
+}
+
+func hashAPIKey(salt []byte, key string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(key))
+	return h.Sum(nil)
+}
+
+// APIKeys holds the managed API keys. The server rereads apikeys.json when
+// it changes, so keys created or revoked by greetd apikey apply without a
+// restart.
+type APIKeys struct {
+	mu       sync.RWMutex
+	filePath string
+	keys     []APIKey
+	// loaded describes the file the keys were read from, nil if there was
+	// none.
+	loaded os.FileInfo
+}
+
+// NewAPIKeys returns the keys kept in apikeys.json in dir, the state
+// directory. It has no keys until Load is called.
+func NewAPIKeys(dir string) *APIKeys {
+	return &APIKeys{filePath: filepath.Join(dir, APIKeysFileName)}
+}
+
+// Load reads the persisted keys. A missing file means no keys.
+func (a *APIKeys) Load() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.loadUnsafe()
+}
+
+func (a *APIKeys) loadUnsafe() error {
+	info, err := os.Stat(a.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		a.keys, a.loaded = nil, nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read API keys file: %w", err)
+	}
+
+	data, err := os.ReadFile(a.filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read API keys file: %w", err)
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("failed to unmarshal API keys: %w", err)
+	}
+	a.keys, a.loaded = keys, info
+	return nil
+}
+
+// Refresh rereads apikeys.json when it was replaced, created or removed
+// since it was last read, and reports whether it did. On error the keys
+// read before stay in place.
+func (a *APIKeys) Refresh() (bool, error) {
+	info, err := os.Stat(a.filePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("failed to read API keys file: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if info == nil && a.loaded == nil {
+		return false, nil
+	}
+	if info != nil && a.loaded != nil && os.SameFile(info, a.loaded) && info.Size() == a.loaded.Size() && info.ModTime().Equal(a.loaded.ModTime()) {
+		return false, nil
+	}
+	if err := a.loadUnsafe(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// List returns the keys sorted by name.
+func (a *APIKeys) List() []APIKey {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	keys := append([]APIKey(nil), a.keys...)
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys
+}
+
+// Len returns the number of keys.
+func (a *APIKeys) Len() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.keys)
+}
+
+// Match returns the name of the key that key is, or false.
+func (a *APIKeys) Match(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, k := range a.keys {
+		if k.matches(key) {
+			return k.Name, true
+		}
+	}
+	return "", false
+}
+
+// Create generates a key named name, persists its hash and returns the key.
+// The key cannot be recovered afterwards. Names follow the rules of message
+// keys.
+func (a *APIKeys) Create(name string) (string, error) {
+	if !ValidKey(name) {
+		return "", ErrInvalidKey
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// Another process may have changed the keys since they were read.
+	if err := a.loadUnsafe(); err != nil {
+		return "", err
+	}
+	for _, k := range a.keys {
+		if k.Name == name {
+			return "", fmt.Errorf("%w: %q", ErrAPIKeyExists, name)
+		}
+	}
+
+	secret := make([]byte, 24)
+	salt := make([]byte, 16)
+	rand.Read(secret)
+	rand.Read(salt)
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+
+	keys := append(append([]APIKey(nil), a.keys...), APIKey{
+		Name:      name,
+		Salt:      hex.EncodeToString(salt),
+		Hash:      hex.EncodeToString(hashAPIKey(salt, key)),
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	})
+	if err := a.saveUnsafe(keys); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// Revoke deletes the key named name.
+func (a *APIKeys) Revoke(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.loadUnsafe(); err != nil {
+		return err
+	}
+
+	keys := make([]APIKey, 0, len(a.keys))
+	for _, k := range a.keys {
+		if k.Name != name {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == len(a.keys) {
+		return fmt.Errorf("%w: %q", ErrAPIKeyNotFound, name)
+	}
+	return a.saveUnsafe(keys)
+}
+
+func (a *APIKeys) saveUnsafe(keys []APIKey) error {
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal API keys: %w", err)
+	}
+	if err := writeFileAtomic(a.filePath, data); err != nil {
+		return fmt.Errorf("failed to write API keys file: %w", err)
+	}
+	return a.loadUnsafe()
+}
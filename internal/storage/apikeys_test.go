@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	dataPath := t.TempDir()
+
+	keys := NewAPIKeys(dataPath)
+	require.NoError(t, keys.Load())
+	assert.Equal(t, 0, keys.Len())
+
+	key, err := keys.Create("ci-bot")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, apiKeyPrefix))
+
+	_, err = keys.Create("ci-bot")
+	assert.ErrorIs(t, err, ErrAPIKeyExists)
+	_, err = keys.Create("CI Bot")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	name, ok := keys.Match(key)
+	assert.True(t, ok)
+	assert.Equal(t, "ci-bot", name)
+	_, ok = keys.Match(key + "x")
+	assert.False(t, ok)
+
+	// Only the salted hash is persisted.
+	data, err := os.ReadFile(filepath.Join(dataPath, APIKeysFileName))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), key)
+
+	_, err = keys.Create("deploy")
+	require.NoError(t, err)
+	list := keys.List()
+	require.Len(t, list, 2)
+	assert.Equal(t, "ci-bot", list[0].Name)
+	assert.Equal(t, "deploy", list[1].Name)
+	assert.NotEqual(t, list[0].Salt, list[1].Salt)
+
+	require.NoError(t, keys.Revoke("ci-bot"))
+	assert.ErrorIs(t, keys.Revoke("ci-bot"), ErrAPIKeyNotFound)
+	_, ok = keys.Match(key)
+	assert.False(t, ok)
+	assert.Equal(t, 1, keys.Len())
+}
+
+func TestAPIKeysRefresh(t *testing.T) {
+	dataPath := t.TempDir()
+	server := NewAPIKeys(dataPath)
+	require.NoError(t, server.Load())
+
+	changed, err := server.Refresh()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// Another process, such as greetd apikey, changes the file.
+	cli := NewAPIKeys(dataPath)
+	key, err := cli.Create("ci-bot")
+	require.NoError(t, err)
+
+	changed, err = server.Refresh()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	name, ok := server.Match(key)
+	assert.True(t, ok)
+	assert.Equal(t, "ci-bot", name)
+
+	changed, err = server.Refresh()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	require.NoError(t, cli.Revoke("ci-bot"))
+	changed, err = server.Refresh()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	_, ok = server.Match(key)
+	assert.False(t, ok)
+
+	// A broken file keeps the keys read before.
+	_, err = cli.Create("deploy")
+	require.NoError(t, err)
+	_, err = server.Refresh()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dataPath, APIKeysFileName), []byte("{"), 0644))
+	_, err = server.Refresh()
+	assert.Error(t, err)
+	assert.Equal(t, 1, server.Len())
+}
@@ -0,0 +1,279 @@
+package storage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Names of the files in a backup archive besides MessagesFileName and
+// StatsFileName.
+const (
+	BackupConfigName    = "config.json"
+	BackupAppLogName    = "logs/app.log"
+	BackupAccessLogName = "logs/access.log"
+)
+
+// maxBackupEntrySize bounds each file read from an archive, so a
+// malicious one cannot exhaust memory.
+const maxBackupEntrySize = 256 << 20
+
+// BackupOptions names the files that go into a backup, or are written by a
+// restore, besides the state files in the data directory.
+type BackupOptions struct {
+	// ConfigPath is the config file; "" leaves it out of a backup.
+	ConfigPath string
+	// AppLogPath and AccessLogPath are the log files; "" leaves them out.
+	AppLogPath    string
+	AccessLogPath string
+	// IncludeLogs adds the log files to a backup.
+	IncludeLogs bool
+}
+
+// BackupFile is one file in a backup archive.
+type BackupFile struct {
+	Name string
+	Data []byte
+	// path is read when Data is nil.
+	path string
+}
+
+// WriteBackup writes a gzipped tarball of the messages, the greeting
+// statistics, the config file and, with IncludeLogs, the log files to w.
+// The messages and statistics are snapshots taken under their stores'
+// locks. Config and log files that do not exist are left out.
+func WriteBackup(w io.Writer, messages *MessageStore, stats *HelloStats, opts BackupOptions) error {
+	var files []BackupFile
+
+	data, err := messages.snapshot()
+	if err != nil {
+		return err
+	}
+	files = append(files, BackupFile{Name: MessagesFileName, Data: data})
+
+	if data, err = stats.snapshot(); err != nil {
+		return err
+	}
+	files = append(files, BackupFile{Name: StatsFileName, Data: data})
+
+	if opts.ConfigPath != "" {
+		files = append(files, BackupFile{Name: BackupConfigName, path: opts.ConfigPath})
+	}
+	if opts.IncludeLogs {
+		if opts.AppLogPath != "" {
+			files = append(files, BackupFile{Name: BackupAppLogName, path: opts.AppLogPath})
+		}
+		if opts.AccessLogPath != "" {
+			files = append(files, BackupFile{Name: BackupAccessLogName, path: opts.AccessLogPath})
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, file := range files {
+		if err := writeBackupFile(tw, file, now); err != nil {
+			return fmt.Errorf("failed to add %s: %w", file.Name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeBackupFile(tw *tar.Writer, file BackupFile, now time.Time) error {
+	if file.Data != nil {
+		header := &tar.Header{Name: file.Name, Mode: 0644, Size: int64(len(file.Data)), ModTime: now}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(file.Data)
+		return err
+	}
+
+	f, err := os.Open(file.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	// A log file may grow while it is copied; the archive gets the size
+	// it had when the copy started.
+	header := &tar.Header{Name: file.Name, Mode: 0644, Size: info.Size(), ModTime: info.ModTime()}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, f, info.Size())
+	return err
+}
+
+// ReadBackup reads and validates a backup archive: every file must be one
+// greetd writes, appear once, and the JSON files must parse. checkConfig,
+// when set, validates config.json further. Nothing is written.
+func ReadBackup(r io.Reader, checkConfig func(data []byte) error) ([]BackupFile, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a gzipped archive: %w", err)
+	}
+	defer gz.Close()
+
+	var files []BackupFile
+	seen := make(map[string]bool)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive: %w", err)
+		}
+
+		name := header.Name
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("invalid archive: %s is not a regular file", name)
+		}
+		if !isBackupName(name) {
+			return nil, fmt.Errorf("invalid archive: unexpected file %s", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("invalid archive: %s appears more than once", name)
+		}
+		seen[name] = true
+		if header.Size > maxBackupEntrySize {
+			return nil, fmt.Errorf("invalid archive: %s is larger than %d bytes", name, maxBackupEntrySize)
+		}
+
+		data, err := io.ReadAll(io.LimitReader(tr, maxBackupEntrySize))
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive: %s: %w", name, err)
+		}
+		if err := validateBackupFile(name, data, checkConfig); err != nil {
+			return nil, fmt.Errorf("invalid archive: %s: %w", name, err)
+		}
+		files = append(files, BackupFile{Name: name, Data: data})
+	}
+
+	if !seen[MessagesFileName] {
+		return nil, fmt.Errorf("invalid archive: no %s", MessagesFileName)
+	}
+	return files, nil
+}
+
+func isBackupName(name string) bool {
+	switch name {
+	case MessagesFileName, StatsFileName, BackupConfigName, BackupAppLogName, BackupAccessLogName:
+		return true
+	}
+	return false
+}
+
+func validateBackupFile(name string, data []byte, checkConfig func([]byte) error) error {
+	switch name {
+	case MessagesFileName:
+		// An encrypted file can only be checked once it is read with
+		// its key.
+		if IsEncrypted(data) {
+			return nil
+		}
+		var file MessagesFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return err
+		}
+		for key := range file.Messages {
+			if !ValidKey(key) {
+				return fmt.Errorf("invalid key %q", key)
+			}
+		}
+	case StatsFileName:
+		return json.Unmarshal(data, &statsData{})
+	case BackupConfigName:
+		if checkConfig != nil {
+			return checkConfig(data)
+		}
+		var config map[string]interface{}
+		return json.Unmarshal(data, &config)
+	}
+	return nil
+}
+
+// RestoreBackup writes files read by ReadBackup: the state files to
+// dataPath and the config and log files to the paths in opts. Files the
+// options give no path for are skipped. Unless force is set it refuses,
+// before writing anything, when a destination already exists. It returns
+// the paths written.
+func RestoreBackup(files []BackupFile, dataPath string, opts BackupOptions, force bool) ([]string, error) {
+	destinations := map[string]string{
+		MessagesFileName:    filepath.Join(dataPath, MessagesFileName),
+		StatsFileName:       filepath.Join(dataPath, StatsFileName),
+		BackupConfigName:    opts.ConfigPath,
+		BackupAppLogName:    opts.AppLogPath,
+		BackupAccessLogName: opts.AccessLogPath,
+	}
+
+	var existing []string
+	for _, file := range files {
+		path := destinations[file.Name]
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			existing = append(existing, path)
+		}
+	}
+	if !force && len(existing) > 0 {
+		sort.Strings(existing)
+		return nil, fmt.Errorf("%w: %s", os.ErrExist, strings.Join(existing, ", "))
+	}
+
+	var written []string
+	for _, file := range files {
+		path := destinations[file.Name]
+		if path == "" {
+			continue
+		}
+		if err := writeFileAtomic(path, file.Data); err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// snapshot returns the contents of messages.json as of now, encrypted
+// when the store has keys.
+func (s *MessageStore) snapshot() ([]byte, error) {
+	s.refresh()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.marshalUnsafe()
+}
+
+// snapshot returns the current counters as they would be saved.
+func (s *HelloStats) snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal stats data: %w", err)
+	}
+	return data, nil
+}
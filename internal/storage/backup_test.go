@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	source := t.TempDir()
+	configPath := filepath.Join(source, "config.json")
+	appLog := filepath.Join(source, "app.log")
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"server":{"port":9090}}`), 0644))
+	require.NoError(t, os.WriteFile(appLog, []byte("log line\n"), 0644))
+
+	store := NewMessageStore(source)
+	require.NoError(t, store.Load(context.Background()))
+	require.NoError(t, store.SetMessage(context.Background(), "Backed up", UpdatedByCLI))
+	require.NoError(t, store.SetKey(context.Background(), "motd", "Welcome", UpdatedByAPI))
+	stats := NewHelloStats(source)
+	require.NoError(t, stats.Load())
+	stats.Record("Ann")
+
+	opts := BackupOptions{ConfigPath: configPath, AppLogPath: appLog, AccessLogPath: filepath.Join(source, "missing.log")}
+
+	var archive bytes.Buffer
+	require.NoError(t, WriteBackup(&archive, store, stats, opts))
+	files, err := ReadBackup(bytes.NewReader(archive.Bytes()), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{MessagesFileName, StatsFileName, BackupConfigName}, backupNames(files))
+
+	opts.IncludeLogs = true
+	archive.Reset()
+	require.NoError(t, WriteBackup(&archive, store, stats, opts))
+	files, err = ReadBackup(bytes.NewReader(archive.Bytes()), nil)
+	require.NoError(t, err)
+	// The access log does not exist and is left out.
+	assert.Equal(t, []string{MessagesFileName, StatsFileName, BackupConfigName, BackupAppLogName}, backupNames(files))
+
+	target := t.TempDir()
+	restored := BackupOptions{
+		ConfigPath: filepath.Join(target, "config", "config.json"),
+		AppLogPath: filepath.Join(target, "app.log"),
+	}
+	written, err := RestoreBackup(files, target, restored, false)
+	require.NoError(t, err)
+	assert.Len(t, written, 4)
+
+	reloaded := NewMessageStore(target)
+	require.NoError(t, reloaded.Load(context.Background()))
+	assert.Equal(t, "Backed up", reloaded.GetMessage(context.Background()))
+	motd, err := reloaded.GetKey(context.Background(), "motd")
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome", motd.Message)
+
+	// The in-memory counters were captured even though they were never
+	// saved.
+	reloadedStats := NewHelloStats(target)
+	require.NoError(t, reloadedStats.Load())
+	_, total := reloadedStats.Top(1)
+	assert.Equal(t, int64(1), total)
+
+	data, err := os.ReadFile(restored.ConfigPath)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"server":{"port":9090}}`, string(data))
+
+	// Restoring again would overwrite everything.
+	_, err = RestoreBackup(files, target, restored, false)
+	assert.ErrorIs(t, err, os.ErrExist)
+	_, err = RestoreBackup(files, target, restored, true)
+	assert.NoError(t, err)
+}
+
+func TestReadBackupRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"path traversal", map[string]string{MessagesFileName: `{}`, "../evil": "x"}, "unexpected file ../evil"},
+		{"unknown file", map[string]string{MessagesFileName: `{}`, "notes.txt": "x"}, "unexpected file"},
+		{"corrupt messages", map[string]string{MessagesFileName: `{"messages":`}, MessagesFileName},
+		{"invalid key", map[string]string{MessagesFileName: `{"messages":{"Bad Key":{"message":"x"}}}`}, "invalid key"},
+		{"corrupt stats", map[string]string{MessagesFileName: `{}`, StatsFileName: `[]`}, StatsFileName},
+		{"no messages", map[string]string{StatsFileName: `{}`}, "no " + MessagesFileName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadBackup(bytes.NewReader(tarball(t, tt.files)), nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+
+	_, err := ReadBackup(bytes.NewReader([]byte("not gzip")), nil)
+	assert.Error(t, err)
+}
+
+func backupNames(files []BackupFile) []string {
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	return names
+}
+
+// tarball returns a gzipped tar archive of files.
+func tarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
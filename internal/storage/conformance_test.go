@@ -0,0 +1,35 @@
+package storage_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage/storagetest"
+)
+
+// fileStore is a MessageStore that the conformance suite can reopen and
+// crash.
+type fileStore struct {
+	*storage.MessageStore
+	dir string
+}
+
+func (s fileStore) Reopen() storage.Store {
+	return fileStore{storage.NewMessageStore(s.dir), s.dir}
+}
+
+// Crash leaves a write cut off before its rename behind, as a process
+// killed while saving would.
+func (s fileStore) Crash() error {
+	partial := []byte(`{"messages": {"default": {"message": "Lost`)
+	return os.WriteFile(filepath.Join(s.dir, ".greetd-write-4242"), partial, 0o600)
+}
+
+func TestMessageStoreConformance(t *testing.T) {
+	storagetest.Run(t, func() storage.Store {
+		dir := t.TempDir()
+		return fileStore{storage.NewMessageStore(dir), dir}
+	})
+}
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeySize is the length of the keys messages.json is encrypted with.
+const KeySize = 32
+
+var (
+	// ErrEncrypted is returned when messages.json is encrypted and no keys
+	// are configured.
+	ErrEncrypted = errors.New("messages.json is encrypted; configure storage.encryption.key_file or GREETD_STORAGE_KEY")
+	// ErrUnknownKey is returned when messages.json is encrypted with a key
+	// that is not configured.
+	ErrUnknownKey = errors.New("messages.json is encrypted with a key that is not configured")
+)
+
+// passphraseSalt and passphraseIterations derive a key from a passphrase.
+// The salt is fixed so every process derives the same key.
+var passphraseSalt = []byte("greetd messages.json")
+
+const passphraseIterations = 600000
+
+// Keyring holds the keys messages.json is encrypted with. Files encrypted
+// with any of them can be read; new files are encrypted with the first, so
+// keys can be rotated by putting the new one first.
+type Keyring struct {
+	keys []keyringKey
+}
+
+type keyringKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// envelope is the format of an encrypted messages.json.
+type envelope struct {
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+	KeyID      string `json:"key_id"`
+}
+
+// NewKeyring returns a keyring for keys of KeySize bytes each; the first
+// encrypts.
+func NewKeyring(keys ...[]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no encryption keys")
+	}
+
+	ring := &Keyring{}
+	for i, key := range keys {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("encryption key %d has %d bytes, want %d", i+1, len(key), KeySize)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		ring.keys = append(ring.keys, keyringKey{id: keyID(key), aead: aead})
+	}
+	return ring, nil
+}
+
+// LoadKeyring returns the keyring for the keys in keyFile and a
+// passphrase, which comes first when set. It returns nil when neither is
+// set.
+func LoadKeyring(keyFile, passphrase string) (*Keyring, error) {
+	var keys [][]byte
+	if passphrase != "" {
+		key, err := PassphraseKey(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		fileKeys, err := ParseKeyFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key file %s: %w", keyFile, err)
+		}
+		keys = append(keys, fileKeys...)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return NewKeyring(keys...)
+}
+
+// ParseKeyFile reads the keys in a key file: either exactly KeySize raw
+// bytes, or one hex or base64 encoded key per line. Empty lines and lines
+// starting with "#" are skipped.
+func ParseKeyFile(data []byte) ([][]byte, error) {
+	if len(data) == KeySize {
+		return [][]byte{data}, nil
+	}
+
+	var keys [][]byte
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := hex.DecodeString(line)
+		if err != nil {
+			key, err = base64.StdEncoding.DecodeString(line)
+		}
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("line %d is not a %d-byte key in hex or base64", i+1, KeySize)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no keys")
+	}
+	return keys, nil
+}
+
+// PassphraseKey derives a key from passphrase with PBKDF2-SHA256.
+func PassphraseKey(passphrase string) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, passphraseSalt, passphraseIterations, KeySize)
+}
+
+// keyID names a key in the envelope without revealing it.
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// seal encrypts plaintext with the first key into an envelope.
+func (k *Keyring) seal(plaintext []byte) ([]byte, error) {
+	key := k.keys[0]
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to encrypt messages: %w", err)
+	}
+	env := envelope{
+		Nonce:      nonce,
+		Ciphertext: key.aead.Seal(nil, nonce, plaintext, []byte(key.id)),
+		KeyID:      key.id,
+	}
+	data, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal encrypted messages: %w", err)
+	}
+	return data, nil
+}
+
+// Decrypt returns the plaintext of an encrypted messages.json. Plaintext
+// data is returned as is, so a nil keyring reads unencrypted files.
+func (k *Keyring) Decrypt(data []byte) ([]byte, error) {
+	env, ok := parseEnvelope(data)
+	if !ok {
+		return data, nil
+	}
+	if k == nil {
+		return nil, ErrEncrypted
+	}
+	for _, key := range k.keys {
+		if key.id != env.KeyID {
+			continue
+		}
+		if len(env.Nonce) != key.aead.NonceSize() {
+			return nil, errors.New("failed to decrypt messages: invalid nonce")
+		}
+		plaintext, err := key.aead.Open(nil, env.Nonce, env.Ciphertext, []byte(key.id))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt messages: %w", err)
+		}
+		return plaintext, nil
+	}
+	return nil, fmt.Errorf("%w (key ID %s)", ErrUnknownKey, env.KeyID)
+}
+
+// parseEnvelope returns the envelope in data, and whether data is one
+// rather than plaintext messages.
+func parseEnvelope(data []byte) (envelope, bool) {
+	var env envelope
+	if !bytes.Contains(data, []byte(`"ciphertext"`)) || json.Unmarshal(data, &env) != nil {
+		return envelope{}, false
+	}
+	return env, env.KeyID != "" && env.Ciphertext != nil
+}
+
+// IsEncrypted reports whether data is an encrypted messages.json.
+func IsEncrypted(data []byte) bool {
+	_, ok := parseEnvelope(data)
+	return ok
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func TestMessageStoreEncryption(t *testing.T) {
+	dataPath := t.TempDir()
+	messagesPath := filepath.Join(dataPath, MessagesFileName)
+
+	// A plaintext file is read with keys, and encrypted on the next write.
+	plain := NewMessageStore(dataPath)
+	require.NoError(t, plain.Load(context.Background()))
+	require.NoError(t, plain.SetMessage(context.Background(), "Door code 1234", UpdatedByCLI))
+
+	oldKeys, err := NewKeyring(testKey(1))
+	require.NoError(t, err)
+	store := NewMessageStore(dataPath)
+	store.SetKeyring(oldKeys)
+	require.NoError(t, store.Load(context.Background()))
+	assert.Equal(t, "Door code 1234", store.GetMessage(context.Background()))
+	require.NoError(t, store.SetKey(context.Background(), "motd", "Welcome", UpdatedByCLI))
+
+	data, err := os.ReadFile(messagesPath)
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(data))
+	assert.NotContains(t, string(data), "Door code")
+	assert.Contains(t, string(data), keyID(testKey(1)))
+
+	// Without the key the file cannot be read.
+	locked := NewMessageStore(dataPath)
+	assert.ErrorIs(t, locked.Load(context.Background()), ErrEncrypted)
+	otherKeys, err := NewKeyring(testKey(2))
+	require.NoError(t, err)
+	locked.SetKeyring(otherKeys)
+	assert.ErrorIs(t, locked.Load(context.Background()), ErrUnknownKey)
+
+	// After a rotation the old key still decrypts, and writes use the new
+	// one.
+	rotated, err := NewKeyring(testKey(2), testKey(1))
+	require.NoError(t, err)
+	store = NewMessageStore(dataPath)
+	store.SetKeyring(rotated)
+	require.NoError(t, store.Load(context.Background()))
+	assert.Equal(t, "Door code 1234", store.GetMessage(context.Background()))
+	require.NoError(t, store.Rewrite(context.Background(), rotated))
+	data, err = os.ReadFile(messagesPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), keyID(testKey(2)))
+
+	// Rewriting without keys decrypts the file.
+	require.NoError(t, store.Rewrite(context.Background(), nil))
+	data, err = os.ReadFile(messagesPath)
+	require.NoError(t, err)
+	assert.False(t, IsEncrypted(data))
+	assert.Contains(t, string(data), "Door code 1234")
+	motd, err := NewMessageStore(dataPath).GetKey(context.Background(), "motd")
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome", motd.Message)
+}
+
+func TestMessageStoreEncryptionTampered(t *testing.T) {
+	dataPath := t.TempDir()
+	keys, err := NewKeyring(testKey(1))
+	require.NoError(t, err)
+
+	store := NewMessageStore(dataPath)
+	store.SetKeyring(keys)
+	require.NoError(t, store.Load(context.Background()))
+	require.NoError(t, store.SetMessage(context.Background(), "Hello", UpdatedByCLI))
+
+	messagesPath := filepath.Join(dataPath, MessagesFileName)
+	data, err := os.ReadFile(messagesPath)
+	require.NoError(t, err)
+	tampered := bytes.Replace(data, []byte(`"ciphertext": "`), []byte(`"ciphertext": "AAAA`), 1)
+	require.NoError(t, os.WriteFile(messagesPath, tampered, 0644))
+
+	reader := NewMessageStore(dataPath)
+	reader.SetKeyring(keys)
+	assert.ErrorContains(t, reader.Load(context.Background()), "failed to decrypt")
+}
+
+func TestParseKeyFile(t *testing.T) {
+	key := testKey(7)
+
+	keys, err := ParseKeyFile(key)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{key}, keys)
+
+	file := "# current\n" + hex.EncodeToString(testKey(8)) + "\n\n# previous\n" + base64.StdEncoding.EncodeToString(key) + "\n"
+	keys, err = ParseKeyFile([]byte(file))
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{testKey(8), key}, keys)
+
+	_, err = ParseKeyFile([]byte("deadbeef\n"))
+	assert.ErrorContains(t, err, "line 1")
+	_, err = ParseKeyFile([]byte("# nothing here\n"))
+	assert.Error(t, err)
+}
+
+func TestLoadKeyring(t *testing.T) {
+	keys, err := LoadKeyring("", "")
+	require.NoError(t, err)
+	assert.Nil(t, keys)
+
+	keyFile := filepath.Join(t.TempDir(), "greetd.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(hex.EncodeToString(testKey(1))+"\n"), 0600))
+
+	// The passphrase comes first and the key file still decrypts.
+	keys, err = LoadKeyring(keyFile, "correct horse battery staple")
+	require.NoError(t, err)
+	require.Len(t, keys.keys, 2)
+	passphraseKey, err := PassphraseKey("correct horse battery staple")
+	require.NoError(t, err)
+	assert.Equal(t, keyID(passphraseKey), keys.keys[0].id)
+	assert.Equal(t, keyID(testKey(1)), keys.keys[1].id)
+
+	_, err = LoadKeyring(filepath.Join(t.TempDir(), "missing.key"), "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,8 @@
+package storage
+
+// FreeSpace returns the number of bytes available to unprivileged users on
+// the filesystem holding dir. A missing dir is checked through its nearest
+// existing parent, like CheckWritable.
+func FreeSpace(dir string) (uint64, error) {
+	return freeSpace(nearestExisting(dir))
+}
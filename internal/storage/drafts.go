@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// GetDraft returns the draft stored for key, or ErrNoDraft.
+func (s *MessageStore) GetDraft(ctx context.Context, key string) (MessageData, error) {
+	s.refresh()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	draft, ok := s.drafts[key]
+	if !ok {
+		return MessageData{}, ErrNoDraft
+	}
+	return draft, nil
+}
+
+// SetDraft stores message as the draft for key, replacing any earlier
+// draft. The message stored under key is unchanged until PublishDraft.
+// Revision counts the writes to the draft since it was last published or
+// deleted.
+func (s *MessageStore) SetDraft(ctx context.Context, key, message, updatedBy string) (MessageData, error) {
+	if !ValidKey(key) {
+		return MessageData{}, ErrInvalidKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lockUnsafe(ctx)
+	if err != nil {
+		return MessageData{}, err
+	}
+	defer unlock()
+	if s.readOnly {
+		return MessageData{}, ErrReadOnly
+	}
+
+	draft := MessageData{
+		Message:   message,
+		UpdatedAt: time.Now().UTC().Truncate(time.Second),
+		UpdatedBy: updatedBy,
+		Revision:  s.drafts[key].Revision + 1,
+	}
+	if err := s.replaceDraftUnsafe(ctx, key, &draft); err != nil {
+		return MessageData{}, err
+	}
+	return draft, nil
+}
+
+// DeleteDraft discards the draft for key, or returns ErrNoDraft.
+func (s *MessageStore) DeleteDraft(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lockUnsafe(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := s.drafts[key]; !ok {
+		return ErrNoDraft
+	}
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	return s.replaceDraftUnsafe(ctx, key, nil)
+}
+
+// PublishDraft stores the draft for key as its message, written by
+// updatedBy, and removes the draft in the same write. The replaced message
+// can be restored with UndoKey. It returns ErrNoDraft without a draft.
+func (s *MessageStore) PublishDraft(ctx context.Context, key, updatedBy string) (MessageData, error) {
+	if !ValidKey(key) {
+		return MessageData{}, ErrInvalidKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lockUnsafe(ctx)
+	if err != nil {
+		return MessageData{}, err
+	}
+	defer unlock()
+
+	draft, ok := s.drafts[key]
+	if !ok || draft.Message == "" {
+		return MessageData{}, ErrNoDraft
+	}
+
+	delete(s.drafts, key)
+	_, data, err := s.setKeyUnsafe(ctx, key, draft.Message, updatedBy)
+	if err != nil {
+		s.drafts[key] = draft
+		return MessageData{}, err
+	}
+	return data, nil
+}
+
+// replaceDraftUnsafe stores draft for key, or removes it when draft is nil,
+// and saves. The previous draft is restored if saving fails.
+func (s *MessageStore) replaceDraftUnsafe(ctx context.Context, key string, draft *MessageData) error {
+	previous, existed := s.drafts[key]
+
+	if draft != nil {
+		s.drafts[key] = *draft
+	} else {
+		delete(s.drafts, key)
+	}
+
+	if err := s.saveUnsafe(ctx); err != nil {
+		if existed {
+			s.drafts[key] = previous
+		} else {
+			delete(s.drafts, key)
+		}
+		return err
+	}
+	return nil
+}
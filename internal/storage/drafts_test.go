@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageStoreDrafts(t *testing.T) {
+	ctx := context.Background()
+	dataPath := t.TempDir()
+	store := NewMessageStore(dataPath)
+	require.NoError(t, store.Load(ctx))
+
+	_, err := store.GetDraft(ctx, DefaultKey)
+	assert.ErrorIs(t, err, ErrNoDraft)
+	_, err = store.PublishDraft(ctx, DefaultKey, UpdatedByAPI)
+	assert.ErrorIs(t, err, ErrNoDraft)
+	assert.ErrorIs(t, store.DeleteDraft(ctx, DefaultKey), ErrNoDraft)
+
+	draft, err := store.SetDraft(ctx, DefaultKey, "First draft", UpdatedByUI)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), draft.Revision)
+	draft, err = store.SetDraft(ctx, DefaultKey, "Next week's message", UpdatedByUI)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), draft.Revision)
+
+	// The published message is unchanged, and the draft survives a restart.
+	assert.Equal(t, DefaultMessage, store.GetMessage(ctx))
+	reopened := NewMessageStore(dataPath)
+	require.NoError(t, reopened.Load(ctx))
+	draft, err = reopened.GetDraft(ctx, DefaultKey)
+	require.NoError(t, err)
+	assert.Equal(t, "Next week's message", draft.Message)
+	assert.Equal(t, UpdatedByUI, draft.UpdatedBy)
+
+	data, err := reopened.PublishDraft(ctx, DefaultKey, "api-key:comms")
+	require.NoError(t, err)
+	assert.Equal(t, "Next week's message", data.Message)
+	assert.Equal(t, "api-key:comms", data.UpdatedBy)
+	assert.Equal(t, int64(1), data.Revision)
+	_, err = reopened.GetDraft(ctx, DefaultKey)
+	assert.ErrorIs(t, err, ErrNoDraft)
+
+	// The other store sees the publish, which can be undone.
+	assert.Equal(t, "Next week's message", store.GetMessage(ctx))
+	data, err = store.UndoKey(ctx, DefaultKey, UpdatedByCLI)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultMessage, data.Message)
+
+	_, err = store.SetDraft(ctx, DefaultKey, "Scrapped", UpdatedByUI)
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteDraft(ctx, DefaultKey))
+	_, err = reopened.GetDraft(ctx, DefaultKey)
+	assert.ErrorIs(t, err, ErrNoDraft)
+
+	_, err = store.SetDraft(ctx, "Bad Key", "x", UpdatedByUI)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestMessageStorePublishDraftTooManyKeys(t *testing.T) {
+	ctx := context.Background()
+	store := NewMessageStore(t.TempDir())
+	require.NoError(t, store.Load(ctx))
+	store.SetMaxKeys(1)
+	require.NoError(t, store.SetKey(ctx, "motd", "Open", UpdatedByCLI))
+
+	_, err := store.SetDraft(ctx, "footer", "Bye", UpdatedByCLI)
+	require.NoError(t, err)
+	_, err = store.PublishDraft(ctx, "footer", UpdatedByCLI)
+	assert.ErrorIs(t, err, ErrTooManyKeys)
+
+	// A failed publish keeps the draft.
+	draft, err := store.GetDraft(ctx, "footer")
+	require.NoError(t, err)
+	assert.Equal(t, "Bye", draft.Message)
+}
@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExportAPIVersion is the version of the export format written by Export.
+const ExportAPIVersion = "greetd/v1"
+
+// ExportFile is the versioned, reviewable form of the stored messages used
+// by "greetd export" and "greetd import".
+type ExportFile struct {
+	APIVersion string `json:"apiVersion" yaml:"apiVersion"`
+	// Messages maps each key to its message text.
+	Messages map[string]string `json:"messages" yaml:"messages"`
+}
+
+// ParseExport reads an export file in YAML or JSON. Files written by older
+// versions of the format are migrated to the current one.
+func ParseExport(data []byte) (ExportFile, error) {
+	var header struct {
+		APIVersion string `yaml:"apiVersion"`
+	}
+	if err := yaml.Unmarshal(data, &header); err != nil {
+		return ExportFile{}, fmt.Errorf("invalid export file: %w", err)
+	}
+
+	switch header.APIVersion {
+	case ExportAPIVersion:
+		var file ExportFile
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return ExportFile{}, fmt.Errorf("invalid export file: %w", err)
+		}
+		if file.Messages == nil {
+			file.Messages = make(map[string]string)
+		}
+		return file, nil
+	case "":
+		return ExportFile{}, fmt.Errorf("invalid export file: apiVersion is missing")
+	default:
+		return ExportFile{}, fmt.Errorf("unsupported apiVersion %q (supported: %s)", header.APIVersion, ExportAPIVersion)
+	}
+}
+
+// ParseImport reads messages to import from an export file, a
+// messages.json or a message.json, the format from before keyed messages.
+// complete is false for a message.json: it only holds the default message
+// and says nothing about the other keys. An encrypted messages.json is not
+// recognized.
+func ParseImport(data []byte) (messages map[string]string, complete bool, err error) {
+	var header struct {
+		APIVersion string `yaml:"apiVersion"`
+	}
+	if err := yaml.Unmarshal(data, &header); err != nil {
+		return nil, false, fmt.Errorf("invalid file: %w", err)
+	}
+	if header.APIVersion != "" {
+		file, err := ParseExport(data)
+		return file.Messages, true, err
+	}
+
+	var stored struct {
+		Messages map[string]MessageData `json:"messages"`
+		Message  *string                `json:"message"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, false, fmt.Errorf("invalid file: %w", err)
+	}
+	switch {
+	case stored.Messages != nil:
+		messages = make(map[string]string, len(stored.Messages))
+		for key, data := range stored.Messages {
+			messages[key] = data.Message
+		}
+		return messages, true, nil
+	case stored.Message != nil:
+		return map[string]string{DefaultKey: *stored.Message}, false, nil
+	}
+	return nil, false, fmt.Errorf("unrecognized file: expected an export file (apiVersion: %s), %s or %s", ExportAPIVersion, MessagesFileName, LegacyMessageFileName)
+}
+
+// Export returns the stored messages, including the default one.
+func (s *MessageStore) Export(ctx context.Context) ExportFile {
+	return ExportFile{APIVersion: ExportAPIVersion, Messages: s.texts()}
+}
+
+// texts returns the text of every key, including the default one.
+func (s *MessageStore) texts() map[string]string {
+	s.refresh()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.textsUnsafe()
+}
+
+// textsUnsafe is texts for callers holding s.mu.
+func (s *MessageStore) textsUnsafe() map[string]string {
+	texts := map[string]string{DefaultKey: s.getKeyUnsafe(DefaultKey).Message}
+	for key, data := range s.messages {
+		texts[key] = data.Message
+	}
+	return texts
+}
+
+// MessageChange is one difference between the stored messages and an
+// import. Old is empty for added keys and New for removed ones.
+type MessageChange struct {
+	Key      string
+	Old, New string
+	Added    bool
+	Removed  bool
+}
+
+// Diff lists, sorted by key, what ReplaceMessages(messages) would change.
+// A missing default key means the default message.
+func (s *MessageStore) Diff(ctx context.Context, messages map[string]string) []MessageChange {
+	return diffTexts(s.texts(), messages)
+}
+
+// diffTexts lists, sorted by key, what replacing current with messages
+// changes.
+func diffTexts(current, messages map[string]string) []MessageChange {
+	next := make(map[string]string, len(messages)+1)
+	next[DefaultKey] = DefaultMessage
+	for key, text := range messages {
+		next[key] = text
+	}
+
+	var changes []MessageChange
+	for key, text := range next {
+		old, exists := current[key]
+		switch {
+		case !exists:
+			changes = append(changes, MessageChange{Key: key, New: text, Added: true})
+		case old != text:
+			changes = append(changes, MessageChange{Key: key, Old: old, New: text})
+		}
+	}
+	for key, old := range current {
+		if _, kept := next[key]; !kept {
+			changes = append(changes, MessageChange{Key: key, Old: old, Removed: true})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// ReplaceMessages makes the stored messages equal messages in a single
+// write. Keys missing from messages are deleted, and the default key falls
+// back to DefaultMessage. Changed messages get a new revision and can be
+// undone; unchanged ones keep their metadata.
+func (s *MessageStore) ReplaceMessages(ctx context.Context, messages map[string]string, updatedBy string) error {
+	_, err := s.SwapMessages(ctx, messages, updatedBy)
+	return err
+}
+
+// SwapMessages is ReplaceMessages that also returns what it changed, as
+// Diff lists it, read under the same lock as the write.
+func (s *MessageStore) SwapMessages(ctx context.Context, messages map[string]string, updatedBy string) ([]MessageChange, error) {
+	for key := range messages {
+		if !ValidKey(key) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	unlock, err := s.lockUnsafe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if len(messages) > s.maxKeys {
+		return nil, ErrTooManyKeys
+	}
+	changes := diffTexts(s.textsUnsafe(), messages)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	replaced := make(map[string]MessageData, len(messages))
+	history := make(map[string]MessageHistory, len(s.history))
+	for key, text := range messages {
+		current := s.getKeyUnsafe(key)
+		_, exists := s.messages[key]
+		if exists {
+			history[key] = s.history[key]
+		}
+		if current.Message == text && (exists || key == DefaultKey) {
+			if exists {
+				replaced[key] = current
+			}
+			continue
+		}
+
+		if exists || key == DefaultKey {
+			history[key] = MessageHistory{Undo: pushHistory(s.history[key].Undo, current)}
+		}
+		replaced[key] = MessageData{Message: text, UpdatedAt: now, UpdatedBy: updatedBy, Revision: s.nextRevisionUnsafe(key)}
+	}
+	deleted := maps.Clone(s.deleted)
+	for key, data := range s.messages {
+		if _, ok := replaced[key]; !ok {
+			deleted[key] = max(data.Revision, deleted[key])
+		}
+	}
+
+	previous, previousHistory, previousDeleted := s.messages, s.history, s.deleted
+	s.messages, s.history, s.deleted = replaced, history, deleted
+	if err := s.saveUnsafe(ctx); err != nil {
+		s.messages, s.history, s.deleted = previous, previousHistory, previousDeleted
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	store := NewMessageStore(t.TempDir())
+	require.NoError(t, store.Load(context.Background()))
+	require.NoError(t, store.SetKey(context.Background(), "motd", "Welcome", UpdatedByCLI))
+
+	data, err := yaml.Marshal(store.Export(context.Background()))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "apiVersion: greetd/v1")
+
+	file, err := ParseExport(data)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{DefaultKey: DefaultMessage, "motd": "Welcome"}, file.Messages)
+	assert.Empty(t, store.Diff(context.Background(), file.Messages))
+
+	// JSON is YAML, so both forms parse.
+	file, err = ParseExport([]byte(`{"apiVersion": "greetd/v1", "messages": {"motd": "Hi"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "Hi", file.Messages["motd"])
+
+	_, err = ParseExport([]byte("messages: {}\n"))
+	assert.ErrorContains(t, err, "apiVersion is missing")
+	_, err = ParseExport([]byte("apiVersion: greetd/v9\n"))
+	assert.ErrorContains(t, err, "unsupported apiVersion")
+}
+
+func TestReplaceMessages(t *testing.T) {
+	dir := t.TempDir()
+	store := NewMessageStore(dir)
+	require.NoError(t, store.Load(context.Background()))
+	require.NoError(t, store.SetMessage(context.Background(), "Hello", UpdatedByCLI))
+	require.NoError(t, store.SetKey(context.Background(), "motd", "Welcome", UpdatedByCLI))
+	require.NoError(t, store.SetKey(context.Background(), "footer", "Bye", UpdatedByCLI))
+	motd, err := store.GetKey(context.Background(), "motd")
+	require.NoError(t, err)
+
+	next := map[string]string{"motd": "Welcome", "banner": "New", DefaultKey: "Hi"}
+	want := []MessageChange{
+		{Key: "banner", New: "New", Added: true},
+		{Key: DefaultKey, Old: "Hello", New: "Hi"},
+		{Key: "footer", Old: "Bye", Removed: true},
+	}
+	assert.Equal(t, want, store.Diff(context.Background(), next))
+
+	// SwapMessages reports what it changed, as Diff did beforehand.
+	changes, err := store.SwapMessages(context.Background(), next, "import")
+	require.NoError(t, err)
+	assert.Equal(t, want, changes)
+	assert.Empty(t, store.Diff(context.Background(), next))
+
+	// Unchanged messages keep their revision; changed ones can be undone.
+	unchanged, err := store.GetKey(context.Background(), "motd")
+	require.NoError(t, err)
+	assert.Equal(t, motd, unchanged)
+	_, err = store.GetKey(context.Background(), "footer")
+	assert.ErrorIs(t, err, ErrNotFound)
+	data, err := store.UndoKey(context.Background(), DefaultKey, UpdatedByCLI)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", data.Message)
+
+	reloaded := NewMessageStore(dir)
+	require.NoError(t, reloaded.Load(context.Background()))
+	assert.Equal(t, 3, reloaded.Len())
+
+	// A removed key counts on from its last revision when it returns.
+	require.NoError(t, reloaded.SetKey(context.Background(), "footer", "Bye again", UpdatedByCLI))
+	footer, err := reloaded.GetKey(context.Background(), "footer")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), footer.Revision)
+	require.NoError(t, store.DeleteKey(context.Background(), "footer"))
+
+	// A missing default key restores the default message.
+	require.NoError(t, store.ReplaceMessages(context.Background(), map[string]string{}, "import"))
+	assert.Equal(t, DefaultMessage, store.GetMessage(context.Background()))
+	assert.Equal(t, 0, store.Len())
+
+	assert.ErrorIs(t, store.ReplaceMessages(context.Background(), map[string]string{"bad key": "x"}, "import"), ErrInvalidKey)
+	store.SetMaxKeys(1)
+	assert.ErrorIs(t, store.ReplaceMessages(context.Background(), map[string]string{"a": "x", "b": "y"}, "import"), ErrTooManyKeys)
+}
+
+func TestParseImport(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		want     map[string]string
+		complete bool
+		wantErr  string
+	}{
+		{
+			name:     "export file",
+			data:     "apiVersion: greetd/v1\nmessages:\n  default: Hi\n  motd: Open\n",
+			want:     map[string]string{"default": "Hi", "motd": "Open"},
+			complete: true,
+		},
+		{
+			name:     "messages.json",
+			data:     `{"messages": {"default": {"message": "Hi", "revision": 3}, "motd": {"message": "Open"}}}`,
+			want:     map[string]string{"default": "Hi", "motd": "Open"},
+			complete: true,
+		},
+		{
+			name: "message.json",
+			data: `{"message": "Hi", "updated_at": "2024-01-01T12:00:00Z"}`,
+			want: map[string]string{"default": "Hi"},
+		},
+		{name: "unsupported version", data: "apiVersion: greetd/v9\n", wantErr: "unsupported apiVersion"},
+		{name: "not a message file", data: `{"greeting": "Hi"}`, wantErr: "unrecognized file"},
+		{name: "wrong types", data: `{"messages": {"default": "Hi"}}`, wantErr: "invalid file"},
+		{name: "not YAML", data: "{{{", wantErr: "invalid file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, complete, err := ParseImport([]byte(tt.data))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, messages)
+			assert.Equal(t, tt.complete, complete)
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// IdempotencyFileName is the file remembered idempotency keys are
+	// persisted to.
+	IdempotencyFileName = "idempotency.json"
+	// MaxIdempotencyKeys bounds the number of remembered keys; when it is
+	// reached the least recently used key makes room for a new one.
+	MaxIdempotencyKeys = 1000
+)
+
+var (
+	// ErrIdempotencyMismatch is returned when a key is reused for a
+	// different request.
+	ErrIdempotencyMismatch = errors.New("idempotency key was used for a different request")
+	// ErrIdempotencyInProgress is returned when a request with the same key
+	// has not finished yet.
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is in progress")
+)
+
+// StoredResponse is a response remembered for an idempotency key, replayed
+// when the same request is sent again.
+type StoredResponse struct {
+	// Fingerprint identifies the request that produced the response.
+	Fingerprint string    `json:"fingerprint"`
+	Status      int       `json:"status"`
+	ContentType string    `json:"content_type,omitempty"`
+	ETag        string    `json:"etag,omitempty"`
+	Body        []byte    `json:"body"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type idempotencyEntry struct {
+	Key string `json:"key"`
+	StoredResponse
+}
+
+// IdempotencyStore remembers the responses to requests sent with an
+// idempotency key for a window of time. It keeps at most MaxIdempotencyKeys
+// keys in memory; Save writes them to disk, so they survive a restart on a
+// best-effort basis. The file holds the response bodies, so it is encrypted
+// like messages.json when the store is given a keyring.
+type IdempotencyStore struct {
+	mu       sync.Mutex
+	filePath string
+	window   time.Duration
+	// order holds the *idempotencyEntry values, most recently used first;
+	// entries indexes it by key.
+	order   *list.List
+	entries map[string]*list.Element
+	// pending holds the fingerprints of requests that are still running.
+	pending  map[string]string
+	hits     uint64
+	dirty    bool
+	readOnly bool
+	// keys encrypts the file, which holds response bodies; nil stores it
+	// in plaintext.
+	keys *Keyring
+	now  func() time.Time
+}
+
+func NewIdempotencyStore(dataPath string, window time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{
+		filePath: filepath.Join(dataPath, IdempotencyFileName),
+		window:   window,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		pending:  make(map[string]string),
+		now:      time.Now,
+	}
+}
+
+// SetKeyring makes Save encrypt the file with the first of keys, and Load
+// decrypt it with any of them. nil, the default, writes plaintext.
+func (s *IdempotencyStore) SetKeyring(keys *Keyring) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+// Load reads persisted keys, dropping the expired ones. A missing file
+// starts empty; nothing is written until Save.
+func (s *IdempotencyStore) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.readOnly = CheckWritable(filepath.Dir(s.filePath)) != nil
+
+	data, err := os.ReadFile(s.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read idempotency file: %w", err)
+	}
+	if data, err = s.keys.Decrypt(data); err != nil {
+		return fmt.Errorf("failed to read idempotency file: %w", err)
+	}
+
+	var loaded []idempotencyEntry
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("failed to unmarshal idempotency data: %w", err)
+	}
+
+	s.order.Init()
+	s.entries = make(map[string]*list.Element)
+	// The file lists the most recently used key first.
+	for i := range loaded {
+		entry := &loaded[i]
+		if _, ok := s.entries[entry.Key]; ok || s.expiredUnsafe(entry) {
+			continue
+		}
+		s.entries[entry.Key] = s.order.PushBack(entry)
+	}
+	for s.order.Len() > MaxIdempotencyKeys {
+		s.removeUnsafe(s.order.Back())
+	}
+	return nil
+}
+
+// Begin starts a request with key. It returns the stored response when the
+// same request was already answered within the window, which counts as a
+// hit. Otherwise the key is reserved until Finish or Abort, and Begin
+// returns nil. A key used for a request with another fingerprint returns
+// ErrIdempotencyMismatch; one whose request is still running returns
+// ErrIdempotencyInProgress.
+func (s *IdempotencyStore) Begin(key, fingerprint string) (*StoredResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pending, ok := s.pending[key]; ok {
+		if pending != fingerprint {
+			return nil, ErrIdempotencyMismatch
+		}
+		return nil, ErrIdempotencyInProgress
+	}
+
+	if elem, ok := s.entries[key]; ok {
+		entry := elem.Value.(*idempotencyEntry)
+		if !s.expiredUnsafe(entry) {
+			if entry.Fingerprint != fingerprint {
+				return nil, ErrIdempotencyMismatch
+			}
+			s.order.MoveToFront(elem)
+			s.hits++
+			res := entry.StoredResponse
+			return &res, nil
+		}
+		s.removeUnsafe(elem)
+	}
+
+	s.pending[key] = fingerprint
+	return nil, nil
+}
+
+// Finish remembers res as the response for key, reserved by Begin.
+func (s *IdempotencyStore) Finish(key string, res StoredResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.pending, key)
+	if res.CreatedAt.IsZero() {
+		res.CreatedAt = s.now()
+	}
+	if elem, ok := s.entries[key]; ok {
+		s.removeUnsafe(elem)
+	}
+	s.entries[key] = s.order.PushFront(&idempotencyEntry{Key: key, StoredResponse: res})
+	for s.order.Len() > MaxIdempotencyKeys {
+		s.removeUnsafe(s.order.Back())
+	}
+	s.dirty = true
+}
+
+// Abort releases key, reserved by Begin, without remembering a response, so
+// the request can be retried.
+func (s *IdempotencyStore) Abort(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.pending, key)
+}
+
+// Len returns the number of remembered keys, including expired ones not
+// yet dropped.
+func (s *IdempotencyStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.order.Len()
+}
+
+// Hits returns how many responses were replayed.
+func (s *IdempotencyStore) Hits() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.hits
+}
+
+// Save writes the remembered keys if they changed since the last save,
+// dropping the expired ones. The file is replaced atomically.
+func (s *IdempotencyStore) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	entries := make([]*idempotencyEntry, 0, s.order.Len())
+	for elem := s.order.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*idempotencyEntry)
+		if s.expiredUnsafe(entry) {
+			s.removeUnsafe(elem)
+		} else {
+			entries = append(entries, entry)
+		}
+		elem = next
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal idempotency data: %w", err)
+	}
+	if s.keys != nil {
+		if data, err = s.keys.seal(data); err != nil {
+			return err
+		}
+	}
+	if err := writeFileAtomic(s.filePath, data); err != nil {
+		return fmt.Errorf("failed to write idempotency file: %w", err)
+	}
+
+	s.dirty = false
+	return nil
+}
+
+func (s *IdempotencyStore) expiredUnsafe(entry *idempotencyEntry) bool {
+	return s.now().Sub(entry.CreatedAt) >= s.window
+}
+
+func (s *IdempotencyStore) removeUnsafe(elem *list.Element) {
+	delete(s.entries, elem.Value.(*idempotencyEntry).Key)
+	s.order.Remove(elem)
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	dataPath := t.TempDir()
+
+	store := NewIdempotencyStore(dataPath, time.Hour)
+	require.NoError(t, store.Load())
+
+	stored, err := store.Begin("k1", "fp1")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	_, err = store.Begin("k1", "fp1")
+	assert.ErrorIs(t, err, ErrIdempotencyInProgress)
+	_, err = store.Begin("k1", "fp2")
+	assert.ErrorIs(t, err, ErrIdempotencyMismatch)
+
+	store.Finish("k1", StoredResponse{Fingerprint: "fp1", Status: 200, ContentType: "application/json", ETag: `"2"`, Body: []byte(`{"ok":true}`)})
+
+	stored, err = store.Begin("k1", "fp1")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, 200, stored.Status)
+	assert.Equal(t, `"2"`, stored.ETag)
+	assert.Equal(t, `{"ok":true}`, string(stored.Body))
+
+	_, err = store.Begin("k1", "fp2")
+	assert.ErrorIs(t, err, ErrIdempotencyMismatch)
+
+	// An aborted key is forgotten, so the request can be retried.
+	_, err = store.Begin("k2", "fp1")
+	require.NoError(t, err)
+	store.Abort("k2")
+	stored, err = store.Begin("k2", "fp1")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+	store.Abort("k2")
+
+	assert.Equal(t, 1, store.Len())
+	assert.Equal(t, uint64(1), store.Hits())
+
+	// Nothing is written until Save.
+	assert.NoFileExists(t, filepath.Join(dataPath, IdempotencyFileName))
+	require.NoError(t, store.Save())
+
+	reloaded := NewIdempotencyStore(dataPath, time.Hour)
+	require.NoError(t, reloaded.Load())
+	stored, err = reloaded.Begin("k1", "fp1")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, `{"ok":true}`, string(stored.Body))
+}
+
+func TestIdempotencyStoreExpiry(t *testing.T) {
+	dataPath := t.TempDir()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	store := NewIdempotencyStore(dataPath, time.Hour)
+	store.now = func() time.Time { return now }
+	require.NoError(t, store.Load())
+
+	_, err := store.Begin("k1", "fp1")
+	require.NoError(t, err)
+	store.Finish("k1", StoredResponse{Fingerprint: "fp1", Status: 200})
+	require.NoError(t, store.Save())
+
+	now = now.Add(time.Hour)
+
+	// An expired key is a new request, even with another fingerprint.
+	stored, err := store.Begin("k1", "fp2")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	reloaded := NewIdempotencyStore(dataPath, time.Hour)
+	reloaded.now = store.now
+	require.NoError(t, reloaded.Load())
+	assert.Zero(t, reloaded.Len())
+}
+
+func TestIdempotencyStoreBounded(t *testing.T) {
+	store := NewIdempotencyStore(t.TempDir(), time.Hour)
+	require.NoError(t, store.Load())
+
+	remember := func(key string) {
+		_, err := store.Begin(key, "fp")
+		require.NoError(t, err)
+		store.Finish(key, StoredResponse{Fingerprint: "fp", Status: 200})
+	}
+
+	remember("first")
+	remember("second")
+	// Using the first key makes the second the least recently used.
+	stored, err := store.Begin("first", "fp")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+
+	for i := 0; i < MaxIdempotencyKeys-1; i++ {
+		remember(fmt.Sprintf("key-%d", i))
+	}
+
+	assert.Equal(t, MaxIdempotencyKeys, store.Len())
+	stored, err = store.Begin("first", "fp")
+	require.NoError(t, err)
+	assert.NotNil(t, stored)
+	stored, err = store.Begin("second", "fp")
+	require.NoError(t, err)
+	assert.Nil(t, stored, "the least recently used key makes room")
+}
+
+func TestIdempotencyStoreEncryption(t *testing.T) {
+	dataPath := t.TempDir()
+	keys, err := NewKeyring(testKey(1))
+	require.NoError(t, err)
+
+	store := NewIdempotencyStore(dataPath, time.Hour)
+	store.SetKeyring(keys)
+	require.NoError(t, store.Load())
+	_, err = store.Begin("k1", "fp1")
+	require.NoError(t, err)
+	store.Finish("k1", StoredResponse{Fingerprint: "fp1", Status: 200, Body: []byte(`{"message":"Door code 1234"}`)})
+	require.NoError(t, store.Save())
+
+	data, err := os.ReadFile(filepath.Join(dataPath, IdempotencyFileName))
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(data))
+	assert.NotContains(t, string(data), "fp1")
+
+	reloaded := NewIdempotencyStore(dataPath, time.Hour)
+	reloaded.SetKeyring(keys)
+	require.NoError(t, reloaded.Load())
+	stored, err := reloaded.Begin("k1", "fp1")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, `{"message":"Door code 1234"}`, string(stored.Body))
+
+	assert.ErrorIs(t, NewIdempotencyStore(dataPath, time.Hour).Load(), ErrEncrypted)
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// MaintenanceFileName is the file the maintenance mode is persisted to.
+	MaintenanceFileName = "maintenance.json"
+	// DefaultMaintenanceMessage is shown when maintenance mode is enabled
+	// without a message.
+	DefaultMaintenanceMessage = "greetd is down for maintenance. Please try again later."
+)
+
+// MaintenanceState is whether maintenance mode is enabled, and since when
+// and by whom it was last changed.
+type MaintenanceState struct {
+	Enabled   bool      `json:"enabled"`
+	Message   string    `json:"message,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+}
+
+// Maintenance holds the maintenance mode of the server. Every change is
+// written to disk, so the mode survives restarts.
+type Maintenance struct {
+	mu       sync.RWMutex
+	filePath string
+	state    MaintenanceState
+	readOnly bool
+}
+
+func NewMaintenance(dataPath string) *Maintenance {
+	return &Maintenance{filePath: filepath.Join(dataPath, MaintenanceFileName)}
+}
+
+// Load reads the persisted mode. A missing file means disabled.
+func (m *Maintenance) Load() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.readOnly = CheckWritable(filepath.Dir(m.filePath)) != nil
+
+	data, err := os.ReadFile(m.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read maintenance file: %w", err)
+	}
+
+	var state MaintenanceState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to unmarshal maintenance data: %w", err)
+	}
+	m.state = state
+	return nil
+}
+
+// Get returns the current mode.
+func (m *Maintenance) Get() MaintenanceState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state
+}
+
+// Set enables or disables maintenance mode. An empty message while enabled
+// means DefaultMaintenanceMessage. The mode is unchanged if it cannot be
+// saved.
+func (m *Maintenance) Set(enabled bool, message, updatedBy string) (MaintenanceState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.readOnly {
+		return m.state, ErrReadOnly
+	}
+
+	state := MaintenanceState{
+		Enabled:   enabled,
+		UpdatedAt: time.Now().UTC().Truncate(time.Second),
+		UpdatedBy: updatedBy,
+	}
+	if enabled {
+		state.Message = message
+		if state.Message == "" {
+			state.Message = DefaultMaintenanceMessage
+		}
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return m.state, fmt.Errorf("failed to marshal maintenance data: %w", err)
+	}
+	if err := writeFileAtomic(m.filePath, data); err != nil {
+		return m.state, fmt.Errorf("failed to write maintenance file: %w", err)
+	}
+
+	m.state = state
+	return state, nil
+}
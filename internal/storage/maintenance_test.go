@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenance(t *testing.T) {
+	dataPath := t.TempDir()
+
+	m := NewMaintenance(dataPath)
+	require.NoError(t, m.Load())
+	assert.False(t, m.Get().Enabled)
+
+	state, err := m.Set(true, "", "ops")
+	require.NoError(t, err)
+	assert.True(t, state.Enabled)
+	assert.Equal(t, DefaultMaintenanceMessage, state.Message)
+	assert.Equal(t, "ops", state.UpdatedBy)
+
+	_, err = m.Set(true, "Back soon", "ops")
+	require.NoError(t, err)
+
+	// The mode survives a restart.
+	reloaded := NewMaintenance(dataPath)
+	require.NoError(t, reloaded.Load())
+	assert.True(t, reloaded.Get().Enabled)
+	assert.Equal(t, "Back soon", reloaded.Get().Message)
+
+	state, err = reloaded.Set(false, "ignored", "ops")
+	require.NoError(t, err)
+	assert.False(t, state.Enabled)
+	assert.Empty(t, state.Message)
+}
@@ -0,0 +1,273 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/svanhalla/prompt-lab/greetd/internal/lockfile"
+)
+
+const (
+	// StatsFileName is the file the greeting counters are persisted to.
+	StatsFileName = "hello_stats.json"
+	// StatsLockFileName is locked while a process saves the counters.
+	StatsLockFileName = "hello_stats.json.lock"
+	// MaxStatsNames bounds the number of names counted; when it is reached
+	// the least greeted name makes room for a new one.
+	MaxStatsNames = 1000
+	// maxStatsNameLength truncates names, in runes, before counting.
+	maxStatsNameLength = 64
+	// statsLockTimeout is how long Save waits for another process saving.
+	statsLockTimeout = 5 * time.Second
+)
+
+// NameCount is the number of greetings for one name.
+type NameCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// HelloStats counts greetings per normalized name in memory. Save writes
+// the counters to disk; they are not persisted on every greeting. Several
+// processes, such as the server and "greetd hello", can count into the
+// same file: Save adds the greetings counted since the last save to the
+// counts on disk rather than replacing them.
+type HelloStats struct {
+	mu       sync.Mutex
+	filePath string
+	lockPath string
+	data     statsData
+	// added holds the greetings counted since the last load or save.
+	added statsData
+	// reset is set by Reset, so the next Save replaces the file.
+	reset    bool
+	dirty    bool
+	readOnly bool
+}
+
+type statsData struct {
+	Total int64            `json:"total"`
+	Names map[string]int64 `json:"names"`
+}
+
+func NewHelloStats(dataPath string) *HelloStats {
+	return &HelloStats{
+		filePath: filepath.Join(dataPath, StatsFileName),
+		lockPath: filepath.Join(dataPath, StatsLockFileName),
+		data:     statsData{Names: make(map[string]int64)},
+		added:    statsData{Names: make(map[string]int64)},
+	}
+}
+
+// Load reads persisted counters. A missing file starts from zero; nothing
+// is written until Save.
+func (s *HelloStats) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.readOnly = CheckWritable(filepath.Dir(s.filePath)) != nil
+
+	loaded, err := s.readUnsafe()
+	if err != nil {
+		return err
+	}
+	s.data = loaded
+	s.added = statsData{Names: make(map[string]int64)}
+	for len(s.data.Names) > MaxStatsNames {
+		evict(s.data.Names)
+	}
+	return nil
+}
+
+// readUnsafe returns the counters on disk, none when the file is missing.
+func (s *HelloStats) readUnsafe() (statsData, error) {
+	loaded := statsData{Names: make(map[string]int64)}
+	data, err := os.ReadFile(s.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return loaded, nil
+	}
+	if err != nil {
+		return loaded, fmt.Errorf("failed to read stats file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return loaded, fmt.Errorf("failed to unmarshal stats data: %w", err)
+	}
+	if loaded.Names == nil {
+		loaded.Names = make(map[string]int64)
+	}
+	return loaded, nil
+}
+
+// NormalizeName returns the key a greeted name is counted under: trimmed,
+// lowercased, with inner whitespace collapsed and truncated to 64 runes.
+func NormalizeName(name string) string {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if utf8.RuneCountInString(name) > maxStatsNameLength {
+		name = string([]rune(name)[:maxStatsNameLength])
+	}
+	return name
+}
+
+// Record counts one greeting for name. Greetings without a name only count
+// towards the total.
+func (s *HelloStats) Record(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Total++
+	s.added.Total++
+	s.dirty = true
+
+	key := NormalizeName(name)
+	if key == "" {
+		return
+	}
+	count(s.data.Names, key, 1)
+	count(s.added.Names, key, 1)
+}
+
+// count adds n greetings for name, making room for a new name by evicting
+// the least greeted one.
+func count(names map[string]int64, name string, n int64) {
+	if _, ok := names[name]; !ok && len(names) >= MaxStatsNames {
+		evict(names)
+	}
+	names[name] += n
+}
+
+// evict drops the least greeted name, preferring to keep the
+// alphabetically first among equals so the result is deterministic.
+func evict(names map[string]int64) {
+	victim := ""
+	var lowest int64 = -1
+	for name, count := range names {
+		if lowest < 0 || count < lowest || (count == lowest && name > victim) {
+			victim, lowest = name, count
+		}
+	}
+	delete(names, victim)
+}
+
+// Top returns up to n names with the most greetings, most greeted first,
+// and the total number of greetings.
+func (s *HelloStats) Top(n int) ([]NameCount, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	top := make([]NameCount, 0, len(s.data.Names))
+	for name, count := range s.data.Names {
+		top = append(top, NameCount{Name: name, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Name < top[j].Name
+	})
+
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top, s.data.Total
+}
+
+// Reset clears all counters.
+func (s *HelloStats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = statsData{Names: make(map[string]int64)}
+	s.added = statsData{Names: make(map[string]int64)}
+	s.reset = true
+	s.dirty = true
+}
+
+// Save writes the counters if they changed since the last save. Under a
+// lock on the file, it rereads the counts on disk and adds those counted
+// since the last save, so greetings counted by other processes are kept;
+// after Reset it replaces them. The file is replaced atomically, so a crash
+// leaves either the old or the new counts.
+func (s *HelloStats) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), statsLockTimeout)
+	defer cancel()
+	lock, err := lockfile.Acquire(ctx, s.lockPath)
+	if err != nil {
+		return fmt.Errorf("failed to lock stats file: %w", err)
+	}
+	defer lock.Release()
+
+	merged := s.data
+	if !s.reset {
+		if merged, err = s.readUnsafe(); err != nil {
+			return err
+		}
+		merged.Total += s.added.Total
+		for name, n := range s.added.Names {
+			count(merged.Names, name, n)
+		}
+	}
+
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal stats data: %w", err)
+	}
+	if err := writeFileAtomic(s.filePath, data); err != nil {
+		return fmt.Errorf("failed to write stats file: %w", err)
+	}
+
+	s.data = merged
+	s.added = statsData{Names: make(map[string]int64)}
+	s.reset = false
+	s.dirty = false
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place once it is synced to disk.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
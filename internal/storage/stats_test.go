@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHelloStats(t *testing.T) {
+	dataPath := t.TempDir()
+
+	stats := NewHelloStats(dataPath)
+	require.NoError(t, stats.Load())
+
+	for _, name := range []string{"Ann", " ann ", "ANN", "Bob", "bob", "Cleo", ""} {
+		stats.Record(name)
+	}
+
+	top, total := stats.Top(2)
+	assert.Equal(t, int64(7), total)
+	assert.Equal(t, []NameCount{{Name: "ann", Count: 3}, {Name: "bob", Count: 2}}, top)
+
+	// Nothing is written until Save.
+	assert.NoFileExists(t, filepath.Join(dataPath, StatsFileName))
+	require.NoError(t, stats.Save())
+
+	reloaded := NewHelloStats(dataPath)
+	require.NoError(t, reloaded.Load())
+	top, total = reloaded.Top(10)
+	assert.Equal(t, int64(7), total)
+	assert.Equal(t, []NameCount{{Name: "ann", Count: 3}, {Name: "bob", Count: 2}, {Name: "cleo", Count: 1}}, top)
+
+	reloaded.Reset()
+	top, total = reloaded.Top(10)
+	assert.Empty(t, top)
+	assert.Zero(t, total)
+}
+
+func TestHelloStatsBounded(t *testing.T) {
+	stats := NewHelloStats(t.TempDir())
+	require.NoError(t, stats.Load())
+
+	stats.Record("popular")
+	stats.Record("popular")
+	for i := 0; i < MaxStatsNames+500; i++ {
+		stats.Record(fmt.Sprintf("scanner-%d", i))
+	}
+
+	top, total := stats.Top(MaxStatsNames + 1)
+	assert.Len(t, top, MaxStatsNames)
+	assert.Equal(t, int64(MaxStatsNames+502), total)
+	assert.Equal(t, NameCount{Name: "popular", Count: 2}, top[0])
+}
+
+func TestHelloStatsAtomicSave(t *testing.T) {
+	dataPath := t.TempDir()
+
+	stats := NewHelloStats(dataPath)
+	require.NoError(t, stats.Load())
+	stats.Record("Ann")
+	require.NoError(t, stats.Save())
+
+	// Only the stats file and its lock remain; the temporary file was
+	// renamed.
+	entries, err := os.ReadDir(dataPath)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, StatsFileName, entries[0].Name())
+	assert.Equal(t, StatsLockFileName, entries[1].Name())
+}
+
+func TestHelloStatsSharedFile(t *testing.T) {
+	dataPath := t.TempDir()
+
+	server := NewHelloStats(dataPath)
+	require.NoError(t, server.Load())
+	server.Record("Ann")
+	require.NoError(t, server.Save())
+
+	// "greetd hello" counts into the same file between the server's saves.
+	cli := NewHelloStats(dataPath)
+	require.NoError(t, cli.Load())
+	cli.Record("Bob")
+	require.NoError(t, cli.Save())
+
+	server.Record("Ann")
+	require.NoError(t, server.Save())
+	top, total := server.Top(10)
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, []NameCount{{Name: "ann", Count: 2}, {Name: "bob", Count: 1}}, top)
+
+	reloaded := NewHelloStats(dataPath)
+	require.NoError(t, reloaded.Load())
+	top, total = reloaded.Top(10)
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, []NameCount{{Name: "ann", Count: 2}, {Name: "bob", Count: 1}}, top)
+
+	// A reset replaces the file, keeping only later greetings.
+	server.Reset()
+	server.Record("Cleo")
+	require.NoError(t, server.Save())
+	require.NoError(t, reloaded.Load())
+	top, total = reloaded.Top(10)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, []NameCount{{Name: "cleo", Count: 1}}, top)
+}
+
+func TestNormalizeName(t *testing.T) {
+	assert.Equal(t, "ann lee", NormalizeName("  Ann \t LEE "))
+	assert.Equal(t, "", NormalizeName("   "))
+	assert.Equal(t, strings.Repeat("a", 64), NormalizeName(strings.Repeat("A", 100)))
+}
@@ -1,74 +1,863 @@
 package storage
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"sync"
+	"time"
+
+	"github.com/svanhalla/prompt-lab/greetd/internal/health"
+	"github.com/svanhalla/prompt-lab/greetd/internal/lockfile"
+)
+
+// ErrReadOnly is returned by SetMessage when the data directory cannot be
+// written to.
+var ErrReadOnly = errors.New("storage is read-only")
+
+var (
+	// ErrInvalidKey is returned for keys that do not match [a-z0-9_-]{1,64}.
+	ErrInvalidKey = errors.New("key must be 1-64 characters of a-z, 0-9, - and _")
+	// ErrTooManyKeys is returned when a new key would exceed the limit.
+	ErrTooManyKeys = errors.New("too many messages")
+	// ErrNotFound is returned for keys without a stored message.
+	ErrNotFound = errors.New("message not found")
+	// ErrConflict is returned by CompareAndSetKey when the message was
+	// changed since the expected revision.
+	ErrConflict = errors.New("message was changed by someone else")
+	// ErrNothingToUndo is returned by UndoKey without an earlier message.
+	ErrNothingToUndo = errors.New("nothing to undo")
+	// ErrNothingToRedo is returned by RedoKey when nothing was undone since
+	// the last change.
+	ErrNothingToRedo = errors.New("nothing to redo")
+	// ErrNoDraft is returned for keys without a stored draft.
+	ErrNoDraft = errors.New("no draft")
 )
 
+const (
+	// MessagesFileName is the file the messages are persisted to.
+	MessagesFileName = "messages.json"
+	// MessagesLockFileName is locked while a process changes
+	// MessagesFileName, so writes from the server and the CLI take turns.
+	MessagesLockFileName = "messages.json.lock"
+	// LegacyMessageFileName held the single message before keyed messages.
+	// It is read when MessagesFileName does not exist yet and removed once
+	// the messages are saved.
+	LegacyMessageFileName = "message.json"
+	// DefaultKey is the key served by /message.
+	DefaultKey = "default"
+	// DefaultMaxKeys is the number of keys allowed unless SetMaxKeys is
+	// called.
+	DefaultMaxKeys = 100
+	// DefaultMessage is served for the default key until one is set.
+	DefaultMessage = "Hello, World!"
+	// MaxHistory bounds the number of earlier messages kept per key for
+	// undo, and of undone messages kept for redo.
+	MaxHistory = 20
+)
+
+var keyPattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// ValidKey reports whether key can name a message.
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// MessageStore holds named messages. The default key always has a message:
+// DefaultMessage until another one is set.
+//
+// Its methods take the context of the request they serve. Reads are answered
+// from memory and ignore it; writes check it once they hold the lock and
+// return its error without changing anything when it is done.
+//
+// Several processes can share the data directory. Writes hold the lock file
+// and reread messages.json before changing it, so no process overwrites a
+// change it has not seen, and reads pick up a messages.json replaced by
+// another process. Subscribe reports the changes as they are seen.
 type MessageStore struct {
-	mu       sync.RWMutex
-	filePath string
-	data     MessageData
+	mu         sync.RWMutex
+	filePath   string
+	legacyPath string
+	lockPath   string
+	messages   map[string]MessageData
+	history    map[string]MessageHistory
+	drafts     map[string]MessageData
+	deleted    map[string]int64
+	maxKeys    int
+	readOnly   bool
+	// keys encrypts messages.json; nil stores it in plaintext.
+	keys *Keyring
+	// loaded is messages.json as last read or written, nil when it did not
+	// exist; a different file on disk was written by another process.
+	loaded os.FileInfo
+	// sum is the SHA-256 of messages.json as last read or written, so a
+	// reread of an unchanged file, such as our own write, is not a change.
+	sum [sha256.Size]byte
+
+	subscribersMu sync.Mutex
+	subscribers   map[chan ChangeEvent]struct{}
+}
+
+// ChangeEvent reports a change to the stored messages.
+type ChangeEvent struct {
+	Time time.Time
+	// Reloaded is set when messages.json was changed by another process or
+	// program and has been read again; it is unset for writes through the
+	// store.
+	Reloaded bool
+	// Err is set when a changed messages.json could not be read. The
+	// messages in memory are kept.
+	Err error
 }
 
+// Writers recorded in MessageData.UpdatedBy besides API keys.
+const (
+	UpdatedByCLI = "cli"
+	UpdatedByUI  = "ui"
+	UpdatedByAPI = "api"
+)
+
 type MessageData struct {
 	Message string `json:"message"`
+	// UpdatedAt is when the message was last set; zero for the default
+	// message.
+	UpdatedAt time.Time `json:"updated_at"`
+	// UpdatedBy names the writer: an API key name, "api", "cli" or "ui".
+	UpdatedBy string `json:"updated_by,omitempty"`
+	// Revision counts the writes to the message; 0 until it is first set.
+	Revision int64 `json:"revision"`
+}
+
+// MessageHistory holds the earlier messages of a key, most recent last.
+type MessageHistory struct {
+	Undo []MessageData `json:"undo,omitempty"`
+	Redo []MessageData `json:"redo,omitempty"`
+}
+
+// MessagesFile is the format of messages.json.
+type MessagesFile struct {
+	Messages map[string]MessageData    `json:"messages"`
+	History  map[string]MessageHistory `json:"history,omitempty"`
+	// Drafts are messages staged to replace those under the same keys.
+	Drafts map[string]MessageData `json:"drafts,omitempty"`
+	// Deleted holds the last revision of each deleted key, so its
+	// revisions count on when it is set again and a stale If-Match
+	// cannot match a new message.
+	Deleted map[string]int64 `json:"deleted,omitempty"`
+}
+
+// KeyInfo describes one stored message without its text.
+type KeyInfo struct {
+	Key       string
+	UpdatedAt time.Time
+	UpdatedBy string
 }
 
 func NewMessageStore(dataPath string) *MessageStore {
 	return &MessageStore{
-		filePath: filepath.Join(dataPath, "message.json"),
-		data:     MessageData{Message: "Hello, World!"},
+		filePath:   filepath.Join(dataPath, MessagesFileName),
+		legacyPath: filepath.Join(dataPath, LegacyMessageFileName),
+		lockPath:   filepath.Join(dataPath, MessagesLockFileName),
+		messages:   make(map[string]MessageData),
+		history:    make(map[string]MessageHistory),
+		drafts:     make(map[string]MessageData),
+		deleted:    make(map[string]int64),
+		maxKeys:    DefaultMaxKeys,
 	}
 }
 
-func (s *MessageStore) Load() error {
+// SetMaxKeys limits the number of stored keys, including the default key
+// once it is set. Existing keys beyond the limit are kept.
+func (s *MessageStore) SetMaxKeys(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxKeys = n
+}
+
+// SetKeyring makes the store write messages.json encrypted with the first
+// of keys, and read it encrypted with any of them. A plaintext file is still
+// read, and encrypted on the next write. nil, the default, writes plaintext.
+func (s *MessageStore) SetKeyring(keys *Keyring) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+// Keyring returns the keys messages.json is encrypted with, nil when it is
+// stored in plaintext.
+func (s *MessageStore) Keyring() *Keyring {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys
+}
+
+// Load reads the stored messages, falling back to a legacy message.json for
+// the default key. Missing files leave the default message in place; nothing
+// is written until a message is set. When the data directory is not writable
+// the store is marked read-only.
+func (s *MessageStore) Load(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, err := os.Stat(s.filePath); os.IsNotExist(err) {
-		// Create with default message
-		return s.saveUnsafe()
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	data, err := os.ReadFile(s.filePath)
+	s.readOnly = CheckWritable(filepath.Dir(s.filePath)) != nil
+	return s.readUnsafe()
+}
+
+// readUnsafe replaces the messages in memory with those on disk. They are
+// left in place if the files cannot be read.
+func (s *MessageStore) readUnsafe() error {
+	file, err := os.Open(s.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		messages := make(map[string]MessageData)
+		if legacy, ok, err := s.readLegacy(); err != nil {
+			return err
+		} else if ok {
+			messages[DefaultKey] = legacy
+		}
+		s.messages, s.history, s.drafts = messages, make(map[string]MessageHistory), make(map[string]MessageData)
+		s.deleted = make(map[string]int64)
+		s.loaded, s.sum = nil, [sha256.Size]byte{}
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to read message file: %w", err)
+		return fmt.Errorf("failed to read messages file: %w", err)
 	}
+	defer file.Close()
 
-	if err := json.Unmarshal(data, &s.data); err != nil {
-		return fmt.Errorf("failed to unmarshal message data: %w", err)
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read messages file: %w", err)
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("failed to read messages file: %w", err)
+	}
+	plaintext, err := s.keys.Decrypt(data)
+	if err != nil {
+		return err
+	}
+
+	var stored MessagesFile
+	if err := json.Unmarshal(plaintext, &stored); err != nil {
+		return fmt.Errorf("failed to unmarshal messages: %w", err)
+	}
+	s.messages = make(map[string]MessageData, len(stored.Messages))
+	for key, message := range stored.Messages {
+		if ValidKey(key) {
+			s.messages[key] = message
+		}
+	}
+	s.history = make(map[string]MessageHistory, len(stored.History))
+	for key, history := range stored.History {
+		if ValidKey(key) {
+			s.history[key] = history
+		}
+	}
+	s.drafts = make(map[string]MessageData, len(stored.Drafts))
+	for key, draft := range stored.Drafts {
+		if ValidKey(key) {
+			s.drafts[key] = draft
+		}
 	}
+	s.deleted = make(map[string]int64, len(stored.Deleted))
+	for key, revision := range stored.Deleted {
+		if ValidKey(key) {
+			s.deleted[key] = revision
+		}
+	}
+	s.loaded = info
+	s.sum = sha256.Sum256(data)
+	return nil
+}
 
+// reloadUnsafe is readUnsafe that notifies the subscribers when the
+// content differs from what was last read or written.
+func (s *MessageStore) reloadUnsafe() error {
+	previous := s.sum
+	if err := s.readUnsafe(); err != nil {
+		return err
+	}
+	if s.sum != previous {
+		s.notify(ChangeEvent{Time: time.Now(), Reloaded: true})
+	}
 	return nil
 }
 
-func (s *MessageStore) GetMessage() string {
+// changedOnDisk reports whether messages.json is not the file last read or
+// written, checking the file's identity, size and modification time.
+func (s *MessageStore) changedOnDisk() bool {
+	info, err := os.Stat(s.filePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.data.Message
+	if info == nil || s.loaded == nil {
+		return info != s.loaded
+	}
+	return !os.SameFile(info, s.loaded) || info.Size() != s.loaded.Size() || !info.ModTime().Equal(s.loaded.ModTime())
+}
+
+// refresh rereads messages.json when another process replaced it. A file
+// that cannot be read leaves the messages in memory in place.
+func (s *MessageStore) refresh() {
+	s.reload()
+}
+
+// reload is refresh that returns why a changed messages.json could not be
+// read.
+func (s *MessageStore) reload() error {
+	if !s.changedOnDisk() {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reloadUnsafe()
+}
+
+// lockUnsafe takes the lock file shared with other processes and rereads
+// messages.json, so a write applies to the latest messages. It waits for
+// the lock until ctx is done. The returned function releases the lock. A
+// read-only store is only reread, since its writes fail anyway.
+func (s *MessageStore) lockUnsafe(ctx context.Context) (func(), error) {
+	if s.readOnly {
+		return func() {}, s.reloadUnsafe()
+	}
+
+	lock, err := lockfile.Acquire(ctx, s.lockPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock messages: %w", err)
+	}
+	if err := s.reloadUnsafe(); err != nil {
+		lock.Release()
+		return nil, err
+	}
+	return func() { lock.Release() }, nil
+}
+
+// readLegacy reads message.json, the message for the default key, if it
+// exists.
+func (s *MessageStore) readLegacy() (MessageData, bool, error) {
+	data, err := os.ReadFile(s.legacyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return MessageData{}, false, nil
+	}
+	if err != nil {
+		return MessageData{}, false, fmt.Errorf("failed to read message file: %w", err)
+	}
+
+	var message MessageData
+	if err := json.Unmarshal(data, &message); err != nil {
+		return MessageData{}, false, fmt.Errorf("failed to unmarshal message data: %w", err)
+	}
+
+	// Files written before updated_at existed date from their last write.
+	if message.UpdatedAt.IsZero() {
+		if info, err := os.Stat(s.legacyPath); err == nil {
+			message.UpdatedAt = info.ModTime().UTC().Truncate(time.Second)
+		}
+	}
+
+	return message, true, nil
+}
+
+func (s *MessageStore) GetMessage(ctx context.Context) string {
+	return s.Get(ctx).Message
+}
+
+// Get returns the default message with its metadata.
+func (s *MessageStore) Get(ctx context.Context) MessageData {
+	data, _ := s.GetKey(ctx, DefaultKey)
+	return data
+}
+
+// GetKey returns the message stored under key. The default key always has a
+// message; other keys return ErrNotFound until they are set.
+func (s *MessageStore) GetKey(ctx context.Context, key string) (MessageData, error) {
+	s.refresh()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.messages[key]; !ok && key != DefaultKey {
+		return MessageData{}, ErrNotFound
+	}
+	return s.getKeyUnsafe(key), nil
+}
+
+// Revisions returns the revisions of the message stored under key that are
+// still known, oldest first: the current one and those kept for undo and
+// redo. Other keys than the default one return ErrNotFound until they are
+// set.
+func (s *MessageStore) Revisions(ctx context.Context, key string) ([]MessageData, error) {
+	s.refresh()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.messages[key]; !ok && key != DefaultKey {
+		return nil, ErrNotFound
+	}
+	history := s.history[key]
+	revisions := make([]MessageData, 0, len(history.Undo)+len(history.Redo)+1)
+	revisions = append(revisions, history.Undo...)
+	revisions = append(revisions, history.Redo...)
+	revisions = append(revisions, s.getKeyUnsafe(key))
+	sort.SliceStable(revisions, func(i, j int) bool { return revisions[i].Revision < revisions[j].Revision })
+	return slices.CompactFunc(revisions, func(a, b MessageData) bool { return a.Revision == b.Revision }), nil
+}
+
+// Keys lists the keys with a message, sorted, including the default key.
+func (s *MessageStore) Keys(ctx context.Context) []KeyInfo {
+	s.refresh()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]KeyInfo, 0, len(s.messages)+1)
+	if _, ok := s.messages[DefaultKey]; !ok {
+		keys = append(keys, KeyInfo{Key: DefaultKey})
+	}
+	for key, data := range s.messages {
+		keys = append(keys, KeyInfo{Key: key, UpdatedAt: data.UpdatedAt, UpdatedBy: data.UpdatedBy})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	return keys
+}
+
+// Len returns the number of keys with a stored message. An unset default
+// key is not counted.
+func (s *MessageStore) Len() int {
+	s.refresh()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.messages)
+}
+
+// ReadOnly reports whether Load found the data directory unwritable.
+func (s *MessageStore) ReadOnly() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readOnly
+}
+
+// HealthCheck returns the "storage" check of the store: a warning when Load
+// found the data directory unwritable, or when it can no longer be written
+// to, since everything but changing messages still works.
+func (s *MessageStore) HealthCheck() health.Checker {
+	return health.CheckFunc("storage", func(ctx context.Context) error {
+		if s.ReadOnly() {
+			return health.Warning("read-only")
+		}
+		if err := CheckWritable(filepath.Dir(s.filePath)); err != nil {
+			return health.Warning("not writable: " + err.Error())
+		}
+		return nil
+	})
+}
+
+// SetMessage stores message under the default key, recording when and by
+// whom it was set.
+func (s *MessageStore) SetMessage(ctx context.Context, message, updatedBy string) error {
+	return s.SetKey(ctx, DefaultKey, message, updatedBy)
+}
+
+// SetKey stores message under key, recording when and by whom it was set.
+func (s *MessageStore) SetKey(ctx context.Context, key, message, updatedBy string) error {
+	_, _, err := s.SwapKey(ctx, key, message, updatedBy)
+	return err
 }
 
-func (s *MessageStore) SetMessage(message string) error {
+// SwapKey is SetKey that also returns the message it replaced, read under
+// the same lock as the write. previous is nil when the key had no message;
+// the default key always has one.
+func (s *MessageStore) SwapKey(ctx context.Context, key, message, updatedBy string) (previous *MessageData, stored MessageData, err error) {
+	if !ValidKey(key) {
+		return nil, MessageData{}, ErrInvalidKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lockUnsafe(ctx)
+	if err != nil {
+		return nil, MessageData{}, err
+	}
+	defer unlock()
+
+	return s.setKeyUnsafe(ctx, key, message, updatedBy)
+}
+
+// CompareAndSetMessage is CompareAndSetKey for the default key.
+func (s *MessageStore) CompareAndSetMessage(ctx context.Context, message, updatedBy string, revision int64) (MessageData, error) {
+	return s.CompareAndSetKey(ctx, DefaultKey, message, updatedBy, revision)
+}
+
+// CompareAndSetKey stores message under key only if the stored revision
+// still equals revision; 0 expects a key that was never set. It returns the
+// stored message, or the current one alongside ErrConflict.
+func (s *MessageStore) CompareAndSetKey(ctx context.Context, key, message, updatedBy string, revision int64) (MessageData, error) {
+	_, stored, err := s.CompareAndSwapKey(ctx, key, message, updatedBy, revision)
+	return stored, err
+}
+
+// CompareAndSwapKey is CompareAndSetKey that also returns the message it
+// replaced, as SwapKey does.
+func (s *MessageStore) CompareAndSwapKey(ctx context.Context, key, message, updatedBy string, revision int64) (previous *MessageData, stored MessageData, err error) {
+	if !ValidKey(key) {
+		return nil, MessageData{}, ErrInvalidKey
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.data.Message = message
-	return s.saveUnsafe()
+	unlock, err := s.lockUnsafe(ctx)
+	if err != nil {
+		return nil, MessageData{}, err
+	}
+	defer unlock()
+
+	if current := s.getKeyUnsafe(key); current.Revision != revision {
+		return nil, current, ErrConflict
+	}
+	return s.setKeyUnsafe(ctx, key, message, updatedBy)
+}
+
+// getKeyUnsafe returns the message stored under key, the default message
+// for an unset default key, and a zero MessageData for other unset keys.
+func (s *MessageStore) getKeyUnsafe(key string) MessageData {
+	if data, ok := s.messages[key]; ok {
+		return data
+	}
+	if key == DefaultKey {
+		return MessageData{Message: DefaultMessage}
+	}
+	return MessageData{}
+}
+
+// nextRevisionUnsafe returns the revision of the next write to key,
+// counting on from the last revision of a deleted key.
+func (s *MessageStore) nextRevisionUnsafe(key string) int64 {
+	return max(s.getKeyUnsafe(key).Revision, s.deleted[key]) + 1
+}
+
+// setKeyUnsafe stores message under key and returns the message it
+// replaced, nil for an unset key other than the default one.
+func (s *MessageStore) setKeyUnsafe(ctx context.Context, key, message, updatedBy string) (*MessageData, MessageData, error) {
+	if s.readOnly {
+		return nil, MessageData{}, ErrReadOnly
+	}
+	_, exists := s.messages[key]
+	if !exists && len(s.messages) >= s.maxKeys {
+		return nil, MessageData{}, ErrTooManyKeys
+	}
+
+	// The replaced message can be restored with UndoKey; an unset key
+	// other than the default one has nothing to go back to.
+	current := s.getKeyUnsafe(key)
+	history := MessageHistory{Undo: s.history[key].Undo}
+	var previous *MessageData
+	if exists || key == DefaultKey {
+		history.Undo = pushHistory(history.Undo, current)
+		previous = &current
+	}
+
+	data := MessageData{
+		Message:   message,
+		UpdatedAt: time.Now().UTC().Truncate(time.Second),
+		UpdatedBy: updatedBy,
+		Revision:  s.nextRevisionUnsafe(key),
+	}
+	if err := s.replaceUnsafe(ctx, key, &data, history); err != nil {
+		return nil, MessageData{}, err
+	}
+	return previous, data, nil
+}
+
+// UndoKey restores the message stored under key before the last change,
+// keeping the undone message for RedoKey. The restored message is a new
+// revision written by updatedBy.
+func (s *MessageStore) UndoKey(ctx context.Context, key, updatedBy string) (MessageData, error) {
+	return s.restore(ctx, key, updatedBy, false)
 }
 
-func (s *MessageStore) saveUnsafe() error {
-	data, err := json.MarshalIndent(s.data, "", "  ")
+// RedoKey restores the message undone last with UndoKey.
+func (s *MessageStore) RedoKey(ctx context.Context, key, updatedBy string) (MessageData, error) {
+	return s.restore(ctx, key, updatedBy, true)
+}
+
+func (s *MessageStore) restore(ctx context.Context, key, updatedBy string, redo bool) (MessageData, error) {
+	if !ValidKey(key) {
+		return MessageData{}, ErrInvalidKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return MessageData{}, ErrReadOnly
+	}
+	unlock, err := s.lockUnsafe(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to marshal message data: %w", err)
+		return MessageData{}, err
 	}
+	defer unlock()
 
-	if err := os.WriteFile(s.filePath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write message file: %w", err)
+	from, to, empty := s.history[key].Undo, s.history[key].Redo, ErrNothingToUndo
+	if redo {
+		from, to, empty = to, from, ErrNothingToRedo
+	}
+	if len(from) == 0 {
+		return MessageData{}, empty
+	}
+
+	current := s.getKeyUnsafe(key)
+	target := from[len(from)-1]
+	from = from[:len(from)-1]
+	to = pushHistory(to, current)
+
+	history := MessageHistory{Undo: from, Redo: to}
+	if redo {
+		history = MessageHistory{Undo: to, Redo: from}
+	}
+
+	data := MessageData{
+		Message:   target.Message,
+		UpdatedAt: time.Now().UTC().Truncate(time.Second),
+		UpdatedBy: updatedBy,
+		Revision:  s.nextRevisionUnsafe(key),
+	}
+	if err := s.replaceUnsafe(ctx, key, &data, history); err != nil {
+		return MessageData{}, err
+	}
+	return data, nil
+}
+
+// pushHistory returns a copy of list with data appended, dropping the
+// oldest entries beyond MaxHistory.
+func pushHistory(list []MessageData, data MessageData) []MessageData {
+	pushed := append(append(make([]MessageData, 0, len(list)+1), list...), data)
+	if len(pushed) > MaxHistory {
+		pushed = pushed[len(pushed)-MaxHistory:]
+	}
+	return pushed
+}
+
+// replaceUnsafe stores data and history under key, or removes both when
+// data is nil, and saves. The previous state is restored if saving fails.
+func (s *MessageStore) replaceUnsafe(ctx context.Context, key string, data *MessageData, history MessageHistory) error {
+	previous, exists := s.messages[key]
+	previousHistory, hadHistory := s.history[key]
+
+	if data != nil {
+		s.messages[key] = *data
+	} else {
+		delete(s.messages, key)
+	}
+	if len(history.Undo) > 0 || len(history.Redo) > 0 {
+		s.history[key] = history
+	} else {
+		delete(s.history, key)
 	}
 
+	if err := s.saveUnsafe(ctx); err != nil {
+		if exists {
+			s.messages[key] = previous
+		} else {
+			delete(s.messages, key)
+		}
+		if hadHistory {
+			s.history[key] = previousHistory
+		} else {
+			delete(s.history, key)
+		}
+		return err
+	}
 	return nil
 }
+
+// DeleteKey removes the message stored under key along with its history.
+// Deleting the default key restores DefaultMessage. The revision counts on
+// from the deleted message when the key is set again.
+func (s *MessageStore) DeleteKey(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lockUnsafe(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, exists := s.messages[key]; !exists {
+		if key == DefaultKey {
+			return nil
+		}
+		return ErrNotFound
+	}
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	previous, hadDeleted := s.deleted[key]
+	s.deleted[key] = s.nextRevisionUnsafe(key) - 1
+	if err := s.replaceUnsafe(ctx, key, nil, MessageHistory{}); err != nil {
+		if hadDeleted {
+			s.deleted[key] = previous
+		} else {
+			delete(s.deleted, key)
+		}
+		return err
+	}
+	return nil
+}
+
+// marshalUnsafe returns the messages as they are written to messages.json,
+// encrypted when the store has keys.
+func (s *MessageStore) marshalUnsafe() ([]byte, error) {
+	data, err := json.MarshalIndent(MessagesFile{Messages: s.messages, History: s.history, Drafts: s.drafts, Deleted: s.deleted}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal messages: %w", err)
+	}
+	if s.keys != nil {
+		return s.keys.seal(data)
+	}
+	return data, nil
+}
+
+// Rewrite writes messages.json again, encrypted with the first of keys, or
+// in plaintext when keys is nil, and keeps using keys from then on. The
+// file is read with the keys the store had, so Rewrite encrypts,
+// decrypts or re-encrypts it with a new key. Nothing is written when there
+// are no stored messages.
+func (s *MessageStore) Rewrite(ctx context.Context, keys *Keyring) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	unlock, err := s.lockUnsafe(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	previous := s.keys
+	s.keys = keys
+	if s.loaded == nil && len(s.messages) == 0 {
+		return nil
+	}
+	if err := s.saveUnsafe(ctx); err != nil {
+		s.keys = previous
+		return err
+	}
+	return nil
+}
+
+// saveUnsafe writes the messages, unless ctx is done; nothing is written
+// then.
+func (s *MessageStore) saveUnsafe(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := s.marshalUnsafe()
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(s.filePath, data); err != nil {
+		return fmt.Errorf("failed to write messages file: %w", err)
+	}
+	// Other processes wait for the lock before writing, so this is the
+	// file just written.
+	if info, err := os.Stat(s.filePath); err == nil {
+		s.loaded = info
+	}
+	s.sum = sha256.Sum256(data)
+	s.notify(ChangeEvent{Time: time.Now()})
+
+	// The legacy file has been carried over into messages.json.
+	if err := os.Remove(s.legacyPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %w", LegacyMessageFileName, err)
+	}
+	return nil
+}
+
+// Subscribe returns a channel that receives every change to the messages,
+// and a function that stops the subscription. Events are dropped for a
+// subscriber that has queue events waiting, so a slow reader never holds up
+// a write.
+func (s *MessageStore) Subscribe(queue int) (events <-chan ChangeEvent, cancel func()) {
+	ch := make(chan ChangeEvent, queue)
+
+	s.subscribersMu.Lock()
+	if s.subscribers == nil {
+		s.subscribers = make(map[chan ChangeEvent]struct{})
+	}
+	s.subscribers[ch] = struct{}{}
+	s.subscribersMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.subscribersMu.Lock()
+			delete(s.subscribers, ch)
+			s.subscribersMu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (s *MessageStore) notify(event ChangeEvent) {
+	s.subscribersMu.Lock()
+	defer s.subscribersMu.Unlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// CheckWritable reports whether files can be created in dir. A missing dir
+// is checked through its nearest existing parent, since it would be created
+// on the first write. Nothing is left behind.
+func CheckWritable(dir string) error {
+	file, err := os.CreateTemp(nearestExisting(dir), ".greetd-write-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// nearestExisting returns dir, or its closest ancestor that exists.
+func nearestExisting(dir string) string {
+	for {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
@@ -1,98 +1,428 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/health"
 )
 
-func TestMessageStore(t *testing.T) {
+func TestMessageStoreFileExists(t *testing.T) {
 	// Create temporary directory
 	tmpDir, err := os.MkdirTemp("", "greetd-test")
 	require.NoError(t, err)
 	defer os.RemoveAll(tmpDir)
 
-	store := NewMessageStore(tmpDir)
+	// Create message file with custom content
+	messageFile := filepath.Join(tmpDir, "message.json")
+	content := `{"message": "Existing message"}`
+	err = os.WriteFile(messageFile, []byte(content), 0644)
+	require.NoError(t, err)
 
-	// Test initial load (should create default)
-	err = store.Load()
+	store := NewMessageStore(tmpDir)
+	err = store.Load(context.Background())
 	require.NoError(t, err)
 
-	// Test default message
-	message := store.GetMessage()
-	assert.Equal(t, "Hello, World!", message)
+	message := store.GetMessage(context.Background())
+	assert.Equal(t, "Existing message", message)
+}
+
+func TestMessageStoreHistoryBounded(t *testing.T) {
+	store := NewMessageStore(t.TempDir())
+	require.NoError(t, store.Load(context.Background()))
+
+	for i := 1; i <= MaxHistory+5; i++ {
+		require.NoError(t, store.SetMessage(context.Background(), strconv.Itoa(i), UpdatedByAPI))
+	}
+
+	var last MessageData
+	var undone int
+	for {
+		data, err := store.UndoKey(context.Background(), DefaultKey, UpdatedByAPI)
+		if errors.Is(err, ErrNothingToUndo) {
+			break
+		}
+		require.NoError(t, err)
+		last = data
+		undone++
+	}
+	assert.Equal(t, MaxHistory, undone)
+	assert.Equal(t, "5", last.Message)
+}
+
+func TestMessageStoreUndoInterleaved(t *testing.T) {
+	store := NewMessageStore(t.TempDir())
+	require.NoError(t, store.Load(context.Background()))
+
+	var wg sync.WaitGroup
+	var writes atomic.Int64
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				if assert.NoError(t, store.SetMessage(context.Background(), "set", UpdatedByAPI)) {
+					writes.Add(1)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				_, err := store.UndoKey(context.Background(), DefaultKey, UpdatedByAPI)
+				if err == nil {
+					writes.Add(1)
+				} else {
+					assert.ErrorIs(t, err, ErrNothingToUndo)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every successful set and undo produced exactly one revision.
+	assert.Equal(t, writes.Load(), store.Get(context.Background()).Revision)
+}
+
+func TestMessageStoreCreatesDataPathOnSave(t *testing.T) {
+	dataPath := filepath.Join(t.TempDir(), "nested", "data")
+
+	store := NewMessageStore(dataPath)
+	require.NoError(t, store.Load(context.Background()))
+	assert.NoDirExists(t, dataPath)
+
+	require.NoError(t, store.SetMessage(context.Background(), "persisted", UpdatedByCLI))
+	assert.FileExists(t, filepath.Join(dataPath, MessagesFileName))
+}
 
-	// Test setting message
-	newMessage := "Hello, Universe!"
-	err = store.SetMessage(newMessage)
+func TestMessageStoreMetadata(t *testing.T) {
+	dataPath := t.TempDir()
+
+	store := NewMessageStore(dataPath)
+	require.NoError(t, store.Load(context.Background()))
+	assert.True(t, store.Get(context.Background()).UpdatedAt.IsZero())
+
+	before := time.Now().Add(-time.Second)
+	require.NoError(t, store.SetMessage(context.Background(), "Hi", UpdatedByUI))
+
+	reloaded := NewMessageStore(dataPath)
+	require.NoError(t, reloaded.Load(context.Background()))
+	data := reloaded.Get(context.Background())
+	assert.Equal(t, "Hi", data.Message)
+	assert.Equal(t, UpdatedByUI, data.UpdatedBy)
+	assert.True(t, data.UpdatedAt.After(before))
+	assert.Equal(t, store.Get(context.Background()).UpdatedAt, data.UpdatedAt)
+}
+
+func TestMessageStoreLegacyFile(t *testing.T) {
+	dataPath := t.TempDir()
+	path := filepath.Join(dataPath, "message.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"message": "Old"}`), 0644))
+	mtime := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+
+	store := NewMessageStore(dataPath)
+	require.NoError(t, store.Load(context.Background()))
+	data := store.Get(context.Background())
+	assert.Equal(t, "Old", data.Message)
+	assert.True(t, mtime.Equal(data.UpdatedAt))
+	assert.Empty(t, data.UpdatedBy)
+
+	// The first write carries the legacy message over into messages.json.
+	require.NoError(t, store.SetKey(context.Background(), "motd", "Welcome", UpdatedByCLI))
+	assert.NoFileExists(t, path)
+
+	reloaded := NewMessageStore(dataPath)
+	require.NoError(t, reloaded.Load(context.Background()))
+	assert.Equal(t, "Old", reloaded.GetMessage(context.Background()))
+	motd, err := reloaded.GetKey(context.Background(), "motd")
 	require.NoError(t, err)
+	assert.Equal(t, "Welcome", motd.Message)
+}
+
+func TestMessageStoreKeys(t *testing.T) {
+	dataPath := t.TempDir()
+
+	store := NewMessageStore(dataPath)
+	require.NoError(t, store.Load(context.Background()))
 
-	// Test getting updated message
-	message = store.GetMessage()
-	assert.Equal(t, newMessage, message)
+	_, err := store.GetKey(context.Background(), "motd")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, []KeyInfo{{Key: DefaultKey}}, store.Keys(context.Background()))
 
-	// Test persistence by creating new store
-	store2 := NewMessageStore(tmpDir)
-	err = store2.Load()
+	require.NoError(t, store.SetKey(context.Background(), "motd", "Welcome", UpdatedByAPI))
+	require.NoError(t, store.SetKey(context.Background(), "footer", "Bye", UpdatedByUI))
+	for _, key := range []string{"", "Upper", "with space", "a/b", strings.Repeat("k", 65)} {
+		assert.ErrorIs(t, store.SetKey(context.Background(), key, "x", UpdatedByAPI), ErrInvalidKey, "key %q", key)
+	}
+
+	keys := store.Keys(context.Background())
+	require.Len(t, keys, 3)
+	assert.Equal(t, []string{DefaultKey, "footer", "motd"}, []string{keys[0].Key, keys[1].Key, keys[2].Key})
+	assert.Equal(t, UpdatedByUI, keys[1].UpdatedBy)
+
+	reloaded := NewMessageStore(dataPath)
+	require.NoError(t, reloaded.Load(context.Background()))
+	data, err := reloaded.GetKey(context.Background(), "motd")
 	require.NoError(t, err)
+	assert.Equal(t, "Welcome", data.Message)
+	assert.Equal(t, "Hello, World!", reloaded.GetMessage(context.Background()))
+
+	require.NoError(t, reloaded.DeleteKey(context.Background(), "motd"))
+	assert.ErrorIs(t, reloaded.DeleteKey(context.Background(), "motd"), ErrNotFound)
+	_, err = reloaded.GetKey(context.Background(), "motd")
+	assert.ErrorIs(t, err, ErrNotFound)
 
-	message = store2.GetMessage()
-	assert.Equal(t, newMessage, message)
+	// Deleting the default key restores the default message.
+	require.NoError(t, reloaded.SetMessage(context.Background(), "Custom", UpdatedByAPI))
+	require.NoError(t, reloaded.DeleteKey(context.Background(), DefaultKey))
+	assert.Equal(t, DefaultMessage, reloaded.GetMessage(context.Background()))
 }
 
-func TestMessageStoreFileExists(t *testing.T) {
-	// Create temporary directory
-	tmpDir, err := os.MkdirTemp("", "greetd-test")
-	require.NoError(t, err)
-	defer os.RemoveAll(tmpDir)
+func TestMessageStoreMaxKeys(t *testing.T) {
+	store := NewMessageStore(t.TempDir())
+	require.NoError(t, store.Load(context.Background()))
+	store.SetMaxKeys(2)
 
-	// Create message file with custom content
-	messageFile := filepath.Join(tmpDir, "message.json")
-	content := `{"message": "Existing message"}`
-	err = os.WriteFile(messageFile, []byte(content), 0644)
+	require.NoError(t, store.SetKey(context.Background(), "one", "1", UpdatedByAPI))
+	require.NoError(t, store.SetKey(context.Background(), "two", "2", UpdatedByAPI))
+	assert.ErrorIs(t, store.SetKey(context.Background(), "three", "3", UpdatedByAPI), ErrTooManyKeys)
+
+	// Existing keys can still be replaced, and deleting one makes room.
+	require.NoError(t, store.SetKey(context.Background(), "two", "2 again", UpdatedByAPI))
+	require.NoError(t, store.DeleteKey(context.Background(), "one"))
+	require.NoError(t, store.SetKey(context.Background(), "three", "3", UpdatedByAPI))
+}
+
+func TestMessageStoreReadOnly(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+
+	dataPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dataPath, "message.json"), []byte(`{"message": "Existing message"}`), 0644))
+	require.NoError(t, os.Chmod(dataPath, 0500))
+	t.Cleanup(func() { os.Chmod(dataPath, 0700) })
+
+	store := NewMessageStore(dataPath)
+	require.NoError(t, store.Load(context.Background()))
+	assert.True(t, store.ReadOnly())
+	assert.Equal(t, "Existing message", store.GetMessage(context.Background()))
+
+	assert.ErrorIs(t, store.SetMessage(context.Background(), "changed", UpdatedByCLI), ErrReadOnly)
+	assert.Equal(t, "Existing message", store.GetMessage(context.Background()))
+
+	// A missing data path below a read-only directory cannot be created.
+	store = NewMessageStore(filepath.Join(dataPath, "data"))
+	require.NoError(t, store.Load(context.Background()))
+	assert.True(t, store.ReadOnly())
+	assert.Equal(t, "Hello, World!", store.GetMessage(context.Background()))
+}
+
+// writerDirEnv makes the test binary run TestMessageStoreWriterProcess as a
+// writer against the data directory it names.
+const writerDirEnv = "GREETD_TEST_WRITER_DIR"
+
+const processWrites = 25
+
+func TestMessageStoreMultipleProcesses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts several processes")
+	}
+	dataPath := t.TempDir()
+
+	// Each process adds keys of its own and increments a shared counter,
+	// with the store loaded once at startup, like the server and the CLI.
+	const processes = 4
+	var wg sync.WaitGroup
+	for i := 0; i < processes; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestMessageStoreWriterProcess$", "-test.count=1")
+		cmd.Env = append(os.Environ(), writerDirEnv+"="+dataPath, "GREETD_TEST_WRITER_ID="+strconv.Itoa(i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := cmd.CombinedOutput()
+			assert.NoError(t, err, "%s", out)
+		}()
+	}
+	wg.Wait()
+
+	store := NewMessageStore(dataPath)
+	require.NoError(t, store.Load(context.Background()))
+	assert.Equal(t, strconv.Itoa(processes*processWrites), store.GetMessage(context.Background()))
+	assert.Len(t, store.Keys(context.Background()), processes*processWrites+1)
+}
+
+// TestMessageStoreWriterProcess is run by TestMessageStoreMultipleProcesses
+// in a process of its own.
+func TestMessageStoreWriterProcess(t *testing.T) {
+	dataPath := os.Getenv(writerDirEnv)
+	if dataPath == "" {
+		t.Skip("only run by TestMessageStoreMultipleProcesses")
+	}
+	id := os.Getenv("GREETD_TEST_WRITER_ID")
+
+	store := NewMessageStore(dataPath)
+	store.SetMaxKeys(1000)
+	require.NoError(t, store.Load(context.Background()))
+
+	for i := 0; i < processWrites; i++ {
+		require.NoError(t, store.SetKey(context.Background(), "writer-"+id+"-"+strconv.Itoa(i), "written", UpdatedByCLI))
+
+		// Read-modify-write of the counter, retried on conflicts: the read
+		// picks up the other processes' writes.
+		for {
+			current := store.Get(context.Background())
+			n := 0
+			if current.Message != DefaultMessage {
+				var err error
+				n, err = strconv.Atoi(current.Message)
+				require.NoError(t, err)
+			}
+			_, err := store.CompareAndSetMessage(context.Background(), strconv.Itoa(n+1), UpdatedByCLI, current.Revision)
+			if errors.Is(err, ErrConflict) {
+				continue
+			}
+			require.NoError(t, err)
+			break
+		}
+	}
+}
+
+func TestMessageStoreExternalChange(t *testing.T) {
+	dataPath := t.TempDir()
+
+	server := NewMessageStore(dataPath)
+	require.NoError(t, server.Load(context.Background()))
+	require.NoError(t, server.SetKey(context.Background(), "motd", "Welcome", UpdatedByAPI))
+
+	// Another process, such as "greetd set message", changes the messages.
+	cli := NewMessageStore(dataPath)
+	require.NoError(t, cli.Load(context.Background()))
+	require.NoError(t, cli.SetMessage(context.Background(), "From the CLI", UpdatedByCLI))
+
+	assert.Equal(t, "From the CLI", server.GetMessage(context.Background()))
+
+	// A write applies to the messages on disk, keeping the other change.
+	require.NoError(t, server.SetKey(context.Background(), "footer", "Bye", UpdatedByAPI))
+	reloaded := NewMessageStore(dataPath)
+	require.NoError(t, reloaded.Load(context.Background()))
+	assert.Equal(t, "From the CLI", reloaded.GetMessage(context.Background()))
+	data, err := reloaded.GetKey(context.Background(), "motd")
 	require.NoError(t, err)
+	assert.Equal(t, "Welcome", data.Message)
 
-	store := NewMessageStore(tmpDir)
-	err = store.Load()
+	// An unreadable file leaves the last messages in place.
+	require.NoError(t, os.WriteFile(filepath.Join(dataPath, MessagesFileName), []byte("{not json"), 0644))
+	assert.Equal(t, "From the CLI", server.GetMessage(context.Background()))
+}
+
+func TestMessageStoreCancelled(t *testing.T) {
+	dataPath := t.TempDir()
+
+	store := NewMessageStore(dataPath)
+	require.NoError(t, store.Load(context.Background()))
+	require.NoError(t, store.SetKey(context.Background(), "motd", "Hi", UpdatedByCLI))
+	require.NoError(t, store.SetKey(context.Background(), "motd", "Welcome", UpdatedByCLI))
+	before, err := os.ReadFile(filepath.Join(dataPath, MessagesFileName))
 	require.NoError(t, err)
 
-	message := store.GetMessage()
-	assert.Equal(t, "Existing message", message)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, store.SetMessage(ctx, "changed", UpdatedByCLI), context.Canceled)
+	_, err = store.CompareAndSetKey(ctx, "motd", "changed", UpdatedByCLI, 2)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = store.UndoKey(ctx, "motd", UpdatedByCLI)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, store.DeleteKey(ctx, "motd"), context.Canceled)
+	assert.ErrorIs(t, store.ReplaceMessages(ctx, map[string]string{"other": "text"}, UpdatedByCLI), context.Canceled)
+	assert.ErrorIs(t, store.Load(ctx), context.Canceled)
+
+	// Nothing changed, in memory or on disk.
+	assert.Equal(t, DefaultMessage, store.GetMessage(context.Background()))
+	data, err := store.GetKey(context.Background(), "motd")
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome", data.Message)
+	assert.Equal(t, int64(2), data.Revision)
+	after, err := os.ReadFile(filepath.Join(dataPath, MessagesFileName))
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
 }
 
-func TestMessageStoreConcurrency(t *testing.T) {
-	// Create temporary directory
-	tmpDir, err := os.MkdirTemp("", "greetd-test")
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, CheckWritable(dir))
+	require.NoError(t, CheckWritable(filepath.Join(dir, "missing", "nested")))
+	assert.NoDirExists(t, filepath.Join(dir, "missing"))
+
+	entries, err := os.ReadDir(dir)
 	require.NoError(t, err)
-	defer os.RemoveAll(tmpDir)
+	assert.Empty(t, entries)
+}
 
-	store := NewMessageStore(tmpDir)
-	err = store.Load()
+func TestFreeSpace(t *testing.T) {
+	free, err := FreeSpace(filepath.Join(t.TempDir(), "missing", "dir"))
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("free space is not available on this platform")
+	}
 	require.NoError(t, err)
+	assert.Positive(t, free)
+}
 
-	// Test concurrent access
-	done := make(chan bool, 2)
+// BenchmarkMessageStoreContention reads the message from parallel
+// goroutines while every tenth operation replaces it, as a busy server does.
+func BenchmarkMessageStoreContention(b *testing.B) {
+	store := NewMessageStore(b.TempDir())
+	require.NoError(b, store.Load(context.Background()))
 
-	go func() {
-		for i := 0; i < 100; i++ {
-			store.SetMessage("Message from goroutine 1")
+	var ops atomic.Int64
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := context.Background()
+		for pb.Next() {
+			if n := ops.Add(1); n%10 == 0 {
+				if err := store.SetMessage(ctx, "Message "+strconv.FormatInt(n, 10), UpdatedByAPI); err != nil {
+					b.Error(err)
+					return
+				}
+			} else {
+				store.GetMessage(ctx)
+			}
 		}
-		done <- true
-	}()
+	})
+}
 
-	go func() {
-		for i := 0; i < 100; i++ {
-			store.GetMessage()
-		}
-		done <- true
-	}()
+func TestMessageStoreHealthCheck(t *testing.T) {
+	dataPath := t.TempDir()
+	store := NewMessageStore(dataPath)
+	require.NoError(t, store.Load(context.Background()))
+
+	check := store.HealthCheck()
+	assert.Equal(t, "storage", check.Name())
+	assert.NoError(t, check.Check(context.Background()))
 
-	// Wait for both goroutines
-	<-done
-	<-done
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+	// The directory turning read-only after Load is noticed too.
+	require.NoError(t, os.Chmod(dataPath, 0500))
+	t.Cleanup(func() { os.Chmod(dataPath, 0700) })
+	err := check.Check(context.Background())
+	assert.True(t, health.IsWarning(err))
+	assert.Contains(t, err.Error(), "not writable")
 
-	// Should not panic or race
+	require.NoError(t, store.Load(context.Background()))
+	assert.Equal(t, health.Warning("read-only"), store.HealthCheck().Check(context.Background()))
 }
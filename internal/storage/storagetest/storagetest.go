@@ -0,0 +1,452 @@
+// Package storagetest checks that a message storage backend behaves like
+// the file store greetd ships with.
+//
+// A backend plugs in from its own tests by passing a constructor to Run,
+// which calls it for every check and expects an empty store each time:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func() storage.Store {
+//			return mybackend.New(newEmptyDatabase(t))
+//		})
+//	}
+//
+// Stores whose data outlives them should implement Reopener, so the suite
+// can check that everything written survives a restart, and file-like
+// stores Crasher as well. Run the suite with -race: it writes from many
+// goroutines at once.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/svanhalla/prompt-lab/greetd/internal/storage"
+)
+
+// Reopener is implemented by stores whose data outlives them. Reopen
+// returns a new store over the same data, not loaded yet, as after a
+// restart.
+type Reopener interface {
+	Reopen() storage.Store
+}
+
+// Crasher is implemented by file-like stores that can be left the way a
+// write interrupted by a crash leaves them, such as with a partly written
+// temporary file. A store reopened afterwards must load the last complete
+// write.
+type Crasher interface {
+	Crash() error
+}
+
+// Run checks the store newStore returns against the semantics of
+// storage.Store, each in a subtest. Checks that need a Reopener or Crasher
+// are skipped for stores without one.
+func Run(t *testing.T, newStore func() storage.Store) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, store storage.Store)
+	}{
+		{"DefaultMessage", testDefaultMessage},
+		{"SetAndGet", testSetAndGet},
+		{"InvalidKey", testInvalidKey},
+		{"RevisionsIncrease", testRevisionsIncrease},
+		{"CompareAndSet", testCompareAndSet},
+		{"Swap", testSwap},
+		{"UndoRedo", testUndoRedo},
+		{"HistoryOrder", testHistoryOrder},
+		{"Delete", testDelete},
+		{"ConcurrentWriters", testConcurrentWriters},
+		{"Persistence", testPersistence},
+		{"CrashRecovery", testCrashRecovery},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, load(t, newStore()))
+		})
+	}
+}
+
+// load loads store and fails the test if that does not work.
+func load(t *testing.T, store storage.Store) storage.Store {
+	t.Helper()
+	require.NoError(t, store.Load(context.Background()))
+	return store
+}
+
+func testDefaultMessage(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	data, err := store.GetKey(ctx, storage.DefaultKey)
+	require.NoError(t, err)
+	assert.Equal(t, storage.DefaultMessage, data.Message)
+	assert.Zero(t, data.Revision)
+	assert.True(t, data.UpdatedAt.IsZero())
+
+	_, err = store.GetKey(ctx, "motd")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.Equal(t, []string{storage.DefaultKey}, keyNames(store.Keys(ctx)))
+
+	revisions, err := store.Revisions(ctx, storage.DefaultKey)
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	assert.Equal(t, storage.DefaultMessage, revisions[0].Message)
+}
+
+func testSetAndGet(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, store.SetKey(ctx, storage.DefaultKey, "Hello, Universe!", storage.UpdatedByCLI))
+	require.NoError(t, store.SetKey(ctx, "motd", "Welcome", storage.UpdatedByAPI))
+
+	data, err := store.GetKey(ctx, storage.DefaultKey)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Universe!", data.Message)
+	assert.Equal(t, storage.UpdatedByCLI, data.UpdatedBy)
+	assert.False(t, data.UpdatedAt.IsZero())
+
+	data, err = store.GetKey(ctx, "motd")
+	require.NoError(t, err)
+	assert.Equal(t, "Welcome", data.Message)
+	assert.Equal(t, storage.UpdatedByAPI, data.UpdatedBy)
+
+	keys := store.Keys(ctx)
+	assert.Equal(t, []string{storage.DefaultKey, "motd"}, keyNames(keys))
+	assert.Equal(t, storage.UpdatedByAPI, keys[1].UpdatedBy)
+}
+
+func testInvalidKey(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	assert.ErrorIs(t, store.SetKey(ctx, "Not a key", "Hi", storage.UpdatedByAPI), storage.ErrInvalidKey)
+	_, err := store.CompareAndSetKey(ctx, "../motd", "Hi", storage.UpdatedByAPI, 0)
+	assert.ErrorIs(t, err, storage.ErrInvalidKey)
+	assert.Equal(t, []string{storage.DefaultKey}, keyNames(store.Keys(ctx)))
+}
+
+func testRevisionsIncrease(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	for want := int64(1); want <= 5; want++ {
+		require.NoError(t, store.SetKey(ctx, "motd", "Message "+strconv.FormatInt(want, 10), storage.UpdatedByAPI))
+		data, err := store.GetKey(ctx, "motd")
+		require.NoError(t, err)
+		assert.Equal(t, want, data.Revision)
+	}
+
+	// Undoing is a change too.
+	data, err := store.UndoKey(ctx, "motd", storage.UpdatedByAPI)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), data.Revision)
+
+	// The revision counts on once the key is deleted, so a request with
+	// If-Match for the deleted message cannot overwrite the new one.
+	require.NoError(t, store.DeleteKey(ctx, "motd"))
+	require.NoError(t, store.SetKey(ctx, "motd", "Again", storage.UpdatedByAPI))
+	data, err = store.GetKey(ctx, "motd")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), data.Revision)
+	_, err = store.CompareAndSetKey(ctx, "motd", "Stale", storage.UpdatedByAPI, 1)
+	assert.ErrorIs(t, err, storage.ErrConflict)
+}
+
+func testCompareAndSet(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	data, err := store.CompareAndSetKey(ctx, storage.DefaultKey, "First", storage.UpdatedByAPI, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), data.Revision)
+	require.NoError(t, store.SetKey(ctx, storage.DefaultKey, "Second", storage.UpdatedByCLI))
+
+	// A stale revision is rejected and reports the current message.
+	current, err := store.CompareAndSetKey(ctx, storage.DefaultKey, "Stale", storage.UpdatedByUI, 1)
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	assert.Equal(t, "Second", current.Message)
+	assert.Equal(t, int64(2), current.Revision)
+	data, err = store.GetKey(ctx, storage.DefaultKey)
+	require.NoError(t, err)
+	assert.Equal(t, "Second", data.Message)
+
+	// Revision 0 only creates keys that do not exist yet.
+	_, err = store.CompareAndSetKey(ctx, "motd", "Welcome", storage.UpdatedByAPI, 0)
+	require.NoError(t, err)
+	_, err = store.CompareAndSetKey(ctx, "motd", "Again", storage.UpdatedByAPI, 0)
+	assert.ErrorIs(t, err, storage.ErrConflict)
+}
+
+func testSwap(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	// The default key replaces the default message; other keys start
+	// without one.
+	previous, stored, err := store.SwapKey(ctx, storage.DefaultKey, "First", storage.UpdatedByAPI)
+	require.NoError(t, err)
+	require.NotNil(t, previous)
+	assert.Equal(t, storage.DefaultMessage, previous.Message)
+	assert.Equal(t, "First", stored.Message)
+	assert.Equal(t, int64(1), stored.Revision)
+
+	previous, _, err = store.SwapKey(ctx, "motd", "Welcome", storage.UpdatedByAPI)
+	require.NoError(t, err)
+	assert.Nil(t, previous)
+
+	previous, stored, err = store.CompareAndSwapKey(ctx, "motd", "Welcome back", storage.UpdatedByUI, 1)
+	require.NoError(t, err)
+	require.NotNil(t, previous)
+	assert.Equal(t, "Welcome", previous.Message)
+	assert.Equal(t, int64(1), previous.Revision)
+	assert.Equal(t, int64(2), stored.Revision)
+
+	previous, current, err := store.CompareAndSwapKey(ctx, "motd", "Stale", storage.UpdatedByUI, 1)
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	assert.Nil(t, previous)
+	assert.Equal(t, "Welcome back", current.Message)
+
+	// Each writer sees the revision it replaced, never one another
+	// writer replaced too.
+	const writers = 8
+	replaced := make(chan int64, writers)
+	var wg sync.WaitGroup
+	for n := 0; n < writers; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			previous, stored, err := store.SwapKey(ctx, storage.DefaultKey, strconv.Itoa(n), storage.UpdatedByAPI)
+			if assert.NoError(t, err) && assert.NotNil(t, previous) {
+				assert.Equal(t, stored.Revision-1, previous.Revision)
+				replaced <- previous.Revision
+			}
+		}(n)
+	}
+	wg.Wait()
+	close(replaced)
+	seen := map[int64]bool{}
+	for revision := range replaced {
+		assert.False(t, seen[revision], "revision %d replaced twice", revision)
+		seen[revision] = true
+	}
+	assert.Len(t, seen, writers)
+}
+
+func testUndoRedo(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	_, err := store.UndoKey(ctx, storage.DefaultKey, storage.UpdatedByAPI)
+	assert.ErrorIs(t, err, storage.ErrNothingToUndo)
+	_, err = store.RedoKey(ctx, storage.DefaultKey, storage.UpdatedByAPI)
+	assert.ErrorIs(t, err, storage.ErrNothingToRedo)
+
+	require.NoError(t, store.SetKey(ctx, storage.DefaultKey, "First", storage.UpdatedByAPI))
+	require.NoError(t, store.SetKey(ctx, storage.DefaultKey, "Second", storage.UpdatedByAPI))
+
+	data, err := store.UndoKey(ctx, storage.DefaultKey, storage.UpdatedByCLI)
+	require.NoError(t, err)
+	assert.Equal(t, "First", data.Message)
+	assert.Equal(t, storage.UpdatedByCLI, data.UpdatedBy)
+
+	// Undoing the first change goes back to the default message.
+	data, err = store.UndoKey(ctx, storage.DefaultKey, storage.UpdatedByCLI)
+	require.NoError(t, err)
+	assert.Equal(t, storage.DefaultMessage, data.Message)
+	_, err = store.UndoKey(ctx, storage.DefaultKey, storage.UpdatedByCLI)
+	assert.ErrorIs(t, err, storage.ErrNothingToUndo)
+
+	data, err = store.RedoKey(ctx, storage.DefaultKey, storage.UpdatedByAPI)
+	require.NoError(t, err)
+	assert.Equal(t, "First", data.Message)
+
+	// A new change discards what could be redone.
+	require.NoError(t, store.SetKey(ctx, storage.DefaultKey, "Third", storage.UpdatedByAPI))
+	_, err = store.RedoKey(ctx, storage.DefaultKey, storage.UpdatedByAPI)
+	assert.ErrorIs(t, err, storage.ErrNothingToRedo)
+}
+
+func testHistoryOrder(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, store.SetKey(ctx, storage.DefaultKey, "First", storage.UpdatedByAPI))
+	require.NoError(t, store.SetKey(ctx, storage.DefaultKey, "Second", storage.UpdatedByAPI))
+	_, err := store.UndoKey(ctx, storage.DefaultKey, storage.UpdatedByAPI)
+	require.NoError(t, err)
+
+	// Revision 2 was undone, and is kept for redo; revision 3 restored 1.
+	assert.Equal(t, []string{"0:" + storage.DefaultMessage, "2:Second", "3:First"}, revisionNames(t, store, storage.DefaultKey))
+
+	_, err = store.Revisions(ctx, "motd")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testDelete(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, store.SetKey(ctx, "motd", "Welcome", storage.UpdatedByAPI))
+	require.NoError(t, store.SetKey(ctx, "motd", "Again", storage.UpdatedByAPI))
+	require.NoError(t, store.DeleteKey(ctx, "motd"))
+	_, err := store.GetKey(ctx, "motd")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.ErrorIs(t, store.DeleteKey(ctx, "motd"), storage.ErrNotFound)
+
+	// Its history went with it.
+	require.NoError(t, store.SetKey(ctx, "motd", "New", storage.UpdatedByAPI))
+	_, err = store.UndoKey(ctx, "motd", storage.UpdatedByAPI)
+	assert.ErrorIs(t, err, storage.ErrNothingToUndo)
+
+	// Deleting the default key restores the default message.
+	require.NoError(t, store.SetKey(ctx, storage.DefaultKey, "Hi", storage.UpdatedByAPI))
+	require.NoError(t, store.DeleteKey(ctx, storage.DefaultKey))
+	data, err := store.GetKey(ctx, storage.DefaultKey)
+	require.NoError(t, err)
+	assert.Equal(t, storage.DefaultMessage, data.Message)
+}
+
+// testConcurrentWriters increments a counter in the default message from
+// several goroutines with read-modify-write loops, while others write
+// their own keys and read. A lost update would leave the counter short.
+func testConcurrentWriters(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	_, err := store.CompareAndSetKey(ctx, storage.DefaultKey, "0", storage.UpdatedByAPI, 0)
+	require.NoError(t, err)
+
+	const writers, increments = 8, 25
+	var wg sync.WaitGroup
+	var conflicts atomic.Int64
+	for w := range writers {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; {
+				current, err := store.GetKey(ctx, storage.DefaultKey)
+				if !assert.NoError(t, err) {
+					return
+				}
+				n, err := strconv.Atoi(current.Message)
+				if !assert.NoError(t, err) {
+					return
+				}
+				_, err = store.CompareAndSetKey(ctx, storage.DefaultKey, strconv.Itoa(n+1), storage.UpdatedByAPI, current.Revision)
+				if errors.Is(err, storage.ErrConflict) {
+					conflicts.Add(1)
+					continue
+				}
+				if !assert.NoError(t, err) {
+					return
+				}
+				i++
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			key := fmt.Sprintf("writer-%d", w)
+			for i := range increments {
+				if !assert.NoError(t, store.SetKey(ctx, key, strconv.Itoa(i), storage.UpdatedByAPI)) {
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range increments {
+				store.Keys(ctx)
+				if _, err := store.Revisions(ctx, storage.DefaultKey); !assert.NoError(t, err) {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := store.GetKey(ctx, storage.DefaultKey)
+	require.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(writers*increments), data.Message)
+	assert.Equal(t, int64(writers*increments+1), data.Revision)
+	for w := range writers {
+		data, err := store.GetKey(ctx, fmt.Sprintf("writer-%d", w))
+		require.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(increments-1), data.Message)
+		assert.Equal(t, int64(increments), data.Revision)
+	}
+	t.Logf("%d conflicts retried", conflicts.Load())
+}
+
+func testPersistence(t *testing.T, store storage.Store) {
+	reopener, ok := store.(Reopener)
+	if !ok {
+		t.Skip("the store does not implement storagetest.Reopener")
+	}
+	ctx := context.Background()
+
+	require.NoError(t, store.SetKey(ctx, storage.DefaultKey, "First", storage.UpdatedByAPI))
+	require.NoError(t, store.SetKey(ctx, storage.DefaultKey, "Second", storage.UpdatedByAPI))
+	_, err := store.UndoKey(ctx, storage.DefaultKey, storage.UpdatedByAPI)
+	require.NoError(t, err)
+	require.NoError(t, store.SetKey(ctx, "motd", "Welcome", storage.UpdatedByCLI))
+	want, err := store.GetKey(ctx, "motd")
+	require.NoError(t, err)
+
+	reopened := load(t, reopener.Reopen())
+	got, err := reopened.GetKey(ctx, "motd")
+	require.NoError(t, err)
+	assert.Equal(t, want.Message, got.Message)
+	assert.Equal(t, want.Revision, got.Revision)
+	assert.Equal(t, want.UpdatedBy, got.UpdatedBy)
+	assert.True(t, want.UpdatedAt.Equal(got.UpdatedAt))
+	assert.Equal(t, revisionNames(t, store, storage.DefaultKey), revisionNames(t, reopened, storage.DefaultKey))
+
+	// The history survives too.
+	data, err := reopened.RedoKey(ctx, storage.DefaultKey, storage.UpdatedByAPI)
+	require.NoError(t, err)
+	assert.Equal(t, "Second", data.Message)
+	assert.Equal(t, int64(4), data.Revision)
+}
+
+func testCrashRecovery(t *testing.T, store storage.Store) {
+	reopener, ok := store.(Reopener)
+	crasher, crashes := store.(Crasher)
+	if !ok || !crashes {
+		t.Skip("the store does not implement storagetest.Reopener and storagetest.Crasher")
+	}
+	ctx := context.Background()
+
+	require.NoError(t, store.SetKey(ctx, storage.DefaultKey, "Committed", storage.UpdatedByAPI))
+	require.NoError(t, crasher.Crash())
+
+	reopened := load(t, reopener.Reopen())
+	data, err := reopened.GetKey(ctx, storage.DefaultKey)
+	require.NoError(t, err)
+	assert.Equal(t, "Committed", data.Message)
+	assert.Equal(t, int64(1), data.Revision)
+
+	// The store keeps working after the crash.
+	require.NoError(t, reopened.SetKey(ctx, storage.DefaultKey, "After", storage.UpdatedByAPI))
+	data, err = load(t, reopener.Reopen()).GetKey(ctx, storage.DefaultKey)
+	require.NoError(t, err)
+	assert.Equal(t, "After", data.Message)
+}
+
+func keyNames(keys []storage.KeyInfo) []string {
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = key.Key
+	}
+	return names
+}
+
+// revisionNames lists the revisions of key as "<revision>:<message>".
+func revisionNames(t *testing.T, store storage.Store, key string) []string {
+	t.Helper()
+	revisions, err := store.Revisions(context.Background(), key)
+	require.NoError(t, err)
+	names := make([]string, len(revisions))
+	for i, data := range revisions {
+		names[i] = strconv.FormatInt(data.Revision, 10) + ":" + data.Message
+	}
+	return names
+}
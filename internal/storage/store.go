@@ -0,0 +1,44 @@
+package storage
+
+import "context"
+
+// Store is what a message storage backend provides. MessageStore, backed
+// by messages.json, is the one greetd ships with. A new backend is checked
+// against the same semantics by passing a constructor for it to
+// storagetest.Run from its tests.
+type Store interface {
+	// Load reads the stored messages. It is called once, before anything
+	// else, and finding nothing stored is not an error.
+	Load(ctx context.Context) error
+	// GetKey returns the message under key. The default key always has a
+	// message, DefaultMessage until it is set; other keys return
+	// ErrNotFound.
+	GetKey(ctx context.Context, key string) (MessageData, error)
+	// SetKey stores message under key as a new revision.
+	SetKey(ctx context.Context, key, message, updatedBy string) error
+	// SwapKey is SetKey that also returns the message replaced, nil for a
+	// key without one, as it was when the new one was stored.
+	SwapKey(ctx context.Context, key, message, updatedBy string) (previous *MessageData, stored MessageData, err error)
+	// CompareAndSetKey stores message under key only while the key is at
+	// revision, 0 meaning that it was never set, and returns ErrConflict
+	// with the current message otherwise.
+	CompareAndSetKey(ctx context.Context, key, message, updatedBy string, revision int64) (MessageData, error)
+	// CompareAndSwapKey is CompareAndSetKey that also returns the message
+	// replaced, as SwapKey does.
+	CompareAndSwapKey(ctx context.Context, key, message, updatedBy string, revision int64) (previous *MessageData, stored MessageData, err error)
+	// UndoKey and RedoKey restore the message before the last change, or
+	// the one undone last, as a new revision, and return ErrNothingToUndo
+	// or ErrNothingToRedo when there is none.
+	UndoKey(ctx context.Context, key, updatedBy string) (MessageData, error)
+	RedoKey(ctx context.Context, key, updatedBy string) (MessageData, error)
+	// DeleteKey removes the message under key and its history.
+	DeleteKey(ctx context.Context, key string) error
+	// Keys lists the keys with a message, sorted, including the default
+	// key.
+	Keys(ctx context.Context) []KeyInfo
+	// Revisions returns the known revisions of the message under key,
+	// oldest first.
+	Revisions(ctx context.Context, key string) ([]MessageData, error)
+}
+
+var _ Store = (*MessageStore)(nil)
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var (
+	// watchDebounce is how long Watch waits for file events to settle
+	// before reloading, so an editor saving in several steps causes one
+	// reload.
+	watchDebounce = 100 * time.Millisecond
+	// watchPollInterval is how often Watch checks messages.json when file
+	// events are not available.
+	watchPollInterval = 2 * time.Second
+	// newFSWatcher is replaced in tests to simulate exhausted inotify
+	// limits.
+	newFSWatcher = fsnotify.NewWatcher
+)
+
+// Watch reloads messages.json as soon as another process or program changes
+// it, until ctx is done, so the messages served follow edits made outside
+// greetd without waiting for the next read. A file that cannot be read is
+// reported to the subscribers and the messages in memory are kept.
+//
+// When the data directory cannot be watched, for example because the
+// inotify limits are exhausted, Watch checks the file every few seconds
+// instead and returns the error that prevented watching it.
+func (s *MessageStore) Watch(ctx context.Context) error {
+	watcher, err := s.watchDir()
+	if err != nil {
+		go s.poll(ctx)
+		return err
+	}
+	go s.watch(ctx, watcher)
+	return nil
+}
+
+// watchDir watches the directory of messages.json rather than the file,
+// since writes replace the file by renaming another one over it.
+func (s *MessageStore) watchDir() (*fsnotify.Watcher, error) {
+	dir := filepath.Dir(s.filePath)
+	watcher, err := newFSWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	return watcher, nil
+}
+
+func (s *MessageStore) watch(ctx context.Context, watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	var failed string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Base(event.Name) == MessagesFileName &&
+				event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
+				debounce.Reset(watchDebounce)
+			}
+		case _, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			// Events may have been lost, such as when the queue overflowed.
+			debounce.Reset(watchDebounce)
+		case <-debounce.C:
+			s.reloadWatched(&failed)
+		}
+	}
+}
+
+func (s *MessageStore) poll(ctx context.Context) {
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+
+	var failed string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reloadWatched(&failed)
+		}
+	}
+}
+
+// reloadWatched reloads a changed messages.json for Watch. A file that
+// cannot be read is reported once, not on every check until it is fixed;
+// failed holds the error last reported.
+func (s *MessageStore) reloadWatched(failed *string) {
+	err := s.reload()
+	if err == nil {
+		*failed = ""
+		return
+	}
+	if err.Error() != *failed {
+		*failed = err.Error()
+		s.notify(ChangeEvent{Time: time.Now(), Err: err})
+	}
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nextEvent waits for the next change event.
+func nextEvent(t *testing.T, events <-chan ChangeEvent) ChangeEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change event")
+		return ChangeEvent{}
+	}
+}
+
+// noEvent checks that no change event arrives for a while.
+func noEvent(t *testing.T, events <-chan ChangeEvent) {
+	t.Helper()
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected change event: %+v", event)
+	case <-time.After(5 * watchDebounce):
+	}
+}
+
+func TestMessageStoreWatch(t *testing.T) {
+	dataPath := t.TempDir()
+
+	server := NewMessageStore(dataPath)
+	require.NoError(t, server.Load(context.Background()))
+	require.NoError(t, server.SetMessage(context.Background(), "Hello", UpdatedByAPI))
+
+	events, cancel := server.Subscribe(8)
+	defer cancel()
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	require.NoError(t, server.Watch(ctx))
+
+	// Our own writes are reported once, not reloaded again.
+	require.NoError(t, server.SetMessage(context.Background(), "Welcome", UpdatedByAPI))
+	assert.False(t, nextEvent(t, events).Reloaded)
+	noEvent(t, events)
+
+	// A change by another process is reloaded without a read.
+	cli := NewMessageStore(dataPath)
+	require.NoError(t, cli.Load(context.Background()))
+	require.NoError(t, cli.SetMessage(context.Background(), "From the CLI", UpdatedByCLI))
+	event := nextEvent(t, events)
+	assert.True(t, event.Reloaded)
+	assert.NoError(t, event.Err)
+	assert.Equal(t, "From the CLI", server.GetMessage(context.Background()))
+
+	// An invalid file is reported and the messages are kept.
+	require.NoError(t, os.WriteFile(filepath.Join(dataPath, MessagesFileName), []byte("{not json"), 0644))
+	event = nextEvent(t, events)
+	assert.Error(t, event.Err)
+	assert.Equal(t, "From the CLI", server.GetMessage(context.Background()))
+}
+
+func TestMessageStoreWatchPolling(t *testing.T) {
+	watcher, interval := newFSWatcher, watchPollInterval
+	defer func() { newFSWatcher, watchPollInterval = watcher, interval }()
+	newFSWatcher = func() (*fsnotify.Watcher, error) { return nil, errors.New("too many open files") }
+	watchPollInterval = 20 * time.Millisecond
+
+	dataPath := t.TempDir()
+	server := NewMessageStore(dataPath)
+	require.NoError(t, server.Load(context.Background()))
+
+	events, cancel := server.Subscribe(8)
+	defer cancel()
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	assert.ErrorContains(t, server.Watch(ctx), "too many open files")
+
+	cli := NewMessageStore(dataPath)
+	require.NoError(t, cli.Load(context.Background()))
+	require.NoError(t, cli.SetMessage(context.Background(), "From the CLI", UpdatedByCLI))
+	assert.True(t, nextEvent(t, events).Reloaded)
+
+	// An invalid file is reported once, not on every check.
+	require.NoError(t, os.WriteFile(filepath.Join(dataPath, MessagesFileName), []byte("{not json"), 0644))
+	assert.Error(t, nextEvent(t, events).Err)
+	noEvent(t, events)
+	assert.Equal(t, "From the CLI", server.GetMessage(context.Background()))
+}
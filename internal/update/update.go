@@ -0,0 +1,274 @@
+// Package update looks up the latest greetd release on GitHub and compares
+// it with the running version. It only reports; nothing is downloaded or
+// installed.
+package update
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultReleasesURL is the GitHub API endpoint of the latest release.
+const DefaultReleasesURL = "https://api.github.com/repos/svanhalla/prompt-lab/releases/latest"
+
+// CacheFileName is the file in the state directory that holds the latest
+// release found, so the API is asked at most once per CacheTTL.
+const CacheFileName = "update-check.json"
+
+// CacheTTL is how long a release found is reused.
+const CacheTTL = 24 * time.Hour
+
+// maxResponse bounds the release response read.
+const maxResponse = 1 << 20
+
+// Statuses of a Result.
+const (
+	StatusUpToDate  = "up-to-date"
+	StatusAvailable = "available"
+	StatusUnknown   = "unknown"
+)
+
+// Result is the outcome of a check.
+type Result struct {
+	Status  string `json:"status"`
+	Current string `json:"current"`
+	// Latest and URL are the newest release and its page, when known.
+	Latest    string    `json:"latest,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	CheckedAt time.Time `json:"checked_at,omitempty"`
+	// Error says why the status is unknown.
+	Error string `json:"error,omitempty"`
+}
+
+// Available reports whether a newer release exists.
+func (r Result) Available() bool {
+	return r.Status == StatusAvailable
+}
+
+// release is the part of the GitHub release the check reads, and what the
+// cache file holds.
+type release struct {
+	TagName   string    `json:"tag_name"`
+	HTMLURL   string    `json:"html_url"`
+	CheckedAt time.Time `json:"checked_at"`
+	// Source is the URL the release was read from, so the cache of one
+	// releases URL is not used for another.
+	Source string `json:"source"`
+}
+
+// Checker compares Current with the latest release.
+type Checker struct {
+	Client  *http.Client
+	URL     string // DefaultReleasesURL when empty
+	Current string
+	// CacheDir holds CacheFileName; empty disables the cache.
+	CacheDir string
+
+	now func() time.Time
+}
+
+// Check returns the status of Current. When the latest release cannot be
+// found, the status is unknown and the error says why; a release found
+// within CacheTTL is reused without asking again.
+func (c *Checker) Check(ctx context.Context) (Result, error) {
+	result := Result{Status: StatusUnknown, Current: c.Current}
+
+	latest, err := c.latest(ctx)
+	if err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+	result.Latest = latest.TagName
+	result.URL = latest.HTMLURL
+	result.CheckedAt = latest.CheckedAt
+
+	newer, err := Newer(latest.TagName, c.Current)
+	if err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+	result.Status = StatusUpToDate
+	if newer {
+		result.Status = StatusAvailable
+	}
+	return result, nil
+}
+
+// latest returns the cached release, or asks the API and caches the
+// answer.
+func (c *Checker) latest(ctx context.Context) (release, error) {
+	source := c.URL
+	if source == "" {
+		source = DefaultReleasesURL
+	}
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+
+	cacheFile := ""
+	if c.CacheDir != "" {
+		cacheFile = filepath.Join(c.CacheDir, CacheFileName)
+		if cached, err := readCache(cacheFile); err == nil && cached.Source == source &&
+			now().Sub(cached.CheckedAt) < CacheTTL && !cached.CheckedAt.After(now()) {
+			return cached, nil
+		}
+	}
+
+	latest, err := c.fetch(ctx, source)
+	if err != nil {
+		return release{}, err
+	}
+	latest.CheckedAt = now().UTC()
+	latest.Source = source
+	if cacheFile != "" {
+		// A cache that cannot be written only means asking again.
+		if data, err := json.MarshalIndent(latest, "", "  "); err == nil {
+			os.WriteFile(cacheFile, data, 0o600)
+		}
+	}
+	return latest, nil
+}
+
+func readCache(file string) (release, error) {
+	var cached release
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return cached, err
+	}
+	err = json.Unmarshal(data, &cached)
+	return cached, err
+}
+
+func (c *Checker) fetch(ctx context.Context, source string) (release, error) {
+	var latest release
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return latest, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "greetd/"+c.Current)
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return latest, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return latest, errors.New("no releases found")
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		return latest, fmt.Errorf("rate limited (%d)", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return latest, fmt.Errorf("releases API returned %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponse)).Decode(&latest); err != nil {
+		return latest, fmt.Errorf("failed to decode release: %w", err)
+	}
+	if latest.TagName == "" {
+		return latest, errors.New("release has no tag")
+	}
+	return latest, nil
+}
+
+// Newer reports whether the semantic version latest is newer than current,
+// such as "v1.3.0" over "1.2.9". A pre-release is older than the release it
+// leads up to. Build metadata is ignored, and so is the suffix git describe
+// adds to builds after a tag, such as "-3-gabc1234-dirty".
+func Newer(latest, current string) (bool, error) {
+	l, err := parseVersion(latest)
+	if err != nil {
+		return false, err
+	}
+	c, err := parseVersion(current)
+	if err != nil {
+		return false, err
+	}
+	return compareVersions(l, c) > 0, nil
+}
+
+// semver is a parsed version: major, minor and patch, and the pre-release
+// identifiers.
+type semver struct {
+	core [3]int
+	pre  []string
+}
+
+// describeSuffix matches what git describe appends to the tag.
+var describeSuffix = regexp.MustCompile(`(-[0-9]+-g[0-9a-f]+)?(-dirty)?$`)
+
+func parseVersion(s string) (semver, error) {
+	var v semver
+	rest := strings.TrimPrefix(s, "v")
+	rest, _, _ = strings.Cut(rest, "+")
+	rest = describeSuffix.ReplaceAllString(rest, "")
+	rest, pre, hasPre := strings.Cut(rest, "-")
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("%q is not a release version", s)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("%q is not a release version", s)
+		}
+		v.core[i] = n
+	}
+	if hasPre {
+		v.pre = strings.Split(pre, ".")
+	}
+	return v, nil
+}
+
+// compareVersions returns -1, 0 or 1 as a is older than, the same as or
+// newer than b, by the precedence rules of semantic versioning.
+func compareVersions(a, b semver) int {
+	for i := range a.core {
+		if a.core[i] != b.core[i] {
+			return cmp.Compare(a.core[i], b.core[i])
+		}
+	}
+	switch {
+	case len(a.pre) == 0 && len(b.pre) == 0:
+		return 0
+	case len(a.pre) == 0:
+		return 1
+	case len(b.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.pre) && i < len(b.pre); i++ {
+		if a.pre[i] == b.pre[i] {
+			continue
+		}
+		an, aErr := strconv.Atoi(a.pre[i])
+		bn, bErr := strconv.Atoi(b.pre[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			return cmp.Compare(an, bn)
+		case aErr == nil:
+			// Numeric identifiers sort before alphanumeric ones.
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			return strings.Compare(a.pre[i], b.pre[i])
+		}
+	}
+	return cmp.Compare(len(a.pre), len(b.pre))
+}
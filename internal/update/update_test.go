@@ -0,0 +1,127 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"v1.3.0", "v1.2.9", true},
+		{"v1.3.0", "1.3.0", false},
+		{"v1.10.0", "v1.9.0", true},
+		{"v2.0.0", "v10.0.0", false},
+		{"v1.3.0", "v1.3.0-rc.1", true},
+		{"v1.3.0-rc.2", "v1.3.0-rc.1", true},
+		{"v1.3.0-rc.10", "v1.3.0-rc.9", true},
+		{"v1.3.0-rc.1", "v1.3.0-beta", true},
+		{"v1.3.0-beta", "v1.3.0-rc.1", false},
+		{"v1.3.0-alpha.1", "v1.3.0-alpha", true},
+		{"v1.3.0", "v1.3.0+build.5", false},
+		{"v1.3.0", "v1.3.0-4-gabc1234-dirty", false},
+		{"v1.3.1", "v1.3.0-4-gabc1234", true},
+	}
+	for _, tt := range tests {
+		got, err := Newer(tt.latest, tt.current)
+		require.NoError(t, err, "%s over %s", tt.latest, tt.current)
+		assert.Equal(t, tt.want, got, "%s over %s", tt.latest, tt.current)
+	}
+
+	for _, invalid := range []string{"dev", "abc1234", "v1.2", "v1.x.0"} {
+		_, err := Newer("v1.0.0", invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	var requests atomic.Int32
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		assert.Equal(t, "application/vnd.github+json", r.Header.Get("Accept"))
+		assert.Contains(t, r.Header.Get("User-Agent"), "greetd/")
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"tag_name": "v1.3.0",
+			"html_url": "https://github.com/svanhalla/prompt-lab/releases/tag/v1.3.0",
+			"name":     "greetd 1.3.0",
+		})
+	}))
+	defer srv.Close()
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	checker := &Checker{
+		URL:      srv.URL,
+		Current:  "v1.2.0",
+		CacheDir: t.TempDir(),
+		now:      func() time.Time { return now },
+	}
+
+	result, err := checker.Check(context.Background())
+	require.NoError(t, err)
+	assert.True(t, result.Available())
+	assert.Equal(t, Result{
+		Status:    StatusAvailable,
+		Current:   "v1.2.0",
+		Latest:    "v1.3.0",
+		URL:       "https://github.com/svanhalla/prompt-lab/releases/tag/v1.3.0",
+		CheckedAt: now,
+	}, result)
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Within a day the cached release is used, even while the API fails.
+	status = http.StatusForbidden
+	now = now.Add(23 * time.Hour)
+	result, err = checker.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, StatusAvailable, result.Status)
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Afterwards it is asked again, and a failure leaves the status unknown.
+	now = now.Add(2 * time.Hour)
+	result, err = checker.Check(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, StatusUnknown, result.Status)
+	assert.Equal(t, "rate limited (403)", result.Error)
+	assert.Equal(t, int32(2), requests.Load())
+
+	status = http.StatusOK
+	checker.Current = "v1.3.0"
+	result, err = checker.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, StatusUpToDate, result.Status)
+	assert.False(t, result.Available())
+
+	// A development build cannot be compared, but the release is known.
+	checker.Current = "dev"
+	result, err = checker.Check(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, StatusUnknown, result.Status)
+	assert.Equal(t, "v1.3.0", result.Latest)
+}
+
+func TestCheckUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	checker := &Checker{URL: url, Current: "v1.2.0"}
+	result, err := checker.Check(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, StatusUnknown, result.Status)
+	assert.Contains(t, result.Error, "request failed")
+}
@@ -0,0 +1,134 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultMaxLength is the default message limit in runes.
+const DefaultMaxLength = 1024
+
+var (
+	// ErrEmpty is returned for messages that are empty or only whitespace.
+	ErrEmpty = errors.New("message cannot be empty")
+	// ErrBidiControl is returned for messages containing bidirectional
+	// override or isolate characters, which can make the stored text read
+	// differently from how it is displayed.
+	ErrBidiControl = errors.New("message contains bidirectional control characters")
+)
+
+// TooLongError is returned when a message exceeds the configured limit.
+type TooLongError struct {
+	Length int
+	Limit  int
+}
+
+func (e *TooLongError) Error() string {
+	return fmt.Sprintf("message is %d characters long; the limit is %d", e.Length, e.Limit)
+}
+
+// ForbiddenError is returned when a message matches a forbidden pattern.
+type ForbiddenError struct {
+	Pattern string
+}
+
+func (e *ForbiddenError) Error() string {
+	return fmt.Sprintf("message matches the forbidden pattern %q", e.Pattern)
+}
+
+// Options configures message validation.
+type Options struct {
+	// MaxLength is the maximum number of runes. Zero disables the check.
+	MaxLength int
+	// Forbidden are patterns a message must not match.
+	Forbidden []*regexp.Regexp
+	// Words are banned words. Messages containing them are rejected, or
+	// masked by Prepare when MaskWords is set.
+	Words     *WordFilter
+	MaskWords bool
+}
+
+// CompilePatterns compiles forbidden message patterns, naming the first one
+// that is not a valid regular expression.
+func CompilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// Normalize returns msg in Unicode normalization form C with zero-width
+// spaces, word joiners and byte order marks removed. Zero-width joiners and
+// non-joiners are kept, since emoji sequences and some scripts need them.
+// Messages are normalized before they are validated and stored.
+func Normalize(msg string) string {
+	return norm.NFC.String(stripInvisible(msg))
+}
+
+// stripInvisible removes the invisible characters Normalize drops.
+func stripInvisible(msg string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\u200b', '\u2060', '\ufeff':
+			return -1
+		}
+		return r
+	}, msg)
+}
+
+// bidiControl reports whether r is a bidirectional embedding, override or
+// isolate character.
+func bidiControl(r rune) bool {
+	return (r >= '\u202a' && r <= '\u202e') || (r >= '\u2066' && r <= '\u2069')
+}
+
+// Message checks msg against the rules shared by the API, the UI form, and the CLI.
+// A message of nothing but whitespace and the characters Normalize removes
+// is empty.
+func Message(msg string, opts Options) error {
+	if strings.TrimSpace(stripInvisible(msg)) == "" {
+		return ErrEmpty
+	}
+
+	if strings.IndexFunc(msg, bidiControl) >= 0 {
+		return ErrBidiControl
+	}
+
+	if opts.MaxLength > 0 {
+		if length := utf8.RuneCountInString(msg); length > opts.MaxLength {
+			return &TooLongError{Length: length, Limit: opts.MaxLength}
+		}
+	}
+
+	for _, re := range opts.Forbidden {
+		if re.MatchString(msg) {
+			return &ForbiddenError{Pattern: re.String()}
+		}
+	}
+
+	if opts.Words.Match(msg) {
+		return ErrBannedWord
+	}
+
+	return nil
+}
+
+// Prepare returns msg as it should be stored: normalized, with banned words
+// masked when opts.MaskWords is set, and checked with Message.
+func Prepare(msg string, opts Options) (string, error) {
+	msg = Normalize(msg)
+	if opts.MaskWords {
+		msg = opts.Words.Mask(msg)
+	}
+	return msg, Message(msg, opts)
+}
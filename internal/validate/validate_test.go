@@ -0,0 +1,112 @@
+package validate
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		opts    Options
+		err     error
+		tooLong bool
+	}{
+		{"valid", "Hello", Options{MaxLength: 10}, nil, false},
+		{"empty", "", Options{}, ErrEmpty, false},
+		{"whitespace only", " \t\n ", Options{}, ErrEmpty, false},
+		{"at limit", strings.Repeat("a", 10), Options{MaxLength: 10}, nil, false},
+		{"over limit", strings.Repeat("a", 11), Options{MaxLength: 10}, nil, true},
+		{"limit counts runes not bytes", strings.Repeat("å", 10), Options{MaxLength: 10}, nil, false},
+		{"multibyte over limit", strings.Repeat("日", 11), Options{MaxLength: 10}, nil, true},
+		{"no limit", strings.Repeat("a", 5000), Options{}, nil, false},
+		{"zero-width space only", "\u200b\u200b", Options{}, ErrEmpty, false},
+		{"byte order mark and spaces", " \ufeff ", Options{}, ErrEmpty, false},
+		{"zero-width joiner in emoji", "👩\u200d💻", Options{}, nil, false},
+		{"zero-width non-joiner", "می\u200cخواهم", Options{}, nil, false},
+		{"right-to-left text", "שלום", Options{}, nil, false},
+		{"right-to-left mark", "abc\u200f", Options{}, nil, false},
+		{"right-to-left override", "abc\u202edcba", Options{}, ErrBidiControl, false},
+		{"left-to-right embedding", "\u202aabc", Options{}, ErrBidiControl, false},
+		{"first strong isolate", "a\u2068b\u2069", Options{}, ErrBidiControl, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Message(tt.message, tt.opts)
+
+			if tt.tooLong {
+				var tooLong *TooLongError
+				assert.True(t, errors.As(err, &tooLong))
+				assert.Equal(t, tt.opts.MaxLength, tooLong.Limit)
+				return
+			}
+
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestMessageForbidden(t *testing.T) {
+	opts := Options{Forbidden: []*regexp.Regexp{regexp.MustCompile(`(?i)casino`), regexp.MustCompile(`https?://`)}}
+
+	tests := []struct {
+		message string
+		pattern string
+	}{
+		{"Welcome", ""},
+		{"Visit our CASINO", "(?i)casino"},
+		{"See http://example.com", "https?://"},
+	}
+
+	for _, tt := range tests {
+		err := Message(tt.message, opts)
+		if tt.pattern == "" {
+			assert.NoError(t, err, tt.message)
+			continue
+		}
+		var forbidden *ForbiddenError
+		if assert.True(t, errors.As(err, &forbidden), tt.message) {
+			assert.Equal(t, tt.pattern, forbidden.Pattern)
+		}
+	}
+}
+
+func TestCompilePatterns(t *testing.T) {
+	patterns, err := CompilePatterns([]string{"a+", "^b$"})
+	assert.NoError(t, err)
+	assert.Len(t, patterns, 2)
+
+	_, err = CompilePatterns([]string{"ok", "(unclosed"})
+	assert.ErrorContains(t, err, `"(unclosed"`)
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{"ascii", "Hello", "Hello"},
+		{"combining accent composed", "Cafe\u0301", "Caf\u00e9"},
+		{"already composed", "Caf\u00e9", "Caf\u00e9"},
+		{"hangul jamo composed", "\u1100\u1161", "\uac00"},
+		{"zero-width space removed", "Hel\u200blo", "Hello"},
+		{"word joiner removed", "Hel\u2060lo", "Hello"},
+		{"byte order mark removed", "\ufeffHello", "Hello"},
+		{"zero-width joiner kept", "👩\u200d💻", "👩\u200d💻"},
+		{"bidi override kept for Message to reject", "a\u202eb", "a\u202eb"},
+		{"whitespace kept", " line\n\tindented ", " line\n\tindented "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Normalize(tt.message))
+		})
+	}
+}
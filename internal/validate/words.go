@@ -0,0 +1,236 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrBannedWord is returned when a message contains a word of the banned
+// word list. It does not name the word, so error messages do not repeat it.
+var ErrBannedWord = errors.New("message contains a word that is not allowed")
+
+// WordFilter matches messages against a list of banned words read from a
+// file with one word per line; empty lines and lines starting with "#" are
+// skipped. Words match case-insensitively, and a message may repeat their
+// letters or spell them out with punctuation, so "baaad" and "B.a.d!" match
+// "bad". Letters are only collapsed in the message, so "as" does not match
+// "ass". A nil WordFilter matches nothing.
+type WordFilter struct {
+	path string
+
+	mu sync.RWMutex
+	// words maps the letters of each banned word, with repeats collapsed,
+	// to how often each letter repeats in the words that collapse to them.
+	words   map[string][][]int
+	count   int
+	modTime time.Time
+	size    int64
+}
+
+// LoadWordFilter reads the banned words in path. It returns nil when path
+// is empty.
+func LoadWordFilter(path string) (*WordFilter, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f := &WordFilter{path: path}
+	if _, err := f.Refresh(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Refresh reads the file again if its modification time or size changed
+// since it was last read, and reports whether it did. When the file cannot
+// be read the words already loaded are kept.
+func (f *WordFilter) Refresh() (bool, error) {
+	if f == nil {
+		return false, nil
+	}
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read banned words: %w", err)
+	}
+
+	f.mu.RLock()
+	unchanged := f.words != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size
+	f.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read banned words: %w", err)
+	}
+	words := make(map[string][][]int)
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word := foldWord(line)
+		if word == "" || seen[word] {
+			continue
+		}
+		seen[word] = true
+		letters, runs := collapse(word)
+		words[letters] = append(words[letters], runs)
+	}
+
+	f.mu.Lock()
+	f.words, f.count, f.modTime, f.size = words, len(seen), info.ModTime(), info.Size()
+	f.mu.Unlock()
+	return true, nil
+}
+
+// Len returns the number of banned words.
+func (f *WordFilter) Len() int {
+	if f == nil {
+		return 0
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.count
+}
+
+// Match reports whether msg contains a banned word.
+func (f *WordFilter) Match(msg string) bool {
+	found := false
+	f.each(msg, func(start, end int) { found = true })
+	return found
+}
+
+// Mask returns msg with the letters of every banned word replaced by
+// asterisks, keeping punctuation around it.
+func (f *WordFilter) Mask(msg string) string {
+	var b strings.Builder
+	last := 0
+	f.each(msg, func(start, end int) {
+		b.WriteString(msg[last:start])
+		b.WriteString(strings.Repeat("*", len([]rune(msg[start:end]))))
+		last = end
+	})
+	if last == 0 {
+		return msg
+	}
+	b.WriteString(msg[last:])
+	return b.String()
+}
+
+// each calls match with the byte range of every banned word in msg. Words
+// are runs of letters and digits. Single letters joined by punctuation, as
+// in "b.a.d", are read as one word.
+func (f *WordFilter) each(msg string, match func(start, end int)) {
+	if f == nil {
+		return
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if len(f.words) == 0 {
+		return
+	}
+
+	for _, token := range splitFunc(msg, 0, unicode.IsSpace) {
+		words := splitFunc(msg[token[0]:token[1]], token[0], notWordRune)
+		spelled := len(words) > 1
+		for _, word := range words {
+			spelled = spelled && utf8.RuneCountInString(msg[word[0]:word[1]]) == 1
+		}
+		if spelled {
+			start, end := words[0][0], words[len(words)-1][1]
+			if f.bannedUnsafe(msg[start:end]) {
+				match(start, end)
+			}
+			continue
+		}
+		for _, word := range words {
+			if f.bannedUnsafe(msg[word[0]:word[1]]) {
+				match(word[0], word[1])
+			}
+		}
+	}
+}
+
+// splitFunc returns the byte ranges, offset by offset, of the runs of s
+// separated by runes for which sep is true.
+func splitFunc(s string, offset int, sep func(rune) bool) [][2]int {
+	var ranges [][2]int
+	start := -1
+	for i, r := range s {
+		if sep(r) {
+			if start >= 0 {
+				ranges = append(ranges, [2]int{offset + start, offset + i})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		ranges = append(ranges, [2]int{offset + start, offset + len(s)})
+	}
+	return ranges
+}
+
+// bannedUnsafe reports whether word is a banned word with its letters
+// repeated at least as often, so "baaad" matches "bad" and "as" does not
+// match "ass". f.mu must be held.
+func (f *WordFilter) bannedUnsafe(word string) bool {
+	letters, runs := collapse(foldWord(word))
+	for _, banned := range f.words[letters] {
+		if atLeast(runs, banned) {
+			return true
+		}
+	}
+	return false
+}
+
+// atLeast reports whether every count in runs is at least that in min.
+func atLeast(runs, min []int) bool {
+	for i := range min {
+		if runs[i] < min[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func notWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// foldWord lowercases word and drops everything but letters and digits.
+func foldWord(word string) string {
+	return strings.Map(func(r rune) rune {
+		if notWordRune(r) {
+			return -1
+		}
+		return r
+	}, strings.ToLower(word))
+}
+
+// collapse returns word with runs of the same character collapsed, and how
+// long each run was.
+func collapse(word string) (string, []int) {
+	var b strings.Builder
+	var runs []int
+	var prev rune
+	for _, r := range word {
+		if r == prev {
+			runs[len(runs)-1]++
+			continue
+		}
+		b.WriteRune(r)
+		runs = append(runs, 1)
+		prev = r
+	}
+	return b.String(), runs
+}
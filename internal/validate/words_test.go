@@ -0,0 +1,110 @@
+package validate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeWords writes a banned words file with the given modification time.
+func writeWords(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestWordFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banned.txt")
+	writeWords(t, path, "# kiosk policy\nbad\n\n  Heck  \nass\nhell\nHELL\n", time.Now())
+
+	filter, err := LoadWordFilter(path)
+	require.NoError(t, err)
+	assert.Equal(t, 4, filter.Len())
+
+	tests := []struct {
+		message string
+		match   bool
+		masked  string
+	}{
+		{"Good morning", false, "Good morning"},
+		{"bad", true, "***"},
+		{"Not BAD at all", true, "Not *** at all"},
+		{"baaaad!", true, "******!"},
+		{"b.a.d", true, "*****"},
+		{"(heck)", true, "(****)"},
+		{"badge and abad", false, "badge and abad"},
+		{"bad\nheck", true, "***\n****"},
+		// Repeated letters are only collapsed in the message.
+		{"as good as it gets", false, "as good as it gets"},
+		{"aasss", true, "*****"},
+		{"he'll be there", false, "he'll be there"},
+		{"hello-hell", true, "hello-****"},
+		{"well,bad", true, "well,***"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.match, filter.Match(tt.message), tt.message)
+		assert.Equal(t, tt.masked, filter.Mask(tt.message), tt.message)
+	}
+}
+
+func TestWordFilterRefresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banned.txt")
+	modTime := time.Now().Add(-time.Hour)
+	writeWords(t, path, "bad\n", modTime)
+
+	filter, err := LoadWordFilter(path)
+	require.NoError(t, err)
+
+	changed, err := filter.Refresh()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	writeWords(t, path, "worse\n", modTime.Add(time.Second))
+	changed, err = filter.Refresh()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.False(t, filter.Match("bad"))
+	assert.True(t, filter.Match("worse"))
+
+	// A file that disappears keeps the words already loaded.
+	require.NoError(t, os.Remove(path))
+	_, err = filter.Refresh()
+	assert.Error(t, err)
+	assert.True(t, filter.Match("worse"))
+}
+
+func TestLoadWordFilter(t *testing.T) {
+	filter, err := LoadWordFilter("")
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+	assert.False(t, filter.Match("anything"))
+	assert.Equal(t, "anything", filter.Mask("anything"))
+
+	_, err = LoadWordFilter(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
+
+func TestPrepareBannedWords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banned.txt")
+	writeWords(t, path, "bad\n", time.Now())
+	filter, err := LoadWordFilter(path)
+	require.NoError(t, err)
+
+	_, err = Prepare("so bad", Options{Words: filter})
+	assert.True(t, errors.Is(err, ErrBannedWord))
+	assert.NotContains(t, err.Error(), "bad")
+
+	msg, err := Prepare("so bad", Options{Words: filter, MaskWords: true})
+	require.NoError(t, err)
+	assert.Equal(t, "so ***", msg)
+
+	// A message of nothing but a masked word is not empty.
+	msg, err = Prepare("bad", Options{Words: filter, MaskWords: true})
+	require.NoError(t, err)
+	assert.Equal(t, "***", msg)
+}
@@ -0,0 +1,19 @@
+package version
+
+import "time"
+
+// startTime is taken when the package is initialized, before main runs.
+var startTime = time.Now()
+
+// StartTime returns when the process started. Servers and handlers created
+// later, or again, report their uptime from it.
+func StartTime() time.Time {
+	return startTime
+}
+
+// Uptime returns how long the process has been running. It is measured on
+// the monotonic clock, so it only grows, even when the wall clock is set
+// back.
+func Uptime() time.Duration {
+	return time.Since(startTime)
+}
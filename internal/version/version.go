@@ -3,6 +3,7 @@ package version
 import (
 	"fmt"
 	"runtime"
+	"runtime/debug"
 )
 
 var (
@@ -11,23 +12,63 @@ var (
 	BuildTime = "unknown"
 )
 
+// readBuildInfo is swapped out in tests.
+var readBuildInfo = debug.ReadBuildInfo
+
 type Info struct {
 	Version   string `json:"version"`
 	Commit    string `json:"commit"`
 	BuildTime string `json:"build_time"`
 	GoVersion string `json:"go_version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
 }
 
+// Get returns the build information. Values not set via -ldflags are taken
+// from the module and VCS information embedded by the Go toolchain, when
+// available.
 func Get() Info {
-	return Info{
+	info := Info{
 		Version:   Version,
 		Commit:    Commit,
 		BuildTime: BuildTime,
 		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+
+	bi, ok := readBuildInfo()
+	if !ok {
+		return info
+	}
+
+	if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
 	}
+
+	var modified bool
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "unknown" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildTime == "unknown" {
+				info.BuildTime = setting.Value
+			}
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if modified && Commit == "unknown" && info.Commit != "unknown" {
+		info.Commit += "-dirty"
+	}
+
+	return info
 }
 
 func (i Info) String() string {
-	return fmt.Sprintf("greetd %s (commit: %s, built: %s, go: %s)",
-		i.Version, i.Commit, i.BuildTime, i.GoVersion)
+	return fmt.Sprintf("greetd %s (commit: %s, built: %s, go: %s, %s/%s)",
+		i.Version, i.Commit, i.BuildTime, i.GoVersion, i.OS, i.Arch)
 }
@@ -1,8 +1,11 @@
 package version
 
 import (
+	"runtime"
+	"runtime/debug"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGet(t *testing.T) {
@@ -42,3 +45,82 @@ func TestVariables(t *testing.T) {
 		t.Error("BuildTime variable should not be empty")
 	}
 }
+
+func TestGetPlatform(t *testing.T) {
+	info := Get()
+
+	if info.OS != runtime.GOOS {
+		t.Errorf("OS = %q, want %q", info.OS, runtime.GOOS)
+	}
+	if info.Arch != runtime.GOARCH {
+		t.Errorf("Arch = %q, want %q", info.Arch, runtime.GOARCH)
+	}
+	if !strings.Contains(info.String(), runtime.GOOS+"/"+runtime.GOARCH) {
+		t.Errorf("String() should include the platform, got: %s", info.String())
+	}
+}
+
+func TestGetBuildInfoFallback(t *testing.T) {
+	buildInfo := &debug.BuildInfo{
+		Main: debug.Module{Version: "v1.2.3"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abc123"},
+			{Key: "vcs.time", Value: "2024-01-01T00:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	stubBuildInfo(t, buildInfo)
+
+	info := Get()
+	if info.Version != "v1.2.3" {
+		t.Errorf("Version = %q, want module version v1.2.3", info.Version)
+	}
+	if info.Commit != "abc123-dirty" {
+		t.Errorf("Commit = %q, want abc123-dirty", info.Commit)
+	}
+	if info.BuildTime != "2024-01-01T00:00:00Z" {
+		t.Errorf("BuildTime = %q, want the VCS time", info.BuildTime)
+	}
+
+	// Values set via -ldflags win over the build info.
+	Version, Commit = "1.0.0", "def456"
+	t.Cleanup(func() { Version, Commit = "dev", "unknown" })
+
+	info = Get()
+	if info.Version != "1.0.0" || info.Commit != "def456" {
+		t.Errorf("ldflags values were overridden: %+v", info)
+	}
+}
+
+func TestGetDevelBuild(t *testing.T) {
+	stubBuildInfo(t, &debug.BuildInfo{Main: debug.Module{Version: "(devel)"}})
+
+	if info := Get(); info.Version != "dev" || info.Commit != "unknown" {
+		t.Errorf("expected defaults for a devel build without VCS info, got %+v", info)
+	}
+}
+
+func stubBuildInfo(t *testing.T, bi *debug.BuildInfo) {
+	t.Helper()
+	original := readBuildInfo
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return bi, true }
+	t.Cleanup(func() { readBuildInfo = original })
+}
+
+func TestUptime(t *testing.T) {
+	start := StartTime()
+	if start.IsZero() || start.After(time.Now()) {
+		t.Fatalf("StartTime should be in the past, got: %v", start)
+	}
+
+	first := Uptime()
+	time.Sleep(time.Millisecond)
+	second := Uptime()
+	if second <= first {
+		t.Errorf("Uptime should increase, got %v then %v", first, second)
+	}
+
+	if !StartTime().Equal(start) {
+		t.Errorf("StartTime should not change, got %v then %v", start, StartTime())
+	}
+}
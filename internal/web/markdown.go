@@ -0,0 +1,24 @@
+package web
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+)
+
+// markdownPolicy strips anything that could run script or break out of the
+// message box; only basic formatting, lists, code and links survive.
+var markdownPolicy = bluemonday.UGCPolicy()
+
+// RenderMarkdown converts src to sanitized HTML that is safe to embed in a page.
+func RenderMarkdown(src string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := goldmark.Convert([]byte(src), &buf); err != nil {
+		return "", err
+	}
+
+	// The output has been sanitized by the policy above.
+	return template.HTML(markdownPolicy.SanitizeBytes(buf.Bytes())), nil
+}
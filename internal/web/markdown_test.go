@@ -0,0 +1,36 @@
+package web
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		notWant string
+	}{
+		{name: "emphasis", input: "**hi**", want: "<strong>hi</strong>"},
+		{name: "link", input: "[docs](https://example.com)", want: `href="https://example.com"`},
+		{name: "script tag", input: "<script>alert(1)</script>", notWant: "<script"},
+		{name: "javascript link", input: "[x](javascript:alert(1))", notWant: "javascript:"},
+		{name: "event handler", input: `<img src="x" onerror="alert(1)">`, notWant: "onerror"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderMarkdown(tt.input)
+			if err != nil {
+				t.Fatalf("RenderMarkdown() error = %v", err)
+			}
+			if tt.want != "" && !strings.Contains(string(got), tt.want) {
+				t.Errorf("RenderMarkdown() = %q, want it to contain %q", got, tt.want)
+			}
+			if tt.notWant != "" && strings.Contains(string(got), tt.notWant) {
+				t.Errorf("RenderMarkdown() = %q, must not contain %q", got, tt.notWant)
+			}
+		})
+	}
+}
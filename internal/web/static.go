@@ -0,0 +1,116 @@
+package web
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"path"
+	"strings"
+	"sync"
+)
+
+//go:embed static
+var staticFS embed.FS
+
+// Asset is an embedded static file.
+type Asset struct {
+	Name        string
+	Data        []byte
+	Hash        string
+	ContentType string
+}
+
+// HashedName returns the asset name with a content hash inserted before the
+// extension, e.g. swagger-ui/swagger-ui.css -> swagger-ui/swagger-ui.1a2b3c4d.css.
+func (a *Asset) HashedName() string {
+	ext := path.Ext(a.Name)
+	return strings.TrimSuffix(a.Name, ext) + "." + a.Hash + ext
+}
+
+// contentTypes covers extensions missing from mime's built-in table, which
+// would otherwise depend on the host's mime.types.
+var contentTypes = map[string]string{
+	".ico": "image/x-icon",
+}
+
+type assetIndex struct {
+	byName   map[string]*Asset
+	byHashed map[string]*Asset
+}
+
+var (
+	assetsOnce sync.Once
+	assets     assetIndex
+)
+
+func loadAssets() {
+	assets = assetIndex{
+		byName:   make(map[string]*Asset),
+		byHashed: make(map[string]*Asset),
+	}
+
+	_ = fs.WalkDir(staticFS, "static", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		data, err := staticFS.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		name := strings.TrimPrefix(p, "static/")
+		contentType, ok := contentTypes[path.Ext(name)]
+		if !ok {
+			contentType = mime.TypeByExtension(path.Ext(name))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		asset := &Asset{
+			Name:        name,
+			Data:        data,
+			Hash:        hex.EncodeToString(sum[:])[:12],
+			ContentType: contentType,
+		}
+		assets.byName[name] = asset
+		assets.byHashed[asset.HashedName()] = asset
+		return nil
+	})
+}
+
+// LookupAsset resolves a path below /static/ to an embedded asset. The
+// second return value reports whether the path was the content-hashed name,
+// which makes the response safe to cache forever.
+func LookupAsset(name string) (*Asset, bool, bool) {
+	assetsOnce.Do(loadAssets)
+
+	name = strings.TrimPrefix(name, "/")
+	if asset, ok := assets.byHashed[name]; ok {
+		return asset, true, true
+	}
+	if asset, ok := assets.byName[name]; ok {
+		return asset, false, true
+	}
+	return nil, false, false
+}
+
+// HasAsset reports whether the named asset is embedded in the binary.
+func HasAsset(name string) bool {
+	_, _, ok := LookupAsset(name)
+	return ok
+}
+
+// AssetURL returns the content-hashed URL for an embedded asset, or an empty
+// string when the asset is not embedded.
+func AssetURL(name string) string {
+	asset, _, ok := LookupAsset(name)
+	if !ok {
+		return ""
+	}
+	return "/static/" + asset.HashedName()
+}
@@ -0,0 +1,63 @@
+// Greetd color theme. Loaded in <head> so a theme picked earlier applies
+// before the page is painted. Without a picked theme the stylesheet follows
+// prefers-color-scheme; the toggle in the navigation stores the choice in
+// localStorage and marks <html> with theme-light or theme-dark.
+(function () {
+    const storageKey = 'greetd-theme';
+    const root = document.documentElement;
+    const prefersDark = window.matchMedia('(prefers-color-scheme: dark)');
+
+    function saved() {
+        try {
+            return localStorage.getItem(storageKey);
+        } catch (e) {
+            return null; // storage disabled
+        }
+    }
+
+    // picked is the theme chosen with the toggle, or null to follow the
+    // system.
+    let picked = saved();
+
+    function current() {
+        if (picked === 'light' || picked === 'dark') {
+            return picked;
+        }
+        return prefersDark.matches ? 'dark' : 'light';
+    }
+
+    function apply(theme) {
+        root.classList.toggle('theme-light', theme === 'light');
+        root.classList.toggle('theme-dark', theme === 'dark');
+    }
+
+    apply(picked);
+
+    document.addEventListener('DOMContentLoaded', function () {
+        const toggle = document.getElementById('theme-toggle');
+        if (!toggle) {
+            return;
+        }
+
+        function update() {
+            const dark = current() === 'dark';
+            toggle.setAttribute('aria-pressed', dark ? 'true' : 'false');
+            toggle.textContent = dark ? '☀' : '☾';
+        }
+
+        toggle.addEventListener('click', function () {
+            picked = current() === 'dark' ? 'light' : 'dark';
+            try {
+                localStorage.setItem(storageKey, picked);
+            } catch (e) {
+                // The theme still changes for this page.
+            }
+            apply(picked);
+            update();
+        });
+        prefersDark.addEventListener('change', update);
+
+        toggle.hidden = false;
+        update();
+    });
+})();
@@ -2,124 +2,406 @@ package web
 
 import (
 	"embed"
+	"errors"
+	"fmt"
+	"html"
 	"html/template"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/svanhalla/prompt-lab/greetd/internal/i18n"
 )
 
 //go:embed templates/*.html
-var templateFS embed.FS
+var embeddedTemplates embed.FS
+
+// templateFS holds the embedded templates under templates/. Tests replace
+// it to simulate a binary that lacks some.
+var templateFS fs.FS = embeddedTemplates
+
+// pageNames are the page templates, in the order they are loaded.
+var pageNames = []string{"ui.html", "logs.html", "404.html", "error.html", "swagger.html", "redoc.html", "status.html"}
 
+// ErrTemplateUnavailable is returned for a page whose template failed to
+// load when the templates were created.
+var ErrTemplateUnavailable = errors.New("page template unavailable")
+
+// Templates holds the parsed pages. The exported fields are the pages in
+// i18n.DefaultLang; the getters return a page in any language of the
+// locales, parsed on first use.
 type Templates struct {
 	UI       *template.Template
 	Logs     *template.Template
 	NotFound *template.Template
+	Error    *template.Template
 	Swagger  *template.Template
 	Redoc    *template.Template
-	devMode  bool
+	Status   *template.Template
+	// dir is where template files are read from in dev mode; "" uses
+	// the embedded templates.
+	dir      string
+	basePath string
+	locales  *i18n.Catalog
+	// failed holds the pages that failed to load, by name. Their fields
+	// are nil.
+	failed map[string]error
+
+	// mu guards cache, the pages parsed on demand: in other languages than
+	// i18n.DefaultLang, and from the filesystem in dev mode.
+	mu    sync.Mutex
+	cache map[pageKey]cachedTemplate
+}
+
+// pageKey identifies a page parsed for a language.
+type pageKey struct {
+	name string
+	lang string
+}
+
+// layoutName is the template file every page is parsed together with. It
+// defines the page skeleton and shared navigation; pages fill in its blocks.
+const layoutName = "layout.html"
+
+// cachedTemplate is a page parsed on demand. In dev mode it is kept until
+// the modification time or size of the page or layout file changes. A page
+// that failed to parse keeps its error.
+type cachedTemplate struct {
+	tmpl   *template.Template
+	err    error
+	page   fileStamp
+	layout fileStamp
+}
+
+// fileStamp identifies a version of a template file on disk. It is zero for
+// a file that is not on disk.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func stampFile(path string) (fileStamp, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, false
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, true
 }
 
-// parseTemplate tries to load from filesystem first, falls back to embedded
-func parseTemplate(name string, devMode bool) (*template.Template, error) {
-	// In development mode, always try filesystem first
-	if devMode {
-		fsPath := filepath.Join("internal", "web", "templates", name)
-		if _, err := os.Stat(fsPath); err == nil {
-			return template.ParseFiles(fsPath)
+func (s fileStamp) equal(other fileStamp) bool {
+	return s.modTime.Equal(other.modTime) && s.size == other.size
+}
+
+// newFuncMap returns the helpers available to every template, with the
+// text in lang. asset and path prefix their result with basePath so pages
+// work when served under a sub-path; t looks up a string in locales.
+func newFuncMap(basePath string, locales *i18n.Catalog, lang string) template.FuncMap {
+	unit := func(n int, unit string) string {
+		if n != 1 {
+			unit += "s"
 		}
+		return locales.Tr(lang, "duration."+unit, n)
 	}
 
-	// Fallback to embedded (for production or when filesystem not available)
-	return template.ParseFS(templateFS, "templates/"+name)
+	return template.FuncMap{
+		"asset": func(name string) string { return basePath + assetPath(name) },
+		"path":  func(p string) string { return basePath + p },
+		"ago": func(t time.Time) string {
+			d := time.Since(t)
+			if d < time.Minute {
+				return locales.T(lang, "time.just_now")
+			}
+			return locales.Tr(lang, "time.ago", humanDuration(d, unit))
+		},
+		"formatTime":    FormatTime,
+		"humanDuration": func(d time.Duration) string { return humanDuration(d, unit) },
+		"truncate":      Truncate,
+		"percent":       func(f float64) string { return fmt.Sprintf("%.1f%%", f*100) },
+		"t":             func(key string, args ...interface{}) string { return locales.Tr(lang, key, args...) },
+		"lang":          func() string { return lang },
+		"languages":     func() []Language { return languages(locales, lang) },
+	}
 }
 
-// reloadTemplate reloads a template from filesystem if in dev mode
-func (t *Templates) reloadTemplate(name string) *template.Template {
-	if !t.devMode {
-		return nil // Don't reload in production
+// Language is an entry of the language picker in the navigation.
+type Language struct {
+	Code string
+	// Name is the name of the language in that language, e.g. "Svenska".
+	Name    string
+	Current bool
+}
+
+func languages(locales *i18n.Catalog, current string) []Language {
+	var res []Language
+	for _, code := range locales.Languages() {
+		res = append(res, Language{Code: code, Name: locales.T(code, "language.name"), Current: code == current})
 	}
+	return res
+}
 
-	fsPath := filepath.Join("internal", "web", "templates", name)
-	if _, err := os.Stat(fsPath); err == nil {
-		if tmpl, err := template.ParseFiles(fsPath); err == nil {
-			return tmpl
+// FormatTime formats t in UTC for display, such as "2024-01-02 15:04 UTC".
+// The zero time is shown as an empty string.
+func FormatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02 15:04 UTC")
+}
+
+// HumanDuration describes d in its largest whole unit, such as "1 minute",
+// "3 hours" or "2 days". Durations under a minute are counted in seconds.
+func HumanDuration(d time.Duration) string {
+	return humanDuration(d, func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit
 		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	})
+}
+
+// humanDuration is HumanDuration with count formatting n of unit, which is
+// "second", "minute", "hour" or "day".
+func humanDuration(d time.Duration, count func(n int, unit string) string) string {
+	switch {
+	case d < time.Minute:
+		return count(int(d/time.Second), "second")
+	case d < time.Hour:
+		return count(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return count(int(d/time.Hour), "hour")
+	default:
+		return count(int(d/(24*time.Hour)), "day")
 	}
-	return nil
 }
 
-// GetUI returns UI template, reloading from filesystem if in dev mode
-func (t *Templates) GetUI() *template.Template {
-	if reloaded := t.reloadTemplate("ui.html"); reloaded != nil {
-		return reloaded
+// Truncate shortens s to at most n characters, ending it with "…" when
+// anything was cut. The argument order allows {{.Message | truncate 80}}.
+func Truncate(n int, s string) string {
+	runes := []rune(s)
+	if n < 1 || len(runes) <= n {
+		return s
 	}
-	return t.UI
+	return string(runes[:n-1]) + "…"
 }
 
-// GetLogs returns Logs template, reloading from filesystem if in dev mode
-func (t *Templates) GetLogs() *template.Template {
-	if reloaded := t.reloadTemplate("logs.html"); reloaded != nil {
-		return reloaded
+// TimeAgo describes how long before now t was, such as "just now",
+// "1 minute ago" or "3 days ago".
+func TimeAgo(t, now time.Time) string {
+	d := now.Sub(t)
+	if d < time.Minute {
+		return "just now"
 	}
-	return t.Logs
+	return HumanDuration(d) + " ago"
 }
 
-// GetNotFound returns NotFound template, reloading from filesystem if in dev mode
-func (t *Templates) GetNotFound() *template.Template {
-	if reloaded := t.reloadTemplate("404.html"); reloaded != nil {
-		return reloaded
+// assetPath returns the content-hashed URL for an embedded static asset
+func assetPath(name string) string {
+	if url := AssetURL(name); url != "" {
+		return url
 	}
-	return t.NotFound
+	return "/static/" + name
 }
 
-// GetSwagger returns Swagger template, reloading from filesystem if in dev mode
-func (t *Templates) GetSwagger() *template.Template {
-	if reloaded := t.reloadTemplate("swagger.html"); reloaded != nil {
-		return reloaded
+// parseTemplate parses the page name together with the layout. In dev mode
+// each file is read from dir when it exists there and from the embedded
+// templates otherwise. A file that does not parse is reported as a
+// *ParseError.
+func parseTemplate(name, dir string, funcs template.FuncMap) (*template.Template, error) {
+	tmpl := template.New(name).Funcs(funcs)
+	// The page is parsed last so its blocks replace the layout's defaults.
+	for _, file := range []string{layoutName, name} {
+		var err error
+		if _, onDisk := stampFile(filepath.Join(dir, file)); dir != "" && onDisk {
+			tmpl, err = tmpl.ParseFiles(filepath.Join(dir, file))
+		} else {
+			tmpl, err = tmpl.ParseFS(templateFS, "templates/"+file)
+		}
+		if err != nil {
+			return nil, &ParseError{Name: file, Err: err}
+		}
 	}
-	return t.Swagger
+	return tmpl, nil
 }
 
-// GetRedoc returns Redoc template, reloading from filesystem if in dev mode
-func (t *Templates) GetRedoc() *template.Template {
-	if reloaded := t.reloadTemplate("redoc.html"); reloaded != nil {
-		return reloaded
+// ParseError is returned when a template file does not parse. Name is the
+// page or the layout, whichever failed; the error text names the file and
+// line.
+type ParseError struct {
+	Name string
+	Err  error
+}
+
+func (e *ParseError) Error() string { return e.Err.Error() }
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// DevMode reports whether templates are reloaded from the filesystem.
+func (t *Templates) DevMode() bool {
+	return t.dir != ""
+}
+
+// get returns the named page in the best match for lang. In dev mode it is
+// parsed again from the filesystem whenever the page or layout file changed
+// since the last call, and a file that does not parse is reported as a
+// *ParseError rather than falling back to the embedded version.
+func (t *Templates) get(name, lang string, embedded *template.Template) (*template.Template, error) {
+	lang = t.locales.Match(lang)
+
+	var page, layout fileStamp
+	onDisk := false
+	if t.dir != "" {
+		var pageOnDisk, layoutOnDisk bool
+		page, pageOnDisk = stampFile(filepath.Join(t.dir, name))
+		layout, layoutOnDisk = stampFile(filepath.Join(t.dir, layoutName))
+		onDisk = pageOnDisk || layoutOnDisk
+	}
+	if !onDisk && lang == i18n.DefaultLang {
+		if embedded == nil {
+			return nil, t.unavailable(name, t.failed[name])
+		}
+		return embedded, nil
 	}
-	return t.Redoc
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := pageKey{name: name, lang: lang}
+	if cached, ok := t.cache[key]; ok && cached.page.equal(page) && cached.layout.equal(layout) {
+		return cached.tmpl, t.unavailable(name, cached.err)
+	}
+
+	cached := cachedTemplate{page: page, layout: layout}
+	cached.tmpl, cached.err = parseTemplate(name, t.dir, newFuncMap(t.basePath, t.locales, lang))
+	if t.cache == nil {
+		t.cache = make(map[pageKey]cachedTemplate)
+	}
+	t.cache[key] = cached
+	return cached.tmpl, t.unavailable(name, cached.err)
 }
 
-func NewTemplates(devMode bool) (*Templates, error) {
-	ui, err := parseTemplate("ui.html", devMode)
-	if err != nil {
-		return nil, err
+// unavailable marks err as ErrTemplateUnavailable when the page failed to
+// load at startup, so it cannot fall back to a working version.
+func (t *Templates) unavailable(name string, err error) error {
+	if err == nil || t.failed[name] == nil {
+		return err
 	}
+	return fmt.Errorf("%w: %w", ErrTemplateUnavailable, err)
+}
 
-	logs, err := parseTemplate("logs.html", devMode)
-	if err != nil {
-		return nil, err
+// Err returns the errors of the pages that failed to load, joined, or nil
+// when all of them loaded. In dev mode a page fixed on disk since is served
+// again, but still counts here until the templates are loaded again.
+func (t *Templates) Err() error {
+	var errs []error
+	for _, name := range pageNames {
+		if err := t.failed[name]; err != nil {
+			errs = append(errs, err)
+		}
 	}
+	return errors.Join(errs...)
+}
 
-	notFound, err := parseTemplate("404.html", devMode)
-	if err != nil {
+// GetUI returns UI template, reloading from filesystem if in dev mode
+func (t *Templates) GetUI(lang string) (*template.Template, error) {
+	return t.get("ui.html", lang, t.UI)
+}
+
+// GetLogs returns Logs template, reloading from filesystem if in dev mode
+func (t *Templates) GetLogs(lang string) (*template.Template, error) {
+	return t.get("logs.html", lang, t.Logs)
+}
+
+// GetNotFound returns NotFound template, reloading from filesystem if in dev mode
+func (t *Templates) GetNotFound(lang string) (*template.Template, error) {
+	return t.get("404.html", lang, t.NotFound)
+}
+
+// GetError returns the generic error template, reloading from filesystem if in dev mode
+func (t *Templates) GetError(lang string) (*template.Template, error) {
+	return t.get("error.html", lang, t.Error)
+}
+
+// GetSwagger returns Swagger template, reloading from filesystem if in dev mode
+func (t *Templates) GetSwagger(lang string) (*template.Template, error) {
+	return t.get("swagger.html", lang, t.Swagger)
+}
+
+// GetRedoc returns Redoc template, reloading from filesystem if in dev mode
+func (t *Templates) GetRedoc(lang string) (*template.Template, error) {
+	return t.get("redoc.html", lang, t.Redoc)
+}
+
+// GetStatus returns the status page template, reloading from filesystem if in dev mode
+func (t *Templates) GetStatus(lang string) (*template.Template, error) {
+	return t.get("status.html", lang, t.Status)
+}
+
+// NewTemplates parses the page templates, each together with the shared
+// layout. dir, when not empty, turns on dev mode: template files in dir are
+// used instead of the embedded ones and re-read when they change; files
+// missing from dir fall back to the embedded version. basePath is the
+// prefix greetd is mounted under (e.g. "/greetd"), or empty when served
+// from the root. locales holds the page text; nil uses
+// i18n.DefaultLocales. It fails if any page does not load; LoadTemplates
+// does not.
+func NewTemplates(dir, basePath string, locales *i18n.Catalog) (*Templates, error) {
+	templates := LoadTemplates(dir, basePath, locales)
+	if err := templates.Err(); err != nil {
 		return nil, err
 	}
+	return templates, nil
+}
 
-	swagger, err := parseTemplate("swagger.html", devMode)
-	if err != nil {
-		return nil, err
+// LoadTemplates is NewTemplates, except that a page that fails to load is
+// recorded in Err rather than failing. Its getter returns an error wrapping
+// ErrTemplateUnavailable whenever it cannot parse the page again, which in
+// production mode is always.
+func LoadTemplates(dir, basePath string, locales *i18n.Catalog) *Templates {
+	if locales == nil {
+		locales = i18n.DefaultLocales()
 	}
+	funcs := newFuncMap(basePath, locales, i18n.DefaultLang)
 
-	redoc, err := parseTemplate("redoc.html", devMode)
-	if err != nil {
-		return nil, err
+	t := &Templates{
+		dir:      dir,
+		basePath: basePath,
+		locales:  locales,
+		failed:   make(map[string]error),
+	}
+	pages := make(map[string]*template.Template, len(pageNames))
+	for _, name := range pageNames {
+		tmpl, err := parseTemplate(name, dir, funcs)
+		if err != nil {
+			t.failed[name] = err
+			continue
+		}
+		pages[name] = tmpl
 	}
 
-	return &Templates{
-		UI:       ui,
-		Logs:     logs,
-		NotFound: notFound,
-		Swagger:  swagger,
-		Redoc:    redoc,
-		devMode:  devMode,
-	}, nil
+	t.UI = pages["ui.html"]
+	t.Logs = pages["logs.html"]
+	t.NotFound = pages["404.html"]
+	t.Error = pages["error.html"]
+	t.Swagger = pages["swagger.html"]
+	t.Redoc = pages["redoc.html"]
+	t.Status = pages["status.html"]
+	return t
+}
+
+// FallbackPage returns a minimal HTML page, built without any template, for
+// a page whose template is unavailable. It names the template and adds
+// detail, when not empty, below.
+func FallbackPage(name, detail string) []byte {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>500 Internal Server Error - Greetd</title></head>\n<body>\n")
+	b.WriteString("<p style=\"background:#b91c1c;color:#fff;padding:0.75em 1em;font-family:sans-serif\"><strong>500 Internal Server Error</strong>: ")
+	fmt.Fprintf(&b, "the page template %s is unavailable, so this page cannot be shown.</p>\n", html.EscapeString(name))
+	if detail != "" {
+		fmt.Fprintf(&b, "<pre>%s</pre>\n", html.EscapeString(detail))
+	}
+	b.WriteString("<p>The JSON API is not affected.</p>\n</body>\n</html>\n")
+	return []byte(b.String())
 }
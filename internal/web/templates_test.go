@@ -1,44 +1,421 @@
 package web
 
 import (
+	"errors"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 func TestNewTemplates(t *testing.T) {
 	// Test with dev mode false (embedded templates)
-	templates, err := NewTemplates(false)
+	templates, err := NewTemplates("", "", nil)
 	if err != nil {
-		t.Fatalf("NewTemplates(false) failed: %v", err)
+		t.Fatalf("NewTemplates(\"\") failed: %v", err)
 	}
 	if templates == nil {
-		t.Fatal("NewTemplates(false) returned nil templates")
+		t.Fatal("NewTemplates(\"\") returned nil templates")
+	}
+	if templates.DevMode() {
+		t.Error("DevMode() = true without a template directory")
 	}
 
 	// Test template getters
-	if templates.GetUI() == nil {
-		t.Error("GetUI() returned nil")
+	getters := map[string]func(lang string) (*template.Template, error){
+		"GetUI":       templates.GetUI,
+		"GetLogs":     templates.GetLogs,
+		"GetNotFound": templates.GetNotFound,
+		"GetError":    templates.GetError,
+		"GetSwagger":  templates.GetSwagger,
+		"GetRedoc":    templates.GetRedoc,
+		"GetStatus":   templates.GetStatus,
 	}
-	if templates.GetLogs() == nil {
-		t.Error("GetLogs() returned nil")
+	for name, get := range getters {
+		if tmpl, err := get("en"); err != nil || tmpl == nil {
+			t.Errorf("%s() = %v, %v", name, tmpl, err)
+		}
 	}
-	if templates.GetNotFound() == nil {
-		t.Error("GetNotFound() returned nil")
+}
+
+func TestNewTemplatesDevMode(t *testing.T) {
+	// Test with dev mode on, reading the templates in this package
+	templates, err := NewTemplates("templates", "", nil)
+	if err != nil {
+		t.Fatalf("NewTemplates(templates) failed: %v", err)
 	}
-	if templates.GetSwagger() == nil {
-		t.Error("GetSwagger() returned nil")
+	if templates == nil {
+		t.Fatal("NewTemplates(templates) returned nil templates")
 	}
-	if templates.GetRedoc() == nil {
-		t.Error("GetRedoc() returned nil")
+	if !templates.DevMode() {
+		t.Error("DevMode() = false with a template directory")
 	}
 }
 
-func TestNewTemplatesDevMode(t *testing.T) {
-	// Test with dev mode true (filesystem templates if available)
-	templates, err := NewTemplates(true)
+func TestDevModeParseError(t *testing.T) {
+	dir := t.TempDir()
+
+	// Without template files on disk the embedded ones are used.
+	templates, err := NewTemplates(dir, "", nil)
 	if err != nil {
-		t.Fatalf("NewTemplates(true) failed: %v", err)
+		t.Fatalf("NewTemplates(dir) failed: %v", err)
 	}
-	if templates == nil {
-		t.Fatal("NewTemplates(true) returned nil templates")
+
+	if err := os.WriteFile(filepath.Join(dir, "ui.html"), []byte("<p>{{ if }}</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = templates.GetUI("en")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("GetUI() error = %v, want a *ParseError", err)
+	}
+	if parseErr.Name != "ui.html" || !strings.Contains(err.Error(), "ui.html") {
+		t.Errorf("ParseError = %q for %q, want it to name ui.html", err, parseErr.Name)
+	}
+
+	if _, err := templates.GetLogs("en"); err != nil {
+		t.Errorf("GetLogs() error = %v, want the embedded template", err)
+	}
+}
+
+func TestMissingTemplate(t *testing.T) {
+	// A dev-mode directory holding every template but status.html, with the
+	// embedded templates lacking it too, as in a binary built without it.
+	dir := t.TempDir()
+	stripped := fstest.MapFS{}
+	for _, name := range append([]string{layoutName}, pageNames...) {
+		if name == "status.html" {
+			continue
+		}
+		data, err := fs.ReadFile(embeddedTemplates, "templates/"+name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		stripped["templates/"+name] = &fstest.MapFile{Data: data}
+	}
+	templateFS = stripped
+	t.Cleanup(func() { templateFS = embeddedTemplates })
+
+	if _, err := NewTemplates(dir, "", nil); err == nil {
+		t.Error("NewTemplates() succeeded without status.html")
+	}
+
+	templates := LoadTemplates(dir, "", nil)
+	var parseErr *ParseError
+	if err := templates.Err(); !errors.As(err, &parseErr) || parseErr.Name != "status.html" {
+		t.Fatalf("Err() = %v, want a *ParseError for status.html", err)
+	}
+	if templates.Status != nil {
+		t.Error("Status is set although status.html failed to load")
+	}
+
+	for _, lang := range []string{"en", "sv"} {
+		if _, err := templates.GetStatus(lang); !errors.Is(err, ErrTemplateUnavailable) {
+			t.Errorf("GetStatus(%q) error = %v, want ErrTemplateUnavailable", lang, err)
+		}
+		if _, err := templates.GetUI(lang); err != nil {
+			t.Errorf("GetUI(%q) error = %v", lang, err)
+		}
+	}
+
+	// A page that loaded is not reported as unavailable when it breaks.
+	if err := os.WriteFile(filepath.Join(dir, "ui.html"), []byte("<p>{{ if }}</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := templates.GetUI("en"); err == nil || errors.Is(err, ErrTemplateUnavailable) {
+		t.Errorf("GetUI() error = %v, want a parse error", err)
+	}
+}
+
+func TestFallbackPage(t *testing.T) {
+	page := string(FallbackPage("status.html", "<missing>"))
+	for _, want := range []string{"500 Internal Server Error", "status.html", "&lt;missing&gt;"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("FallbackPage() = %q, want it to contain %q", page, want)
+		}
+	}
+	if strings.Contains(string(FallbackPage("ui.html", "")), "<pre>") {
+		t.Error("FallbackPage() without detail includes a detail block")
+	}
+}
+
+func TestDevModeCache(t *testing.T) {
+	dir := t.TempDir()
+
+	templates, err := NewTemplates(dir, "", nil)
+	if err != nil {
+		t.Fatalf("NewTemplates(dir) failed: %v", err)
+	}
+
+	uiPath := filepath.Join(dir, "ui.html")
+	write := func(content string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(uiPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(uiPath, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	render := func() (*template.Template, string) {
+		t.Helper()
+		tmpl, err := templates.GetUI("en")
+		if err != nil {
+			t.Fatalf("GetUI() error = %v", err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, nil); err != nil {
+			t.Fatal(err)
+		}
+		return tmpl, b.String()
+	}
+
+	modTime := time.Now().Add(-time.Hour)
+	write("first", modTime)
+	first, out := render()
+	if out != "first" {
+		t.Errorf("GetUI() rendered %q, want %q", out, "first")
+	}
+
+	// An unchanged file is not parsed again, even by concurrent callers.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tmpl, _ := templates.GetUI("en"); tmpl != first {
+				t.Error("GetUI() parsed an unchanged file again")
+			}
+		}()
+	}
+	wg.Wait()
+
+	write("second", modTime.Add(time.Second))
+	second, out := render()
+	if second == first || out != "second" {
+		t.Errorf("GetUI() rendered %q after the file changed, want %q", out, "second")
+	}
+}
+
+func TestDevModeLayout(t *testing.T) {
+	dir := t.TempDir()
+
+	templates, err := NewTemplates(dir, "", nil)
+	if err != nil {
+		t.Fatalf("NewTemplates(dir) failed: %v", err)
+	}
+
+	layoutPath := filepath.Join(dir, "layout.html")
+	write := func(content string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(layoutPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(layoutPath, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A layout on disk is used with the embedded pages, and re-read when it
+	// changes.
+	modTime := time.Now().Add(-time.Hour)
+	write(`{{define "layout"}}<main>{{block "title" .}}{{end}}</main>{{end}}`, modTime)
+	tmpl, err := templates.GetNotFound("en")
+	if err != nil {
+		t.Fatalf("GetNotFound() error = %v", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "<main>Page Not Found - Greetd</main>"; got != want {
+		t.Errorf("GetNotFound() rendered %q, want %q", got, want)
+	}
+
+	write(`{{define "layout"}}{{ if }}{{end}}`, modTime.Add(time.Second))
+	_, err = templates.GetNotFound("en")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Name != "layout.html" {
+		t.Errorf("GetNotFound() error = %v, want a *ParseError for layout.html", err)
+	}
+}
+
+func TestLayoutNav(t *testing.T) {
+	templates, err := NewTemplates("", "/greetd", nil)
+	if err != nil {
+		t.Fatalf("NewTemplates failed: %v", err)
+	}
+
+	tmpl, err := templates.GetNotFound("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, link := range []string{`href="/greetd/ui"`, `href="/greetd/logs"`, `href="/greetd/status"`, `href="/greetd/swagger/"`, `href="/greetd/api/v1/health"`} {
+		if !strings.Contains(b.String(), link) {
+			t.Errorf("navigation is missing %s", link)
+		}
+	}
+	if strings.Count(b.String(), "<html") != 1 {
+		t.Error("page should be rendered inside a single layout")
+	}
+}
+
+func TestLocalizedPages(t *testing.T) {
+	templates, err := NewTemplates("", "", nil)
+	if err != nil {
+		t.Fatalf("NewTemplates failed: %v", err)
+	}
+
+	render := func(lang string) string {
+		t.Helper()
+		tmpl, err := templates.GetNotFound(lang)
+		if err != nil {
+			t.Fatalf("GetNotFound(%q) error = %v", lang, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, nil); err != nil {
+			t.Fatal(err)
+		}
+		return b.String()
+	}
+
+	sv := render("sv-SE")
+	for _, want := range []string{`<html lang="sv">`, "<title>Sidan hittades inte - Greetd</title>", ">Loggar</a>", `class="nav-lang-current" aria-current="true">Svenska</a>`, `href="?lang=en"`} {
+		if !strings.Contains(sv, want) {
+			t.Errorf("Swedish page is missing %s", want)
+		}
+	}
+
+	for _, lang := range []string{"en", "xx", ""} {
+		if page := render(lang); !strings.Contains(page, `<html lang="en">`) || !strings.Contains(page, ">Logs</a>") {
+			t.Errorf("GetNotFound(%q) should render in English", lang)
+		}
+	}
+
+	first, _ := templates.GetNotFound("sv")
+	if second, _ := templates.GetNotFound("sv"); second != first {
+		t.Error("GetNotFound(sv) parsed the page again")
+	}
+	if embedded, _ := templates.GetNotFound("en"); embedded != templates.NotFound {
+		t.Error("GetNotFound(en) should return the page parsed at startup")
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	cet := time.FixedZone("CET", 3600)
+	if got, want := FormatTime(time.Date(2024, 1, 2, 16, 4, 5, 0, cet)), "2024-01-02 15:04 UTC"; got != want {
+		t.Errorf("FormatTime() = %q, want %q", got, want)
+	}
+	if got := FormatTime(time.Time{}); got != "" {
+		t.Errorf("FormatTime(zero) = %q, want empty", got)
+	}
+}
+
+func TestHumanDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0 seconds"},
+		{time.Second, "1 second"},
+		{90 * time.Second, "1 minute"},
+		{3*time.Hour + 59*time.Minute, "3 hours"},
+		{48 * time.Hour, "2 days"},
+	}
+
+	for _, tt := range tests {
+		if got := HumanDuration(tt.d); got != tt.want {
+			t.Errorf("HumanDuration(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		n    int
+		s    string
+		want string
+	}{
+		{5, "hello", "hello"},
+		{4, "hello", "hel…"},
+		{3, "åäöü", "åä…"},
+		{0, "hello", "hello"},
+	}
+
+	for _, tt := range tests {
+		if got := Truncate(tt.n, tt.s); got != tt.want {
+			t.Errorf("Truncate(%d, %q) = %q, want %q", tt.n, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestTimeAgo(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{0, "just now"},
+		{59 * time.Second, "just now"},
+		{time.Minute, "1 minute ago"},
+		{5*time.Minute + 30*time.Second, "5 minutes ago"},
+		{time.Hour, "1 hour ago"},
+		{23 * time.Hour, "23 hours ago"},
+		{49 * time.Hour, "2 days ago"},
+	}
+
+	for _, tt := range tests {
+		if got := TimeAgo(now.Add(-tt.ago), now); got != tt.want {
+			t.Errorf("TimeAgo(-%s) = %q, want %q", tt.ago, got, tt.want)
+		}
+	}
+}
+
+func TestLookupAsset(t *testing.T) {
+	asset, hashed, ok := LookupAsset("swagger-ui/swagger-ui-bundle.js")
+	if !ok {
+		t.Fatal("swagger-ui-bundle.js should be embedded")
+	}
+	if hashed {
+		t.Error("plain name should not be reported as hashed")
+	}
+	if !strings.Contains(asset.ContentType, "javascript") {
+		t.Errorf("unexpected content type: %s", asset.ContentType)
+	}
+
+	url := AssetURL("swagger-ui/swagger-ui-bundle.js")
+	if url != "/static/"+asset.HashedName() {
+		t.Errorf("unexpected asset URL: %s", url)
+	}
+
+	byHash, hashed, ok := LookupAsset(asset.HashedName())
+	if !ok || !hashed || byHash != asset {
+		t.Error("hashed name should resolve to the same asset")
+	}
+
+	if icon, _, ok := LookupAsset("favicon.ico"); !ok || icon.ContentType != "image/x-icon" {
+		t.Errorf("favicon.ico should be embedded as image/x-icon, got %v", icon)
+	}
+
+	if theme, _, ok := LookupAsset("theme.js"); !ok || !strings.Contains(theme.ContentType, "javascript") {
+		t.Errorf("theme.js should be embedded as JavaScript, got %v", theme)
+	}
+
+	if AssetURL("does/not/exist.js") != "" {
+		t.Error("AssetURL should be empty for missing assets")
 	}
 }